@@ -16,6 +16,7 @@ package olric
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/buraksezer/olric/internal/dmap"
@@ -25,6 +26,33 @@ import (
 
 const DefaultScanCount = 10
 
+const (
+	// StandbyKeyPrefix is the prefix of the keys that keep the values written by SetStandby.
+	// The standby value is stored in the same DMap with the key StandbyKeyPrefix + key.
+	StandbyKeyPrefix = dmap.StandbyKeyPrefix
+
+	// ProtectedValueKeyPrefix is the prefix of the keys that keep the values written by
+	// LockContext.LeaseAndPut. The protected value of a lock is stored in the same DMap
+	// with the key ProtectedValueKeyPrefix + key.
+	ProtectedValueKeyPrefix = dmap.ProtectedValueKeyPrefix
+
+	// ClaimKeyPrefix is the prefix of the keys that keep the claim markers written by
+	// ClaimNext. The marker of a claimed entry is stored in the same DMap with the key
+	// ClaimKeyPrefix + key.
+	ClaimKeyPrefix = dmap.ClaimKeyPrefix
+)
+
+// ListTrim denotes the end of a capped list that LPushCapped drops the elements from.
+type ListTrim = dmap.ListTrim
+
+const (
+	// TrimTail drops the oldest elements, the list keeps the most recent elements.
+	TrimTail = dmap.TrimTail
+
+	// TrimHead drops the newest elements, the values pushed after the list is full are discarded.
+	TrimHead = dmap.TrimHead
+)
+
 // Member denotes a member of the Olric cluster.
 type Member struct {
 	// Member name in the cluster. It's also host:port of the node.
@@ -41,6 +69,26 @@ type Member struct {
 	Coordinator bool
 }
 
+// KeyTTL denotes a key with its remaining time to live.
+type KeyTTL struct {
+	Key string
+	TTL time.Duration
+}
+
+// Instance denotes a live instance of a service registered by Register, with its
+// metadata and the remaining time to live of its registration.
+type Instance struct {
+	Name string
+	Meta []byte
+	TTL  time.Duration
+}
+
+// KeyAccess denotes a key with its approximate access count.
+type KeyAccess struct {
+	Key   string
+	Count int64
+}
+
 // Iterator defines an interface to implement iterators on the distributed maps.
 type Iterator interface {
 	// Next returns true if there is more key in the iterator implementation.
@@ -54,6 +102,21 @@ type Iterator interface {
 	Close()
 }
 
+// LockOptions configures a LockWithOptions call.
+type LockOptions struct {
+	// Deadline is the maximum time to wait to acquire the lock. If it's zero, the lock
+	// is tried only once.
+	Deadline time.Duration
+
+	// Lease is the duration after which the lock is released automatically. The
+	// lock never expires if it's zero.
+	Lease time.Duration
+
+	// PollInterval is the interval between two attempts to acquire the lock. The
+	// server's default, config.DMaps.LockPollInterval, is used if it's zero.
+	PollInterval time.Duration
+}
+
 // LockContext interface defines methods to manage locks on distributed maps.
 type LockContext interface {
 	// Unlock releases an acquired lock for the given key. It returns ErrNoSuchLock
@@ -61,8 +124,29 @@ type LockContext interface {
 	Unlock(ctx context.Context) error
 
 	// Lease sets or updates the timeout of the acquired lock for the given key.
-	// It returns ErrNoSuchLock if there is no lock for the given key.
+	// It's the way to renew a lock that is held longer than expected: the token
+	// is verified on the partition owner before the timeout is updated. It returns
+	// ErrNoSuchLock if there is no lock for the given key, or if the lock has been
+	// released or acquired by someone else.
 	Lease(ctx context.Context, duration time.Duration) error
+
+	// LeaseAndPut updates the timeout of the acquired lock and writes its protected value
+	// in a single round trip. The partition owner of the lock verifies the token, updates
+	// the timeout and writes the value under the lock of the key, so the lease cannot be
+	// lost in between. It returns ErrNoSuchLock without writing the value if the lock has
+	// been released or acquired by someone else. The value is stored with the key
+	// ProtectedValueKeyPrefix + key in the same DMap.
+	LeaseAndPut(ctx context.Context, value []byte, duration time.Duration) error
+
+	// Token returns the fencing token of the lock. The locks are approximate, a holder
+	// that is paused beyond its lease may still think that it holds the lock. The fencing
+	// tokens of a key increase monotonically every time the lock is acquired, even after
+	// it's released or expired. The counter of a key is dropped after an hour without an
+	// acquisition, a new one starts from the current time in microseconds. Pass it to
+	// the protected resource along with the writes, so the resource can reject the writes
+	// of a holder that has been paused beyond its lease while a newer holder has acquired
+	// the lock.
+	Token() uint64
 }
 
 // PutOption is a function for define options to control behavior of the Put command.
@@ -116,6 +200,8 @@ func XX() PutOption {
 
 type dmapConfig struct {
 	storageEntryImplementation func() storage.Entry
+	readTimeout                time.Duration
+	writeTimeout               time.Duration
 }
 
 // DMapOption is a function for defining options to control behavior of distributed map instances.
@@ -128,6 +214,25 @@ func StorageEntryImplementation(e func() storage.Entry) DMapOption {
 	}
 }
 
+// ReadTimeout sets the maximum time of Get calls on the DMap instance. A call that takes
+// longer returns ErrTimeout. It's applied in addition to the readTimeout in the DMap
+// configuration of the cluster.
+func ReadTimeout(timeout time.Duration) DMapOption {
+	return func(cfg *dmapConfig) {
+		cfg.readTimeout = timeout
+	}
+}
+
+// WriteTimeout sets the maximum time of Put and Delete calls on the DMap instance. A call
+// that takes longer returns ErrTimeout, a write that is already sent to the cluster may
+// still be applied. It's applied in addition to the writeTimeout in the DMap configuration
+// of the cluster.
+func WriteTimeout(timeout time.Duration) DMapOption {
+	return func(cfg *dmapConfig) {
+		cfg.writeTimeout = timeout
+	}
+}
+
 // ScanOption is a function for defining options to control behavior of the SCAN command.
 type ScanOption func(*dmap.ScanConfig)
 
@@ -170,34 +275,223 @@ type DMap interface {
 	// of the argument after Delete returns.
 	Delete(ctx context.Context, keys ...string) (int, error)
 
+	// DeleteIfExpiredBefore deletes the key only if its expiry time is at or before ts, in
+	// milliseconds since the Unix epoch. It returns true if the key is deleted. A key without
+	// a TTL is never deleted. The check and the deletion are atomic on the partition owner,
+	// so a cleanup worker doesn't delete a key whose TTL is extended concurrently.
+	DeleteIfExpiredBefore(ctx context.Context, key string, ts int64) (bool, error)
+
 	// Incr atomically increments the key by delta. The return value is the new value
-	// after being incremented or an error.
+	// after being incremented or an error. It returns
+	// ErrNotAnInteger if the value is not an integer.
 	Incr(ctx context.Context, key string, delta int) (int, error)
 
 	// Decr atomically decrements the key by delta. The return value is the new value
-	// after being decremented or an error.
+	// after being decremented or an error. It returns
+	// ErrNotAnInteger if the value is not an integer.
 	Decr(ctx context.Context, key string, delta int) (int, error)
 
+	// NextSequence returns the next number of the sequence stored at the key. The
+	// numbers start from 1, they are unique and increasing across the cluster.
+	NextSequence(ctx context.Context, name string) (int64, error)
+
+	// ReserveSequenceBlock reserves n numbers of the sequence stored at the key in one
+	// request and returns the first one. The caller owns the numbers in [start, start+n)
+	// and can allocate them locally, the blocks never overlap. The counter is a regular
+	// key, so it returns an error if the DMap can expire or evict it. See
+	// NoEvictKeyPatterns in the DMap configuration.
+	ReserveSequenceBlock(ctx context.Context, name string, n int) (start int64, err error)
+
+	// HIncrBy atomically increments the field of the hash stored at the key by delta. The
+	// hash and the field are created if they don't exist. The return value is the new
+	// value of the field. It returns ErrNotAnInteger if the field is not an integer and
+	// ErrNotAHash if the value is not a hash.
+	HIncrBy(ctx context.Context, key, field string, delta int) (int, error)
+
+	// HMSet sets the fields of the hash stored at the key in one pass. The hash is
+	// created if it doesn't exist, the other fields and the TTL are preserved. It
+	// returns ErrNotAHash if the value is not a hash.
+	HMSet(ctx context.Context, key string, fields map[string][]byte) error
+
+	// HMGet returns the fields of the hash stored at the key in one pass. The absent
+	// fields are omitted. It returns ErrNotAHash if the value is not a hash.
+	HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error)
+
+	// PutWithVersion sets the value of the key if its current version is equal to
+	// expectedVersion, and increments the version atomically. An absent key has version 0.
+	// It returns the new version, or ErrVersionMismatch if the version is different. The
+	// version is kept in the metadata of the entry, Get returns the value and
+	// GetResponse.Version returns the version. A Put without a version resets the version
+	// to 0. The TTL of the key is preserved.
+	PutWithVersion(ctx context.Context, key string, value []byte, expectedVersion int64) (int64, error)
+
+	// PutIfField atomically sets the value of the key if the field of the stored object is
+	// equal to expected, and returns true if the value is written. The stored object is either
+	// a hash or a JSON object, the string fields of a JSON object are compared without the
+	// quotes. It returns false if the object doesn't have the field, ErrKeyNotFound if the
+	// key does not exist and ErrNotAnObject if the value is not an object.
+	PutIfField(ctx context.Context, key, field string, expected, value []byte) (bool, error)
+
+	// Merge atomically applies a JSON merge patch (RFC 7386) to the JSON document stored at
+	// the key. The fields of the patch replace the fields of the document, the objects are
+	// merged recursively and a null deletes the field. The key is created from the patch if
+	// it doesn't exist. It returns ErrNotAnObject if the stored value is not JSON.
+	Merge(ctx context.Context, key string, patch []byte) error
+
+	// SetStandby stages the value in the standby slot of the key. The active slot is the
+	// value of the key, it's preserved and Get keeps returning it. The standby value is
+	// stored with the key StandbyKeyPrefix + key and expires with the active value.
+	SetStandby(ctx context.Context, key string, value []byte) error
+
+	// PromoteStandby atomically swaps the active and the standby slots of the key, the
+	// previous active value becomes the standby one. Calling it again rolls the change
+	// back. It returns ErrNoStandby if the key has no standby value.
+	PromoteStandby(ctx context.Context, key string) error
+
+	// LPushCapped atomically pushes the values to the head of the list stored at the key
+	// and trims the list to max elements, the last value becomes the head. trim decides
+	// which end of the list is dropped. It returns the length of the list, or ErrNotAList
+	// if the value is not a list.
+	LPushCapped(ctx context.Context, key string, max int, trim ListTrim, values ...[]byte) (int, error)
+
+	// LRange returns the elements of the list stored at the key between start and stop,
+	// both inclusive. The head is at index 0, the negative indexes are counted from the
+	// tail. It returns ErrNotAList if the value is not a list.
+	LRange(ctx context.Context, key string, start, stop int) ([][]byte, error)
+
+	// SetBit atomically sets or clears the bit at the offset in the value stored at the key,
+	// and returns the previous bit. The value is zero-padded if the offset is beyond its end.
+	// The semantics is the same as Redis SETBIT.
+	SetBit(ctx context.Context, key string, offset, value int) (int, error)
+
+	// GetBit returns the bit at the offset in the value stored at the key. The bits beyond
+	// the end of the value and the bits of an absent key are 0.
+	GetBit(ctx context.Context, key string, offset int) (int, error)
+
+	// DecrAndDeleteAtZero atomically decrements the key by delta. If the result is equal to or
+	// less than zero, the key is deleted, deleted is true and remaining is zero. It returns
+	// ErrKeyNotFound if the key does not exist and ErrNotAnInteger if the value is not an integer.
+	DecrAndDeleteAtZero(ctx context.Context, key string, delta int) (remaining int, deleted bool, err error)
+
+	// GetAndReset atomically returns the integer value stored at the key and sets it to zero,
+	// so the increments between the read and the reset are not lost. It returns 0 if the key
+	// does not exist, and ErrNotAnInteger if the value is not an integer.
+	GetAndReset(ctx context.Context, key string) (int, error)
+
+	// GetDel atomically returns the value of the key and deletes it, so only one of the
+	// concurrent callers gets the value, like consuming a one-shot token. It returns
+	// ErrKeyNotFound if the key does not exist.
+	GetDel(ctx context.Context, key string) (*GetResponse, error)
+
+	// Append atomically appends suffix to the value of the key and returns the length of the
+	// new value. If the key does not exist, it is created with suffix as its value.
+	Append(ctx context.Context, key string, suffix []byte) (int, error)
+
 	// GetPut atomically sets the key to value and returns the old value stored at key. It returns nil if there is no
 	// previous value.
 	GetPut(ctx context.Context, key string, value interface{}) (*GetResponse, error)
 
+	// GetByTag returns the values of all keys whose hash tag is tag, like "user:42" for the
+	// keys "{user:42}:profile" and "{user:42}:session", mapped by their keys. The keys with
+	// the same tag are stored on the same partition, so it reads a single partition on its
+	// owner in one pass. It returns ErrHashTagsDisabled if the hash tags are not enabled.
+	GetByTag(ctx context.Context, tag string) (map[string]*GetResponse, error)
+
+	// Rotate atomically sets the key to value and returns the old value. The old value is
+	// copied to the key with the ":previous" suffix and expires after graceTTL, so the old
+	// value stays retrievable during a zero-downtime rotation, like a credential rotation.
+	// It returns nil if the key doesn't exist, and no previous value is written.
+	Rotate(ctx context.Context, key string, value interface{}, graceTTL time.Duration) (*GetResponse, error)
+
+	// SetNXGet atomically sets the key to value with the given TTL if the key does not exist.
+	// It returns true and the newly written entry if the key is set. Otherwise, it returns false
+	// and the entry of the current holder. A zero ttl means no expiration.
+	SetNXGet(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, *GetResponse, error)
+
+	// GetOrSet returns the value of the key and true if the key exists. Otherwise, it sets
+	// the key to value with the given TTL, and returns the new value and false. It runs on
+	// the partition owner in a single round trip, so the concurrent callers agree on a
+	// single value. A zero ttl means no expiration.
+	GetOrSet(ctx context.Context, key string, value interface{}, ttl time.Duration) (*GetResponse, bool, error)
+
+	// ClaimNext atomically claims an unclaimed entry and returns its key and value. The
+	// entry stays claimed for claimTTL, so the claim of a crashed worker is released
+	// automatically. The partition owners are tried in turn. It returns ErrNoAvailable
+	// if all entries are claimed. The claim is a marker entry with the key ClaimKeyPrefix +
+	// key and the TTL claimTTL, so it's replicated and moved with its partition.
+	ClaimNext(ctx context.Context, claimTTL time.Duration) (string, *GetResponse, error)
+
 	// IncrByFloat atomically increments the key by delta. The return value is the new value
-	// after being incremented or an error.
+	// after being incremented or an error. It returns ErrInvalidFloat if the delta or the
+	// stored value is NaN or Infinity, or the result would overflow to Infinity.
 	IncrByFloat(ctx context.Context, key string, delta float64) (float64, error)
 
+	// Eval runs a script atomically on the given keys. All keys must belong to the same
+	// partition owner, otherwise it returns ErrCrossOwnerKeys. The script runs on the owner
+	// under the locks of the keys and its writes are applied only if it returns successfully.
+	// A script runs at most one second. The return value is nil, an int64 or a string.
+	//
+	// The scripts are written in a small language without loops:
+	//
+	//	let balance = get(KEYS[1])
+	//	if balance != nil && balance >= ARGV[1] {
+	//		set(KEYS[1], balance - ARGV[1])
+	//		incr(KEYS[2], ARGV[1])
+	//		return 1
+	//	}
+	//	return 0
+	//
+	// The built-in functions are get, set, del, exists, incr and error.
+	Eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error)
+
+	// PutAllIfNoneExist sets all the given keys only if none of them exists, it's
+	// all-or-nothing. It returns false without writing anything if any of the keys already
+	// exists. All keys must belong to the same partition owner, otherwise it returns
+	// ErrCrossOwnerKeys. It's useful to bootstrap a group of keys idempotently.
+	PutAllIfNoneExist(ctx context.Context, pairs map[string][]byte) (bool, error)
+
+	// MemoryUsage returns the number of bytes that the key occupies in the storage engine,
+	// including the key and the metadata, like Redis' MEMORY USAGE. The value is not fetched.
+	// It returns ErrKeyNotFound if the key doesn't exist.
+	MemoryUsage(ctx context.Context, key string) (int, error)
+
+	// Exists returns the number of the keys that exist. A key is counted as many times
+	// as it's given. The keys are grouped by their partition owners and every owner is
+	// queried once. The values are not fetched.
+	Exists(ctx context.Context, keys ...string) (int, error)
+
+	// PutMany sets the raw values of the keys with the same options. The pairs are grouped
+	// by their partition owners and every owner receives one request. The batch is not
+	// atomic: a failed pair, for example an existing key with NX, stops the rest of the
+	// batch on its owner, and the pairs that were already written are kept.
+	PutMany(ctx context.Context, pairs map[string][]byte, options ...PutOption) error
+
+	// GetMany returns the raw values of the keys. The absent keys are omitted. The keys
+	// are grouped by their partition owners and every owner is queried once.
+	GetMany(ctx context.Context, keys []string) (map[string][]byte, error)
+
+	// GetTTLMany returns the remaining TTLs of the keys in milliseconds. The keys without
+	// a TTL are mapped to -1 and the absent keys are omitted. The keys are grouped by their
+	// partition owners and every owner is queried once. The values are not fetched.
+	GetTTLMany(ctx context.Context, keys []string) (map[string]int64, error)
+
+	// TTL returns the remaining time to live of the key. It returns zero if the key has no
+	// expiry, and ErrKeyNotFound if the key does not exist. The value is not fetched.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+
 	// Expire updates the expiry for the given key. It returns ErrKeyNotFound if
 	// the DB does not contain the key. It's thread-safe.
 	Expire(ctx context.Context, key string, timeout time.Duration) error
 
+	// Persist removes the expiry of the given key, so it never expires. The value is
+	// not rewritten. It returns ErrKeyNotFound if the DB does not contain the key.
+	Persist(ctx context.Context, key string) error
+
 	// Lock sets a lock for the given key. Acquired lock is only for the key in
 	// this dmap.
 	//
 	// It returns immediately if it acquires the lock for the given key. Otherwise,
 	// it waits until deadline.
-	//
-	// You should know that the locks are approximate, and only to be used for
-	// non-critical purposes.
 	Lock(ctx context.Context, key string, deadline time.Duration) (LockContext, error)
 
 	// LockWithTimeout sets a lock for the given key. If the lock is still unreleased
@@ -207,11 +501,60 @@ type DMap interface {
 	//
 	// It returns immediately if it acquires the lock for the given key. Otherwise,
 	// it waits until deadline.
-	//
-	// You should know that the locks are approximate, and only to be used for
-	// non-critical purposes.
 	LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration) (LockContext, error)
 
+	// LockWithOptions sets a lock for the given key. The acquisition deadline, the
+	// lease and the poll interval are set separately by LockOptions. Cancelling
+	// ctx aborts the acquisition, it returns the context's error in this case.
+	LockWithOptions(ctx context.Context, key string, opts LockOptions) (LockContext, error)
+
+	// TryLock tries to acquire the lock for the given key only once. It returns
+	// ErrLockNotAcquired immediately if the lock is held by someone else. The lock
+	// is released automatically after timeout, it never expires if timeout is zero.
+	TryLock(ctx context.Context, key string, timeout time.Duration) (LockContext, error)
+
+	// LockInfo returns whether the lock of the key is held and its remaining TTL, without
+	// trying to acquire it. The TTL is zero if the lock never expires. It neither acquires
+	// nor modifies the lock, it's useful to debug the stuck locks.
+	LockInfo(ctx context.Context, key string) (held bool, ttl time.Duration, err error)
+
+	// AcquirePermit takes a permit from the distributed counting semaphore stored at
+	// the key. At most max permits are held at the same time across the cluster. It
+	// doesn't block, ok is false if the semaphore is full. The permit is released by
+	// calling release, or automatically after ttl if the holder crashes.
+	AcquirePermit(ctx context.Context, key string, max int, ttl time.Duration) (release func(ctx context.Context) error, ok bool, err error)
+
+	// Register registers the instance of the service with its metadata, like its address.
+	// The registration expires after ttl, the returned heartbeat extends it by ttl again and
+	// should be called periodically. The heartbeat returns ErrNoSuchInstance if the
+	// registration is expired or the instance is registered again somewhere else, it never
+	// clobbers the new registration.
+	Register(ctx context.Context, service, instance string, meta []byte, ttl time.Duration) (heartbeat func(ctx context.Context) error, err error)
+
+	// Discover returns the live instances of the service sorted by their names. The
+	// instances without a heartbeat disappear when their registrations expire.
+	Discover(ctx context.Context, service string) ([]Instance, error)
+
+	// Campaign runs a campaign of the candidate for the leadership of the election. The
+	// leader holds a lease of leaseTTL, which is renewed automatically in the background.
+	// If isLeader is false, another candidate holds the lease, call Campaign again to
+	// take over after the lease of the leader expires. resign stops the renewals and
+	// releases the lease, so a new leader can be elected. The renewals stop when the client
+	// is closed, or the lease cannot be renewed before it expires. Use Leader to observe
+	// the leader, or CampaignWithLeadership to learn when the leadership is lost.
+	Campaign(ctx context.Context, election, candidateID string, leaseTTL time.Duration) (isLeader bool, resign func(), err error)
+
+	// CampaignWithLeadership runs a campaign like Campaign. If the candidate is elected,
+	// the returned leadership context is cancelled when the leadership is lost: another
+	// candidate takes the lease, the lease cannot be renewed before it expires, resign is
+	// called or the client is closed. The leader should stop acting as the leader then.
+	// leadership is nil if another candidate holds the lease.
+	CampaignWithLeadership(ctx context.Context, election, candidateID string, leaseTTL time.Duration) (leadership context.Context, resign func(), err error)
+
+	// Leader returns the candidate that holds the lease of the election. It returns
+	// ErrNoLeader if the election has no leader.
+	Leader(ctx context.Context, election string) (string, error)
+
 	// Scan returns an iterator to loop over the keys.
 	//
 	// Available scan options:
@@ -299,6 +642,64 @@ type Client interface {
 	// Members returns a thread-safe list of cluster members.
 	Members(ctx context.Context) ([]Member, error)
 
+	// KeyPlacement returns the partition id, the primary owner and the replica owners
+	// of the given key on the given DMap. It's computed from the routing table of the
+	// cluster coordinator, so it reflects the latest placement after rebalancing.
+	KeyPlacement(ctx context.Context, dmap, key string) (*KeyPlacement, error)
+
+	// EntryAgeRange returns the oldest and the newest write timestamps of the entries
+	// in the given DMap, in nanoseconds. Every member walks its primary copies and the
+	// results are merged. It returns zero values if the DMap has no entries.
+	EntryAgeRange(ctx context.Context, dmap string) (oldest, newest int64, err error)
+
+	// ExpiringSoon returns up to limit keys in the given DMap whose remaining TTL is
+	// below within, the soonest expiring keys first. Zero limit means no limit. Every
+	// member scans all of its primary copies, so the cost is O(N) in the number of keys.
+	ExpiringSoon(ctx context.Context, dmap string, within time.Duration, limit int) ([]KeyTTL, error)
+
+	// CapTTL sets the TTL of the keys in the given DMap without a TTL or with a TTL longer
+	// than maxTTL to maxTTL, and returns the number of the adjusted keys. Every member
+	// scans all of its primary copies, so the cost is O(N) in the number of keys.
+	CapTTL(ctx context.Context, dmap string, maxTTL time.Duration) (int, error)
+
+	// HotKeys returns the n most accessed keys in the given DMap with their approximate
+	// access counts, the most accessed key first. The counts decay over time, so they
+	// reflect the recent reads. It requires the access counter of the DMap to be enabled,
+	// otherwise it returns ErrAccessCounterDisabled.
+	HotKeys(ctx context.Context, dmap string, n int) ([]KeyAccess, error)
+
+	// Diff compares two DMaps by the keys and the hashes of the values. It returns the keys
+	// that exist only in dmapA, the keys that exist only in dmapB and the keys that exist
+	// in both with different values. Only the hashes of the values are transferred, but
+	// every member walks its primary copies of both DMaps, so the cost is O(N) in the
+	// number of keys.
+	Diff(ctx context.Context, dmapA, dmapB string) (onlyA, onlyB, valueDiffers []string, err error)
+
+	// SyncReplica replaces the replica of the DMap's fragment on the given partition and
+	// member with a full copy of the primary fragment. It's useful to repair a stale
+	// replica without waiting for read-repair. It returns the number of bytes transferred.
+	// A member runs one sync per config.DMaps.ReplicaSyncInterval, it returns
+	// ErrReplicaSyncRateLimited otherwise.
+	SyncReplica(ctx context.Context, dmap string, partID uint64, member string) (int64, error)
+
+	// ExportMatching writes the entries of the DMap whose keys match the pattern to w and
+	// returns the number of exported entries. The keys are matched on the partition owners
+	// and the entries are streamed, so the memory use doesn't depend on the number of
+	// matches. The records are msgpack-encoded and can be loaded with Import. See
+	// https://pkg.go.dev/regexp for the pattern syntax.
+	ExportMatching(ctx context.Context, dmap, pattern string, w io.Writer) (int, error)
+
+	// Import reads the records written by ExportMatching from r and puts them into the DMap.
+	// The expiry times are preserved, the expired records are skipped. It returns the number
+	// of imported entries.
+	Import(ctx context.Context, dmap string, r io.Reader) (int, error)
+
+	// ReplayWAL applies the mutations in the write-ahead logs of the members to the
+	// cluster. dirs are the config.DMaps.WALDir directories of the members, the logs are
+	// merged by the times of the mutations. It's useful to reconstruct the state in a
+	// fresh cluster. It returns the number of applied mutations.
+	ReplayWAL(ctx context.Context, dirs ...string) (int, error)
+
 	// RefreshMetadata fetches a list of available members and the latest routing
 	// table version. It also closes stale clients, if there are any.
 	RefreshMetadata(ctx context.Context) error
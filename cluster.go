@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/tidwall/redcon"
 )
@@ -160,6 +161,107 @@ func (db *Olric) routingTable(ctx context.Context) (RoutingTable, error) {
 	return mapToRoutingTable(slice)
 }
 
+// KeyPlacement denotes the location of a key in the cluster.
+type KeyPlacement struct {
+	PartID        uint64
+	PrimaryOwner  string
+	ReplicaOwners []string
+}
+
+func parseKeyPlacement(result []interface{}) (*KeyPlacement, error) {
+	if len(result) != 3 {
+		return nil, fmt.Errorf("invalid response length: %d", len(result))
+	}
+
+	kp := &KeyPlacement{}
+	switch rawPartID := result[0].(type) {
+	case int64:
+		kp.PartID = uint64(rawPartID)
+	case string:
+		partID, err := strconv.ParseUint(rawPartID, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid partition id: %v: %w", rawPartID, err)
+		}
+		kp.PartID = partID
+	default:
+		return nil, fmt.Errorf("invalid partition id: %v", rawPartID)
+	}
+
+	primaryOwner, ok := result[1].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid primary owner: %v", result[1])
+	}
+	kp.PrimaryOwner = primaryOwner
+
+	replicaOwners, ok := result[2].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid replica owners: %v", result[2])
+	}
+	for _, rawOwner := range replicaOwners {
+		owner, ok := rawOwner.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid owner: %v", rawOwner)
+		}
+		kp.ReplicaOwners = append(kp.ReplicaOwners, owner)
+	}
+	return kp, nil
+}
+
+// keyPlacement returns the location of a key from the routing table of the cluster coordinator.
+func (db *Olric) keyPlacement(ctx context.Context, dmap, key string) (*KeyPlacement, error) {
+	coordinator := db.rt.Discovery().GetCoordinator()
+	if !coordinator.CompareByID(db.rt.This()) {
+		// Redirect to the cluster coordinator
+		cmd := protocol.NewClusterKeyPlacement(dmap, key).Command(ctx)
+		rc := db.client.Get(coordinator.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return nil, protocol.ConvertError(err)
+		}
+		result, err := cmd.Result()
+		if err != nil {
+			return nil, protocol.ConvertError(err)
+		}
+		return parseKeyPlacement(result)
+	}
+
+	partID := partitions.HKey(dmap, key) % db.config.PartitionCount
+	primaryOwners := db.primary.PartitionOwnersByID(partID)
+	if len(primaryOwners) == 0 {
+		return nil, fmt.Errorf("primary owners list for %d is empty", partID)
+	}
+	kp := &KeyPlacement{
+		PartID:       partID,
+		PrimaryOwner: primaryOwners[len(primaryOwners)-1].String(),
+	}
+	for _, owner := range db.backup.PartitionOwnersByID(partID) {
+		kp.ReplicaOwners = append(kp.ReplicaOwners, owner.String())
+	}
+	return kp, nil
+}
+
+func (db *Olric) clusterKeyPlacementCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	kpCmd, err := protocol.ParseClusterKeyPlacement(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	kp, err := db.keyPlacement(db.ctx, kpCmd.DMap, kpCmd.Key)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(3)
+	conn.WriteUint64(kp.PartID)
+	conn.WriteBulkString(kp.PrimaryOwner)
+	conn.WriteArray(len(kp.ReplicaOwners))
+	for _, owner := range kp.ReplicaOwners {
+		conn.WriteBulkString(owner)
+	}
+}
+
 func (db *Olric) clusterMembersCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	_, err := protocol.ParseClusterMembers(cmd)
 	if err != nil {
@@ -169,9 +271,10 @@ func (db *Olric) clusterMembersCommandHandler(conn redcon.Conn, cmd redcon.Comma
 
 	coordinator := db.rt.Discovery().GetCoordinator()
 	members := db.rt.Discovery().GetMembers()
+	hashSeed := db.rt.Discovery().HashSeed()
 	conn.WriteArray(len(members))
 	for _, member := range members {
-		conn.WriteArray(3)
+		conn.WriteArray(4)
 		conn.WriteBulkString(member.Name)
 		// go-redis/redis package cannot handle uint64. At the time of this writing,
 		// there is no solution for this, and I don't want to use a soft fork to repair it.
@@ -182,5 +285,7 @@ func (db *Olric) clusterMembersCommandHandler(conn redcon.Conn, cmd redcon.Comma
 		} else {
 			conn.WriteBulkString("false")
 		}
+		// The cluster clients need the hash seed to find the partition owners.
+		conn.WriteBulkString(strconv.FormatUint(hashSeed, 10))
 	}
 }
@@ -16,12 +16,17 @@ package olric
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -40,6 +45,7 @@ import (
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/buraksezer/olric/stats"
 	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 var pool = bufpool.New()
@@ -49,9 +55,10 @@ var pool = bufpool.New()
 const DefaultRoutingTableFetchInterval = time.Minute
 
 type ClusterLockContext struct {
-	key   string
-	token string
-	dm    *ClusterDMap
+	key          string
+	token        string
+	fencingToken uint64
+	dm           *ClusterDMap
 }
 
 // ClusterDMap implements a client for DMaps.
@@ -75,6 +82,9 @@ func processProtocolError(err error) error {
 	if err == redis.Nil {
 		return ErrKeyNotFound
 	}
+	if errors.Is(err, dmap.ErrTimeout) {
+		return ErrTimeout
+	}
 	if errors.Is(err, syscall.ECONNREFUSED) {
 		opErr := err.(*net.OpError)
 		return fmt.Errorf("%s %s %s: %w", opErr.Op, opErr.Net, opErr.Addr, ErrConnRefused)
@@ -105,20 +115,29 @@ func (dm *ClusterDMap) writePutCommand(c *dmap.PutConfig, key string, value []by
 	return cmd
 }
 
-func (cl *ClusterClient) clientByPartID(partID uint64) (*redis.Client, error) {
+// routeByPartID returns the route of the given partition. The list of primary owners is never empty.
+func (cl *ClusterClient) routeByPartID(partID uint64) (Route, error) {
 	raw := cl.routingTable.Load()
 	if raw == nil {
-		return nil, fmt.Errorf("routing table is empty")
+		return Route{}, fmt.Errorf("routing table is empty")
 	}
 
 	routingTable, ok := raw.(RoutingTable)
 	if !ok {
-		return nil, fmt.Errorf("routing table is corrupt")
+		return Route{}, fmt.Errorf("routing table is corrupt")
 	}
 
 	route := routingTable[partID]
 	if len(route.PrimaryOwners) == 0 {
-		return nil, fmt.Errorf("primary owners list for %d is empty", partID)
+		return Route{}, fmt.Errorf("primary owners list for %d is empty", partID)
+	}
+	return route, nil
+}
+
+func (cl *ClusterClient) clientByPartID(partID uint64) (*redis.Client, error) {
+	route, err := cl.routeByPartID(partID)
+	if err != nil {
+		return nil, err
 	}
 
 	primaryOwner := route.PrimaryOwners[len(route.PrimaryOwners)-1]
@@ -156,7 +175,9 @@ func (dm *ClusterDMap) Put(ctx context.Context, key string, value interface{}, o
 	putCmd := dm.writePutCommand(&pc, key, valueBuf.Bytes())
 	cmd := putCmd.Command(ctx)
 
-	err = rc.Process(ctx, cmd)
+	err = dmap.RunWithTimeout(ctx, dm.config.writeTimeout, func(ctx context.Context) error {
+		return rc.Process(ctx, cmd)
+	})
 	if err != nil {
 		return processProtocolError(err)
 	}
@@ -180,18 +201,62 @@ func (dm *ClusterDMap) makeGetResponse(cmd *redis.StringCmd) (*GetResponse, erro
 // does not contain the key. It's thread-safe. It is safe to modify the contents
 // of the returned value. See GetResponse for the details.
 func (dm *ClusterDMap) Get(ctx context.Context, key string) (*GetResponse, error) {
-	cmd := protocol.NewGet(dm.name, key).SetRaw().Command(ctx)
+	if dm.clusterClient.config.maxHedges > 0 {
+		return dm.hedgedGet(ctx, key)
+	}
+
+	if dm.clusterClient.config.readStrategy == ReadFromFastestReplica {
+		addr, replica, err := dm.clusterClient.pickForRead(dm.name, key)
+		if err != nil {
+			return nil, err
+		}
+		if replica {
+			gr, err := dm.getFromReplica(ctx, addr, key)
+			if err == nil {
+				return gr, nil
+			}
+			// Fallback to the primary owner.
+		}
+	}
+
 	rc, err := dm.clusterClient.smartPick(dm.name, key)
 	if err != nil {
 		return nil, err
 	}
-	err = rc.Process(ctx, cmd)
+	return dm.getFromPrimary(ctx, rc, key)
+}
+
+// getFromPrimary reads the key from the given primary owner.
+func (dm *ClusterDMap) getFromPrimary(ctx context.Context, rc *redis.Client, key string) (*GetResponse, error) {
+	cmd := protocol.NewGet(dm.name, key).SetRaw().Command(ctx)
+	err := dmap.RunWithTimeout(ctx, dm.config.readTimeout, func(ctx context.Context) error {
+		return rc.Process(ctx, cmd)
+	})
 	if err != nil {
 		return nil, processProtocolError(err)
 	}
 	return dm.makeGetResponse(cmd)
 }
 
+// getFromReplica reads the key from the backup partition on the given replica owner.
+func (dm *ClusterDMap) getFromReplica(ctx context.Context, addr, key string) (*GetResponse, error) {
+	cmd := protocol.NewGetEntry(dm.name, key).SetReplica().Command(ctx)
+	rc := dm.client.Get(addr)
+
+	start := time.Now()
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		err = processProtocolError(err)
+		// A cancelled request, for example the loser of a hedged read, says nothing about the member.
+		if err != ErrKeyNotFound && ctx.Err() == nil {
+			dm.clusterClient.latency.markUnhealthy(addr)
+		}
+		return nil, err
+	}
+	dm.clusterClient.latency.update(addr, time.Since(start))
+	return dm.makeGetResponse(cmd)
+}
+
 // Delete deletes values for the given keys. Delete will not return error
 // if key doesn't exist. It's thread-safe. It is safe to modify the contents
 // of the argument after Delete returns.
@@ -202,7 +267,9 @@ func (dm *ClusterDMap) Delete(ctx context.Context, keys ...string) (int, error)
 	}
 
 	cmd := protocol.NewDel(dm.name, keys...).Command(ctx)
-	err = rc.Process(ctx, cmd)
+	err = dmap.RunWithTimeout(ctx, dm.config.writeTimeout, func(ctx context.Context) error {
+		return rc.Process(ctx, cmd)
+	})
 	if err != nil {
 		return 0, processProtocolError(err)
 	}
@@ -214,6 +281,28 @@ func (dm *ClusterDMap) Delete(ctx context.Context, keys ...string) (int, error)
 	return int(res), nil
 }
 
+// DeleteIfExpiredBefore deletes the key only if its expiry time is at or before ts, in
+// milliseconds since the Unix epoch. It returns true if the key is deleted. A key without
+// a TTL is never deleted. The check and the deletion are atomic on the partition owner,
+// so a cleanup worker doesn't delete a key whose TTL is extended concurrently.
+func (dm *ClusterDMap) DeleteIfExpiredBefore(ctx context.Context, key string, ts int64) (bool, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return false, err
+	}
+
+	cmd := protocol.NewDelIfExpiredBefore(dm.name, key, ts).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return false, processProtocolError(err)
+	}
+	deleted, err := cmd.Result()
+	if err != nil {
+		return false, processProtocolError(err)
+	}
+	return deleted == 1, nil
+}
+
 // Incr atomically increments the key by delta. The return value is the new value
 // after being incremented or an error.
 func (dm *ClusterDMap) Incr(ctx context.Context, key string, delta int) (int, error) {
@@ -254,144 +343,1213 @@ func (dm *ClusterDMap) Decr(ctx context.Context, key string, delta int) (int, er
 	return int(res), nil
 }
 
-// GetPut atomically sets the key to value and returns the old value stored at key. It returns nil if there is no
-// previous value.
-func (dm *ClusterDMap) GetPut(ctx context.Context, key string, value interface{}) (*GetResponse, error) {
+// NextSequence returns the next number of the sequence stored at the key. The
+// numbers start from 1, they are unique and increasing across the cluster.
+func (dm *ClusterDMap) NextSequence(ctx context.Context, name string) (int64, error) {
+	return dm.ReserveSequenceBlock(ctx, name, 1)
+}
+
+// ReserveSequenceBlock reserves n numbers of the sequence stored at the key in one
+// request and returns the first one. The caller owns the numbers in [start, start+n)
+// and can allocate them locally, the blocks never overlap.
+func (dm *ClusterDMap) ReserveSequenceBlock(ctx context.Context, name string, n int) (int64, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: block size must be greater than zero: %d", protocol.ErrInvalidArgument, n)
+	}
+
+	rc, err := dm.clusterClient.smartPick(dm.name, name)
+	if err != nil {
+		return 0, err
+	}
+
+	// The member validates the DMap and increments the counter on the partition owner.
+	cmd := protocol.NewReserveSequence(dm.name, name, n).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	start, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return start, nil
+}
+
+// HIncrBy atomically increments the field of the hash stored at the key by delta. The
+// hash and the field are created if they don't exist. The return value is the new
+// value of the field. It returns ErrNotAnInteger if the field is not an integer and
+// ErrNotAHash if the value is not a hash.
+func (dm *ClusterDMap) HIncrBy(ctx context.Context, key, field string, delta int) (int, error) {
 	rc, err := dm.clusterClient.smartPick(dm.name, key)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	valueBuf := pool.Get()
-	defer pool.Put(valueBuf)
+	cmd := protocol.NewHIncrBy(dm.name, key, field, delta).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	res, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(cmd.Err())
+	}
+	return int(res), nil
+}
 
-	enc := resp.New(valueBuf)
-	err = enc.Encode(value)
+// HMSet sets the fields of the hash stored at the key in one pass. The hash is
+// created if it doesn't exist, the other fields and the TTL are preserved. It
+// returns ErrNotAHash if the value is not a hash.
+func (dm *ClusterDMap) HMSet(ctx context.Context, key string, fields map[string][]byte) error {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return err
+	}
+
+	cmd := protocol.NewHMSet(dm.name, key, fields).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
+// HMGet returns the fields of the hash stored at the key in one pass. The absent
+// fields are omitted. It returns ErrNotAHash if the value is not a hash.
+func (dm *ClusterDMap) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
 	if err != nil {
 		return nil, err
 	}
 
-	cmd := protocol.NewGetPut(dm.name, key, valueBuf.Bytes()).SetRaw().Command(ctx)
+	cmd := protocol.NewHMGet(dm.name, key, fields...).Command(ctx)
 	err = rc.Process(ctx, cmd)
-	err = processProtocolError(err)
 	if err != nil {
-		// First try to set a key/value with GetPut
-		if err == ErrKeyNotFound {
-			return nil, nil
+		return nil, processProtocolError(err)
+	}
+	values, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	result := make(map[string][]byte)
+	for i := 0; i+1 < len(values); i += 2 {
+		field, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid field: %v", values[i])
+		}
+		value, ok := values[i+1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value: %v", values[i+1])
 		}
+		result[field] = []byte(value)
+	}
+	return result, nil
+}
+
+// PutWithVersion sets the value of the key if its current version is equal to
+// expectedVersion, and increments the version atomically. An absent key has version 0.
+// It returns the new version, or ErrVersionMismatch if the version is different. The
+// version is kept in the metadata of the entry, Get returns the value and
+// GetResponse.Version returns the version. A Put without a version resets the version
+// to 0. The TTL of the key is preserved.
+func (dm *ClusterDMap) PutWithVersion(ctx context.Context, key string, value []byte, expectedVersion int64) (int64, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewPutWithVersion(dm.name, key, value, expectedVersion).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	version, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(cmd.Err())
+	}
+	return version, nil
+}
+
+// PutIfField atomically sets the value of the key if the field of the stored object is
+// equal to expected, and returns true if the value is written. The stored object is either
+// a hash or a JSON object, the string fields of a JSON object are compared without the
+// quotes. It returns false if the object doesn't have the field, ErrKeyNotFound if the
+// key does not exist and ErrNotAnObject if the value is not an object.
+func (dm *ClusterDMap) PutIfField(ctx context.Context, key, field string, expected, value []byte) (bool, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return false, err
+	}
+
+	cmd := protocol.NewPutIfField(dm.name, key, field, expected, value).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return false, processProtocolError(err)
+	}
+	written, err := cmd.Result()
+	if err != nil {
+		return false, processProtocolError(err)
+	}
+	return written == 1, nil
+}
+
+// Merge atomically applies a JSON merge patch (RFC 7386) to the JSON document stored at
+// the key. The fields of the patch replace the fields of the document, the objects are
+// merged recursively and a null deletes the field. The key is created from the patch if
+// it doesn't exist. It returns ErrNotAnObject if the stored value is not JSON.
+func (dm *ClusterDMap) Merge(ctx context.Context, key string, patch []byte) error {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return err
+	}
+
+	cmd := protocol.NewMerge(dm.name, key, patch).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
+// SetStandby stages the value in the standby slot of the key. The active slot is the
+// value of the key, it's preserved and Get keeps returning it. The standby value is
+// stored with the key StandbyKeyPrefix + key and expires with the active value.
+func (dm *ClusterDMap) SetStandby(ctx context.Context, key string, value []byte) error {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return err
+	}
+
+	cmd := protocol.NewSetStandby(dm.name, key, value).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
+// PromoteStandby atomically swaps the active and the standby slots of the key, the
+// previous active value becomes the standby one. Calling it again rolls the change
+// back. It returns ErrNoStandby if the key has no standby value.
+func (dm *ClusterDMap) PromoteStandby(ctx context.Context, key string) error {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return err
+	}
+
+	cmd := protocol.NewPromoteStandby(dm.name, key).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
+// LPushCapped atomically pushes the values to the head of the list stored at the key
+// and trims the list to max elements, the last value becomes the head. trim decides
+// which end of the list is dropped. It returns the length of the list, or ErrNotAList
+// if the value is not a list.
+func (dm *ClusterDMap) LPushCapped(ctx context.Context, key string, max int, trim ListTrim, values ...[]byte) (int, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewLPushCapped(dm.name, key, max, int(trim), values...).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	length, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(length), nil
+}
+
+// LRange returns the elements of the list stored at the key between start and stop,
+// both inclusive. The head is at index 0, the negative indexes are counted from the
+// tail. It returns ErrNotAList if the value is not a list.
+func (dm *ClusterDMap) LRange(ctx context.Context, key string, start, stop int) ([][]byte, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
 		return nil, err
 	}
 
-	raw, err := cmd.Bytes()
+	cmd := protocol.NewLRange(dm.name, key, start, stop).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	values, err := cmd.Result()
 	if err != nil {
 		return nil, processProtocolError(err)
 	}
 
-	e := dm.newEntry()
-	e.Decode(raw)
-	return &GetResponse{
-		entry: e,
-	}, nil
+	list := make([][]byte, 0, len(values))
+	for _, value := range values {
+		element, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid element: %v", value)
+		}
+		list = append(list, []byte(element))
+	}
+	return list, nil
 }
 
-// IncrByFloat atomically increments the key by delta. The return value is the new value
-// after being incremented or an error.
-func (dm *ClusterDMap) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
+// SetBit atomically sets or clears the bit at the offset in the value stored at the key,
+// and returns the previous bit. The value is zero-padded if the offset is beyond its end.
+// The semantics is the same as Redis SETBIT.
+func (dm *ClusterDMap) SetBit(ctx context.Context, key string, offset, value int) (int, error) {
 	rc, err := dm.clusterClient.smartPick(dm.name, key)
 	if err != nil {
 		return 0, err
 	}
 
-	cmd := protocol.NewIncrByFloat(dm.name, key, delta).Command(ctx)
+	cmd := protocol.NewSetBit(dm.name, key, offset, value).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	old, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(old), nil
+}
+
+// GetBit returns the bit at the offset in the value stored at the key. The bits beyond
+// the end of the value and the bits of an absent key are 0.
+func (dm *ClusterDMap) GetBit(ctx context.Context, key string, offset int) (int, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewGetBit(dm.name, key, offset).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	bit, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(bit), nil
+}
+
+// DecrAndDeleteAtZero atomically decrements the key by delta. If the result is equal to or
+// less than zero, the key is deleted, deleted is true and remaining is zero. It returns
+// ErrKeyNotFound if the key does not exist and ErrNotAnInteger if the value is not an integer.
+func (dm *ClusterDMap) DecrAndDeleteAtZero(ctx context.Context, key string, delta int) (remaining int, deleted bool, err error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	cmd := protocol.NewDecrAndDeleteAtZero(dm.name, key, delta).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, false, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return 0, false, processProtocolError(err)
+	}
+	if len(result) != 2 {
+		return 0, false, fmt.Errorf("invalid response length: %d", len(result))
+	}
+	res, ok := result[0].(int64)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid response type: %T", result[0])
+	}
+	del, ok := result[1].(int64)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid response type: %T", result[1])
+	}
+	return int(res), del == 1, nil
+}
+
+// GetAndReset atomically returns the integer value stored at the key and sets it to zero,
+// so the increments between the read and the reset are not lost. It returns 0 if the key
+// does not exist, and ErrNotAnInteger if the value is not an integer.
+func (dm *ClusterDMap) GetAndReset(ctx context.Context, key string) (int, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewGetAndReset(dm.name, key).Command(ctx)
 	err = rc.Process(ctx, cmd)
 	if err != nil {
 		return 0, processProtocolError(err)
 	}
-	res, err := cmd.Result()
+	value, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(value), nil
+}
+
+// GetDel atomically returns the value of the key and deletes it, so only one of the
+// concurrent callers gets the value, like consuming a one-shot token. It returns
+// ErrKeyNotFound if the key does not exist.
+func (dm *ClusterDMap) GetDel(ctx context.Context, key string) (*GetResponse, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewGetDel(dm.name, key).SetRaw().Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	return dm.makeGetResponse(cmd)
+}
+
+// Append atomically appends suffix to the value of the key and returns the length of the
+// new value. If the key does not exist, it is created with suffix as its value.
+func (dm *ClusterDMap) Append(ctx context.Context, key string, suffix []byte) (int, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewAppend(dm.name, key, suffix).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	length, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(length), nil
+}
+
+// GetPut atomically sets the key to value and returns the old value stored at key. It returns nil if there is no
+// previous value.
+func (dm *ClusterDMap) GetPut(ctx context.Context, key string, value interface{}) (*GetResponse, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	enc := resp.New(valueBuf)
+	err = enc.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewGetPut(dm.name, key, valueBuf.Bytes()).SetRaw().Command(ctx)
+	err = rc.Process(ctx, cmd)
+	err = processProtocolError(err)
+	if err != nil {
+		// First try to set a key/value with GetPut
+		if err == ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	raw, err := cmd.Bytes()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	e := dm.newEntry()
+	e.Decode(raw)
+	return &GetResponse{
+		entry: e,
+	}, nil
+}
+
+// GetByTag returns the values of all keys whose hash tag is tag, like "user:42" for the
+// keys "{user:42}:profile" and "{user:42}:session", mapped by their keys. The keys with
+// the same tag are stored on the same partition, so it reads a single partition on its
+// owner in one pass. It returns ErrHashTagsDisabled if the hash tags are not enabled.
+func (dm *ClusterDMap) GetByTag(ctx context.Context, tag string) (map[string]*GetResponse, error) {
+	// The keys with the tag are placed like the key "{tag}". If the hash tags are not enabled
+	// on the client, it's an arbitrary member and the member redirects the request to the owner.
+	rc, err := dm.clusterClient.smartPick(dm.name, "{"+tag+"}")
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewGetByTag(dm.name, tag).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	raws, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	result := make(map[string]*GetResponse, len(raws))
+	for _, raw := range raws {
+		e := dm.newEntry()
+		e.Decode([]byte(raw))
+		result[e.Key()] = &GetResponse{entry: e}
+	}
+	return result, nil
+}
+
+// copied to the key with the ":previous" suffix and expires after graceTTL, so the old
+// value stays retrievable during a zero-downtime rotation, like a credential rotation.
+// It returns nil if the key doesn't exist, and no previous value is written.
+func (dm *ClusterDMap) Rotate(ctx context.Context, key string, value interface{}, graceTTL time.Duration) (*GetResponse, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	enc := resp.New(valueBuf)
+	err = enc.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewRotate(dm.name, key, valueBuf.Bytes(), graceTTL.Milliseconds()).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	err = processProtocolError(err)
+	if err != nil {
+		// There is no previous value.
+		if err == ErrKeyNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	raw, err := cmd.Bytes()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	e := dm.newEntry()
+	e.Decode(raw)
+	return &GetResponse{
+		entry: e,
+	}, nil
+}
+
+// SetNXGet atomically sets the key to value with the given TTL if the key does not exist.
+// It returns true and the newly written entry if the key is set. Otherwise, it returns false
+// and the entry of the current holder. A zero ttl means no expiration.
+func (dm *ClusterDMap) SetNXGet(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, *GetResponse, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return false, nil, err
+	}
+
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	enc := resp.New(valueBuf)
+	err = enc.Encode(value)
+	if err != nil {
+		return false, nil, err
+	}
+
+	cmd := protocol.NewSetNXGet(dm.name, key, valueBuf.Bytes(), ttl.Milliseconds()).SetRaw().Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return false, nil, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return false, nil, processProtocolError(err)
+	}
+	if len(result) != 2 {
+		return false, nil, fmt.Errorf("invalid response length: %d", len(result))
+	}
+	acquired, ok := result[0].(int64)
+	if !ok {
+		return false, nil, fmt.Errorf("invalid response type: %T", result[0])
+	}
+	raw, ok := result[1].(string)
+	if !ok {
+		return false, nil, fmt.Errorf("invalid response type: %T", result[1])
+	}
+
+	e := dm.newEntry()
+	e.Decode([]byte(raw))
+	return acquired == 1, &GetResponse{
+		entry: e,
+	}, nil
+}
+
+// GetOrSet returns the value of the key and true if the key exists. Otherwise, it sets
+// the key to value with the given TTL, and returns the new value and false. It runs on
+// the partition owner in a single round trip, so the concurrent callers agree on a
+// single value. A zero ttl means no expiration.
+func (dm *ClusterDMap) GetOrSet(ctx context.Context, key string, value interface{}, ttl time.Duration) (*GetResponse, bool, error) {
+	set, gr, err := dm.SetNXGet(ctx, key, value, ttl)
+	if err != nil {
+		return nil, false, err
+	}
+	return gr, !set, nil
+}
+
+// ClaimNext atomically claims an unclaimed entry and returns its key and value. The
+// entry stays claimed for claimTTL, so the claim of a crashed worker is released
+// automatically. The partition owners are tried in turn. It returns ErrNoAvailable
+// if all entries are claimed. The claim is a marker entry with the key ClaimKeyPrefix +
+// key and the TTL claimTTL, so it's replicated and moved with its partition.
+func (dm *ClusterDMap) ClaimNext(ctx context.Context, claimTTL time.Duration) (string, *GetResponse, error) {
+	rc, err := dm.client.Pick()
+	if err != nil {
+		return "", nil, err
+	}
+
+	cmd := protocol.NewClaimNext(dm.name, claimTTL.Milliseconds()).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return "", nil, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return "", nil, processProtocolError(err)
+	}
+	if len(result) != 1 {
+		return "", nil, fmt.Errorf("invalid response length: %d", len(result))
+	}
+	raw, ok := result[0].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("invalid response type: %T", result[0])
+	}
+
+	e := dm.newEntry()
+	e.Decode([]byte(raw))
+	return e.Key(), &GetResponse{
+		entry: e,
+	}, nil
+}
+
+// IncrByFloat atomically increments the key by delta. The return value is the new value
+// after being incremented or an error. It returns ErrInvalidFloat if the delta or the
+// stored value is NaN or Infinity, or the result would overflow to Infinity.
+func (dm *ClusterDMap) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewIncrByFloat(dm.name, key, delta).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	res, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(cmd.Err())
+	}
+	return res, nil
+}
+
+// Eval runs a script atomically on the given keys. All keys must belong to the same
+// partition owner, otherwise it returns ErrCrossOwnerKeys. The script runs on the owner
+// under the locks of the keys and its writes are applied only if it returns successfully.
+// A script runs at most one second. The return value is nil, an int64 or a string.
+//
+// The scripts are written in a small language without loops:
+//
+//	let balance = get(KEYS[1])
+//	if balance != nil && balance >= ARGV[1] {
+//		set(KEYS[1], balance - ARGV[1])
+//		incr(KEYS[2], ARGV[1])
+//		return 1
+//	}
+//	return 0
+//
+// The built-in functions are get, set, del, exists, incr and error.
+func (dm *ClusterDMap) Eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error) {
+	var rc *redis.Client
+	var err error
+	if len(keys) == 0 {
+		rc, err = dm.client.Pick()
+	} else {
+		rc, err = dm.clusterClient.smartPick(dm.name, keys[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewEval(dm.name, script, keys, args...).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		perr := processProtocolError(err)
+		if errors.Is(perr, ErrScript) {
+			// Keep the details of the script error.
+			prefix := protocol.GetPrefix(dmap.ErrScript) + " " + dmap.ErrScript.Error()
+			return nil, fmt.Errorf("%w%s", ErrScript, strings.TrimPrefix(err.Error(), prefix))
+		}
+		return nil, perr
+	}
+	return cmd.Val(), nil
+}
+
+// PutAllIfNoneExist sets all the given keys only if none of them exists, it's
+// all-or-nothing. It returns false without writing anything if any of the keys already
+// exists. All keys must belong to the same partition owner, otherwise it returns
+// ErrCrossOwnerKeys.
+func (dm *ClusterDMap) PutAllIfNoneExist(ctx context.Context, pairs map[string][]byte) (bool, error) {
+	if len(pairs) == 0 {
+		return true, nil
+	}
+
+	var key string
+	for key = range pairs {
+		break
+	}
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return false, err
+	}
+
+	cmd := protocol.NewPutAllIfNoneExist(dm.name, pairs).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return false, processProtocolError(err)
+	}
+	return cmd.Val() == 1, nil
+}
+
+// MemoryUsage returns the number of bytes that the key occupies in the storage engine,
+// including the key and the metadata. The value is not fetched. It returns ErrKeyNotFound
+// if the key doesn't exist.
+func (dm *ClusterDMap) MemoryUsage(ctx context.Context, key string) (int, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewMemoryUsage(dm.name, key).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	size, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(size), nil
+}
+
+// Exists returns the number of the keys that exist. A key is counted as many times
+// as it's given. The keys are grouped by their partition owners and every owner is
+// queried once. The values are not fetched.
+func (dm *ClusterDMap) Exists(ctx context.Context, keys ...string) (int, error) {
+	rc, err := dm.client.Pick()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewExists(dm.name, keys).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	count, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(count), nil
+}
+
+// PutMany sets the raw values of the keys with the same options. The pairs are grouped
+// by their partition owners and every owner receives one request. The batch is not
+// atomic: a failed pair, for example an existing key with NX, stops the rest of the
+// batch on its owner, and the pairs that were already written are kept.
+func (dm *ClusterDMap) PutMany(ctx context.Context, pairs map[string][]byte, options ...PutOption) error {
+	rc, err := dm.client.Pick()
+	if err != nil {
+		return err
+	}
+
+	var pc dmap.PutConfig
+	for _, opt := range options {
+		opt(&pc)
+	}
+
+	putManyCmd := protocol.NewPutMany(dm.name)
+	for key, value := range pairs {
+		putManyCmd.Add(key, value)
+	}
+	switch {
+	case pc.HasEX:
+		putManyCmd.SetEX(pc.EX.Seconds())
+	case pc.HasPX:
+		putManyCmd.SetPX(pc.PX.Milliseconds())
+	case pc.HasEXAT:
+		putManyCmd.SetEXAT(pc.EXAT.Seconds())
+	case pc.HasPXAT:
+		putManyCmd.SetPXAT(pc.PXAT.Milliseconds())
+	}
+	switch {
+	case pc.HasNX:
+		putManyCmd.SetNX()
+	case pc.HasXX:
+		putManyCmd.SetXX()
+	}
+
+	cmd := putManyCmd.Command(ctx)
+	err = dmap.RunWithTimeout(ctx, dm.config.writeTimeout, func(ctx context.Context) error {
+		return rc.Process(ctx, cmd)
+	})
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
+// GetMany returns the raw values of the keys. The absent keys are omitted. The keys
+// are grouped by their partition owners and every owner is queried once.
+func (dm *ClusterDMap) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	rc, err := dm.client.Pick()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewGetMany(dm.name, keys).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	if len(result)%2 != 0 {
+		return nil, fmt.Errorf("invalid GetMany response: %v", result)
+	}
+
+	values := make(map[string][]byte)
+	for i := 0; i < len(result); i += 2 {
+		key, ok := result[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", result[i])
+		}
+		value, ok := result[i+1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value: %v", result[i+1])
+		}
+		values[key] = []byte(value)
+	}
+	return values, nil
+}
+
+// GetTTLMany returns the remaining TTLs of the keys in milliseconds. The keys without
+// a TTL are mapped to -1 and the absent keys are omitted. The keys are grouped by their
+// partition owners and every owner is queried once. The values are not fetched.
+func (dm *ClusterDMap) GetTTLMany(ctx context.Context, keys []string) (map[string]int64, error) {
+	rc, err := dm.client.Pick()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewGetTTLMany(dm.name, keys).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	if len(result)%2 != 0 {
+		return nil, fmt.Errorf("invalid TTL response: %v", result)
+	}
+
+	ttls := make(map[string]int64)
+	for i := 0; i < len(result); i += 2 {
+		key, ok := result[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", result[i])
+		}
+		ttl, ok := result[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid TTL: %v", result[i+1])
+		}
+		ttls[key] = ttl
+	}
+	return ttls, nil
+}
+
+// TTL returns the remaining time to live of the key. It returns zero if the key has no
+// expiry, and ErrKeyNotFound if the key does not exist. The value is not fetched.
+func (dm *ClusterDMap) TTL(ctx context.Context, key string) (time.Duration, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewPTTL(dm.name, key).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	ttl, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	switch ttl {
+	case -2:
+		return 0, ErrKeyNotFound
+	case -1:
+		return 0, nil
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
+
+// Expire updates the expiry for the given key. It returns ErrKeyNotFound if
+// the DB does not contain the key. It's thread-safe.
+func (dm *ClusterDMap) Expire(ctx context.Context, key string, timeout time.Duration) error {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return err
+	}
+
+	cmd := protocol.NewExpire(dm.name, key, timeout).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
+// Persist removes the expiry of the given key, so it never expires. The value is
+// not rewritten. It returns ErrKeyNotFound if the DB does not contain the key.
+func (dm *ClusterDMap) Persist(ctx context.Context, key string) error {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return err
+	}
+
+	cmd := protocol.NewPersist(dm.name, key).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
+func (dm *ClusterDMap) newLockContext(key string, cmd *redis.StringCmd) (LockContext, error) {
+	res, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	token, fencingToken, err := protocol.ParseLockResponse(res)
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterLockContext{
+		key:          key,
+		token:        token,
+		fencingToken: fencingToken,
+		dm:           dm,
+	}, nil
+}
+
+// Lock sets a lock for the given key. Acquired lock is only for the key in
+// this dmap.
+//
+// It returns immediately if it acquires the lock for the given key. Otherwise,
+// it waits until deadline.
+func (dm *ClusterDMap) Lock(ctx context.Context, key string, deadline time.Duration) (LockContext, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewLock(dm.name, key, deadline.Seconds()).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	return dm.newLockContext(key, cmd)
+}
+
+// LockWithTimeout sets a lock for the given key. If the lock is still unreleased
+// the end of given period of time, it automatically releases the lock.
+// Acquired lock is only for the key in this DMap.
+//
+// It returns immediately if it acquires the lock for the given key. Otherwise,
+// it waits until deadline.
+func (dm *ClusterDMap) LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration) (LockContext, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewLock(dm.name, key, deadline.Seconds()).SetPX(timeout.Milliseconds()).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	return dm.newLockContext(key, cmd)
+}
+
+// LockWithOptions sets a lock for the given key. The acquisition deadline, the
+// lease and the poll interval are set separately by LockOptions. Cancelling
+// ctx aborts the acquisition, it returns the context's error in this case.
+func (dm *ClusterDMap) LockWithOptions(ctx context.Context, key string, opts LockOptions) (LockContext, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return nil, err
+	}
+
+	lockCmd := protocol.NewLock(dm.name, key, opts.Deadline.Seconds())
+	if opts.Lease != 0 {
+		lockCmd.SetPX(opts.Lease.Milliseconds())
+	}
+	if opts.PollInterval != 0 {
+		lockCmd.SetPollInterval(opts.PollInterval.Milliseconds())
+	}
+	cmd := lockCmd.Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	return dm.newLockContext(key, cmd)
+}
+
+// TryLock tries to acquire the lock for the given key only once. It returns
+// ErrLockNotAcquired immediately if the lock is held by someone else. The lock
+// is released automatically after timeout, it never expires if timeout is zero.
+func (dm *ClusterDMap) TryLock(ctx context.Context, key string, timeout time.Duration) (LockContext, error) {
+	return dm.LockWithOptions(ctx, key, LockOptions{Lease: timeout})
+}
+
+// LockInfo returns whether the lock of the key is held and its remaining TTL, without
+// trying to acquire it. The TTL is zero if the lock never expires. It's read-only, it's
+// useful to debug the stuck locks.
+func (dm *ClusterDMap) LockInfo(ctx context.Context, key string) (bool, time.Duration, error) {
+	ttls, err := dm.GetTTLMany(ctx, []string{key})
+	if err != nil {
+		return false, 0, err
+	}
+	ttl, ok := ttls[key]
+	if !ok {
+		return false, 0, nil
+	}
+	if ttl == dmap.NoExpiry {
+		return true, 0, nil
+	}
+	return true, time.Duration(ttl) * time.Millisecond, nil
+}
+
+// AcquirePermit takes a permit from the distributed counting semaphore stored at
+// the key. At most max permits are held at the same time across the cluster. It
+// doesn't block, ok is false if the semaphore is full. The permit is released by
+// calling release, or automatically after ttl if the holder crashes.
+func (dm *ClusterDMap) AcquirePermit(ctx context.Context, key string, max int, ttl time.Duration) (release func(ctx context.Context) error, ok bool, err error) {
+	raw := make([]byte, 16)
+	_, err = rand.Read(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(raw)
+
+	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cmd := protocol.NewAcquirePermit(dm.name, key, token, max, ttl.Milliseconds()).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, false, processProtocolError(err)
+	}
+	res, err := cmd.Result()
+	if err != nil {
+		return nil, false, processProtocolError(cmd.Err())
+	}
+	if res != 1 {
+		return nil, false, nil
+	}
+
+	release = func(ctx context.Context) error {
+		rc, err := dm.clusterClient.smartPick(dm.name, key)
+		if err != nil {
+			return err
+		}
+		cmd := protocol.NewReleasePermit(dm.name, key, token).Command(ctx)
+		err = rc.Process(ctx, cmd)
+		if err != nil {
+			return processProtocolError(err)
+		}
+		return processProtocolError(cmd.Err())
+	}
+	return release, true, nil
+}
+
+// Register registers the instance of the service with its metadata, like its address.
+// The registration expires after ttl, the returned heartbeat extends it by ttl again and
+// should be called periodically. The heartbeat returns ErrNoSuchInstance if the
+// registration is expired or the instance is registered again somewhere else, it never
+// clobbers the new registration.
+func (dm *ClusterDMap) Register(ctx context.Context, service, instance string, meta []byte, ttl time.Duration) (heartbeat func(ctx context.Context) error, err error) {
+	raw := make([]byte, 16)
+	_, err = rand.Read(raw)
 	if err != nil {
-		return 0, processProtocolError(cmd.Err())
+		return nil, err
 	}
-	return res, nil
-}
+	token := hex.EncodeToString(raw)
 
-// Expire updates the expiry for the given key. It returns ErrKeyNotFound if
-// the DB does not contain the key. It's thread-safe.
-func (dm *ClusterDMap) Expire(ctx context.Context, key string, timeout time.Duration) error {
-	rc, err := dm.clusterClient.smartPick(dm.name, key)
+	rc, err := dm.clusterClient.smartPick(dm.name, service)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	cmd := protocol.NewExpire(dm.name, key, timeout).Command(ctx)
+	cmd := protocol.NewRegister(dm.name, service, instance, token, ttl.Milliseconds(), meta).Command(ctx)
 	err = rc.Process(ctx, cmd)
 	if err != nil {
-		return processProtocolError(err)
+		return nil, processProtocolError(err)
 	}
-	return processProtocolError(cmd.Err())
+	if err = cmd.Err(); err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	heartbeat = func(ctx context.Context) error {
+		rc, err := dm.clusterClient.smartPick(dm.name, service)
+		if err != nil {
+			return err
+		}
+		cmd := protocol.NewHeartbeat(dm.name, service, instance, token, ttl.Milliseconds()).Command(ctx)
+		err = rc.Process(ctx, cmd)
+		if err != nil {
+			return processProtocolError(err)
+		}
+		return processProtocolError(cmd.Err())
+	}
+	return heartbeat, nil
 }
 
-// Lock sets a lock for the given key. Acquired lock is only for the key in
-// this dmap.
-//
-// It returns immediately if it acquires the lock for the given key. Otherwise,
-// it waits until deadline.
-//
-// You should know that the locks are approximate, and only to be used for
-// non-critical purposes.
-func (dm *ClusterDMap) Lock(ctx context.Context, key string, deadline time.Duration) (LockContext, error) {
-	rc, err := dm.clusterClient.smartPick(dm.name, key)
+// Discover returns the live instances of the service sorted by their names. The
+// instances without a heartbeat disappear when their registrations expire.
+func (dm *ClusterDMap) Discover(ctx context.Context, service string) ([]Instance, error) {
+	rc, err := dm.clusterClient.smartPick(dm.name, service)
 	if err != nil {
 		return nil, err
 	}
 
-	cmd := protocol.NewLock(dm.name, key, deadline.Seconds()).Command(ctx)
+	cmd := protocol.NewDiscover(dm.name, service).Command(ctx)
 	err = rc.Process(ctx, cmd)
 	if err != nil {
 		return nil, processProtocolError(err)
 	}
-
-	token, err := cmd.Bytes()
+	values, err := cmd.Result()
 	if err != nil {
 		return nil, processProtocolError(err)
 	}
-	return &ClusterLockContext{
-		key:   key,
-		token: string(token),
-		dm:    dm,
-	}, nil
+	if len(values)%3 != 0 {
+		return nil, fmt.Errorf("invalid discover response length: %d", len(values))
+	}
+
+	instances := make([]Instance, 0, len(values)/3)
+	for i := 0; i < len(values); i += 3 {
+		name, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid instance name: %v", values[i])
+		}
+		meta, ok := values[i+1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid instance metadata: %v", values[i+1])
+		}
+		ttl, ok := values[i+2].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid instance TTL: %v", values[i+2])
+		}
+		instances = append(instances, Instance{
+			Name: name,
+			Meta: []byte(meta),
+			TTL:  time.Duration(ttl) * time.Millisecond,
+		})
+	}
+	return instances, nil
 }
 
-// LockWithTimeout sets a lock for the given key. If the lock is still unreleased
-// the end of given period of time, it automatically releases the lock.
-// Acquired lock is only for the key in this DMap.
-//
-// It returns immediately if it acquires the lock for the given key. Otherwise,
-// it waits until deadline.
-//
-// You should know that the locks are approximate, and only to be used for
-// non-critical purposes.
-func (dm *ClusterDMap) LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration) (LockContext, error) {
-	rc, err := dm.clusterClient.smartPick(dm.name, key)
+// Campaign runs a campaign of the candidate for the leadership of the election. The
+// leader holds a lease of leaseTTL, which is renewed automatically in the background.
+// If isLeader is false, another candidate holds the lease, call Campaign again to
+// take over after the lease of the leader expires. resign stops the renewals and
+// releases the lease, so a new leader can be elected. The renewals stop when the client
+// is closed, or the lease cannot be renewed before it expires. Use Leader to observe
+// the leader, or CampaignWithLeadership to learn when the leadership is lost.
+func (dm *ClusterDMap) Campaign(ctx context.Context, election, candidateID string, leaseTTL time.Duration) (isLeader bool, resign func(), err error) {
+	leadership, resign, err := dm.CampaignWithLeadership(ctx, election, candidateID, leaseTTL)
 	if err != nil {
-		return nil, err
+		return false, nil, err
 	}
+	return leadership != nil, resign, nil
+}
 
-	cmd := protocol.NewLock(dm.name, key, deadline.Seconds()).SetPX(timeout.Milliseconds()).Command(ctx)
-	err = rc.Process(ctx, cmd)
-	if err != nil {
-		return nil, processProtocolError(err)
+// CampaignWithLeadership runs a campaign like Campaign. If the candidate is elected,
+// the returned leadership context is cancelled when the leadership is lost: another
+// candidate takes the lease, the lease cannot be renewed before it expires, resign is
+// called or the client is closed. The leader should stop acting as the leader then.
+// leadership is nil if another candidate holds the lease.
+func (dm *ClusterDMap) CampaignWithLeadership(ctx context.Context, election, candidateID string, leaseTTL time.Duration) (leadership context.Context, resign func(), err error) {
+	campaignFn := func(ctx context.Context) (string, error) {
+		rc, err := dm.clusterClient.smartPick(dm.name, election)
+		if err != nil {
+			return "", err
+		}
+		cmd := protocol.NewCampaign(dm.name, election, candidateID, leaseTTL.Milliseconds()).Command(ctx)
+		err = rc.Process(ctx, cmd)
+		if err != nil {
+			return "", processProtocolError(err)
+		}
+		leader, err := cmd.Result()
+		if err != nil {
+			return "", processProtocolError(err)
+		}
+		return leader, nil
+	}
+	resignFn := func(ctx context.Context) error {
+		rc, err := dm.clusterClient.smartPick(dm.name, election)
+		if err != nil {
+			return err
+		}
+		cmd := protocol.NewResign(dm.name, election, candidateID).Command(ctx)
+		err = rc.Process(ctx, cmd)
+		if err != nil {
+			return processProtocolError(err)
+		}
+		return processProtocolError(cmd.Err())
 	}
+	return campaign(ctx, dm.clusterClient.ctx, candidateID, leaseTTL, campaignFn, resignFn)
+}
 
-	token, err := cmd.Bytes()
+// Leader returns the candidate that holds the lease of the election. It returns
+// ErrNoLeader if the election has no leader.
+func (dm *ClusterDMap) Leader(ctx context.Context, election string) (string, error) {
+	gr, err := dm.Get(ctx, election)
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", ErrNoLeader
+	}
 	if err != nil {
-		return nil, processProtocolError(err)
+		return "", err
 	}
-
-	return &ClusterLockContext{
-		key:   key,
-		token: string(token),
-		dm:    dm,
-	}, nil
+	return gr.String()
 }
 
 func (c *ClusterLockContext) Unlock(ctx context.Context) error {
@@ -407,6 +1565,11 @@ func (c *ClusterLockContext) Unlock(ctx context.Context) error {
 	return processProtocolError(cmd.Err())
 }
 
+// Token returns the fencing token of the lock. It's zero if the server doesn't support the fencing tokens.
+func (c *ClusterLockContext) Token() uint64 {
+	return c.fencingToken
+}
+
 func (c *ClusterLockContext) Lease(ctx context.Context, duration time.Duration) error {
 	rc, err := c.dm.clusterClient.smartPick(c.dm.name, c.key)
 	if err != nil {
@@ -420,6 +1583,20 @@ func (c *ClusterLockContext) Lease(ctx context.Context, duration time.Duration)
 	return processProtocolError(cmd.Err())
 }
 
+// LeaseAndPut updates the timeout of the lock and writes its protected value in a single round trip.
+func (c *ClusterLockContext) LeaseAndPut(ctx context.Context, value []byte, duration time.Duration) error {
+	rc, err := c.dm.clusterClient.smartPick(c.dm.name, c.key)
+	if err != nil {
+		return err
+	}
+	cmd := protocol.NewLockLeasePut(c.dm.name, c.key, c.token, duration.Milliseconds(), value).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return processProtocolError(err)
+	}
+	return processProtocolError(cmd.Err())
+}
+
 // Scan returns an iterator to loop over the keys.
 //
 // Available scan options:
@@ -486,6 +1663,7 @@ type ClusterClient struct {
 	logger         *log.Logger
 	routingTable   atomic.Value
 	partitionCount uint64
+	latency        *latencyTracker
 	wg             sync.WaitGroup
 	ctx            context.Context
 	cancel         context.CancelFunc
@@ -539,6 +1717,294 @@ func (cl *ClusterClient) RoutingTable(ctx context.Context) (RoutingTable, error)
 	return mapToRoutingTable(result)
 }
 
+// KeyPlacement returns the partition id, the primary owner and the replica owners
+// of the given key on the given DMap. It's computed from the routing table of the
+// cluster coordinator, so it reflects the latest placement after rebalancing.
+func (cl *ClusterClient) KeyPlacement(ctx context.Context, dmap, key string) (*KeyPlacement, error) {
+	rc, err := cl.client.Pick()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewClusterKeyPlacement(dmap, key).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	return parseKeyPlacement(result)
+}
+
+// EntryAgeRange returns the oldest and the newest write timestamps of the entries
+// in the given DMap, in nanoseconds. Every member walks its primary copies and the
+// results are merged. It returns zero values if the DMap has no entries.
+func (cl *ClusterClient) EntryAgeRange(ctx context.Context, dmap string) (oldest, newest int64, err error) {
+	rc, err := cl.client.Pick()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	cmd := protocol.NewEntryAgeRange(dmap).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, 0, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return 0, 0, processProtocolError(err)
+	}
+	if len(result) != 2 {
+		return 0, 0, fmt.Errorf("invalid entry age range response: %v", result)
+	}
+	oldest, ok := result[0].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid oldest timestamp: %v", result[0])
+	}
+	newest, ok = result[1].(int64)
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid newest timestamp: %v", result[1])
+	}
+	return oldest, newest, nil
+}
+
+// ExpiringSoon returns up to limit keys in the given DMap whose remaining TTL is
+// below within, the soonest expiring keys first. Zero limit means no limit. Every
+// member scans all of its primary copies, so the cost is O(N) in the number of keys.
+func (cl *ClusterClient) ExpiringSoon(ctx context.Context, dmap string, within time.Duration, limit int) ([]KeyTTL, error) {
+	rc, err := cl.client.Pick()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewExpiringSoon(dmap, within.Milliseconds(), int64(limit)).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	if len(result)%2 != 0 {
+		return nil, fmt.Errorf("invalid expiring soon response: %v", result)
+	}
+	var keys []KeyTTL
+	for i := 0; i < len(result); i += 2 {
+		key, ok := result[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", result[i])
+		}
+		ttl, ok := result[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid TTL: %v", result[i+1])
+		}
+		keys = append(keys, KeyTTL{Key: key, TTL: time.Duration(ttl) * time.Millisecond})
+	}
+	return keys, nil
+}
+
+// CapTTL sets the TTL of the keys in the given DMap without a TTL or with a TTL longer
+// than maxTTL to maxTTL, and returns the number of the adjusted keys. Every member
+// scans all of its primary copies, so the cost is O(N) in the number of keys.
+func (cl *ClusterClient) CapTTL(ctx context.Context, dmap string, maxTTL time.Duration) (int, error) {
+	rc, err := cl.client.Pick()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewCapTTL(dmap, maxTTL.Milliseconds()).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	count, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return int(count), nil
+}
+
+// HotKeys returns the n most accessed keys in the given DMap with their approximate
+// access counts, the most accessed key first. The counts decay over time, so they
+// reflect the recent reads. It requires the access counter of the DMap to be enabled,
+// otherwise it returns ErrAccessCounterDisabled.
+func (cl *ClusterClient) HotKeys(ctx context.Context, dmap string, n int) ([]KeyAccess, error) {
+	rc, err := cl.client.Pick()
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := protocol.NewHotKeys(dmap, int64(n)).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, processProtocolError(err)
+	}
+	if len(result)%2 != 0 {
+		return nil, fmt.Errorf("invalid hot keys response: %v", result)
+	}
+	var keys []KeyAccess
+	for i := 0; i < len(result); i += 2 {
+		key, ok := result[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", result[i])
+		}
+		count, ok := result[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid access count: %v", result[i+1])
+		}
+		keys = append(keys, KeyAccess{Key: key, Count: count})
+	}
+	return keys, nil
+}
+
+// Diff compares two DMaps by the keys and the hashes of the values. It returns the keys
+// that exist only in dmapA, the keys that exist only in dmapB and the keys that exist
+// in both with different values. Only the hashes of the values are transferred, but
+// every member walks its primary copies of both DMaps, so the cost is O(N) in the
+// number of keys.
+func (cl *ClusterClient) Diff(ctx context.Context, dmapA, dmapB string) (onlyA, onlyB, valueDiffers []string, err error) {
+	rc, err := cl.client.Pick()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	cmd := protocol.NewDiff(dmapA, dmapB).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, nil, nil, processProtocolError(err)
+	}
+
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, nil, nil, processProtocolError(err)
+	}
+	if len(result) != 3 {
+		return nil, nil, nil, fmt.Errorf("invalid diff response: %v", result)
+	}
+
+	var lists [3][]string
+	for i, rawKeys := range result {
+		keys, ok := rawKeys.([]interface{})
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("invalid key list: %v", rawKeys)
+		}
+		for _, rawKey := range keys {
+			key, ok := rawKey.(string)
+			if !ok {
+				return nil, nil, nil, fmt.Errorf("invalid key: %v", rawKey)
+			}
+			lists[i] = append(lists[i], key)
+		}
+	}
+	return lists[0], lists[1], lists[2], nil
+}
+
+// SyncReplica replaces the replica of the DMap's fragment on the given partition and
+// member with a full copy of the primary fragment. It's useful to repair a stale
+// replica without waiting for read-repair. It returns the number of bytes transferred.
+// A member runs one sync per config.DMaps.ReplicaSyncInterval, it returns
+// ErrReplicaSyncRateLimited otherwise.
+func (cl *ClusterClient) SyncReplica(ctx context.Context, dmap string, partID uint64, member string) (int64, error) {
+	rc, err := cl.client.Pick()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewSyncReplica(dmap, partID, member).Command(ctx)
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+
+	n, err := cmd.Result()
+	if err != nil {
+		return 0, processProtocolError(err)
+	}
+	return n, nil
+}
+
+// StreamChanges streams the put, delete and expire events of the given DMap on the whole
+// cluster, starting from the changes written at or after since (Unix time in nanoseconds),
+// then tails the live changes. It reads the changes from every member and merges them. Call
+// the returned function to stop the stream, the channel is closed then. The stream is also
+// stopped when the client is closed. See EmbeddedClient.StreamChanges for the guarantees.
+func (cl *ClusterClient) StreamChanges(name string, since int64) (<-chan Change, func()) {
+	ctx, cancel := context.WithCancel(cl.ctx)
+
+	members := func(ctx context.Context) ([]string, error) {
+		members, err := cl.Members(ctx)
+		if err != nil {
+			return nil, err
+		}
+		addrs := make([]string, 0, len(members))
+		for _, member := range members {
+			addrs = append(addrs, member.Name)
+		}
+		return addrs, nil
+	}
+	read := func(ctx context.Context, addr string, since int64, partID, seq uint64) (*dmap.ChangeBatch, error) {
+		cmd := protocol.NewChanges(name, since, partID, seq).Command(ctx)
+		rc := cl.client.Get(addr)
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return nil, processProtocolError(err)
+		}
+		raw, err := cmd.Bytes()
+		if err != nil {
+			return nil, processProtocolError(err)
+		}
+		batch := &dmap.ChangeBatch{}
+		if err = msgpack.Unmarshal(raw, batch); err != nil {
+			return nil, err
+		}
+		return batch, nil
+	}
+	return dmap.MergeChanges(ctx, &cl.wg, since, members, read), cancel
+}
+
+// ExportMatching writes the entries of the DMap whose keys match the pattern to w and
+// returns the number of exported entries. The keys are matched on the partition owners
+// and the entries are streamed, so the memory use doesn't depend on the number of
+// matches. The records are msgpack-encoded and can be loaded with Import. See
+// https://pkg.go.dev/regexp for the pattern syntax.
+func (cl *ClusterClient) ExportMatching(ctx context.Context, dmap, pattern string, w io.Writer) (int, error) {
+	dm, err := cl.NewDMap(dmap)
+	if err != nil {
+		return 0, err
+	}
+	return exportMatching(ctx, dm, pattern, w)
+}
+
+// Import reads the records written by ExportMatching from r and puts them into the DMap.
+// The expiry times are preserved, the expired records are skipped. It returns the number
+// of imported entries.
+func (cl *ClusterClient) Import(ctx context.Context, dmap string, r io.Reader) (int, error) {
+	dm, err := cl.NewDMap(dmap)
+	if err != nil {
+		return 0, err
+	}
+	return importRecords(ctx, dm, r)
+}
+
+// ReplayWAL applies the mutations in the write-ahead logs of the members to the
+// cluster. dirs are the config.DMaps.WALDir directories of the members, the logs are
+// merged by the times of the mutations. It's useful to reconstruct the state in a
+// fresh cluster. It returns the number of applied mutations.
+func (cl *ClusterClient) ReplayWAL(ctx context.Context, dirs ...string) (int, error) {
+	return replayWAL(ctx, cl, dirs)
+}
+
 // Stats returns stats.Stats with the given options.
 func (cl *ClusterClient) Stats(ctx context.Context, address string, options ...StatsOption) (stats.Stats, error) {
 	var cfg statsConfig
@@ -576,26 +2042,34 @@ func (cl *ClusterClient) Stats(ctx context.Context, address string, options ...S
 
 // Members returns a thread-safe list of cluster members.
 func (cl *ClusterClient) Members(ctx context.Context) ([]Member, error) {
+	members, _, err := cl.members(ctx)
+	return members, err
+}
+
+// members returns the members and the hash seed of the cluster. The hash seed is
+// zero if the members don't advertise it.
+func (cl *ClusterClient) members(ctx context.Context) ([]Member, uint64, error) {
 	rc, err := cl.client.Pick()
 	if err != nil {
-		return []Member{}, err
+		return []Member{}, 0, err
 	}
 
 	cmd := protocol.NewClusterMembers().Command(ctx)
 	err = rc.Process(ctx, cmd)
 	if err != nil {
-		return []Member{}, processProtocolError(err)
+		return []Member{}, 0, processProtocolError(err)
 	}
 
 	if err = cmd.Err(); err != nil {
-		return []Member{}, processProtocolError(err)
+		return []Member{}, 0, processProtocolError(err)
 	}
 
 	items, err := cmd.Slice()
 	if err != nil {
-		return []Member{}, processProtocolError(err)
+		return []Member{}, 0, processProtocolError(err)
 	}
 	var members []Member
+	var hashSeed uint64
 	for _, rawItem := range items {
 		m := Member{}
 		item := rawItem.([]interface{})
@@ -609,9 +2083,16 @@ func (cl *ClusterClient) Members(ctx context.Context) ([]Member, error) {
 		if item[2] == "true" {
 			m.Coordinator = true
 		}
+		if len(item) > 3 && hashSeed == 0 {
+			// The hash seed is sent as a string, like the IDs.
+			hashSeed, err = strconv.ParseUint(item[3].(string), 10, 64)
+			if err != nil {
+				return []Member{}, 0, err
+			}
+		}
 		members = append(members, m)
 	}
-	return members, nil
+	return members, hashSeed, nil
 }
 
 // RefreshMetadata fetches a list of available members and the latest routing
@@ -643,6 +2124,7 @@ func (cl *ClusterClient) RefreshMetadata(ctx context.Context) error {
 			if err := cl.client.Close(addr); err != nil {
 				return err
 			}
+			cl.latency.remove(addr)
 		}
 	}
 
@@ -662,6 +2144,8 @@ func (cl *ClusterClient) Close(ctx context.Context) error {
 
 	// Wait for the background workers:
 	// * fetchRoutingTablePeriodically
+	// * probeLatenciesPeriodically
+	// * change streams
 	cl.wg.Wait()
 
 	// Close the underlying TCP sockets gracefully.
@@ -700,7 +2184,13 @@ type clusterClientConfig struct {
 	logger                    *log.Logger
 	config                    *config.Client
 	hasher                    hasher.Hasher
+	hashSeed                  uint64
+	hashTags                  bool
 	routingTableFetchInterval time.Duration
+	readStrategy              ReadStrategy
+	latencyProbeInterval      time.Duration
+	hedgeDelay                time.Duration
+	maxHedges                 int
 }
 
 func WithHasher(h hasher.Hasher) ClusterClientOption {
@@ -709,6 +2199,25 @@ func WithHasher(h hasher.Hasher) ClusterClientOption {
 	}
 }
 
+// WithHashSeed sets the seed of the key hashes. It must be the same with the HashSeed
+// of the cluster, otherwise the requests are not sent to the partition owners directly.
+// By default, the client uses the seed advertised by the cluster members.
+func WithHashSeed(seed uint64) ClusterClientOption {
+	return func(cfg *clusterClientConfig) {
+		cfg.hashSeed = seed
+	}
+}
+
+// WithHashTags enables the hash tags. It must be used if the HashTags option of the
+// cluster is enabled, otherwise the requests are not sent to the partition owners directly.
+// The setting is shared by the process, a client without this option leaves it as it is,
+// so it doesn't disable the hash tags of an embedded member in the same process.
+func WithHashTags() ClusterClientOption {
+	return func(cfg *clusterClientConfig) {
+		cfg.hashTags = true
+	}
+}
+
 func WithLogger(l *log.Logger) ClusterClientOption {
 	return func(cfg *clusterClientConfig) {
 		cfg.logger = l
@@ -793,6 +2302,10 @@ func NewClusterClient(addresses []string, options ...ClusterClientOption) (*Clus
 		cc.routingTableFetchInterval = DefaultRoutingTableFetchInterval
 	}
 
+	if cc.latencyProbeInterval <= 0 {
+		cc.latencyProbeInterval = DefaultLatencyProbeInterval
+	}
+
 	if err := cc.config.Sanitize(); err != nil {
 		return nil, err
 	}
@@ -802,11 +2315,12 @@ func NewClusterClient(addresses []string, options ...ClusterClientOption) (*Clus
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cl := &ClusterClient{
-		client: server.NewClient(cc.config),
-		config: &cc,
-		logger: cc.logger,
-		ctx:    ctx,
-		cancel: cancel,
+		client:  server.NewClient(cc.config),
+		config:  &cc,
+		logger:  cc.logger,
+		latency: newLatencyTracker(),
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 
 	// Initialize clients for the given cluster members.
@@ -815,26 +2329,43 @@ func NewClusterClient(addresses []string, options ...ClusterClientOption) (*Clus
 	}
 
 	// Discover all cluster members
-	members, err := cl.Members(ctx)
+	members, hashSeed, err := cl.members(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error while discovering the cluster members: %w", err)
 	}
 	for _, member := range members {
 		cl.client.Get(member.Name)
 	}
+	if cc.hashSeed == 0 {
+		// Use the seed that is generated by the cluster.
+		cc.hashSeed = hashSeed
+	}
 
 	// Hash function is required to target primary owners instead of random cluster members.
 	partitions.SetHashFunc(cc.hasher)
+	partitions.SetHashSeed(cc.hashSeed)
 
 	// Initial fetch. ClusterClient targets the primary owners for a smooth and quick operation.
 	if err := cl.fetchRoutingTable(); err != nil {
 		return nil, err
 	}
+	if cc.hashTags {
+		// The partition count is known after the first fetch.
+		partitions.SetHashTags(cl.partitionCount)
+	}
 
 	// Refresh the routing table in every 15 seconds.
 	cl.wg.Add(1)
 	go cl.fetchRoutingTablePeriodically()
 
+	if cc.readStrategy == ReadFromFastestReplica {
+		// Measure the latencies before serving the first read request.
+		cl.probeLatencies()
+
+		cl.wg.Add(1)
+		go cl.probeLatenciesPeriodically()
+	}
+
 	return cl, nil
 }
 
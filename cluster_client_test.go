@@ -15,14 +15,21 @@
 package olric
 
 import (
+	"bytes"
 	"context"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"math"
 	"os"
 	"testing"
 	"time"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/hasher"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/kvstore/table"
+	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/buraksezer/olric/stats"
 	"github.com/stretchr/testify/require"
@@ -101,6 +108,350 @@ func TestClusterClient_RoutingTable_Cluster(t *testing.T) {
 	require.Len(t, rt, int(db.config.PartitionCount))
 }
 
+func TestClusterClient_KeyPlacement(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		return c
+	}
+	cluster.addMemberWithConfig(t, newConfig()) // Cluster coordinator
+	<-time.After(250 * time.Millisecond)
+
+	cluster.addMemberWithConfig(t, newConfig())
+	db := cluster.addMemberWithConfig(t, newConfig())
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		require.NoError(t, dm.Put(ctx, key, testutil.ToVal(i)))
+
+		kp, err := c.KeyPlacement(ctx, "mydmap", key)
+		require.NoError(t, err)
+		require.Equal(t, partitions.HKey("mydmap", key)%db.config.PartitionCount, kp.PartID)
+		require.Len(t, kp.ReplicaOwners, 1)
+		require.NotEqual(t, kp.PrimaryOwner, kp.ReplicaOwners[0])
+
+		// The write lands on the primary owner and the replica owner.
+		for name, member := range cluster.members {
+			primaryLength := member.primary.PartitionByID(kp.PartID).Length()
+			backupLength := member.backup.PartitionByID(kp.PartID).Length()
+			switch name {
+			case kp.PrimaryOwner:
+				require.NotZero(t, primaryLength)
+				require.Zero(t, backupLength)
+			case kp.ReplicaOwners[0]:
+				require.Zero(t, primaryLength)
+				require.NotZero(t, backupLength)
+			default:
+				require.Zero(t, primaryLength)
+				require.Zero(t, backupLength)
+			}
+		}
+	}
+}
+
+func TestClusterClient_EntryAgeRange(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	start := time.Now().UnixNano()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i))
+	}
+	end := time.Now().UnixNano()
+
+	oldest, newest, err := c.EntryAgeRange(ctx, "mydmap")
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, oldest, start)
+	require.LessOrEqual(t, oldest, newest)
+	require.LessOrEqual(t, newest, end)
+}
+
+func TestClusterClient_ExpiringSoon(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		ttl := time.Hour
+		if i < 5 {
+			ttl = time.Duration(10+i) * time.Second
+		}
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i, EX(ttl)))
+	}
+	require.NoError(t, dm.Put(ctx, "no-ttl", "value"))
+
+	keys, err := c.ExpiringSoon(ctx, "mydmap", time.Minute, 0)
+	require.NoError(t, err)
+	require.Len(t, keys, 5)
+	for i, k := range keys {
+		require.Equal(t, testutil.ToKey(i), k.Key)
+		require.Greater(t, int64(k.TTL), int64(0))
+		require.LessOrEqual(t, int64(k.TTL), int64(15*time.Second))
+	}
+
+	keys, err = c.ExpiringSoon(ctx, "mydmap", time.Minute, 2)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+}
+
+func TestClusterClient_CapTTL(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		if i < 10 {
+			require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i, EX(time.Hour)))
+			continue
+		}
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i))
+	}
+
+	count, err := c.CapTTL(ctx, "mydmap", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 20, count)
+
+	keys, err := c.ExpiringSoon(ctx, "mydmap", time.Minute+time.Second, 0)
+	require.NoError(t, err)
+	require.Len(t, keys, 20)
+}
+
+func TestClusterClient_Diff(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	live, err := c.NewDMap("live")
+	require.NoError(t, err)
+	rebuilt, err := c.NewDMap("rebuilt")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, live.Put(ctx, testutil.ToKey(i), i))
+		require.NoError(t, rebuilt.Put(ctx, testutil.ToKey(i), i))
+	}
+	require.NoError(t, live.Put(ctx, "live-only", "value"))
+	require.NoError(t, rebuilt.Put(ctx, "rebuilt-only", "value"))
+	require.NoError(t, rebuilt.Put(ctx, testutil.ToKey(3), "changed"))
+
+	onlyA, onlyB, valueDiffers, err := c.Diff(ctx, "live", "rebuilt")
+	require.NoError(t, err)
+	require.Equal(t, []string{"live-only"}, onlyA)
+	require.Equal(t, []string{"rebuilt-only"}, onlyB)
+	require.Equal(t, []string{testutil.ToKey(3)}, valueDiffers)
+}
+
+func TestClusterClient_SyncReplica(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		return c
+	}
+	db := cluster.addMemberWithConfig(t, newConfig())
+	cluster.addMemberWithConfig(t, newConfig())
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i))
+	}
+
+	replica := db.backup.PartitionByID(0).Owners()[0]
+	n, err := c.SyncReplica(ctx, "mydmap", 0, replica.String())
+	require.NoError(t, err)
+	require.Greater(t, n, int64(0))
+
+	_, err = c.SyncReplica(ctx, "mydmap", 0, replica.String())
+	require.ErrorIs(t, err, ErrReplicaSyncRateLimited)
+}
+
+func TestClusterClient_ExportMatching(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.Put(ctx, fmt.Sprintf("user:%d", i), i))
+		require.NoError(t, dm.Put(ctx, fmt.Sprintf("session:%d", i), i))
+	}
+	require.NoError(t, dm.Put(ctx, "user:ttl", "value", EX(time.Hour)))
+
+	buf := bytes.NewBuffer(nil)
+	n, err := c.ExportMatching(ctx, "mydmap", "^user:", buf)
+	require.NoError(t, err)
+	require.Equal(t, 101, n)
+
+	n, err = c.Import(ctx, "fresh", buf)
+	require.NoError(t, err)
+	require.Equal(t, 101, n)
+
+	fresh, err := c.NewDMap("fresh")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		gr, err := fresh.Get(ctx, fmt.Sprintf("user:%d", i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+
+		_, err = fresh.Get(ctx, fmt.Sprintf("session:%d", i))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+
+	gr, err := fresh.Get(ctx, "user:ttl")
+	require.NoError(t, err)
+	require.Greater(t, gr.TTL(), int64(0))
+}
+
+// walState returns the values and the expiry flags of the keys, the missing keys are omitted.
+func walState(ctx context.Context, t *testing.T, dm DMap) map[string]string {
+	state := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		gr, err := dm.Get(ctx, key)
+		if err == ErrKeyNotFound {
+			continue
+		}
+		require.NoError(t, err)
+		value, err := gr.Byte()
+		require.NoError(t, err)
+		state[key] = fmt.Sprintf("%s ttl:%t", value, gr.TTL() > 0)
+	}
+	return state
+}
+
+func TestClusterClient_ReplayWAL(t *testing.T) {
+	var dirs []string
+	cluster := newTestOlricCluster(t)
+	for i := 0; i < 2; i++ {
+		dir, err := ioutil.TempDir("", "olric-wal")
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, os.RemoveAll(dir))
+		}()
+		dirs = append(dirs, dir)
+
+		c := testutil.NewConfig()
+		c.DMaps.WALDir = dir
+		c.DMaps.WALSync = "always"
+		cluster.addMemberWithConfig(t, c)
+	}
+
+	ctx := context.Background()
+	var db *Olric
+	for _, member := range cluster.members {
+		db = member
+	}
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm.Put(ctx, fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i)))
+	}
+	for i := 0; i < 10; i++ {
+		_, err = dm.Delete(ctx, fmt.Sprintf("key-%d", i))
+		require.NoError(t, err)
+	}
+	for i := 10; i < 20; i++ {
+		require.NoError(t, dm.Put(ctx, fmt.Sprintf("key-%d", i), "updated"))
+	}
+	for i := 20; i < 30; i++ {
+		require.NoError(t, dm.Expire(ctx, fmt.Sprintf("key-%d", i), time.Hour))
+	}
+	// Recreate a deleted key.
+	_, err = dm.Incr(ctx, "key-0", 10)
+	require.NoError(t, err)
+	expected := walState(ctx, t, dm)
+	require.Len(t, expected, 91)
+
+	fresh := newTestOlricCluster(t)
+	freshDB := fresh.addMember(t)
+	fc, err := NewClusterClient([]string{freshDB.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, fc.Close(ctx))
+	}()
+
+	n, err := fc.ReplayWAL(ctx, dirs...)
+	require.NoError(t, err)
+	require.Equal(t, 100+10+10+10+1, n)
+
+	freshDM, err := fc.NewDMap("mydmap")
+	require.NoError(t, err)
+	require.Equal(t, expected, walState(ctx, t, freshDM))
+}
+
 func TestClusterClient_Put(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -277,6 +628,8 @@ func TestClusterClient_IncrByFloat(t *testing.T) {
 	result, err := dm.IncrByFloat(ctx, "mykey", 1.2)
 	require.NoError(t, err)
 	require.Equal(t, 13.199999999999998, result)
+	_, err = dm.IncrByFloat(ctx, "mykey", math.Inf(1))
+	require.ErrorIs(t, err, ErrInvalidFloat)
 }
 
 func TestClusterClient_Decr(t *testing.T) {
@@ -337,7 +690,59 @@ func TestClusterClient_GetPut(t *testing.T) {
 	require.Equal(t, "myvalue", value)
 }
 
-func TestClusterClient_Expire(t *testing.T) {
+func TestClusterClient_GetByTag(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	for i := 0; i < 2; i++ {
+		c := testutil.NewConfig()
+		c.HashTags = true
+		cluster.addMemberWithConfig(t, c)
+	}
+	var db *Olric
+	for _, member := range cluster.members {
+		db = member
+	}
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name}, WithHashTags())
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, fmt.Sprintf("{user:1}:%d", i), i))
+		require.NoError(t, dm.Put(ctx, fmt.Sprintf("{user:2}:%d", i), i))
+	}
+
+	entries, err := dm.GetByTag(ctx, "user:1")
+	require.NoError(t, err)
+	require.Len(t, entries, 10)
+	for i := 0; i < 10; i++ {
+		gr, ok := entries[fmt.Sprintf("{user:1}:%d", i)]
+		require.True(t, ok)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+
+	t.Run("Client without hash tags", func(t *testing.T) {
+		c2, err := NewClusterClient([]string{db.name})
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, c2.Close(ctx))
+		}()
+
+		// The hash tags of the members in this process are still enabled.
+		entries, err := dm.GetByTag(ctx, "user:2")
+		require.NoError(t, err)
+		require.Len(t, entries, 10)
+	})
+}
+
+func TestClusterClient_Rotate(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
 
@@ -351,19 +756,25 @@ func TestClusterClient_Expire(t *testing.T) {
 	dm, err := c.NewDMap("mydmap")
 	require.NoError(t, err)
 
-	err = dm.Put(ctx, "mykey", "myvalue")
+	gr, err := dm.Rotate(ctx, "secret", "v1", time.Minute)
 	require.NoError(t, err)
+	require.Nil(t, gr)
 
-	err = dm.Expire(ctx, "mykey", time.Millisecond)
+	gr, err = dm.Rotate(ctx, "secret", "v2", time.Minute)
 	require.NoError(t, err)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "v1", value)
 
-	<-time.After(time.Millisecond)
-
-	_, err = dm.Get(ctx, "mykey")
-	require.ErrorIs(t, err, ErrKeyNotFound)
+	gr, err = dm.Get(ctx, "secret:previous")
+	require.NoError(t, err)
+	value, err = gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "v1", value)
+	require.NotZero(t, gr.TTL())
 }
 
-func TestClusterClient_Lock_Unlock(t *testing.T) {
+func TestClusterClient_DeleteIfExpiredBefore(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
 
@@ -376,8 +787,1328 @@ func TestClusterClient_Lock_Unlock(t *testing.T) {
 
 	dm, err := c.NewDMap("mydmap")
 	require.NoError(t, err)
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue", PX(time.Second)))
 
-	lx, err := dm.Lock(ctx, "lock.foo.key", time.Second)
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	expiry := gr.TTL()
+
+	require.NoError(t, dm.Expire(ctx, "mykey", time.Hour))
+	deleted, err := dm.DeleteIfExpiredBefore(ctx, "mykey", expiry)
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	deleted, err = dm.DeleteIfExpiredBefore(ctx, "mykey", time.Now().Add(2*time.Hour).UnixNano()/1000000)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestClusterClient_HotKeys(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"mydmap": {AccessCounter: true},
+	}
+	db := cluster.addMemberWithConfig(t, c)
+
+	ctx := context.Background()
+	cc, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, cc.Close(ctx))
+	}()
+
+	dm, err := cc.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i))
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+	for i := 0; i < 20; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(5))
+		require.NoError(t, err)
+	}
+
+	keys, err := cc.HotKeys(ctx, "mydmap", 1)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.Equal(t, testutil.ToKey(5), keys[0].Key)
+	require.Equal(t, int64(21), keys[0].Count)
+
+	_, err = cc.HotKeys(ctx, "other", 1)
+	require.ErrorIs(t, err, ErrAccessCounterDisabled)
+}
+
+func TestClusterClient_HIncrBy(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var errGr errgroup.Group
+	for i := 0; i < 10; i++ {
+		errGr.Go(func() error {
+			_, err := dm.HIncrBy(ctx, "mykey", "counter", 1)
+			return err
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	result, err := dm.HIncrBy(ctx, "mykey", "counter", 1)
+	require.NoError(t, err)
+	require.Equal(t, 11, result)
+
+	err = dm.Put(ctx, "mystring", "foo")
+	require.NoError(t, err)
+	_, err = dm.HIncrBy(ctx, "mystring", "counter", 1)
+	require.ErrorIs(t, err, ErrNotAHash)
+}
+
+func TestClusterClient_HMSet_HMGet(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.HMSet(ctx, "mykey", map[string][]byte{
+		"name":  []byte("foo"),
+		"count": []byte("1"),
+	})
+	require.NoError(t, err)
+
+	_, err = dm.HIncrBy(ctx, "mykey", "count", 1)
+	require.NoError(t, err)
+
+	fields, err := dm.HMGet(ctx, "mykey", "name", "count", "absent")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{
+		"name":  []byte("foo"),
+		"count": []byte("2"),
+	}, fields)
+
+	err = dm.Put(ctx, "mystring", "foo")
+	require.NoError(t, err)
+	_, err = dm.HMGet(ctx, "mystring", "name")
+	require.ErrorIs(t, err, ErrNotAHash)
+}
+
+func TestClusterClient_PutWithVersion(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	version, err := dm.PutWithVersion(ctx, "mykey", []byte("first"), 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), version)
+
+	_, err = dm.PutWithVersion(ctx, "mykey", []byte("stale"), 0)
+	require.ErrorIs(t, err, ErrVersionMismatch)
+
+	version, err = dm.PutWithVersion(ctx, "mykey", []byte("second"), 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), version)
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.Byte()
+	require.NoError(t, err)
+	require.Equal(t, []byte("second"), value)
+	require.Equal(t, int64(2), gr.Version())
+}
+
+func TestClusterClient_PutIfField(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Put(ctx, "mykey", []byte(`{"state": "pending"}`))
+	require.NoError(t, err)
+
+	written, err := dm.PutIfField(ctx, "mykey", "state", []byte("pending"), []byte(`{"state": "active"}`))
+	require.NoError(t, err)
+	require.True(t, written)
+
+	written, err = dm.PutIfField(ctx, "mykey", "state", []byte("pending"), []byte(`{"state": "cancelled"}`))
+	require.NoError(t, err)
+	require.False(t, written)
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.Byte()
+	require.NoError(t, err)
+	require.Equal(t, `{"state": "active"}`, string(value))
+
+	err = dm.Put(ctx, "string", "value")
+	require.NoError(t, err)
+	_, err = dm.PutIfField(ctx, "string", "state", []byte("pending"), []byte("value"))
+	require.ErrorIs(t, err, ErrNotAnObject)
+}
+
+func TestClusterClient_Merge(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Merge(ctx, "mykey", []byte(`{"name": "olric", "tags": {"cache": true}}`))
+	require.NoError(t, err)
+	err = dm.Merge(ctx, "mykey", []byte(`{"tags": {"cache": null, "kv": true}, "version": 1}`))
+	require.NoError(t, err)
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.Byte()
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name": "olric", "tags": {"kv": true}, "version": 1}`, string(value))
+
+	err = dm.Put(ctx, "string", "value")
+	require.NoError(t, err)
+	err = dm.Merge(ctx, "string", []byte(`{"a": 1}`))
+	require.ErrorIs(t, err, ErrNotAnObject)
+}
+
+func TestClusterClient_PromoteStandby(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	active := func() string {
+		gr, err := dm.Get(ctx, "flag")
+		require.NoError(t, err)
+		value, err := gr.String()
+		require.NoError(t, err)
+		return value
+	}
+
+	require.NoError(t, dm.SetStandby(ctx, "flag", []byte("off")))
+	_, err = dm.Get(ctx, "flag")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.NoError(t, dm.PromoteStandby(ctx, "flag"))
+	require.Equal(t, "off", active())
+
+	require.NoError(t, dm.SetStandby(ctx, "flag", []byte("on")))
+	require.Equal(t, "off", active())
+	require.NoError(t, dm.PromoteStandby(ctx, "flag"))
+	require.Equal(t, "on", active())
+
+	// Roll back.
+	require.NoError(t, dm.PromoteStandby(ctx, "flag"))
+	require.Equal(t, "off", active())
+
+	err = dm.PromoteStandby(ctx, "absent")
+	require.ErrorIs(t, err, ErrNoStandby)
+}
+
+func TestClusterClient_TryLock(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	lx, err := dm.TryLock(ctx, "mykey", time.Minute)
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = dm.TryLock(ctx, "mykey", time.Minute)
+	require.ErrorIs(t, err, ErrLockNotAcquired)
+	require.Less(t, time.Since(start), time.Second)
+
+	require.NoError(t, lx.Unlock(ctx))
+	lx, err = dm.TryLock(ctx, "mykey", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, lx.Unlock(ctx))
+}
+
+func TestClusterClient_LPushCapped(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		length, err := dm.LPushCapped(ctx, "mylist", 3, TrimTail, []byte(fmt.Sprintf("%d", i)))
+		require.NoError(t, err)
+		require.LessOrEqual(t, length, 3)
+	}
+
+	list, err := dm.LRange(ctx, "mylist", 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("4"), []byte("3"), []byte("2")}, list)
+
+	require.NoError(t, dm.Put(ctx, "mystring", "foo"))
+	_, err = dm.LPushCapped(ctx, "mystring", 3, TrimTail, []byte("foo"))
+	require.ErrorIs(t, err, ErrNotAList)
+}
+
+func TestClusterClient_SetBit(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	old, err := dm.SetBit(ctx, "flags", 20, 1)
+	require.NoError(t, err)
+	require.Equal(t, 0, old)
+
+	old, err = dm.SetBit(ctx, "flags", 20, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, old)
+
+	bit, err := dm.GetBit(ctx, "flags", 20)
+	require.NoError(t, err)
+	require.Equal(t, 1, bit)
+
+	bit, err = dm.GetBit(ctx, "flags", 200)
+	require.NoError(t, err)
+	require.Equal(t, 0, bit)
+}
+
+func TestClusterClient_ReserveSequenceBlock(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	n, err := dm.NextSequence(ctx, "myseq")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	start, err := dm.ReserveSequenceBlock(ctx, "myseq", 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), start)
+
+	n, err = dm.NextSequence(ctx, "myseq")
+	require.NoError(t, err)
+	require.Equal(t, int64(12), n)
+}
+
+func TestClusterClient_ReserveSequenceBlock_TTLDuration(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c1 := testutil.NewConfig()
+	c1.DMaps.TTLDuration = time.Hour
+	db := cluster.addMemberWithConfig(t, c1)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The counter would expire and the sequence would restart from 1.
+	_, err = dm.NextSequence(ctx, "myseq")
+	require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+}
+
+func TestClusterClient_AcquirePermit(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var releases []func(ctx context.Context) error
+	for i := 0; i < 2; i++ {
+		release, ok, err := dm.AcquirePermit(ctx, "mysemaphore", 2, time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok)
+		releases = append(releases, release)
+	}
+
+	_, ok, err := dm.AcquirePermit(ctx, "mysemaphore", 2, time.Minute)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	require.NoError(t, releases[0](ctx))
+	_, ok, err = dm.AcquirePermit(ctx, "mysemaphore", 2, time.Minute)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+func TestClusterClient_Register(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("services")
+	require.NoError(t, err)
+
+	ttl := 300 * time.Millisecond
+	heartbeatA, err := dm.Register(ctx, "api", "instance-a", []byte("10.0.0.1:8080"), ttl)
+	require.NoError(t, err)
+	heartbeatB, err := dm.Register(ctx, "api", "instance-b", []byte("10.0.0.2:8080"), ttl)
+	require.NoError(t, err)
+
+	instances, err := dm.Discover(ctx, "api")
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	require.Equal(t, "instance-a", instances[0].Name)
+	require.Equal(t, []byte("10.0.0.1:8080"), instances[0].Meta)
+	require.Equal(t, "instance-b", instances[1].Name)
+	require.Greater(t, instances[1].TTL, time.Duration(0))
+
+	// Instance B stops its heartbeats and ages out.
+	for i := 0; i < 6; i++ {
+		<-time.After(100 * time.Millisecond)
+		require.NoError(t, heartbeatA(ctx))
+	}
+
+	instances, err = dm.Discover(ctx, "api")
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	require.Equal(t, "instance-a", instances[0].Name)
+
+	require.ErrorIs(t, heartbeatB(ctx), ErrNoSuchInstance)
+}
+
+func TestClusterClient_SetNXGet(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	acquired, gr, err := dm.SetNXGet(ctx, "mykey", "myvalue", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+
+	acquired, gr, err = dm.SetNXGet(ctx, "mykey", "myvalue-2", time.Minute)
+	require.NoError(t, err)
+	require.False(t, acquired)
+
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+	require.NotZero(t, gr.TTL())
+}
+
+func TestClusterClient_GetOrSet(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	gr, loaded, err := dm.GetOrSet(ctx, "mykey", "myvalue", time.Minute)
+	require.NoError(t, err)
+	require.False(t, loaded)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+
+	gr, loaded, err = dm.GetOrSet(ctx, "mykey", "myvalue-2", time.Minute)
+	require.NoError(t, err)
+	require.True(t, loaded)
+	value, err = gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+	require.NotZero(t, gr.TTL())
+}
+
+func TestClusterClient_ClaimNext(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	expected := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		key, value := testutil.ToKey(i), testutil.ToVal(i)
+		require.NoError(t, dm.Put(ctx, key, value))
+		expected[key] = string(value)
+	}
+
+	claimed := make(map[string]string)
+	for i := 0; i < 10; i++ {
+		key, gr, err := dm.ClaimNext(ctx, time.Minute)
+		require.NoError(t, err)
+		value, err := gr.Byte()
+		require.NoError(t, err)
+		claimed[key] = string(value)
+	}
+	require.Equal(t, expected, claimed)
+
+	_, _, err = dm.ClaimNext(ctx, time.Minute)
+	require.ErrorIs(t, err, ErrNoAvailable)
+}
+
+func TestClusterClient_StreamChanges(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	changes, cancel := c.StreamChanges("mydmap", time.Now().UnixNano())
+	defer cancel()
+
+	readChange := func() Change {
+		select {
+		case change, ok := <-changes:
+			require.True(t, ok)
+			return change
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "no change received")
+		}
+		return Change{}
+	}
+
+	// The keys are owned by both members.
+	expected := make(map[string]string)
+	for i := 0; i < 20; i++ {
+		key, value := testutil.ToKey(i), testutil.ToVal(i)
+		require.NoError(t, dm.Put(ctx, key, value))
+		expected[key] = string(value)
+	}
+
+	received := make(map[string]string)
+	for len(received) < len(expected) {
+		change := readChange()
+		require.Equal(t, ChangePut, change.Type)
+		received[change.Key] = string(change.Value)
+	}
+	require.Equal(t, expected, received)
+
+	_, err = dm.Delete(ctx, testutil.ToKey(0))
+	require.NoError(t, err)
+
+	// The puts may be delivered again from the change logs.
+	for {
+		change := readChange()
+		if change.Type == ChangeDelete {
+			require.Equal(t, testutil.ToKey(0), change.Key)
+			break
+		}
+		require.Equal(t, ChangePut, change.Type)
+	}
+}
+
+func TestClusterClient_DecrAndDeleteAtZero(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.Incr(ctx, "mykey", 2)
+	require.NoError(t, err)
+
+	remaining, deleted, err := dm.DecrAndDeleteAtZero(ctx, "mykey", 1)
+	require.NoError(t, err)
+	require.False(t, deleted)
+	require.Equal(t, 1, remaining)
+
+	remaining, deleted, err = dm.DecrAndDeleteAtZero(ctx, "mykey", 1)
+	require.NoError(t, err)
+	require.True(t, deleted)
+	require.Equal(t, 0, remaining)
+
+	_, _, err = dm.DecrAndDeleteAtZero(ctx, "mykey", 1)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, dm.Put(ctx, "not-an-integer", "foobar"))
+	_, _, err = dm.DecrAndDeleteAtZero(ctx, "not-an-integer", 1)
+	require.ErrorIs(t, err, ErrNotAnInteger)
+}
+
+func TestClusterClient_GetDel(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.GetDel(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue"))
+
+	gr, err := dm.GetDel(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "myvalue", value)
+
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestClusterClient_Campaign(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	c1, err := NewClusterClient([]string{db1.name})
+	require.NoError(t, err)
+	c2, err := NewClusterClient([]string{db2.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c2.Close(ctx))
+	}()
+
+	dm1, err := c1.NewDMap("elections")
+	require.NoError(t, err)
+	dm2, err := c2.NewDMap("elections")
+	require.NoError(t, err)
+
+	_, err = dm2.Leader(ctx, "my-election")
+	require.ErrorIs(t, err, ErrNoLeader)
+
+	leaseTTL := 300 * time.Millisecond
+	isLeader, _, err := dm1.Campaign(ctx, "my-election", "candidate-a", leaseTTL)
+	require.NoError(t, err)
+	require.True(t, isLeader)
+
+	// The lease is renewed in the background, there is one leader beyond the lease TTL.
+	for i := 0; i < 10; i++ {
+		isLeader, _, err = dm2.Campaign(ctx, "my-election", "candidate-b", leaseTTL)
+		require.NoError(t, err)
+		require.False(t, isLeader)
+
+		leader, err := dm2.Leader(ctx, "my-election")
+		require.NoError(t, err)
+		require.Equal(t, "candidate-a", leader)
+		<-time.After(100 * time.Millisecond)
+	}
+
+	// The leader stops renewing, a new leader is elected after the lease expires.
+	require.NoError(t, c1.Close(ctx))
+	isLeader, _, err = dm2.Campaign(ctx, "my-election", "candidate-b", leaseTTL)
+	require.NoError(t, err)
+	require.False(t, isLeader)
+
+	var resign func()
+	require.Eventually(t, func() bool {
+		isLeader, resign, err = dm2.Campaign(ctx, "my-election", "candidate-b", leaseTTL)
+		require.NoError(t, err)
+		return isLeader
+	}, 5*time.Second, 50*time.Millisecond)
+
+	leader, err := dm2.Leader(ctx, "my-election")
+	require.NoError(t, err)
+	require.Equal(t, "candidate-b", leader)
+
+	resign()
+	_, err = dm2.Leader(ctx, "my-election")
+	require.ErrorIs(t, err, ErrNoLeader)
+}
+
+func TestClusterClient_CampaignWithLeadership(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("elections")
+	require.NoError(t, err)
+
+	leaseTTL := 300 * time.Millisecond
+	leadership, resign, err := dm.CampaignWithLeadership(ctx, "my-election", "candidate-a", leaseTTL)
+	require.NoError(t, err)
+	require.NotNil(t, leadership)
+	defer resign()
+
+	leadershipB, _, err := dm.CampaignWithLeadership(ctx, "my-election", "candidate-b", leaseTTL)
+	require.NoError(t, err)
+	require.Nil(t, leadershipB)
+
+	// Another candidate takes the lease, the next renewal of the leader fails.
+	_, err = dm.Delete(ctx, "my-election")
+	require.NoError(t, err)
+	isLeader, _, err := dm.Campaign(ctx, "my-election", "candidate-b", leaseTTL)
+	require.NoError(t, err)
+	require.True(t, isLeader)
+
+	select {
+	case <-leadership.Done():
+	case <-time.After(5 * leaseTTL):
+		t.Fatal("Leadership is not lost")
+	}
+}
+
+func TestClusterClient_Append(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	length, err := dm.Append(ctx, "mykey", []byte("line-1\n"))
+	require.NoError(t, err)
+	require.Equal(t, 7, length)
+
+	length, err = dm.Append(ctx, "mykey", []byte("line-2\n"))
+	require.NoError(t, err)
+	require.Equal(t, 14, length)
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	value, err := gr.Byte()
+	require.NoError(t, err)
+	require.Equal(t, []byte("line-1\nline-2\n"), value)
+}
+
+func TestClusterClient_GetAndReset(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	value, err := dm.GetAndReset(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, 0, value)
+
+	_, err = dm.Incr(ctx, "mykey", 5)
+	require.NoError(t, err)
+
+	value, err = dm.GetAndReset(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, 5, value)
+
+	value, err = dm.Incr(ctx, "mykey", 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, value)
+
+	require.NoError(t, dm.Put(ctx, "not-an-integer", "foobar"))
+	_, err = dm.GetAndReset(ctx, "not-an-integer")
+	require.ErrorIs(t, err, ErrNotAnInteger)
+}
+
+func TestClusterClient_Eval(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	script := `
+if get(KEYS[1]) == ARGV[1] {
+	set(KEYS[1], ARGV[2])
+	set(KEYS[2], ARGV[2])
+	return get(KEYS[2])
+}
+return nil
+`
+	keys := []string{"key-1", "key-2"}
+	result, err := dm.Eval(ctx, script, keys, "old", "new")
+	require.NoError(t, err)
+	require.Nil(t, result)
+
+	require.NoError(t, dm.Put(ctx, "key-1", "old"))
+	result, err = dm.Eval(ctx, script, keys, "old", "new")
+	require.NoError(t, err)
+	require.Equal(t, "new", result)
+
+	for _, key := range keys {
+		gr, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		value, err := gr.String()
+		require.NoError(t, err)
+		require.Equal(t, "new", value)
+	}
+
+	result, err = dm.Eval(ctx, `return exists(KEYS[1])`, keys)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), result)
+
+	_, err = dm.Eval(ctx, `error("aborted")`, nil)
+	require.ErrorIs(t, err, ErrScript)
+	require.Contains(t, err.Error(), "aborted")
+}
+
+func TestClusterClient_PutAllIfNoneExist(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.Put(ctx, "key-2", "existing"))
+
+	written, err := dm.PutAllIfNoneExist(ctx, map[string][]byte{
+		"key-1": []byte("value"),
+		"key-2": []byte("value"),
+	})
+	require.NoError(t, err)
+	require.False(t, written)
+
+	_, err = dm.Get(ctx, "key-1")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	written, err = dm.PutAllIfNoneExist(ctx, map[string][]byte{
+		"key-1": []byte("value"),
+		"key-3": []byte("value"),
+	})
+	require.NoError(t, err)
+	require.True(t, written)
+
+	for _, key := range []string{"key-1", "key-3"} {
+		gr, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		value, err := gr.Byte()
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), value)
+	}
+}
+
+func TestClusterClient_MemoryUsage(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	value := bytes.Repeat([]byte("a"), 1000)
+	require.NoError(t, dm.Put(ctx, "mykey", value))
+
+	size, err := dm.MemoryUsage(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, len("mykey")+len(value)+table.MetadataLength, size)
+
+	_, err = dm.MemoryUsage(ctx, "absent-key")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestClusterClient_Exists(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.Put(ctx, "key-1", "value"))
+	require.NoError(t, dm.Put(ctx, "key-2", "value"))
+
+	count, err := dm.Exists(ctx, "key-1", "key-2", "absent-key")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+}
+
+func TestClusterClient_PutMany(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	pairs := make(map[string][]byte)
+	for i := 0; i < 100; i++ {
+		pairs[fmt.Sprintf("key-%d", i)] = []byte(fmt.Sprintf("value-%d", i))
+	}
+	require.NoError(t, dm.PutMany(ctx, pairs, EX(time.Hour)))
+
+	for key, value := range pairs {
+		gr, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		raw, err := gr.Byte()
+		require.NoError(t, err)
+		require.Equal(t, value, raw)
+
+		ttl, err := dm.TTL(ctx, key)
+		require.NoError(t, err)
+		require.Greater(t, ttl, 59*time.Minute)
+	}
+
+	err = dm.PutMany(ctx, map[string][]byte{"key-1": []byte("new-value")}, NX())
+	require.ErrorIs(t, err, ErrKeyFound)
+}
+
+func TestClusterClient_GetMany(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var keys []string
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		keys = append(keys, key)
+		require.NoError(t, dm.Put(ctx, key, fmt.Sprintf("value-%d", i)))
+	}
+	keys = append(keys, "absent-key")
+
+	values, err := dm.GetMany(ctx, keys)
+	require.NoError(t, err)
+	require.Len(t, values, 10)
+	require.NotContains(t, values, "absent-key")
+	for i := 0; i < 10; i++ {
+		require.Equal(t, []byte(fmt.Sprintf("value-%d", i)), values[fmt.Sprintf("key-%d", i)])
+	}
+}
+
+func TestClusterClient_GetTTLMany(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.Put(ctx, "key-with-ttl", "value", EX(time.Hour)))
+	require.NoError(t, dm.Put(ctx, "key-without-ttl", "value"))
+
+	ttls, err := dm.GetTTLMany(ctx, []string{"key-with-ttl", "key-without-ttl", "absent-key"})
+	require.NoError(t, err)
+	require.Len(t, ttls, 2)
+	require.Greater(t, ttls["key-with-ttl"], (59 * time.Minute).Milliseconds())
+	require.Equal(t, int64(-1), ttls["key-without-ttl"])
+}
+
+func TestClusterClient_Expire(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	err = dm.Expire(ctx, "mykey", time.Millisecond)
+	require.NoError(t, err)
+
+	<-time.After(time.Millisecond)
+
+	_, err = dm.Get(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestClusterClient_TTL(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.TTL(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	err = dm.Put(ctx, "mykey", "myvalue")
+	require.NoError(t, err)
+
+	ttl, err := dm.TTL(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, time.Duration(0), ttl)
+
+	err = dm.Put(ctx, "mykey", "myvalue", EX(time.Minute))
+	require.NoError(t, err)
+
+	ttl, err = dm.TTL(ctx, "mykey")
+	require.NoError(t, err)
+	require.LessOrEqual(t, ttl, time.Minute)
+	require.Greater(t, ttl, 50*time.Second)
+}
+
+func TestClusterClient_Persist(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Persist(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	err = dm.Put(ctx, "mykey", "myvalue", PX(50*time.Millisecond))
+	require.NoError(t, err)
+
+	err = dm.Persist(ctx, "mykey")
+	require.NoError(t, err)
+
+	<-time.After(100 * time.Millisecond)
+
+	gr, err := dm.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), gr.TTL())
+}
+
+func TestClusterClient_Lock_Unlock(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	lx, err := dm.Lock(ctx, "lock.foo.key", time.Second)
+	require.NoError(t, err)
+
+	err = lx.Unlock(ctx)
+	require.NoError(t, err)
+}
+
+func TestClusterClient_Lock_FencingToken(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The first holder's lease expires, a newer holder gets a greater fencing token.
+	first, err := dm.LockWithTimeout(ctx, "lock.foo.key", 50*time.Millisecond, time.Second)
+	require.NoError(t, err)
+	second, err := dm.LockWithTimeout(ctx, "lock.foo.key", time.Minute, time.Second)
+	require.NoError(t, err)
+	require.Greater(t, second.Token(), first.Token())
+	require.ErrorIs(t, first.Unlock(ctx), ErrNoSuchLock)
+	require.NoError(t, second.Unlock(ctx))
+
+	// The counter survives the release of the lock.
+	third, err := dm.Lock(ctx, "lock.foo.key", time.Second)
+	require.NoError(t, err)
+	require.Greater(t, third.Token(), second.Token())
+	require.NoError(t, third.Unlock(ctx))
+}
+
+func TestClusterClient_Lock_LeaseAndPut(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	lx, err := dm.LockWithTimeout(ctx, "lock.foo.key", time.Second, time.Second)
+	require.NoError(t, err)
+
+	require.NoError(t, lx.LeaseAndPut(ctx, []byte("value"), time.Minute))
+	gr, err := dm.Get(ctx, ProtectedValueKeyPrefix+"lock.foo.key")
+	require.NoError(t, err)
+	value, err := gr.Byte()
+	require.NoError(t, err)
+	require.Equal(t, []byte("value"), value)
+
+	require.NoError(t, lx.Unlock(ctx))
+	require.ErrorIs(t, lx.LeaseAndPut(ctx, []byte("stale"), time.Minute), ErrNoSuchLock)
+}
+
+func TestClusterClient_LockInfo(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+	cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	held, _, err := dm.LockInfo(ctx, "lock.foo.key")
+	require.NoError(t, err)
+	require.False(t, held)
+
+	lx, err := dm.LockWithTimeout(ctx, "lock.foo.key", time.Minute, time.Second)
+	require.NoError(t, err)
+
+	held, ttl, err := dm.LockInfo(ctx, "lock.foo.key")
+	require.NoError(t, err)
+	require.True(t, held)
+	require.Greater(t, ttl, 50*time.Second)
+	require.NoError(t, lx.Unlock(ctx))
+}
+
+func TestClusterClient_LockWithOptions(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.LockWithOptions(ctx, "lock.foo.key", LockOptions{Lease: 100 * time.Millisecond})
+	require.NoError(t, err)
+
+	// The first attempt fails, the next one is made after the lease expires.
+	lx, err := dm.LockWithOptions(ctx, "lock.foo.key", LockOptions{
+		Deadline:     5 * time.Second,
+		PollInterval: 200 * time.Millisecond,
+	})
 	require.NoError(t, err)
 
 	err = lx.Unlock(ctx)
@@ -0,0 +1,99 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// olric-wal-replay applies the write-ahead logs of the Olric members to a cluster.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+
+	"github.com/buraksezer/olric"
+)
+
+func usage() {
+	var msg = `Usage: olric-wal-replay [options] DIR...
+
+Applies the write-ahead logs in the given directories to an Olric cluster. The
+directories are the dmaps.walDir directories of the members. The logs are merged
+by the times of the mutations.
+
+Options:
+  -h, --help     Print this message and exit.
+  -a, --address  Address of a cluster member. Default is 127.0.0.1:3320.
+      --hashSeed Hash seed of the cluster. Default is the seed advertised by the cluster.
+
+The Go runtime version %s
+Report bugs to https://github.com/buraksezer/olric/issues
+`
+	_, err := fmt.Fprintf(os.Stdout, msg, runtime.Version())
+	if err != nil {
+		panic(err)
+	}
+}
+
+type arguments struct {
+	address  string
+	hashSeed uint64
+	help     bool
+}
+
+// DefaultAddress is the default address of the cluster member.
+const DefaultAddress = "127.0.0.1:3320"
+
+func main() {
+	args := &arguments{}
+
+	f := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	f.SetOutput(ioutil.Discard)
+	f.BoolVar(&args.help, "h", false, "")
+	f.BoolVar(&args.help, "help", false, "")
+
+	f.StringVar(&args.address, "address", DefaultAddress, "")
+	f.StringVar(&args.address, "a", DefaultAddress, "")
+	f.Uint64Var(&args.hashSeed, "hashSeed", 0, "")
+
+	if err := f.Parse(os.Args[1:]); err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "parsing error: %v\n", err)
+		usage()
+		os.Exit(1)
+	}
+
+	if args.help || f.NArg() == 0 {
+		usage()
+		return
+	}
+
+	ctx := context.Background()
+	c, err := olric.NewClusterClient([]string{args.address}, olric.WithHashSeed(args.hashSeed))
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to connect to the cluster: %s: %v\n", args.address, err)
+		os.Exit(1)
+	}
+	defer func() {
+		_ = c.Close(ctx)
+	}()
+
+	n, err := c.ReplayWAL(ctx, f.Args()...)
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "failed to replay the write-ahead logs after %d mutations: %v\n", n, err)
+		os.Exit(1)
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "%d mutations have been applied\n", n)
+}
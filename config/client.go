@@ -83,6 +83,12 @@ type Client struct {
 	// new connection is slow.
 	MinIdleConns int
 
+	// Maximum number of socket connections across all members. When the limit is
+	// reached, the idle connections to the least recently used member are closed.
+	// It bounds the number of file descriptors on large clusters, PoolSize is still
+	// the limit per member. Default is 0, no limit.
+	MaxTotalConns int
+
 	// Connection age at which client retires (closes) the connection.
 	// Default is to not close aged connections.
 	MaxConnAge time.Duration
@@ -128,7 +134,11 @@ func (c *Client) Sanitize() error {
 			if c.TLSConfig == nil {
 				return netDialer.DialContext(ctx, network, addr)
 			}
-			return tls.DialWithDialer(netDialer, network, addr, c.TLSConfig)
+			tlsDialer := &tls.Dialer{
+				NetDialer: netDialer,
+				Config:    c.TLSConfig,
+			}
+			return tlsDialer.DialContext(ctx, network, addr)
 		}
 	}
 	if c.PoolSize == 0 {
@@ -175,7 +185,12 @@ func (c *Client) Sanitize() error {
 }
 
 // Validate finds errors in the current configuration.
-func (c *Client) Validate() error { return nil }
+func (c *Client) Validate() error {
+	if c.MaxTotalConns < 0 {
+		return fmt.Errorf("maxTotalConns cannot be negative: %d", c.MaxTotalConns)
+	}
+	return nil
+}
 
 func (c *Client) RedisOptions() *redis.Options {
 	// Note: IdleCheckFrequency is gone since go-redis no longer checks idle connections.
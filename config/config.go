@@ -15,6 +15,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
 	"log"
@@ -49,6 +50,18 @@ const (
 	AsyncReplicationMode = 1
 )
 
+const (
+	// FullReadRepair enables full read-repair scope which means that the whole
+	// entry is rewritten on the members that have a stale version of it. The
+	// default scope is FullReadRepair.
+	FullReadRepair = 0
+
+	// MetadataReadRepair enables metadata read-repair scope which means that only
+	// the TTL and the timestamp are updated if the stale version has the same value.
+	// The whole entry is rewritten only if the values diverge.
+	MetadataReadRepair = 1
+)
+
 const (
 	LogLevelDebug = "DEBUG"
 	LogLevelWarn  = "WARN"
@@ -138,6 +151,9 @@ const (
 	// DefaultTriggerBalancerInterval is interval between two sequential call of balancer worker.
 	DefaultTriggerBalancerInterval = 15 * time.Second
 
+	// DefaultMaxConcurrentMoves is the default maximum number of concurrent fragment moves.
+	DefaultMaxConcurrentMoves = 1
+
 	// DefaultCheckEmptyFragmentsInterval is the default value of interval between
 	// two sequential call of empty fragment cleaner. It's one minute by default.
 	DefaultCheckEmptyFragmentsInterval = time.Minute
@@ -147,6 +163,49 @@ const (
 	// its work is done. It's 10 minutes by default.
 	DefaultTriggerCompactionInterval = 10 * time.Minute
 
+	// DefaultShutdownSnapshotTimeout is the default value of maximum time to write
+	// the shutdown snapshot of DMaps. It's 30 seconds by default.
+	DefaultShutdownSnapshotTimeout = 30 * time.Second
+
+	// DefaultLockPollInterval is the default value of interval between two attempts
+	// to acquire a lock. It's 10 milliseconds by default.
+	DefaultLockPollInterval = 10 * time.Millisecond
+
+	// DefaultReplicaSyncInterval is the default value of minimum interval between two
+	// replica syncs on a member. It's 1 second by default.
+	DefaultReplicaSyncInterval = time.Second
+
+	// DefaultAccessCounterHalfLife is the default value of the half-life of the per-key
+	// access counters. It's 1 minute by default.
+	DefaultAccessCounterHalfLife = time.Minute
+
+	// DefaultMaxMetricLabels is the default value of maximum number of DMaps that have
+	// their own label in the operation metrics.
+	DefaultMaxMetricLabels = 100
+
+	// DefaultMaxGossipValueSize is the default value of maximum size of a value that is
+	// replicated by the gossip protocol. It's 256 bytes by default.
+	DefaultMaxGossipValueSize = 256
+
+	// DefaultSlowLogSampleRate is the default fraction of the slow operations that are
+	// recorded in the slow log.
+	DefaultSlowLogSampleRate = 1.0
+
+	// DefaultSlowLogMaxLen is the default value of maximum number of the entries in the
+	// slow log of a member.
+	DefaultSlowLogMaxLen = 128
+
+	// DefaultWALSync is the default sync policy of the write-ahead log.
+	DefaultWALSync = "interval"
+
+	// DefaultWALSyncInterval is the default value of interval between two syncs of the
+	// write-ahead log with the interval sync policy. It's 1 second by default.
+	DefaultWALSyncInterval = time.Second
+
+	// DefaultWALMaxSegmentSize is the default value of maximum size of a write-ahead log
+	// segment file. It's 64 MB by default.
+	DefaultWALMaxSegmentSize = 64 << 20
+
 	// DefaultLeaveTimeout is the default value of maximum amount of time before
 	DefaultLeaveTimeout = 5 * time.Second
 
@@ -161,6 +220,10 @@ const (
 	// order to take the connection open, the option will prevent unexpected
 	// connection closed events.
 	DefaultKeepAlivePeriod = 300 * time.Second
+
+	// DefaultErrorResponseLogRate is the default maximum number of error responses
+	// logged per second, if LogErrorResponses is enabled.
+	DefaultErrorResponseLogRate = 100
 )
 
 // Config is the configuration to create a Olric instance.
@@ -177,14 +240,32 @@ type Config struct {
 	// Default LogLevel is DEBUG. Available levels: "DEBUG", "WARN", "ERROR", "INFO"
 	LogLevel string
 
+	// LogErrorResponses enables logging of every error response returned to the
+	// clients with the command, its first arguments and the error. It's useful to
+	// debug the client-side logic errors, such as ErrKeyFound returned by the NX path.
+	LogErrorResponses bool
+
+	// ErrorResponseLogVerbosity denotes the verbosity level of the error response
+	// log entries. The default value is DefaultLogVerbosity.
+	ErrorResponseLogVerbosity int32
+
+	// ErrorResponseLogRate is the maximum number of error responses logged per second.
+	// The rest is counted and reported in the next second. The default value is 100.
+	ErrorResponseLogRate int
+
 	// BindAddr denotes the address that Olric will bind to for communication
 	// with other Olric nodes.
 	BindAddr string
 
 	// BindPort denotes the address that Olric will bind to for communication
-	// with other Olric nodes.
+	// with other Olric nodes. If it's zero, Olric binds to a port chosen by the
+	// operating system and advertises it to the other nodes.
 	BindPort int
 
+	// TLS enables TLS on the listener at BindAddr:BindPort. The members connect to
+	// each other on the same listener, so Client.TLSConfig has to be set too.
+	TLS *tls.Config
+
 	// Client denotes configuration for TCP clients in Olric and the official
 	// Golang client.
 	Client *Client
@@ -214,6 +295,25 @@ type Config struct {
 	// TriggerBalancerInterval is interval between two sequential call of balancer worker.
 	TriggerBalancerInterval time.Duration
 
+	// MaxConcurrentMoves is the maximum number of fragments that the balancer moves
+	// to the new owners at once. The rest are queued. Default is 1, the fragments are
+	// moved one by one.
+	MaxConcurrentMoves int
+
+	// EnableWriteFencing makes the previous owner of a partition stop accepting writes
+	// for it after the routing table is updated. The writes that wait for a fragment
+	// during a move are forwarded to the new owner when the move step completes, so
+	// they are never applied to a fragment that is about to be discarded. The writes
+	// on a moving partition are blocked until the move step completes. Default is false.
+	EnableWriteFencing bool
+
+	// DrainConnections makes the node drain its client connections gracefully when it
+	// leaves the cluster. The new connections are rejected, the idle connections are
+	// closed and the busy connections are closed after their in-flight commands complete,
+	// so the clients reconnect to another member. Draining is bounded by LeaveTimeout,
+	// the remaining connections are closed abruptly. Default is false.
+	DrainConnections bool
+
 	// The list of host:port which are used by memberlist for discovery.
 	// Don't confuse it with Name.
 	Peers []string
@@ -236,6 +336,10 @@ type Config struct {
 	// Switch to control read-repair algorithm which helps to reduce entropy.
 	ReadRepair bool
 
+	// ReadRepairScope controls what read-repair rewrites on the stale members.
+	// Default value is FullReadRepair.
+	ReadRepairScope int
+
 	// Default value is SyncReplicationMode.
 	ReplicationMode int
 
@@ -257,6 +361,25 @@ type Config struct {
 	// Default hasher is github.com/cespare/xxhash/v2
 	Hasher hasher.Hasher
 
+	// HashSeed is mixed into the hashes of the keys, so the distribution of the keys
+	// over the partitions is unpredictable to the ones who don't know the seed. It
+	// prevents the clients from choosing keys that collide into a single partition.
+	// Zero means that the member which forms a new cluster generates a random seed and
+	// the joining members learn it from the cluster. A member that joins a cluster
+	// without a seed, e.g. during a rolling upgrade from an older version, keeps using
+	// zero. A member with a seed that differs from the seed of the cluster cannot join
+	// it. The cluster clients learn the seed from the cluster unless they set one.
+	// Default is 0.
+	HashSeed uint64
+
+	// HashTags enables the hash tags for partition affinity. If a key contains a
+	// substring inside {...}, only that substring is hashed to find the partition of
+	// the key, so the keys with the same tag, like "{user:42}:profile" and
+	// "{user:42}:session", are stored on the same partition. The keys without a tag
+	// are distributed as usual. All members and the cluster clients must use the same
+	// setting. Default is false.
+	HashTags bool
+
 	// LogOutput is the writer where logs should be sent. If this is not
 	// set, logging will go to stderr by default. You cannot specify both LogOutput
 	// and Logger at the same time.
@@ -281,6 +404,16 @@ type Config struct {
 	// cluster before forming a new one.
 	MaxJoinAttempts int
 
+	// MaxClusterSize denotes the maximum number of members in the cluster. A node
+	// that tries to join a cluster which has already reached this limit is rejected
+	// with an error. Zero means no limit.
+	//
+	// The cluster coordinator admits the joining nodes one by one, so the concurrent
+	// joins through different members cannot exceed the limit. A joining node waits
+	// for the verdict of the coordinator up to MemberlistConfig.TCPTimeout, and the
+	// join is retried if the coordinator doesn't answer.
+	MaxClusterSize int
+
 	// Callback function. Olric calls this after
 	// the server is ready to accept new connections.
 	Started func()
@@ -334,6 +467,10 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("cannot specify ReadQuorum greater than ReplicaCount")
 	}
 
+	if c.ReadRepairScope != FullReadRepair && c.ReadRepairScope != MetadataReadRepair {
+		return fmt.Errorf("invalid ReadRepairScope: %d", c.ReadRepairScope)
+	}
+
 	if c.WriteQuorum <= 0 {
 		return fmt.Errorf("cannot specify WriteQuorum less than or equal to zero")
 	}
@@ -341,6 +478,14 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("cannot specify WriteQuorum greater than ReplicaCount")
 	}
 
+	if c.MaxClusterSize < 0 {
+		return fmt.Errorf("cannot specify MaxClusterSize less than zero")
+	}
+
+	if c.ErrorResponseLogRate < 0 {
+		return fmt.Errorf("cannot specify ErrorResponseLogRate less than zero")
+	}
+
 	if err := c.validateMemberlistConfig(); err != nil {
 		return err
 	}
@@ -353,8 +498,8 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("bindAddr cannot be empty")
 	}
 
-	if c.BindPort == 0 {
-		return fmt.Errorf("bindPort cannot be empty or zero")
+	if c.BindPort < 0 {
+		return fmt.Errorf("bindPort cannot be negative")
 	}
 
 	// Check peers. If Peers slice contains node's itself, return an error.
@@ -397,6 +542,14 @@ func (c *Config) Sanitize() error {
 		c.LogVerbosity = DefaultLogVerbosity
 	}
 
+	if c.ErrorResponseLogVerbosity <= 0 {
+		c.ErrorResponseLogVerbosity = DefaultLogVerbosity
+	}
+
+	if c.ErrorResponseLogRate == 0 {
+		c.ErrorResponseLogRate = DefaultErrorResponseLogRate
+	}
+
 	if c.Logger == nil {
 		c.Logger = log.New(c.LogOutput, "", log.LstdFlags)
 	} else {
@@ -414,12 +567,6 @@ func (c *Config) Sanitize() error {
 		}
 		c.BindAddr = name
 	}
-	// We currently don't support ephemeral port selection. Because it needs
-	// improved flow control in server initialization stage.
-	if c.BindPort == 0 {
-		c.BindPort = DefaultPort
-	}
-
 	if c.LoadFactor == 0 {
 		c.LoadFactor = DefaultLoadFactor
 	}
@@ -471,6 +618,10 @@ func (c *Config) Sanitize() error {
 		c.TriggerBalancerInterval = DefaultTriggerBalancerInterval
 	}
 
+	if c.MaxConcurrentMoves <= 0 {
+		c.MaxConcurrentMoves = DefaultMaxConcurrentMoves
+	}
+
 	if c.KeepAlivePeriod == 0 {
 		c.KeepAlivePeriod = DefaultKeepAlivePeriod
 	}
@@ -16,8 +16,17 @@ package config
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -50,6 +59,7 @@ client:
   poolFIFO: true
   poolSize: 10
   minIdleConns: 5
+  maxTotalConns: 100
   maxConnAge: 2h
   poolTimeout: 4s
   idleTimeout: 6m
@@ -163,6 +173,7 @@ func TestConfig(t *testing.T) {
 	c.Client.PoolFIFO = true
 	c.Client.PoolSize = 10
 	c.Client.MinIdleConns = 5
+	c.Client.MaxTotalConns = 100
 	c.Client.MaxConnAge = 2 * time.Hour
 	c.Client.PoolTimeout = 4 * time.Second
 	c.Client.IdleTimeout = 6 * time.Minute
@@ -233,3 +244,113 @@ func TestConfig_Initialize(t *testing.T) {
 	require.NoError(t, c.Sanitize())
 	require.NoError(t, c.Validate())
 }
+
+func TestConfig_Validate_MaxTotalConns(t *testing.T) {
+	c := NewClient()
+	c.MaxTotalConns = -1
+	require.Error(t, c.Validate())
+}
+
+func TestConfig_Validate_LockPollJitter(t *testing.T) {
+	c := &Config{}
+	require.NoError(t, c.Sanitize())
+	c.DMaps.LockPollJitter = 1.5
+	require.Error(t, c.Validate())
+}
+
+func TestConfig_Validate_WAL_EncryptionKey(t *testing.T) {
+	c := &Config{}
+	require.NoError(t, c.Sanitize())
+	c.DMaps.WALDir = t.TempDir()
+	require.NoError(t, c.Validate())
+
+	c.DMaps.EncryptionKey = make([]byte, 32)
+	require.Error(t, c.Validate())
+
+	c.DMaps.EncryptionKey = nil
+	c.DMaps.Custom = map[string]DMap{"encrypted": {EncryptionKey: make([]byte, 32)}}
+	require.Error(t, c.Validate())
+}
+
+// writeTestCertificate writes a self-signed certificate and its key in PEM format.
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "olric-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	rawKey, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: rawKey}), 0600))
+	return certFile, keyFile
+}
+
+func TestConfig_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeTestCertificate(t, dir)
+
+	newConfigFile := func(tlsSection string) string {
+		path := filepath.Join(dir, "olricd.yaml")
+		data := `memberlist:
+  environment: "local"
+  bindAddr: "127.0.0.1"
+  bindPort: 3322
+
+tls:
+` + tlsSection
+		require.NoError(t, ioutil.WriteFile(path, []byte(data), 0600))
+		return path
+	}
+
+	c, err := Load(newConfigFile(`  certFile: "` + certFile + `"
+  keyFile: "` + keyFile + `"
+  clientCAs: "` + certFile + `"
+  requireClientCert: true
+`))
+	require.NoError(t, err)
+	require.NotNil(t, c.TLS)
+	require.Len(t, c.TLS.Certificates, 1)
+	require.NotNil(t, c.TLS.ClientCAs)
+	require.Equal(t, tls.RequireAndVerifyClientCert, c.TLS.ClientAuth)
+
+	// The members connect to each other with the same certificate.
+	require.NotNil(t, c.Client.TLSConfig)
+	require.Equal(t, c.TLS.Certificates, c.Client.TLSConfig.Certificates)
+	require.NotNil(t, c.Client.TLSConfig.RootCAs)
+
+	t.Run("Without TLS", func(t *testing.T) {
+		c, err := Load(newConfigFile(""))
+		require.NoError(t, err)
+		require.Nil(t, c.TLS)
+		require.Nil(t, c.Client.TLSConfig)
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		_, err := Load(newConfigFile(`  certFile: "` + certFile + `"
+  keyFile: "` + certFile + `"
+`))
+		require.ErrorContains(t, err, "failed to load tls.certFile and tls.keyFile")
+	})
+
+	t.Run("Invalid client CAs", func(t *testing.T) {
+		_, err := Load(newConfigFile(`  certFile: "` + certFile + `"
+  keyFile: "` + keyFile + `"
+  clientCAs: "` + keyFile + `"
+`))
+		require.ErrorContains(t, err, "failed to parse tls.clientCAs")
+	})
+}
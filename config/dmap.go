@@ -16,6 +16,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"time"
 )
 
@@ -46,6 +47,12 @@ type DMap struct {
 	// instance.
 	TTLDuration time.Duration
 
+	// MaxAge denotes the maximum time for each entry to stay in the DMap after its
+	// last write, regardless of its TTL. The entries older than MaxAge are expired
+	// and evicted automatically, it enforces a hard data retention window. It's
+	// disabled if it's zero.
+	MaxAge time.Duration
+
 	// MaxKeys denotes maximum key count on a particular node. So if you have 10
 	// nodes with MaxKeys=100000, your key count in the cluster should be around
 	// MaxKeys*10=1000000
@@ -64,6 +71,88 @@ type DMap struct {
 	// EvictionPolicy determines the eviction policy in use. It's NONE by default.
 	// Set as LRU to enable LRU eviction policy.
 	EvictionPolicy EvictionPolicy
+
+	// NoEvictKeyPatterns is a list of regular expressions. The keys that match any of
+	// the patterns are never evicted by the LRU policy or MaxIdleDuration. It doesn't
+	// affect the TTL of the keys. The patterns are matched on every eviction candidate,
+	// so keep the list short. The protected keys are counted in LRUSamples, the limits
+	// can be exceeded while all the sampled keys are protected.
+	NoEvictKeyPatterns []string
+
+	// EvictionGracePeriod protects the newly written keys from eviction. A key is not
+	// evicted by the LRU policy or MaxIdleDuration within this period after its last
+	// write, so a key cannot be evicted before it's read under memory pressure. The limits
+	// can be exceeded while all the sampled keys are in their grace period. It doesn't
+	// affect the TTL of the keys. It's disabled if it's zero.
+	EvictionGracePeriod time.Duration
+
+	// DeadLetterDMap is the name of a DMap to archive the entries expired by their TTL. The
+	// partition owner writes a JSON document with the DMap name, the key, the last value and
+	// the expiry time to the dead-letter DMap under the same key. The archival write is
+	// best-effort and asynchronous, it never blocks the expiry. It's disabled if it's empty.
+	DeadLetterDMap string
+
+	// EncryptionKey enables the encryption of the values at rest. It's an AES key of 16, 24
+	// or 32 bytes to select AES-128, AES-192 or AES-256. The partition owner encrypts every
+	// value with AES-GCM before storing it, a random nonce is stored in front of the
+	// ciphertext. The values are decrypted on read, so the replicas and the rebalancing
+	// transfers carry the ciphertext. Every write and read costs one AES-GCM operation on
+	// the value and 28 extra bytes are stored per entry. The key has to be the same on all
+	// members. It's disabled if it's empty. It cannot be used with DMaps.WALDir, the
+	// write-ahead log stores the values in plain text.
+	EncryptionKey []byte
+
+	// ReadTimeout is the maximum time of a read operation on the DMap. An operation that
+	// takes longer fails with ErrTimeout and stops waiting for the partition and the other
+	// members. It's useful to fail fast on latency-critical DMaps. There is no limit if
+	// it's zero.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the maximum time of a write operation on the DMap. An operation that
+	// takes longer fails with ErrTimeout. A write that times out while waiting for the
+	// partition is not applied, one that is already sent to another member may still be
+	// applied. There is no limit if it's zero.
+	WriteTimeout time.Duration
+
+	// KeySchema is a regular expression that all the keys of the DMap have to match, like
+	// "^[a-z]+:[0-9]+:[a-z]+$" for type:id:field keys. The partition owner rejects the
+	// writes with a non-matching key with ErrKeySchema, so malformed keys are caught at
+	// write time. The keys are not validated if it's empty.
+	KeySchema string
+
+	// AccessCounter enables the approximate per-key access counters on the partition
+	// owners. The counters are updated on every read and decay over time, so they reflect
+	// the recent accesses. HotKeys requires the counters. It costs a map update under a
+	// lock on every read. It's disabled by default.
+	AccessCounter bool
+
+	// PreSplitSize is the expected total size of the DMap in bytes. If it's set, the initial
+	// table of every fragment is allocated with PreSplitSize/PartitionCount bytes instead of
+	// the tableSize of the storage engine, and the members create the fragments of their
+	// partitions when the DMap is created. It prevents the repeated table growth while a huge
+	// DMap is being loaded. The empty fragments are still deleted periodically, the recreated
+	// ones use the same size. It's disabled if it's zero.
+	PreSplitSize int
+
+	// GossipReplication enables the replication of the small values by the gossip protocol.
+	// The partition owner piggybacks every write of a value up to MaxGossipValueSize bytes
+	// on the gossip messages, and every member keeps the values in a local replica that
+	// serves the reads without a network round trip. It's useful for the tiny values that
+	// are read everywhere, like feature flags. The replicas are eventually consistent: a
+	// member may serve a stale value until the gossip reaches it, and the larger values are
+	// read from the partition owner as usual. The writes are still replicated to the backup
+	// owners. It's disabled by default.
+	GossipReplication bool
+
+	// SlowLogThreshold enables the slow log. The Get, Put and Delete operations on the
+	// DMap that take longer are recorded in the slow log of the member that serves them.
+	// It's disabled if it's zero.
+	SlowLogThreshold time.Duration
+
+	// SlowLogSampleRate is the fraction of the slow operations that are recorded in the
+	// slow log. It must be between 0 and 1. A lower rate keeps a high-traffic DMap from
+	// flooding the slow log. It's 1 by default, all the slow operations are recorded.
+	SlowLogSampleRate float64
 }
 
 // Sanitize sets default values to empty configuration variables, if it's possible.
@@ -80,6 +169,9 @@ func (dm *DMap) Sanitize() error {
 	if dm.MaxKeys < 0 {
 		dm.MaxKeys = 0
 	}
+	if dm.PreSplitSize < 0 {
+		dm.PreSplitSize = 0
+	}
 
 	if dm.Engine == nil {
 		dm.Engine = NewEngine()
@@ -98,6 +190,20 @@ func (dm *DMap) Validate() error {
 		return fmt.Errorf("failed to validate storage engine configuration: %w", err)
 	}
 
+	for _, pattern := range dm.NoEvictKeyPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid no-evict key pattern: %s: %w", pattern, err)
+		}
+	}
+
+	if err := validateEncryptionKey(dm.EncryptionKey); err != nil {
+		return err
+	}
+
+	if err := validateSlowLogSampleRate(dm.SlowLogSampleRate); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -34,3 +34,12 @@ func TestConfig_DMap(t *testing.T) {
 	require.Equal(t, EvictionPolicy("NONE"), d.EvictionPolicy)
 	require.NotNil(t, d.Engine)
 }
+
+func TestConfig_DMap_EncryptionKey(t *testing.T) {
+	d := &DMap{EncryptionKey: []byte("short")}
+	require.NoError(t, d.Sanitize())
+	require.Error(t, d.Validate())
+
+	d.EncryptionKey = make([]byte, 32)
+	require.NoError(t, d.Validate())
+}
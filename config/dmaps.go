@@ -16,6 +16,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"runtime"
 	"time"
 )
@@ -45,6 +46,13 @@ type DMaps struct {
 	// distributed map instance.
 	TTLDuration time.Duration
 
+	// MaxAge denotes the maximum time for each entry to stay in the DMap after its
+	// last write, regardless of its TTL. The entries older than MaxAge are expired
+	// and evicted automatically, it enforces a hard data retention window. The
+	// no-evict key patterns and the eviction grace period don't apply to it. It's
+	// disabled if it's zero.
+	MaxAge time.Duration
+
 	// MaxKeys denotes maximum key count on a particular node. So if you have 10
 	// nodes with MaxKeys=100000, your key count in the cluster should be around
 	// MaxKeys*10=1000000
@@ -64,6 +72,88 @@ type DMaps struct {
 	// Set as LRU to enable LRU eviction policy.
 	EvictionPolicy EvictionPolicy
 
+	// NoEvictKeyPatterns is a list of regular expressions. The keys that match any of
+	// the patterns are never evicted by the LRU policy or MaxIdleDuration. It doesn't
+	// affect the TTL of the keys. The patterns are matched on every eviction candidate,
+	// so keep the list short. The protected keys are counted in LRUSamples, the limits
+	// can be exceeded while all the sampled keys are protected.
+	NoEvictKeyPatterns []string
+
+	// EvictionGracePeriod protects the newly written keys from eviction. A key is not
+	// evicted by the LRU policy or MaxIdleDuration within this period after its last
+	// write, so a key cannot be evicted before it's read under memory pressure. The limits
+	// can be exceeded while all the sampled keys are in their grace period. It doesn't
+	// affect the TTL of the keys. It's disabled if it's zero.
+	EvictionGracePeriod time.Duration
+
+	// DeadLetterDMap is the name of a DMap to archive the entries expired by their TTL. The
+	// partition owner writes a JSON document with the DMap name, the key, the last value and
+	// the expiry time to the dead-letter DMap under the same key. The archival write is
+	// best-effort and asynchronous, it never blocks the expiry. It's disabled if it's empty.
+	DeadLetterDMap string
+
+	// EncryptionKey enables the encryption of the values at rest. It's an AES key of 16, 24
+	// or 32 bytes to select AES-128, AES-192 or AES-256. The partition owner encrypts every
+	// value with AES-GCM before storing it, a random nonce is stored in front of the
+	// ciphertext. The values are decrypted on read, so the replicas and the rebalancing
+	// transfers carry the ciphertext. Every write and read costs one AES-GCM operation on
+	// the value and 28 extra bytes are stored per entry. The key has to be the same on all
+	// members. It's disabled if it's empty. It cannot be used with WALDir, the write-ahead
+	// log stores the values in plain text.
+	EncryptionKey []byte
+
+	// ReadTimeout is the maximum time of a read operation on the DMap. An operation that
+	// takes longer fails with ErrTimeout and stops waiting for the partition and the other
+	// members. It's useful to fail fast on latency-critical DMaps. There is no limit if
+	// it's zero.
+	ReadTimeout time.Duration
+
+	// WriteTimeout is the maximum time of a write operation on the DMap. An operation that
+	// takes longer fails with ErrTimeout. A write that times out while waiting for the
+	// partition is not applied, one that is already sent to another member may still be
+	// applied. There is no limit if it's zero.
+	WriteTimeout time.Duration
+
+	// KeySchema is a regular expression that all the keys of the DMap have to match, like
+	// "^[a-z]+:[0-9]+:[a-z]+$" for type:id:field keys. The partition owner rejects the
+	// writes with a non-matching key with ErrKeySchema, so malformed keys are caught at
+	// write time. The keys are not validated if it's empty.
+	KeySchema string
+
+	// AccessCounter enables the approximate per-key access counters on the partition
+	// owners. The counters are updated on every read and decay over time, so they reflect
+	// the recent accesses. HotKeys requires the counters. It costs a map update under a
+	// lock on every read. It's disabled by default.
+	AccessCounter bool
+
+	// PreSplitSize is the expected total size of the DMap in bytes. If it's set, the initial
+	// table of every fragment is allocated with PreSplitSize/PartitionCount bytes instead of
+	// the tableSize of the storage engine, and the members create the fragments of their
+	// partitions when the DMap is created. It prevents the repeated table growth while a huge
+	// DMap is being loaded. The empty fragments are still deleted periodically, the recreated
+	// ones use the same size. It's disabled if it's zero.
+	PreSplitSize int
+
+	// GossipReplication enables the replication of the small values by the gossip protocol.
+	// The partition owner piggybacks every write of a value up to MaxGossipValueSize bytes
+	// on the gossip messages, and every member keeps the values in a local replica that
+	// serves the reads without a network round trip. It's useful for the tiny values that
+	// are read everywhere, like feature flags. The replicas are eventually consistent: a
+	// member may serve a stale value until the gossip reaches it, and the larger values are
+	// read from the partition owner as usual. The writes are still replicated to the backup
+	// owners. It's disabled by default.
+	GossipReplication bool
+
+	// SlowLogThreshold enables the slow log. The Get, Put and Delete operations on the
+	// DMap that take longer are recorded in the slow log of the member that serves them.
+	// It's disabled if it's zero.
+	SlowLogThreshold time.Duration
+
+	// SlowLogSampleRate is the fraction of the slow operations that are recorded in the
+	// slow log. It must be between 0 and 1. A lower rate keeps a high-traffic DMap from
+	// flooding the slow log. It's 1 by default, all the slow operations are recorded.
+	SlowLogSampleRate float64
+
 	// CheckEmptyFragmentsInterval is the interval between two sequential calls of empty
 	// fragment cleaner. This is a global configuration variable. So you cannot set
 	// different values per DMap.
@@ -74,6 +164,121 @@ type DMaps struct {
 	// different values per DMap.
 	TriggerCompactionInterval time.Duration
 
+	// IdleCompactionInterval is the interval between two sequential checks of the
+	// request rate. If the member is idle in an interval, the tables of the fragments
+	// are merged proactively when the live data fits into fewer tables, so the reads
+	// stay fast without waiting for a garbage-triggered compaction. It's disabled if
+	// it's zero. This is a global configuration variable. So you cannot set different
+	// values per DMap.
+	IdleCompactionInterval time.Duration
+
+	// IdleCompactionThreshold is the maximum number of Get, Put and Delete operations
+	// in an IdleCompactionInterval for the member to be considered idle. It's zero by
+	// default, the member is idle if there is no operation at all.
+	IdleCompactionThreshold int64
+
+	// DeferCompactions defers the compactions which are needed but not urgent, the ones
+	// triggered by the maxTables limit of the storage engine, to the low-traffic windows:
+	// they run when the member is idle in an IdleCompactionInterval. The tables with too
+	// much garbage are still compacted immediately. It has no effect if
+	// IdleCompactionInterval is zero. This is a global configuration variable. So you
+	// cannot set different values per DMap.
+	DeferCompactions bool
+
+	// ShutdownSnapshotDir is the directory to write a snapshot of the DMap fragments
+	// during graceful shutdown. The snapshot is restored and removed on the next start,
+	// so the node warms up quickly. It's disabled if it's empty. The directory should
+	// be unique per node.
+	//
+	// The snapshot is merged into the cluster by the timestamps of the entries and
+	// deletions leave nothing behind to compare with. So the keys that are deleted by
+	// the other members while this node is down come back when it restarts and joins
+	// the cluster again. Don't enable it if the deletions must be permanent, or remove
+	// the snapshot file before restarting a node that was down for a long time.
+	ShutdownSnapshotDir string
+
+	// ShutdownSnapshotTimeout is the maximum time to write the shutdown snapshot. A snapshot
+	// that cannot be completed in time is discarded. It's 30 seconds by default.
+	ShutdownSnapshotTimeout time.Duration
+
+	// WALDir is the directory of the write-ahead log. A member appends every put, delete
+	// and expire that it applies as the partition owner to the log before applying it.
+	// Unlike the shutdown snapshot, the log provides durability: the state can be
+	// reconstructed from the logs of the members after a crash. The values are written
+	// in plain text, so the log cannot be enabled together with an EncryptionKey on any
+	// DMap. It's disabled if it's empty. The directory should be unique per node.
+	WALDir string
+
+	// WALSync is the sync policy of the write-ahead log: "always" syncs after every
+	// mutation, "interval" syncs every WALSyncInterval and "never" leaves it to the
+	// operating system. It's "interval" by default.
+	WALSync string
+
+	// WALSyncInterval is the interval between two syncs of the write-ahead log with the
+	// "interval" policy. It's 1 second by default.
+	WALSyncInterval time.Duration
+
+	// WALMaxSegmentSize is the maximum size of a write-ahead log segment file in bytes.
+	// A new segment is started when it's reached. It's 64 MB by default.
+	WALMaxSegmentSize int64
+
+	// LockPollInterval is the default interval between two attempts to acquire a lock
+	// that is held by someone else. LockWithOptions can override it per call. It's
+	// 10 milliseconds by default.
+	LockPollInterval time.Duration
+
+	// LockPollMaxInterval enables the exponential backoff of the lock attempts. If it's
+	// greater than the poll interval, the interval is doubled after every failed attempt
+	// until it reaches LockPollMaxInterval. It's disabled by default.
+	LockPollMaxInterval time.Duration
+
+	// LockPollJitter randomizes the interval between two lock attempts by the given
+	// fraction, so the waiters of the same lock don't poll the partition owner at the
+	// same time. It must be between 0 and 1. 0.1 means ±10%. It's disabled by default.
+	LockPollJitter float64
+
+	// ScanTimeBudget is the maximum time that a single scan call can spend on a
+	// fragment. A scan that exceeds the budget is aborted with ErrScanTimeout, so a
+	// broad match over a huge number of keys cannot hold the fragment lock for long.
+	// There is no limit if it's zero.
+	ScanTimeBudget time.Duration
+
+	// ReplicaSyncInterval is the minimum interval between two replica syncs started
+	// by SyncReplica on a member. A sync that is requested earlier is rejected. It's
+	// 1 second by default.
+	ReplicaSyncInterval time.Duration
+
+	// MaxDMaps is the maximum number of DMaps in the cluster. Creating a new DMap beyond
+	// the limit fails with ErrTooManyDMaps, the existing DMaps remain usable. The first
+	// access to a DMap on a member queries the other members to count the DMaps in the
+	// cluster. Concurrent creations on different members may exceed the limit slightly.
+	// This is a global configuration variable. There is no limit if it's zero.
+	MaxDMaps int
+
+	// MaxMetricLabels is the maximum number of DMaps that have their own label in the
+	// per-DMap operation metrics of a member. The DMaps are labeled in the order of their
+	// first operation on the member, the operations of the DMaps beyond the limit are
+	// counted under the "other" label. It caps the cardinality of the metrics. This is a
+	// global configuration variable. It's 100 by default.
+	MaxMetricLabels int
+
+	// AccessCounterHalfLife is the time that an access counter takes to decay to the half
+	// of its value. This is a global configuration variable. So you cannot set different
+	// values per DMap. It's 1 minute by default.
+	AccessCounterHalfLife time.Duration
+
+	// MaxGossipValueSize is the maximum size of a value that is replicated by the gossip
+	// protocol on the DMaps with GossipReplication. A gossip message carries the key and
+	// the metadata as well, so the value has to fit into a single gossip packet with them,
+	// see UDPBufferSize of the memberlist configuration. This is a global configuration
+	// variable. It's 256 bytes by default.
+	MaxGossipValueSize int
+
+	// SlowLogMaxLen is the maximum number of the entries in the slow log of a member. The
+	// oldest entry is dropped when it's full. This is a global configuration variable.
+	// It's 128 by default.
+	SlowLogMaxLen int
+
 	// Custom is useful to set custom cache config per DMap instance.
 	Custom map[string]DMap
 }
@@ -104,6 +309,10 @@ func (dm *DMaps) Sanitize() error {
 		dm.MaxKeys = 0
 	}
 
+	if dm.PreSplitSize < 0 {
+		dm.PreSplitSize = 0
+	}
+
 	if dm.NumEvictionWorkers <= 0 {
 		dm.NumEvictionWorkers = int64(runtime.NumCPU())
 	}
@@ -116,6 +325,70 @@ func (dm *DMaps) Sanitize() error {
 		dm.TriggerCompactionInterval = DefaultTriggerCompactionInterval
 	}
 
+	if dm.ShutdownSnapshotTimeout <= 0 {
+		dm.ShutdownSnapshotTimeout = DefaultShutdownSnapshotTimeout
+	}
+
+	if dm.LockPollInterval <= 0 {
+		dm.LockPollInterval = DefaultLockPollInterval
+	}
+
+	if dm.WALSync == "" {
+		dm.WALSync = DefaultWALSync
+	}
+
+	if dm.WALSyncInterval <= 0 {
+		dm.WALSyncInterval = DefaultWALSyncInterval
+	}
+
+	if dm.WALMaxSegmentSize <= 0 {
+		dm.WALMaxSegmentSize = DefaultWALMaxSegmentSize
+	}
+
+	if dm.ScanTimeBudget < 0 {
+		dm.ScanTimeBudget = 0
+	}
+
+	if dm.ReplicaSyncInterval <= 0 {
+		dm.ReplicaSyncInterval = DefaultReplicaSyncInterval
+	}
+
+	if dm.IdleCompactionInterval < 0 {
+		dm.IdleCompactionInterval = 0
+	}
+
+	if dm.IdleCompactionThreshold < 0 {
+		dm.IdleCompactionThreshold = 0
+	}
+
+	if dm.MaxDMaps < 0 {
+		dm.MaxDMaps = 0
+	}
+
+	if dm.MaxMetricLabels <= 0 {
+		dm.MaxMetricLabels = DefaultMaxMetricLabels
+	}
+
+	if dm.AccessCounterHalfLife <= 0 {
+		dm.AccessCounterHalfLife = DefaultAccessCounterHalfLife
+	}
+
+	if dm.MaxGossipValueSize <= 0 {
+		dm.MaxGossipValueSize = DefaultMaxGossipValueSize
+	}
+
+	if dm.SlowLogThreshold < 0 {
+		dm.SlowLogThreshold = 0
+	}
+
+	if dm.SlowLogSampleRate == 0 {
+		dm.SlowLogSampleRate = DefaultSlowLogSampleRate
+	}
+
+	if dm.SlowLogMaxLen <= 0 {
+		dm.SlowLogMaxLen = DefaultSlowLogMaxLen
+	}
+
 	for _, d := range dm.Custom {
 		if err := d.Sanitize(); err != nil {
 			return err
@@ -133,6 +406,66 @@ func (dm *DMaps) Validate() error {
 	if err := dm.Engine.Validate(); err != nil {
 		return fmt.Errorf("failed to validate storage engine configuration: %w", err)
 	}
+
+	for _, pattern := range dm.NoEvictKeyPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid no-evict key pattern: %s: %w", pattern, err)
+		}
+	}
+
+	if dm.LockPollJitter < 0 || dm.LockPollJitter > 1 {
+		return fmt.Errorf("invalid LockPollJitter: %v, it must be between 0 and 1", dm.LockPollJitter)
+	}
+
+	if err := validateSlowLogSampleRate(dm.SlowLogSampleRate); err != nil {
+		return err
+	}
+
+	switch dm.WALSync {
+	case "always", "interval", "never":
+	default:
+		return fmt.Errorf("invalid WALSync: %s", dm.WALSync)
+	}
+
+	for name, d := range dm.Custom {
+		for _, pattern := range d.NoEvictKeyPatterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid no-evict key pattern for DMap: %s: %s: %w", name, pattern, err)
+			}
+		}
+		if err := validateEncryptionKey(d.EncryptionKey); err != nil {
+			return fmt.Errorf("invalid configuration for DMap: %s: %w", name, err)
+		}
+		if err := validateSlowLogSampleRate(d.SlowLogSampleRate); err != nil {
+			return fmt.Errorf("invalid configuration for DMap: %s: %w", name, err)
+		}
+		if dm.WALDir != "" && len(d.EncryptionKey) != 0 {
+			// The write-ahead log stores the values in plain text.
+			return fmt.Errorf("invalid configuration for DMap: %s: EncryptionKey cannot be used with WALDir", name)
+		}
+	}
+
+	if dm.WALDir != "" && len(dm.EncryptionKey) != 0 {
+		return fmt.Errorf("EncryptionKey cannot be used with WALDir")
+	}
+	return validateEncryptionKey(dm.EncryptionKey)
+}
+
+// validateEncryptionKey checks the length of an AES key. An empty key disables the encryption.
+func validateEncryptionKey(key []byte) error {
+	switch len(key) {
+	case 0, 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("invalid encryption key size: %d, it must be 16, 24 or 32 bytes", len(key))
+	}
+}
+
+// validateSlowLogSampleRate checks the sample rate of the slow log. Zero means the default rate.
+func validateSlowLogSampleRate(rate float64) error {
+	if rate < 0 || rate > 1 {
+		return fmt.Errorf("invalid SlowLogSampleRate: %v, it must be between 0 and 1", rate)
+	}
 	return nil
 }
 
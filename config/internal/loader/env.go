@@ -0,0 +1,169 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	yaml3 "gopkg.in/yaml.v3"
+)
+
+// ErrEnvNotSet is returned when the configuration file refers to an environment
+// variable that is not set and has no default value.
+var ErrEnvNotSet = errors.New("environment variable is not set")
+
+func isEnvName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, c := range name {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// lookupEnv resolves a reference like VAR or VAR:-default.
+func lookupEnv(ref string) (string, error) {
+	name, def, hasDefault := ref, "", false
+	if i := strings.Index(ref, ":-"); i >= 0 {
+		name, def, hasDefault = ref[:i], ref[i+2:], true
+	}
+	if !isEnvName(name) {
+		return "", fmt.Errorf("invalid environment variable reference: '${%s}'", ref)
+	}
+	if hasDefault {
+		// Like the shell, the default value is used for an empty variable too.
+		if value := os.Getenv(name); value != "" {
+			return value, nil
+		}
+		return def, nil
+	}
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrEnvNotSet, name)
+	}
+	return value, nil
+}
+
+// expandEnv replaces the ${VAR} and ${VAR:-default} references with the values of
+// the environment variables. $$ is replaced with a literal $, and a $ that is not
+// followed by { or $ is kept as it is, like in "^[a-z]+$".
+func expandEnv(value string) (string, error) {
+	if !strings.ContainsRune(value, '$') {
+		return value, nil
+	}
+
+	var buf strings.Builder
+	buf.Grow(len(value))
+	for len(value) > 0 {
+		i := strings.IndexByte(value, '$')
+		if i < 0 || i == len(value)-1 {
+			buf.WriteString(value)
+			break
+		}
+		buf.WriteString(value[:i])
+		value = value[i:]
+
+		switch value[1] {
+		case '$':
+			buf.WriteByte('$')
+			value = value[2:]
+		case '{':
+			end := strings.IndexByte(value, '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated environment variable reference: '%s'",
+					strings.SplitN(value, "\n", 2)[0])
+			}
+			env, err := lookupEnv(value[2:end])
+			if err != nil {
+				return "", err
+			}
+			buf.WriteString(env)
+			value = value[end+1:]
+		default:
+			buf.WriteByte('$')
+			value = value[1:]
+		}
+	}
+	return buf.String(), nil
+}
+
+// expandYAMLNode expands the references in the string values under the node. The keys,
+// the comments and the values of the other types are kept as they are.
+func expandYAMLNode(node *yaml3.Node) error {
+	switch node.Kind {
+	case yaml3.ScalarNode:
+		if node.ShortTag() != "!!str" {
+			return nil
+		}
+		value, err := expandEnv(node.Value)
+		if err != nil {
+			return err
+		}
+		if value == node.Value {
+			return nil
+		}
+		node.Value = value
+		if node.Style == 0 {
+			// A plain value takes the type of the expanded value, like bindPort: ${PORT}.
+			node.Tag = ""
+		}
+	case yaml3.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := expandYAMLNode(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml3.DocumentNode, yaml3.SequenceNode:
+		for _, item := range node.Content {
+			if err := expandYAMLNode(item); err != nil {
+				return err
+			}
+		}
+	}
+	// The aliases share the node of their anchor, it's expanded once.
+	return nil
+}
+
+// expandYAML expands the references in the string values of a YAML document. The
+// document is parsed first, so the references in the comments are ignored and the
+// expanded values are quoted as required, even if they contain a ':' or a newline.
+func expandYAML(data []byte) ([]byte, error) {
+	if !bytes.ContainsRune(data, '$') {
+		return data, nil
+	}
+
+	var doc yaml3.Node
+	if err := yaml3.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Kind == 0 {
+		// Empty document
+		return data, nil
+	}
+	if err := expandYAMLNode(&doc); err != nil {
+		return nil, err
+	}
+	return yaml3.Marshal(&doc)
+}
@@ -0,0 +1,154 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// yamlValue converts a value decoded by encoding/json to the value yaml.v2 decodes
+// from the same document: the objects are map[interface{}]interface{} and the
+// integers are int, or uint64 if they don't fit.
+func yamlValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(v))
+		for key, item := range v {
+			m[key] = yamlValue(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = yamlValue(item)
+		}
+		return v
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 0); err == nil {
+			return int(i)
+		}
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u
+		}
+		f, _ := strconv.ParseFloat(v.String(), 64)
+		return f
+	}
+	return value
+}
+
+// yamlMap converts the values of a top-level map, yaml.v2 decodes it to
+// map[string]interface{} as it's typed.
+func yamlMap(m map[string]interface{}) {
+	for key, value := range m {
+		m[key] = yamlValue(value)
+	}
+}
+
+// expandJSONValue expands the references in the string values of a value decoded by
+// encoding/json. The keys and the values of the other types are kept as they are.
+func expandJSONValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return expandEnv(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			expanded, err := expandJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = expanded
+		}
+	case []interface{}:
+		for i, item := range v {
+			expanded, err := expandJSONValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = expanded
+		}
+	}
+	return value, nil
+}
+
+// expandJSON expands the references in the string values of a JSON document. The
+// document is decoded first, so the expanded values are escaped as required.
+func expandJSON(data []byte) ([]byte, error) {
+	if !bytes.ContainsRune(data, '$') {
+		return data, nil
+	}
+
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	doc, err := expandJSONValue(doc)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// NewFromJSON tries to read Olric configuration from a JSON file. The keys are the
+// same as in the YAML format, and the result is the same as the result of New for
+// the same configuration. The ${VAR} and ${VAR:-default} references in the string
+// values are replaced with the environment variables, and $$ is a literal $. The
+// numbers and the booleans cannot refer to the environment variables in JSON.
+func NewFromJSON(data []byte) (*Loader, error) {
+	data, err := expandJSON(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var lc Loader
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&lc); err != nil {
+		return nil, err
+	}
+
+	yamlMap(lc.ServiceDiscovery)
+	if lc.DMaps.Engine != nil {
+		yamlMap(lc.DMaps.Engine.Config)
+	}
+	for _, dc := range lc.DMaps.Custom {
+		if dc.Engine != nil {
+			yamlMap(dc.Engine.Config)
+		}
+	}
+
+	if err := lc.Validate(); err != nil {
+		return nil, err
+	}
+	return &lc, nil
+}
+
+// LoadFile reads Olric configuration from a file. The files with the .json extension
+// are read with NewFromJSON, the others with New.
+func LoadFile(path string) (*Loader, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return NewFromJSON(data)
+	}
+	return New(data)
+}
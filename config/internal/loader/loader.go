@@ -17,126 +17,202 @@ package loader
 import "gopkg.in/yaml.v2"
 
 type olricd struct {
-	Name                       string  `yaml:"name"`
-	BindAddr                   string  `yaml:"bindAddr"`
-	BindPort                   int     `yaml:"bindPort"`
-	Interface                  string  `yaml:"interface"`
-	ReplicationMode            int     `yaml:"replicationMode"`
-	PartitionCount             uint64  `yaml:"partitionCount"`
-	LoadFactor                 float64 `yaml:"loadFactor"`
-	KeepAlivePeriod            string  `yaml:"keepAlivePeriod"`
-	IdleClose                  string  `yaml:"idleClose"`
-	BootstrapTimeout           string  `yaml:"bootstrapTimeout"`
-	ReplicaCount               int     `yaml:"replicaCount"`
-	WriteQuorum                int     `yaml:"writeQuorum"`
-	ReadQuorum                 int     `yaml:"readQuorum"`
-	ReadRepair                 bool    `yaml:"readRepair"`
-	MemberCountQuorum          int32   `yaml:"memberCountQuorum"`
-	RoutingTablePushInterval   string  `yaml:"routingTablePushInterval"`
-	TriggerBalancerInterval    string  `yaml:"triggerBalancerInterval"`
-	LeaveTimeout               string  `yaml:"leaveTimeout"`
-	EnableClusterEventsChannel bool    `yaml:"enableClusterEventsChannel"`
+	Name                       string  `yaml:"name" json:"name"`
+	BindAddr                   string  `yaml:"bindAddr" json:"bindAddr"`
+	BindPort                   int     `yaml:"bindPort" json:"bindPort"`
+	Interface                  string  `yaml:"interface" json:"interface"`
+	ReplicationMode            int     `yaml:"replicationMode" json:"replicationMode"`
+	PartitionCount             uint64  `yaml:"partitionCount" json:"partitionCount"`
+	LoadFactor                 float64 `yaml:"loadFactor" json:"loadFactor"`
+	KeepAlivePeriod            string  `yaml:"keepAlivePeriod" json:"keepAlivePeriod"`
+	IdleClose                  string  `yaml:"idleClose" json:"idleClose"`
+	BootstrapTimeout           string  `yaml:"bootstrapTimeout" json:"bootstrapTimeout"`
+	ReplicaCount               int     `yaml:"replicaCount" json:"replicaCount"`
+	WriteQuorum                int     `yaml:"writeQuorum" json:"writeQuorum"`
+	ReadQuorum                 int     `yaml:"readQuorum" json:"readQuorum"`
+	ReadRepair                 bool    `yaml:"readRepair" json:"readRepair"`
+	ReadRepairScope            int     `yaml:"readRepairScope" json:"readRepairScope"`
+	MemberCountQuorum          int32   `yaml:"memberCountQuorum" json:"memberCountQuorum"`
+	RoutingTablePushInterval   string  `yaml:"routingTablePushInterval" json:"routingTablePushInterval"`
+	TriggerBalancerInterval    string  `yaml:"triggerBalancerInterval" json:"triggerBalancerInterval"`
+	MaxConcurrentMoves         int     `yaml:"maxConcurrentMoves" json:"maxConcurrentMoves"`
+	LeaveTimeout               string  `yaml:"leaveTimeout" json:"leaveTimeout"`
+	EnableClusterEventsChannel bool    `yaml:"enableClusterEventsChannel" json:"enableClusterEventsChannel"`
+	EnableWriteFencing         bool    `yaml:"enableWriteFencing" json:"enableWriteFencing"`
+	DrainConnections           bool    `yaml:"drainConnections" json:"drainConnections"`
+	HashSeed                   uint64  `yaml:"hashSeed" json:"hashSeed"`
+	HashTags                   bool    `yaml:"hashTags" json:"hashTags"`
 }
 
 type client struct {
-	DialTimeout     string `yaml:"dialTimeout"`
-	ReadTimeout     string `yaml:"readTimeout"`
-	WriteTimeout    string `yaml:"writeTimeout"`
-	MaxRetries      int    `yaml:"maxRetries"`
-	MinRetryBackoff string `yaml:"minRetryBackoff"`
-	MaxRetryBackoff string `yaml:"maxRetryBackoff"`
-	PoolFIFO        bool   `yaml:"poolFIFO"`
-	PoolSize        int    `yaml:"poolSize"`
-	MinIdleConns    int    `yaml:"minIdleConns"`
-	MaxConnAge      string `yaml:"maxConnAge"`
-	PoolTimeout     string `yaml:"poolTimeout"`
-	IdleTimeout     string `yaml:"idleTimeout"`
+	DialTimeout     string `yaml:"dialTimeout" json:"dialTimeout"`
+	ReadTimeout     string `yaml:"readTimeout" json:"readTimeout"`
+	WriteTimeout    string `yaml:"writeTimeout" json:"writeTimeout"`
+	MaxRetries      int    `yaml:"maxRetries" json:"maxRetries"`
+	MinRetryBackoff string `yaml:"minRetryBackoff" json:"minRetryBackoff"`
+	MaxRetryBackoff string `yaml:"maxRetryBackoff" json:"maxRetryBackoff"`
+	PoolFIFO        bool   `yaml:"poolFIFO" json:"poolFIFO"`
+	PoolSize        int    `yaml:"poolSize" json:"poolSize"`
+	MinIdleConns    int    `yaml:"minIdleConns" json:"minIdleConns"`
+	MaxTotalConns   int    `yaml:"maxTotalConns" json:"maxTotalConns"`
+	MaxConnAge      string `yaml:"maxConnAge" json:"maxConnAge"`
+	PoolTimeout     string `yaml:"poolTimeout" json:"poolTimeout"`
+	IdleTimeout     string `yaml:"idleTimeout" json:"idleTimeout"`
 }
 
 // logging contains configuration variables of logging section of config file.
 type logging struct {
-	Verbosity int32  `yaml:"verbosity"`
-	Level     string `yaml:"level"`
-	Output    string `yaml:"output"`
+	Verbosity                 int32  `yaml:"verbosity" json:"verbosity"`
+	Level                     string `yaml:"level" json:"level"`
+	Output                    string `yaml:"output" json:"output"`
+	LogErrorResponses         bool   `yaml:"logErrorResponses" json:"logErrorResponses"`
+	ErrorResponseLogVerbosity int32  `yaml:"errorResponseLogVerbosity" json:"errorResponseLogVerbosity"`
+	ErrorResponseLogRate      int    `yaml:"errorResponseLogRate" json:"errorResponseLogRate"`
 }
 
 type memberlist struct {
-	Environment             string   `yaml:"environment"` // required
-	BindAddr                string   `yaml:"bindAddr"`    // required
-	BindPort                int      `yaml:"bindPort"`    // required
-	Interface               string   `yaml:"interface"`
-	EnableCompression       *bool    `yaml:"enableCompression"`
-	JoinRetryInterval       string   `yaml:"joinRetryInterval"` // required
-	MaxJoinAttempts         int      `yaml:"maxJoinAttempts"`   // required
-	Peers                   []string `yaml:"peers"`
-	IndirectChecks          *int     `yaml:"indirectChecks"`
-	RetransmitMult          *int     `yaml:"retransmitMult"`
-	SuspicionMult           *int     `yaml:"suspicionMult"`
-	TCPTimeout              *string  `yaml:"tcpTimeout"`
-	PushPullInterval        *string  `yaml:"pushPullInterval"`
-	ProbeTimeout            *string  `yaml:"probeTimeout"`
-	ProbeInterval           *string  `yaml:"probeInterval"`
-	GossipInterval          *string  `yaml:"gossipInterval"`
-	GossipToTheDeadTime     *string  `yaml:"gossipToTheDeadTime"`
-	AdvertiseAddr           *string  `yaml:"advertiseAddr"`
-	AdvertisePort           *int     `yaml:"advertisePort"`
-	SuspicionMaxTimeoutMult *int     `yaml:"suspicionMaxTimeoutMult"`
-	DisableTCPPings         *bool    `yaml:"disableTCPPings"`
-	AwarenessMaxMultiplier  *int     `yaml:"awarenessMaxMultiplier"`
-	GossipNodes             *int     `yaml:"gossipNodes"`
-	GossipVerifyIncoming    *bool    `yaml:"gossipVerifyIncoming"`
-	GossipVerifyOutgoing    *bool    `yaml:"gossipVerifyOutgoing"`
-	DNSConfigPath           *string  `yaml:"dnsConfigPath"`
-	HandoffQueueDepth       *int     `yaml:"handoffQueueDepth"`
-	UDPBufferSize           *int     `yaml:"udpBufferSize"`
+	Environment             string   `yaml:"environment" json:"environment"` // required
+	BindAddr                string   `yaml:"bindAddr" json:"bindAddr"`       // required
+	BindPort                int      `yaml:"bindPort" json:"bindPort"`       // required
+	Interface               string   `yaml:"interface" json:"interface"`
+	EnableCompression       *bool    `yaml:"enableCompression" json:"enableCompression"`
+	JoinRetryInterval       string   `yaml:"joinRetryInterval" json:"joinRetryInterval"` // required
+	MaxJoinAttempts         int      `yaml:"maxJoinAttempts" json:"maxJoinAttempts"`     // required
+	MaxClusterSize          int      `yaml:"maxClusterSize" json:"maxClusterSize"`
+	Peers                   []string `yaml:"peers" json:"peers"`
+	IndirectChecks          *int     `yaml:"indirectChecks" json:"indirectChecks"`
+	RetransmitMult          *int     `yaml:"retransmitMult" json:"retransmitMult"`
+	SuspicionMult           *int     `yaml:"suspicionMult" json:"suspicionMult"`
+	TCPTimeout              *string  `yaml:"tcpTimeout" json:"tcpTimeout"`
+	PushPullInterval        *string  `yaml:"pushPullInterval" json:"pushPullInterval"`
+	ProbeTimeout            *string  `yaml:"probeTimeout" json:"probeTimeout"`
+	ProbeInterval           *string  `yaml:"probeInterval" json:"probeInterval"`
+	GossipInterval          *string  `yaml:"gossipInterval" json:"gossipInterval"`
+	GossipToTheDeadTime     *string  `yaml:"gossipToTheDeadTime" json:"gossipToTheDeadTime"`
+	AdvertiseAddr           *string  `yaml:"advertiseAddr" json:"advertiseAddr"`
+	AdvertisePort           *int     `yaml:"advertisePort" json:"advertisePort"`
+	SuspicionMaxTimeoutMult *int     `yaml:"suspicionMaxTimeoutMult" json:"suspicionMaxTimeoutMult"`
+	DisableTCPPings         *bool    `yaml:"disableTCPPings" json:"disableTCPPings"`
+	AwarenessMaxMultiplier  *int     `yaml:"awarenessMaxMultiplier" json:"awarenessMaxMultiplier"`
+	GossipNodes             *int     `yaml:"gossipNodes" json:"gossipNodes"`
+	GossipVerifyIncoming    *bool    `yaml:"gossipVerifyIncoming" json:"gossipVerifyIncoming"`
+	GossipVerifyOutgoing    *bool    `yaml:"gossipVerifyOutgoing" json:"gossipVerifyOutgoing"`
+	DNSConfigPath           *string  `yaml:"dnsConfigPath" json:"dnsConfigPath"`
+	HandoffQueueDepth       *int     `yaml:"handoffQueueDepth" json:"handoffQueueDepth"`
+	UDPBufferSize           *int     `yaml:"udpBufferSize" json:"udpBufferSize"`
 }
 
 type engine struct {
-	Name   string                 `yaml:"name"`
-	Config map[string]interface{} `yaml:"config"`
+	Name   string                 `yaml:"name" json:"name"`
+	Config map[string]interface{} `yaml:"config" json:"config"`
 }
 
 type dmap struct {
-	Engine          *engine `yaml:"engine"`
-	MaxIdleDuration string  `yaml:"maxIdleDuration"`
-	TTLDuration     string  `yaml:"ttlDuration"`
-	MaxKeys         int     `yaml:"maxKeys"`
-	MaxInuse        int     `yaml:"maxInuse"`
-	LRUSamples      int     `yaml:"lruSamples"`
-	EvictionPolicy  string  `yaml:"evictionPolicy"`
+	Engine              *engine  `yaml:"engine" json:"engine"`
+	MaxIdleDuration     string   `yaml:"maxIdleDuration" json:"maxIdleDuration"`
+	TTLDuration         string   `yaml:"ttlDuration" json:"ttlDuration"`
+	MaxAge              string   `yaml:"maxAge" json:"maxAge"`
+	MaxKeys             int      `yaml:"maxKeys" json:"maxKeys"`
+	MaxInuse            int      `yaml:"maxInuse" json:"maxInuse"`
+	LRUSamples          int      `yaml:"lruSamples" json:"lruSamples"`
+	EvictionPolicy      string   `yaml:"evictionPolicy" json:"evictionPolicy"`
+	NoEvictKeyPatterns  []string `yaml:"noEvictKeyPatterns" json:"noEvictKeyPatterns"`
+	EvictionGracePeriod string   `yaml:"evictionGracePeriod" json:"evictionGracePeriod"`
+	DeadLetterDMap      string   `yaml:"deadLetterDMap" json:"deadLetterDMap"`
+	EncryptionKey       string   `yaml:"encryptionKey" json:"encryptionKey"`
+	ReadTimeout         string   `yaml:"readTimeout" json:"readTimeout"`
+	WriteTimeout        string   `yaml:"writeTimeout" json:"writeTimeout"`
+	KeySchema           string   `yaml:"keySchema" json:"keySchema"`
+	AccessCounter       bool     `yaml:"accessCounter" json:"accessCounter"`
+	PreSplitSize        int      `yaml:"preSplitSize" json:"preSplitSize"`
+	GossipReplication   bool     `yaml:"gossipReplication" json:"gossipReplication"`
+	SlowLogThreshold    string   `yaml:"slowLogThreshold" json:"slowLogThreshold"`
+	SlowLogSampleRate   float64  `yaml:"slowLogSampleRate" json:"slowLogSampleRate"`
 }
 
 type dmaps struct {
-	Engine                      *engine         `yaml:"engine"`
-	NumEvictionWorkers          int64           `yaml:"numEvictionWorkers"`
-	MaxIdleDuration             string          `yaml:"maxIdleDuration"`
-	TTLDuration                 string          `yaml:"ttlDuration"`
-	MaxKeys                     int             `yaml:"maxKeys"`
-	MaxInuse                    int             `yaml:"maxInuse"`
-	LRUSamples                  int             `yaml:"lruSamples"`
-	EvictionPolicy              string          `yaml:"evictionPolicy"`
-	CheckEmptyFragmentsInterval string          `yaml:"checkEmptyFragmentsInterval"`
-	TriggerCompactionInterval   string          `yaml:"triggerCompactionInterval"`
-	Custom                      map[string]dmap `yaml:"custom"`
+	Engine                      *engine         `yaml:"engine" json:"engine"`
+	NumEvictionWorkers          int64           `yaml:"numEvictionWorkers" json:"numEvictionWorkers"`
+	MaxIdleDuration             string          `yaml:"maxIdleDuration" json:"maxIdleDuration"`
+	TTLDuration                 string          `yaml:"ttlDuration" json:"ttlDuration"`
+	MaxAge                      string          `yaml:"maxAge" json:"maxAge"`
+	MaxKeys                     int             `yaml:"maxKeys" json:"maxKeys"`
+	MaxInuse                    int             `yaml:"maxInuse" json:"maxInuse"`
+	LRUSamples                  int             `yaml:"lruSamples" json:"lruSamples"`
+	EvictionPolicy              string          `yaml:"evictionPolicy" json:"evictionPolicy"`
+	NoEvictKeyPatterns          []string        `yaml:"noEvictKeyPatterns" json:"noEvictKeyPatterns"`
+	EvictionGracePeriod         string          `yaml:"evictionGracePeriod" json:"evictionGracePeriod"`
+	DeadLetterDMap              string          `yaml:"deadLetterDMap" json:"deadLetterDMap"`
+	EncryptionKey               string          `yaml:"encryptionKey" json:"encryptionKey"`
+	ReadTimeout                 string          `yaml:"readTimeout" json:"readTimeout"`
+	WriteTimeout                string          `yaml:"writeTimeout" json:"writeTimeout"`
+	KeySchema                   string          `yaml:"keySchema" json:"keySchema"`
+	AccessCounter               bool            `yaml:"accessCounter" json:"accessCounter"`
+	AccessCounterHalfLife       string          `yaml:"accessCounterHalfLife" json:"accessCounterHalfLife"`
+	PreSplitSize                int             `yaml:"preSplitSize" json:"preSplitSize"`
+	GossipReplication           bool            `yaml:"gossipReplication" json:"gossipReplication"`
+	MaxGossipValueSize          int             `yaml:"maxGossipValueSize" json:"maxGossipValueSize"`
+	SlowLogThreshold            string          `yaml:"slowLogThreshold" json:"slowLogThreshold"`
+	SlowLogSampleRate           float64         `yaml:"slowLogSampleRate" json:"slowLogSampleRate"`
+	SlowLogMaxLen               int             `yaml:"slowLogMaxLen" json:"slowLogMaxLen"`
+	CheckEmptyFragmentsInterval string          `yaml:"checkEmptyFragmentsInterval" json:"checkEmptyFragmentsInterval"`
+	TriggerCompactionInterval   string          `yaml:"triggerCompactionInterval" json:"triggerCompactionInterval"`
+	IdleCompactionInterval      string          `yaml:"idleCompactionInterval" json:"idleCompactionInterval"`
+	IdleCompactionThreshold     int64           `yaml:"idleCompactionThreshold" json:"idleCompactionThreshold"`
+	DeferCompactions            bool            `yaml:"deferCompactions" json:"deferCompactions"`
+	ShutdownSnapshotDir         string          `yaml:"shutdownSnapshotDir" json:"shutdownSnapshotDir"`
+	ShutdownSnapshotTimeout     string          `yaml:"shutdownSnapshotTimeout" json:"shutdownSnapshotTimeout"`
+	WALDir                      string          `yaml:"walDir" json:"walDir"`
+	WALSync                     string          `yaml:"walSync" json:"walSync"`
+	WALSyncInterval             string          `yaml:"walSyncInterval" json:"walSyncInterval"`
+	WALMaxSegmentSize           int64           `yaml:"walMaxSegmentSize" json:"walMaxSegmentSize"`
+	LockPollInterval            string          `yaml:"lockPollInterval" json:"lockPollInterval"`
+	LockPollMaxInterval         string          `yaml:"lockPollMaxInterval" json:"lockPollMaxInterval"`
+	LockPollJitter              float64         `yaml:"lockPollJitter" json:"lockPollJitter"`
+	ScanTimeBudget              string          `yaml:"scanTimeBudget" json:"scanTimeBudget"`
+	ReplicaSyncInterval         string          `yaml:"replicaSyncInterval" json:"replicaSyncInterval"`
+	MaxDMaps                    int             `yaml:"maxDMaps" json:"maxDMaps"`
+	MaxMetricLabels             int             `yaml:"maxMetricLabels" json:"maxMetricLabels"`
+	Custom                      map[string]dmap `yaml:"custom" json:"custom"`
+}
+
+// tlsConfig contains configuration variables of tls section of config file.
+type tlsConfig struct {
+	CertFile          string `yaml:"certFile" json:"certFile"`
+	KeyFile           string `yaml:"keyFile" json:"keyFile"`
+	ClientCAs         string `yaml:"clientCAs" json:"clientCAs"`
+	RequireClientCert bool   `yaml:"requireClientCert" json:"requireClientCert"`
 }
 
 type serviceDiscovery map[string]interface{}
 
 // Loader is the main configuration struct
 type Loader struct {
-	Memberlist       memberlist       `yaml:"memberlist"`
-	Logging          logging          `yaml:"logging"`
-	Olricd           olricd           `yaml:"olricd"`
-	Client           client           `yaml:"client"`
-	DMaps            dmaps            `yaml:"dmaps"`
-	ServiceDiscovery serviceDiscovery `yaml:"serviceDiscovery"`
+	Memberlist       memberlist       `yaml:"memberlist" json:"memberlist"`
+	Logging          logging          `yaml:"logging" json:"logging"`
+	Olricd           olricd           `yaml:"olricd" json:"olricd"`
+	Client           client           `yaml:"client" json:"client"`
+	DMaps            dmaps            `yaml:"dmaps" json:"dmaps"`
+	TLS              tlsConfig        `yaml:"tls" json:"tls"`
+	ServiceDiscovery serviceDiscovery `yaml:"serviceDiscovery" json:"serviceDiscovery"`
 }
 
-// New tries to read Olric configuration from a YAML file.
+// New tries to read Olric configuration from a YAML file. The ${VAR} and
+// ${VAR:-default} references in the string values are replaced with the environment
+// variables, and $$ is a literal $. A plain value, like bindPort: ${PORT}, takes the
+// type of the expanded value. The configuration is validated, see Validate.
 func New(data []byte) (*Loader, error) {
+	data, err := expandYAML(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var lc Loader
 	if err := yaml.Unmarshal(data, &lc); err != nil {
 		return nil, err
 	}
+	if err := lc.Validate(); err != nil {
+		return nil, err
+	}
 	return &lc, nil
 }
@@ -0,0 +1,425 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const validConfig = `olricd:
+  bindAddr: "0.0.0.0"
+  bindPort: 3320
+  keepAlivePeriod: "300s"
+  bootstrapTimeout: "5s"
+  replicaCount: 2
+  writeQuorum: 1
+  readQuorum: 2
+
+memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+  joinRetryInterval: "1s"
+  maxJoinAttempts: 10
+
+dmaps:
+  ttlDuration: "100s"
+  custom:
+    foobar:
+      maxIdleDuration: "60s"
+`
+
+func TestLoader_Validate(t *testing.T) {
+	lc, err := New([]byte(validConfig))
+	require.NoError(t, err)
+	require.Equal(t, "local", lc.Memberlist.Environment)
+
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "Missing memberlist.environment",
+			config: `memberlist:
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+`,
+			err: "memberlist.environment: required",
+		},
+		{
+			name: "Unknown memberlist.environment",
+			config: `memberlist:
+  environment: "foobar"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+`,
+			err: "memberlist.environment: unknown environment: 'foobar'",
+		},
+		{
+			name: "Missing memberlist.bindAddr",
+			config: `memberlist:
+  environment: "local"
+  bindPort: 3322
+`,
+			err: "memberlist.bindAddr: required",
+		},
+		{
+			name: "Missing memberlist.bindPort",
+			config: `memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+`,
+			err: "memberlist.bindPort: required",
+		},
+		{
+			name: "Invalid olricd.keepAlivePeriod",
+			config: `olricd:
+  keepAlivePeriod: "300"
+
+memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+`,
+			err: "olricd.keepAlivePeriod: invalid duration: '300'",
+		},
+		{
+			name: "Invalid memberlist.tcpTimeout",
+			config: `memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+  tcpTimeout: "1 second"
+`,
+			err: "memberlist.tcpTimeout: invalid duration: '1 second'",
+		},
+		{
+			name: "Invalid custom DMap duration",
+			config: `memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+
+dmaps:
+  custom:
+    foobar:
+      ttlDuration: "1x"
+`,
+			err: "dmaps.custom.foobar.ttlDuration: invalid duration: '1x'",
+		},
+		{
+			name: "olricd.readQuorum greater than olricd.replicaCount",
+			config: `olricd:
+  replicaCount: 2
+  readQuorum: 3
+
+memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+`,
+			err: "olricd.readQuorum: cannot be greater than olricd.replicaCount: 3 > 2",
+		},
+		{
+			name: "olricd.writeQuorum greater than olricd.replicaCount",
+			config: `olricd:
+  replicaCount: 1
+  writeQuorum: 2
+
+memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+`,
+			err: "olricd.writeQuorum: cannot be greater than olricd.replicaCount: 2 > 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New([]byte(test.config))
+			require.ErrorIs(t, err, ErrInvalidConfig)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestLoader_ExpandEnv(t *testing.T) {
+	t.Setenv("OLRIC_TEST_BIND_ADDR", "127.0.0.1")
+	t.Setenv("OLRIC_TEST_EMPTY", "")
+
+	config := `olricd:
+  bindAddr: "${OLRIC_TEST_BIND_ADDR}"
+  bindPort: ${OLRIC_TEST_BIND_PORT:-3320}
+
+memberlist:
+  environment: "${OLRIC_TEST_EMPTY:-local}"
+  bindAddr: "${OLRIC_TEST_BIND_ADDR:-0.0.0.0}"
+  bindPort: 3322
+
+dmaps:
+  keySchema: "^[a-z]+$"
+  encryptionKey: "pa$$word"
+`
+	lc, err := New([]byte(config))
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", lc.Olricd.BindAddr)
+	require.Equal(t, 3320, lc.Olricd.BindPort)
+	require.Equal(t, "local", lc.Memberlist.Environment)
+	require.Equal(t, "127.0.0.1", lc.Memberlist.BindAddr)
+	require.Equal(t, "^[a-z]+$", lc.DMaps.KeySchema)
+	require.Equal(t, "pa$word", lc.DMaps.EncryptionKey)
+
+	t.Run("Comments and special characters", func(t *testing.T) {
+		t.Setenv("OLRIC_TEST_KEY_SCHEMA", "^[a-z]+: [0-9]+$")
+		t.Setenv("OLRIC_TEST_ENCRYPTION_KEY", "first line\nsecond: line")
+
+		lc, err := New([]byte(`olricd:
+  # The default is ${OLRIC_TEST_UNSET}, it's only a comment.
+  bindAddr: "0.0.0.0"
+  bindPort: 3320
+
+memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+
+dmaps:
+  keySchema: ${OLRIC_TEST_KEY_SCHEMA}
+  encryptionKey: "${OLRIC_TEST_ENCRYPTION_KEY}"
+`))
+		require.NoError(t, err)
+		require.Equal(t, "^[a-z]+: [0-9]+$", lc.DMaps.KeySchema)
+		require.Equal(t, "first line\nsecond: line", lc.DMaps.EncryptionKey)
+	})
+
+	t.Run("Unset variable", func(t *testing.T) {
+		_, err := New([]byte(`olricd:
+  bindAddr: "${OLRIC_TEST_UNSET}"
+`))
+		require.ErrorIs(t, err, ErrEnvNotSet)
+		require.ErrorContains(t, err, "OLRIC_TEST_UNSET")
+	})
+
+	t.Run("Invalid reference", func(t *testing.T) {
+		_, err := New([]byte(`olricd:
+  bindAddr: "${OLRIC TEST}"
+`))
+		require.ErrorContains(t, err, "invalid environment variable reference: '${OLRIC TEST}'")
+
+		_, err = New([]byte(`olricd:
+  bindAddr: "${OLRIC_TEST"
+`))
+		require.ErrorContains(t, err, "unterminated environment variable reference")
+	})
+}
+
+func TestLoader_NewFromJSON(t *testing.T) {
+	yamlConfig := `olricd:
+  bindAddr: "0.0.0.0"
+  bindPort: 3320
+  keepAlivePeriod: "300s"
+  replicaCount: 2
+  loadFactor: 1.25
+  hashSeed: 18446744073709551615
+
+memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+  enableCompression: false
+  peers:
+    - "localhost:3325"
+
+dmaps:
+  engine:
+    name: "kvstore"
+    config:
+      tableSize: 524288
+      maxTables: 16
+      ratio: 0.5
+  noEvictKeyPatterns:
+    - "session:*"
+  custom:
+    foobar:
+      ttlDuration: "60s"
+
+serviceDiscovery:
+  provider: "consul"
+  passingOnly: true
+  port: 8500
+  tags:
+    - "olric"
+  payload:
+    name: "olric-cluster"
+    weight: 10
+`
+	jsonConfig := `{
+  "olricd": {
+    "bindAddr": "0.0.0.0",
+    "bindPort": 3320,
+    "keepAlivePeriod": "300s",
+    "replicaCount": 2,
+    "loadFactor": 1.25,
+    "hashSeed": 18446744073709551615
+  },
+  "memberlist": {
+    "environment": "local",
+    "bindAddr": "0.0.0.0",
+    "bindPort": 3322,
+    "enableCompression": false,
+    "peers": ["localhost:3325"]
+  },
+  "dmaps": {
+    "engine": {
+      "name": "kvstore",
+      "config": {"tableSize": 524288, "maxTables": 16, "ratio": 0.5}
+    },
+    "noEvictKeyPatterns": ["session:*"],
+    "custom": {
+      "foobar": {"ttlDuration": "60s"}
+    }
+  },
+  "serviceDiscovery": {
+    "provider": "consul",
+    "passingOnly": true,
+    "port": 8500,
+    "tags": ["olric"],
+    "payload": {"name": "olric-cluster", "weight": 10}
+  }
+}`
+
+	fromYAML, err := New([]byte(yamlConfig))
+	require.NoError(t, err)
+	fromJSON, err := NewFromJSON([]byte(jsonConfig))
+	require.NoError(t, err)
+	require.Equal(t, fromYAML, fromJSON)
+
+	t.Run("LoadFile", func(t *testing.T) {
+		dir := t.TempDir()
+		yamlPath := filepath.Join(dir, "olricd.yaml")
+		require.NoError(t, os.WriteFile(yamlPath, []byte(yamlConfig), 0600))
+		jsonPath := filepath.Join(dir, "olricd.json")
+		require.NoError(t, os.WriteFile(jsonPath, []byte(jsonConfig), 0600))
+
+		lc, err := LoadFile(yamlPath)
+		require.NoError(t, err)
+		require.Equal(t, fromYAML, lc)
+
+		lc, err = LoadFile(jsonPath)
+		require.NoError(t, err)
+		require.Equal(t, fromYAML, lc)
+	})
+
+	t.Run("Environment variables", func(t *testing.T) {
+		t.Setenv("OLRIC_TEST_BIND_ADDR", "127.0.0.1")
+		t.Setenv("OLRIC_TEST_ENCRYPTION_KEY", "first \"line\"\nsecond: line")
+
+		lc, err := NewFromJSON([]byte(`{
+  "memberlist": {"environment": "local", "bindAddr": "${OLRIC_TEST_BIND_ADDR}", "bindPort": 3322},
+  "dmaps": {"keySchema": "^[a-z]+$", "encryptionKey": "${OLRIC_TEST_ENCRYPTION_KEY}:pa$$word"}
+}`))
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1", lc.Memberlist.BindAddr)
+		require.Equal(t, "^[a-z]+$", lc.DMaps.KeySchema)
+		require.Equal(t, "first \"line\"\nsecond: line:pa$word", lc.DMaps.EncryptionKey)
+
+		_, err = NewFromJSON([]byte(`{"memberlist": {"bindAddr": "${OLRIC_TEST_UNSET}"}}`))
+		require.ErrorIs(t, err, ErrEnvNotSet)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := NewFromJSON([]byte(`{"memberlist": {"environment": "local", "bindPort": 3322}}`))
+		require.ErrorIs(t, err, ErrInvalidConfig)
+		require.ErrorContains(t, err, "memberlist.bindAddr: required")
+	})
+}
+
+func TestLoader_Validate_TLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	require.NoError(t, os.WriteFile(certFile, []byte("cert"), 0600))
+	keyFile := filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(keyFile, []byte("key"), 0600))
+
+	newConfig := func(tlsSection string) []byte {
+		return []byte(`memberlist:
+  environment: "local"
+  bindAddr: "0.0.0.0"
+  bindPort: 3322
+
+tls:
+` + tlsSection)
+	}
+
+	lc, err := New(newConfig(`  certFile: "` + certFile + `"
+  keyFile: "` + keyFile + `"
+  clientCAs: "` + certFile + `"
+  requireClientCert: true
+`))
+	require.NoError(t, err)
+	require.Equal(t, certFile, lc.TLS.CertFile)
+	require.True(t, lc.TLS.RequireClientCert)
+
+	tests := []struct {
+		name       string
+		tlsSection string
+		err        string
+	}{
+		{
+			name:       "Missing tls.certFile",
+			tlsSection: `  keyFile: "` + keyFile + `"`,
+			err:        "tls.certFile: required",
+		},
+		{
+			name:       "Missing tls.keyFile",
+			tlsSection: `  certFile: "` + certFile + `"`,
+			err:        "tls.keyFile: required",
+		},
+		{
+			name: "tls.requireClientCert without tls.clientCAs",
+			tlsSection: `  certFile: "` + certFile + `"
+  keyFile: "` + keyFile + `"
+  requireClientCert: true`,
+			err: "tls.requireClientCert: requires tls.clientCAs",
+		},
+		{
+			name: "Absent tls.certFile",
+			tlsSection: `  certFile: "` + filepath.Join(dir, "absent.pem") + `"
+  keyFile: "` + keyFile + `"`,
+			err: "tls.certFile: stat " + filepath.Join(dir, "absent.pem"),
+		},
+		{
+			name: "tls.clientCAs is a directory",
+			tlsSection: `  certFile: "` + certFile + `"
+  keyFile: "` + keyFile + `"
+  clientCAs: "` + dir + `"`,
+			err: "tls.clientCAs: '" + dir + "' is a directory",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(newConfig(test.tlsSection))
+			require.ErrorIs(t, err, ErrInvalidConfig)
+			require.ErrorContains(t, err, test.err)
+		})
+	}
+}
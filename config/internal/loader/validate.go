@@ -0,0 +1,234 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidConfig is returned when the configuration file is incomplete or malformed.
+var ErrInvalidConfig = errors.New("invalid configuration")
+
+func invalidConfig(key, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s: %s", ErrInvalidConfig, key, fmt.Sprintf(format, args...))
+}
+
+// duration is a YAML key that holds a duration string, like "5s".
+type duration struct {
+	key   string
+	value string
+}
+
+func validateDurations(durations []duration) error {
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d.value); err != nil {
+			return invalidConfig(d.key, "invalid duration: '%s'", d.value)
+		}
+	}
+	return nil
+}
+
+func optionalDuration(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func (l *Loader) validateOlricd() error {
+	o := l.Olricd
+	if o.BindPort < 0 || o.BindPort > 65535 {
+		return invalidConfig("olricd.bindPort", "invalid port: %d", o.BindPort)
+	}
+
+	// ReplicaCount is set to its default value later, if it's not given.
+	if o.ReplicaCount > 0 {
+		if o.ReadQuorum > o.ReplicaCount {
+			return invalidConfig("olricd.readQuorum", "cannot be greater than olricd.replicaCount: %d > %d",
+				o.ReadQuorum, o.ReplicaCount)
+		}
+		if o.WriteQuorum > o.ReplicaCount {
+			return invalidConfig("olricd.writeQuorum", "cannot be greater than olricd.replicaCount: %d > %d",
+				o.WriteQuorum, o.ReplicaCount)
+		}
+	}
+
+	return validateDurations([]duration{
+		{"olricd.keepAlivePeriod", o.KeepAlivePeriod},
+		{"olricd.idleClose", o.IdleClose},
+		{"olricd.bootstrapTimeout", o.BootstrapTimeout},
+		{"olricd.routingTablePushInterval", o.RoutingTablePushInterval},
+		{"olricd.triggerBalancerInterval", o.TriggerBalancerInterval},
+		{"olricd.leaveTimeout", o.LeaveTimeout},
+	})
+}
+
+func (l *Loader) validateMemberlist() error {
+	m := l.Memberlist
+	switch strings.ToLower(m.Environment) {
+	case "":
+		return invalidConfig("memberlist.environment", "required")
+	case "local", "lan", "wan":
+	default:
+		return invalidConfig("memberlist.environment", "unknown environment: '%s', must be one of local, lan or wan",
+			m.Environment)
+	}
+	if m.BindAddr == "" {
+		return invalidConfig("memberlist.bindAddr", "required")
+	}
+	if m.BindPort <= 0 || m.BindPort > 65535 {
+		return invalidConfig("memberlist.bindPort", "required, got an invalid port: %d", m.BindPort)
+	}
+
+	return validateDurations([]duration{
+		{"memberlist.joinRetryInterval", m.JoinRetryInterval},
+		{"memberlist.tcpTimeout", optionalDuration(m.TCPTimeout)},
+		{"memberlist.pushPullInterval", optionalDuration(m.PushPullInterval)},
+		{"memberlist.probeTimeout", optionalDuration(m.ProbeTimeout)},
+		{"memberlist.probeInterval", optionalDuration(m.ProbeInterval)},
+		{"memberlist.gossipInterval", optionalDuration(m.GossipInterval)},
+		{"memberlist.gossipToTheDeadTime", optionalDuration(m.GossipToTheDeadTime)},
+	})
+}
+
+func (l *Loader) validateClient() error {
+	c := l.Client
+	return validateDurations([]duration{
+		{"client.dialTimeout", c.DialTimeout},
+		{"client.readTimeout", c.ReadTimeout},
+		{"client.writeTimeout", c.WriteTimeout},
+		{"client.minRetryBackoff", c.MinRetryBackoff},
+		{"client.maxRetryBackoff", c.MaxRetryBackoff},
+		{"client.maxConnAge", c.MaxConnAge},
+		{"client.poolTimeout", c.PoolTimeout},
+		{"client.idleTimeout", c.IdleTimeout},
+	})
+}
+
+func (l *Loader) validateDMaps() error {
+	d := l.DMaps
+	err := validateDurations([]duration{
+		{"dmaps.maxIdleDuration", d.MaxIdleDuration},
+		{"dmaps.ttlDuration", d.TTLDuration},
+		{"dmaps.maxAge", d.MaxAge},
+		{"dmaps.evictionGracePeriod", d.EvictionGracePeriod},
+		{"dmaps.readTimeout", d.ReadTimeout},
+		{"dmaps.writeTimeout", d.WriteTimeout},
+		{"dmaps.accessCounterHalfLife", d.AccessCounterHalfLife},
+		{"dmaps.slowLogThreshold", d.SlowLogThreshold},
+		{"dmaps.checkEmptyFragmentsInterval", d.CheckEmptyFragmentsInterval},
+		{"dmaps.triggerCompactionInterval", d.TriggerCompactionInterval},
+		{"dmaps.idleCompactionInterval", d.IdleCompactionInterval},
+		{"dmaps.shutdownSnapshotTimeout", d.ShutdownSnapshotTimeout},
+		{"dmaps.walSyncInterval", d.WALSyncInterval},
+		{"dmaps.lockPollInterval", d.LockPollInterval},
+		{"dmaps.lockPollMaxInterval", d.LockPollMaxInterval},
+		{"dmaps.scanTimeBudget", d.ScanTimeBudget},
+		{"dmaps.replicaSyncInterval", d.ReplicaSyncInterval},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Sort the names to return the same error for the same file.
+	var names []string
+	for name := range d.Custom {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := d.Custom[name]
+		prefix := "dmaps.custom." + name + "."
+		err = validateDurations([]duration{
+			{prefix + "maxIdleDuration", c.MaxIdleDuration},
+			{prefix + "ttlDuration", c.TTLDuration},
+			{prefix + "maxAge", c.MaxAge},
+			{prefix + "evictionGracePeriod", c.EvictionGracePeriod},
+			{prefix + "readTimeout", c.ReadTimeout},
+			{prefix + "writeTimeout", c.WriteTimeout},
+			{prefix + "slowLogThreshold", c.SlowLogThreshold},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFile checks that the file exists, it's read later.
+func validateFile(key, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return invalidConfig(key, "%v", err)
+	}
+	if info.IsDir() {
+		return invalidConfig(key, "'%s' is a directory", path)
+	}
+	return nil
+}
+
+func (l *Loader) validateTLS() error {
+	t := l.TLS
+	if t == (tlsConfig{}) {
+		// TLS is disabled.
+		return nil
+	}
+	if t.CertFile == "" {
+		return invalidConfig("tls.certFile", "required")
+	}
+	if t.KeyFile == "" {
+		return invalidConfig("tls.keyFile", "required")
+	}
+	if t.RequireClientCert && t.ClientCAs == "" {
+		return invalidConfig("tls.requireClientCert", "requires tls.clientCAs")
+	}
+	if err := validateFile("tls.certFile", t.CertFile); err != nil {
+		return err
+	}
+	if err := validateFile("tls.keyFile", t.KeyFile); err != nil {
+		return err
+	}
+	if t.ClientCAs != "" {
+		return validateFile("tls.clientCAs", t.ClientCAs)
+	}
+	return nil
+}
+
+// Validate checks the required fields, the duration strings and the TLS files of the
+// configuration file. The errors name the offending YAML key, like memberlist.bindAddr, so the
+// misconfigurations are reported at startup instead of failing later.
+func (l *Loader) Validate() error {
+	validators := []func() error{
+		l.validateOlricd,
+		l.validateMemberlist,
+		l.validateClient,
+		l.validateDMaps,
+		l.validateTLS,
+	}
+	for _, validate := range validators {
+		if err := validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
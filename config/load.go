@@ -15,6 +15,9 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -77,6 +80,14 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.TTLDuration = ttlDuration
 	}
 
+	if c.DMaps.MaxAge != "" {
+		maxAge, err := time.ParseDuration(c.DMaps.MaxAge)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.maxAge")
+		}
+		res.MaxAge = maxAge
+	}
+
 	if c.DMaps.CheckEmptyFragmentsInterval != "" {
 		checkEmptyFragmentsInterval, err := time.ParseDuration(c.DMaps.CheckEmptyFragmentsInterval)
 		if err != nil {
@@ -93,11 +104,133 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.TriggerCompactionInterval = triggerCompactionInterval
 	}
 
+	if c.DMaps.IdleCompactionInterval != "" {
+		idleCompactionInterval, err := time.ParseDuration(c.DMaps.IdleCompactionInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.idleCompactionInterval")
+		}
+		res.IdleCompactionInterval = idleCompactionInterval
+	}
+
+	if c.DMaps.ShutdownSnapshotTimeout != "" {
+		shutdownSnapshotTimeout, err := time.ParseDuration(c.DMaps.ShutdownSnapshotTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.shutdownSnapshotTimeout")
+		}
+		res.ShutdownSnapshotTimeout = shutdownSnapshotTimeout
+	}
+
+	if c.DMaps.WALSyncInterval != "" {
+		walSyncInterval, err := time.ParseDuration(c.DMaps.WALSyncInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmaps.walSyncInterval")
+		}
+		res.WALSyncInterval = walSyncInterval
+	}
+
+	if c.DMaps.LockPollInterval != "" {
+		lockPollInterval, err := time.ParseDuration(c.DMaps.LockPollInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.lockPollInterval")
+		}
+		res.LockPollInterval = lockPollInterval
+	}
+
+	if c.DMaps.LockPollMaxInterval != "" {
+		lockPollMaxInterval, err := time.ParseDuration(c.DMaps.LockPollMaxInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.lockPollMaxInterval")
+		}
+		res.LockPollMaxInterval = lockPollMaxInterval
+	}
+	res.LockPollJitter = c.DMaps.LockPollJitter
+
+	if c.DMaps.ScanTimeBudget != "" {
+		scanTimeBudget, err := time.ParseDuration(c.DMaps.ScanTimeBudget)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.scanTimeBudget")
+		}
+		res.ScanTimeBudget = scanTimeBudget
+	}
+
+	if c.DMaps.ReplicaSyncInterval != "" {
+		replicaSyncInterval, err := time.ParseDuration(c.DMaps.ReplicaSyncInterval)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmap.replicaSyncInterval")
+		}
+		res.ReplicaSyncInterval = replicaSyncInterval
+	}
+
 	res.NumEvictionWorkers = c.DMaps.NumEvictionWorkers
+	res.MaxDMaps = c.DMaps.MaxDMaps
+	res.MaxMetricLabels = c.DMaps.MaxMetricLabels
+	res.IdleCompactionThreshold = c.DMaps.IdleCompactionThreshold
+	res.DeferCompactions = c.DMaps.DeferCompactions
 	res.MaxKeys = c.DMaps.MaxKeys
 	res.MaxInuse = c.DMaps.MaxInuse
 	res.EvictionPolicy = EvictionPolicy(c.DMaps.EvictionPolicy)
 	res.LRUSamples = c.DMaps.LRUSamples
+	res.NoEvictKeyPatterns = c.DMaps.NoEvictKeyPatterns
+
+	if c.DMaps.EvictionGracePeriod != "" {
+		evictionGracePeriod, err := time.ParseDuration(c.DMaps.EvictionGracePeriod)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmaps.evictionGracePeriod")
+		}
+		res.EvictionGracePeriod = evictionGracePeriod
+	}
+	res.DeadLetterDMap = c.DMaps.DeadLetterDMap
+
+	if c.DMaps.EncryptionKey != "" {
+		encryptionKey, err := base64.StdEncoding.DecodeString(c.DMaps.EncryptionKey)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to decode dmaps.encryptionKey")
+		}
+		res.EncryptionKey = encryptionKey
+	}
+
+	if c.DMaps.ReadTimeout != "" {
+		readTimeout, err := time.ParseDuration(c.DMaps.ReadTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmaps.readTimeout")
+		}
+		res.ReadTimeout = readTimeout
+	}
+
+	if c.DMaps.WriteTimeout != "" {
+		writeTimeout, err := time.ParseDuration(c.DMaps.WriteTimeout)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmaps.writeTimeout")
+		}
+		res.WriteTimeout = writeTimeout
+	}
+	res.KeySchema = c.DMaps.KeySchema
+	res.AccessCounter = c.DMaps.AccessCounter
+	res.PreSplitSize = c.DMaps.PreSplitSize
+	res.GossipReplication = c.DMaps.GossipReplication
+	res.MaxGossipValueSize = c.DMaps.MaxGossipValueSize
+
+	if c.DMaps.SlowLogThreshold != "" {
+		slowLogThreshold, err := time.ParseDuration(c.DMaps.SlowLogThreshold)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmaps.slowLogThreshold")
+		}
+		res.SlowLogThreshold = slowLogThreshold
+	}
+	res.SlowLogSampleRate = c.DMaps.SlowLogSampleRate
+	res.SlowLogMaxLen = c.DMaps.SlowLogMaxLen
+
+	if c.DMaps.AccessCounterHalfLife != "" {
+		accessCounterHalfLife, err := time.ParseDuration(c.DMaps.AccessCounterHalfLife)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to parse dmaps.accessCounterHalfLife")
+		}
+		res.AccessCounterHalfLife = accessCounterHalfLife
+	}
+	res.ShutdownSnapshotDir = c.DMaps.ShutdownSnapshotDir
+	res.WALDir = c.DMaps.WALDir
+	res.WALSync = c.DMaps.WALSync
+	res.WALMaxSegmentSize = c.DMaps.WALMaxSegmentSize
 
 	if c.DMaps.Engine != nil {
 		e := NewEngine()
@@ -110,10 +243,17 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 		res.Custom = make(map[string]DMap)
 		for name, dc := range c.DMaps.Custom {
 			cc := DMap{
-				MaxInuse:       dc.MaxInuse,
-				MaxKeys:        dc.MaxKeys,
-				EvictionPolicy: EvictionPolicy(dc.EvictionPolicy),
-				LRUSamples:     dc.LRUSamples,
+				MaxInuse:           dc.MaxInuse,
+				MaxKeys:            dc.MaxKeys,
+				EvictionPolicy:     EvictionPolicy(dc.EvictionPolicy),
+				LRUSamples:         dc.LRUSamples,
+				NoEvictKeyPatterns: dc.NoEvictKeyPatterns,
+				DeadLetterDMap:     dc.DeadLetterDMap,
+				KeySchema:          dc.KeySchema,
+				AccessCounter:      dc.AccessCounter,
+				PreSplitSize:       dc.PreSplitSize,
+				GossipReplication:  dc.GossipReplication,
+				SlowLogSampleRate:  dc.SlowLogSampleRate,
 			}
 			if dc.Engine != nil {
 				e := NewEngine()
@@ -135,22 +275,98 @@ func loadDMapConfig(c *loader.Loader) (*DMaps, error) {
 				}
 				cc.TTLDuration = ttlDuration
 			}
+			if dc.MaxAge != "" {
+				maxAge, err := time.ParseDuration(dc.MaxAge)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.maxAge", name)
+				}
+				cc.MaxAge = maxAge
+			}
+			if dc.EvictionGracePeriod != "" {
+				evictionGracePeriod, err := time.ParseDuration(dc.EvictionGracePeriod)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.evictionGracePeriod", name)
+				}
+				cc.EvictionGracePeriod = evictionGracePeriod
+			}
+			if dc.EncryptionKey != "" {
+				encryptionKey, err := base64.StdEncoding.DecodeString(dc.EncryptionKey)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to decode dmaps.%s.encryptionKey", name)
+				}
+				cc.EncryptionKey = encryptionKey
+			}
+			if dc.ReadTimeout != "" {
+				readTimeout, err := time.ParseDuration(dc.ReadTimeout)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.readTimeout", name)
+				}
+				cc.ReadTimeout = readTimeout
+			}
+			if dc.WriteTimeout != "" {
+				writeTimeout, err := time.ParseDuration(dc.WriteTimeout)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.writeTimeout", name)
+				}
+				cc.WriteTimeout = writeTimeout
+			}
+			if dc.SlowLogThreshold != "" {
+				slowLogThreshold, err := time.ParseDuration(dc.SlowLogThreshold)
+				if err != nil {
+					return nil, errors.WithMessagef(err, "failed to parse dmaps.%s.slowLogThreshold", name)
+				}
+				cc.SlowLogThreshold = slowLogThreshold
+			}
 			res.Custom[name] = cc
 		}
 	}
 	return res, nil
 }
 
-// loadMemberlistConfig creates a new *memberlist.Config by parsing olric.yaml
-func loadMemberlistConfig(c *loader.Loader, mc *memberlist.Config) (*memberlist.Config, error) {
-	var err error
-	if c.Memberlist.BindAddr == "" {
-		name, err := os.Hostname()
+// loadTLSConfig creates the TLS configurations of the listener and of the connections
+// between the members. The members present the same certificate to each other and
+// verify it with tls.clientCAs.
+func loadTLSConfig(c *loader.Loader) (*tls.Config, *tls.Config, error) {
+	if c.TLS.CertFile == "" {
+		// TLS is disabled.
+		return nil, nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.TLS.CertFile, c.TLS.KeyFile)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "failed to load tls.certFile and tls.keyFile")
+	}
+	serverConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	clientConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.TLS.ClientCAs != "" {
+		data, err := ioutil.ReadFile(c.TLS.ClientCAs)
 		if err != nil {
-			return nil, err
+			return nil, nil, errors.WithMessage(err, "failed to read tls.clientCAs")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, nil, fmt.Errorf("failed to parse tls.clientCAs: no certificate found in '%s'", c.TLS.ClientCAs)
 		}
-		c.Memberlist.BindAddr = name
+		serverConfig.ClientCAs = pool
+		serverConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		clientConfig.RootCAs = pool
+	}
+	if c.TLS.RequireClientCert {
+		serverConfig.ClientAuth = tls.RequireAndVerifyClientCert
 	}
+	return serverConfig, clientConfig, nil
+}
+
+// loadMemberlistConfig creates a new *memberlist.Config by parsing olric.yaml
+func loadMemberlistConfig(c *loader.Loader, mc *memberlist.Config) (*memberlist.Config, error) {
+	var err error
 	mc.BindAddr = c.Memberlist.BindAddr
 	mc.BindPort = c.Memberlist.BindPort
 
@@ -255,20 +471,13 @@ func loadMemberlistConfig(c *loader.Loader, mc *memberlist.Config) (*memberlist.
 	return mc, nil
 }
 
-// Load reads and loads Olric configuration.
+// Load reads and loads Olric configuration. The file is in YAML format, or in JSON
+// format if it has the .json extension.
 func Load(filename string) (*Config, error) {
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
 		return nil, fmt.Errorf("file doesn't exists: %s", filename)
 	}
-	f, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	data, err := ioutil.ReadAll(f)
-	if err != nil {
-		return nil, err
-	}
-	c, err := loader.New(data)
+	c, err := loader.LoadFile(filename)
 	if err != nil {
 		return nil, err
 	}
@@ -368,6 +577,12 @@ func Load(filename string) (*Config, error) {
 		return nil, err
 	}
 
+	tlsConfig, clientTLSConfig, err := loadTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	clientConfig.TLSConfig = clientTLSConfig
+
 	dmapConfig, err := loadDMapConfig(c)
 	if err != nil {
 		return nil, err
@@ -376,6 +591,7 @@ func Load(filename string) (*Config, error) {
 	cfg := &Config{
 		BindAddr:                   c.Olricd.BindAddr,
 		BindPort:                   c.Olricd.BindPort,
+		TLS:                        tlsConfig,
 		Interface:                  c.Olricd.Interface,
 		ServiceDiscovery:           c.ServiceDiscovery,
 		MemberlistInterface:        c.Memberlist.Interface,
@@ -385,8 +601,12 @@ func Load(filename string) (*Config, error) {
 		JoinRetryInterval:          joinRetryInterval,
 		RoutingTablePushInterval:   routingTablePushInterval,
 		TriggerBalancerInterval:    triggerBalancerInterval,
+		MaxConcurrentMoves:         c.Olricd.MaxConcurrentMoves,
 		EnableClusterEventsChannel: c.Olricd.EnableClusterEventsChannel,
+		EnableWriteFencing:         c.Olricd.EnableWriteFencing,
+		DrainConnections:           c.Olricd.DrainConnections,
 		MaxJoinAttempts:            c.Memberlist.MaxJoinAttempts,
+		MaxClusterSize:             c.Memberlist.MaxClusterSize,
 		Peers:                      c.Memberlist.Peers,
 		PartitionCount:             c.Olricd.PartitionCount,
 		ReplicaCount:               c.Olricd.ReplicaCount,
@@ -394,12 +614,18 @@ func Load(filename string) (*Config, error) {
 		ReadQuorum:                 c.Olricd.ReadQuorum,
 		ReplicationMode:            c.Olricd.ReplicationMode,
 		ReadRepair:                 c.Olricd.ReadRepair,
+		ReadRepairScope:            c.Olricd.ReadRepairScope,
 		LoadFactor:                 c.Olricd.LoadFactor,
 		MemberCountQuorum:          c.Olricd.MemberCountQuorum,
 		Logger:                     log.New(logOutput, "", log.LstdFlags),
 		LogOutput:                  logOutput,
 		LogVerbosity:               c.Logging.Verbosity,
+		LogErrorResponses:          c.Logging.LogErrorResponses,
+		ErrorResponseLogVerbosity:  c.Logging.ErrorResponseLogVerbosity,
+		ErrorResponseLogRate:       c.Logging.ErrorResponseLogRate,
 		Hasher:                     hasher.NewDefaultHasher(),
+		HashSeed:                   c.Olricd.HashSeed,
+		HashTags:                   c.Olricd.HashTags,
 		KeepAlivePeriod:            keepAlivePeriod,
 		IdleClose:                  idleClose,
 		BootstrapTimeout:           bootstrapTimeout,
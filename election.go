@@ -0,0 +1,113 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// electionSafetyMargin returns how long before the lease expires the leadership is given up,
+// so the leader stops acting before another candidate can take the lease even if the clocks
+// drift or the renewal is slow to reach the partition owner.
+func electionSafetyMargin(leaseTTL time.Duration) time.Duration {
+	return leaseTTL / 10
+}
+
+// campaign runs a campaign of the candidate with campaignFn, which returns the current
+// leader. If the candidate is elected, the lease is renewed in the background every
+// leaseTTL/3 until resign is called or the client is closed, which cancels clientCtx.
+// The returned leadership context is cancelled when the renewals stop, it's nil if the
+// candidate is not elected. The renewals stop if another candidate takes the lease, or
+// the lease cannot be renewed in time: the leadership is cancelled by a timer at
+// leaseTTL minus the safety margin after the start of the last successful attempt, even
+// if an attempt is still in progress. resign stops the renewals and releases the lease
+// with resignFn, so a new leader is elected without waiting for the lease to expire.
+func campaign(
+	ctx, clientCtx context.Context,
+	candidate string,
+	leaseTTL time.Duration,
+	campaignFn func(ctx context.Context) (string, error),
+	resignFn func(ctx context.Context) error,
+) (context.Context, func(), error) {
+	validity := leaseTTL - electionSafetyMargin(leaseTTL)
+	// The lease may be taken at any time during the attempt, it's valid for leaseTTL
+	// from the start of the attempt at the latest.
+	start := time.Now()
+	leader, err := campaignFn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if leader != candidate {
+		return nil, func() {}, nil
+	}
+
+	leadership, lose := context.WithCancel(context.Background())
+	expiry := time.AfterFunc(time.Until(start.Add(validity)), lose)
+	renewCtx, cancel := context.WithCancel(clientCtx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer lose()
+		defer expiry.Stop()
+
+		interval := leaseTTL / 3
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-leadership.Done():
+				// The lease couldn't be renewed in time, a new leader may be elected.
+				return
+			case <-ticker.C:
+			}
+
+			renewStart := time.Now()
+			attemptCtx, cancelAttempt := context.WithTimeout(renewCtx, interval)
+			leader, err := campaignFn(attemptCtx)
+			cancelAttempt()
+			if err != nil {
+				// Try again on the next tick, the timer cancels the leadership if it's too late.
+				continue
+			}
+			if leader != candidate {
+				// Another candidate took the lease.
+				return
+			}
+			if !expiry.Stop() {
+				// The leadership has already been cancelled.
+				return
+			}
+			expiry.Reset(time.Until(renewStart.Add(validity)))
+		}
+	}()
+
+	var once sync.Once
+	resign := func() {
+		once.Do(func() {
+			cancel()
+			<-done
+			// The lease expires anyway, if it cannot be released.
+			resignCtx, cancelResign := context.WithTimeout(context.Background(), leaseTTL)
+			defer cancelResign()
+			_ = resignFn(resignCtx)
+		})
+	}
+	return leadership, resign, nil
+}
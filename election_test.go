@@ -0,0 +1,130 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCampaign_Leadership_Lost(t *testing.T) {
+	leaseTTL := 150 * time.Millisecond
+	resignFn := func(ctx context.Context) error { return nil }
+
+	t.Run("Lease cannot be renewed", func(t *testing.T) {
+		var fail int32
+		campaignFn := func(ctx context.Context) (string, error) {
+			if atomic.LoadInt32(&fail) == 1 {
+				return "", errors.New("renewal failed")
+			}
+			return "candidate-a", nil
+		}
+
+		leadership, resign, err := campaign(context.Background(), context.Background(), "candidate-a", leaseTTL, campaignFn, resignFn)
+		require.NoError(t, err)
+		require.NotNil(t, leadership)
+		defer resign()
+
+		<-time.After(leaseTTL)
+		require.NoError(t, leadership.Err())
+
+		atomic.StoreInt32(&fail, 1)
+		select {
+		case <-leadership.Done():
+		case <-time.After(5 * leaseTTL):
+			t.Fatal("Leadership is not lost")
+		}
+	})
+
+	t.Run("Renewal hangs", func(t *testing.T) {
+		var stall int32
+		var lastStart atomic.Value
+		release := make(chan struct{})
+		campaignFn := func(ctx context.Context) (string, error) {
+			start := time.Now()
+			if atomic.LoadInt32(&stall) == 1 {
+				// Ignores the context, like a request stuck on the network.
+				<-release
+				return "candidate-a", nil
+			}
+			lastStart.Store(start)
+			return "candidate-a", nil
+		}
+
+		leadership, resign, err := campaign(context.Background(), context.Background(), "candidate-a", leaseTTL, campaignFn, resignFn)
+		require.NoError(t, err)
+		require.NotNil(t, leadership)
+		defer resign()
+		defer close(release)
+
+		<-time.After(leaseTTL)
+		require.NoError(t, leadership.Err())
+
+		atomic.StoreInt32(&stall, 1)
+		select {
+		case <-leadership.Done():
+		case <-time.After(5 * leaseTTL):
+			t.Fatal("Leadership is not lost")
+		}
+		// The leadership is lost before the lease taken by the last successful attempt expires.
+		require.Less(t, time.Since(lastStart.Load().(time.Time)), leaseTTL)
+	})
+
+	t.Run("Lease taken by another candidate", func(t *testing.T) {
+		var leader atomic.Value
+		leader.Store("candidate-a")
+		campaignFn := func(ctx context.Context) (string, error) {
+			return leader.Load().(string), nil
+		}
+
+		leadership, resign, err := campaign(context.Background(), context.Background(), "candidate-a", leaseTTL, campaignFn, resignFn)
+		require.NoError(t, err)
+		defer resign()
+
+		leader.Store("candidate-b")
+		select {
+		case <-leadership.Done():
+		case <-time.After(5 * leaseTTL):
+			t.Fatal("Leadership is not lost")
+		}
+	})
+
+	t.Run("Resign", func(t *testing.T) {
+		campaignFn := func(ctx context.Context) (string, error) {
+			return "candidate-a", nil
+		}
+
+		leadership, resign, err := campaign(context.Background(), context.Background(), "candidate-a", leaseTTL, campaignFn, resignFn)
+		require.NoError(t, err)
+
+		resign()
+		require.ErrorIs(t, leadership.Err(), context.Canceled)
+	})
+
+	t.Run("Not elected", func(t *testing.T) {
+		campaignFn := func(ctx context.Context) (string, error) {
+			return "candidate-b", nil
+		}
+
+		leadership, _, err := campaign(context.Background(), context.Background(), "candidate-a", leaseTTL, campaignFn, resignFn)
+		require.NoError(t, err)
+		require.Nil(t, leadership)
+	})
+}
@@ -17,22 +17,27 @@ package olric
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"sync"
 	"time"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/dmap"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/util"
+	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/buraksezer/olric/stats"
 )
 
 // EmbeddedLockContext is returned by Lock and LockWithTimeout methods.
 // It should be stored in a proper way to release the lock.
 type EmbeddedLockContext struct {
-	key   string
-	token []byte
-	dm    *EmbeddedDMap
+	key          string
+	token        []byte
+	fencingToken uint64
+	dm           *EmbeddedDMap
 }
 
 // Unlock releases the lock.
@@ -47,6 +52,17 @@ func (l *EmbeddedLockContext) Lease(ctx context.Context, duration time.Duration)
 	return convertDMapError(err)
 }
 
+// LeaseAndPut updates the timeout of the lock and writes its protected value atomically.
+func (l *EmbeddedLockContext) LeaseAndPut(ctx context.Context, value []byte, duration time.Duration) error {
+	err := l.dm.dm.LeaseAndPut(ctx, l.key, l.token, value, duration)
+	return convertDMapError(err)
+}
+
+// Token returns the fencing token of the lock.
+func (l *EmbeddedLockContext) Token() uint64 {
+	return l.fencingToken
+}
+
 // EmbeddedClient is an Olric client implementation for embedded-member scenario.
 type EmbeddedClient struct {
 	db *Olric
@@ -155,24 +171,26 @@ func (dm *EmbeddedDMap) Scan(ctx context.Context, options ...ScanOption) (Iterat
 	return e, nil
 }
 
+func (dm *EmbeddedDMap) newLockContext(key string, token []byte, fencingToken uint64) LockContext {
+	return &EmbeddedLockContext{
+		key:          key,
+		token:        token,
+		fencingToken: fencingToken,
+		dm:           dm,
+	}
+}
+
 // Lock sets a lock for the given key. Acquired lock is only for the key in
 // this dmap.
 //
 // It returns immediately if it acquires the lock for the given key. Otherwise,
 // it waits until deadline.
-//
-// You should know that the locks are approximate, and only to be used for
-// non-critical purposes.
 func (dm *EmbeddedDMap) Lock(ctx context.Context, key string, deadline time.Duration) (LockContext, error) {
-	token, err := dm.dm.Lock(ctx, key, 0*time.Second, deadline)
+	token, fencingToken, err := dm.dm.Lock(ctx, key, 0*time.Second, deadline)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
-	return &EmbeddedLockContext{
-		key:   key,
-		token: token,
-		dm:    dm,
-	}, nil
+	return dm.newLockContext(key, token, fencingToken), nil
 }
 
 // LockWithTimeout sets a lock for the given key. If the lock is still unreleased
@@ -182,19 +200,141 @@ func (dm *EmbeddedDMap) Lock(ctx context.Context, key string, deadline time.Dura
 //
 // It returns immediately if it acquires the lock for the given key. Otherwise,
 // it waits until deadline.
-//
-// You should know that the locks are approximate, and only to be used for
-// non-critical purposes.
 func (dm *EmbeddedDMap) LockWithTimeout(ctx context.Context, key string, timeout, deadline time.Duration) (LockContext, error) {
-	token, err := dm.dm.Lock(ctx, key, timeout, deadline)
+	token, fencingToken, err := dm.dm.Lock(ctx, key, timeout, deadline)
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
-	return &EmbeddedLockContext{
-		key:   key,
-		token: token,
-		dm:    dm,
-	}, nil
+	return dm.newLockContext(key, token, fencingToken), nil
+}
+
+// LockWithOptions sets a lock for the given key. The acquisition deadline, the
+// lease and the poll interval are set separately by LockOptions. Cancelling
+// ctx aborts the acquisition, it returns the context's error in this case.
+func (dm *EmbeddedDMap) LockWithOptions(ctx context.Context, key string, opts LockOptions) (LockContext, error) {
+	token, fencingToken, err := dm.dm.LockWithPollInterval(ctx, key, opts.Lease, opts.Deadline, opts.PollInterval)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return dm.newLockContext(key, token, fencingToken), nil
+}
+
+// TryLock tries to acquire the lock for the given key only once. It returns
+// ErrLockNotAcquired immediately if the lock is held by someone else. The lock
+// is released automatically after timeout, it never expires if timeout is zero.
+func (dm *EmbeddedDMap) TryLock(ctx context.Context, key string, timeout time.Duration) (LockContext, error) {
+	token, fencingToken, err := dm.dm.TryLock(ctx, key, timeout)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return dm.newLockContext(key, token, fencingToken), nil
+}
+
+// LockInfo returns whether the lock of the key is held and its remaining TTL, without
+// trying to acquire it. The TTL is zero if the lock never expires. It's read-only, it's
+// useful to debug the stuck locks.
+func (dm *EmbeddedDMap) LockInfo(ctx context.Context, key string) (bool, time.Duration, error) {
+	held, ttl, err := dm.dm.LockInfo(ctx, key)
+	if err != nil {
+		return false, 0, convertDMapError(err)
+	}
+	return held, ttl, nil
+}
+
+// AcquirePermit takes a permit from the distributed counting semaphore stored at
+// the key. At most max permits are held at the same time across the cluster. It
+// doesn't block, ok is false if the semaphore is full. The permit is released by
+// calling release, or automatically after ttl if the holder crashes.
+func (dm *EmbeddedDMap) AcquirePermit(ctx context.Context, key string, max int, ttl time.Duration) (release func(ctx context.Context) error, ok bool, err error) {
+	token, ok, err := dm.dm.AcquirePermit(ctx, key, max, ttl)
+	if err != nil {
+		return nil, false, convertDMapError(err)
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	release = func(ctx context.Context) error {
+		return convertDMapError(dm.dm.ReleasePermit(ctx, key, token))
+	}
+	return release, true, nil
+}
+
+// Register registers the instance of the service with its metadata, like its address.
+// The registration expires after ttl, the returned heartbeat extends it by ttl again and
+// should be called periodically. The heartbeat returns ErrNoSuchInstance if the
+// registration is expired or the instance is registered again somewhere else, it never
+// clobbers the new registration.
+func (dm *EmbeddedDMap) Register(ctx context.Context, service, instance string, meta []byte, ttl time.Duration) (heartbeat func(ctx context.Context) error, err error) {
+	token, err := dm.dm.Register(ctx, service, instance, meta, ttl)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	heartbeat = func(ctx context.Context) error {
+		return convertDMapError(dm.dm.Heartbeat(ctx, service, instance, token, ttl))
+	}
+	return heartbeat, nil
+}
+
+// Discover returns the live instances of the service sorted by their names. The
+// instances without a heartbeat disappear when their registrations expire.
+func (dm *EmbeddedDMap) Discover(ctx context.Context, service string) ([]Instance, error) {
+	instances, err := dm.dm.Discover(ctx, service)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	result := make([]Instance, 0, len(instances))
+	for _, instance := range instances {
+		result = append(result, Instance{
+			Name: instance.Name,
+			Meta: instance.Meta,
+			TTL:  instance.TTL,
+		})
+	}
+	return result, nil
+}
+
+// Campaign runs a campaign of the candidate for the leadership of the election. The
+// leader holds a lease of leaseTTL, which is renewed automatically in the background.
+// If isLeader is false, another candidate holds the lease, call Campaign again to
+// take over after the lease of the leader expires. resign stops the renewals and
+// releases the lease, so a new leader can be elected. The renewals stop when the client
+// is closed, or the lease cannot be renewed before it expires. Use Leader to observe
+// the leader, or CampaignWithLeadership to learn when the leadership is lost.
+func (dm *EmbeddedDMap) Campaign(ctx context.Context, election, candidateID string, leaseTTL time.Duration) (isLeader bool, resign func(), err error) {
+	leadership, resign, err := dm.CampaignWithLeadership(ctx, election, candidateID, leaseTTL)
+	if err != nil {
+		return false, nil, err
+	}
+	return leadership != nil, resign, nil
+}
+
+// CampaignWithLeadership runs a campaign like Campaign. If the candidate is elected,
+// the returned leadership context is cancelled when the leadership is lost: another
+// candidate takes the lease, the lease cannot be renewed before it expires, resign is
+// called or the client is closed. The leader should stop acting as the leader then.
+// leadership is nil if another candidate holds the lease.
+func (dm *EmbeddedDMap) CampaignWithLeadership(ctx context.Context, election, candidateID string, leaseTTL time.Duration) (leadership context.Context, resign func(), err error) {
+	campaignFn := func(ctx context.Context) (string, error) {
+		leader, err := dm.dm.Campaign(ctx, election, candidateID, leaseTTL)
+		if err != nil {
+			return "", convertDMapError(err)
+		}
+		return leader, nil
+	}
+	resignFn := func(ctx context.Context) error {
+		return convertDMapError(dm.dm.Resign(ctx, election, candidateID))
+	}
+	return campaign(ctx, dm.client.db.ctx, candidateID, leaseTTL, campaignFn, resignFn)
+}
+
+// Leader returns the candidate that holds the lease of the election. It returns
+// ErrNoLeader if the election has no leader.
+func (dm *EmbeddedDMap) Leader(ctx context.Context, election string) (string, error) {
+	leader, err := dm.dm.Leader(ctx, election)
+	if err != nil {
+		return "", convertDMapError(err)
+	}
+	return leader, nil
 }
 
 // Destroy flushes the given DMap on the cluster. You should know that there
@@ -210,6 +350,12 @@ func (dm *EmbeddedDMap) Expire(ctx context.Context, key string, timeout time.Dur
 	return dm.dm.Expire(ctx, key, timeout)
 }
 
+// Persist removes the expiry of the given key, so it never expires. The value is
+// not rewritten. It returns ErrKeyNotFound if the DB does not contain the key.
+func (dm *EmbeddedDMap) Persist(ctx context.Context, key string) error {
+	return dm.dm.Persist(ctx, key)
+}
+
 // Name exposes name of the DMap.
 func (dm *EmbeddedDMap) Name() string {
 	return dm.name
@@ -220,42 +366,463 @@ func (dm *EmbeddedDMap) Name() string {
 func (dm *EmbeddedDMap) GetPut(ctx context.Context, key string, value interface{}) (*GetResponse, error) {
 	e, err := dm.dm.GetPut(ctx, key, value)
 	if err != nil {
-		return nil, err
+		return nil, convertDMapError(err)
 	}
 	return &GetResponse{
 		entry: e,
 	}, nil
 }
 
+// GetByTag returns the values of all keys whose hash tag is tag, like "user:42" for the
+// keys "{user:42}:profile" and "{user:42}:session", mapped by their keys. The keys with
+// the same tag are stored on the same partition, so it reads a single partition on its
+// owner in one pass. It returns ErrHashTagsDisabled if the hash tags are not enabled.
+func (dm *EmbeddedDMap) GetByTag(ctx context.Context, tag string) (map[string]*GetResponse, error) {
+	entries, err := dm.dm.GetByTag(ctx, tag)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	result := make(map[string]*GetResponse, len(entries))
+	for key, entry := range entries {
+		result[key] = &GetResponse{entry: entry}
+	}
+	return result, nil
+}
+
+// Rotate atomically sets the key to value and returns the old value. The old value is
+// copied to the key with the ":previous" suffix and expires after graceTTL, so the old
+// value stays retrievable during a zero-downtime rotation, like a credential rotation.
+// It returns nil if the key doesn't exist, and no previous value is written.
+func (dm *EmbeddedDMap) Rotate(ctx context.Context, key string, value interface{}, graceTTL time.Duration) (*GetResponse, error) {
+	e, err := dm.dm.Rotate(ctx, key, value, graceTTL)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	if e == nil {
+		return nil, nil
+	}
+	return &GetResponse{
+		entry: e,
+	}, nil
+}
+
+// SetNXGet atomically sets the key to value with the given TTL if the key does not exist.
+// It returns true and the newly written entry if the key is set. Otherwise, it returns false
+// and the entry of the current holder. A zero ttl means no expiration.
+func (dm *EmbeddedDMap) SetNXGet(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, *GetResponse, error) {
+	acquired, e, err := dm.dm.SetNXGet(ctx, key, value, ttl)
+	if err != nil {
+		return false, nil, convertDMapError(err)
+	}
+	return acquired, &GetResponse{
+		entry: e,
+	}, nil
+}
+
+// GetOrSet returns the value of the key and true if the key exists. Otherwise, it sets
+// the key to value with the given TTL, and returns the new value and false. It runs on
+// the partition owner in a single round trip, so the concurrent callers agree on a
+// single value. A zero ttl means no expiration.
+func (dm *EmbeddedDMap) GetOrSet(ctx context.Context, key string, value interface{}, ttl time.Duration) (*GetResponse, bool, error) {
+	e, loaded, err := dm.dm.GetOrSet(ctx, key, value, ttl)
+	if err != nil {
+		return nil, false, convertDMapError(err)
+	}
+	return &GetResponse{
+		entry: e,
+	}, loaded, nil
+}
+
+// ClaimNext atomically claims an unclaimed entry and returns its key and value. The
+// entry stays claimed for claimTTL, so the claim of a crashed worker is released
+// automatically. The partition owners are tried in turn. It returns ErrNoAvailable
+// if all entries are claimed. The claim is a marker entry with the key ClaimKeyPrefix +
+// key and the TTL claimTTL, so it's replicated and moved with its partition.
+func (dm *EmbeddedDMap) ClaimNext(ctx context.Context, claimTTL time.Duration) (string, *GetResponse, error) {
+	e, err := dm.dm.ClaimNext(ctx, claimTTL)
+	if err != nil {
+		return "", nil, convertDMapError(err)
+	}
+	return e.Key(), &GetResponse{
+		entry: e,
+	}, nil
+}
+
 // Decr atomically decrements the key by delta. The return value is the new value
 // after being decremented or an error.
 func (dm *EmbeddedDMap) Decr(ctx context.Context, key string, delta int) (int, error) {
-	return dm.dm.Decr(ctx, key, delta)
+	value, err := dm.dm.Decr(ctx, key, delta)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return value, nil
+}
+
+// NextSequence returns the next number of the sequence stored at the key. The
+// numbers start from 1, they are unique and increasing across the cluster.
+func (dm *EmbeddedDMap) NextSequence(ctx context.Context, name string) (int64, error) {
+	n, err := dm.dm.NextSequence(ctx, name)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return n, nil
+}
+
+// ReserveSequenceBlock reserves n numbers of the sequence stored at the key in one
+// request and returns the first one. The caller owns the numbers in [start, start+n)
+// and can allocate them locally, the blocks never overlap.
+func (dm *EmbeddedDMap) ReserveSequenceBlock(ctx context.Context, name string, n int) (int64, error) {
+	start, err := dm.dm.ReserveSequenceBlock(ctx, name, n)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return start, nil
+}
+
+// HIncrBy atomically increments the field of the hash stored at the key by delta. The
+// hash and the field are created if they don't exist. The return value is the new
+// value of the field. It returns ErrNotAnInteger if the field is not an integer and
+// ErrNotAHash if the value is not a hash.
+func (dm *EmbeddedDMap) HIncrBy(ctx context.Context, key, field string, delta int) (int, error) {
+	value, err := dm.dm.HIncrBy(ctx, key, field, delta)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return value, nil
+}
+
+// HMSet sets the fields of the hash stored at the key in one pass. The hash is
+// created if it doesn't exist, the other fields and the TTL are preserved. It
+// returns ErrNotAHash if the value is not a hash.
+func (dm *EmbeddedDMap) HMSet(ctx context.Context, key string, fields map[string][]byte) error {
+	return convertDMapError(dm.dm.HMSet(ctx, key, fields))
+}
+
+// HMGet returns the fields of the hash stored at the key in one pass. The absent
+// fields are omitted. It returns ErrNotAHash if the value is not a hash.
+func (dm *EmbeddedDMap) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	result, err := dm.dm.HMGet(ctx, key, fields...)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return result, nil
+}
+
+// PutWithVersion sets the value of the key if its current version is equal to
+// expectedVersion, and increments the version atomically. An absent key has version 0.
+// It returns the new version, or ErrVersionMismatch if the version is different. The
+// version is kept in the metadata of the entry, Get returns the value and
+// GetResponse.Version returns the version. A Put without a version resets the version
+// to 0. The TTL of the key is preserved.
+func (dm *EmbeddedDMap) PutWithVersion(ctx context.Context, key string, value []byte, expectedVersion int64) (int64, error) {
+	version, err := dm.dm.PutWithVersion(ctx, key, value, expectedVersion)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return version, nil
+}
+
+// PutIfField atomically sets the value of the key if the field of the stored object is
+// equal to expected, and returns true if the value is written. The stored object is either
+// a hash or a JSON object, the string fields of a JSON object are compared without the
+// quotes. It returns false if the object doesn't have the field, ErrKeyNotFound if the
+// key does not exist and ErrNotAnObject if the value is not an object.
+func (dm *EmbeddedDMap) PutIfField(ctx context.Context, key, field string, expected, value []byte) (bool, error) {
+	written, err := dm.dm.PutIfField(ctx, key, field, expected, value)
+	if err != nil {
+		return false, convertDMapError(err)
+	}
+	return written, nil
+}
+
+// Merge atomically applies a JSON merge patch (RFC 7386) to the JSON document stored at
+// the key. The fields of the patch replace the fields of the document, the objects are
+// merged recursively and a null deletes the field. The key is created from the patch if
+// it doesn't exist. It returns ErrNotAnObject if the stored value is not JSON.
+func (dm *EmbeddedDMap) Merge(ctx context.Context, key string, patch []byte) error {
+	return convertDMapError(dm.dm.Merge(ctx, key, patch))
+}
+
+// SetStandby stages the value in the standby slot of the key. The active slot is the
+// value of the key, it's preserved and Get keeps returning it. The standby value is
+// stored with the key StandbyKeyPrefix + key and expires with the active value.
+func (dm *EmbeddedDMap) SetStandby(ctx context.Context, key string, value []byte) error {
+	return convertDMapError(dm.dm.SetStandby(ctx, key, value))
+}
+
+// PromoteStandby atomically swaps the active and the standby slots of the key, the
+// previous active value becomes the standby one. Calling it again rolls the change
+// back. It returns ErrNoStandby if the key has no standby value.
+func (dm *EmbeddedDMap) PromoteStandby(ctx context.Context, key string) error {
+	return convertDMapError(dm.dm.PromoteStandby(ctx, key))
+}
+
+// LPushCapped atomically pushes the values to the head of the list stored at the key
+// and trims the list to max elements, the last value becomes the head. trim decides
+// which end of the list is dropped. It returns the length of the list, or ErrNotAList
+// if the value is not a list.
+func (dm *EmbeddedDMap) LPushCapped(ctx context.Context, key string, max int, trim ListTrim, values ...[]byte) (int, error) {
+	length, err := dm.dm.LPushCapped(ctx, key, max, trim, values...)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return length, nil
+}
+
+// LRange returns the elements of the list stored at the key between start and stop,
+// both inclusive. The head is at index 0, the negative indexes are counted from the
+// tail. It returns ErrNotAList if the value is not a list.
+func (dm *EmbeddedDMap) LRange(ctx context.Context, key string, start, stop int) ([][]byte, error) {
+	list, err := dm.dm.LRange(ctx, key, start, stop)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return list, nil
+}
+
+// SetBit atomically sets or clears the bit at the offset in the value stored at the key,
+// and returns the previous bit. The value is zero-padded if the offset is beyond its end.
+// The semantics is the same as Redis SETBIT.
+func (dm *EmbeddedDMap) SetBit(ctx context.Context, key string, offset, value int) (int, error) {
+	old, err := dm.dm.SetBit(ctx, key, offset, value)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return old, nil
+}
+
+// GetBit returns the bit at the offset in the value stored at the key. The bits beyond
+// the end of the value and the bits of an absent key are 0.
+func (dm *EmbeddedDMap) GetBit(ctx context.Context, key string, offset int) (int, error) {
+	bit, err := dm.dm.GetBit(ctx, key, offset)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return bit, nil
+}
+
+// DecrAndDeleteAtZero atomically decrements the key by delta. If the result is equal to or
+// less than zero, the key is deleted, deleted is true and remaining is zero. It returns
+// ErrKeyNotFound if the key does not exist and ErrNotAnInteger if the value is not an integer.
+func (dm *EmbeddedDMap) DecrAndDeleteAtZero(ctx context.Context, key string, delta int) (remaining int, deleted bool, err error) {
+	remaining, deleted, err = dm.dm.DecrAndDeleteAtZero(ctx, key, delta)
+	if err != nil {
+		return 0, false, convertDMapError(err)
+	}
+	return remaining, deleted, nil
+}
+
+// GetAndReset atomically returns the integer value stored at the key and sets it to zero,
+// so the increments between the read and the reset are not lost. It returns 0 if the key
+// does not exist, and ErrNotAnInteger if the value is not an integer.
+func (dm *EmbeddedDMap) GetAndReset(ctx context.Context, key string) (int, error) {
+	value, err := dm.dm.GetAndReset(ctx, key)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return value, nil
+}
+
+// GetDel atomically returns the value of the key and deletes it, so only one of the
+// concurrent callers gets the value, like consuming a one-shot token. It returns
+// ErrKeyNotFound if the key does not exist.
+func (dm *EmbeddedDMap) GetDel(ctx context.Context, key string) (*GetResponse, error) {
+	entry, err := dm.dm.GetDel(ctx, key)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return &GetResponse{
+		entry: entry,
+	}, nil
+}
+
+// Append atomically appends suffix to the value of the key and returns the length of the
+// new value. If the key does not exist, it is created with suffix as its value.
+func (dm *EmbeddedDMap) Append(ctx context.Context, key string, suffix []byte) (int, error) {
+	length, err := dm.dm.Append(ctx, key, suffix)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return length, nil
+}
+
+// OnZero registers a callback that is called once when DecrAndDeleteAtZero brings the
+// counter stored at the key to zero, then the callback is removed. It runs in the background
+// on the member that runs the decrement, which is the partition owner of the key. The
+// callbacks are kept in memory, register the callback on every member to survive the
+// ownership changes.
+func (dm *EmbeddedDMap) OnZero(key string, f func(dmap, key string)) {
+	dm.dm.OnZero(key, f)
+}
+
+// CancelOnZero removes the callback of the key registered on this member.
+func (dm *EmbeddedDMap) CancelOnZero(key string) {
+	dm.dm.CancelOnZero(key)
 }
 
 // Incr atomically increments the key by delta. The return value is the new value
 // after being incremented or an error.
 func (dm *EmbeddedDMap) Incr(ctx context.Context, key string, delta int) (int, error) {
-	return dm.dm.Incr(ctx, key, delta)
+	value, err := dm.dm.Incr(ctx, key, delta)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return value, nil
 }
 
 // IncrByFloat atomically increments the key by delta. The return value is the new value after being incremented or an error.
+// It returns ErrInvalidFloat if the delta or the stored value is NaN or Infinity, or the result would overflow to Infinity.
 func (dm *EmbeddedDMap) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
-	return dm.dm.IncrByFloat(ctx, key, delta)
+	value, err := dm.dm.IncrByFloat(ctx, key, delta)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return value, nil
+}
+
+// Eval runs a script atomically on the given keys. All keys must belong to the same
+// partition owner, otherwise it returns ErrCrossOwnerKeys. The script runs on the owner
+// under the locks of the keys and its writes are applied only if it returns successfully.
+// A script runs at most one second. The return value is nil, an int64 or a string.
+//
+// The scripts are written in a small language without loops:
+//
+//	let balance = get(KEYS[1])
+//	if balance != nil && balance >= ARGV[1] {
+//		set(KEYS[1], balance - ARGV[1])
+//		incr(KEYS[2], ARGV[1])
+//		return 1
+//	}
+//	return 0
+//
+// The built-in functions are get, set, del, exists, incr and error.
+func (dm *EmbeddedDMap) Eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error) {
+	value, err := dm.dm.Eval(ctx, script, keys, args...)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return value, nil
+}
+
+// PutAllIfNoneExist sets all the given keys only if none of them exists, it's
+// all-or-nothing. It returns false without writing anything if any of the keys already
+// exists. All keys must belong to the same partition owner, otherwise it returns
+// ErrCrossOwnerKeys.
+func (dm *EmbeddedDMap) PutAllIfNoneExist(ctx context.Context, pairs map[string][]byte) (bool, error) {
+	written, err := dm.dm.PutAllIfNoneExist(ctx, pairs)
+	if err != nil {
+		return false, convertDMapError(err)
+	}
+	return written, nil
+}
+
+// MemoryUsage returns the number of bytes that the key occupies in the storage engine,
+// including the key and the metadata. The value is not fetched. It returns ErrKeyNotFound
+// if the key doesn't exist.
+func (dm *EmbeddedDMap) MemoryUsage(ctx context.Context, key string) (int, error) {
+	size, err := dm.dm.MemoryUsage(ctx, key)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return size, nil
+}
+
+// Exists returns the number of the keys that exist. A key is counted as many times
+// as it's given. The keys are grouped by their partition owners and every owner is
+// queried once. The values are not fetched.
+func (dm *EmbeddedDMap) Exists(ctx context.Context, keys ...string) (int, error) {
+	count, err := dm.dm.Exists(ctx, keys...)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return count, nil
+}
+
+// PutMany sets the raw values of the keys with the same options. The pairs are grouped
+// by their partition owners and every owner receives one request. The batch is not
+// atomic: a failed pair, for example an existing key with NX, stops the rest of the
+// batch on its owner, and the pairs that were already written are kept.
+func (dm *EmbeddedDMap) PutMany(ctx context.Context, pairs map[string][]byte, options ...PutOption) error {
+	var pc dmap.PutConfig
+	for _, opt := range options {
+		opt(&pc)
+	}
+	err := dm.dm.PutMany(ctx, pairs, &pc)
+	if err != nil {
+		return convertDMapError(err)
+	}
+	return nil
+}
+
+// GetMany returns the raw values of the keys. The absent keys are omitted. The keys
+// are grouped by their partition owners and every owner is queried once.
+func (dm *EmbeddedDMap) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values, err := dm.dm.GetMany(ctx, keys)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return values, nil
+}
+
+// GetTTLMany returns the remaining TTLs of the keys in milliseconds. The keys without
+// a TTL are mapped to -1 and the absent keys are omitted. The keys are grouped by their
+// partition owners and every owner is queried once. The values are not fetched.
+func (dm *EmbeddedDMap) GetTTLMany(ctx context.Context, keys []string) (map[string]int64, error) {
+	ttls, err := dm.dm.GetTTLMany(ctx, keys)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	return ttls, nil
+}
+
+// TTL returns the remaining time to live of the key. It returns zero if the key has no
+// expiry, and ErrKeyNotFound if the key does not exist. The value is not fetched.
+func (dm *EmbeddedDMap) TTL(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := dm.dm.TTL(ctx, key)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return ttl, nil
 }
 
 // Delete deletes values for the given keys. Delete will not return error
 // if key doesn't exist. It's thread-safe. It is safe to modify the contents
 // of the argument after Delete returns.
 func (dm *EmbeddedDMap) Delete(ctx context.Context, keys ...string) (int, error) {
-	return dm.dm.Delete(ctx, keys...)
+	var count int
+	err := dmap.RunWithTimeout(ctx, dm.config.writeTimeout, func(ctx context.Context) error {
+		var err error
+		count, err = dm.dm.Delete(ctx, keys...)
+		return err
+	})
+	if errors.Is(err, dmap.ErrTimeout) {
+		return 0, ErrTimeout
+	}
+	return count, err
+}
+
+// DeleteIfExpiredBefore deletes the key only if its expiry time is at or before ts, in
+// milliseconds since the Unix epoch. It returns true if the key is deleted. A key without
+// a TTL is never deleted. The check and the deletion are atomic on the partition owner,
+// so a cleanup worker doesn't delete a key whose TTL is extended concurrently.
+func (dm *EmbeddedDMap) DeleteIfExpiredBefore(ctx context.Context, key string, ts int64) (bool, error) {
+	deleted, err := dm.dm.DeleteIfExpiredBefore(ctx, key, ts)
+	if err != nil {
+		return false, convertDMapError(err)
+	}
+	return deleted, nil
 }
 
 // Get gets the value for the given key. It returns ErrKeyNotFound if the DB
 // does not contain the key. It's thread-safe. It is safe to modify the contents
 // of the returned value. See GetResponse for the details.
 func (dm *EmbeddedDMap) Get(ctx context.Context, key string) (*GetResponse, error) {
-	result, err := dm.dm.Get(ctx, key)
+	var result storage.Entry
+	err := dmap.RunWithTimeout(ctx, dm.config.readTimeout, func(ctx context.Context) error {
+		var err error
+		result, err = dm.dm.Get(ctx, key)
+		return err
+	})
 	if err != nil {
 		return nil, convertDMapError(err)
 	}
@@ -273,7 +840,9 @@ func (dm *EmbeddedDMap) Put(ctx context.Context, key string, value interface{},
 	for _, opt := range options {
 		opt(&pc)
 	}
-	err := dm.dm.Put(ctx, key, value, &pc)
+	err := dmap.RunWithTimeout(ctx, dm.config.writeTimeout, func(ctx context.Context) error {
+		return dm.dm.Put(ctx, key, value, &pc)
+	})
 	if err != nil {
 		return convertDMapError(err)
 	}
@@ -300,6 +869,94 @@ func (e *EmbeddedClient) NewDMap(name string, options ...DMapOption) (DMap, erro
 	}, nil
 }
 
+// Change is a single modification on a DMap. See EmbeddedClient.StreamChanges.
+type Change = dmap.Change
+
+// ChangeType denotes the kind of modification delivered by a change stream.
+type ChangeType = dmap.ChangeType
+
+const (
+	// ChangePut is emitted when a key is inserted or updated.
+	ChangePut = dmap.ChangePut
+
+	// ChangeDelete is emitted when a key is deleted by a client.
+	ChangeDelete = dmap.ChangeDelete
+
+	// ChangeExpire is emitted when a key is expired or evicted.
+	ChangeExpire = dmap.ChangeExpire
+
+	// ChangeLag is emitted when the consumer was too slow and some changes have been dropped
+	// from the change log before it could read them.
+	ChangeLag = dmap.ChangeLag
+)
+
+const (
+	// DefaultChangeLogSize is the number of the latest changes kept for every DMap on a member.
+	DefaultChangeLogSize = dmap.DefaultChangeLogSize
+
+	// DefaultChangeLogBytes is the maximum size of the keys and the values kept in the change
+	// log of a DMap on a member.
+	DefaultChangeLogBytes = dmap.DefaultChangeLogBytes
+
+	// DefaultChangeLogRetention is how long a member keeps recording the changes of a DMap
+	// after the last read of a stream.
+	DefaultChangeLogRetention = dmap.DefaultChangeLogRetention
+)
+
+// StreamChanges streams the put, delete and expire events of the given DMap on the whole
+// cluster, starting from the changes written at or after since (Unix time in nanoseconds),
+// then tails the live changes. The changes are published by the partition owners, the stream
+// reads them from every member and merges them. Call the returned function to stop the
+// stream, the channel is closed then. The channel is closed immediately if the DMap cannot
+// be created.
+//
+// Every member keeps the latest changes of a DMap in a change log, bounded by
+// DefaultChangeLogSize and DefaultChangeLogBytes. The log records the changes only while a
+// stream reads it, and for DefaultChangeLogRetention after the last read, the values of an
+// encrypted DMap are kept encrypted. A slow consumer doesn't block the writers, and a
+// consumer that resumes within the retention with a new stream from the timestamp of the
+// last change it has processed receives the deletes and expirations it has missed. If the
+// log of a member doesn't hold every change since then, the entries written at or after
+// since are replayed from the storage of the member first, one partition at a time, then
+// the changes in the log follow.
+//
+// The delivery is at-least-once for the changes in the logs, a change may be delivered more
+// than once. The changes of a member are delivered in order, the changes of different
+// members are interleaved. If the consumer falls behind the log of a member, it receives a
+// ChangeLag event with the timestamp of the last change delivered before the gap. A new
+// stream from that timestamp replays the puts in the gap, but the deletes and expirations
+// in the gap are lost. When a partition moves to another member, the moved entries are not
+// delivered as changes.
+func (e *EmbeddedClient) StreamChanges(name string, since int64) (<-chan Change, func()) {
+	dm, err := e.db.dmap.NewDMap(name)
+	if err != nil {
+		e.db.log.V(2).Printf("[ERROR] Failed to stream the changes of DMap: %s: %v", name, err)
+		changes := make(chan Change)
+		close(changes)
+		return changes, func() {}
+	}
+	return dm.StreamChanges(since)
+}
+
+// ReconfigureFragment reinitializes the storage engine of a DMap fragment hosted by this
+// member with the given engine options, e.g. tableSize. Set backup to reconfigure the
+// backup fragment of the partition instead of the primary one.
+//
+// Every live entry of the fragment is copied into the new engine, the reads and writes on the
+// fragment wait until the copy is done. The options are not persisted, the fragment uses the
+// options of the DMap again after it's moved to another member.
+func (e *EmbeddedClient) ReconfigureFragment(name string, partID uint64, backup bool, options map[string]interface{}) error {
+	dm, err := e.db.dmap.NewDMap(name)
+	if err != nil {
+		return convertDMapError(err)
+	}
+	kind := partitions.PRIMARY
+	if backup {
+		kind = partitions.BACKUP
+	}
+	return convertDMapError(dm.ReconfigureFragment(partID, kind, options))
+}
+
 // Stats exposes some useful metrics to monitor an Olric node.
 func (e *EmbeddedClient) Stats(ctx context.Context, address string, options ...StatsOption) (stats.Stats, error) {
 	if err := e.db.isOperable(); err != nil {
@@ -362,6 +1019,140 @@ func (e *EmbeddedClient) RoutingTable(ctx context.Context) (RoutingTable, error)
 	return e.db.routingTable(ctx)
 }
 
+// KeyPlacement returns the partition id, the primary owner and the replica owners
+// of the given key on the given DMap. It's computed from the routing table of the
+// cluster coordinator, so it reflects the latest placement after rebalancing.
+func (e *EmbeddedClient) KeyPlacement(ctx context.Context, dmap, key string) (*KeyPlacement, error) {
+	return e.db.keyPlacement(ctx, dmap, key)
+}
+
+// EntryAgeRange returns the oldest and the newest write timestamps of the entries
+// in the given DMap, in nanoseconds. Every member walks its primary copies and the
+// results are merged. It returns zero values if the DMap has no entries.
+func (e *EmbeddedClient) EntryAgeRange(ctx context.Context, dmap string) (oldest, newest int64, err error) {
+	dm, err := e.db.dmap.NewDMap(dmap)
+	if err != nil {
+		return 0, 0, convertDMapError(err)
+	}
+	oldest, newest, err = dm.EntryAgeRange(ctx)
+	if err != nil {
+		return 0, 0, convertDMapError(err)
+	}
+	return oldest, newest, nil
+}
+
+// ExpiringSoon returns up to limit keys in the given DMap whose remaining TTL is
+// below within, the soonest expiring keys first. Zero limit means no limit. Every
+// member scans all of its primary copies, so the cost is O(N) in the number of keys.
+func (e *EmbeddedClient) ExpiringSoon(ctx context.Context, dmap string, within time.Duration, limit int) ([]KeyTTL, error) {
+	dm, err := e.db.dmap.NewDMap(dmap)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	keys, err := dm.ExpiringSoon(ctx, within, limit)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	var result []KeyTTL
+	for _, k := range keys {
+		result = append(result, KeyTTL{Key: k.Key, TTL: k.TTL})
+	}
+	return result, nil
+}
+
+// CapTTL sets the TTL of the keys in the given DMap without a TTL or with a TTL longer
+// than maxTTL to maxTTL, and returns the number of the adjusted keys. Every member
+// scans all of its primary copies, so the cost is O(N) in the number of keys.
+func (e *EmbeddedClient) CapTTL(ctx context.Context, dmap string, maxTTL time.Duration) (int, error) {
+	dm, err := e.db.dmap.NewDMap(dmap)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	count, err := dm.CapTTL(ctx, maxTTL)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return count, nil
+}
+
+// HotKeys returns the n most accessed keys in the given DMap with their approximate
+// access counts, the most accessed key first. The counts decay over time, so they
+// reflect the recent reads. It requires the access counter of the DMap to be enabled,
+// otherwise it returns ErrAccessCounterDisabled.
+func (e *EmbeddedClient) HotKeys(ctx context.Context, dmap string, n int) ([]KeyAccess, error) {
+	dm, err := e.db.dmap.NewDMap(dmap)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	keys, err := dm.HotKeys(ctx, n)
+	if err != nil {
+		return nil, convertDMapError(err)
+	}
+	var result []KeyAccess
+	for _, k := range keys {
+		result = append(result, KeyAccess{Key: k.Key, Count: k.Count})
+	}
+	return result, nil
+}
+
+// Diff compares two DMaps by the keys and the hashes of the values. It returns the keys
+// that exist only in dmapA, the keys that exist only in dmapB and the keys that exist
+// in both with different values. Only the hashes of the values are transferred, but
+// every member walks its primary copies of both DMaps, so the cost is O(N) in the
+// number of keys.
+func (e *EmbeddedClient) Diff(ctx context.Context, dmapA, dmapB string) (onlyA, onlyB, valueDiffers []string, err error) {
+	onlyA, onlyB, valueDiffers, err = e.db.dmap.Diff(ctx, dmapA, dmapB)
+	if err != nil {
+		return nil, nil, nil, convertDMapError(err)
+	}
+	return onlyA, onlyB, valueDiffers, nil
+}
+
+// SyncReplica replaces the replica of the DMap's fragment on the given partition and
+// member with a full copy of the primary fragment. It's useful to repair a stale
+// replica without waiting for read-repair. It returns the number of bytes transferred.
+// A member runs one sync per config.DMaps.ReplicaSyncInterval, it returns
+// ErrReplicaSyncRateLimited otherwise.
+func (e *EmbeddedClient) SyncReplica(ctx context.Context, dmap string, partID uint64, member string) (int64, error) {
+	n, err := e.db.dmap.SyncReplica(ctx, dmap, partID, member)
+	if err != nil {
+		return 0, convertDMapError(err)
+	}
+	return n, nil
+}
+
+// ExportMatching writes the entries of the DMap whose keys match the pattern to w and
+// returns the number of exported entries. The keys are matched on the partition owners
+// and the entries are streamed, so the memory use doesn't depend on the number of
+// matches. The records are msgpack-encoded and can be loaded with Import. See
+// https://pkg.go.dev/regexp for the pattern syntax.
+func (e *EmbeddedClient) ExportMatching(ctx context.Context, dmap, pattern string, w io.Writer) (int, error) {
+	dm, err := e.NewDMap(dmap)
+	if err != nil {
+		return 0, err
+	}
+	return exportMatching(ctx, dm, pattern, w)
+}
+
+// Import reads the records written by ExportMatching from r and puts them into the DMap.
+// The expiry times are preserved, the expired records are skipped. It returns the number
+// of imported entries.
+func (e *EmbeddedClient) Import(ctx context.Context, dmap string, r io.Reader) (int, error) {
+	dm, err := e.NewDMap(dmap)
+	if err != nil {
+		return 0, err
+	}
+	return importRecords(ctx, dm, r)
+}
+
+// ReplayWAL applies the mutations in the write-ahead logs of the members to the
+// cluster. dirs are the config.DMaps.WALDir directories of the members, the logs are
+// merged by the times of the mutations. It's useful to reconstruct the state in a
+// fresh cluster. It returns the number of applied mutations.
+func (e *EmbeddedClient) ReplayWAL(ctx context.Context, dirs ...string) (int, error) {
+	return replayWAL(ctx, e, dirs)
+}
+
 // Members returns a thread-safe list of cluster members.
 func (e *EmbeddedClient) Members(_ context.Context) ([]Member, error) {
 	members := e.db.rt.Discovery().GetMembers()
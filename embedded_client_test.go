@@ -20,6 +20,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
@@ -368,6 +369,106 @@ func TestEmbeddedClient_DMap_Lock(t *testing.T) {
 
 	lx, err := dm.Lock(ctx, key, time.Second)
 	require.NoError(t, err)
+	require.NotZero(t, lx.Token())
+
+	err = lx.Unlock(ctx)
+	require.NoError(t, err)
+}
+
+func TestEmbeddedClient_DMap_Lock_TTLDuration(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.TTLDuration = time.Hour
+	db := cluster.addMemberWithConfig(t, c)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := "lock.key.test"
+
+	var first uint64
+	for i := 0; i < 3; i++ {
+		lx, err := dm.Lock(ctx, key, time.Second)
+		require.NoError(t, err)
+		if i == 0 {
+			first = lx.Token()
+		}
+		require.Equal(t, first+uint64(i), lx.Token())
+		require.NoError(t, lx.Unlock(ctx))
+	}
+}
+
+func TestEmbeddedClient_StreamChanges(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	c := testutil.NewConfig()
+	c.DMaps.MaxDMaps = 1
+	db := cluster.addMemberWithConfig(t, c)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	changes, cancel := e.StreamChanges("mydmap", time.Now().UnixNano())
+	defer cancel()
+
+	ctx := context.Background()
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue"))
+
+	// The put may be replayed from the storage and delivered again from the change log.
+	for _, expected := range []ChangeType{ChangePut, ChangeDelete} {
+		for {
+			var change Change
+			select {
+			case change = <-changes:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("No change received: %s", expected)
+			}
+			require.Equal(t, "mykey", change.Key)
+			if change.Type == expected {
+				break
+			}
+			require.Equal(t, ChangePut, change.Type)
+		}
+
+		if expected == ChangePut {
+			_, err = dm.Delete(ctx, "mykey")
+			require.NoError(t, err)
+		}
+	}
+
+	t.Run("DMap cannot be created", func(t *testing.T) {
+		changes, cancel := e.StreamChanges("another-dmap", 0)
+		defer cancel()
+
+		_, ok := <-changes
+		require.False(t, ok)
+	})
+}
+
+func TestEmbeddedClient_DMap_LockWithOptions(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := "lock.key.test"
+
+	lx, err := dm.LockWithOptions(ctx, key, LockOptions{
+		Deadline:     time.Second,
+		Lease:        time.Minute,
+		PollInterval: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	cctx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	_, err = dm.LockWithOptions(cctx, key, LockOptions{Deadline: 10 * time.Second})
+	require.ErrorIs(t, err, context.Canceled)
 
 	err = lx.Unlock(ctx)
 	require.NoError(t, err)
@@ -570,6 +671,27 @@ func TestEmbeddedClient_RoutingTable_Cluster(t *testing.T) {
 	require.Len(t, owners, 3)
 }
 
+func TestEmbeddedClient_KeyPlacement(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t) // Cluster coordinator
+	<-time.After(250 * time.Millisecond)
+
+	db2 := cluster.addMember(t)
+
+	ctx := context.Background()
+	kp1, err := db1.NewEmbeddedClient().KeyPlacement(ctx, "mydmap", "mykey")
+	require.NoError(t, err)
+
+	// The non-coordinator member redirects the request to the coordinator.
+	kp2, err := db2.NewEmbeddedClient().KeyPlacement(ctx, "mydmap", "mykey")
+	require.NoError(t, err)
+	require.Equal(t, kp1, kp2)
+
+	owner := db1.primary.PartitionByID(kp1.PartID).Owner()
+	require.Equal(t, owner.String(), kp1.PrimaryOwner)
+	require.Empty(t, kp1.ReplicaOwners)
+}
+
 func TestEmbeddedClient_Member(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	db := cluster.addMember(t)
@@ -614,3 +736,28 @@ func TestEmbeddedClient_Ping_WithMessage(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, message, response)
 }
+
+func TestEmbeddedClient_ReconfigureFragment(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db := cluster.addMember(t)
+
+	e := db.NewEmbeddedClient()
+	dm, err := e.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	key := testutil.ToKey(1)
+	require.NoError(t, dm.Put(ctx, key, "value"))
+
+	partID := db.primary.PartitionIDByHKey(partitions.HKey("mydmap", key))
+	err = e.ReconfigureFragment("mydmap", partID, false, map[string]interface{}{
+		"tableSize": 1 << 12,
+	})
+	require.NoError(t, err)
+
+	gr, err := dm.Get(ctx, key)
+	require.NoError(t, err)
+	value, err := gr.String()
+	require.NoError(t, err)
+	require.Equal(t, "value", value)
+}
@@ -0,0 +1,99 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// exportRecord is the portable form of an entry written by ExportMatching. The records
+// are msgpack-encoded and written back to back. TTL is the expiry time in milliseconds
+// since the Unix epoch, zero means no expiry.
+type exportRecord struct {
+	Key   string `msgpack:"key"`
+	Value []byte `msgpack:"value"`
+	TTL   int64  `msgpack:"ttl"`
+}
+
+// exportMatching scans the DMap for the keys matching the pattern and writes a record
+// for each of them. Only a single batch of keys is held in memory at a time.
+func exportMatching(ctx context.Context, dm DMap, pattern string, w io.Writer) (int, error) {
+	it, err := dm.Scan(ctx, Match(pattern))
+	if err != nil {
+		return 0, err
+	}
+	defer it.Close()
+
+	enc := msgpack.NewEncoder(w)
+	var count int
+	for it.Next() {
+		gr, err := dm.Get(ctx, it.Key())
+		if errors.Is(err, ErrKeyNotFound) {
+			// Deleted or expired after the scan.
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		value, err := gr.Byte()
+		if err != nil {
+			return count, err
+		}
+		err = enc.Encode(&exportRecord{
+			Key:   it.Key(),
+			Value: value,
+			TTL:   gr.TTL(),
+		})
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// importRecords reads the records written by exportMatching and puts them into the DMap.
+// The records whose TTL has passed are skipped.
+func importRecords(ctx context.Context, dm DMap, r io.Reader) (int, error) {
+	dec := msgpack.NewDecoder(r)
+	var count int
+	for {
+		var record exportRecord
+		err := dec.Decode(&record)
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		var options []PutOption
+		if record.TTL != 0 {
+			if record.TTL <= time.Now().UnixNano()/1000000 {
+				continue
+			}
+			options = append(options, PXAT(time.Duration(record.TTL)*time.Millisecond))
+		}
+		if err = dm.Put(ctx, record.Key, record.Value, options...); err != nil {
+			return count, err
+		}
+		count++
+	}
+}
@@ -195,3 +195,12 @@ func (g *GetResponse) TTL() int64 {
 func (g *GetResponse) Timestamp() int64 {
 	return g.entry.Timestamp()
 }
+
+// Version returns the version of the key written by PutWithVersion. It's zero if the key
+// is written without a version or the storage engine doesn't keep versions.
+func (g *GetResponse) Version() int64 {
+	if ve, ok := g.entry.(storage.VersionedEntry); ok {
+		return ve.Version()
+	}
+	return 0
+}
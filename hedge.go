@@ -0,0 +1,110 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+)
+
+// WithHedging enables hedged reads for Get. If the primary owner doesn't respond within delay,
+// ClusterClient sends the same request to a replica owner and uses the first successful response.
+// A new hedge is sent after every delay until maxHedges requests are sent to the replicas. The
+// slower requests are cancelled. Hedging requires ReplicaCount > 1 and the replicas may serve stale
+// data, especially in async replication mode. It's disabled if maxHedges is zero, the default.
+func WithHedging(delay time.Duration, maxHedges int) ClusterClientOption {
+	return func(cfg *clusterClientConfig) {
+		cfg.hedgeDelay = delay
+		cfg.maxHedges = maxHedges
+	}
+}
+
+type hedgeResult struct {
+	gr      *GetResponse
+	err     error
+	primary bool
+}
+
+// hedgedGet sends the Get request to the primary owner and, if it's slow or fails, to the replica owners.
+func (dm *ClusterDMap) hedgedGet(ctx context.Context, key string) (*GetResponse, error) {
+	partID := partitions.HKey(dm.name, key) % dm.clusterClient.partitionCount
+	route, err := dm.clusterClient.routeByPartID(partID)
+	if err != nil {
+		return nil, err
+	}
+	replicas := route.ReplicaOwners
+	if len(replicas) > dm.clusterClient.config.maxHedges {
+		replicas = replicas[:dm.clusterClient.config.maxHedges]
+	}
+
+	// Cancels the requests that are still in flight when hedgedGet returns.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan hedgeResult, len(replicas)+1)
+	primaryOwner := route.PrimaryOwners[len(route.PrimaryOwners)-1]
+	go func() {
+		gr, err := dm.getFromPrimary(ctx, dm.client.Get(primaryOwner), key)
+		results <- hedgeResult{gr: gr, err: err, primary: true}
+	}()
+	inflight := 1
+
+	hedge := func() {
+		addr := replicas[0]
+		replicas = replicas[1:]
+		inflight++
+		go func() {
+			gr, err := dm.getFromReplica(ctx, addr, key)
+			results <- hedgeResult{gr: gr, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(dm.clusterClient.config.hedgeDelay)
+	defer timer.Stop()
+
+	var primaryErr, lastErr error
+	for {
+		select {
+		case <-timer.C:
+			if len(replicas) > 0 {
+				hedge()
+				timer.Reset(dm.clusterClient.config.hedgeDelay)
+			}
+		case res := <-results:
+			inflight--
+			// The primary owner is authoritative if the key doesn't exist, a replica may lag behind it.
+			if res.err == nil || (res.primary && res.err == ErrKeyNotFound) {
+				return res.gr, res.err
+			}
+			if res.primary {
+				primaryErr = res.err
+			}
+			lastErr = res.err
+			if len(replicas) > 0 {
+				// Don't wait for the delay, a request has already failed.
+				hedge()
+				continue
+			}
+			if inflight == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, lastErr
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// slowGetHook delays the Get requests sent to the primary owners.
+type slowGetHook struct {
+	delay     time.Duration
+	cancelled int32
+}
+
+func (h *slowGetHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (h *slowGetHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func (h *slowGetHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == protocol.DMap.Get {
+			select {
+			case <-time.After(h.delay):
+			case <-ctx.Done():
+				atomic.AddInt32(&h.cancelled, 1)
+				return ctx.Err()
+			}
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func TestHedging_SlowPrimary(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	db := cluster.addMemberWithConfig(t, c1)
+
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	cluster.addMemberWithConfig(t, c2)
+
+	ctx := context.Background()
+	newClient := func(options ...ClusterClientOption) (*ClusterClient, *slowGetHook) {
+		c, err := NewClusterClient([]string{db.name}, options...)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			require.NoError(t, c.Close(ctx))
+		})
+		require.NoError(t, c.RefreshMetadata(ctx))
+
+		rt, err := c.RoutingTable(ctx)
+		require.NoError(t, err)
+		hook := &slowGetHook{delay: 20 * time.Millisecond}
+		hooked := make(map[string]struct{})
+		for _, route := range rt {
+			for _, addr := range append(route.PrimaryOwners, route.ReplicaOwners...) {
+				if _, ok := hooked[addr]; !ok {
+					c.client.Get(addr).AddHook(hook)
+					hooked[addr] = struct{}{}
+				}
+			}
+		}
+		return c, hook
+	}
+
+	// p99 runs the Get requests and returns the 99th percentile of the response times.
+	p99 := func(c *ClusterClient) time.Duration {
+		dm, err := c.NewDMap("mydmap")
+		require.NoError(t, err)
+
+		var latencies []time.Duration
+		for i := 0; i < 50; i++ {
+			start := time.Now()
+			gr, err := dm.Get(ctx, testutil.ToKey(i))
+			latencies = append(latencies, time.Since(start))
+			require.NoError(t, err)
+			value, err := gr.Byte()
+			require.NoError(t, err)
+			require.Equal(t, testutil.ToVal(i), value)
+		}
+		sort.Slice(latencies, func(i, j int) bool {
+			return latencies[i] < latencies[j]
+		})
+		return latencies[len(latencies)*99/100]
+	}
+
+	plain, _ := newClient()
+	dm, err := plain.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 50; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i)))
+	}
+
+	hedged, hook := newClient(WithHedging(2*time.Millisecond, 1))
+
+	withoutHedging := p99(plain)
+	withHedging := p99(hedged)
+	require.GreaterOrEqual(t, withoutHedging, hook.delay)
+	require.Less(t, withHedging, hook.delay)
+
+	// The replicas won, the requests sent to the primary owners are cancelled.
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&hook.cancelled) == 50
+	}, time.Second, time.Millisecond)
+}
@@ -26,9 +26,14 @@ import (
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/environment"
 	"github.com/buraksezer/olric/internal/service"
+	"github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/pkg/flog"
+	"golang.org/x/sync/semaphore"
 )
 
+// ActiveMoves is the number of fragment moves in progress on this member.
+var ActiveMoves = stats.NewInt64Gauge()
+
 type Balancer struct {
 	sync.Mutex
 
@@ -37,6 +42,9 @@ type Balancer struct {
 	primary *partitions.Partitions
 	backup  *partitions.Partitions
 	rt      *routingtable.RoutingTable
+	// moveSem bounds the number of concurrent fragment moves.
+	moveSem *semaphore.Weighted
+	moves   sync.WaitGroup
 	wg      sync.WaitGroup
 	ctx     context.Context
 	cancel  context.CancelFunc
@@ -51,6 +59,7 @@ func New(e *environment.Environment) *Balancer {
 		primary: e.Get("primary").(*partitions.Partitions),
 		backup:  e.Get("backup").(*partitions.Partitions),
 		rt:      e.Get("routingtable").(*routingtable.RoutingTable),
+		moveSem: semaphore.NewWeighted(int64(c.MaxConcurrentMoves)),
 		log:     log,
 		ctx:     ctx,
 		cancel:  cancel,
@@ -83,14 +92,31 @@ func (b *Balancer) scanPartition(sign uint64, part *partitions.Partition, owners
 		}
 		name := strings.TrimPrefix(rawName.(string), "dmap.")
 
+		// Wait for a free slot, the moves are queued if MaxConcurrentMoves moves are in progress.
+		if err := b.moveSem.Acquire(b.ctx, 1); err != nil {
+			return false
+		}
+		if b.breakLoop(sign) {
+			b.moveSem.Release(1)
+			return false
+		}
+
 		b.log.V(2).Printf("[INFO] Moving %s fragment: %s (kind: %s) on PartID: %d to %s",
 			f.Name(), name, part.Kind(), part.ID(), ownersStr)
 
-		err := f.Move(part, name, owners)
-		if err != nil {
-			b.log.V(2).Printf("[ERROR] Failed to move %s fragment: %s on PartID: %d to %s: %v",
-				f.Name(), name, part.ID(), ownersStr, err)
-		}
+		ActiveMoves.Increase(1)
+		b.moves.Add(1)
+		go func() {
+			defer b.moves.Done()
+			defer b.moveSem.Release(1)
+			defer ActiveMoves.Decrease(1)
+
+			err := f.Move(part, name, owners)
+			if err != nil {
+				b.log.V(2).Printf("[ERROR] Failed to move %s fragment: %s on PartID: %d to %s: %v",
+					f.Name(), name, part.ID(), ownersStr, err)
+			}
+		}()
 
 		// if this returns true, the iteration continues
 		return !b.breakLoop(sign)
@@ -198,6 +224,9 @@ func (b *Balancer) triggerBalancer() {
 	if b.config.ReplicaCount > config.MinimumReplicaCount {
 		b.backupCopies()
 	}
+
+	// Wait for the moves in progress. The next call works on the latest state.
+	b.moves.Wait()
 }
 
 func (b *Balancer) BalanceEagerly() {
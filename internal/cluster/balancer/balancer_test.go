@@ -21,6 +21,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -190,6 +191,88 @@ func TestBalance_Primary_Move(t *testing.T) {
 	}
 }
 
+// concurrencyFragment records the maximum number of concurrent moves.
+type concurrencyFragment struct {
+	*mockfragment.MockFragment
+	active    *int64
+	maxActive *int64
+}
+
+func (f *concurrencyFragment) Move(part *partitions.Partition, name string, owners []discovery.Member) error {
+	n := atomic.AddInt64(f.active, 1)
+	defer atomic.AddInt64(f.active, -1)
+	for {
+		current := atomic.LoadInt64(f.maxActive)
+		if n <= current || atomic.CompareAndSwapInt64(f.maxActive, current, n) {
+			break
+		}
+	}
+	<-time.After(5 * time.Millisecond)
+	return f.MockFragment.Move(part, name, owners)
+}
+
+func TestBalance_MaxConcurrentMoves(t *testing.T) {
+	cluster := newMockCluster(t)
+	defer cluster.shutdown()
+
+	e1 := newTestEnvironment(nil)
+	c := e1.Get("config").(*config.Config)
+	c.MaxConcurrentMoves = 1
+	cluster.addNode(e1)
+
+	var active, maxActive int64
+	var fragments []*concurrencyFragment
+	part := e1.Get(strings.ToLower(partitions.PRIMARY.String())).(*partitions.Partitions)
+	for partID := uint64(0); partID < c.PartitionCount; partID++ {
+		part := part.PartitionByID(partID)
+		for i := 0; i < 3; i++ {
+			f := &concurrencyFragment{
+				MockFragment: mockfragment.New(),
+				active:       &active,
+				maxActive:    &maxActive,
+			}
+			f.Put("key", i)
+			part.Map().Store(fmt.Sprintf("dmap.test-data-%d", i), f)
+			fragments = append(fragments, f)
+		}
+	}
+
+	e2 := newTestEnvironment(nil)
+	b2 := cluster.addNode(e2)
+
+	err := testutil.TryWithInterval(50, 100*time.Millisecond, func() error {
+		if !b2.rt.IsBootstrapped() {
+			return errors.New("the second node cannot be bootstrapped")
+		}
+		var moved int
+		for _, f := range fragments {
+			if len(f.Result()) != 0 {
+				moved++
+			}
+		}
+		if moved == 0 {
+			return errors.New("no fragment has been moved yet")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	b1Part := e1.Get(strings.ToLower(partitions.PRIMARY.String())).(*partitions.Partitions)
+	err = testutil.TryWithInterval(50, 100*time.Millisecond, func() error {
+		for partID := uint64(0); partID < c.PartitionCount; partID++ {
+			if b1Part.PartitionByID(partID).Owner().CompareByName(b2.rt.This()) &&
+				b1Part.PartitionByID(partID).Length() != 0 {
+				return fmt.Errorf("partition %d has not been moved yet", partID)
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	// The moves proceed one by one.
+	require.Equal(t, int64(1), atomic.LoadInt64(&maxActive))
+}
+
 func checkBackupOwnership(e *environment.Environment) error {
 	c := e.Get("config").(*config.Config)
 	primary := e.Get(strings.ToLower(partitions.PRIMARY.String())).(*partitions.Partitions)
@@ -15,7 +15,11 @@
 package partitions
 
 import (
+	"encoding/binary"
+	"math"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/buraksezer/olric/hasher"
@@ -24,15 +28,107 @@ import (
 var (
 	hashFunc hasher.Hasher
 	once     sync.Once
+
+	// seedPrefix is prepended to the hashed keys. It's empty if there is no seed.
+	seedPrefix atomic.Value
+
+	// hashTagPartitionCount is the partition count if the hash tags are enabled, it's zero otherwise.
+	hashTagPartitionCount uint64
 )
 
+func init() {
+	seedPrefix.Store("")
+}
+
 func SetHashFunc(h hasher.Hasher) {
 	once.Do(func() {
 		hashFunc = h
 	})
 }
 
-func HKey(name, key string) uint64 {
-	tmp := name + key
+// SetHashSeed sets the seed of HKey. All members of a cluster and the clients must use
+// the same seed, it should be set once at startup. Zero means no seed, the keys are
+// hashed as they are.
+func SetHashSeed(seed uint64) {
+	if seed == 0 {
+		seedPrefix.Store("")
+		return
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], seed)
+	seedPrefix.Store(string(buf[:]))
+}
+
+// HashSeed returns the seed of HKey.
+func HashSeed() uint64 {
+	prefix := seedPrefix.Load().(string)
+	if prefix == "" {
+		return 0
+	}
+	return binary.BigEndian.Uint64([]byte(prefix))
+}
+
+// SetHashTags enables the hash tags of HKey: only the substring inside the first {...} of
+// a key is hashed to find its partition, like the hash tags of Redis Cluster, so the keys
+// with the same tag are stored on the same partition. partitionCount has to be the
+// partition count of the cluster. Zero disables the hash tags. All members of a cluster
+// and the clients must use the same setting, it should be set once at startup.
+func SetHashTags(partitionCount uint64) {
+	atomic.StoreUint64(&hashTagPartitionCount, partitionCount)
+}
+
+// HashTagPartID returns the partition of the keys with the given hash tag in the DMap. It
+// returns false if the hash tags are disabled.
+func HashTagPartID(name, tag string) (uint64, bool) {
+	count := atomic.LoadUint64(&hashTagPartitionCount)
+	if count == 0 {
+		return 0, false
+	}
+	return sum64(name, tag) % count, true
+}
+
+// HashTag returns the substring inside the first {...} of the key. It returns false if
+// there is no tag or the tag is empty, the whole key is hashed in that case.
+func HashTag(key string) (string, bool) {
+	start := strings.IndexByte(key, '{')
+	if start == -1 {
+		return "", false
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return "", false
+	}
+	return key[start+1 : start+1+end], true
+}
+
+func sum64(name, key string) uint64 {
+	tmp := seedPrefix.Load().(string) + name + key
 	return hashFunc.Sum64(*(*[]byte)(unsafe.Pointer(&tmp)))
 }
+
+// HKey returns the hash of the key in the given DMap. The keys are distributed to the
+// partitions by this hash, the seed makes the distribution unpredictable to the ones who
+// don't know it.
+//
+// The hash also identifies the key in the storage engine, so a tagged key keeps its own
+// hash, only its remainder by the partition count is replaced by the one of the tag.
+func HKey(name, key string) uint64 {
+	hkey := sum64(name, key)
+
+	count := atomic.LoadUint64(&hashTagPartitionCount)
+	if count == 0 {
+		return hkey
+	}
+	tag, ok := HashTag(key)
+	if !ok {
+		return hkey
+	}
+
+	partID := sum64(name, tag) % count
+	base := hkey - hkey%count
+	if base > math.MaxUint64-partID {
+		// The last block of the uint64 range is not complete.
+		base -= count
+	}
+	return base + partID
+}
@@ -15,6 +15,7 @@
 package partitions
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/buraksezer/olric/hasher"
@@ -26,3 +27,73 @@ func TestPartitions_HKey(t *testing.T) {
 	hkey := HKey("storage-unit-name", "some-key")
 	require.NotEqualf(t, 0, hkey, "HKey is zero. This shouldn't be normal")
 }
+
+func TestPartitions_HKey_Seed(t *testing.T) {
+	SetHashFunc(hasher.NewDefaultHasher())
+	defer SetHashSeed(0)
+
+	const partitionCount = 271
+	assignments := func(seed uint64) []uint64 {
+		SetHashSeed(seed)
+		require.Equal(t, seed, HashSeed())
+
+		var res []uint64
+		for i := 0; i < 100; i++ {
+			res = append(res, HKey("mydmap", fmt.Sprintf("key-%d", i))%partitionCount)
+		}
+		return res
+	}
+
+	unseeded := HKey("mydmap", "key-0")
+	require.Equal(t, assignments(0), assignments(0))
+	require.Equal(t, unseeded, HKey("mydmap", "key-0"))
+	require.Equal(t, assignments(1), assignments(1))
+	require.NotEqual(t, assignments(1), assignments(2))
+	require.NotEqual(t, assignments(0), assignments(1))
+}
+
+func TestPartitions_HKey_HashTags(t *testing.T) {
+	SetHashFunc(hasher.NewDefaultHasher())
+	defer SetHashTags(0)
+
+	const partitionCount = 271
+	untagged := HKey("mydmap", "{user:42}:profile")
+
+	SetHashTags(partitionCount)
+	profile := HKey("mydmap", "{user:42}:profile")
+	session := HKey("mydmap", "{user:42}:session")
+	require.Equal(t, profile%partitionCount, session%partitionCount)
+	require.Equal(t, HKey("mydmap", "user:42")%partitionCount, profile%partitionCount)
+	// The keys are still identified by their own hashes.
+	require.NotEqual(t, profile, session)
+
+	partID, ok := HashTagPartID("mydmap", "user:42")
+	require.True(t, ok)
+	require.Equal(t, profile%partitionCount, partID)
+
+	// The keys without a tag or with an empty tag are hashed as usual.
+	require.Equal(t, HKey("mydmap", "foobar"), sum64("mydmap", "foobar"))
+	require.Equal(t, sum64("mydmap", "{}foobar"), HKey("mydmap", "{}foobar"))
+	require.Equal(t, sum64("mydmap", "{foobar"), HKey("mydmap", "{foobar"))
+
+	SetHashTags(0)
+	require.Equal(t, untagged, HKey("mydmap", "{user:42}:profile"))
+	_, ok = HashTagPartID("mydmap", "user:42")
+	require.False(t, ok)
+}
+
+func TestPartitions_HashTag(t *testing.T) {
+	tests := map[string]string{
+		"{user:42}:profile": "user:42",
+		"foo{bar}{baz}":     "bar",
+		"foo{}{bar}":        "",
+		"foo{bar":           "",
+		"foo}bar{":          "",
+		"foobar":            "",
+	}
+	for key, expected := range tests {
+		tag, ok := HashTag(key)
+		require.Equal(t, expected != "", ok, key)
+		require.Equal(t, expected, tag, key)
+	}
+}
@@ -18,6 +18,8 @@ import (
 	"context"
 	"errors"
 	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
 )
 
 var (
@@ -62,6 +64,10 @@ func (r *RoutingTable) attemptToJoin() error {
 		}
 
 		r.log.V(2).Printf("[ERROR] Join attempt returned error: %s", err)
+		if errors.Is(err, discovery.ErrMaxClusterSize) {
+			// The cluster is full. Don't retry and don't form a new cluster.
+			return err
+		}
 		if r.IsBootstrapped() {
 			r.log.V(2).Printf("[INFO] Bootstrapped by the cluster coordinator")
 			return nil
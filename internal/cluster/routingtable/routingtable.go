@@ -114,6 +114,9 @@ func New(e *environment.Environment) *RoutingTable {
 		ctx:        ctx,
 		cancel:     cancel,
 	}
+	// The members and the hash seed of the partitions are in the same process, so it's
+	// enough to set the seed when it's generated or learned from the other members.
+	rt.discovery.SetHashSeedHandler(partitions.SetHashSeed)
 	registerErrors()
 	rt.RegisterHandlers()
 	return rt
@@ -361,6 +364,15 @@ func (r *RoutingTable) Join() error {
 		return err
 	}
 
+	if r.discovery.NumMembers() == 1 {
+		// This member forms a new cluster, it generates the hash seed if it's not configured.
+		// A member that joins a cluster without a seed keeps using zero.
+		err = r.discovery.GenerateHashSeed()
+		if err != nil {
+			return err
+		}
+	}
+
 	this, err := r.discovery.FindMemberByName(r.config.MemberlistConfig.Name)
 	if err != nil {
 		r.log.V(2).Printf("[ERROR] Failed to get this node in cluster: %v", err)
@@ -0,0 +1,113 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"github.com/hashicorp/memberlist"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// The first byte of a user message denotes its type. The messages sent by Broadcast
+// are passed to the MessageHandler, the others are used by the discovery itself.
+const (
+	messageTypeUser byte = iota + 1
+	messageTypeAdmissionRequest
+	messageTypeAdmissionVerdict
+)
+
+func encodeMessage(kind byte, value interface{}) ([]byte, error) {
+	data, err := msgpack.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{kind}, data...), nil
+}
+
+// MessageHandler is called with the user messages received by the gossip protocol.
+type MessageHandler func(msg []byte)
+
+// broadcast is a user message that is piggybacked on the gossip messages. It implements
+// memberlist.NamedBroadcast, so a new message replaces the queued one with the same name.
+type broadcast struct {
+	name string
+	msg  []byte
+}
+
+func (b *broadcast) Invalidates(_ memberlist.Broadcast) bool {
+	return false
+}
+
+func (b *broadcast) Name() string {
+	return b.name
+}
+
+func (b *broadcast) Message() []byte {
+	return b.msg
+}
+
+func (b *broadcast) Finished() {}
+
+// Broadcast disseminates the message to all members by piggybacking it on the gossip messages.
+// The delivery is best-effort and eventually consistent: a queued message is replaced by a newer
+// one with the same name, and a message that doesn't fit into a gossip packet is never sent.
+// It must be called after Start.
+func (d *Discovery) Broadcast(name string, msg []byte) {
+	d.broadcasts.QueueBroadcast(&broadcast{
+		name: name,
+		msg:  append([]byte{messageTypeUser}, msg...),
+	})
+}
+
+// SetMessageHandler sets the function that is called with the messages sent by Broadcast
+// on the other members.
+func (d *Discovery) SetMessageHandler(h MessageHandler) {
+	d.messageHandler.Store(h)
+}
+
+func (d *Discovery) notifyMsg(data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	kind, data := data[0], data[1:]
+	switch kind {
+	case messageTypeUser:
+	case messageTypeAdmissionRequest:
+		if d.sizeGuard != nil {
+			// Answering blocks on the network, memberlist waits for NotifyMsg to return
+			// and reuses the buffer.
+			req := make([]byte, len(data))
+			copy(req, data)
+			d.wg.Add(1)
+			go d.handleAdmissionRequest(req)
+		}
+		return
+	case messageTypeAdmissionVerdict:
+		if d.sizeGuard != nil {
+			d.handleAdmissionVerdict(data)
+		}
+		return
+	default:
+		return
+	}
+
+	h, ok := d.messageHandler.Load().(MessageHandler)
+	if !ok || len(data) == 0 {
+		return
+	}
+	// memberlist reuses the buffer after NotifyMsg returns.
+	msg := make([]byte, len(data))
+	copy(msg, data)
+	h(msg)
+}
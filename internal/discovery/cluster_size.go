@@ -0,0 +1,374 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/memberlist"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrMaxClusterSize is returned when a join attempt is rejected because the
+// cluster has already reached config.MaxClusterSize.
+var ErrMaxClusterSize = errors.New("maximum cluster size reached")
+
+var (
+	// errNotAdmitted is returned for a member that has not been admitted by the coordinator.
+	errNotAdmitted = errors.New("member is not admitted by the cluster coordinator")
+
+	// errNotCoordinator is returned when the admission request reaches a member that
+	// is not the coordinator anymore. The join is retried.
+	errNotCoordinator = errors.New("member is not the cluster coordinator")
+
+	// errAdmissionTimeout is returned when the coordinator doesn't answer the admission
+	// request in time. The join is retried.
+	errAdmissionTimeout = errors.New("admission request timed out")
+)
+
+// admissionGrantTTL is the time the coordinator counts an admitted member that has not
+// appeared in its member list yet.
+const admissionGrantTTL = time.Minute
+
+const (
+	admissionGranted uint8 = iota + 1
+	admissionRejected
+	admissionNotCoordinator
+)
+
+// admissionMessage is sent by a joining member to the coordinator and back.
+type admissionMessage struct {
+	Name    string
+	Addr    string
+	Port    uint16
+	Verdict uint8
+}
+
+// clusterSizeGuard enforces config.MaxClusterSize. It implements
+// memberlist.MergeDelegate, memberlist.AliveDelegate and memberlist.EventDelegate.
+//
+// The cluster coordinator, the oldest member, is the single authority that admits
+// the joining members. A joining member merges the state of the cluster, asks the
+// coordinator for admission and announces it in its metadata. The coordinator
+// counts its members and the admitted members that have not appeared yet, and
+// serializes the admissions, so two concurrent joins cannot both take the last
+// slot. The other members ignore the nodes that are not admitted, so a rejected
+// member never appears in the cluster.
+type clusterSizeGuard struct {
+	mtx      sync.Mutex
+	limit    int
+	self     string
+	joining  bool
+	admitted bool
+	members  map[string]Member
+	granted  map[string]time.Time
+	verdicts chan uint8
+	events   memberlist.EventDelegate
+}
+
+func newClusterSizeGuard(self string, limit int, events memberlist.EventDelegate) *clusterSizeGuard {
+	return &clusterSizeGuard{
+		limit:    limit,
+		self:     self,
+		members:  make(map[string]Member),
+		granted:  make(map[string]time.Time),
+		verdicts: make(chan uint8, 1),
+		events:   events,
+	}
+}
+
+func (g *clusterSizeGuard) errMaxClusterSize() error {
+	return fmt.Errorf("%w: %d", ErrMaxClusterSize, g.limit)
+}
+
+func (g *clusterSizeGuard) setJoining(joining bool) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.joining = joining
+}
+
+func (g *clusterSizeGuard) isAdmitted() bool {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.admitted
+}
+
+func (g *clusterSizeGuard) setAdmitted() {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.admitted = true
+}
+
+// coordinator returns the oldest known member.
+func (g *clusterSizeGuard) coordinator() Member {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return g.coordinatorLocked()
+}
+
+func (g *clusterSizeGuard) coordinatorLocked() Member {
+	var coordinator Member
+	for _, member := range g.members {
+		if coordinator.Name == "" || member.Birthdate < coordinator.Birthdate {
+			coordinator = member
+		}
+	}
+	return coordinator
+}
+
+// checkPeer returns nil if a node that is not known yet can be added to the local view.
+// A joining member learns the cluster from the push/pull state, the others only accept
+// the members admitted by the coordinator.
+func (g *clusterSizeGuard) checkPeer(peer *memberlist.Node) error {
+	if g.joining {
+		return nil
+	}
+	meta, err := decodeMetadata(peer.Meta)
+	if err != nil {
+		return err
+	}
+	if !meta.Admitted {
+		return fmt.Errorf("%w: %s", errNotAdmitted, peer.Name)
+	}
+	return nil
+}
+
+// NotifyMerge is invoked on both sides of a join, before the remote state is merged.
+func (g *clusterSizeGuard) NotifyMerge(peers []*memberlist.Node) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	total := len(g.members)
+	for _, peer := range peers {
+		if peer.State != memberlist.StateAlive {
+			continue
+		}
+		if _, ok := g.members[peer.Name]; ok {
+			continue
+		}
+		if err := g.checkPeer(peer); err != nil {
+			return err
+		}
+		total++
+	}
+	if total > g.limit {
+		return g.errMaxClusterSize()
+	}
+	return nil
+}
+
+// NotifyAlive is invoked for every alive message. Messages about the known nodes are
+// always accepted, an unknown node is accepted if it's admitted by the coordinator.
+func (g *clusterSizeGuard) NotifyAlive(peer *memberlist.Node) error {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if _, ok := g.members[peer.Name]; ok || peer.Name == g.self {
+		return nil
+	}
+	if g.joining && len(g.members) >= g.limit {
+		return g.errMaxClusterSize()
+	}
+	return g.checkPeer(peer)
+}
+
+// admit decides on an admission request. It's only granted by the coordinator.
+func (g *clusterSizeGuard) admit(name string) uint8 {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	if g.coordinatorLocked().Name != g.self {
+		return admissionNotCoordinator
+	}
+
+	now := time.Now()
+	for member, grantedAt := range g.granted {
+		if now.Sub(grantedAt) > admissionGrantTTL {
+			delete(g.granted, member)
+		}
+	}
+
+	total := len(g.members) + len(g.granted)
+	if _, ok := g.granted[name]; !ok {
+		if _, ok = g.members[name]; !ok {
+			total++
+		}
+	}
+	if total > g.limit {
+		return admissionRejected
+	}
+	if _, ok := g.members[name]; !ok {
+		g.granted[name] = now
+	}
+	// The coordinator has admitted itself by admitting the others.
+	g.admitted = true
+	return admissionGranted
+}
+
+// NotifyJoin is invoked when a node is detected to have joined.
+func (g *clusterSizeGuard) NotifyJoin(node *memberlist.Node) {
+	member, err := NewMemberFromMetadata(node.Meta)
+	if err == nil {
+		g.mtx.Lock()
+		g.members[node.Name] = member
+		delete(g.granted, node.Name)
+		g.mtx.Unlock()
+	}
+
+	g.events.NotifyJoin(node)
+}
+
+// NotifyLeave is invoked when a node is detected to have left.
+func (g *clusterSizeGuard) NotifyLeave(node *memberlist.Node) {
+	g.mtx.Lock()
+	delete(g.members, node.Name)
+	g.mtx.Unlock()
+
+	g.events.NotifyLeave(node)
+}
+
+// NotifyUpdate is invoked when a node is detected to have updated, usually involving the meta data.
+func (g *clusterSizeGuard) NotifyUpdate(node *memberlist.Node) {
+	g.events.NotifyUpdate(node)
+}
+
+// admitLocalMember asks the coordinator to admit the local member after a join, and
+// announces the admission with the metadata of the member. The oldest member admits
+// itself.
+func (d *Discovery) admitLocalMember() error {
+	g := d.sizeGuard
+	if g.isAdmitted() {
+		return nil
+	}
+
+	coordinator := g.coordinator()
+	if coordinator.Name == d.member.Name {
+		if g.admit(d.member.Name) != admissionGranted {
+			return g.errMaxClusterSize()
+		}
+		return d.announceAdmission()
+	}
+
+	var node *memberlist.Node
+	for _, member := range d.memberlist.Members() {
+		if member.Name == coordinator.Name {
+			node = member
+			break
+		}
+	}
+	if node == nil {
+		return fmt.Errorf("%w: %s", ErrMemberNotFound, coordinator)
+	}
+
+	local := d.memberlist.LocalNode()
+	msg, err := encodeMessage(messageTypeAdmissionRequest, admissionMessage{
+		Name: local.Name,
+		Addr: local.Addr.String(),
+		Port: local.Port,
+	})
+	if err != nil {
+		return err
+	}
+
+	// Drop the verdict of a timed out request.
+	select {
+	case <-g.verdicts:
+	default:
+	}
+	if err = d.memberlist.SendReliable(node, msg); err != nil {
+		return err
+	}
+
+	select {
+	case verdict := <-g.verdicts:
+		switch verdict {
+		case admissionGranted:
+			g.setAdmitted()
+			return d.announceAdmission()
+		case admissionRejected:
+			return g.errMaxClusterSize()
+		default:
+			return fmt.Errorf("%w: %s", errNotCoordinator, coordinator)
+		}
+	case <-time.After(d.config.MemberlistConfig.TCPTimeout):
+		return fmt.Errorf("%w: %s", errAdmissionTimeout, coordinator)
+	case <-d.ctx.Done():
+		return d.ctx.Err()
+	}
+}
+
+// announceAdmission gossips the metadata of the local member, so the other members
+// start accepting it.
+func (d *Discovery) announceAdmission() error {
+	return d.memberlist.UpdateNode(d.config.MemberlistConfig.TCPTimeout)
+}
+
+// handleAdmissionRequest runs on the coordinator and sends the verdict back to the
+// joining member. The joining member is not a part of the local view yet, so it's
+// addressed directly.
+func (d *Discovery) handleAdmissionRequest(data []byte) {
+	defer d.wg.Done()
+
+	req := admissionMessage{}
+	if err := msgpack.Unmarshal(data, &req); err != nil {
+		d.log.V(2).Printf("[ERROR] Failed to decode the admission request: %v", err)
+		return
+	}
+
+	wasAdmitted := d.sizeGuard.isAdmitted()
+	verdict := d.sizeGuard.admit(req.Name)
+	if verdict == admissionRejected {
+		d.log.V(2).Printf("[WARN] Rejected %s: %v", req.Name, d.sizeGuard.errMaxClusterSize())
+	}
+	if verdict == admissionGranted && !wasAdmitted {
+		if err := d.announceAdmission(); err != nil {
+			d.log.V(2).Printf("[ERROR] Failed to announce the admission of the coordinator: %v", err)
+		}
+	}
+
+	msg, err := encodeMessage(messageTypeAdmissionVerdict, admissionMessage{
+		Name:    d.member.Name,
+		Verdict: verdict,
+	})
+	if err != nil {
+		d.log.V(2).Printf("[ERROR] Failed to encode the admission verdict: %v", err)
+		return
+	}
+	node := &memberlist.Node{
+		Name: req.Name,
+		Addr: net.ParseIP(req.Addr),
+		Port: req.Port,
+	}
+	if err = d.memberlist.SendReliable(node, msg); err != nil {
+		d.log.V(2).Printf("[ERROR] Failed to send the admission verdict to %s: %v", req.Name, err)
+	}
+}
+
+// handleAdmissionVerdict passes the verdict of the coordinator to the ongoing join.
+func (d *Discovery) handleAdmissionVerdict(data []byte) {
+	verdict := admissionMessage{}
+	if err := msgpack.Unmarshal(data, &verdict); err != nil {
+		d.log.V(2).Printf("[ERROR] Failed to decode the admission verdict: %v", err)
+		return
+	}
+	select {
+	case d.sizeGuard.verdicts <- verdict.Verdict:
+	default:
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscovery_MaxClusterSize(t *testing.T) {
+	c := newTestCluster(t)
+	newConfig := func() *config.Config {
+		cfg := testutil.NewConfig()
+		cfg.MaxClusterSize = 2
+		c.mtx.RLock()
+		cfg.Peers = append(cfg.Peers, c.members...)
+		c.mtx.RUnlock()
+		return cfg
+	}
+
+	d1 := c.addNewMemberWithConfig(t, newConfig())
+	d2 := c.addNewMemberWithConfig(t, newConfig())
+
+	// The join is rejected whichever member it contacts.
+	for _, peer := range newConfig().Peers {
+		cfg := newConfig()
+		cfg.Peers = []string{peer}
+		d3 := New(testutil.NewFlogger(cfg), cfg)
+		require.NoError(t, d3.Start())
+
+		_, err := d3.Join()
+		require.ErrorIs(t, err, ErrMaxClusterSize)
+		require.Equal(t, 1, d3.NumMembers())
+		require.NoError(t, d3.Shutdown())
+	}
+
+	<-time.After(250 * time.Millisecond)
+	require.Equal(t, 2, d1.NumMembers())
+	require.Equal(t, 2, d2.NumMembers())
+}
+
+func TestDiscovery_MaxClusterSize_Concurrent_Joins(t *testing.T) {
+	c := newTestCluster(t)
+	newConfig := func() *config.Config {
+		cfg := testutil.NewConfig()
+		cfg.MaxClusterSize = 3
+		c.mtx.RLock()
+		cfg.Peers = append(cfg.Peers, c.members...)
+		c.mtx.RUnlock()
+		return cfg
+	}
+
+	d1 := c.addNewMemberWithConfig(t, newConfig())
+	d2 := c.addNewMemberWithConfig(t, newConfig())
+
+	// Both nodes compete for the last slot, each one through a different member.
+	var joiners []*Discovery
+	for _, peer := range newConfig().Peers {
+		cfg := newConfig()
+		cfg.Peers = []string{peer}
+		d := New(testutil.NewFlogger(cfg), cfg)
+		require.NoError(t, d.Start())
+		t.Cleanup(func() {
+			require.NoError(t, d.Shutdown())
+		})
+		joiners = append(joiners, d)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(joiners))
+	for i, d := range joiners {
+		wg.Add(1)
+		go func(i int, d *Discovery) {
+			defer wg.Done()
+			_, errs[i] = d.Join()
+		}(i, d)
+	}
+	wg.Wait()
+
+	var admitted []*Discovery
+	for i, err := range errs {
+		if err == nil {
+			admitted = append(admitted, joiners[i])
+			continue
+		}
+		require.ErrorIs(t, err, ErrMaxClusterSize)
+	}
+	require.Len(t, admitted, 1)
+
+	require.Eventually(t, func() bool {
+		return d1.NumMembers() == 3 && d2.NumMembers() == 3 && admitted[0].NumMembers() == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	<-time.After(250 * time.Millisecond)
+	require.Equal(t, 3, d1.NumMembers())
+	require.Equal(t, 3, d2.NumMembers())
+	_, err := d1.FindMemberByName(admitted[0].member.Name)
+	require.NoError(t, err)
+	_, err = d2.FindMemberByName(admitted[0].member.Name)
+	require.NoError(t, err)
+}
@@ -14,37 +14,76 @@
 
 package discovery
 
+import (
+	"github.com/buraksezer/olric/pkg/flog"
+	"github.com/hashicorp/memberlist"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
 // delegate is a struct which implements memberlist.Delegate interface.
 type delegate struct {
-	meta []byte
+	meta       func() ([]byte, error)
+	broadcasts *memberlist.TransmitLimitedQueue
+	notify     func(msg []byte)
+	seed       *hashSeed
+	log        *flog.Logger
 }
 
 // newDelegate returns a new delegate instance.
 func (d *Discovery) newDelegate() (delegate, error) {
-	data, err := d.member.Encode()
-	if err != nil {
+	if _, err := d.encodeMember(); err != nil {
 		return delegate{}, err
 	}
 	return delegate{
-		meta: data,
+		meta:       d.encodeMember,
+		broadcasts: d.broadcasts,
+		notify:     d.notifyMsg,
+		seed:       d.hashSeed,
+		log:        d.log,
 	}, nil
 }
 
+// encodeMember encodes the metadata of this member with the current hash seed. memberlist
+// reads the metadata while creating the local node and when the admission is announced, so
+// it only carries a configured seed.
+func (d *Discovery) encodeMember() ([]byte, error) {
+	return msgpack.Marshal(metadata{
+		Member:   *d.member,
+		HashSeed: d.hashSeed.get(),
+		Admitted: d.sizeGuard != nil && d.sizeGuard.isAdmitted(),
+	})
+}
+
 // NodeMeta is used to retrieve meta-data about the current node
 // when broadcasting an alive message. It's length is limited to
 // the given byte size. This metadata is available in the Node structure.
 func (d delegate) NodeMeta(limit int) []byte {
-	return d.meta
+	data, err := d.meta()
+	if err != nil {
+		d.log.V(2).Printf("[ERROR] Failed to encode the member metadata: %v", err)
+	}
+	return data
 }
 
 // NotifyMsg is called when a user-data message is received.
-func (d delegate) NotifyMsg(data []byte) {}
+func (d delegate) NotifyMsg(data []byte) {
+	d.notify(data)
+}
 
 // GetBroadcasts is called when user data messages can be broadcast.
-func (d delegate) GetBroadcasts(overhead, limit int) [][]byte { return nil }
+func (d delegate) GetBroadcasts(overhead, limit int) [][]byte {
+	return d.broadcasts.GetBroadcasts(overhead, limit)
+}
 
-// LocalState is used for a TCP Push/Pull.
-func (d delegate) LocalState(join bool) []byte { return nil }
+// LocalState is used for a TCP Push/Pull. It sends the hash seed of the cluster.
+func (d delegate) LocalState(join bool) []byte {
+	return d.seed.encode()
+}
 
-// MergeRemoteState is invoked after a TCP Push/Pull.
-func (d delegate) MergeRemoteState(buf []byte, join bool) {}
+// MergeRemoteState is invoked after a TCP Push/Pull. A member that doesn't know the hash
+// seed learns it here, while joining or later with the periodic push/pull.
+func (d delegate) MergeRemoteState(buf []byte, join bool) {
+	if err := d.seed.merge(buf); err != nil {
+		d.log.V(1).Printf("[ERROR] Failed to merge the remote state: %v", err)
+	}
+}
@@ -23,7 +23,9 @@ import (
 	"plugin"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/buraksezer/olric/config"
@@ -63,6 +65,8 @@ type Discovery struct {
 	member     *Member
 	memberlist *memberlist.Memberlist
 	config     *config.Config
+	hashSeed   *hashSeed
+	sizeGuard  *clusterSizeGuard
 
 	// To manage Join/Leave/Update events
 	clusterEventsMtx sync.RWMutex
@@ -72,6 +76,10 @@ type Discovery struct {
 	eventSubscribers []chan *ClusterEvent
 	serviceDiscovery service_discovery.ServiceDiscovery
 
+	// User messages disseminated by the gossip protocol
+	broadcasts     *memberlist.TransmitLimitedQueue
+	messageHandler atomic.Value
+
 	// Flow control
 	wg     sync.WaitGroup
 	ctx    context.Context
@@ -83,15 +91,41 @@ func New(log *flog.Logger, c *config.Config) *Discovery {
 	member := NewMember(c)
 	ctx, cancel := context.WithCancel(context.Background())
 	d := &Discovery{
-		member: &member,
-		config: c,
-		log:    log,
-		ctx:    ctx,
-		cancel: cancel,
+		member:   &member,
+		config:   c,
+		log:      log,
+		hashSeed: &hashSeed{seed: c.HashSeed},
+		ctx:      ctx,
+		cancel:   cancel,
 	}
 	return d
 }
 
+// HashSeed returns the hash seed of the cluster. It returns zero if the seed is not known yet.
+func (d *Discovery) HashSeed() uint64 {
+	return d.hashSeed.get()
+}
+
+// SetHashSeedHandler sets a function to call when the member learns or generates the hash
+// seed of the cluster. It has to be called before Start.
+func (d *Discovery) SetHashSeedHandler(f func(seed uint64)) {
+	d.hashSeed.onChange = f
+}
+
+// GenerateHashSeed generates a random hash seed if the seed is not configured or learned
+// from the other members. It's called by the member that forms a new cluster, the other
+// members learn the seed from the push/pull state of memberlist.
+func (d *Discovery) GenerateHashSeed() error {
+	changed, err := d.hashSeed.generate()
+	if err != nil {
+		return err
+	}
+	if changed {
+		d.log.V(2).Printf("[INFO] Generated a new hash seed for the cluster")
+	}
+	return nil
+}
+
 func (d *Discovery) loadServiceDiscoveryPlugin() error {
 	var sd service_discovery.ServiceDiscovery
 
@@ -157,6 +191,11 @@ func (d *Discovery) Start() error {
 	// ClusterEvents chan is consumed by the Olric package to maintain a consistent hash ring.
 	d.ClusterEvents = d.SubscribeNodeEvents()
 
+	d.broadcasts = &memberlist.TransmitLimitedQueue{
+		NumNodes:       d.NumMembers,
+		RetransmitMult: d.config.MemberlistConfig.RetransmitMult,
+	}
+
 	// Initialize a new memberlist
 	dl, err := d.newDelegate()
 	if err != nil {
@@ -165,9 +204,17 @@ func (d *Discovery) Start() error {
 	eventsCh := make(chan memberlist.NodeEvent, eventChanCapacity)
 	d.config.MemberlistConfig.Delegate = dl
 	d.config.MemberlistConfig.Logger = d.config.Logger
-	d.config.MemberlistConfig.Events = &memberlist.ChannelEventDelegate{
+	var events memberlist.EventDelegate = &memberlist.ChannelEventDelegate{
 		Ch: eventsCh,
 	}
+	if d.config.MaxClusterSize > 0 {
+		d.sizeGuard = newClusterSizeGuard(d.member.Name, d.config.MaxClusterSize, events)
+		d.config.MemberlistConfig.Merge = d.sizeGuard
+		d.config.MemberlistConfig.Alive = d.sizeGuard
+		events = d.sizeGuard
+	}
+	d.config.MemberlistConfig.Merge = newHashSeedGuard(d.hashSeed, d.config.MemberlistConfig.Merge)
+	d.config.MemberlistConfig.Events = events
 	list, err := memberlist.Create(d.config.MemberlistConfig)
 	if err != nil {
 		return err
@@ -199,9 +246,35 @@ func (d *Discovery) Join() (int, error) {
 		if err != nil {
 			return 0, err
 		}
-		return d.memberlist.Join(peers)
+		return d.join(peers)
+	}
+	return d.join(d.config.Peers)
+}
+
+func (d *Discovery) join(peers []string) (int, error) {
+	_ = d.hashSeed.takeMismatch()
+	if d.sizeGuard != nil {
+		d.sizeGuard.setJoining(true)
+	}
+	n, err := d.memberlist.Join(peers)
+	if d.sizeGuard != nil {
+		d.sizeGuard.setJoining(false)
+	}
+	if err != nil && d.config.MaxClusterSize > 0 && strings.Contains(err.Error(), ErrMaxClusterSize.Error()) {
+		// memberlist flattens the errors returned by the merge delegate.
+		return n, fmt.Errorf("%w: %d", ErrMaxClusterSize, d.config.MaxClusterSize)
+	}
+	if err != nil && strings.Contains(err.Error(), ErrHashSeedMismatch.Error()) {
+		return n, fmt.Errorf("%w: %v", ErrHashSeedMismatch, err)
+	}
+	if err == nil {
+		// A generated seed is merged with the push/pull state, after the merge delegate.
+		err = d.hashSeed.takeMismatch()
+	}
+	if err == nil && d.sizeGuard != nil {
+		err = d.admitLocalMember()
 	}
-	return d.memberlist.Join(d.config.Peers)
+	return n, err
 }
 
 func (d *Discovery) Rejoin(peers []string) (int, error) {
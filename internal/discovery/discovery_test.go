@@ -25,6 +25,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
@@ -49,13 +50,19 @@ func newTestCluster(t *testing.T) *testCluster {
 }
 
 func (tc *testCluster) addNewMember(t *testing.T) *Discovery {
-	tc.mtx.Lock()
-	defer tc.mtx.Unlock()
-
+	tc.mtx.RLock()
 	cfg := testutil.NewConfig()
 	for _, peer := range tc.members {
 		cfg.Peers = append(cfg.Peers, peer)
 	}
+	tc.mtx.RUnlock()
+
+	return tc.addNewMemberWithConfig(t, cfg)
+}
+
+func (tc *testCluster) addNewMemberWithConfig(t *testing.T, cfg *config.Config) *Discovery {
+	tc.mtx.Lock()
+	defer tc.mtx.Unlock()
 
 	flogger := testutil.NewFlogger(cfg)
 	d := New(flogger, cfg)
@@ -149,6 +156,34 @@ func TestDiscovery_FindMemberByName(t *testing.T) {
 	}
 }
 
+func TestDiscovery_Broadcast(t *testing.T) {
+	c := newTestCluster(t)
+	d1 := c.addNewMember(t)
+	d2 := c.addNewMember(t)
+	d3 := c.addNewMember(t)
+
+	var mtx sync.Mutex
+	received := make(map[*Discovery]string)
+	for _, d := range []*Discovery{d2, d3} {
+		d := d
+		d.SetMessageHandler(func(msg []byte) {
+			mtx.Lock()
+			defer mtx.Unlock()
+			received[d] = string(msg)
+		})
+	}
+
+	// A newer message replaces the queued one with the same name.
+	d1.Broadcast("foo", []byte("bar"))
+	d1.Broadcast("foo", []byte("baz"))
+
+	require.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return received[d2] == "baz" && received[d3] == "baz"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
 func TestDiscovery_increaseUptimeSeconds(t *testing.T) {
 	c := newTestCluster(t)
 	c.addNewMember(t)
@@ -278,3 +313,94 @@ loop:
 	require.Contains(t, members, net.JoinHostPort(d2.config.MemberlistConfig.BindAddr, strconv.Itoa(d2.config.MemberlistConfig.BindPort)))
 	require.Contains(t, members, net.JoinHostPort(d3.config.MemberlistConfig.BindAddr, strconv.Itoa(d3.config.MemberlistConfig.BindPort)))
 }
+
+func TestDiscovery_HashSeedMismatch(t *testing.T) {
+	c := newTestCluster(t)
+	newConfig := func(seed uint64) *config.Config {
+		cfg := testutil.NewConfig()
+		cfg.HashSeed = seed
+		c.mtx.RLock()
+		cfg.Peers = append(cfg.Peers, c.members...)
+		c.mtx.RUnlock()
+		return cfg
+	}
+
+	d1 := c.addNewMemberWithConfig(t, newConfig(1))
+	c.addNewMemberWithConfig(t, newConfig(1))
+
+	cfg := newConfig(2)
+	d3 := New(testutil.NewFlogger(cfg), cfg)
+	require.NoError(t, d3.Start())
+	t.Cleanup(func() {
+		require.NoError(t, d3.Shutdown())
+	})
+
+	_, err := d3.Join()
+	require.ErrorIs(t, err, ErrHashSeedMismatch)
+
+	<-time.After(250 * time.Millisecond)
+	require.Equal(t, 2, d1.NumMembers())
+}
+
+func TestDiscovery_GenerateHashSeed(t *testing.T) {
+	c := newTestCluster(t)
+	newConfig := func(seed uint64) *config.Config {
+		cfg := testutil.NewConfig()
+		cfg.HashSeed = seed
+		c.mtx.RLock()
+		cfg.Peers = append(cfg.Peers, c.members...)
+		c.mtx.RUnlock()
+		return cfg
+	}
+
+	d1 := c.addNewMemberWithConfig(t, newConfig(0))
+	require.Zero(t, d1.HashSeed())
+
+	require.NoError(t, d1.GenerateHashSeed())
+	seed := d1.HashSeed()
+	require.NotZero(t, seed)
+
+	// The seed is not generated again.
+	require.NoError(t, d1.GenerateHashSeed())
+	require.Equal(t, seed, d1.HashSeed())
+
+	t.Run("Learn the seed while joining", func(t *testing.T) {
+		d2 := c.addNewMemberWithConfig(t, newConfig(0))
+		require.Equal(t, seed, d2.HashSeed())
+	})
+
+	t.Run("Accept the same configured seed", func(t *testing.T) {
+		d3 := c.addNewMemberWithConfig(t, newConfig(seed))
+		require.Equal(t, seed, d3.HashSeed())
+	})
+
+	t.Run("Reject a different configured seed", func(t *testing.T) {
+		cfg := newConfig(seed + 1)
+		d4 := New(testutil.NewFlogger(cfg), cfg)
+		require.NoError(t, d4.Start())
+		t.Cleanup(func() {
+			require.NoError(t, d4.Shutdown())
+		})
+
+		_, err := d4.Join()
+		require.ErrorIs(t, err, ErrHashSeedMismatch)
+	})
+}
+
+func TestDiscovery_HashSeed_Without_Seed(t *testing.T) {
+	c := newTestCluster(t)
+	newConfig := func() *config.Config {
+		cfg := testutil.NewConfig()
+		c.mtx.RLock()
+		cfg.Peers = append(cfg.Peers, c.members...)
+		c.mtx.RUnlock()
+		return cfg
+	}
+
+	// A cluster that has no seed, like the ones formed by the older versions.
+	d1 := c.addNewMemberWithConfig(t, newConfig())
+	d2 := c.addNewMemberWithConfig(t, newConfig())
+	require.Zero(t, d1.HashSeed())
+	require.Zero(t, d2.HashSeed())
+	require.Equal(t, 2, d2.NumMembers())
+}
@@ -0,0 +1,170 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package discovery
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hashicorp/memberlist"
+)
+
+// ErrHashSeedMismatch is returned when a join attempt is rejected because the
+// members use different hash seeds.
+var ErrHashSeedMismatch = errors.New("hash seed mismatch")
+
+// hashSeed keeps the hash seed of the cluster. Zero means that the member doesn't know
+// the seed yet. A seed that is not configured is generated by the member that forms a new
+// cluster and learned by the joining members from the push/pull state of memberlist. The
+// members of a cluster without a seed, e.g. a cluster formed by an older version, keep
+// using zero.
+type hashSeed struct {
+	mtx      sync.RWMutex
+	seed     uint64
+	mismatch error
+	onChange func(seed uint64)
+}
+
+func (h *hashSeed) get() uint64 {
+	h.mtx.RLock()
+	defer h.mtx.RUnlock()
+	return h.seed
+}
+
+// learn sets the seed if it's not known yet. It returns ErrHashSeedMismatch if the member
+// already has a different seed.
+func (h *hashSeed) learn(seed uint64) error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.seed == seed {
+		return nil
+	}
+	if h.seed != 0 {
+		return fmt.Errorf("%w: %d != %d", ErrHashSeedMismatch, seed, h.seed)
+	}
+	h.seed = seed
+	if h.onChange != nil {
+		h.onChange(seed)
+	}
+	return nil
+}
+
+// encode returns the seed to send with the push/pull state. It returns nil if the seed
+// is not known.
+func (h *hashSeed) encode() []byte {
+	seed := h.get()
+	if seed == 0 {
+		return nil
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, seed)
+	return buf
+}
+
+// merge learns the seed from the push/pull state of a remote member. The members that
+// don't know the seed send no state. A mismatch is kept to fail the ongoing join.
+func (h *hashSeed) merge(buf []byte) error {
+	if len(buf) != 8 {
+		return nil
+	}
+	seed := binary.BigEndian.Uint64(buf)
+	if seed == 0 {
+		return nil
+	}
+	err := h.learn(seed)
+	if err != nil {
+		h.mtx.Lock()
+		h.mismatch = err
+		h.mtx.Unlock()
+	}
+	return err
+}
+
+// takeMismatch returns and clears the last mismatch found by merge.
+func (h *hashSeed) takeMismatch() error {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	err := h.mismatch
+	h.mismatch = nil
+	return err
+}
+
+// generate sets a random seed if it's not known yet. It returns true if the seed is changed.
+func (h *hashSeed) generate() (bool, error) {
+	buf := make([]byte, 8)
+	var seed uint64
+	// Zero means no seed.
+	for seed == 0 {
+		if _, err := rand.Read(buf); err != nil {
+			return false, err
+		}
+		seed = binary.BigEndian.Uint64(buf)
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if h.seed != 0 {
+		return false, nil
+	}
+	h.seed = seed
+	if h.onChange != nil {
+		h.onChange(seed)
+	}
+	return true, nil
+}
+
+// hashSeedGuard rejects the members with a different configured hash seed. The members
+// route the keys to the partitions by the seeded hashes, a member with a different seed
+// would look for the keys on the wrong partitions. The metadata only carries the
+// configured seeds, a generated seed is checked with the push/pull state. It implements
+// memberlist.MergeDelegate and calls the next delegate, if there is any.
+type hashSeedGuard struct {
+	seed *hashSeed
+	next memberlist.MergeDelegate
+}
+
+func newHashSeedGuard(seed *hashSeed, next memberlist.MergeDelegate) *hashSeedGuard {
+	return &hashSeedGuard{
+		seed: seed,
+		next: next,
+	}
+}
+
+// NotifyMerge is invoked on both sides of a join, before the remote state is merged.
+func (g *hashSeedGuard) NotifyMerge(peers []*memberlist.Node) error {
+	for _, peer := range peers {
+		meta, err := decodeMetadata(peer.Meta)
+		if err != nil {
+			return err
+		}
+		if meta.HashSeed == 0 {
+			// A joining member that doesn't know the seed of the cluster yet.
+			continue
+		}
+		if err = g.seed.learn(meta.HashSeed); err != nil {
+			return fmt.Errorf("%w: %s", err, meta.Member)
+		}
+	}
+	if g.next != nil {
+		return g.next.NotifyMerge(peers)
+	}
+	return nil
+}
@@ -31,6 +31,17 @@ type Member struct {
 	Birthdate int64
 }
 
+// metadata is the metadata of a member that is gossiped by memberlist. The hash seed of
+// the cluster is not a part of the member identity, a joining member learns it from the
+// metadata of the others. Admitted is set once the coordinator admits the member into a
+// cluster with a size limit. The fields of the member are inlined, so a Member can be
+// decoded from it.
+type metadata struct {
+	Member   `msgpack:",inline"`
+	HashSeed uint64
+	Admitted bool
+}
+
 // CompareByID returns true if two members denote the same member in the cluster.
 func (m Member) CompareByID(other Member) bool {
 	// ID variable is calculated by combining member's name and birthdate
@@ -57,6 +68,12 @@ func NewMemberFromMetadata(metadata []byte) (Member, error) {
 	return *res, err
 }
 
+func decodeMetadata(data []byte) (metadata, error) {
+	res := metadata{}
+	err := msgpack.Unmarshal(data, &res)
+	return res, err
+}
+
 func MemberID(name string, birthdate int64) uint64 {
 	// Calculate member's identity. It's useful to compare hosts.
 	buf := make([]byte, 8+len(name))
@@ -18,6 +18,7 @@ import (
 	"testing"
 
 	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func TestMembers(t *testing.T) {
@@ -72,4 +73,27 @@ func TestMembers(t *testing.T) {
 			t.Fatalf("Decoded member is different")
 		}
 	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		data, err := msgpack.Marshal(metadata{Member: member1, HashSeed: 42})
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+
+		decoded, err := NewMemberFromMetadata(data)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if decoded != member1 {
+			t.Fatalf("Decoded member is different")
+		}
+
+		meta, err := decodeMetadata(data)
+		if err != nil {
+			t.Fatalf("Expected nil. Got: %v", err)
+		}
+		if meta.Member != member1 || meta.HashSeed != 42 {
+			t.Fatalf("Decoded metadata is different")
+		}
+	})
 }
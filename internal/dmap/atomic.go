@@ -18,14 +18,37 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"strconv"
 	"time"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/resp"
 	"github.com/buraksezer/olric/internal/util"
 	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/redis/go-redis/v9"
 )
 
+var (
+	// ErrNotAnInteger is returned when an integer operation is called on a key whose value is not an integer.
+	ErrNotAnInteger = errors.New("value is not an integer")
+
+	// ErrInvalidFloat is returned when a float operation is called with NaN or Infinity, or would produce one.
+	ErrInvalidFloat = errors.New("value is NaN or Infinity")
+)
+
+func isValidFloat(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
+
+// encodeInt encodes a counter in the same format as resp.Encoder, so the counters are
+// readable by every Get path and the values written as plain bytes are valid counters.
+// It skips the buffer pool and the encoder, the counters are written on every call.
+func encodeInt(n int) []byte {
+	return strconv.AppendInt(make([]byte, 0, 20), int64(n), 10)
+}
+
 func (dm *DMap) loadCurrentAtomicInt(e *env) (int, int64, error) {
 	entry, err := dm.Get(e.ctx, e.key)
 	if errors.Is(err, ErrKeyNotFound) {
@@ -40,7 +63,7 @@ func (dm *DMap) loadCurrentAtomicInt(e *env) (int, int64, error) {
 	}
 	nr, err := util.ParseInt(entry.Value(), 10, 64)
 	if err != nil {
-		return 0, 0, nil
+		return 0, 0, fmt.Errorf("%w: %s", ErrNotAnInteger, e.key)
 	}
 	return int(nr), entry.TTL(), nil
 }
@@ -70,16 +93,7 @@ func (dm *DMap) atomicIncrDecr(cmd string, e *env, delta int) (int, error) {
 		return 0, fmt.Errorf("invalid operation")
 	}
 
-	valueBuf := pool.Get()
-	defer pool.Put(valueBuf)
-
-	enc := resp.New(valueBuf)
-	err = enc.Encode(updated)
-	if err != nil {
-		return 0, err
-	}
-	e.value = make([]byte, valueBuf.Len())
-	copy(e.value, valueBuf.Bytes())
+	e.value = encodeInt(updated)
 
 	if ttl != 0 {
 		e.putConfig.HasPX = true
@@ -93,20 +107,305 @@ func (dm *DMap) atomicIncrDecr(cmd string, e *env, delta int) (int, error) {
 	return updated, nil
 }
 
+// incrDecr runs Incr or Decr on the partition owner of the key, so the read-modify-write
+// is serialized by the fine-grained lock of the key on a single member.
+func (dm *DMap) incrDecr(ctx context.Context, cmd, key string, delta int) (int, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.atomicIncrDecr(cmd, e, delta)
+	}
+
+	// Redirect to the partition owner.
+	var rcmd *redis.IntCmd
+	switch cmd {
+	case protocol.DMap.Incr:
+		rcmd = protocol.NewIncr(dm.name, key, delta).Command(ctx)
+	case protocol.DMap.Decr:
+		rcmd = protocol.NewDecr(dm.name, key, delta).Command(ctx)
+	default:
+		return 0, fmt.Errorf("invalid operation")
+	}
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, rcmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	value, err := rcmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(value), nil
+}
+
 // Incr atomically increments key by delta. The return value is the new value after being incremented or an error.
+// It returns ErrNotAnInteger if the value is not an integer. The operation runs on the partition owner of the key.
 func (dm *DMap) Incr(ctx context.Context, key string, delta int) (int, error) {
-	e := newEnv(ctx)
-	e.dmap = dm.name
-	e.key = key
-	return dm.atomicIncrDecr(protocol.DMap.Incr, e, delta)
+	return dm.incrDecr(ctx, protocol.DMap.Incr, key, delta)
 }
 
 // Decr atomically decrements key by delta. The return value is the new value after being decremented or an error.
+// It returns ErrNotAnInteger if the value is not an integer. The operation runs on the partition owner of the key.
 func (dm *DMap) Decr(ctx context.Context, key string, delta int) (int, error) {
-	e := newEnv(ctx)
-	e.dmap = dm.name
-	e.key = key
-	return dm.atomicIncrDecr(protocol.DMap.Decr, e, delta)
+	return dm.incrDecr(ctx, protocol.DMap.Decr, key, delta)
+}
+
+func (dm *DMap) decrAndDeleteAtZero(e *env, delta int) (int, bool, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	entry, err := dm.Get(e.ctx, e.key)
+	if err != nil {
+		return 0, false, err
+	}
+	current, err := util.ParseInt(entry.Value(), 10, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: %s", ErrNotAnInteger, e.key)
+	}
+
+	remaining := int(current) - delta
+	if remaining <= 0 {
+		_, err = dm.deleteKeys(e.ctx, e.key)
+		if err != nil {
+			return 0, false, err
+		}
+		dm.fireZeroCallback(e.key)
+		return 0, true, nil
+	}
+
+	e.value = encodeInt(remaining)
+
+	if entry.TTL() != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Until(time.UnixMilli(entry.TTL()))
+	}
+	err = dm.put(e)
+	if err != nil {
+		return 0, false, err
+	}
+	return remaining, false, nil
+}
+
+// DecrAndDeleteAtZero atomically decrements key by delta. If the result is equal to or less than zero,
+// the key is deleted, deleted is true and the callback registered with OnZero is fired. It returns
+// ErrKeyNotFound if the key does not exist and ErrNotAnInteger if the value is not an integer.
+// The operation runs on the partition owner of the key.
+func (dm *DMap) DecrAndDeleteAtZero(ctx context.Context, key string, delta int) (remaining int, deleted bool, err error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.decrAndDeleteAtZero(e, delta)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewDecrAndDeleteAtZero(dm.name, key, delta).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, false, protocol.ConvertError(err)
+	}
+	result, err := cmd.Result()
+	if err != nil {
+		return 0, false, protocol.ConvertError(err)
+	}
+	if len(result) != 2 {
+		return 0, false, fmt.Errorf("invalid response length: %d", len(result))
+	}
+	res, ok := result[0].(int64)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid response type: %T", result[0])
+	}
+	del, ok := result[1].(int64)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid response type: %T", result[1])
+	}
+	return int(res), del == 1, nil
+}
+
+func (dm *DMap) getAndReset(e *env) (int, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	current, err := util.ParseInt(entry.Value(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrNotAnInteger, e.key)
+	}
+
+	e.value = encodeInt(0)
+
+	if entry.TTL() != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Until(time.UnixMilli(entry.TTL()))
+	}
+	err = dm.put(e)
+	if err != nil {
+		return 0, err
+	}
+	return int(current), nil
+}
+
+// GetAndReset atomically returns the integer value stored at the key and sets it to zero,
+// so the increments between the read and the reset are not lost. It returns 0 if the key
+// does not exist, and ErrNotAnInteger if the value is not an integer. The TTL of the key
+// is preserved. The operation runs on the partition owner of the key.
+func (dm *DMap) GetAndReset(ctx context.Context, key string) (int, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.getAndReset(e)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewGetAndReset(dm.name, key).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	value, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(value), nil
+}
+
+func (dm *DMap) getDel(e *env) (storage.Entry, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	entry, err := dm.Get(e.ctx, e.key)
+	if err != nil {
+		return nil, err
+	}
+	_, err = dm.deleteKeys(e.ctx, e.key)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// GetDel atomically returns the entry of the key and deletes it, so only one of the
+// concurrent callers gets the value, like consuming a one-shot token. It returns
+// ErrKeyNotFound if the key does not exist. The operation runs on the partition owner
+// of the key.
+func (dm *DMap) GetDel(ctx context.Context, key string) (storage.Entry, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.getDel(e)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewGetDel(dm.name, key).SetRaw().Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	raw, err := cmd.Bytes()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+
+	entry := dm.engine.NewEntry()
+	entry.Decode(raw)
+	return entry, nil
+}
+
+func (dm *DMap) appendValue(e *env, suffix []byte) (int, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var current []byte
+	if entry != nil {
+		current = entry.Value()
+		if entry.TTL() != 0 {
+			e.putConfig.HasPX = true
+			e.putConfig.PX = time.Until(time.UnixMilli(entry.TTL()))
+		}
+	}
+
+	e.value = make([]byte, 0, len(current)+len(suffix))
+	e.value = append(e.value, current...)
+	e.value = append(e.value, suffix...)
+	err = dm.put(e)
+	if err != nil {
+		return 0, err
+	}
+	return len(e.value), nil
+}
+
+// Append atomically appends suffix to the value stored at the key and returns the length of
+// the new value. If the key does not exist, it is created with suffix as its value. The TTL
+// of the key is preserved. The operation runs on the partition owner of the key.
+func (dm *DMap) Append(ctx context.Context, key string, suffix []byte) (int, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.appendValue(e, suffix)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewAppend(dm.name, key, suffix).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	length, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(length), nil
 }
 
 func (dm *DMap) getPut(e *env) (storage.Entry, error) {
@@ -137,7 +436,8 @@ func (dm *DMap) getPut(e *env) (storage.Entry, error) {
 	return entry, nil
 }
 
-// GetPut atomically sets key to value and returns the old value stored at key.
+// GetPut atomically sets key to value and returns the old value stored at key. It returns nil
+// if the key does not exist. The operation runs on the partition owner of the key.
 func (dm *DMap) GetPut(ctx context.Context, key string, value interface{}) (storage.Entry, error) {
 	if value == nil {
 		value = struct{}{}
@@ -152,23 +452,174 @@ func (dm *DMap) GetPut(ctx context.Context, key string, value interface{}) (stor
 		return nil, err
 	}
 
+	raw := make([]byte, valueBuf.Len())
+	copy(raw, valueBuf.Bytes())
+	return dm.getPutOnOwner(ctx, key, raw)
+}
+
+// getPutOnOwner runs GetPut on the partition owner of the key, so the read and the write
+// are serialized by the fine-grained lock of the key on a single member. It returns nil if
+// there is no previous value.
+func (dm *DMap) getPutOnOwner(ctx context.Context, key string, value []byte) (storage.Entry, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		e.value = value
+		return dm.getPut(e)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewGetPut(dm.name, key, value).SetRaw().Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	raw, err := cmd.Bytes()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+
+	entry := dm.engine.NewEntry()
+	entry.Decode(raw)
+	return entry, nil
+}
+
+// setNXGetOnCluster runs on the partition owner. The current holder is read and the key
+// is written under the same fragment lock, so a failed caller always gets the entry that
+// made it fail.
+func (dm *DMap) setNXGetOnCluster(e *env) (bool, storage.Entry, error) {
+	part := dm.getPartitionByHKey(e.hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return false, nil, err
+	}
+
+	e.fragment = f
+	f.Lock()
+	if dm.isWriteFenced(e.hkey, f) {
+		f.Unlock()
+		// The partition has been moved, forward the command to the current owner.
+		return dm.setNXGet(e)
+	}
+	defer f.Unlock()
+
+	current, err := f.storage.Get(e.hkey)
+	if err == nil && !isKeyExpired(current.TTL()) {
+		if err = dm.decryptEntry(current); err != nil {
+			return false, nil, err
+		}
+		return false, current, nil
+	}
+	if err != nil && !errors.Is(err, storage.ErrKeyNotFound) {
+		return false, nil, err
+	}
+
+	e.putConfig.HasNX = true
+	if err = dm.putOnFragment(e); err != nil {
+		return false, nil, err
+	}
+	nt := dm.engine.NewEntry()
+	nt.SetKey(e.key)
+	nt.SetValue(e.value)
+	nt.SetTTL(prepareTTL(e))
+	nt.SetTimestamp(e.timestamp)
+	return true, nt, nil
+}
+
+func (dm *DMap) setNXGet(e *env) (bool, storage.Entry, error) {
+	e.hkey = partitions.HKey(e.dmap, e.key)
+	member := dm.s.primary.PartitionByHKey(e.hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.setNXGetOnCluster(e)
+	}
+
+	var px int64
+	if e.putConfig.HasPX {
+		px = e.putConfig.PX.Milliseconds()
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewSetNXGet(e.dmap, e.key, e.value, px).SetRaw().Command(e.ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(e.ctx, cmd)
+	if err != nil {
+		return false, nil, protocol.ConvertError(err)
+	}
+	result, err := cmd.Result()
+	if err != nil {
+		return false, nil, protocol.ConvertError(err)
+	}
+	if len(result) != 2 {
+		return false, nil, fmt.Errorf("invalid response length: %d", len(result))
+	}
+	set, ok := result[0].(int64)
+	if !ok {
+		return false, nil, fmt.Errorf("invalid response type: %T", result[0])
+	}
+	raw, ok := result[1].(string)
+	if !ok {
+		return false, nil, fmt.Errorf("invalid response type: %T", result[1])
+	}
+
+	entry := dm.engine.NewEntry()
+	entry.Decode([]byte(raw))
+	return set == 1, entry, nil
+}
+
+// SetNXGet atomically sets key to value with the given TTL, if the key does not exist.
+// If the key is set, it returns true and the newly written entry. Otherwise, it returns
+// false and the entry of the current holder. The operation runs on the partition owner,
+// so the concurrent callers on different members are serialized. A zero ttl means no
+// expiration.
+func (dm *DMap) SetNXGet(ctx context.Context, key string, value interface{}, ttl time.Duration) (bool, storage.Entry, error) {
+	if value == nil {
+		value = struct{}{}
+	}
+
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	enc := resp.New(valueBuf)
+	err := enc.Encode(value)
+	if err != nil {
+		return false, nil, err
+	}
+
 	e := newEnv(ctx)
 	e.dmap = dm.name
 	e.key = key
 	e.value = make([]byte, valueBuf.Len())
 	copy(e.value, valueBuf.Bytes())
+	if ttl != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = ttl
+	}
+	return dm.setNXGet(e)
+}
 
-	raw, err := dm.getPut(e)
+// GetOrSet returns the entry of the key and true, if the key exists. Otherwise, it sets the
+// key to value with the given TTL, and returns the new entry and false. The operation runs
+// on the partition owner in a single round trip, so the concurrent callers agree on a single
+// value. A zero ttl means no expiration.
+func (dm *DMap) GetOrSet(ctx context.Context, key string, value interface{}, ttl time.Duration) (storage.Entry, bool, error) {
+	set, entry, err := dm.SetNXGet(ctx, key, value, ttl)
 	if err != nil {
-		return nil, err
-	}
-	if raw == nil {
-		return nil, nil
+		return nil, false, err
 	}
-	return raw, nil
+	return entry, !set, nil
 }
 
 func (dm *DMap) atomicIncrByFloat(e *env, delta float64) (float64, error) {
+	if !isValidFloat(delta) {
+		return 0, fmt.Errorf("%w: delta: %v", ErrInvalidFloat, delta)
+	}
+
 	atomicKey := e.dmap + e.key
 	dm.s.locker.Lock(atomicKey)
 	defer func() {
@@ -192,11 +643,15 @@ func (dm *DMap) atomicIncrByFloat(e *env, delta float64) (float64, error) {
 		if err != nil {
 			return 0, err
 		}
+		if !isValidFloat(current) {
+			return 0, fmt.Errorf("%w: %s", ErrInvalidFloat, e.key)
+		}
 	}
 
 	latest := current + delta
-	if err != nil {
-		return 0, err
+	if !isValidFloat(latest) {
+		// The result overflows, keep the current value.
+		return 0, fmt.Errorf("%w: increment would produce %v", ErrInvalidFloat, latest)
 	}
 
 	valueBuf := pool.Get()
@@ -220,6 +675,9 @@ func (dm *DMap) atomicIncrByFloat(e *env, delta float64) (float64, error) {
 }
 
 // IncrByFloat atomically increments key by delta. The return value is the new value after being incremented or an error.
+//
+// It returns ErrInvalidFloat if the delta or the stored value is NaN or Infinity,
+// or the result would overflow to Infinity. The stored value is not changed in that case.
 func (dm *DMap) IncrByFloat(ctx context.Context, key string, delta float64) (float64, error) {
 	e := newEnv(ctx)
 	e.dmap = dm.name
@@ -16,6 +16,7 @@ package dmap
 
 import (
 	"strconv"
+	"time"
 
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/tidwall/redcon"
@@ -26,11 +27,7 @@ func (s *Service) incrDecrCommon(cmd, dmap, key string, delta int) (int, error)
 	if err != nil {
 		return 0, err
 	}
-
-	e := newEnv(s.ctx)
-	e.dmap = dm.name
-	e.key = key
-	return dm.atomicIncrDecr(cmd, e, delta)
+	return dm.incrDecr(s.ctx, cmd, key, delta)
 }
 
 func (s *Service) incrCommandHandler(conn redcon.Conn, cmd redcon.Command) {
@@ -61,6 +58,98 @@ func (s *Service) decrCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	conn.WriteInt(latest)
 }
 
+func (s *Service) decrAndDeleteAtZeroCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	decrAndDeleteAtZeroCmd, err := protocol.ParseDecrAndDeleteAtZeroCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(decrAndDeleteAtZeroCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	remaining, deleted, err := dm.DecrAndDeleteAtZero(s.ctx, decrAndDeleteAtZeroCmd.Key, decrAndDeleteAtZeroCmd.Delta)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(2)
+	conn.WriteInt(remaining)
+	if deleted {
+		conn.WriteInt(1)
+	} else {
+		conn.WriteInt(0)
+	}
+}
+
+func (s *Service) getAndResetCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	getAndResetCmd, err := protocol.ParseGetAndResetCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(getAndResetCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	value, err := dm.GetAndReset(s.ctx, getAndResetCmd.Key)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(value)
+}
+
+func (s *Service) getDelCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	getDelCmd, err := protocol.ParseGetDelCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(getDelCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	entry, err := dm.GetDel(s.ctx, getDelCmd.Key)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	if getDelCmd.Raw {
+		conn.WriteBulk(entry.Encode())
+		return
+	}
+	conn.WriteBulk(entry.Value())
+}
+
+func (s *Service) appendCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	appendCmd, err := protocol.ParseAppendCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(appendCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	length, err := dm.Append(s.ctx, appendCmd.Key, appendCmd.Suffix)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(length)
+}
+
 func (s *Service) getPutCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	getPutCmd, err := protocol.ParseGetPutCommand(cmd)
 	if err != nil {
@@ -72,12 +161,7 @@ func (s *Service) getPutCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		protocol.WriteError(conn, err)
 		return
 	}
-
-	e := newEnv(s.ctx)
-	e.dmap = getPutCmd.DMap
-	e.key = getPutCmd.Key
-	e.value = getPutCmd.Value
-	old, err := dm.getPut(e)
+	old, err := dm.getPutOnOwner(s.ctx, getPutCmd.Key, getPutCmd.Value)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -96,6 +180,45 @@ func (s *Service) getPutCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	conn.WriteBulk(old.Value())
 }
 
+func (s *Service) setNXGetCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	setNXGetCmd, err := protocol.ParseSetNXGetCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(setNXGetCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	e := newEnv(s.ctx)
+	e.dmap = setNXGetCmd.DMap
+	e.key = setNXGetCmd.Key
+	e.value = setNXGetCmd.Value
+	if setNXGetCmd.PX != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Duration(setNXGetCmd.PX) * time.Millisecond
+	}
+	acquired, current, err := dm.setNXGet(e)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(2)
+	if acquired {
+		conn.WriteInt(1)
+	} else {
+		conn.WriteInt(0)
+	}
+	if setNXGetCmd.Raw {
+		conn.WriteBulk(current.Encode())
+		return
+	}
+	conn.WriteBulk(current.Value())
+}
+
 func (s *Service) incrByFloatCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	incrCmd, err := protocol.ParseIncrByFloatCommand(cmd)
 	if err != nil {
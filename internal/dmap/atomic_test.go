@@ -17,14 +17,19 @@ package dmap
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"math"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/resp"
 	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/sync/errgroup"
@@ -101,6 +106,139 @@ func TestDMap_Atomic_Incr(t *testing.T) {
 	require.Equal(t, 100, res)
 }
 
+func TestDMap_Atomic_Incr_PlainBytes(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	// The counters written by Put as plain bytes or integers are valid.
+	require.NoError(t, dm.Put(ctx, "bytes", []byte("41"), nil))
+	require.NoError(t, dm.Put(ctx, "int", 41, nil))
+
+	for _, key := range []string{"bytes", "int"} {
+		res, err := dm.Incr(ctx, key, 1)
+		require.NoError(t, err)
+		require.Equal(t, 42, res)
+
+		gr, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, []byte("42"), gr.Value())
+
+		var value int
+		require.NoError(t, resp.Scan(gr.Value(), &value))
+		require.Equal(t, 42, value)
+	}
+}
+
+func TestDMap_Atomic_Incr_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	key := "incr"
+
+	// The calls on both members race on the same counter, only one of them is the owner.
+	var g errgroup.Group
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("atomic_test")
+		require.NoError(t, err)
+		for i := 0; i < 50; i++ {
+			g.Go(func() error {
+				_, err := dm.Incr(ctx, key, 2)
+				if err != nil {
+					return err
+				}
+				_, err = dm.Decr(ctx, key, 1)
+				return err
+			})
+		}
+	}
+	require.NoError(t, g.Wait())
+
+	dm, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	gr, err := dm.Get(ctx, key)
+	require.NoError(t, err)
+
+	var res int
+	require.NoError(t, resp.Scan(gr.Value(), &res))
+	require.Equal(t, 100, res)
+}
+
+func TestDMap_Atomic_Incr_ErrNotAnInteger(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	require.NoError(t, dm1.Put(ctx, "mykey", "foobar", nil))
+
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("atomic_test")
+		require.NoError(t, err)
+
+		_, err = dm.Incr(ctx, "mykey", 1)
+		require.ErrorIs(t, err, ErrNotAnInteger)
+		_, err = dm.Decr(ctx, "mykey", 1)
+		require.ErrorIs(t, err, ErrNotAnInteger)
+	}
+
+	// The value is not modified.
+	gr, err := dm1.Get(ctx, "mykey")
+	require.NoError(t, err)
+	var value string
+	require.NoError(t, resp.Scan(gr.Value(), &value))
+	require.Equal(t, "foobar", value)
+}
+
+func BenchmarkDMap_Atomic_Incr(b *testing.B) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("atomic_test")
+	require.NoError(b, err)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err = dm.Incr(ctx, "incr", 1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDMap_Atomic_EncodeInt(b *testing.B) {
+	b.Run("Encoder", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			valueBuf := pool.Get()
+			enc := resp.New(valueBuf)
+			if err := enc.Encode(i); err != nil {
+				b.Fatal(err)
+			}
+			value := make([]byte, valueBuf.Len())
+			copy(value, valueBuf.Bytes())
+			pool.Put(valueBuf)
+		}
+	})
+
+	b.Run("encodeInt", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_ = encodeInt(i)
+		}
+	})
+}
+
 func TestDMap_Atomic_Decr(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	s := cluster.AddMember(nil).(*Service)
@@ -193,6 +331,483 @@ func TestDMap_Atomic_GetPut(t *testing.T) {
 	require.Equal(t, final, atomic.LoadInt64(&total))
 }
 
+func TestDMap_Atomic_GetPut_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("atomic_test")
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			key := testutil.ToKey(i)
+			// The key does not exist.
+			old, err := dm.GetPut(ctx, key, "value-1")
+			require.NoError(t, err)
+			require.Nil(t, old)
+
+			// Overwrite it, the previous value is returned.
+			old, err = dm.GetPut(ctx, key, "value-2")
+			require.NoError(t, err)
+			require.NotNil(t, old)
+			var value string
+			require.NoError(t, resp.Scan(old.Value(), &value))
+			require.Equal(t, "value-1", value)
+
+			gr, err := dm.Get(ctx, key)
+			require.NoError(t, err)
+			require.NoError(t, resp.Scan(gr.Value(), &value))
+			require.Equal(t, "value-2", value)
+
+			_, err = dm.Delete(ctx, key)
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestDMap_Atomic_SetNXGet(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	acquired, current, err := dm1.SetNXGet(ctx, "lock", "winner", time.Minute)
+	require.NoError(t, err)
+	require.True(t, acquired)
+	var value string
+	require.NoError(t, resp.Scan(current.Value(), &value))
+	require.Equal(t, "winner", value)
+	require.NotZero(t, current.TTL())
+
+	acquired, current, err = dm2.SetNXGet(ctx, "lock", "loser", time.Minute)
+	require.NoError(t, err)
+	require.False(t, acquired)
+	require.NoError(t, resp.Scan(current.Value(), &value))
+	require.Equal(t, "winner", value)
+
+	t.Run("Acquire after expiration", func(t *testing.T) {
+		acquired, _, err := dm1.SetNXGet(ctx, "short-lock", "winner", time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, acquired)
+
+		<-time.After(10 * time.Millisecond)
+
+		acquired, current, err := dm2.SetNXGet(ctx, "short-lock", "next", 0)
+		require.NoError(t, err)
+		require.True(t, acquired)
+		require.NoError(t, resp.Scan(current.Value(), &value))
+		require.Equal(t, "next", value)
+	})
+
+	t.Run("Concurrent callers on different members", func(t *testing.T) {
+		const callers = 10
+		for i := 0; i < 10; i++ {
+			key := testutil.ToKey(i)
+			var acquiredBy int64 = -1
+			holders := make([]string, callers)
+
+			var errGr errgroup.Group
+			for j := 0; j < callers; j++ {
+				j := j
+				dm := dm1
+				if j%2 == 0 {
+					dm = dm2
+				}
+				errGr.Go(func() error {
+					acquired, current, err := dm.SetNXGet(ctx, key, fmt.Sprintf("caller-%d", j), time.Minute)
+					if err != nil {
+						return err
+					}
+					if acquired && !atomic.CompareAndSwapInt64(&acquiredBy, -1, int64(j)) {
+						return fmt.Errorf("%s acquired twice", key)
+					}
+					return resp.Scan(current.Value(), &holders[j])
+				})
+			}
+			require.NoError(t, errGr.Wait())
+
+			// Every caller sees the entry written by the only caller that acquired the key.
+			require.NotEqual(t, int64(-1), acquiredBy)
+			for _, holder := range holders {
+				require.Equal(t, fmt.Sprintf("caller-%d", acquiredBy), holder)
+			}
+		}
+	})
+}
+
+func TestDMap_Atomic_GetOrSet(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	const callers = 20
+	var mtx sync.Mutex
+	values := make(map[string]struct{})
+	var stored int64
+
+	var errGr errgroup.Group
+	for i := 0; i < callers; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		value := fmt.Sprintf("value-%d", i)
+		errGr.Go(func() error {
+			entry, loaded, err := dm.GetOrSet(ctx, "mykey", value, time.Minute)
+			if err != nil {
+				return err
+			}
+			if !loaded {
+				atomic.AddInt64(&stored, 1)
+			}
+			var current string
+			if err := resp.Scan(entry.Value(), &current); err != nil {
+				return err
+			}
+			mtx.Lock()
+			values[current] = struct{}{}
+			mtx.Unlock()
+			return nil
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	// All callers agree on a single value, and only one of them has stored it.
+	require.Equal(t, int64(1), atomic.LoadInt64(&stored))
+	require.Len(t, values, 1)
+
+	entry, err := dm1.Get(ctx, "mykey")
+	require.NoError(t, err)
+	var current string
+	require.NoError(t, resp.Scan(entry.Value(), &current))
+	require.Contains(t, values, current)
+}
+
+func TestDMap_Atomic_DecrAndDeleteAtZero(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	const refs = 100
+	_, err = dm.Incr(ctx, "refcount", refs)
+	require.NoError(t, err)
+
+	var deletes int64
+	var errGr errgroup.Group
+	for i := 0; i < refs; i++ {
+		errGr.Go(func() error {
+			_, deleted, err := dm.DecrAndDeleteAtZero(ctx, "refcount", 1)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				atomic.AddInt64(&deletes, 1)
+			}
+			return nil
+		})
+	}
+	require.NoError(t, errGr.Wait())
+	require.Equal(t, int64(1), atomic.LoadInt64(&deletes))
+
+	_, err = dm.Get(ctx, "refcount")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	t.Run("Key not found", func(t *testing.T) {
+		_, _, err := dm.DecrAndDeleteAtZero(ctx, "refcount", 1)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+
+	t.Run("Not an integer", func(t *testing.T) {
+		require.NoError(t, dm.Put(ctx, "not-an-integer", "foobar", nil))
+		_, _, err := dm.DecrAndDeleteAtZero(ctx, "not-an-integer", 1)
+		require.ErrorIs(t, err, ErrNotAnInteger)
+	})
+}
+
+func TestDMap_decrAndDeleteAtZeroCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	// The commands are sent to the member that doesn't own the key, the other
+	// decrements run on the owner.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("atomic_test", "refcount")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	const refs = 1000
+	_, err = owner.Incr(ctx, "refcount", refs)
+	require.NoError(t, err)
+
+	var deletes int64
+	var errGr errgroup.Group
+	for i := 0; i < refs; i++ {
+		i := i
+		errGr.Go(func() error {
+			if i%2 == 0 {
+				_, deleted, err := owner.DecrAndDeleteAtZero(ctx, "refcount", 1)
+				if err != nil {
+					return err
+				}
+				if deleted {
+					atomic.AddInt64(&deletes, 1)
+				}
+				return nil
+			}
+			cmd := protocol.NewDecrAndDeleteAtZero("atomic_test", "refcount", 1).Command(ctx)
+			rc := other.client.Get(other.rt.This().String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return err
+			}
+			result, err := cmd.Result()
+			if err != nil {
+				return err
+			}
+			if result[1].(int64) == 1 {
+				atomic.AddInt64(&deletes, 1)
+			}
+			return nil
+		})
+	}
+	require.NoError(t, errGr.Wait())
+	require.Equal(t, int64(1), atomic.LoadInt64(&deletes))
+
+	_, err = owner.Get(ctx, "refcount")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestDMap_Atomic_GetDel(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	_, err = dm1.GetDel(ctx, "token")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		require.NoError(t, dm1.Put(ctx, key, testutil.ToVal(i), nil))
+
+		// Only one of the concurrent callers gets the value.
+		var consumed int32
+		var g errgroup.Group
+		for _, dm := range []*DMap{dm1, dm2, dm1, dm2} {
+			dm := dm
+			g.Go(func() error {
+				entry, err := dm.GetDel(ctx, key)
+				if errors.Is(err, ErrKeyNotFound) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if !bytes.Equal(testutil.ToVal(i), entry.Value()) {
+					return fmt.Errorf("unexpected value: %s", entry.Value())
+				}
+				atomic.AddInt32(&consumed, 1)
+				return nil
+			})
+		}
+		require.NoError(t, g.Wait())
+		require.Equal(t, int32(1), consumed)
+
+		_, err = dm2.Get(ctx, key)
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+func TestDMap_Atomic_Append(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+
+		// The first append creates the key, the concurrent appends both land.
+		length, err := dm1.Append(ctx, key, []byte("a"))
+		require.NoError(t, err)
+		require.Equal(t, 1, length)
+
+		var g errgroup.Group
+		for _, dm := range []*DMap{dm1, dm2} {
+			dm := dm
+			g.Go(func() error {
+				_, err := dm.Append(ctx, key, []byte("bb"))
+				return err
+			})
+		}
+		require.NoError(t, g.Wait())
+
+		entry, err := dm2.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, []byte("abbbb"), entry.Value())
+	}
+}
+
+func TestDMap_Atomic_GetAndReset(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("atomic_test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	// The increments run on the owner, the resets run on both members.
+	owner, other := dm1, dm2
+	if !s1.primary.PartitionByHKey(partitions.HKey("atomic_test", "counter")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, dm1
+	}
+
+	const increments = 1000
+	var drained int64
+	var errGr errgroup.Group
+	for i := 0; i < increments; i++ {
+		errGr.Go(func() error {
+			_, err := owner.Incr(ctx, "counter", 1)
+			return err
+		})
+		if i%100 == 0 {
+			dm := owner
+			if i%200 == 0 {
+				dm = other
+			}
+			errGr.Go(func() error {
+				value, err := dm.GetAndReset(ctx, "counter")
+				if err != nil {
+					return err
+				}
+				atomic.AddInt64(&drained, int64(value))
+				return nil
+			})
+		}
+	}
+	require.NoError(t, errGr.Wait())
+
+	value, err := other.GetAndReset(ctx, "counter")
+	require.NoError(t, err)
+	drained += int64(value)
+	require.Equal(t, int64(increments), drained)
+
+	value, err = other.GetAndReset(ctx, "counter")
+	require.NoError(t, err)
+	require.Equal(t, 0, value)
+
+	t.Run("Key not found", func(t *testing.T) {
+		value, err := dm1.GetAndReset(ctx, "absent")
+		require.NoError(t, err)
+		require.Equal(t, 0, value)
+
+		_, err = dm1.Get(ctx, "absent")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+
+	t.Run("Not an integer", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "not-an-integer", "foobar", nil))
+		_, err := dm1.GetAndReset(ctx, "not-an-integer")
+		require.ErrorIs(t, err, ErrNotAnInteger)
+	})
+}
+
+func TestDMap_getAndResetCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	// The increments run on the owner, the resets are sent to the other member.
+	owner, other := s1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("atomic_test", "counter")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = s2, s1
+	}
+
+	process := func(s *Service, cmd redis.Cmder) error {
+		rc := s.client.Get(s.rt.This().String())
+		return rc.Process(ctx, cmd)
+	}
+
+	const increments = 1000
+	var done int32
+	var drained int64
+	var incrGr, resetGr errgroup.Group
+	for i := 0; i < 10; i++ {
+		incrGr.Go(func() error {
+			for j := 0; j < increments/10; j++ {
+				if err := process(owner, protocol.NewIncr("atomic_test", "counter", 1).Command(ctx)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	for i := 0; i < 4; i++ {
+		resetGr.Go(func() error {
+			for atomic.LoadInt32(&done) == 0 {
+				cmd := protocol.NewGetAndReset("atomic_test", "counter").Command(ctx)
+				if err := process(other, cmd); err != nil {
+					return err
+				}
+				atomic.AddInt64(&drained, cmd.Val())
+			}
+			return nil
+		})
+	}
+	require.NoError(t, incrGr.Wait())
+	atomic.StoreInt32(&done, 1)
+	require.NoError(t, resetGr.Wait())
+
+	cmd := protocol.NewGetAndReset("atomic_test", "counter").Command(ctx)
+	require.NoError(t, process(other, cmd))
+	require.Equal(t, int64(increments), drained+cmd.Val())
+}
+
 func TestDMap_Atomic_IncrByFloat(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	s := cluster.AddMember(nil).(*Service)
@@ -234,6 +849,48 @@ func TestDMap_Atomic_IncrByFloat(t *testing.T) {
 	require.Equal(t, 120.0000000000002, res)
 }
 
+func TestDMap_Atomic_IncrByFloat_InvalidFloat(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("atomic_test")
+	require.NoError(t, err)
+
+	t.Run("Delta is Inf", func(t *testing.T) {
+		_, err := dm.IncrByFloat(ctx, "delta", math.Inf(1))
+		require.ErrorIs(t, err, ErrInvalidFloat)
+
+		_, err = dm.IncrByFloat(ctx, "delta", math.NaN())
+		require.ErrorIs(t, err, ErrInvalidFloat)
+
+		_, err = dm.Get(ctx, "delta")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+
+	t.Run("Stored value is NaN", func(t *testing.T) {
+		require.NoError(t, dm.Put(ctx, "stored", math.NaN(), nil))
+
+		_, err := dm.IncrByFloat(ctx, "stored", 1.2)
+		require.ErrorIs(t, err, ErrInvalidFloat)
+	})
+
+	t.Run("Result overflows to Inf", func(t *testing.T) {
+		_, err := dm.IncrByFloat(ctx, "overflow", math.MaxFloat64)
+		require.NoError(t, err)
+
+		_, err = dm.IncrByFloat(ctx, "overflow", math.MaxFloat64)
+		require.ErrorIs(t, err, ErrInvalidFloat)
+
+		gr, err := dm.Get(ctx, "overflow")
+		require.NoError(t, err)
+		var res float64
+		require.NoError(t, resp.Scan(gr.Value(), &res))
+		require.Equal(t, math.MaxFloat64, res)
+	})
+}
+
 func TestDMap_incrCommandHandler(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	s := cluster.AddMember(nil).(*Service)
@@ -69,6 +69,25 @@ func (dm *DMap) mergeFragments(part *partitions.Partition, fp *fragmentPack) err
 	})
 }
 
+// isWriteFenced returns true if the primary fragment must not accept a write anymore. It's
+// true if the partition has a new owner or the fragment has been wiped out after a move.
+// The caller must hold the fragment's lock. fragment.Move holds the same lock, so the
+// writes that wait for a moving fragment observe the new owner after the move step.
+func (dm *DMap) isWriteFenced(hkey uint64, f *fragment) bool {
+	if !dm.s.config.EnableWriteFencing {
+		return false
+	}
+
+	select {
+	case <-f.ctx.Done():
+		return true
+	default:
+	}
+
+	owner := dm.s.primary.PartitionByHKey(hkey).Owner()
+	return !owner.CompareByName(dm.s.rt.This())
+}
+
 func (s *Service) checkOwnership(part *partitions.Partition) bool {
 	owners := part.Owners()
 	for _, owner := range owners {
@@ -127,7 +146,7 @@ func (s *Service) moveFragmentCommandHandler(conn redcon.Conn, cmd redcon.Comman
 	}
 	s.log.V(2).Printf("[INFO] Received DMap (kind: %s): %s on PartID: %d", fp.Kind, fp.Name, fp.PartID)
 
-	dm, err := s.NewDMap(fp.Name)
+	dm, err := s.newDMap(fp.Name)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -18,12 +18,16 @@ import (
 	"context"
 	"encoding/json"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/buraksezer/olric/events"
+	"github.com/buraksezer/olric/internal/cluster/balancer"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/environment"
 	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/resp"
 	"github.com/buraksezer/olric/internal/testcluster"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
@@ -216,3 +220,98 @@ L:
 		require.NotEqual(t, part.Owner().ID, db1.rt.This().ID)
 	}
 }
+
+func TestDMap_Balancer_WriteFencing(t *testing.T) {
+	newEnvironment := func() *environment.Environment {
+		c := testutil.NewConfig()
+		c.EnableWriteFencing = true
+		return testcluster.NewEnvironment(c)
+	}
+
+	cluster := testcluster.New(NewService)
+	e1 := newEnvironment()
+	db1 := cluster.AddMember(e1).(*Service)
+	defer cluster.Shutdown()
+
+	dm1, err := db1.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	var mtx sync.Mutex
+	latest := make(map[string]int)
+
+	// Write continuously to the same keys, and keep the latest acknowledged values.
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				key := "fencing-test." + strconv.Itoa(w) + "." + strconv.Itoa(i%100)
+				if err := dm1.Put(ctx, key, i, nil); err != nil {
+					continue
+				}
+				mtx.Lock()
+				latest[key] = i
+				mtx.Unlock()
+			}
+		}(w)
+	}
+
+	<-time.After(100 * time.Millisecond)
+	// Adding a new member moves some partitions to it.
+	db2 := cluster.AddMember(newEnvironment()).(*Service)
+	<-time.After(100 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+
+	// A fragment is moved table by table, move the remaining tables.
+	for i := 0; i < 10; i++ {
+		e1.Get("balancer").(*balancer.Balancer).BalanceEagerly()
+	}
+
+	dm2, err := db2.NewDMap("mymap")
+	require.NoError(t, err)
+	for key, value := range latest {
+		e, err := dm2.Get(ctx, key)
+		require.NoError(t, err)
+		var current int
+		require.NoError(t, resp.Scan(e.Value(), &current))
+		require.Equal(t, value, current, key)
+	}
+
+	t.Run("Forward the writes on a moved partition", func(t *testing.T) {
+		var key string
+		for i := 0; ; i++ {
+			key = "forward-test." + strconv.Itoa(i)
+			hkey := partitions.HKey("mymap", key)
+			if db1.primary.PartitionByHKey(hkey).Owner().CompareByName(db2.rt.This()) {
+				break
+			}
+		}
+
+		// Simulate a write that passed the ownership check before the routing table is updated.
+		e := newEnv(ctx)
+		e.putConfig = &PutConfig{}
+		e.dmap = "mymap"
+		e.key = key
+		e.value = []byte("value")
+		e.hkey = partitions.HKey("mymap", key)
+		require.NoError(t, dm1.putOnCluster(e))
+
+		part := db1.primary.PartitionByHKey(e.hkey)
+		f, err := dm1.loadFragment(part)
+		require.NoError(t, err)
+		require.False(t, f.storage.Check(e.hkey))
+
+		value, err := dm2.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), value.Value())
+	})
+}
@@ -0,0 +1,177 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// MaxBitOffset is the largest bit offset that SetBit and GetBit accept. The
+// bitmap of the largest offset is 512MB, like Redis.
+const MaxBitOffset = 1<<32 - 1
+
+func validateBitOffset(offset int) error {
+	if offset < 0 || int64(offset) > MaxBitOffset {
+		return fmt.Errorf("%w: bit offset is out of range: %d", protocol.ErrInvalidArgument, offset)
+	}
+	return nil
+}
+
+func validateBit(value int) error {
+	if value != 0 && value != 1 {
+		return fmt.Errorf("%w: bit value must be 0 or 1: %d", protocol.ErrInvalidArgument, value)
+	}
+	return nil
+}
+
+// bitAt returns the bit at the offset. The bits of a byte are numbered from the most
+// significant bit, and the bits beyond the end of the value are zero.
+func bitAt(value []byte, offset int) int {
+	i := offset / 8
+	if i >= len(value) {
+		return 0
+	}
+	return int(value[i]>>(7-uint(offset%8))) & 1
+}
+
+// setBit runs on the partition owner of the key.
+func (dm *DMap) setBit(e *env, offset, bit int) (int, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	var current []byte
+	var ttl int64
+	entry, err := dm.Get(e.ctx, e.key)
+	if err == nil {
+		current, ttl = entry.Value(), entry.TTL()
+	} else if !errors.Is(err, ErrKeyNotFound) {
+		return 0, err
+	}
+
+	old := bitAt(current, offset)
+
+	// Grow the bitmap with zero bytes to cover the offset.
+	size := offset/8 + 1
+	if size < len(current) {
+		size = len(current)
+	}
+	value := make([]byte, size)
+	copy(value, current)
+
+	mask := byte(1) << (7 - uint(offset%8))
+	if bit == 1 {
+		value[offset/8] |= mask
+	} else {
+		value[offset/8] &^= mask
+	}
+
+	e.value = value
+	if ttl != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Until(time.UnixMilli(ttl))
+	}
+	if err = dm.put(e); err != nil {
+		return 0, err
+	}
+	return old, nil
+}
+
+// getBit runs on the partition owner of the key.
+func (dm *DMap) getBit(e *env, offset int) (int, error) {
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return bitAt(entry.Value(), offset), nil
+}
+
+// SetBit sets or clears the bit at the offset in the value stored at the key, and returns
+// the previous bit. The value is treated as a bitmap, the bit 0 is the most significant bit
+// of the first byte. The value is zero-padded if the offset is beyond its end, and it's
+// created if the key doesn't exist. The TTL is preserved. The operation runs on the partition
+// owner of the key, like Redis SETBIT.
+func (dm *DMap) SetBit(ctx context.Context, key string, offset, value int) (int, error) {
+	if err := validateBitOffset(offset); err != nil {
+		return 0, err
+	}
+	if err := validateBit(value); err != nil {
+		return 0, err
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.setBit(e, offset, value)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewSetBit(dm.name, key, offset, value).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	old, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(old), nil
+}
+
+// GetBit returns the bit at the offset in the value stored at the key. The bits beyond
+// the end of the value and the bits of an absent key are 0, like Redis GETBIT.
+func (dm *DMap) GetBit(ctx context.Context, key string, offset int) (int, error) {
+	if err := validateBitOffset(offset); err != nil {
+		return 0, err
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.getBit(e, offset)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewGetBit(dm.name, key, offset).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	bit, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(bit), nil
+}
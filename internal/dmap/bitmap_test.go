@@ -0,0 +1,177 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_SetBit(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Set the bits on both members concurrently, the non-owner redirects the request.
+	var errGr errgroup.Group
+	for i := 0; i < 64; i++ {
+		i := i
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			_, err := dm.SetBit(ctx, "flags", i, 1)
+			return err
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	value, err := dm1.Get(ctx, "flags")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, value.Value())
+
+	// SetBit returns the previous bit.
+	old, err := dm2.SetBit(ctx, "flags", 7, 0)
+	require.NoError(t, err)
+	require.Equal(t, 1, old)
+
+	old, err = dm1.SetBit(ctx, "flags", 7, 0)
+	require.NoError(t, err)
+	require.Equal(t, 0, old)
+
+	bit, err := dm2.GetBit(ctx, "flags", 7)
+	require.NoError(t, err)
+	require.Equal(t, 0, bit)
+
+	t.Run("Grow the bitmap", func(t *testing.T) {
+		old, err := dm1.SetBit(ctx, "flags", 100, 1)
+		require.NoError(t, err)
+		require.Equal(t, 0, old)
+
+		value, err := dm2.Get(ctx, "flags")
+		require.NoError(t, err)
+		require.Len(t, value.Value(), 13)
+
+		for _, offset := range []int{64, 99, 101, 103} {
+			bit, err := dm2.GetBit(ctx, "flags", offset)
+			require.NoError(t, err)
+			require.Equal(t, 0, bit)
+		}
+		bit, err := dm2.GetBit(ctx, "flags", 100)
+		require.NoError(t, err)
+		require.Equal(t, 1, bit)
+	})
+
+	t.Run("Unset bits", func(t *testing.T) {
+		bit, err := dm1.GetBit(ctx, "flags", 1000)
+		require.NoError(t, err)
+		require.Equal(t, 0, bit)
+
+		for i := 0; i < 10; i++ {
+			bit, err := dm2.GetBit(ctx, testutil.ToKey(i), 3)
+			require.NoError(t, err)
+			require.Equal(t, 0, bit)
+		}
+	})
+
+	t.Run("Existing value", func(t *testing.T) {
+		// 'a' is 0b01100001, SETBIT 6 1 and SETBIT 7 0 turns it into 'b'.
+		require.NoError(t, dm1.Put(ctx, "mystring", []byte("a"), nil))
+		_, err := dm2.SetBit(ctx, "mystring", 6, 1)
+		require.NoError(t, err)
+		old, err := dm2.SetBit(ctx, "mystring", 7, 0)
+		require.NoError(t, err)
+		require.Equal(t, 1, old)
+
+		value, err := dm1.Get(ctx, "mystring")
+		require.NoError(t, err)
+		require.Equal(t, "b", string(value.Value()))
+	})
+
+	t.Run("Invalid arguments", func(t *testing.T) {
+		_, err := dm1.SetBit(ctx, "flags", -1, 1)
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+
+		_, err = dm1.SetBit(ctx, "flags", 1, 2)
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+
+		_, err = dm1.GetBit(ctx, "flags", -1)
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+	})
+}
+
+func TestDMap_setBitCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The commands are sent to the member that doesn't own the key, the other
+	// writes run on the owner. Every bit must survive.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mybitmap")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	const numBits = 1000
+	var errGr errgroup.Group
+	for i := 0; i < numBits; i++ {
+		offset := i
+		if i%2 == 0 {
+			errGr.Go(func() error {
+				_, err := owner.SetBit(ctx, "mybitmap", offset, 1)
+				return err
+			})
+			continue
+		}
+		errGr.Go(func() error {
+			cmd := protocol.NewSetBit("mydmap", "mybitmap", offset, 1).Command(ctx)
+			rc := other.client.Get(other.rt.This().String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return err
+			}
+			return cmd.Err()
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	rc := other.client.Get(other.rt.This().String())
+	for i := 0; i < numBits; i++ {
+		cmd := protocol.NewGetBit("mydmap", "mybitmap", i).Command(ctx)
+		require.NoError(t, rc.Process(ctx, cmd))
+		require.Equal(t, int64(1), cmd.Val(), "offset: %d", i)
+	}
+}
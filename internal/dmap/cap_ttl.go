@@ -0,0 +1,146 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// localCapTTL walks the primary copies on this member and sets the TTL of the keys
+// without a TTL or with a TTL longer than maxTTL to maxTTL. It returns the number of
+// the adjusted keys. The keys are collected under the read lock of the fragments, and
+// their TTLs are updated with Expire, so the replicas are updated as well.
+func (s *Service) localCapTTL(ctx context.Context, name string, maxTTL time.Duration) (int, error) {
+	dm, err := s.getDMap(name)
+	if errors.Is(err, ErrDMapNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var keys []string
+	now := time.Now().UnixNano() / 1000000
+	limit := now + maxTTL.Milliseconds()
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		f.RLock()
+		f.storage.RangeHKey(func(hkey uint64) bool {
+			ttl, err := f.storage.GetTTL(hkey)
+			if err != nil || (ttl != 0 && ttl <= limit) {
+				// Already within the cap, or expired but not evicted yet.
+				return true // continue
+			}
+			key, err := f.storage.GetKey(hkey)
+			if err != nil {
+				return true
+			}
+			keys = append(keys, key)
+			return true
+		})
+		f.RUnlock()
+	}
+
+	var count int
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+		err := dm.Expire(ctx, key, maxTTL)
+		if errors.Is(err, ErrKeyNotFound) {
+			// Deleted in the meantime.
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (dm *DMap) capTTLOnCluster(ctx context.Context, maxTTL time.Duration) (int, error) {
+	var total int64
+
+	num := int64(runtime.NumCPU())
+	sem := semaphore.NewWeighted(num)
+
+	var members []discovery.Member
+	m := dm.s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		members = append(members, member)
+		return true
+	})
+	m.RUnlock()
+
+	var g errgroup.Group
+	for _, item := range members {
+		member := item
+		g.Go(func() error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+
+			if member.CompareByID(dm.s.rt.This()) {
+				count, err := dm.s.localCapTTL(ctx, dm.name, maxTTL)
+				atomic.AddInt64(&total, int64(count))
+				return err
+			}
+
+			cmd := protocol.NewCapTTL(dm.name, maxTTL.Milliseconds()).SetLocal().Command(ctx)
+			rc := dm.s.client.Get(member.String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return protocol.ConvertError(err)
+			}
+			atomic.AddInt64(&total, cmd.Val())
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+// CapTTL sets the TTL of the keys without a TTL or with a TTL longer than maxTTL to
+// maxTTL, and returns the number of the adjusted keys. The members run it concurrently
+// on their primary copies, so the cost is O(N) in the number of keys. The keys written
+// after a member walks its fragments are not adjusted.
+func (dm *DMap) CapTTL(ctx context.Context, maxTTL time.Duration) (int, error) {
+	if maxTTL.Milliseconds() <= 0 {
+		return 0, fmt.Errorf("%w: max TTL must be at least one millisecond: %s", protocol.ErrInvalidArgument, maxTTL)
+	}
+	return dm.capTTLOnCluster(ctx, maxTTL)
+}
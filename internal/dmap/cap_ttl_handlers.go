@@ -0,0 +1,50 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) capTTLCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	capTTLCmd, err := protocol.ParseCapTTLCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	maxTTL := time.Duration(capTTLCmd.MaxTTL) * time.Millisecond
+
+	var count int
+	if capTTLCmd.Local {
+		count, err = s.localCapTTL(s.ctx, capTTLCmd.DMap, maxTTL)
+	} else {
+		var dm *DMap
+		dm, err = s.getOrCreateDMap(capTTLCmd.DMap)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		count, err = dm.capTTLOnCluster(s.ctx, maxTTL)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(count)
+}
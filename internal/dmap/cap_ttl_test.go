@@ -0,0 +1,82 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_CapTTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	shortTTLs := make(map[string]int64)
+	for i := 0; i < 30; i++ {
+		var pc *PutConfig
+		switch {
+		case i < 10:
+			// Longer than the cap.
+			pc = &PutConfig{HasPX: true, PX: time.Hour}
+		case i < 20:
+			// Shorter than the cap, it's not changed.
+			pc = &PutConfig{HasPX: true, PX: 30 * time.Second}
+		}
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc))
+		if pc != nil && pc.PX < time.Minute {
+			e, err := dm1.Get(ctx, testutil.ToKey(i))
+			require.NoError(t, err)
+			shortTTLs[testutil.ToKey(i)] = e.TTL()
+		}
+	}
+
+	count, err := dm2.CapTTL(ctx, time.Minute)
+	require.NoError(t, err)
+	// 10 keys with a long TTL and 10 keys without a TTL.
+	require.Equal(t, 20, count)
+
+	limit := time.Now().Add(time.Minute).UnixNano() / 1000000
+	for i := 0; i < 30; i++ {
+		e, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.NotZero(t, e.TTL())
+		require.LessOrEqual(t, e.TTL(), limit)
+		if ttl, ok := shortTTLs[testutil.ToKey(i)]; ok {
+			require.Equal(t, ttl, e.TTL())
+		}
+	}
+
+	// All keys are within the cap now.
+	count, err = dm1.CapTTL(ctx, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	_, err = dm1.CapTTL(ctx, 0)
+	require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+}
@@ -0,0 +1,594 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// DefaultChangeLogSize is the number of the latest changes kept for every DMap on a
+	// member. A stream reads the changes from the log, a consumer that falls behind more
+	// than this loses the changes in between and receives a ChangeLag event.
+	DefaultChangeLogSize = 4096
+
+	// DefaultChangeLogBytes is the maximum size of the keys and the values kept in the
+	// change log of a DMap on a member. The oldest changes are dropped to stay under it,
+	// like the ones over DefaultChangeLogSize.
+	DefaultChangeLogBytes = 16 << 20
+
+	// DefaultChangeLogRetention is how long a member keeps recording the changes of a DMap
+	// after the last read of a stream. The changes are not recorded if no stream reads them,
+	// so a consumer that resumes later than this gets the puts replayed from the storage,
+	// but not the deletes and the expirations.
+	DefaultChangeLogRetention = 30 * time.Second
+)
+
+// changeStreamBatchSize is the maximum number of changes a stream reads at once.
+const changeStreamBatchSize = 128
+
+// changeReadWait is the maximum time a read waits for a new change before it returns an
+// empty batch. The streams read again right away, so the recording goes on.
+const changeReadWait = time.Second
+
+// changeOverhead is the size of a change without its key and value in the change log.
+const changeOverhead = 64
+
+// ChangeType denotes the kind of modification delivered by a change stream.
+type ChangeType uint8
+
+const (
+	// ChangePut is emitted when a key is inserted or updated.
+	ChangePut ChangeType = iota + 1
+
+	// ChangeDelete is emitted when a key is deleted by a client.
+	ChangeDelete
+
+	// ChangeExpire is emitted when an expired or idle key is evicted, or a key
+	// is evicted by the LRU policy.
+	ChangeExpire
+
+	// ChangeLag is emitted when the consumer was too slow and the changes it hasn't
+	// read yet have been dropped from the change log. Timestamp is set to the timestamp
+	// of the last change delivered before the gap, the stream goes on with the oldest
+	// change in the log. A new stream from that timestamp replays the puts in the gap,
+	// but the deletes and expirations in the gap are lost.
+	ChangeLag
+)
+
+func (c ChangeType) String() string {
+	switch c {
+	case ChangePut:
+		return "put"
+	case ChangeDelete:
+		return "delete"
+	case ChangeExpire:
+		return "expire"
+	case ChangeLag:
+		return "lag"
+	default:
+		return "unknown"
+	}
+}
+
+// Change is a single modification on a DMap.
+type Change struct {
+	Type      ChangeType
+	DMap      string
+	Key       string
+	Value     []byte
+	Timestamp int64 // in nanoseconds
+
+	// Sequence is the position of the change in the change log of the member that
+	// published it, it's zero for the entries replayed from the storage.
+	Sequence uint64
+}
+
+func (c *Change) size() int {
+	return len(c.Key) + len(c.Value) + changeOverhead
+}
+
+// ChangeBatch is a batch of changes read from a member. PartID and Sequence are the
+// position to read the next batch from.
+type ChangeBatch struct {
+	Changes  []Change
+	PartID   uint64
+	Sequence uint64
+}
+
+// changeLog is a bounded log of the latest changes of a DMap on this member. The changes
+// are numbered from 1, changes[0] is the change with the sequence first. It records the
+// changes only while a stream reads them, the values of an encrypted DMap are kept
+// encrypted and decrypted on delivery.
+type changeLog struct {
+	mtx      sync.Mutex
+	changes  []Change
+	first    uint64
+	next     uint64
+	bytes    int
+	maxSize  int
+	maxBytes int
+	// truncated is the timestamp of the newest change dropped from the log.
+	truncated int64
+	// started is the time the log started recording, the changes before it are not in
+	// the log.
+	started int64
+	// leased is the time the log stops recording, it's extended by every read.
+	leased int64
+	notify chan struct{}
+}
+
+func newChangeLog(maxSize, maxBytes int) *changeLog {
+	return &changeLog{
+		first:    1,
+		next:     1,
+		maxSize:  maxSize,
+		maxBytes: maxBytes,
+	}
+}
+
+// recording returns true if a stream has read the log recently. The caller must hold the lock.
+func (l *changeLog) recording(now int64) bool {
+	return now < l.leased
+}
+
+// subscribe extends the recording of the log by retention. The log starts recording from
+// scratch if nothing read it in the meantime.
+func (l *changeLog) subscribe(retention time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now().UnixNano()
+	if !l.recording(now) {
+		l.reset()
+		l.started = now
+	}
+	l.leased = now + retention.Nanoseconds()
+}
+
+// reset drops the changes in the log. The sequences go on, so the streams that read the
+// dropped changes see the gap. The caller must hold the lock.
+func (l *changeLog) reset() {
+	l.changes = nil
+	l.bytes = 0
+	l.first = l.next
+	l.truncated = 0
+}
+
+// dropOldest drops the oldest change in the log. The caller must hold the lock.
+func (l *changeLog) dropOldest() {
+	oldest := &l.changes[0]
+	if oldest.Timestamp > l.truncated {
+		l.truncated = oldest.Timestamp
+	}
+	l.bytes -= oldest.size()
+	l.changes[0] = Change{}
+	l.changes = l.changes[1:]
+	l.first++
+}
+
+func (l *changeLog) append(change Change) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if !l.recording(time.Now().UnixNano()) {
+		if len(l.changes) > 0 {
+			// Nothing reads the log anymore, free the memory.
+			l.reset()
+		}
+		return
+	}
+
+	if change.Value != nil {
+		// The value may be reused by the caller.
+		change.Value = append([]byte(nil), change.Value...)
+	}
+	change.Sequence = l.next
+	l.changes = append(l.changes, change)
+	l.bytes += change.size()
+	l.next++
+	for len(l.changes) > 0 && (len(l.changes) > l.maxSize || l.bytes > l.maxBytes) {
+		l.dropOldest()
+	}
+
+	if l.notify != nil {
+		// Wake up the streams waiting for a new change.
+		close(l.notify)
+		l.notify = nil
+	}
+}
+
+// position returns the sequence of the first change in the log written at or after since.
+// It also reports whether the log holds every change of this member since then.
+func (l *changeLog) position(since int64) (uint64, bool) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	seq := l.first
+	for ; seq < l.next; seq++ {
+		if l.changes[seq-l.first].Timestamp >= since {
+			break
+		}
+	}
+	return seq, since >= l.started && since > l.truncated
+}
+
+// read copies up to max changes starting at seq. It returns the sequence after them and
+// whether the changes before the oldest one in the log have been dropped. If there is no
+// new change, it returns a channel that's closed when one is appended.
+func (l *changeLog) read(seq uint64, max int) ([]Change, uint64, bool, <-chan struct{}) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	var lost bool
+	if seq < l.first || seq > l.next {
+		// The changes are dropped, or the sequence belongs to a previous run of the member.
+		seq, lost = l.first, true
+	}
+	if seq == l.next {
+		if l.notify == nil {
+			l.notify = make(chan struct{})
+		}
+		return nil, seq, lost, l.notify
+	}
+
+	var changes []Change
+	for ; seq < l.next && len(changes) < max; seq++ {
+		changes = append(changes, l.changes[seq-l.first])
+	}
+	return changes, seq, lost, nil
+}
+
+// changeFeed keeps the change logs of the DMaps on this member.
+type changeFeed struct {
+	mtx       sync.RWMutex
+	maxSize   int
+	maxBytes  int
+	retention time.Duration
+	logs      map[string]*changeLog
+}
+
+func newChangeFeed(maxSize, maxBytes int, retention time.Duration) *changeFeed {
+	return &changeFeed{
+		maxSize:   maxSize,
+		maxBytes:  maxBytes,
+		retention: retention,
+		logs:      make(map[string]*changeLog),
+	}
+}
+
+func (c *changeFeed) log(name string) *changeLog {
+	c.mtx.RLock()
+	l, ok := c.logs[name]
+	c.mtx.RUnlock()
+	if ok {
+		return l
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	l, ok = c.logs[name]
+	if !ok {
+		l = newChangeLog(c.maxSize, c.maxBytes)
+		c.logs[name] = l
+	}
+	return l
+}
+
+// publish appends the change to the log of its DMap, if a stream reads it.
+func (c *changeFeed) publish(change Change) {
+	c.mtx.RLock()
+	l, ok := c.logs[change.DMap]
+	c.mtx.RUnlock()
+	if !ok {
+		// No stream has read the changes of the DMap on this member.
+		return
+	}
+	l.append(change)
+}
+
+func (dm *DMap) publishChange(kind ChangeType, key string, value []byte, timestamp int64) {
+	dm.s.changes.publish(Change{
+		Type:      kind,
+		DMap:      dm.name,
+		Key:       key,
+		Value:     value,
+		Timestamp: timestamp,
+	})
+}
+
+// replayPartition collects the entries of a local primary partition whose timestamp is
+// equal to or greater than since. The result is sorted by timestamp.
+func (dm *DMap) replayPartition(partID uint64, since int64) []Change {
+	part := dm.s.primary.PartitionByID(partID)
+	f, err := dm.loadFragment(part)
+	if err != nil {
+		return nil
+	}
+
+	var changes []Change
+	f.RLock()
+	f.storage.Range(func(_ uint64, e storage.Entry) bool {
+		if e.Timestamp() < since || isKeyExpired(e.TTL()) {
+			return true
+		}
+		value, err := dm.decryptValue(e.Key(), e.Value())
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to replay the change of key: %s on DMap: %s: %v", e.Key(), dm.name, err)
+			return true
+		}
+		changes = append(changes, Change{
+			Type:      ChangePut,
+			DMap:      dm.name,
+			Key:       e.Key(),
+			Value:     append([]byte(nil), value...),
+			Timestamp: e.Timestamp(),
+		})
+		return true
+	})
+	f.RUnlock()
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Timestamp < changes[j].Timestamp
+	})
+	return changes
+}
+
+// decryptChanges decrypts the values of the changes read from the log. The changes that
+// cannot be decrypted are dropped.
+func (dm *DMap) decryptChanges(changes []Change) []Change {
+	decrypted := changes[:0]
+	for _, change := range changes {
+		if change.Value != nil {
+			value, err := dm.decryptValue(change.Key, change.Value)
+			if err != nil {
+				dm.s.log.V(3).Printf("[ERROR] Failed to deliver the change of key: %s on DMap: %s: %v", change.Key, dm.name, err)
+				continue
+			}
+			// The value in the log is shared by the streams.
+			change.Value = append([]byte(nil), value...)
+		}
+		decrypted = append(decrypted, change)
+	}
+	return decrypted
+}
+
+// ReadChanges reads the next batch of the changes that this member publishes as the
+// partition owner. The first read of a stream is done with a zero sequence, the next
+// ones with the position in the previous batch. Every read extends the recording of the
+// change log by DefaultChangeLogRetention.
+//
+// If the log doesn't hold every change since then, the first batches replay the entries
+// written at or after since from the storage, one partition at a time, then the changes in
+// the log follow. If there is no change, it waits for one up to a second and returns an
+// empty batch. A ChangeLag event is returned if the changes after the position have been
+// dropped from the log, its timestamp is set by the caller.
+func (dm *DMap) ReadChanges(ctx context.Context, since int64, partID, seq uint64) (*ChangeBatch, error) {
+	l := dm.s.changes.log(dm.name)
+	l.subscribe(dm.s.changes.retention)
+
+	count := dm.s.config.PartitionCount
+	if seq == 0 {
+		// Take the position before replaying to avoid missing any change.
+		var complete bool
+		seq, complete = l.position(since)
+		partID = 0
+		if complete {
+			partID = count
+		}
+	}
+
+	batch := &ChangeBatch{PartID: partID, Sequence: seq}
+	if partID < count {
+		for ; batch.PartID < count && len(batch.Changes) < changeStreamBatchSize; batch.PartID++ {
+			batch.Changes = append(batch.Changes, dm.replayPartition(batch.PartID, since)...)
+		}
+		return batch, nil
+	}
+
+	timer := time.NewTimer(changeReadWait)
+	defer timer.Stop()
+	for {
+		changes, next, lost, wait := l.read(batch.Sequence, changeStreamBatchSize)
+		if lost {
+			batch.Changes = append(batch.Changes, Change{Type: ChangeLag, DMap: dm.name})
+		}
+		batch.Changes = append(batch.Changes, dm.decryptChanges(changes)...)
+		batch.Sequence = next
+		if wait == nil || len(batch.Changes) > 0 {
+			return batch, nil
+		}
+
+		select {
+		case <-wait:
+		case <-timer.C:
+			return batch, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// changeMembersInterval is the interval to look for the new members in a merged stream, and
+// to retry a member that has failed.
+const changeMembersInterval = time.Second
+
+// ChangeReader reads the next batch of changes from the member at addr. See ReadChanges.
+type ChangeReader func(ctx context.Context, addr string, since int64, partID, seq uint64) (*ChangeBatch, error)
+
+// MergeChanges merges the change streams of the cluster members into a single channel. members
+// returns the addresses of the members, it's called periodically to follow the new members.
+// The channel is closed when the context is done, wg waits for the background goroutines.
+// The changes of a member are in order, the changes of different members are interleaved.
+func MergeChanges(ctx context.Context, wg *sync.WaitGroup, since int64, members func(ctx context.Context) ([]string, error), read ChangeReader) <-chan Change {
+	out := make(chan Change)
+
+	var mtx sync.Mutex
+	streams := make(map[string]struct{})
+	// stream reads the changes of a member until the context is done or the member leaves
+	// the cluster.
+	stream := func(addr string, swg *sync.WaitGroup) {
+		defer swg.Done()
+		defer func() {
+			mtx.Lock()
+			delete(streams, addr)
+			mtx.Unlock()
+		}()
+
+		var partID, seq uint64
+		last := since
+		for {
+			batch, err := read(ctx, addr, since, partID, seq)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				current, err := members(ctx)
+				if err == nil && !containsMember(current, addr) {
+					return
+				}
+				select {
+				case <-time.After(changeMembersInterval):
+					continue
+				case <-ctx.Done():
+					return
+				}
+			}
+			partID, seq = batch.PartID, batch.Sequence
+
+			for _, change := range batch.Changes {
+				if change.Type == ChangeLag {
+					change.Timestamp = last
+				} else {
+					last = change.Timestamp
+				}
+				select {
+				case out <- change:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		var swg sync.WaitGroup
+		defer func() {
+			swg.Wait()
+			close(out)
+		}()
+
+		ticker := time.NewTicker(changeMembersInterval)
+		defer ticker.Stop()
+		for {
+			current, err := members(ctx)
+			if err == nil {
+				mtx.Lock()
+				for _, addr := range current {
+					if _, ok := streams[addr]; ok {
+						continue
+					}
+					streams[addr] = struct{}{}
+					swg.Add(1)
+					go stream(addr, &swg)
+				}
+				mtx.Unlock()
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func containsMember(members []string, addr string) bool {
+	for _, member := range members {
+		if member == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// memberAddrs returns the addresses of the cluster members.
+func (dm *DMap) memberAddrs(_ context.Context) ([]string, error) {
+	var addrs []string
+	m := dm.s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		addrs = append(addrs, member.String())
+		return true
+	})
+	m.RUnlock()
+	return addrs, nil
+}
+
+// readChangesOnMember reads the next batch of changes from the member at addr.
+func (dm *DMap) readChangesOnMember(ctx context.Context, addr string, since int64, partID, seq uint64) (*ChangeBatch, error) {
+	if addr == dm.s.rt.This().String() {
+		return dm.ReadChanges(ctx, since, partID, seq)
+	}
+
+	cmd := protocol.NewChanges(dm.name, since, partID, seq).Command(ctx)
+	rc := dm.s.client.Get(addr)
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	raw, err := cmd.Bytes()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	batch := &ChangeBatch{}
+	if err = msgpack.Unmarshal(raw, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// StreamChanges streams the put, delete and expire events of the DMap on the whole cluster,
+// starting from the changes written at or after since. It reads the changes from every
+// member with ReadChanges and merges them. Call the returned function to stop the stream.
+func (dm *DMap) StreamChanges(since int64) (<-chan Change, func()) {
+	ctx, cancel := context.WithCancel(dm.s.ctx)
+	return MergeChanges(ctx, &dm.s.wg, since, dm.memberAddrs, dm.readChangesOnMember), cancel
+}
+
+func (dm *DMap) publishPut(nt storage.Entry) {
+	dm.gossipPut(nt)
+	// The value is decrypted when the change is delivered.
+	dm.publishChange(ChangePut, nt.Key(), nt.Value(), nt.Timestamp())
+}
+
+func (dm *DMap) publishDelete(kind ChangeType, key string) {
+	dm.gossipDelete(key)
+	dm.publishChange(kind, key, nil, time.Now().UnixNano())
+}
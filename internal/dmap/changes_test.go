@@ -0,0 +1,437 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func readChange(t *testing.T, ch <-chan Change) Change {
+	select {
+	case change, ok := <-ch:
+		require.True(t, ok)
+		return change
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no change received")
+	}
+	return Change{}
+}
+
+// subscribeChanges starts recording the changes of the DMap on the members, like the first
+// read of a stream does. It returns a timestamp to stream the changes from, the log holds
+// every change since then.
+func subscribeChanges(name string, members ...*Service) int64 {
+	for _, s := range members {
+		s.changes.log(name).subscribe(s.changes.retention)
+	}
+	return time.Now().UnixNano()
+}
+
+func TestDMap_StreamChanges(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	changes, cancel := dm.StreamChanges(subscribeChanges("mydmap", s))
+	defer cancel()
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	_, err = dm.Delete(ctx, testutil.ToKey(0))
+	require.NoError(t, err)
+
+	var timestamp int64
+	for i := 0; i < 10; i++ {
+		change := readChange(t, changes)
+		require.Equal(t, ChangePut, change.Type)
+		require.Equal(t, "mydmap", change.DMap)
+		require.Equal(t, testutil.ToKey(i), change.Key)
+		require.Equal(t, testutil.ToVal(i), change.Value)
+		require.GreaterOrEqual(t, change.Timestamp, timestamp)
+		timestamp = change.Timestamp
+	}
+
+	change := readChange(t, changes)
+	require.Equal(t, ChangeDelete, change.Type)
+	require.Equal(t, testutil.ToKey(0), change.Key)
+}
+
+func TestDMap_StreamChanges_Replay(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	// The changes are not recorded before a stream reads them, the entries are
+	// replayed from the storage first.
+	changes, cancel := dm.StreamChanges(0)
+	replayed := make(map[string][]byte)
+	for i := 0; i < 10; i++ {
+		change := readChange(t, changes)
+		require.Equal(t, ChangePut, change.Type)
+		require.Zero(t, change.Sequence)
+		replayed[change.Key] = change.Value
+	}
+	for i := 0; i < 10; i++ {
+		require.Equal(t, testutil.ToVal(i), replayed[testutil.ToKey(i)])
+	}
+
+	// Then the changes in the log follow.
+
+	err = dm.Put(ctx, "live", []byte("value"), nil)
+	require.NoError(t, err)
+	change := readChange(t, changes)
+	require.Equal(t, ChangePut, change.Type)
+	require.Equal(t, "live", change.Key)
+
+	cancel()
+	_, ok := <-changes
+	require.False(t, ok)
+}
+
+func TestDMap_StreamChanges_Resume(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	changes, cancel := dm.StreamChanges(subscribeChanges("mydmap", s))
+	for i := 0; i < 5; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	var last int64
+	for i := 0; i < 5; i++ {
+		last = readChange(t, changes).Timestamp
+	}
+	cancel()
+
+	// The consumer is gone while the keys are deleted.
+	for i := 0; i < 5; i++ {
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	changes, cancel = dm.StreamChanges(last)
+	defer cancel()
+
+	// The last change may be delivered again.
+	change := readChange(t, changes)
+	if change.Type == ChangePut {
+		require.Equal(t, testutil.ToKey(4), change.Key)
+		change = readChange(t, changes)
+	}
+	for i := 0; i < 5; i++ {
+		require.Equal(t, ChangeDelete, change.Type)
+		require.Equal(t, testutil.ToKey(i), change.Key)
+		if i < 4 {
+			change = readChange(t, changes)
+		}
+	}
+}
+
+func TestDMap_StreamChanges_Slow_Consumer(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	changes, cancel := dm.StreamChanges(subscribeChanges("mydmap", s))
+	defer cancel()
+
+	// The consumer doesn't read anything until the writes are done.
+	numKeys := DefaultChangeLogSize / 2
+	for i := 0; i < numKeys; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < numKeys; i++ {
+		change := readChange(t, changes)
+		require.Equal(t, ChangePut, change.Type)
+		require.Equal(t, testutil.ToKey(i), change.Key)
+
+		change = readChange(t, changes)
+		require.Equal(t, ChangeDelete, change.Type)
+		require.Equal(t, testutil.ToKey(i), change.Key)
+	}
+}
+
+func TestDMap_ReadChanges_Lag(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	s.changes = newChangeFeed(16, DefaultChangeLogBytes, DefaultChangeLogRetention)
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	since := subscribeChanges("mydmap", s)
+	err = dm.Put(ctx, "first", []byte("value"), nil)
+	require.NoError(t, err)
+
+	batch, err := dm.ReadChanges(ctx, since, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, batch.Changes, 1)
+	require.Equal(t, "first", batch.Changes[0].Key)
+
+	// The consumer falls behind the log.
+	for i := 0; i < 32; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	batch, err = dm.ReadChanges(ctx, since, batch.PartID, batch.Sequence)
+	require.NoError(t, err)
+	require.Len(t, batch.Changes, 17)
+	require.Equal(t, ChangeLag, batch.Changes[0].Type)
+
+	// The stream goes on with the oldest change in the log.
+	for i, change := range batch.Changes[1:] {
+		require.Equal(t, ChangePut, change.Type)
+		require.Equal(t, testutil.ToKey(i+16), change.Key)
+	}
+}
+
+func TestDMap_ReadChanges_Lag_Bytes(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	// The log holds 16 changes at most.
+	value := bytes.Repeat([]byte("a"), 512)
+	key := testutil.ToKey(0)
+	s.changes = newChangeFeed(DefaultChangeLogSize, 16*(len(key)+len(value)+changeOverhead), DefaultChangeLogRetention)
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	since := subscribeChanges("mydmap", s)
+	err = dm.Put(ctx, key, value, nil)
+	require.NoError(t, err)
+
+	batch, err := dm.ReadChanges(ctx, since, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, batch.Changes, 1)
+
+	for i := 0; i < 32; i++ {
+		err = dm.Put(ctx, key, value, nil)
+		require.NoError(t, err)
+	}
+
+	l := s.changes.log("mydmap")
+	l.mtx.Lock()
+	require.LessOrEqual(t, l.bytes, l.maxBytes)
+	require.Len(t, l.changes, 16)
+	l.mtx.Unlock()
+
+	batch, err = dm.ReadChanges(ctx, since, batch.PartID, batch.Sequence)
+	require.NoError(t, err)
+	require.Len(t, batch.Changes, 17)
+	require.Equal(t, ChangeLag, batch.Changes[0].Type)
+}
+
+func TestMergeChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	members := func(_ context.Context) ([]string, error) {
+		return []string{"member-1", "member-2"}, nil
+	}
+	// Every member returns a put, then a lag, then waits.
+	read := func(ctx context.Context, addr string, _ int64, _, seq uint64) (*ChangeBatch, error) {
+		switch seq {
+		case 0:
+			return &ChangeBatch{
+				Changes:  []Change{{Type: ChangePut, Key: addr, Timestamp: 10}},
+				Sequence: 1,
+			}, nil
+		case 1:
+			return &ChangeBatch{
+				Changes:  []Change{{Type: ChangeLag}},
+				Sequence: 2,
+			}, nil
+		}
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	var wg sync.WaitGroup
+	changes := MergeChanges(ctx, &wg, 1, members, read)
+
+	received := make(map[string]struct{})
+	var lags int
+	for i := 0; i < 4; i++ {
+		change := readChange(t, changes)
+		switch change.Type {
+		case ChangePut:
+			received[change.Key] = struct{}{}
+		case ChangeLag:
+			// The timestamp of the last change delivered before the gap.
+			require.Equal(t, int64(10), change.Timestamp)
+			lags++
+		}
+	}
+	require.Len(t, received, 2)
+	require.Equal(t, 2, lags)
+
+	cancel()
+	_, ok := <-changes
+	require.False(t, ok)
+	wg.Wait()
+}
+
+func TestDMap_StreamChanges_Without_Subscriber(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	s.changes = newChangeFeed(DefaultChangeLogSize, DefaultChangeLogBytes, 100*time.Millisecond)
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	s.changes.mtx.RLock()
+	require.Empty(t, s.changes.logs)
+	s.changes.mtx.RUnlock()
+
+	subscribeChanges("mydmap", s)
+	err = dm.Put(ctx, "recorded", []byte("value"), nil)
+	require.NoError(t, err)
+
+	l := s.changes.log("mydmap")
+	l.mtx.Lock()
+	require.Len(t, l.changes, 1)
+	l.mtx.Unlock()
+
+	// Nothing reads the log after the retention, the changes are dropped.
+	<-time.After(200 * time.Millisecond)
+	err = dm.Put(ctx, "not-recorded", []byte("value"), nil)
+	require.NoError(t, err)
+
+	l.mtx.Lock()
+	require.Empty(t, l.changes)
+	require.Zero(t, l.bytes)
+	l.mtx.Unlock()
+}
+
+func TestDMap_StreamChanges_Encryption(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.EncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	changes, cancel := dm.StreamChanges(subscribeChanges("mydmap", s))
+	defer cancel()
+
+	plaintext := []byte("secret-value")
+	err = dm.Put(ctx, "mykey", plaintext, nil)
+	require.NoError(t, err)
+
+	// The log keeps the value encrypted.
+	l := s.changes.log("mydmap")
+	l.mtx.Lock()
+	require.Len(t, l.changes, 1)
+	require.False(t, bytes.Contains(l.changes[0].Value, plaintext))
+	l.mtx.Unlock()
+
+	change := readChange(t, changes)
+	require.Equal(t, ChangePut, change.Type)
+	require.Equal(t, plaintext, change.Value)
+}
+
+func TestDMap_StreamChanges_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	newConfig := func() *environment.Environment {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		return testcluster.NewEnvironment(c)
+	}
+	s1 := cluster.AddMember(newConfig()).(*Service)
+	s2 := cluster.AddMember(newConfig()).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	changes, cancel := dm1.StreamChanges(subscribeChanges("mydmap", s1, s2))
+	defer cancel()
+
+	// The keys are owned by both members, the replicas don't publish the changes.
+	numKeys := 100
+	for i := 0; i < numKeys; i++ {
+		err = dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	received := make(map[string][]byte)
+	for len(received) < numKeys {
+		change := readChange(t, changes)
+		require.Equal(t, ChangePut, change.Type)
+		received[change.Key] = change.Value
+	}
+	for i := 0; i < numKeys; i++ {
+		require.Equal(t, testutil.ToVal(i), received[testutil.ToKey(i)])
+	}
+
+	cancel()
+	for range changes {
+	}
+}
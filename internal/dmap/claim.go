@@ -0,0 +1,236 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ClaimKeyPrefix is the prefix of the keys that keep the claim markers written by ClaimNext.
+// The marker of a claimed entry is stored in the same DMap with the key ClaimKeyPrefix + key.
+const ClaimKeyPrefix = "olric.claim."
+
+// claimScanCount is the number of entries that are visited in a single scan of a fragment.
+const claimScanCount = 100
+
+// ErrNoAvailable is returned by ClaimNext when all entries are already claimed.
+var ErrNoAvailable = errors.New("no available entry")
+
+// claimCursor is the position to resume the search for an unclaimed entry on this member,
+// so the claimed entries at the beginning of the DMap are not visited on every call.
+type claimCursor struct {
+	mtx    sync.Mutex
+	partID uint64
+	cursor uint64
+}
+
+func (c *claimCursor) load() (uint64, uint64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.partID, c.cursor
+}
+
+func (c *claimCursor) store(partID, cursor uint64) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.partID, c.cursor = partID, cursor
+}
+
+// claimCandidates returns the unexpired entries in the next batch of the fragment and the
+// cursor of the following batch. The claim markers are skipped.
+func (dm *DMap) claimCandidates(f *fragment, cursor uint64) ([]storage.Entry, uint64, error) {
+	f.Lock()
+	defer f.Unlock()
+
+	var entries []storage.Entry
+	cursor, err := f.storage.Scan(cursor, claimScanCount, func(e storage.Entry) bool {
+		if strings.HasPrefix(e.Key(), ClaimKeyPrefix) || isKeyExpired(e.TTL()) {
+			return true
+		}
+		// The entry points to the memory of the storage engine, it's copied before the
+		// fragment is unlocked.
+		entry := dm.engine.NewEntry()
+		entry.Decode(e.Encode())
+		entries = append(entries, entry)
+		return true
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, cursor, nil
+}
+
+// tryClaim writes the claim marker of the key if it doesn't exist. It returns false if the
+// key is already claimed. The marker is written with NX on its partition owner, so only one
+// of the concurrent claims succeeds, and it's replicated and moved with its partition like
+// any other entry.
+func (dm *DMap) tryClaim(ctx context.Context, key string, claimTTL time.Duration) (bool, error) {
+	e := newEnv(ctx)
+	e.dmap = dm.name
+	e.key = ClaimKeyPrefix + key
+	e.value = []byte{}
+	e.putConfig.HasNX = true
+	e.putConfig.HasPX = true
+	e.putConfig.PX = claimTTL
+	err := dm.put(e)
+	if errors.Is(err, ErrKeyFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// claimNextOnThisMember claims an entry from the primary copies on this member. It resumes
+// from the position of the previous claim and visits every partition once.
+func (dm *DMap) claimNextOnThisMember(ctx context.Context, claimTTL time.Duration) (storage.Entry, error) {
+	count := dm.s.config.PartitionCount
+	startID, startCursor := dm.claimCursor.load()
+	if startID >= count {
+		startID, startCursor = 0, 0
+	}
+
+	cursor := startCursor
+	// The first partition is visited once more from the beginning, if the search started
+	// in the middle of it.
+	for i := uint64(0); i <= count; i++ {
+		if i == count && startCursor == 0 {
+			break
+		}
+		partID := (startID + i) % count
+		f, err := dm.loadFragment(dm.s.primary.PartitionByID(partID))
+		if errors.Is(err, errFragmentNotFound) {
+			cursor = 0
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for {
+			entries, next, err := dm.claimCandidates(f, cursor)
+			if err != nil {
+				return nil, err
+			}
+			for _, entry := range entries {
+				ok, err := dm.tryClaim(ctx, entry.Key(), claimTTL)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					// The rest of the batch is visited again by the next call.
+					dm.claimCursor.store(partID, cursor)
+					return entry, nil
+				}
+			}
+			cursor = next
+			if cursor == 0 {
+				break
+			}
+		}
+	}
+	return nil, ErrNoAvailable
+}
+
+// localClaimNext claims an entry from the primary copies on this member.
+func (s *Service) localClaimNext(ctx context.Context, name string, claimTTL time.Duration) (storage.Entry, error) {
+	dm, err := s.getDMap(name)
+	if errors.Is(err, ErrDMapNotFound) {
+		return nil, ErrNoAvailable
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dm.claimNextOnThisMember(ctx, claimTTL)
+}
+
+func (dm *DMap) claimNextOnMember(ctx context.Context, member discovery.Member, claimTTL time.Duration) (storage.Entry, error) {
+	if member.CompareByID(dm.s.rt.This()) {
+		return dm.s.localClaimNext(ctx, dm.name, claimTTL)
+	}
+
+	cmd := protocol.NewClaimNext(dm.name, claimTTL.Milliseconds()).SetLocal().Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	result, err := cmd.Result()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	if len(result) != 1 {
+		return nil, fmt.Errorf("invalid response length: %d", len(result))
+	}
+	raw, ok := result[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("invalid response type: %T", result[0])
+	}
+
+	entry := dm.engine.NewEntry()
+	entry.Decode([]byte(raw))
+	return entry, nil
+}
+
+// ClaimNext atomically claims an unclaimed entry and returns it. The entry stays claimed
+// for claimTTL, so the claim of a crashed worker is released automatically. Every
+// partition owner is tried in turn, this member first. It returns ErrNoAvailable if all
+// entries are claimed.
+//
+// The claim of an entry is a marker stored in the same DMap with the key ClaimKeyPrefix +
+// key and the TTL claimTTL, so it's replicated and moved with its partition like the other
+// entries. Deleting a claimed entry doesn't release its claim, a new entry with the same
+// key is available after the claim expires.
+func (dm *DMap) ClaimNext(ctx context.Context, claimTTL time.Duration) (storage.Entry, error) {
+	if claimTTL <= 0 {
+		return nil, fmt.Errorf("%w: non-positive claim TTL: %s", protocol.ErrInvalidArgument, claimTTL)
+	}
+
+	members := []discovery.Member{dm.s.rt.This()}
+	m := dm.s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		if !member.CompareByID(dm.s.rt.This()) {
+			members = append(members, member)
+		}
+		return true
+	})
+	m.RUnlock()
+
+	for _, member := range members {
+		entry, err := dm.claimNextOnMember(ctx, member, claimTTL)
+		if errors.Is(err, ErrNoAvailable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = dm.decryptEntry(entry); err != nil {
+			return nil, err
+		}
+		return entry, nil
+	}
+	return nil, ErrNoAvailable
+}
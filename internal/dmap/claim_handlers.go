@@ -0,0 +1,53 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) claimNextCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	claimNextCmd, err := protocol.ParseClaimNextCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	claimTTL := time.Duration(claimNextCmd.ClaimTTL) * time.Millisecond
+
+	var entry storage.Entry
+	if claimNextCmd.Local {
+		entry, err = s.localClaimNext(s.ctx, claimNextCmd.DMap, claimTTL)
+	} else {
+		var dm *DMap
+		dm, err = s.getOrCreateDMap(claimNextCmd.DMap)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		entry, err = dm.ClaimNext(s.ctx, claimTTL)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(1)
+	conn.WriteBulk(entry.Encode())
+}
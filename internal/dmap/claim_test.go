@@ -0,0 +1,135 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_ClaimNext(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+
+	var mtx sync.Mutex
+	claimed := make(map[string]int)
+
+	// The workers claim the entries concurrently on both members until none is available.
+	var errGr errgroup.Group
+	for i := 0; i < 10; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			for {
+				entry, err := dm.ClaimNext(ctx, time.Minute)
+				if errors.Is(err, ErrNoAvailable) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				mtx.Lock()
+				claimed[entry.Key()]++
+				mtx.Unlock()
+			}
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	require.Len(t, claimed, 100)
+	for key, count := range claimed {
+		require.Equalf(t, 1, count, "%s is claimed more than once", key)
+	}
+}
+
+func TestDMap_ClaimNext_ClaimTTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue", nil))
+
+	entry, err := dm.ClaimNext(ctx, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "mykey", entry.Key())
+
+	_, err = dm.ClaimNext(ctx, 100*time.Millisecond)
+	require.ErrorIs(t, err, ErrNoAvailable)
+
+	// The claim is released after claimTTL.
+	<-time.After(150 * time.Millisecond)
+	entry, err = dm.ClaimNext(ctx, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "mykey", entry.Key())
+}
+
+func TestDMap_ClaimNext_Rebalance(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+	for i := 0; i < 20; i++ {
+		_, err = dm1.ClaimNext(ctx, time.Minute)
+		require.NoError(t, err)
+	}
+
+	// The claim is a marker entry with the claim TTL.
+	marker, err := dm1.Get(ctx, ClaimKeyPrefix+testutil.ToKey(0))
+	require.NoError(t, err)
+	require.NotZero(t, marker.TTL())
+
+	// The claims are moved with their partitions to the new member.
+	s2 := cluster.AddMember(nil).(*Service)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm2.ClaimNext(ctx, time.Minute)
+	require.ErrorIs(t, err, ErrNoAvailable)
+	_, err = dm1.ClaimNext(ctx, time.Minute)
+	require.ErrorIs(t, err, ErrNoAvailable)
+}
@@ -22,13 +22,39 @@ import (
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/stats"
 	"golang.org/x/sync/semaphore"
 )
 
-func (s *Service) callCompactionOnFragment(f *fragment) bool {
+var (
+	// CompactionsImmediateTotal is the number of compaction steps run by the compaction worker.
+	CompactionsImmediateTotal = stats.NewInt64Counter()
+
+	// CompactionsDeferredTotal is the number of deferred compaction steps run in a low-traffic window.
+	CompactionsDeferredTotal = stats.NewInt64Counter()
+)
+
+// countCompactions returns a compaction function that increases the counter for every
+// step that does some work.
+func countCompactions(compaction func(f *fragment) (bool, error), counter *stats.Int64Counter) func(f *fragment) (bool, error) {
+	return func(f *fragment) (bool, error) {
+		done, err := compaction(f)
+		if !done && err == nil {
+			counter.Increase(1)
+		}
+		return done, err
+	}
+}
+
+// deferCompactions returns true if the non-urgent compactions wait for a low-traffic window.
+func (s *Service) deferCompactions() bool {
+	return s.config.DMaps.DeferCompactions && s.config.DMaps.IdleCompactionInterval > 0
+}
+
+func (s *Service) callCompactionOnFragment(f *fragment, compaction func(f *fragment) (bool, error)) bool {
 	for {
 		f.Lock()
-		done, err := f.Compaction()
+		done, err := compaction(f)
 		if err != nil {
 			f.Unlock()
 			// Continue
@@ -49,8 +75,8 @@ func (s *Service) callCompactionOnFragment(f *fragment) bool {
 	}
 }
 
-func (s *Service) doCompaction(partID uint64) {
-	compaction := func(part *partitions.Partition) {
+func (s *Service) doCompaction(partID uint64, compaction func(f *fragment) (bool, error)) {
+	compactPartition := func(part *partitions.Partition) {
 		part.Map().Range(func(name, tmp interface{}) bool {
 			if !strings.HasPrefix(name.(string), "dmap.") {
 				// Continue. This fragment belongs to a different data structure.
@@ -58,18 +84,18 @@ func (s *Service) doCompaction(partID uint64) {
 			}
 
 			f := tmp.(*fragment)
-			return s.callCompactionOnFragment(f)
+			return s.callCompactionOnFragment(f, compaction)
 		})
 	}
 
 	part := s.primary.PartitionByID(partID)
-	compaction(part)
+	compactPartition(part)
 
 	backup := s.backup.PartitionByID(partID)
-	compaction(backup)
+	compactPartition(backup)
 }
 
-func (s *Service) triggerCompaction() {
+func (s *Service) triggerCompaction(compaction func(f *fragment) (bool, error)) {
 	var wg sync.WaitGroup
 
 	// NumCPU returns the number of logical CPUs usable by the current process.
@@ -97,7 +123,7 @@ func (s *Service) triggerCompaction() {
 		go func(id uint64) {
 			defer wg.Done()
 			defer sem.Release(1)
-			s.doCompaction(id)
+			s.doCompaction(id, compaction)
 		}(partID)
 	}
 
@@ -107,6 +133,12 @@ func (s *Service) triggerCompaction() {
 func (s *Service) compactionWorker() {
 	defer s.wg.Done()
 
+	compaction := (*fragment).Compaction
+	if s.deferCompactions() {
+		compaction = (*fragment).UrgentCompaction
+	}
+	compaction = countCompactions(compaction, CompactionsImmediateTotal)
+
 	timer := time.NewTimer(s.config.DMaps.TriggerCompactionInterval)
 	defer timer.Stop()
 
@@ -114,7 +146,51 @@ func (s *Service) compactionWorker() {
 		timer.Reset(s.config.DMaps.TriggerCompactionInterval)
 		select {
 		case <-timer.C:
-			s.triggerCompaction()
+			s.triggerCompaction(compaction)
+		case <-s.ctx.Done():
+			return
+		}
+	}
+}
+
+// compactOldTables compacts the tables that are not written within the given age on
+// all fragments hosted by this member.
+func (s *Service) compactOldTables(age time.Duration) {
+	s.triggerCompaction(func(f *fragment) (bool, error) {
+		return f.CompactTablesOlderThan(age)
+	})
+}
+
+// operationsTotal returns the number of Get, Put and Delete operations served by this process.
+func operationsTotal() int64 {
+	return GetHits.Read() + GetMisses.Read() + EntriesTotal.Read() + DeleteHits.Read() + DeleteMisses.Read()
+}
+
+// idleCompactionWorker merges the tables of the fragments when the member is idle. The
+// member is idle if it serves at most IdleCompactionThreshold operations in an interval.
+// The deferred compactions run first, if DeferCompactions is enabled.
+func (s *Service) idleCompactionWorker() {
+	defer s.wg.Done()
+
+	deferred := countCompactions((*fragment).DeferredCompaction, CompactionsDeferredTotal)
+
+	interval := s.config.DMaps.IdleCompactionInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	last := operationsTotal()
+	for {
+		timer.Reset(interval)
+		select {
+		case <-timer.C:
+			current := operationsTotal()
+			if current-last <= s.config.DMaps.IdleCompactionThreshold {
+				if s.deferCompactions() {
+					s.triggerCompaction(deferred)
+				}
+				s.triggerCompaction((*fragment).ConsolidateTables)
+			}
+			last = current
 		case <-s.ctx.Done():
 			return
 		}
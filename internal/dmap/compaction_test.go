@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"github.com/buraksezer/olric/internal/kvstore"
+	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/storage"
 	"testing"
 	"time"
@@ -92,3 +93,170 @@ func TestDMap_Compaction(t *testing.T) {
 	})
 	require.NoError(t, err)
 }
+
+func TestDMap_CompactTables(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Engine.Name = config.DefaultStorageEngine
+	c.DMaps.Engine.Config = map[string]interface{}{
+		"tableSize":           uint64(1 << 20),
+		"maxIdleTableTimeout": time.Minute,
+		"maxKeysPerTable":     10, // overwrite maxKeysPerTable to create many tables.
+	}
+	kv, err := kvstore.New(storage.NewConfig(c.DMaps.Engine.Config))
+	require.NoError(t, err)
+	c.DMaps.Engine.Implementation = kv
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 500; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 500; i += 2 {
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+
+	<-time.After(100 * time.Millisecond)
+
+	before := kvstore.CompactionsByTableAgeTotal.Read()
+	cmd := protocol.NewCompactTables(50 * time.Millisecond).Command(ctx)
+	rc := s.client.Get(s.rt.This().String())
+	require.NoError(t, rc.Process(ctx, cmd))
+	require.NoError(t, cmd.Err())
+	require.Greater(t, kvstore.CompactionsByTableAgeTotal.Read(), before)
+
+	for i := 1; i < 500; i += 2 {
+		value, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value.Value())
+	}
+}
+
+func TestDMap_Compaction_WhenIdle(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.IdleCompactionInterval = 50 * time.Millisecond
+	c.DMaps.Engine.Config = map[string]interface{}{
+		"tableSize":           uint64(1 << 20),
+		"maxIdleTableTimeout": time.Millisecond,
+		"maxKeysPerTable":     10, // overwrite maxKeysPerTable to create many tables.
+	}
+	kv, err := kvstore.New(storage.NewConfig(c.DMaps.Engine.Config))
+	require.NoError(t, err)
+	c.DMaps.Engine.Implementation = kv
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	numTables := func() int {
+		var total int
+		for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+			part := s.primary.PartitionByID(partID)
+			part.Map().Range(func(_, v interface{}) bool {
+				total += v.(*fragment).Stats().NumTables
+				return true
+			})
+		}
+		return total
+	}
+
+	ctx := context.Background()
+	before := kvstore.CompactionsWhenIdleTotal.Read()
+	for i := 0; i < 500; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 500; i += 2 {
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+	tables := numTables()
+
+	// Simulate an idle period.
+	<-time.After(300 * time.Millisecond)
+	require.Greater(t, kvstore.CompactionsWhenIdleTotal.Read(), before)
+
+	// Remove the recycled tables.
+	s.triggerCompaction((*fragment).Compaction)
+	require.Less(t, numTables(), tables)
+
+	for i := 1; i < 500; i += 2 {
+		value, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value.Value())
+	}
+}
+
+func TestDMap_Compaction_Deferred(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.TriggerCompactionInterval = 10 * time.Millisecond
+	c.DMaps.IdleCompactionInterval = 100 * time.Millisecond
+	c.DMaps.DeferCompactions = true
+	c.PartitionCount = 1
+	c.DMaps.Engine.Config = map[string]interface{}{
+		"tableSize":           uint64(4096),
+		"maxTables":           2,
+		"maxIdleTableTimeout": time.Minute,
+	}
+	kv, err := kvstore.New(storage.NewConfig(c.DMaps.Engine.Config))
+	require.NoError(t, err)
+	c.DMaps.Engine.Implementation = kv
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mymap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	before := CompactionsDeferredTotal.Read()
+	byTableCount := kvstore.CompactionsByTableCountTotal.Read()
+	// Keep the member busy, the compactions triggered by maxTables are deferred.
+	for i := 0; i < 300; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), []byte(fmt.Sprintf("%0100d", i)), nil)
+		require.NoError(t, err)
+	}
+	for i := 0; i < 300; i += 3 {
+		_, err = dm.Delete(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+	busyUntil := time.Now().Add(300 * time.Millisecond)
+	for time.Now().Before(busyUntil) {
+		_, err = dm.Get(ctx, testutil.ToKey(1))
+		require.NoError(t, err)
+	}
+	require.Equal(t, before, CompactionsDeferredTotal.Read())
+	require.Equal(t, byTableCount, kvstore.CompactionsByTableCountTotal.Read())
+
+	// Simulate a quiet period.
+	err = testutil.TryWithInterval(10, 100*time.Millisecond, func() error {
+		if CompactionsDeferredTotal.Read() <= before {
+			return fmt.Errorf("deferred compactions have not run yet")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	for i := 1; i < 300; i++ {
+		if i%3 == 0 {
+			continue
+		}
+		value, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, []byte(fmt.Sprintf("%0100d", i)), value.Value())
+	}
+}
@@ -15,7 +15,9 @@
 package dmap
 
 import (
+	"crypto/cipher"
 	"fmt"
+	"regexp"
 	"time"
 
 	"github.com/buraksezer/olric/config"
@@ -23,24 +25,50 @@ import (
 
 // dmapConfig keeps DMap config control parameters and access-log for keys in a dmap.
 type dmapConfig struct {
-	engine          *config.Engine
-	maxIdleDuration time.Duration
-	ttlDuration     time.Duration
-	maxKeys         int
-	maxInuse        int
-	lruSamples      int
-	evictionPolicy  config.EvictionPolicy
+	engine            *config.Engine
+	maxIdleDuration   time.Duration
+	ttlDuration       time.Duration
+	maxAge            time.Duration
+	maxKeys           int
+	maxInuse          int
+	lruSamples        int
+	evictionPolicy    config.EvictionPolicy
+	noEvictKeys       []*regexp.Regexp
+	gracePeriod       time.Duration
+	deadLetterDMap    string
+	aead              cipher.AEAD
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	keySchema         *regexp.Regexp
+	accessCounter     bool
+	preSplitSize      int
+	gossipReplication bool
+	slowLogThreshold  time.Duration
+	slowLogSampleRate float64
 }
 
 func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 	// Try to set config configuration for this dmap.
 	c.maxIdleDuration = dc.MaxIdleDuration
 	c.ttlDuration = dc.TTLDuration
+	c.maxAge = dc.MaxAge
 	c.maxKeys = dc.MaxKeys
 	c.maxInuse = dc.MaxInuse
 	c.lruSamples = dc.LRUSamples
 	c.evictionPolicy = dc.EvictionPolicy
 	c.engine = dc.Engine
+	c.deadLetterDMap = dc.DeadLetterDMap
+	c.gracePeriod = dc.EvictionGracePeriod
+	c.readTimeout = dc.ReadTimeout
+	c.writeTimeout = dc.WriteTimeout
+	c.accessCounter = dc.AccessCounter
+	c.preSplitSize = dc.PreSplitSize
+	c.gossipReplication = dc.GossipReplication
+	c.slowLogThreshold = dc.SlowLogThreshold
+	c.slowLogSampleRate = dc.SlowLogSampleRate
+	patterns := dc.NoEvictKeyPatterns
+	encryptionKey := dc.EncryptionKey
+	keySchema := dc.KeySchema
 
 	if dc.Custom != nil {
 		// config.DMap struct can be used for fine-grained control.
@@ -52,6 +80,9 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 			if c.ttlDuration != cs.TTLDuration {
 				c.ttlDuration = cs.TTLDuration
 			}
+			if cs.MaxAge != 0 {
+				c.maxAge = cs.MaxAge
+			}
 			if c.evictionPolicy != cs.EvictionPolicy {
 				c.evictionPolicy = cs.EvictionPolicy
 			}
@@ -70,9 +101,69 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 			if c.engine == nil {
 				c.engine = cs.Engine
 			}
+			if cs.NoEvictKeyPatterns != nil {
+				patterns = cs.NoEvictKeyPatterns
+			}
+			if cs.EvictionGracePeriod != 0 {
+				c.gracePeriod = cs.EvictionGracePeriod
+			}
+			if cs.DeadLetterDMap != "" {
+				c.deadLetterDMap = cs.DeadLetterDMap
+			}
+			if cs.EncryptionKey != nil {
+				encryptionKey = cs.EncryptionKey
+			}
+			if cs.ReadTimeout != 0 {
+				c.readTimeout = cs.ReadTimeout
+			}
+			if cs.WriteTimeout != 0 {
+				c.writeTimeout = cs.WriteTimeout
+			}
+			if cs.KeySchema != "" {
+				keySchema = cs.KeySchema
+			}
+			if cs.AccessCounter {
+				c.accessCounter = true
+			}
+			if cs.PreSplitSize != 0 {
+				c.preSplitSize = cs.PreSplitSize
+			}
+			if cs.GossipReplication {
+				c.gossipReplication = true
+			}
+			if cs.SlowLogThreshold != 0 {
+				c.slowLogThreshold = cs.SlowLogThreshold
+			}
+			if cs.SlowLogSampleRate != 0 {
+				c.slowLogSampleRate = cs.SlowLogSampleRate
+			}
 		}
 	}
 
+	for _, pattern := range patterns {
+		r, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid no-evict key pattern: %s: %w", pattern, err)
+		}
+		c.noEvictKeys = append(c.noEvictKeys, r)
+	}
+
+	if keySchema != "" {
+		r, err := regexp.Compile(keySchema)
+		if err != nil {
+			return fmt.Errorf("invalid key schema: %s: %w", keySchema, err)
+		}
+		c.keySchema = r
+	}
+
+	if len(encryptionKey) != 0 {
+		aead, err := newAEAD(encryptionKey)
+		if err != nil {
+			return fmt.Errorf("invalid encryption key: %w", err)
+		}
+		c.aead = aead
+	}
+
 	//TODO: Create a new function to verify config.
 	if c.evictionPolicy == config.LRUEviction {
 		if c.maxInuse <= 0 && c.maxKeys <= 0 {
@@ -85,3 +176,22 @@ func (c *dmapConfig) load(dc *config.DMaps, name string) error {
 	}
 	return nil
 }
+
+// isEvictable returns false if the key matches one of the no-evict key patterns.
+func (c *dmapConfig) isEvictable(key string) bool {
+	for _, r := range c.noEvictKeys {
+		if r.MatchString(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// inGracePeriod returns true if the entry is written within the eviction grace period.
+// timestamp is the time of the last write in nanoseconds.
+func (c *dmapConfig) inGracePeriod(timestamp int64) bool {
+	if c.gracePeriod <= 0 {
+		return false
+	}
+	return time.Now().UnixNano()-timestamp < c.gracePeriod.Nanoseconds()
+}
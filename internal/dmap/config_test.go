@@ -44,6 +44,7 @@ func TestDMap_Config(t *testing.T) {
 		Engine: &config.Engine{
 			Name: "kvstore",
 			Config: map[string]interface{}{
+				"maxGarbageRatio":     0.40,
 				"maxIdleTableTimeout": 15 * time.Minute,
 				"tableSize":           uint64(1048576),
 			},
@@ -0,0 +1,81 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"encoding/json"
+
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// DeadLetter is the document that is written to the dead-letter DMap for an entry
+// expired by its TTL.
+type DeadLetter struct {
+	DMap  string `json:"dmap"`
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+	// ExpiredAt is the expiry time of the entry in milliseconds since the Unix epoch.
+	ExpiredAt int64 `json:"expired_at"`
+}
+
+func (dm *DMap) hasDeadLetterDMap() bool {
+	if dm.config == nil || dm.config.deadLetterDMap == "" {
+		return false
+	}
+	// Never archive the dead-letter DMap into itself.
+	return dm.config.deadLetterDMap != dm.name
+}
+
+// archiveExpired writes the expired entry to the dead-letter DMap in the background. It's
+// best-effort, the errors are only logged.
+func (dm *DMap) archiveExpired(entry storage.Entry) {
+	if !dm.hasDeadLetterDMap() || !dm.s.isAlive() {
+		return
+	}
+
+	value, err := dm.decryptValue(entry.Key(), entry.Value())
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to archive expired key: %s on DMap: %s: %v", entry.Key(), dm.name, err)
+		return
+	}
+
+	// The value may point to the memory of the storage engine, encode it before returning.
+	data, err := json.Marshal(&DeadLetter{
+		DMap:      dm.name,
+		Key:       entry.Key(),
+		Value:     value,
+		ExpiredAt: entry.TTL(),
+	})
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to encode expired key: %s on DMap: %s: %v", entry.Key(), dm.name, err)
+		return
+	}
+
+	name := dm.config.deadLetterDMap
+	key := entry.Key()
+	dm.s.wg.Add(1)
+	go func() {
+		defer dm.s.wg.Done()
+
+		target, err := dm.s.getOrCreateDMap(name)
+		if err == nil {
+			err = target.Put(dm.s.ctx, key, data, nil)
+		}
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to archive expired key: %s on DMap: %s to the dead-letter DMap: %s: %v",
+				key, dm.name, name, err)
+		}
+	}()
+}
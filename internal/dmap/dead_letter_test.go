@@ -0,0 +1,88 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_DeadLetterDMap(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.DeadLetterDMap = "dead-letter"
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	pc := &PutConfig{
+		HasPX: true,
+		PX:    time.Millisecond,
+	}
+	for i := 0; i < 20; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc))
+	}
+
+	<-time.After(10 * time.Millisecond)
+
+	// Expire the first half lazily, the janitor expires the rest.
+	for i := 0; i < 10; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		f, err := dm.loadFragment(s.primary.PartitionByID(partID))
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		require.NoError(t, err)
+		s.scanFragmentForEviction(partID, s.fragmentName("mydmap"), f)
+	}
+
+	deadLetter, err := s.NewDMap("dead-letter")
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		var dl DeadLetter
+		err = testutil.TryWithInterval(100, 50*time.Millisecond, func() error {
+			e, err := deadLetter.Get(ctx, testutil.ToKey(i))
+			if err != nil {
+				return err
+			}
+			return json.Unmarshal(e.Value(), &dl)
+		})
+		require.NoError(t, err)
+		require.Equal(t, "mydmap", dl.DMap)
+		require.Equal(t, testutil.ToKey(i), dl.Key)
+		require.Equal(t, testutil.ToVal(i), dl.Value)
+		require.NotZero(t, dl.ExpiredAt)
+	}
+
+	// The expired keys are removed from the source DMap.
+	for i := 10; i < 20; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
@@ -17,11 +17,13 @@ package dmap
 import (
 	"context"
 	"errors"
+	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/internal/wal"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -118,7 +120,7 @@ func (dm *DMap) deleteOnCluster(hkey uint64, key string, f *fragment) error {
 	return nil
 }
 
-func (dm *DMap) deleteKey(key string) error {
+func (dm *DMap) deleteKey(ctx context.Context, key string) error {
 	hkey := partitions.HKey(dm.name, key)
 	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
 	f, err := dm.loadOrCreateFragment(part)
@@ -126,7 +128,15 @@ func (dm *DMap) deleteKey(key string) error {
 		return err
 	}
 
-	f.Lock()
+	if err = f.lockContext(ctx); err != nil {
+		return err
+	}
+	if dm.isWriteFenced(hkey, f) {
+		f.Unlock()
+		// The partition has been moved, forward the deletion to the current owner.
+		_, err = dm.deleteKeys(ctx, key)
+		return err
+	}
 	defer f.Unlock()
 
 	// Check the HKey before trying to delete it.
@@ -136,7 +146,16 @@ func (dm *DMap) deleteKey(key string) error {
 		return nil
 	}
 
-	return dm.deleteOnCluster(hkey, key, f)
+	if err = dm.appendWAL(wal.OpDelete, key, nil, 0); err != nil {
+		return err
+	}
+	err = dm.deleteOnCluster(hkey, key, f)
+	if err != nil {
+		return err
+	}
+
+	dm.publishDelete(ChangeDelete, key)
+	return nil
 }
 
 func (dm *DMap) deleteKeys(ctx context.Context, keys ...string) (int, error) {
@@ -150,7 +169,7 @@ func (dm *DMap) deleteKeys(ctx context.Context, keys ...string) (int, error) {
 	for member, distributedKeys := range members {
 		if member.CompareByName(dm.s.rt.This()) {
 			for _, key := range distributedKeys {
-				if err := dm.deleteKey(key); err != nil {
+				if err := dm.deleteKey(ctx, key); err != nil {
 					return 0, err
 				}
 			}
@@ -171,6 +190,19 @@ func (dm *DMap) deleteKeys(ctx context.Context, keys ...string) (int, error) {
 
 // Delete deletes the value for the given key. Delete will not return error if key doesn't exist. It's thread-safe.
 // It is safe to modify the contents of the argument after Delete returns.
+// It returns ErrTimeout if the write timeout of the DMap is exceeded.
 func (dm *DMap) Delete(ctx context.Context, keys ...string) (int, error) {
-	return dm.deleteKeys(ctx, keys...)
+	defer dm.metrics.observeDelete(time.Now())
+	defer dm.observeSlow(protocol.DMap.Del, time.Now(), keys...)
+
+	var count int
+	err := RunWithTimeout(ctx, dm.config.writeTimeout, func(ctx context.Context) error {
+		var err error
+		count, err = dm.deleteKeys(ctx, keys...)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
 }
@@ -15,6 +15,8 @@
 package dmap
 
 import (
+	"time"
+
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/tidwall/redcon"
@@ -31,6 +33,8 @@ func (s *Service) delCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		protocol.WriteError(conn, err)
 		return
 	}
+	defer dm.metrics.observeDelete(time.Now())
+	defer dm.observeSlow(protocol.DMap.Del, time.Now(), delCmd.Keys...)
 
 	count, err := dm.deleteKeys(s.ctx, delCmd.Keys...)
 	if err != nil {
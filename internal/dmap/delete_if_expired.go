@@ -0,0 +1,87 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// deleteIfExpiredBefore runs on the partition owner of the key. The TTL is checked and the
+// key is deleted under the fragment lock, so a concurrent TTL update cannot interleave.
+func (dm *DMap) deleteIfExpiredBefore(ctx context.Context, key string, timestamp int64) (bool, error) {
+	hkey := partitions.HKey(dm.name, key)
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return false, err
+	}
+
+	f.Lock()
+	if dm.isWriteFenced(hkey, f) {
+		f.Unlock()
+		// The partition has been moved, forward the deletion to the current owner.
+		return dm.DeleteIfExpiredBefore(ctx, key, timestamp)
+	}
+	defer f.Unlock()
+
+	ttl, err := f.storage.GetTTL(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		DeleteMisses.Increase(1)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if ttl == 0 || ttl > timestamp {
+		// The key never expires or its TTL has been extended.
+		return false, nil
+	}
+
+	if err = dm.deleteOnCluster(hkey, key, f); err != nil {
+		return false, err
+	}
+	dm.publishDelete(ChangeDelete, key)
+	return true, nil
+}
+
+// DeleteIfExpiredBefore deletes the key only if its expiry time is at or before the
+// timestamp, in milliseconds since the Unix epoch. It returns true if the key is deleted.
+// A key without a TTL is never deleted. The check and the deletion are atomic on the
+// partition owner, so a cleanup worker cannot delete a key whose TTL is extended after
+// the worker decided to delete it.
+func (dm *DMap) DeleteIfExpiredBefore(ctx context.Context, key string, timestamp int64) (bool, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.deleteIfExpiredBefore(ctx, key, timestamp)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewDelIfExpiredBefore(dm.name, key, timestamp).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	deleted, err := cmd.Result()
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	return deleted == 1, nil
+}
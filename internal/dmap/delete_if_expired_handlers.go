@@ -0,0 +1,44 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) delIfExpiredBeforeCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	delCmd, err := protocol.ParseDelIfExpiredBeforeCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(delCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	deleted, err := dm.deleteIfExpiredBefore(s.ctx, delCmd.Key, delCmd.Timestamp)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	if deleted {
+		conn.WriteInt(1)
+		return
+	}
+	conn.WriteInt(0)
+}
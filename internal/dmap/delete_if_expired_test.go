@@ -0,0 +1,131 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_DeleteIfExpiredBefore(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Call DeleteIfExpiredBefore on the member that doesn't own the key, it's redirected to the owner.
+	dm := dm1
+	if s1.primary.PartitionByHKey(partitions.HKey("mydmap", "session")).Owner().CompareByID(s1.rt.This()) {
+		dm = dm2
+	}
+
+	pc := &PutConfig{HasPX: true, PX: time.Second}
+	require.NoError(t, dm.Put(ctx, "session", "value", pc))
+
+	// The cleanup worker decides to delete the key at its current expiry time.
+	entry, err := dm.Get(ctx, "session")
+	require.NoError(t, err)
+	expiry := entry.TTL()
+
+	// The key is refreshed before the worker deletes it.
+	require.NoError(t, dm.Expire(ctx, "session", time.Hour))
+
+	deleted, err := dm.DeleteIfExpiredBefore(ctx, "session", expiry)
+	require.NoError(t, err)
+	require.False(t, deleted)
+
+	_, err = dm.Get(ctx, "session")
+	require.NoError(t, err)
+
+	t.Run("Delete the expired key", func(t *testing.T) {
+		deleted, err := dm.DeleteIfExpiredBefore(ctx, "session", time.Now().Add(2*time.Hour).UnixNano()/1000000)
+		require.NoError(t, err)
+		require.True(t, deleted)
+
+		_, err = dm.Get(ctx, "session")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+
+	t.Run("Key without TTL", func(t *testing.T) {
+		require.NoError(t, dm.Put(ctx, "persistent", "value", nil))
+
+		deleted, err := dm.DeleteIfExpiredBefore(ctx, "persistent", time.Now().Add(time.Hour).UnixNano()/1000000)
+		require.NoError(t, err)
+		require.False(t, deleted)
+	})
+
+	t.Run("Missing key", func(t *testing.T) {
+		deleted, err := dm.DeleteIfExpiredBefore(ctx, "missing", time.Now().UnixNano()/1000000)
+		require.NoError(t, err)
+		require.False(t, deleted)
+	})
+
+	t.Run("Concurrent refresh", func(t *testing.T) {
+		expiries := make(map[string]int64)
+		for i := 0; i < 100; i++ {
+			key := testutil.ToKey(i)
+			require.NoError(t, dm1.Put(ctx, key, testutil.ToVal(i), pc))
+			entry, err := dm1.Get(ctx, key)
+			require.NoError(t, err)
+			expiries[key] = entry.TTL()
+		}
+
+		deleted := make(map[string]*bool)
+		var errGr errgroup.Group
+		for key, expiry := range expiries {
+			key, expiry := key, expiry
+			result := new(bool)
+			deleted[key] = result
+			errGr.Go(func() error {
+				err := dm2.Expire(ctx, key, time.Hour)
+				if errors.Is(err, ErrKeyNotFound) {
+					// Deleted by the worker before the refresh.
+					return nil
+				}
+				return err
+			})
+			errGr.Go(func() error {
+				var err error
+				*result, err = dm1.DeleteIfExpiredBefore(ctx, key, expiry)
+				return err
+			})
+		}
+		require.NoError(t, errGr.Wait())
+
+		// A key is either deleted before the refresh or refreshed and kept.
+		for key, result := range deleted {
+			_, err := dm1.Get(ctx, key)
+			if *result {
+				require.ErrorIs(t, err, ErrKeyNotFound)
+			} else {
+				require.NoError(t, err)
+			}
+		}
+	})
+}
@@ -65,6 +65,7 @@ func (s *Service) destroyLocalDMap(name string) error {
 	s.Lock()
 	delete(s.dmaps, name)
 	s.Unlock()
+	s.gossip.drop(name)
 
 	return nil
 }
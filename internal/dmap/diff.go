@@ -0,0 +1,201 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/cespare/xxhash/v2"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// localValueHashes walks the primary copies of the DMap on this member and returns the
+// hashes of the values by key. It reads the raw entries to avoid updating the last access
+// time of the keys.
+func (s *Service) localValueHashes(name string) (map[string]uint64, error) {
+	hashes := make(map[string]uint64)
+	dm, err := s.getDMap(name)
+	if errors.Is(err, ErrDMapNotFound) {
+		return hashes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixNano() / 1000000
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var decryptErr error
+		f.RLock()
+		e := f.storage.NewEntry()
+		f.storage.RangeHKey(func(hkey uint64) bool {
+			raw, err := f.storage.GetRaw(hkey)
+			if err != nil {
+				return true // continue
+			}
+			e.Decode(raw)
+			if e.TTL() != 0 && now >= e.TTL() {
+				// Expired but not evicted yet.
+				return true
+			}
+			// The ciphertexts of the same value differ, compare the plaintexts.
+			value, err := dm.decryptValue(e.Key(), e.Value())
+			if err != nil {
+				decryptErr = err
+				return false
+			}
+			hashes[e.Key()] = xxhash.Sum64(value)
+			return true
+		})
+		f.RUnlock()
+		if decryptErr != nil {
+			return nil, decryptErr
+		}
+	}
+	return hashes, nil
+}
+
+// valueHashes collects the value hashes of the DMap from all members concurrently.
+// Only the hashes are transferred, not the values.
+func (s *Service) valueHashes(ctx context.Context, name string) (map[string]uint64, error) {
+	result := make(map[string]uint64)
+	var mtx sync.Mutex
+
+	num := int64(runtime.NumCPU())
+	sem := semaphore.NewWeighted(num)
+
+	var members []discovery.Member
+	m := s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		members = append(members, member)
+		return true
+	})
+	m.RUnlock()
+
+	var g errgroup.Group
+	for _, item := range members {
+		member := item
+		g.Go(func() error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+
+			var hashes map[string]uint64
+			if member.CompareByID(s.rt.This()) {
+				var err error
+				hashes, err = s.localValueHashes(name)
+				if err != nil {
+					return err
+				}
+			} else {
+				cmd := protocol.NewValueHashes(name).Command(ctx)
+				rc := s.client.Get(member.String())
+				if err := rc.Process(ctx, cmd); err != nil {
+					return protocol.ConvertError(err)
+				}
+				var err error
+				hashes, err = parseValueHashes(cmd.Val())
+				if err != nil {
+					return err
+				}
+			}
+
+			mtx.Lock()
+			for key, hash := range hashes {
+				result[key] = hash
+			}
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseValueHashes parses a flat list of key and value hash pairs.
+func parseValueHashes(values []interface{}) (map[string]uint64, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("invalid value hashes response")
+	}
+	hashes := make(map[string]uint64)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", values[i])
+		}
+		hash, ok := values[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid value hash: %v", values[i+1])
+		}
+		hashes[key] = uint64(hash)
+	}
+	return hashes, nil
+}
+
+// Diff compares two DMaps by the keys and the hashes of the values. It returns the keys
+// that exist only in dmapA, the keys that exist only in dmapB and the keys that exist in
+// both with different values. Every member walks its primary copies of both DMaps, so the
+// cost is O(N) in the number of keys. The results are sorted.
+func (s *Service) Diff(ctx context.Context, dmapA, dmapB string) (onlyA, onlyB, valueDiffers []string, err error) {
+	hashesA, err := s.valueHashes(ctx, dmapA)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	hashesB, err := s.valueHashes(ctx, dmapB)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	for key, hashA := range hashesA {
+		hashB, ok := hashesB[key]
+		if !ok {
+			onlyA = append(onlyA, key)
+			continue
+		}
+		if hashA != hashB {
+			valueDiffers = append(valueDiffers, key)
+		}
+	}
+	for key := range hashesB {
+		if _, ok := hashesA[key]; !ok {
+			onlyB = append(onlyB, key)
+		}
+	}
+	sort.Strings(onlyA)
+	sort.Strings(onlyB)
+	sort.Strings(valueDiffers)
+	return onlyA, onlyB, valueDiffers, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) valueHashesCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	valueHashesCmd, err := protocol.ParseValueHashesCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	hashes, err := s.localValueHashes(valueHashesCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(hashes) * 2)
+	for key, hash := range hashes {
+		conn.WriteBulkString(key)
+		conn.WriteInt64(int64(hash))
+	}
+}
+
+func writeKeys(conn redcon.Conn, keys []string) {
+	conn.WriteArray(len(keys))
+	for _, key := range keys {
+		conn.WriteBulkString(key)
+	}
+}
+
+func (s *Service) diffCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	diffCmd, err := protocol.ParseDiffCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	onlyA, onlyB, valueDiffers, err := s.Diff(s.ctx, diffCmd.DMapA, diffCmd.DMapB)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(3)
+	writeKeys(conn, onlyA)
+	writeKeys(conn, onlyB)
+	writeKeys(conn, valueDiffers)
+}
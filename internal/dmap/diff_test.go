@@ -0,0 +1,76 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Diff(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	live, err := s1.NewDMap("live")
+	require.NoError(t, err)
+	rebuilt, err := s2.NewDMap("rebuilt")
+	require.NoError(t, err)
+
+	var expectedOnlyA, expectedOnlyB, expectedValueDiffers []string
+	for i := 0; i < 20; i++ {
+		require.NoError(t, live.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+		if i < 5 {
+			expectedOnlyA = append(expectedOnlyA, testutil.ToKey(i))
+		}
+	}
+	for i := 5; i < 25; i++ {
+		value := testutil.ToVal(i)
+		if i == 10 || i == 11 {
+			value = testutil.ToVal(i * 100)
+			expectedValueDiffers = append(expectedValueDiffers, testutil.ToKey(i))
+		}
+		require.NoError(t, rebuilt.Put(ctx, testutil.ToKey(i), value, nil))
+		if i >= 20 {
+			expectedOnlyB = append(expectedOnlyB, testutil.ToKey(i))
+		}
+	}
+	sort.Strings(expectedOnlyA)
+	sort.Strings(expectedOnlyB)
+	sort.Strings(expectedValueDiffers)
+
+	for _, s := range []*Service{s1, s2} {
+		onlyA, onlyB, valueDiffers, err := s.Diff(ctx, "live", "rebuilt")
+		require.NoError(t, err)
+		require.Equal(t, expectedOnlyA, onlyA)
+		require.Equal(t, expectedOnlyB, onlyB)
+		require.Equal(t, expectedValueDiffers, valueDiffers)
+	}
+
+	t.Run("Unknown DMap", func(t *testing.T) {
+		onlyA, onlyB, valueDiffers, err := s1.Diff(ctx, "live", "unknown")
+		require.NoError(t, err)
+		require.Len(t, onlyA, 20)
+		require.Empty(t, onlyB)
+		require.Empty(t, valueDiffers)
+	})
+}
@@ -17,6 +17,7 @@ package dmap
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
@@ -25,6 +26,16 @@ import (
 
 const nilTimeout = 0 * time.Second
 
+// internalDMapPrefix is the prefix of the DMaps that are used by Olric itself. They don't
+// inherit the configuration of the DMaps, so their keys never expire or get evicted, and
+// they are not counted for MaxDMaps.
+const internalDMapPrefix = "olric.internal."
+
+// isInternalDMap returns true if the DMap is used by Olric itself.
+func isInternalDMap(name string) bool {
+	return strings.HasPrefix(name, internalDMapPrefix)
+}
+
 var (
 	// ErrKeyNotFound is returned when a key could not be found.
 	ErrKeyNotFound  = errors.New("key not found")
@@ -39,6 +50,9 @@ type DMap struct {
 	s            *Service
 	engine       storage.Engine
 	config       *dmapConfig
+	accesses     *accessCounter
+	metrics      *OperationMetrics
+	claimCursor  claimCursor
 }
 
 // Name exposes name of the DMap.
@@ -63,8 +77,18 @@ func (s *Service) fragmentName(name string) string {
 }
 
 // NewDMap creates and returns a new DMap instance. It checks member count quorum
-// and bootstrapping status before creating a new DMap.
+// and bootstrapping status before creating a new DMap. It returns ErrTooManyDMaps
+// if the DMap doesn't exist in the cluster and config.DMaps.MaxDMaps is reached.
 func (s *Service) NewDMap(name string) (*DMap, error) {
+	if err := s.checkMaxDMaps(name); err != nil {
+		return nil, err
+	}
+	return s.newDMap(name)
+}
+
+// newDMap creates a new DMap instance without checking config.DMaps.MaxDMaps. It's
+// used to host the fragments of the DMaps that already exist in the cluster.
+func (s *Service) newDMap(name string) (*DMap, error) {
 	// Check operation status first:
 	//
 	// * Checks member count in the cluster, returns ErrClusterQuorum if
@@ -92,13 +116,22 @@ func (s *Service) NewDMap(name string) (*DMap, error) {
 		name:         name,
 		fragmentName: s.fragmentName(name),
 		s:            s,
+		metrics:      DMapOperations.metricsOf(name, s.config.DMaps.MaxMetricLabels),
 	}
-	if err := dm.config.load(s.config.DMaps, name); err != nil {
+	if isInternalDMap(name) {
+		dm.config.engine = s.config.DMaps.Engine
+	} else if err := dm.config.load(s.config.DMaps, name); err != nil {
 		return nil, err
 	}
 
 	// It's a shortcut.
 	dm.engine = dm.config.engine.Implementation
+	if dm.config.accessCounter {
+		dm.accesses = newAccessCounter(s.config.DMaps.AccessCounterHalfLife)
+	}
+	if err := dm.preSplit(); err != nil {
+		return nil, err
+	}
 	s.dmaps[name] = dm
 	return dm, nil
 }
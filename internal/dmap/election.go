@@ -0,0 +1,151 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// ErrNoLeader is returned by Leader when the election has no leader.
+var ErrNoLeader = errors.New("no leader")
+
+func (dm *DMap) campaign(e *env, candidate string, ttl time.Duration) (string, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if entry != nil && string(entry.Value()) != candidate {
+		// Someone else holds the lease.
+		return string(entry.Value()), nil
+	}
+
+	// Take the lease, or renew it if the candidate is already the leader.
+	e.value = []byte(candidate)
+	e.putConfig.HasPX = true
+	e.putConfig.PX = ttl
+	err = dm.put(e)
+	if err != nil {
+		return "", err
+	}
+	return candidate, nil
+}
+
+// Campaign makes the candidate the leader of the election for ttl, if the election has
+// no leader or the candidate is already the leader, in which case the lease is renewed.
+// It returns the current leader. The elections are stored as keys, the operation runs
+// on the partition owner of the election.
+func (dm *DMap) Campaign(ctx context.Context, election, candidate string, ttl time.Duration) (string, error) {
+	if ttl.Milliseconds() <= 0 {
+		return "", fmt.Errorf("%w: TTL is less than a millisecond: %s", protocol.ErrInvalidArgument, ttl)
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, election)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = election
+		return dm.campaign(e, candidate, ttl)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewCampaign(dm.name, election, candidate, ttl.Milliseconds()).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return "", protocol.ConvertError(err)
+	}
+	leader, err := cmd.Result()
+	if err != nil {
+		return "", protocol.ConvertError(err)
+	}
+	return leader, nil
+}
+
+func (dm *DMap) resign(e *env, candidate string) error {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if string(entry.Value()) != candidate {
+		// The lease is already expired and taken by someone else.
+		return nil
+	}
+	_, err = dm.deleteKeys(e.ctx, e.key)
+	return err
+}
+
+// Resign releases the lease of the candidate, so a new leader can be elected without
+// waiting for the lease to expire. It's a no-op if the candidate is not the leader.
+func (dm *DMap) Resign(ctx context.Context, election, candidate string) error {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, election)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = election
+		return dm.resign(e, candidate)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewResign(dm.name, election, candidate).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// Leader returns the current leader of the election. It returns ErrNoLeader if the
+// election has no leader.
+func (dm *DMap) Leader(ctx context.Context, election string) (string, error) {
+	entry, err := dm.Get(ctx, election)
+	if errors.Is(err, ErrKeyNotFound) {
+		return "", ErrNoLeader
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(entry.Value()), nil
+}
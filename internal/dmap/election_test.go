@@ -0,0 +1,96 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Election(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("elections")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("elections")
+	require.NoError(t, err)
+
+	// The elections are distributed, some of the calls are redirected to the owners.
+	for i := 0; i < 10; i++ {
+		election := testutil.ToKey(i)
+
+		_, err = dm2.Leader(ctx, election)
+		require.ErrorIs(t, err, ErrNoLeader)
+
+		leader, err := dm1.Campaign(ctx, election, "candidate-a", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, "candidate-a", leader)
+
+		leader, err = dm2.Campaign(ctx, election, "candidate-b", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, "candidate-a", leader)
+
+		// Renew the lease.
+		leader, err = dm1.Campaign(ctx, election, "candidate-a", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, "candidate-a", leader)
+
+		// Only the leader can resign.
+		require.NoError(t, dm2.Resign(ctx, election, "candidate-b"))
+		leader, err = dm2.Leader(ctx, election)
+		require.NoError(t, err)
+		require.Equal(t, "candidate-a", leader)
+
+		require.NoError(t, dm1.Resign(ctx, election, "candidate-a"))
+		_, err = dm1.Leader(ctx, election)
+		require.ErrorIs(t, err, ErrNoLeader)
+
+		leader, err = dm2.Campaign(ctx, election, "candidate-b", time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, "candidate-b", leader)
+	}
+}
+
+func TestDMap_Election_LeaseExpired(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("elections")
+	require.NoError(t, err)
+
+	leader, err := dm.Campaign(ctx, "my-election", "candidate-a", 50*time.Millisecond)
+	require.NoError(t, err)
+	require.Equal(t, "candidate-a", leader)
+
+	<-time.After(100 * time.Millisecond)
+
+	leader, err = dm.Campaign(ctx, "my-election", "candidate-b", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, "candidate-b", leader)
+
+	_, err = dm.Campaign(ctx, "my-election", "candidate-b", 0)
+	require.Error(t, err)
+}
@@ -0,0 +1,84 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrDecryption is returned when a stored value cannot be decrypted with the configured key.
+var ErrDecryption = errors.New("failed to decrypt value")
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptValue encrypts the value if the encryption is enabled for the DMap. The nonce is
+// stored in front of the ciphertext. The key is authenticated with the value, so a value
+// cannot be moved under another key.
+func (dm *DMap) encryptValue(key string, value []byte) ([]byte, error) {
+	if dm.config == nil || dm.config.aead == nil {
+		return value, nil
+	}
+	aead := dm.config.aead
+
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(value)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, value, []byte(key)), nil
+}
+
+// decryptValue reverses encryptValue.
+func (dm *DMap) decryptValue(key string, value []byte) ([]byte, error) {
+	if dm.config == nil || dm.config.aead == nil {
+		return value, nil
+	}
+	aead := dm.config.aead
+
+	if len(value) < aead.NonceSize() {
+		return nil, fmt.Errorf("%w: key: %s", ErrDecryption, key)
+	}
+	nonce, ciphertext := value[:aead.NonceSize()], value[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: key: %s", ErrDecryption, key)
+	}
+	return plaintext, nil
+}
+
+// decryptEntry replaces the value of the entry with the plaintext. The storage engine is
+// not modified.
+func (dm *DMap) decryptEntry(entry storage.Entry) error {
+	if dm.config == nil || dm.config.aead == nil {
+		return nil
+	}
+	value, err := dm.decryptValue(entry.Key(), entry.Value())
+	if err != nil {
+		return err
+	}
+	entry.SetValue(value)
+	return nil
+}
@@ -0,0 +1,101 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/environment"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Encryption(t *testing.T) {
+	cluster := testcluster.New(NewService)
+
+	newConfig := func() *environment.Environment {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.DMaps.EncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+		return testcluster.NewEnvironment(c)
+	}
+	s1 := cluster.AddMember(newConfig()).(*Service)
+	s2 := cluster.AddMember(newConfig()).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	plaintext := []byte("secret-value")
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), plaintext, nil))
+	}
+
+	t.Run("Round trip", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			for _, dm := range []*DMap{dm1, dm2} {
+				entry, err := dm.Get(ctx, testutil.ToKey(i))
+				require.NoError(t, err)
+				require.Equal(t, plaintext, entry.Value())
+			}
+		}
+	})
+
+	t.Run("Stored bytes are not the plaintext", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			key := testutil.ToKey(i)
+			hkey := partitions.HKey("mydmap", key)
+			var copies int
+			for _, s := range []*Service{s1, s2} {
+				dm, err := s.NewDMap("mydmap")
+				require.NoError(t, err)
+				// Both members store a copy, one is the primary and the other is the replica.
+				for _, part := range []*partitions.Partition{
+					s.primary.PartitionByHKey(hkey),
+					s.backup.PartitionByHKey(hkey),
+				} {
+					f, err := dm.loadFragment(part)
+					if err == errFragmentNotFound {
+						continue
+					}
+					require.NoError(t, err)
+					f.RLock()
+					entry, err := f.storage.Get(hkey)
+					f.RUnlock()
+					if err != nil {
+						continue
+					}
+					require.False(t, bytes.Contains(entry.Value(), plaintext))
+					copies++
+				}
+			}
+			require.Equal(t, 2, copies)
+		}
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		value, err := dm1.encryptValue("mykey", plaintext)
+		require.NoError(t, err)
+		_, err = dm1.decryptValue("another-key", value)
+		require.ErrorIs(t, err, ErrDecryption)
+	})
+}
@@ -0,0 +1,174 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// ageRange keeps the oldest and the newest write timestamps in nanoseconds. Zero
+// values mean that there is no entry.
+type ageRange struct {
+	oldest int64
+	newest int64
+}
+
+func (r *ageRange) merge(oldest, newest int64) {
+	if oldest == 0 {
+		return
+	}
+	if r.oldest == 0 || oldest < r.oldest {
+		r.oldest = oldest
+	}
+	if newest > r.newest {
+		r.newest = newest
+	}
+}
+
+// localEntryAgeRange walks the primary copies on this member. It reads the raw entries
+// to avoid updating the last access time of the keys.
+func (s *Service) localEntryAgeRange(name string) (ageRange, error) {
+	var result ageRange
+	dm, err := s.getDMap(name)
+	if errors.Is(err, ErrDMapNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now().UnixNano() / 1000000
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return result, err
+		}
+
+		f.RLock()
+		e := f.storage.NewEntry()
+		f.storage.RangeHKey(func(hkey uint64) bool {
+			raw, err := f.storage.GetRaw(hkey)
+			if err != nil {
+				return true // continue
+			}
+			e.Decode(raw)
+			if e.TTL() != 0 && now >= e.TTL() {
+				// Expired but not evicted yet.
+				return true
+			}
+			result.merge(e.Timestamp(), e.Timestamp())
+			return true
+		})
+		f.RUnlock()
+	}
+	return result, nil
+}
+
+func (dm *DMap) entryAgeRangeOnCluster(ctx context.Context) (ageRange, error) {
+	var result ageRange
+	var mtx sync.Mutex
+
+	num := int64(runtime.NumCPU())
+	sem := semaphore.NewWeighted(num)
+
+	var members []discovery.Member
+	m := dm.s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		members = append(members, member)
+		return true
+	})
+	m.RUnlock()
+
+	var g errgroup.Group
+	for _, item := range members {
+		member := item
+		g.Go(func() error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+
+			var oldest, newest int64
+			if member.CompareByID(dm.s.rt.This()) {
+				r, err := dm.s.localEntryAgeRange(dm.name)
+				if err != nil {
+					return err
+				}
+				oldest, newest = r.oldest, r.newest
+			} else {
+				cmd := protocol.NewEntryAgeRange(dm.name).SetLocal().Command(ctx)
+				rc := dm.s.client.Get(member.String())
+				if err := rc.Process(ctx, cmd); err != nil {
+					return protocol.ConvertError(err)
+				}
+				var err error
+				oldest, newest, err = parseEntryAgeRange(cmd.Val())
+				if err != nil {
+					return err
+				}
+			}
+
+			mtx.Lock()
+			result.merge(oldest, newest)
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return ageRange{}, err
+	}
+	return result, nil
+}
+
+func parseEntryAgeRange(values []interface{}) (int64, int64, error) {
+	if len(values) != 2 {
+		return 0, 0, errors.New("invalid entry age range response")
+	}
+	oldest, ok := values[0].(int64)
+	if !ok {
+		return 0, 0, errors.New("invalid oldest timestamp")
+	}
+	newest, ok := values[1].(int64)
+	if !ok {
+		return 0, 0, errors.New("invalid newest timestamp")
+	}
+	return oldest, newest, nil
+}
+
+// EntryAgeRange returns the oldest and the newest write timestamps of the entries in
+// the DMap, in nanoseconds. The members are queried concurrently, every member walks
+// its primary copies. It returns zero values if the DMap has no entries. Expired keys
+// that are not evicted yet are skipped.
+func (dm *DMap) EntryAgeRange(ctx context.Context) (oldest, newest int64, err error) {
+	r, err := dm.entryAgeRangeOnCluster(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return r.oldest, r.newest, nil
+}
@@ -0,0 +1,49 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) entryAgeRangeCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	entryAgeRangeCmd, err := protocol.ParseEntryAgeRangeCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	var r ageRange
+	if entryAgeRangeCmd.Local {
+		r, err = s.localEntryAgeRange(entryAgeRangeCmd.DMap)
+	} else {
+		var dm *DMap
+		dm, err = s.getOrCreateDMap(entryAgeRangeCmd.DMap)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		r, err = dm.entryAgeRangeOnCluster(s.ctx)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(2)
+	conn.WriteInt64(r.oldest)
+	conn.WriteInt64(r.newest)
+}
@@ -0,0 +1,66 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_EntryAgeRange(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	oldest, newest, err := dm1.EntryAgeRange(ctx)
+	require.NoError(t, err)
+	require.Zero(t, oldest)
+	require.Zero(t, newest)
+
+	start := time.Now().UnixNano()
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+	firstBatch := time.Now().UnixNano()
+
+	<-time.After(50 * time.Millisecond)
+
+	secondBatch := time.Now().UnixNano()
+	for i := 10; i < 20; i++ {
+		require.NoError(t, dm2.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+	end := time.Now().UnixNano()
+
+	for _, dm := range []*DMap{dm1, dm2} {
+		oldest, newest, err := dm.EntryAgeRange(ctx)
+		require.NoError(t, err)
+		require.GreaterOrEqual(t, oldest, start)
+		require.LessOrEqual(t, oldest, firstBatch)
+		require.GreaterOrEqual(t, newest, secondBatch)
+		require.LessOrEqual(t, newest, end)
+	}
+}
@@ -32,6 +32,8 @@ type env struct {
 	timeout   time.Duration
 	kind      partitions.Kind
 	fragment  *fragment
+	// version is stored in the metadata of the entry, see PutWithVersion.
+	version int64
 }
 
 func newEnv(ctx context.Context) *env {
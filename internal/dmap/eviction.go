@@ -20,6 +20,7 @@ import (
 	"math/rand"
 	"runtime"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
@@ -46,7 +47,46 @@ func (dm *DMap) isKeyIdleOnFragment(hkey uint64, f *fragment) bool {
 	}
 	//TODO: Handle other errors.
 	ttl := (dm.config.maxIdleDuration.Nanoseconds() + lastAccess) / 1000000
-	return isKeyExpired(ttl)
+	if !isKeyExpired(ttl) {
+		return false
+	}
+	if dm.config.gracePeriod > dm.config.maxIdleDuration {
+		// The key may be idle but still in its grace period. Read the raw entry,
+		// Get updates the last access time.
+		raw, err := f.storage.GetRaw(hkey)
+		if err != nil {
+			return false
+		}
+		entry := f.storage.NewEntry()
+		entry.Decode(raw)
+		if dm.config.inGracePeriod(entry.Timestamp()) {
+			return false
+		}
+	}
+	if len(dm.config.noEvictKeys) == 0 {
+		return true
+	}
+	key, err := f.storage.GetKey(hkey)
+	if err != nil {
+		return false
+	}
+	// The keys that match a no-evict pattern never become idle.
+	return dm.config.isEvictable(key)
+}
+
+// isKeyTooOldOnFragment returns true if the last write of the key is older than MaxAge.
+// It is not a thread-safe function. It accesses underlying fragment for the given hkey.
+func (dm *DMap) isKeyTooOldOnFragment(hkey uint64, f *fragment) bool {
+	if dm.config == nil || dm.config.maxAge <= 0 {
+		return false
+	}
+	raw, err := f.storage.GetRaw(hkey)
+	if err != nil {
+		return false
+	}
+	entry := f.storage.NewEntry()
+	entry.Decode(raw)
+	return time.Now().UnixNano()-entry.Timestamp() > dm.config.maxAge.Nanoseconds()
 }
 
 func (dm *DMap) isKeyIdle(hkey uint64) bool {
@@ -123,7 +163,8 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 	var maxTotalCount = 100
 	var totalCount = 0
 
-	dm, err := s.getOrCreateDMap(name)
+	// name is the fragment name, it has the "dmap." prefix.
+	dm, err := s.getOrCreateDMap(strings.TrimPrefix(name, "dmap."))
 	if err != nil {
 		s.log.V(3).Printf("[ERROR] Failed to load DMap: %s: %v", name, err)
 		return
@@ -154,7 +195,17 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 				return true // continue
 			}
 
-			if isKeyExpired(ttl) || dm.isKeyIdleOnFragment(hkey, f) {
+			// The entries older than MaxAge are expired regardless of their TTL.
+			expired := isKeyExpired(ttl) || dm.isKeyTooOldOnFragment(hkey, f)
+			if expired || dm.isKeyIdleOnFragment(hkey, f) {
+				var entry storage.Entry
+				if expired && dm.hasDeadLetterDMap() {
+					entry, err = f.storage.Get(hkey)
+					if err != nil {
+						dm.s.log.V(3).Printf("[ERROR] Failed to get expired key: %s on DMap: %s: %v", key, dm.name, err)
+					}
+				}
+
 				err = dm.deleteOnCluster(hkey, key, f)
 				if err != nil {
 					// It will be tried again.
@@ -165,6 +216,11 @@ func (s *Service) scanFragmentForEviction(partID uint64, name string, f *fragmen
 
 				// number of valid items removed from cache to free memory for new items.
 				EvictedTotal.Increase(1)
+
+				dm.publishDelete(ChangeExpire, key)
+				if entry != nil {
+					dm.archiveExpired(entry)
+				}
 			}
 			return true
 		})
@@ -204,6 +260,7 @@ type lruItem struct {
 
 func (dm *DMap) evictKeyWithLRU(e *env) error {
 	var idx = 1
+	var protected int
 	var items []lruItem
 
 	// Warning: fragment is already locked by DMap.Put. Be sure about that before editing this function.
@@ -213,7 +270,14 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 		if idx >= dm.config.lruSamples {
 			return false
 		}
+		// The protected keys are counted as samples too, otherwise a fragment of
+		// mostly protected keys is scanned from end to end on every write.
 		idx++
+		if !dm.config.isEvictable(e.Key()) || dm.config.inGracePeriod(e.Timestamp()) {
+			// Skip the keys that match a no-evict pattern and the keys in their grace period.
+			protected++
+			return true
+		}
 		i := lruItem{
 			HKey:       hkey,
 			LastAccess: e.LastAccess(),
@@ -223,6 +287,10 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 	})
 
 	if len(items) == 0 {
+		if protected > 0 {
+			// All keys are protected, the limit is exceeded by them.
+			return nil
+		}
 		return fmt.Errorf("nothing found to expire with LRU")
 	}
 
@@ -248,5 +316,7 @@ func (dm *DMap) evictKeyWithLRU(e *env) error {
 
 	// number of valid items removed from cache to free memory for new items.
 	EvictedTotal.Increase(1)
+
+	dm.publishDelete(ChangeExpire, key)
 	return nil
 }
@@ -22,6 +22,7 @@ import (
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/testcluster"
 	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/stretchr/testify/require"
 )
 
@@ -211,3 +212,201 @@ func TestDMap_Eviction_LRU_Config_MaxInuse(t *testing.T) {
 
 	require.NotEqual(t, 100, length)
 }
+
+func TestDMap_Eviction_NoEvictKeyPatterns(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps = &config.DMaps{
+		MaxIdleDuration:    100 * time.Millisecond,
+		NoEvictKeyPatterns: []string{"^config:"},
+		Engine:             config.NewEngine(),
+	}
+	require.NoError(t, c.DMaps.Engine.Sanitize())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, "config:"+testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	<-time.After(150 * time.Millisecond)
+	// Scan all fragments, the idle keys are evicted unless they are protected.
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		part.Map().Range(func(name, v interface{}) bool {
+			s.scanFragmentForEviction(partID, name.(string), v.(*fragment))
+			return true
+		})
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Get(ctx, "config:"+testutil.ToKey(i))
+		require.NoError(t, err)
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
+
+// rangeCountingEngine counts the entries visited by Range.
+type rangeCountingEngine struct {
+	storage.Engine
+	visited int
+}
+
+func (r *rangeCountingEngine) Range(f func(uint64, storage.Entry) bool) {
+	r.Engine.Range(func(hkey uint64, e storage.Entry) bool {
+		r.visited++
+		return f(hkey, e)
+	})
+}
+
+func TestDMap_Eviction_LRU_Protected_Keys(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.PartitionCount = 1
+	c.DMaps = &config.DMaps{
+		MaxKeys:            100000,
+		EvictionPolicy:     config.LRUEviction,
+		LRUSamples:         5,
+		NoEvictKeyPatterns: []string{"^config:"},
+		Engine:             config.NewEngine(),
+	}
+	require.NoError(t, c.DMaps.Engine.Sanitize())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The fragment is mostly protected.
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		err = dm.Put(ctx, "config:"+testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+	err = dm.Put(ctx, testutil.ToKey(0), testutil.ToVal(0), nil)
+	require.NoError(t, err)
+
+	f, err := dm.loadFragment(s.primary.PartitionByID(0))
+	require.NoError(t, err)
+
+	f.Lock()
+	defer f.Unlock()
+
+	engine := &rangeCountingEngine{Engine: f.storage}
+	f.storage = engine
+	defer func() {
+		f.storage = engine.Engine
+	}()
+
+	env := newEnv(ctx)
+	env.dmap = dm.name
+	env.fragment = f
+	require.NoError(t, dm.evictKeyWithLRU(env))
+	// The protected keys are counted as samples, the fragment is not scanned from end to end.
+	require.LessOrEqual(t, engine.visited, dm.config.lruSamples)
+}
+
+func TestDMap_Eviction_GracePeriod(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps = &config.DMaps{
+		MaxKeys:             70,
+		MaxIdleDuration:     10 * time.Millisecond,
+		EvictionPolicy:      config.LRUEviction,
+		EvictionGracePeriod: time.Minute,
+		Engine:              config.NewEngine(),
+	}
+	require.NoError(t, c.DMaps.Engine.Sanitize())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Write far more keys than MaxKeys, LRU would evict most of them without the grace period.
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	<-time.After(20 * time.Millisecond)
+	// The keys are idle but still in their grace period.
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		part.Map().Range(func(name, v interface{}) bool {
+			s.scanFragmentForEviction(partID, name.(string), v.(*fragment))
+			return true
+		})
+	}
+
+	for i := 0; i < 1000; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+}
+
+func TestDMap_Eviction_Config_MaxAge(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps = &config.DMaps{
+		MaxAge: 100 * time.Millisecond,
+		Engine: config.NewEngine(),
+	}
+	require.NoError(t, c.DMaps.Engine.Sanitize())
+
+	e := testcluster.NewEnvironment(c)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The old keys have no TTL or a longer one.
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		var pc *PutConfig
+		if i%2 == 0 {
+			pc = &PutConfig{HasEX: true, EX: time.Hour}
+		}
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc)
+		require.NoError(t, err)
+	}
+
+	<-time.After(150 * time.Millisecond)
+	for i := 10; i < 20; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil)
+		require.NoError(t, err)
+	}
+
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		part.Map().Range(func(name, v interface{}) bool {
+			s.scanFragmentForEviction(partID, name.(string), v.(*fragment))
+			return true
+		})
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+	for i := 10; i < 20; i++ {
+		_, err = dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+}
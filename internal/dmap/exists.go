@@ -0,0 +1,116 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// existsOnFragment checks the key on the primary copy. The value is not read.
+func (dm *DMap) existsOnFragment(hkey uint64) (bool, error) {
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	if !f.storage.Check(hkey) {
+		return false, nil
+	}
+	ttl, err := f.storage.GetTTL(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	// Expired but not evicted yet.
+	return ttl == 0 || ttl > time.Now().UnixNano()/1000000, nil
+}
+
+// localExists returns the number of the keys stored on this member.
+func (dm *DMap) localExists(keys []string) (int, error) {
+	var count int
+	for _, key := range keys {
+		ok, err := dm.existsOnFragment(partitions.HKey(dm.name, key))
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Exists returns the number of the keys that exist. A key is counted as many times
+// as it's given, like Redis' EXISTS. The keys are grouped by their partition owners
+// and every owner is queried once. The values are not read.
+func (dm *DMap) Exists(ctx context.Context, keys ...string) (int, error) {
+	owners := make(map[string]discovery.Member)
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		owner := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+		owners[owner.String()] = owner
+		groups[owner.String()] = append(groups[owner.String()], key)
+	}
+
+	var total int64
+	var g errgroup.Group
+	for name, items := range groups {
+		owner, group := owners[name], items
+		g.Go(func() error {
+			if owner.CompareByID(dm.s.rt.This()) {
+				count, err := dm.localExists(group)
+				if err != nil {
+					return err
+				}
+				atomic.AddInt64(&total, int64(count))
+				return nil
+			}
+
+			cmd := protocol.NewExists(dm.name, group).SetLocal().Command(ctx)
+			rc := dm.s.client.Get(owner.String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return protocol.ConvertError(err)
+			}
+			count, err := cmd.Result()
+			if err != nil {
+				return protocol.ConvertError(err)
+			}
+			atomic.AddInt64(&total, count)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
@@ -0,0 +1,69 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Exists(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		key := testutil.ToKey(i)
+		keys = append(keys, key)
+		if i%2 == 0 {
+			require.NoError(t, dm1.Put(ctx, key, testutil.ToVal(i), nil))
+		}
+	}
+
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+	count, err := dm2.Exists(ctx, keys...)
+	require.NoError(t, err)
+	require.Equal(t, 50, count)
+
+	t.Run("Duplicate keys", func(t *testing.T) {
+		count, err := dm2.Exists(ctx, testutil.ToKey(0), testutil.ToKey(0), testutil.ToKey(1))
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("Expired key", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "expired-key", "value", &PutConfig{
+			HasPX: true,
+			PX:    time.Millisecond,
+		}))
+		<-time.After(10 * time.Millisecond)
+
+		count, err := dm2.Exists(ctx, "expired-key")
+		require.NoError(t, err)
+		require.Equal(t, 0, count)
+	})
+}
@@ -32,3 +32,17 @@ func (dm *DMap) Expire(ctx context.Context, key string, timeout time.Duration) e
 	e.timeout = timeout
 	return dm.put(e)
 }
+
+// Persist removes the expiry of the given key, so it never expires. The value is not
+// rewritten. It returns ErrKeyNotFound if the DB does not contain the key. It's thread-safe.
+func (dm *DMap) Persist(ctx context.Context, key string) error {
+	pc := &PutConfig{
+		OnlyUpdateTTL: true,
+		Persist:       true,
+	}
+	e := newEnv(ctx)
+	e.putConfig = pc
+	e.dmap = dm.name
+	e.key = key
+	return dm.put(e)
+}
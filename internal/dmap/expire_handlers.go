@@ -78,3 +78,24 @@ func (s *Service) pexpireCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	}
 	conn.WriteString(protocol.StatusOK)
 }
+
+func (s *Service) persistCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	persistCmd, err := protocol.ParsePersistCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.getOrCreateDMap(persistCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	err = dm.Persist(s.ctx, persistCmd.Key)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
 )
 
@@ -111,3 +112,66 @@ func TestDMap_Expire_pexpireCommandHandler(t *testing.T) {
 	_, err = dm.Get(ctx, key)
 	require.ErrorIs(t, err, ErrKeyNotFound)
 }
+
+func TestDMap_Persist(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		pc := &PutConfig{
+			HasPX: true,
+			PX:    100 * time.Millisecond,
+		}
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc))
+	}
+
+	// Persist the keys on both the owners and the other members.
+	for i := 0; i < 10; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		require.NoError(t, dm.Persist(ctx, testutil.ToKey(i)))
+	}
+
+	<-time.After(200 * time.Millisecond)
+
+	for i := 0; i < 10; i++ {
+		entry, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), entry.Value())
+		require.Equal(t, int64(0), entry.TTL())
+	}
+}
+
+func TestDMap_Persist_ErrKeyNotFound(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	err = dm.Persist(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// An expired key is not resurrected.
+	pc := &PutConfig{
+		HasPX: true,
+		PX:    time.Millisecond,
+	}
+	require.NoError(t, dm.Put(ctx, "mykey", "myvalue", pc))
+	<-time.After(10 * time.Millisecond)
+
+	err = dm.Persist(ctx, "mykey")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
@@ -0,0 +1,184 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// KeyTTL is a key with its remaining time to live.
+type KeyTTL struct {
+	Key string
+	TTL time.Duration
+}
+
+// sortAndLimitKeyTTLs sorts the keys by their remaining TTL, the soonest expiring key
+// comes first, and keeps the first limit keys. Zero limit means no limit.
+func sortAndLimitKeyTTLs(keys []KeyTTL, limit int) []KeyTTL {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].TTL == keys[j].TTL {
+			return keys[i].Key < keys[j].Key
+		}
+		return keys[i].TTL < keys[j].TTL
+	})
+	if limit > 0 && len(keys) > limit {
+		keys = keys[:limit]
+	}
+	return keys
+}
+
+// localExpiringSoon walks the primary copies on this member and returns up to limit
+// keys whose remaining TTL is below within. It reads the TTLs with GetTTL to avoid
+// updating the last access time of the keys. The cost is O(N) in the number of keys.
+func (s *Service) localExpiringSoon(name string, within time.Duration, limit int) ([]KeyTTL, error) {
+	dm, err := s.getDMap(name)
+	if errors.Is(err, ErrDMapNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []KeyTTL
+	now := time.Now().UnixNano() / 1000000
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		f, err := dm.loadFragment(part)
+		if errors.Is(err, errFragmentNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		f.RLock()
+		f.storage.RangeHKey(func(hkey uint64) bool {
+			ttl, err := f.storage.GetTTL(hkey)
+			if err != nil || ttl == 0 {
+				// No expiry.
+				return true // continue
+			}
+			remaining := time.Duration(ttl-now) * time.Millisecond
+			if remaining <= 0 || remaining >= within {
+				// Expired but not evicted yet, or not expiring soon.
+				return true
+			}
+			key, err := f.storage.GetKey(hkey)
+			if err != nil {
+				return true
+			}
+			result = append(result, KeyTTL{Key: key, TTL: remaining})
+			return true
+		})
+		f.RUnlock()
+	}
+	return sortAndLimitKeyTTLs(result, limit), nil
+}
+
+func (dm *DMap) expiringSoonOnCluster(ctx context.Context, within time.Duration, limit int) ([]KeyTTL, error) {
+	var result []KeyTTL
+	var mtx sync.Mutex
+
+	num := int64(runtime.NumCPU())
+	sem := semaphore.NewWeighted(num)
+
+	var members []discovery.Member
+	m := dm.s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		members = append(members, member)
+		return true
+	})
+	m.RUnlock()
+
+	var g errgroup.Group
+	for _, item := range members {
+		member := item
+		g.Go(func() error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+
+			var keys []KeyTTL
+			if member.CompareByID(dm.s.rt.This()) {
+				var err error
+				keys, err = dm.s.localExpiringSoon(dm.name, within, limit)
+				if err != nil {
+					return err
+				}
+			} else {
+				cmd := protocol.NewExpiringSoon(dm.name, within.Milliseconds(), int64(limit)).SetLocal().Command(ctx)
+				rc := dm.s.client.Get(member.String())
+				if err := rc.Process(ctx, cmd); err != nil {
+					return protocol.ConvertError(err)
+				}
+				var err error
+				keys, err = parseExpiringSoon(cmd.Val())
+				if err != nil {
+					return err
+				}
+			}
+
+			mtx.Lock()
+			result = append(result, keys...)
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return sortAndLimitKeyTTLs(result, limit), nil
+}
+
+// parseExpiringSoon parses a flat list of key and remaining TTL in milliseconds pairs.
+func parseExpiringSoon(values []interface{}) ([]KeyTTL, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("invalid expiring soon response")
+	}
+	var keys []KeyTTL
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", values[i])
+		}
+		ttl, ok := values[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid TTL: %v", values[i+1])
+		}
+		keys = append(keys, KeyTTL{Key: key, TTL: time.Duration(ttl) * time.Millisecond})
+	}
+	return keys, nil
+}
+
+// ExpiringSoon returns up to limit keys whose remaining TTL is below within, with their
+// remaining TTLs. The soonest expiring keys come first, zero limit means no limit. The
+// members are queried concurrently and every member walks all of its primary copies, so
+// the cost is O(N) in the number of keys. Keys without a TTL are never returned.
+func (dm *DMap) ExpiringSoon(ctx context.Context, within time.Duration, limit int) ([]KeyTTL, error) {
+	return dm.expiringSoonOnCluster(ctx, within, limit)
+}
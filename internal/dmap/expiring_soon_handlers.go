@@ -0,0 +1,56 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) expiringSoonCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	expiringSoonCmd, err := protocol.ParseExpiringSoonCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	within := time.Duration(expiringSoonCmd.Within) * time.Millisecond
+	limit := int(expiringSoonCmd.Limit)
+
+	var keys []KeyTTL
+	if expiringSoonCmd.Local {
+		keys, err = s.localExpiringSoon(expiringSoonCmd.DMap, within, limit)
+	} else {
+		var dm *DMap
+		dm, err = s.getOrCreateDMap(expiringSoonCmd.DMap)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		keys, err = dm.expiringSoonOnCluster(s.ctx, within, limit)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(keys) * 2)
+	for _, k := range keys {
+		conn.WriteBulkString(k.Key)
+		conn.WriteInt64(k.TTL.Milliseconds())
+	}
+}
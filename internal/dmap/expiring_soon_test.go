@@ -0,0 +1,72 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_ExpiringSoon(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	expected := make(map[string]struct{})
+	for i := 0; i < 30; i++ {
+		var pc *PutConfig
+		switch {
+		case i < 10:
+			// Expiring soon, the TTLs are 10, 11, ... seconds.
+			pc = &PutConfig{HasPX: true, PX: time.Duration(10+i) * time.Second}
+			expected[testutil.ToKey(i)] = struct{}{}
+		case i < 20:
+			pc = &PutConfig{HasPX: true, PX: time.Hour}
+		}
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc))
+	}
+
+	for _, dm := range []*DMap{dm1, dm2} {
+		keys, err := dm.ExpiringSoon(ctx, time.Minute, 0)
+		require.NoError(t, err)
+		require.Len(t, keys, len(expected))
+		for _, k := range keys {
+			require.Contains(t, expected, k.Key)
+			require.Greater(t, int64(k.TTL), int64(0))
+			require.LessOrEqual(t, int64(k.TTL), int64(20*time.Second))
+		}
+	}
+
+	t.Run("Limit", func(t *testing.T) {
+		keys, err := dm2.ExpiringSoon(ctx, time.Minute, 3)
+		require.NoError(t, err)
+		require.Len(t, keys, 3)
+		for i, k := range keys {
+			require.Equal(t, testutil.ToKey(i), k.Key)
+		}
+	})
+}
@@ -55,6 +55,90 @@ func (f *fragment) Compaction() (bool, error) {
 	return f.storage.Compaction()
 }
 
+// deferredCompactor is implemented by the storage engines that can split the compaction
+// into the urgent steps and the deferrable ones.
+type deferredCompactor interface {
+	UrgentCompaction() (bool, error)
+	DeferredCompaction() (bool, error)
+}
+
+// UrgentCompaction runs only the compaction steps that cannot wait for a low-traffic
+// window. It falls back to Compaction if the storage engine doesn't support it.
+func (f *fragment) UrgentCompaction() (bool, error) {
+	select {
+	case <-f.ctx.Done():
+		// fragment is closed or destroyed
+		return false, nil
+	default:
+	}
+
+	c, ok := f.storage.(deferredCompactor)
+	if !ok {
+		return f.storage.Compaction()
+	}
+	return c.UrgentCompaction()
+}
+
+func (f *fragment) DeferredCompaction() (bool, error) {
+	select {
+	case <-f.ctx.Done():
+		// fragment is closed or destroyed
+		return true, nil
+	default:
+	}
+
+	c, ok := f.storage.(deferredCompactor)
+	if !ok {
+		// The storage engine doesn't support it, Compaction runs all steps.
+		return true, nil
+	}
+	return c.DeferredCompaction()
+}
+
+// oldTableCompactor is implemented by the storage engines that can compact only the
+// tables which are not written recently.
+type oldTableCompactor interface {
+	CompactTablesOlderThan(age time.Duration) (bool, error)
+}
+
+func (f *fragment) CompactTablesOlderThan(age time.Duration) (bool, error) {
+	select {
+	case <-f.ctx.Done():
+		// fragment is closed or destroyed
+		return true, nil
+	default:
+	}
+
+	c, ok := f.storage.(oldTableCompactor)
+	if !ok {
+		// The storage engine doesn't support it.
+		return true, nil
+	}
+	return c.CompactTablesOlderThan(age)
+}
+
+// tableConsolidator is implemented by the storage engines that can merge their tables
+// into fewer tables.
+type tableConsolidator interface {
+	ConsolidateTables() (bool, error)
+}
+
+func (f *fragment) ConsolidateTables() (bool, error) {
+	select {
+	case <-f.ctx.Done():
+		// fragment is closed or destroyed
+		return true, nil
+	default:
+	}
+
+	c, ok := f.storage.(tableConsolidator)
+	if !ok {
+		// The storage engine doesn't support it.
+		return true, nil
+	}
+	return c.ConsolidateTables()
+}
+
 func (f *fragment) Destroy() error {
 	select {
 	case <-f.ctx.Done():
@@ -73,6 +157,48 @@ func (f *fragment) Name() string {
 	return "DMap"
 }
 
+// lockContext locks the fragment for writing. It gives up with the context error if the
+// context is done before the lock is acquired.
+func (f *fragment) lockContext(ctx context.Context) error {
+	return acquireContext(ctx, f.TryLock, f.Lock)
+}
+
+// rlockContext locks the fragment for reading. It gives up with the context error if the
+// context is done before the lock is acquired.
+func (f *fragment) rlockContext(ctx context.Context) error {
+	return acquireContext(ctx, f.TryRLock, f.RLock)
+}
+
+const (
+	minLockRetryInterval = 50 * time.Microsecond
+	maxLockRetryInterval = 5 * time.Millisecond
+)
+
+// acquireContext calls tryLock until it succeeds or the context is done. sync.RWMutex
+// cannot be interrupted while waiting, so it polls with an exponential backoff. It calls
+// lock directly if the context can never be done.
+func acquireContext(ctx context.Context, tryLock func() bool, lock func()) error {
+	if ctx.Done() == nil {
+		lock()
+		return nil
+	}
+
+	interval := minLockRetryInterval
+	for {
+		if tryLock() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		if interval < maxLockRetryInterval {
+			interval *= 2
+		}
+	}
+}
+
 func (f *fragment) Move(part *partitions.Partition, name string, owners []discovery.Member) error {
 	f.Lock()
 	defer f.Unlock()
@@ -128,8 +254,13 @@ func (f *fragment) Move(part *partitions.Partition, name string, owners []discov
 	return i.Drop(index)
 }
 
-func (dm *DMap) newFragment() (*fragment, error) {
+// newEngine creates and starts an empty storage engine instance for a fragment of the DMap.
+func (dm *DMap) newEngine() (storage.Engine, error) {
 	c := storage.NewConfig(dm.config.engine.Config)
+	if size := dm.preSplitTableSize(); size != 0 {
+		c = c.Copy()
+		c.Add("tableSize", size)
+	}
 	engine, err := dm.engine.Fork(c)
 	if err != nil {
 		return nil, err
@@ -140,6 +271,14 @@ func (dm *DMap) newFragment() (*fragment, error) {
 	if err != nil {
 		return nil, err
 	}
+	return engine, nil
+}
+
+func (dm *DMap) newFragment() (*fragment, error) {
+	engine, err := dm.newEngine()
+	if err != nil {
+		return nil, err
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	return &fragment{
@@ -0,0 +1,73 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ReconfigureFragment reinitializes the storage engine of a fragment hosted by this member
+// with the given engine options, e.g. tableSize. The options override the engine options of
+// the DMap, the other fragments are not affected.
+//
+// The engine is forked with the new options and every live entry is copied into it under the
+// fragment lock, so it's an O(N) operation: the reads and writes on the fragment wait until the
+// copy is done and the memory usage of the fragment doubles for a short time. The options are
+// not persisted, a fragment that is moved to another member or recreated uses the options
+// of the DMap again.
+func (dm *DMap) ReconfigureFragment(partID uint64, kind partitions.Kind, options map[string]interface{}) error {
+	part := dm.s.primary.PartitionByID(partID)
+	if kind == partitions.BACKUP {
+		part = dm.s.backup.PartitionByID(partID)
+	}
+	f, err := dm.loadFragment(part)
+	if err != nil {
+		return err
+	}
+
+	c := storage.NewConfig(dm.config.engine.Config).Copy()
+	for key, value := range options {
+		c.Add(key, value)
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	engine, err := f.storage.Fork(c)
+	if err != nil {
+		return err
+	}
+	engine.SetLogger(dm.s.config.Logger)
+	if err = engine.Start(); err != nil {
+		return err
+	}
+
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		err = engine.Put(hkey, e)
+		return err == nil
+	})
+	if err != nil {
+		_ = engine.Close()
+		return err
+	}
+
+	old := f.storage
+	f.storage = engine
+	if err = old.Close(); err != nil {
+		return err
+	}
+	return old.Destroy()
+}
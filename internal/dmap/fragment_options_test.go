@@ -0,0 +1,72 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_ReconfigureFragment(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	key := testutil.ToKey(1)
+	require.NoError(t, dm.Put(ctx, key, testutil.ToVal(1), nil))
+
+	hkey := partitions.HKey(dm.name, key)
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+
+	const tableSize = 1 << 12
+	err = dm.ReconfigureFragment(part.ID(), partitions.PRIMARY, map[string]interface{}{
+		"tableSize": tableSize,
+	})
+	require.NoError(t, err)
+
+	f, err := dm.loadFragment(part)
+	require.NoError(t, err)
+
+	// The entries are copied into the new engine.
+	value, err := dm.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, testutil.ToVal(1), value.Value())
+
+	// Fill the fragment until it allocates new tables, all of them have the new size.
+	for i := 0; f.Stats().NumTables < 3; i++ {
+		e := f.storage.NewEntry()
+		e.SetKey(testutil.ToKey(i))
+		e.SetValue(testutil.ToVal(i))
+		require.NoError(t, f.storage.Put(uint64(i), e))
+	}
+	stats := f.Stats()
+	require.Equal(t, stats.NumTables*tableSize, stats.Allocated)
+
+	t.Run("Fragment not found", func(t *testing.T) {
+		err := dm.ReconfigureFragment(part.ID(), partitions.BACKUP, map[string]interface{}{
+			"tableSize": tableSize,
+		})
+		require.ErrorIs(t, err, errFragmentNotFound)
+	})
+}
@@ -15,9 +15,11 @@
 package dmap
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"sort"
+	"time"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
@@ -81,10 +83,10 @@ func (dm *DMap) getOnFragment(e *env) (storage.Entry, error) {
 	return entry, nil
 }
 
-func (dm *DMap) lookupOnPreviousOwner(owner *discovery.Member, key string) (*version, error) {
-	cmd := protocol.NewGetEntry(dm.name, key).Command(dm.s.ctx)
+func (dm *DMap) lookupOnPreviousOwner(ctx context.Context, owner *discovery.Member, key string) (*version, error) {
+	cmd := protocol.NewGetEntry(dm.name, key).SetStored().Command(dm.s.ctx)
 	rc := dm.s.client.Get(owner.String())
-	err := rc.Process(dm.s.ctx, cmd)
+	err := rc.Process(ctx, cmd)
 	if err != nil {
 		return nil, protocol.ConvertError(err)
 	}
@@ -108,7 +110,7 @@ func (dm *DMap) valueToVersion(value storage.Entry) *version {
 	}
 }
 
-func (dm *DMap) lookupOnThisNode(hkey uint64, key string) *version {
+func (dm *DMap) lookupOnThisNode(ctx context.Context, hkey uint64, key string) (*version, error) {
 	// Check on localhost, the partition owner.
 	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
 	f, err := dm.loadFragment(part)
@@ -116,9 +118,11 @@ func (dm *DMap) lookupOnThisNode(hkey uint64, key string) *version {
 		if !errors.Is(err, errFragmentNotFound) {
 			dm.s.log.V(3).Printf("[ERROR] Failed to get DMap fragment: %v", err)
 		}
-		return dm.valueToVersion(nil)
+		return dm.valueToVersion(nil), nil
+	}
+	if err = f.rlockContext(ctx); err != nil {
+		return nil, err
 	}
-	f.RLock()
 	defer f.RUnlock()
 
 	value, err := f.storage.Get(hkey)
@@ -127,7 +131,7 @@ func (dm *DMap) lookupOnThisNode(hkey uint64, key string) *version {
 			// still need to use "ver". just log this error.
 			dm.s.log.V(3).Printf("[ERROR] Failed to get key: %s on %s: %s", key, dm.name, err)
 		}
-		return dm.valueToVersion(nil)
+		return dm.valueToVersion(nil), nil
 	}
 	// We found the key
 	//
@@ -136,26 +140,32 @@ func (dm *DMap) lookupOnThisNode(hkey uint64, key string) *version {
 	// from the backup or the previous owners. When the fsck merge
 	// a fragmented partition or recover keys from a backup, Olric
 	// continue maintaining a reliable access log.
-	return dm.valueToVersion(value)
+	return dm.valueToVersion(value), nil
 }
 
 // lookupOnOwners collects versions of a key/value pair on the partition owner
 // by including previous partition owners.
-func (dm *DMap) lookupOnOwners(hkey uint64, key string) []*version {
+func (dm *DMap) lookupOnOwners(ctx context.Context, hkey uint64, key string) ([]*version, error) {
 	owners := dm.s.primary.PartitionOwnersByHKey(hkey)
 	if len(owners) == 0 {
 		panic("partition owners list cannot be empty")
 	}
 
-	var versions []*version
-	versions = append(versions, dm.lookupOnThisNode(hkey, key))
+	v, err := dm.lookupOnThisNode(ctx, hkey, key)
+	if err != nil {
+		return nil, err
+	}
+	versions := []*version{v}
 
 	// Run a query on the previous owners.
 	// Traverse in reverse order. Except from the latest host, this one.
 	for i := len(owners) - 2; i >= 0; i-- {
 		owner := owners[i]
-		v, err := dm.lookupOnPreviousOwner(&owner, key)
+		v, err := dm.lookupOnPreviousOwner(ctx, &owner, key)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			if dm.s.log.V(6).Ok() {
 				dm.s.log.V(6).Printf("[ERROR] Failed to call get on a previous "+
 					"primary owner: %s: %v", owner, err)
@@ -166,7 +176,7 @@ func (dm *DMap) lookupOnOwners(hkey uint64, key string) []*version {
 		// by the balancer.
 		versions = append(versions, v)
 	}
-	return versions
+	return versions, nil
 }
 
 func (dm *DMap) sortVersions(versions []*version) []*version {
@@ -193,15 +203,15 @@ func (dm *DMap) sanitizeAndSortVersions(versions []*version) []*version {
 	return dm.sortVersions(sanitized)
 }
 
-func (dm *DMap) lookupOnReplicas(hkey uint64, key string) []*version {
+func (dm *DMap) lookupOnReplicas(ctx context.Context, hkey uint64, key string) []*version {
 	// Check backup.
 	backups := dm.s.backup.PartitionOwnersByHKey(hkey)
 	versions := make([]*version, 0, len(backups))
 	for _, replica := range backups {
 		host := replica
-		cmd := protocol.NewGetEntry(dm.name, key).SetReplica().Command(dm.s.ctx)
+		cmd := protocol.NewGetEntry(dm.name, key).SetReplica().SetStored().Command(dm.s.ctx)
 		rc := dm.s.client.Get(host.String())
-		err := rc.Process(dm.s.ctx, cmd)
+		err := rc.Process(ctx, cmd)
 		err = protocol.ConvertError(err)
 		if err != nil {
 			if dm.s.log.V(6).Ok() {
@@ -229,11 +239,31 @@ func (dm *DMap) lookupOnReplicas(hkey uint64, key string) []*version {
 	return versions
 }
 
+// ttlOnlyRepair returns true if the stale version only needs the TTL of the winner.
+func (dm *DMap) ttlOnlyRepair(winner, stale *version) bool {
+	if dm.s.config.ReadRepairScope != config.MetadataReadRepair || stale.entry == nil {
+		return false
+	}
+	return bytes.Equal(winner.entry.Value(), stale.entry.Value())
+}
+
+// repairTTL updates the TTL and the timestamp of a stale replica without sending the value.
+func (dm *DMap) repairTTL(winner *version, host discovery.Member) error {
+	cmd := protocol.NewUpdateEntryTTL(dm.name, winner.entry.Key(), winner.entry.TTL(), winner.entry.Timestamp()).Command(dm.s.ctx)
+	rc := dm.s.client.Get(host.String())
+	err := rc.Process(dm.s.ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
 func (dm *DMap) readRepair(winner *version, versions []*version) {
 	for _, version := range versions {
 		if version.entry != nil && winner.entry.Timestamp() == version.entry.Timestamp() {
 			continue
 		}
+		ttlOnly := dm.ttlOnlyRepair(winner, version)
 
 		// Sync
 		tmp := *version.host
@@ -251,12 +281,22 @@ func (dm *DMap) readRepair(winner *version, versions []*version) {
 			e := newEnv(context.Background())
 			e.hkey = hkey
 			e.fragment = f
+			e.putConfig.OnlyUpdateTTL = ttlOnly
 			err = dm.putEntryOnFragment(e, winner.entry)
 			if err != nil {
 				dm.s.log.V(3).Printf("[ERROR] Failed to synchronize with replica: %v", err)
 			}
 			f.Unlock()
 		} else {
+			if ttlOnly {
+				err := dm.repairTTL(winner, *version.host)
+				if err == nil {
+					continue
+				}
+				// Fall back to the full repair, the replica may have lost the entry.
+				dm.s.log.V(6).Printf("[DEBUG] Failed to repair the TTL on replica %s: %v", version.host, err)
+			}
+
 			// If readRepair is enabled, this function is called by every GET request.
 			cmd := protocol.NewPutEntry(dm.name, winner.entry.Key(), winner.entry.Encode()).Command(dm.s.ctx)
 			rc := dm.s.client.Get(version.host.String())
@@ -273,13 +313,19 @@ func (dm *DMap) readRepair(winner *version, versions []*version) {
 	}
 }
 
-func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
+func (dm *DMap) getOnCluster(ctx context.Context, hkey uint64, key string) (storage.Entry, error) {
 	// RUnlock should not be called with defer statement here because
 	// readRepair function may call putOnFragment function which needs a write
 	// lock. Please don't forget calling RUnlock before returning here.
-	versions := dm.lookupOnOwners(hkey, key)
+	versions, err := dm.lookupOnOwners(ctx, hkey, key)
+	if err != nil {
+		return nil, err
+	}
 	if dm.s.config.ReadQuorum >= config.MinimumReplicaCount {
-		v := dm.lookupOnReplicas(hkey, key)
+		v := dm.lookupOnReplicas(ctx, hkey, key)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		versions = append(versions, v...)
 	}
 
@@ -299,7 +345,12 @@ func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
 
 	// The most up-to-date version of the values.
 	winner := sorted[0]
-	if isKeyExpired(winner.entry.TTL()) || dm.isKeyIdle(hkey) {
+	if isKeyExpired(winner.entry.TTL()) {
+		// The key is deleted by the janitor later, the dead-letter write is idempotent.
+		dm.archiveExpired(winner.entry)
+		return nil, ErrKeyNotFound
+	}
+	if dm.isKeyIdle(hkey) {
 		return nil, ErrKeyNotFound
 	}
 
@@ -313,23 +364,53 @@ func (dm *DMap) getOnCluster(hkey uint64, key string) (storage.Entry, error) {
 
 // Get gets the value for the given key. It returns ErrKeyNotFound if the DB
 // does not contain the key. It's thread-safe. It is safe to modify the contents
-// of the returned value.
+// of the returned value. It returns ErrTimeout if the read timeout of the DMap is exceeded.
 func (dm *DMap) Get(ctx context.Context, key string) (storage.Entry, error) {
+	defer dm.metrics.observeGet(time.Now())
+	defer dm.observeSlow(protocol.DMap.Get, time.Now(), key)
+
+	var entry storage.Entry
+	err := RunWithTimeout(ctx, dm.config.readTimeout, func(ctx context.Context) error {
+		var err error
+		entry, err = dm.get(ctx, key)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (dm *DMap) get(ctx context.Context, key string) (storage.Entry, error) {
+	if entry, ok := dm.getGossipReplica(key); ok {
+		if err := dm.decryptEntry(entry); err != nil {
+			return nil, err
+		}
+		GetHits.Increase(1)
+		return entry, nil
+	}
+
 	hkey := partitions.HKey(dm.name, key)
 	member := dm.s.primary.PartitionByHKey(hkey).Owner()
 	// We are on the partition owner
 	if member.CompareByName(dm.s.rt.This()) {
-		entry, err := dm.getOnCluster(hkey, key)
+		entry, err := dm.getOnCluster(ctx, hkey, key)
 		if errors.Is(err, ErrKeyNotFound) {
 			GetMisses.Increase(1)
 		}
 		if err != nil {
 			return nil, err
 		}
+		if err = dm.decryptEntry(entry); err != nil {
+			return nil, err
+		}
 
 		// number of keys that have been requested and found present
 		GetHits.Increase(1)
 
+		if dm.accesses != nil {
+			dm.accesses.touch(key)
+		}
 		return entry, nil
 	}
 
@@ -0,0 +1,105 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrHashTagsDisabled is returned by GetByTag when the hash tags are not enabled.
+var ErrHashTagsDisabled = errors.New("hash tags are disabled")
+
+// localGetByTag returns the entries whose hash tag is tag in the given partition. The
+// fragment is read locked during the walk, so the result is a consistent snapshot of the
+// keys with the tag.
+func (dm *DMap) localGetByTag(partID uint64, tag string) (map[string]storage.Entry, error) {
+	result := make(map[string]storage.Entry)
+
+	part := dm.s.primary.PartitionByID(partID)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	now := time.Now().UnixNano() / 1000000
+	var decryptErr error
+	f.storage.Range(func(_ uint64, entry storage.Entry) bool {
+		if entry.TTL() != 0 && now >= entry.TTL() {
+			// Expired but not evicted yet.
+			return true
+		}
+		keyTag, ok := partitions.HashTag(entry.Key())
+		if !ok || keyTag != tag {
+			return true
+		}
+		if decryptErr = dm.decryptEntry(entry); decryptErr != nil {
+			return false
+		}
+		result[entry.Key()] = entry
+		return true
+	})
+	if decryptErr != nil {
+		return nil, decryptErr
+	}
+	return result, nil
+}
+
+// GetByTag returns all entries whose hash tag is tag, like "user:42" for the keys
+// "{user:42}:profile" and "{user:42}:session", mapped by their keys. The keys with the
+// same tag are stored on the same partition, so it reads a single partition on its owner
+// in one pass. It returns ErrHashTagsDisabled if the hash tags are not enabled.
+func (dm *DMap) GetByTag(ctx context.Context, tag string) (map[string]storage.Entry, error) {
+	partID, ok := partitions.HashTagPartID(dm.name, tag)
+	if !ok {
+		return nil, ErrHashTagsDisabled
+	}
+
+	member := dm.s.primary.PartitionByID(partID).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.localGetByTag(partID, tag)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewGetByTag(dm.name, tag).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	raws, err := cmd.Result()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+
+	result := make(map[string]storage.Entry, len(raws))
+	for _, raw := range raws {
+		entry := dm.engine.NewEntry()
+		entry.Decode([]byte(raw))
+		result[entry.Key()] = entry
+	}
+	return result, nil
+}
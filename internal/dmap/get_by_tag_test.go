@@ -0,0 +1,93 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_GetByTag(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c1 := testutil.NewConfig()
+	c1.HashTags = true
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.HashTags = true
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.Put(ctx, "{user:1}:"+testutil.ToKey(i), testutil.ToVal(i), nil))
+		require.NoError(t, dm1.Put(ctx, "{user:2}:"+testutil.ToKey(i), testutil.ToVal(i), nil))
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+
+	// All keys with the tag are read from the single owner of the tag's partition.
+	partID, ok := partitions.HashTagPartID("mydmap", "user:1")
+	require.True(t, ok)
+	owner := s1.primary.PartitionByID(partID).Owner()
+	for _, s := range []*Service{s1, s2} {
+		if !owner.CompareByName(s.rt.This()) {
+			continue
+		}
+		dm, err := s.getDMap("mydmap")
+		require.NoError(t, err)
+		local, err := dm.localGetByTag(partID, "user:1")
+		require.NoError(t, err)
+		require.Len(t, local, 10)
+	}
+
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+
+		entries, err := dm.GetByTag(ctx, "user:1")
+		require.NoError(t, err)
+		require.Len(t, entries, 10)
+		for i := 0; i < 10; i++ {
+			key := "{user:1}:" + testutil.ToKey(i)
+			entry, ok := entries[key]
+			require.True(t, ok)
+			require.Equal(t, key, entry.Key())
+			require.Equal(t, testutil.ToVal(i), entry.Value())
+		}
+
+		entries, err = dm.GetByTag(ctx, "user:3")
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	}
+}
+
+func TestDMap_GetByTag_ErrHashTagsDisabled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	_, err = dm.GetByTag(context.Background(), "user:1")
+	require.ErrorIs(t, err, ErrHashTagsDisabled)
+}
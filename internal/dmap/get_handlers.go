@@ -76,6 +76,14 @@ func (s *Service) getEntryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		return
 	}
 
+	if !getEntryCmd.Stored {
+		// The clients read from the replicas, they expect the plain value.
+		if err = dm.decryptEntry(nt); err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+	}
+
 	// We found it.
 	conn.WriteBulk(nt.Encode())
 }
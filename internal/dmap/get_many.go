@@ -0,0 +1,117 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+)
+
+// localGetMany returns the values of the keys stored on this member. The absent keys
+// are omitted.
+func (dm *DMap) localGetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	values := make(map[string][]byte)
+	for _, key := range keys {
+		entry, err := dm.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		values[key] = entry.Value()
+	}
+	return values, nil
+}
+
+// parseValues parses a flat list of key and value pairs.
+func parseValues(result []interface{}) (map[string][]byte, error) {
+	if len(result)%2 != 0 {
+		return nil, errors.New("invalid GetMany response")
+	}
+	values := make(map[string][]byte)
+	for i := 0; i < len(result); i += 2 {
+		key, ok := result[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", result[i])
+		}
+		value, ok := result[i+1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value: %v", result[i+1])
+		}
+		values[key] = []byte(value)
+	}
+	return values, nil
+}
+
+// GetMany returns the values of the keys. The absent keys are omitted. The keys are
+// grouped by their partition owners, every owner is queried once and concurrently,
+// so fetching many keys costs a round trip per owner instead of one per key.
+func (dm *DMap) GetMany(ctx context.Context, keys []string) (map[string][]byte, error) {
+	owners := make(map[string]discovery.Member)
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		owner := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+		owners[owner.String()] = owner
+		groups[owner.String()] = append(groups[owner.String()], key)
+	}
+
+	result := make(map[string][]byte)
+	var mtx sync.Mutex
+
+	var g errgroup.Group
+	for name, items := range groups {
+		owner, group := owners[name], items
+		g.Go(func() error {
+			var values map[string][]byte
+			if owner.CompareByID(dm.s.rt.This()) {
+				var err error
+				values, err = dm.localGetMany(ctx, group)
+				if err != nil {
+					return err
+				}
+			} else {
+				cmd := protocol.NewGetMany(dm.name, group).SetLocal().Command(ctx)
+				rc := dm.s.client.Get(owner.String())
+				if err := rc.Process(ctx, cmd); err != nil {
+					return protocol.ConvertError(err)
+				}
+				var err error
+				values, err = parseValues(cmd.Val())
+				if err != nil {
+					return err
+				}
+			}
+
+			mtx.Lock()
+			for key, value := range values {
+				result[key] = value
+			}
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) getManyCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	getManyCmd, err := protocol.ParseGetManyCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(getManyCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	var values map[string][]byte
+	if getManyCmd.Local {
+		values, err = dm.localGetMany(s.ctx, getManyCmd.Keys)
+	} else {
+		values, err = dm.GetMany(s.ctx, getManyCmd.Keys)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(values) * 2)
+	for key, value := range values {
+		conn.WriteBulkString(key)
+		conn.WriteBulk(value)
+	}
+}
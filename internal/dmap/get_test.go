@@ -16,11 +16,16 @@ package dmap
 
 import (
 	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/cluster/routingtable"
 	"github.com/buraksezer/olric/internal/testcluster"
 	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
-	"testing"
 )
 
 func TestDMap_Get_Standalone(t *testing.T) {
@@ -232,3 +237,74 @@ func TestDMap_Get_ReadRepair(t *testing.T) {
 		require.Equal(t, testutil.ToVal(i), gr.Value())
 	}
 }
+
+func TestDMap_Get_ReadRepair_Scope(t *testing.T) {
+	for _, scope := range []int{config.FullReadRepair, config.MetadataReadRepair} {
+		scope := scope
+		t.Run(fmt.Sprintf("scope %d", scope), func(t *testing.T) {
+			cluster := testcluster.New(NewService)
+			newConfig := func() *config.Config {
+				c := testutil.NewConfig()
+				c.ReadRepair = true
+				c.ReadRepairScope = scope
+				c.ReplicaCount = 2
+				c.ReadQuorum = 2
+				return c
+			}
+			s1 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+			s2 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+			defer cluster.Shutdown()
+
+			ctx := context.Background()
+			dm1, err := s1.NewDMap("mydmap")
+			require.NoError(t, err)
+			require.NoError(t, dm1.Put(ctx, "mykey", "myvalue", nil))
+
+			hkey := partitions.HKey("mydmap", "mykey")
+			owner, replica := s1, s2
+			if !s1.primary.PartitionByHKey(hkey).Owner().CompareByName(s1.rt.This()) {
+				owner, replica = s2, s1
+			}
+			ownerDM, err := owner.NewDMap("mydmap")
+			require.NoError(t, err)
+			replicaDM, err := replica.NewDMap("mydmap")
+			require.NoError(t, err)
+
+			// Change only the TTL on the owner, the replica has a stale TTL and the same value.
+			f, err := ownerDM.loadFragment(owner.primary.PartitionByHKey(hkey))
+			require.NoError(t, err)
+			ttl := time.Now().Add(time.Hour).UnixMilli()
+			timestamp := time.Now().UnixNano()
+			f.Lock()
+			nt := f.storage.NewEntry()
+			nt.SetKey("mykey")
+			nt.SetTTL(ttl)
+			nt.SetTimestamp(timestamp)
+			require.NoError(t, f.storage.UpdateTTL(hkey, nt))
+			f.Unlock()
+
+			rf, err := replicaDM.loadFragment(replica.backup.PartitionByHKey(hkey))
+			require.NoError(t, err)
+			before := rf.Stats()
+
+			_, err = ownerDM.Get(ctx, "mykey")
+			require.NoError(t, err)
+
+			rf.RLock()
+			repaired, err := rf.storage.Get(hkey)
+			rf.RUnlock()
+			require.NoError(t, err)
+			require.Equal(t, ttl, repaired.TTL())
+			require.Equal(t, timestamp, repaired.Timestamp())
+
+			after := rf.Stats()
+			if scope == config.MetadataReadRepair {
+				// The TTL is updated in place, the value is not transferred.
+				require.Equal(t, before.Inuse, after.Inuse)
+				require.Equal(t, before.Garbage, after.Garbage)
+			} else {
+				require.Greater(t, after.Inuse, before.Inuse)
+			}
+		})
+	}
+}
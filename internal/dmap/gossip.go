@@ -0,0 +1,205 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// gossipMessage is a write on a DMap with gossip replication. It's piggybacked on the
+// gossip messages of memberlist by the partition owner.
+type gossipMessage struct {
+	DMap      string
+	Key       string
+	Timestamp int64
+	// Entry is the encoded entry, it's nil if the key is deleted or the value is too large
+	// to be replicated by the gossip protocol.
+	Entry []byte
+}
+
+// gossipTombstoneTTL is the time to keep a deleted key in the replicas. memberlist sends a
+// gossip message for a limited number of gossip rounds, so a delayed older write on the key
+// arrives long before the tombstone is reclaimed.
+const gossipTombstoneTTL = 5 * time.Minute
+
+// gossipReplica is the last write on a key received by the gossip protocol.
+type gossipReplica struct {
+	timestamp int64
+	entry     []byte
+	// deletedAt is the local time, in nanoseconds, when the tombstone of a deleted key is
+	// stored. It's zero if the key is not deleted.
+	deletedAt int64
+}
+
+// gossipReplicas keeps the replicas of the DMaps with gossip replication on this member.
+type gossipReplicas struct {
+	mtx sync.RWMutex
+	m   map[string]map[string]gossipReplica
+}
+
+func newGossipReplicas() *gossipReplicas {
+	return &gossipReplicas{
+		m: make(map[string]map[string]gossipReplica),
+	}
+}
+
+// apply stores the write if it's newer than the stored one, the last write wins. It returns
+// false if the write is already known or outdated.
+func (g *gossipReplicas) apply(msg *gossipMessage) bool {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	replicas, ok := g.m[msg.DMap]
+	if !ok {
+		replicas = make(map[string]gossipReplica)
+		g.m[msg.DMap] = replicas
+	}
+	if current, ok := replicas[msg.Key]; ok && current.timestamp >= msg.Timestamp {
+		return false
+	}
+	// A deleted key is kept with a nil entry, so a delayed older write cannot restore it.
+	replica := gossipReplica{
+		timestamp: msg.Timestamp,
+		entry:     msg.Entry,
+	}
+	if msg.Entry == nil {
+		replica.deletedAt = time.Now().UnixNano()
+	}
+	replicas[msg.Key] = replica
+	return true
+}
+
+// reclaimTombstones removes the tombstones of the keys deleted before the given time.
+func (g *gossipReplicas) reclaimTombstones(before int64) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	for dmap, replicas := range g.m {
+		for key, replica := range replicas {
+			if replica.entry == nil && replica.deletedAt < before {
+				delete(replicas, key)
+			}
+		}
+		if len(replicas) == 0 {
+			delete(g.m, dmap)
+		}
+	}
+}
+
+func (g *gossipReplicas) get(dmap, key string) ([]byte, bool) {
+	g.mtx.RLock()
+	defer g.mtx.RUnlock()
+
+	replica, ok := g.m[dmap][key]
+	if !ok || replica.entry == nil {
+		return nil, false
+	}
+	return replica.entry, true
+}
+
+func (g *gossipReplicas) drop(dmap string) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	delete(g.m, dmap)
+}
+
+// gossipName is the name of the gossip messages of a key. A newer write on the key replaces
+// the queued one.
+func gossipName(dmap, key string) string {
+	return strconv.FormatUint(partitions.HKey(dmap, key), 10)
+}
+
+// applyGossip is called with the gossip messages sent by the other members. A new write is
+// relayed to the other members, since memberlist sends a queued message to a limited number
+// of members. The known writes are not relayed again, so the relaying stops.
+func (s *Service) applyGossip(data []byte) {
+	msg := &gossipMessage{}
+	if err := msgpack.Unmarshal(data, msg); err != nil {
+		s.log.V(3).Printf("[ERROR] Failed to decode the gossip message: %v", err)
+		return
+	}
+	if s.gossip.apply(msg) {
+		s.rt.Discovery().Broadcast(gossipName(msg.DMap, msg.Key), data)
+	}
+}
+
+func (dm *DMap) broadcastGossip(msg *gossipMessage) {
+	data, err := msgpack.Marshal(msg)
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to encode the gossip message for key: %s on DMap: %s: %v", msg.Key, dm.name, err)
+		return
+	}
+	dm.s.gossip.apply(msg)
+	dm.s.rt.Discovery().Broadcast(gossipName(dm.name, msg.Key), data)
+}
+
+// gossipPut replicates the entry to all members by the gossip protocol, if the DMap has
+// gossip replication. The replicas of a value larger than MaxGossipValueSize are invalidated,
+// so the reads fall back to the partition owner.
+func (dm *DMap) gossipPut(nt storage.Entry) {
+	if !dm.config.gossipReplication {
+		return
+	}
+
+	msg := &gossipMessage{
+		DMap:      dm.name,
+		Key:       nt.Key(),
+		Timestamp: nt.Timestamp(),
+	}
+	if len(nt.Value()) <= dm.s.config.DMaps.MaxGossipValueSize {
+		msg.Entry = nt.Encode()
+	}
+	dm.broadcastGossip(msg)
+}
+
+// gossipDelete invalidates the replicas of the key, if the DMap has gossip replication.
+func (dm *DMap) gossipDelete(key string) {
+	if !dm.config.gossipReplication {
+		return
+	}
+
+	dm.broadcastGossip(&gossipMessage{
+		DMap:      dm.name,
+		Key:       key,
+		Timestamp: time.Now().UnixNano(),
+	})
+}
+
+// getGossipReplica returns the entry from the local replica, if the DMap has gossip
+// replication and the key is replicated.
+func (dm *DMap) getGossipReplica(key string) (storage.Entry, bool) {
+	if !dm.config.gossipReplication {
+		return nil, false
+	}
+
+	raw, ok := dm.s.gossip.get(dm.name, key)
+	if !ok {
+		return nil, false
+	}
+	entry := dm.engine.NewEntry()
+	entry.Decode(raw)
+	if entry.TTL() != 0 && time.Now().UnixNano()/1000000 >= entry.TTL() {
+		// The owner deletes the expired key later.
+		return nil, false
+	}
+	return entry, true
+}
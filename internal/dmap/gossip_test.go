@@ -0,0 +1,131 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_GossipReplication(t *testing.T) {
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.DMaps.Custom = map[string]config.DMap{
+			"flags": {GossipReplication: true},
+		}
+		return c
+	}
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	s2 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	s3 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	defer cluster.Shutdown()
+	services := []*Service{s1, s2, s3}
+
+	replicated := func(key string, value []byte) func() bool {
+		return func() bool {
+			for _, s := range services {
+				dm, err := s.NewDMap("flags")
+				if err != nil {
+					return false
+				}
+				entry, ok := dm.getGossipReplica(key)
+				if value == nil && ok {
+					return false
+				}
+				if value != nil && (!ok || string(entry.Value()) != string(value)) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("flags")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), "on", nil))
+	}
+	for i := 0; i < 10; i++ {
+		require.Eventually(t, replicated(testutil.ToKey(i), []byte("on")), 10*time.Second, 50*time.Millisecond)
+	}
+
+	t.Run("Read from the replica", func(t *testing.T) {
+		for _, s := range services {
+			dm, err := s.NewDMap("flags")
+			require.NoError(t, err)
+			entry, err := dm.Get(ctx, testutil.ToKey(0))
+			require.NoError(t, err)
+			require.Equal(t, "on", string(entry.Value()))
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := dm1.Delete(ctx, testutil.ToKey(1))
+		require.NoError(t, err)
+		require.Eventually(t, replicated(testutil.ToKey(1), nil), 10*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Large value", func(t *testing.T) {
+		value := strings.Repeat("a", s1.config.DMaps.MaxGossipValueSize+1)
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(2), value, nil))
+		require.Eventually(t, replicated(testutil.ToKey(2), nil), 10*time.Second, 50*time.Millisecond)
+
+		dm3, err := s3.NewDMap("flags")
+		require.NoError(t, err)
+		entry, err := dm3.Get(ctx, testutil.ToKey(2))
+		require.NoError(t, err)
+		require.Equal(t, value, string(entry.Value()))
+	})
+
+	t.Run("Not gossip-replicated", func(t *testing.T) {
+		dm, err := s1.NewDMap("mydmap")
+		require.NoError(t, err)
+		require.NoError(t, dm.Put(ctx, "mykey", "on", nil))
+		_, ok := s1.gossip.get("mydmap", "mykey")
+		require.False(t, ok)
+	})
+}
+
+func TestDMap_GossipReplicas_ReclaimTombstones(t *testing.T) {
+	g := newGossipReplicas()
+	require.True(t, g.apply(&gossipMessage{DMap: "flags", Key: "deleted", Timestamp: 2}))
+	require.True(t, g.apply(&gossipMessage{DMap: "flags", Key: "live", Timestamp: 2, Entry: []byte("on")}))
+
+	// The tombstone rejects a delayed older write.
+	require.False(t, g.apply(&gossipMessage{DMap: "flags", Key: "deleted", Timestamp: 1, Entry: []byte("on")}))
+
+	g.reclaimTombstones(time.Now().Add(-time.Minute).UnixNano())
+	require.Contains(t, g.m["flags"], "deleted")
+
+	g.reclaimTombstones(time.Now().UnixNano())
+	require.NotContains(t, g.m["flags"], "deleted")
+	entry, ok := g.get("flags", "live")
+	require.True(t, ok)
+	require.Equal(t, []byte("on"), entry)
+
+	require.True(t, g.apply(&gossipMessage{DMap: "flags", Key: "live", Timestamp: 3}))
+	g.reclaimTombstones(time.Now().UnixNano())
+	require.Empty(t, g.m)
+}
@@ -25,17 +25,69 @@ func (s *Service) RegisterHandlers() {
 	s.server.ServeMux().HandleFunc(protocol.DMap.DelEntry, s.delEntryCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.GetEntry, s.getEntryCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.PutEntry, s.putEntryCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.UpdateEntryTTL, s.updateEntryTTLCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Expire, s.expireCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.PExpire, s.pexpireCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Persist, s.persistCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.TTL, s.ttlCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.PTTL, s.pttlCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Destroy, s.destroyCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Scan, s.scanCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Incr, s.incrCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Decr, s.decrCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.GetPut, s.getPutCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.SetNXGet, s.setNXGetCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.DecrAndDeleteAtZero, s.decrAndDeleteAtZeroCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Eval, s.evalCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.PutAllIfNoneExist, s.putAllIfNoneExistCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.EntryAgeRange, s.entryAgeRangeCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.ExpiringSoon, s.expiringSoonCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.ValueHashes, s.valueHashesCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Diff, s.diffCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.ClaimNext, s.claimNextCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.SyncReplica, s.syncReplicaCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.GetTTLMany, s.getTTLManyCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.GetMany, s.getManyCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.PutMany, s.putManyCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Exists, s.existsCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.ReserveSequence, s.reserveSequenceCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Changes, s.changesCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Rotate, s.rotateCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.DelIfExpiredBefore, s.delIfExpiredBeforeCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HotKeys, s.hotKeysCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HIncrBy, s.hincrByCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HMSet, s.hmsetCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.HMGet, s.hmgetCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.AcquirePermit, s.acquirePermitCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.ReleasePermit, s.releasePermitCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Register, s.registerCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Heartbeat, s.heartbeatCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Discover, s.discoverCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Campaign, s.campaignCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Resign, s.resignCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.PutWithVersion, s.putWithVersionCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.LPushCapped, s.lpushCappedCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.LRange, s.lrangeCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.CapTTL, s.capTTLCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.SetBit, s.setBitCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.GetBit, s.getBitCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.GetAndReset, s.getAndResetCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.GetDel, s.getDelCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Append, s.appendCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.PutIfField, s.putIfFieldCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.Merge, s.mergeCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.SetStandby, s.setStandbyCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.PromoteStandby, s.promoteStandbyCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.GetByTag, s.getByTagCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.IncrByFloat, s.incrByFloatCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.MemoryUsage, s.memoryUsageCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Lock, s.lockCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.Unlock, s.unlockCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.LockLease, s.lockLeaseCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.DMap.PLockLease, s.plockLeaseCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.DMap.LockLeasePut, s.lockLeasePutCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Generic.CompactTables, s.compactTablesCommandHandler)
 	s.server.ServeMux().HandleFunc(protocol.Internal.MoveFragment, s.moveFragmentCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Internal.ReplaceFragment, s.replaceFragmentCommandHandler)
+	s.server.ServeMux().HandleFunc(protocol.Internal.DMapNames, s.dmapNamesCommandHandler)
 }
@@ -0,0 +1,240 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrNotAHash is returned when a hash operation is called on a key whose value is not a hash.
+var ErrNotAHash = errors.New("value is not a hash")
+
+// decodeHash decodes a hash value. A hash is stored as a msgpack encoded map
+// of field names to values.
+func decodeHash(raw []byte) (map[string]string, error) {
+	fields := make(map[string]string)
+	if err := msgpack.Unmarshal(raw, &fields); err != nil {
+		return nil, ErrNotAHash
+	}
+	return fields, nil
+}
+
+func encodeHash(fields map[string]string) ([]byte, error) {
+	return msgpack.Marshal(fields)
+}
+
+// loadHash returns the fields and the TTL of the hash. It returns an empty hash
+// if the key does not exist.
+func (dm *DMap) loadHash(e *env) (map[string]string, int64, error) {
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return make(map[string]string), 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	fields, err := decodeHash(entry.Value())
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", err, e.key)
+	}
+	return fields, entry.TTL(), nil
+}
+
+// storeHash writes the fields back to the key and preserves the TTL.
+func (dm *DMap) storeHash(e *env, fields map[string]string, ttl int64) error {
+	value, err := encodeHash(fields)
+	if err != nil {
+		return err
+	}
+	e.value = value
+	if ttl != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Until(time.UnixMilli(ttl))
+	}
+	return dm.put(e)
+}
+
+// hincrBy runs on the partition owner of the key.
+func (dm *DMap) hincrBy(e *env, field string, delta int) (int, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	fields, ttl, err := dm.loadHash(e)
+	if err != nil {
+		return 0, err
+	}
+
+	var current int
+	if raw, ok := fields[field]; ok {
+		current, err = strconv.Atoi(raw)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s.%s", ErrNotAnInteger, e.key, field)
+		}
+	}
+
+	updated := current + delta
+	fields[field] = strconv.Itoa(updated)
+	if err = dm.storeHash(e, fields, ttl); err != nil {
+		return 0, err
+	}
+	return updated, nil
+}
+
+// HIncrBy atomically increments the field of the hash stored at the key by delta and
+// returns the new value of the field. The hash and the field are created if they don't
+// exist. It returns ErrNotAnInteger if the field is not an integer and ErrNotAHash if
+// the value is not a hash. The operation runs on the partition owner of the key.
+func (dm *DMap) HIncrBy(ctx context.Context, key, field string, delta int) (int, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.hincrBy(e, field, delta)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewHIncrBy(dm.name, key, field, delta).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	res, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(res), nil
+}
+
+// hmset runs on the partition owner of the key.
+func (dm *DMap) hmset(e *env, fields map[string][]byte) error {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	current, ttl, err := dm.loadHash(e)
+	if err != nil {
+		return err
+	}
+	for field, value := range fields {
+		current[field] = string(value)
+	}
+	return dm.storeHash(e, current, ttl)
+}
+
+// hmget runs on the partition owner of the key.
+func (dm *DMap) hmget(e *env, fields []string) (map[string][]byte, error) {
+	current, _, err := dm.loadHash(e)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]byte)
+	for _, field := range fields {
+		if value, ok := current[field]; ok {
+			result[field] = []byte(value)
+		}
+	}
+	return result, nil
+}
+
+// parseHash parses a flat list of field and value pairs.
+func parseHash(values []interface{}) (map[string][]byte, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("invalid hash response")
+	}
+	result := make(map[string][]byte)
+	for i := 0; i < len(values); i += 2 {
+		field, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid field: %v", values[i])
+		}
+		value, ok := values[i+1].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid value: %v", values[i+1])
+		}
+		result[field] = []byte(value)
+	}
+	return result, nil
+}
+
+// HMSet sets the fields of the hash stored at the key. The hash is created if it
+// doesn't exist, the other fields and the TTL are preserved. The hash is read and
+// written once on the partition owner of the key. It returns ErrNotAHash if the
+// value is not a hash.
+func (dm *DMap) HMSet(ctx context.Context, key string, fields map[string][]byte) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: no fields", protocol.ErrInvalidArgument)
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.hmset(e, fields)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewHMSet(dm.name, key, fields).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// HMGet returns the fields of the hash stored at the key. The absent fields are
+// omitted, it returns an empty map if the key doesn't exist. The hash is read once
+// on the partition owner of the key, only the requested fields are transferred. It
+// returns ErrNotAHash if the value is not a hash.
+func (dm *DMap) HMGet(ctx context.Context, key string, fields ...string) (map[string][]byte, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.hmget(e, fields)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewHMGet(dm.name, key, fields...).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	return parseHash(cmd.Val())
+}
@@ -0,0 +1,85 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) hincrByCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	hincrByCmd, err := protocol.ParseHIncrByCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(hincrByCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	value, err := dm.HIncrBy(s.ctx, hincrByCmd.Key, hincrByCmd.Field, hincrByCmd.Delta)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(value)
+}
+
+func (s *Service) hmsetCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	hmsetCmd, err := protocol.ParseHMSetCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(hmsetCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	err = dm.HMSet(s.ctx, hmsetCmd.Key, hmsetCmd.Fields)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (s *Service) hmgetCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	hmgetCmd, err := protocol.ParseHMGetCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(hmgetCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	fields, err := dm.HMGet(s.ctx, hmgetCmd.Key, hmgetCmd.Fields...)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(fields) * 2)
+	for field, value := range fields {
+		conn.WriteBulkString(field)
+		conn.WriteBulk(value)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_HIncrBy(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Increment the same field on both members concurrently, the non-owner redirects the request.
+	var errGr errgroup.Group
+	for i := 0; i < 100; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			_, err := dm.HIncrBy(ctx, "mykey", "counter", 1)
+			return err
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	value, err := dm1.HIncrBy(ctx, "mykey", "counter", -10)
+	require.NoError(t, err)
+	require.Equal(t, 90, value)
+
+	value, err = dm2.HIncrBy(ctx, "mykey", "other", 5)
+	require.NoError(t, err)
+	require.Equal(t, 5, value)
+
+	entry, err := dm1.Get(ctx, "mykey")
+	require.NoError(t, err)
+	fields, err := decodeHash(entry.Value())
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"counter": "90", "other": "5"}, fields)
+
+	t.Run("Non-integer field", func(t *testing.T) {
+		raw, err := encodeHash(map[string]string{"name": "foo"})
+		require.NoError(t, err)
+		require.NoError(t, dm1.Put(ctx, "myhash", raw, nil))
+
+		_, err = dm2.HIncrBy(ctx, "myhash", "name", 1)
+		require.ErrorIs(t, err, ErrNotAnInteger)
+	})
+
+	t.Run("Not a hash", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "mystring", "foo", nil))
+
+		_, err = dm2.HIncrBy(ctx, "mystring", "counter", 1)
+		require.ErrorIs(t, err, ErrNotAHash)
+	})
+}
+
+func TestDMap_hincrByCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The commands are sent to the member that doesn't own the key, the other
+	// increments run on the owner.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mykey")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	var errGr errgroup.Group
+	for i := 0; i < 1000; i++ {
+		i := i
+		errGr.Go(func() error {
+			if i%2 == 0 {
+				_, err := owner.HIncrBy(ctx, "mykey", "counter", 1)
+				return err
+			}
+			cmd := protocol.NewHIncrBy("mydmap", "mykey", "counter", 1).Command(ctx)
+			rc := other.client.Get(other.rt.This().String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return err
+			}
+			return cmd.Err()
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	fields, err := owner.HMGet(ctx, "mykey", "counter")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]byte{"counter": []byte("1000")}, fields)
+}
+
+func TestDMap_HMSet_HMGet(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		err = dm1.HMSet(ctx, key, map[string][]byte{
+			"name":  []byte("foo"),
+			"count": []byte("1"),
+		})
+		require.NoError(t, err)
+		err = dm2.HMSet(ctx, key, map[string][]byte{
+			"name": []byte("bar"),
+			"role": []byte("admin"),
+		})
+		require.NoError(t, err)
+
+		// The fields written by HMSet are valid for HIncrBy.
+		value, err := dm2.HIncrBy(ctx, key, "count", 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, value)
+
+		fields, err := dm1.HMGet(ctx, key, "name", "count", "role", "absent")
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{
+			"name":  []byte("bar"),
+			"count": []byte("2"),
+			"role":  []byte("admin"),
+		}, fields)
+
+		fields, err = dm2.HMGet(ctx, key, "name")
+		require.NoError(t, err)
+		require.Equal(t, map[string][]byte{"name": []byte("bar")}, fields)
+	}
+
+	t.Run("Absent key", func(t *testing.T) {
+		fields, err := dm2.HMGet(ctx, "absent", "name")
+		require.NoError(t, err)
+		require.Empty(t, fields)
+	})
+
+	t.Run("No fields", func(t *testing.T) {
+		err := dm1.HMSet(ctx, "mykey", nil)
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+	})
+
+	t.Run("Not a hash", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "mystring", "foo", nil))
+
+		err := dm2.HMSet(ctx, "mystring", map[string][]byte{"name": []byte("foo")})
+		require.ErrorIs(t, err, ErrNotAHash)
+
+		_, err = dm2.HMGet(ctx, "mystring", "name")
+		require.ErrorIs(t, err, ErrNotAHash)
+	})
+}
+
+func TestDMap_hmsetCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The commands are sent to the member that doesn't own the key, the other
+	// writes run on the owner. Every field must survive.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mykey")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	const numFields = 1000
+	var errGr errgroup.Group
+	for i := 0; i < numFields; i++ {
+		field := testutil.ToKey(i)
+		if i%2 == 0 {
+			errGr.Go(func() error {
+				return owner.HMSet(ctx, "mykey", map[string][]byte{field: []byte("1")})
+			})
+			continue
+		}
+		errGr.Go(func() error {
+			cmd := protocol.NewHMSet("mydmap", "mykey", map[string][]byte{field: []byte("1")}).Command(ctx)
+			rc := other.client.Get(other.rt.This().String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return err
+			}
+			return cmd.Err()
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	fields := make([]string, 0, numFields)
+	for i := 0; i < numFields; i++ {
+		fields = append(fields, testutil.ToKey(i))
+	}
+	cmd := protocol.NewHMGet("mydmap", "mykey", fields...).Command(ctx)
+	rc := other.client.Get(other.rt.This().String())
+	require.NoError(t, rc.Process(ctx, cmd))
+	result, err := parseHash(cmd.Val())
+	require.NoError(t, err)
+	require.Equal(t, numFields, len(result))
+}
@@ -0,0 +1,254 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// maxTrackedKeys is the maximum number of keys that an access counter tracks. The keys
+// whose counters decayed below 1 are removed to make room for the new keys.
+const maxTrackedKeys = 1 << 16
+
+// ErrAccessCounterDisabled is returned by HotKeys when the access counter of the DMap is disabled.
+var ErrAccessCounterDisabled = errors.New("access counter is disabled")
+
+// KeyAccess is a key with its approximate access count.
+type KeyAccess struct {
+	Key   string
+	Count int64
+}
+
+type keyCounter struct {
+	count     float64
+	updatedAt int64
+}
+
+// accessCounter keeps the approximate access counts of the keys on the partition owner.
+// The counts decay exponentially, they halve in every halfLife.
+type accessCounter struct {
+	mtx       sync.Mutex
+	halfLife  time.Duration
+	counters  map[string]*keyCounter
+	lastPrune int64
+}
+
+func newAccessCounter(halfLife time.Duration) *accessCounter {
+	return &accessCounter{
+		halfLife: halfLife,
+		counters: make(map[string]*keyCounter),
+	}
+}
+
+func (a *accessCounter) decayed(c *keyCounter, now int64) float64 {
+	elapsed := float64(now - c.updatedAt)
+	return c.count * math.Exp2(-elapsed/float64(a.halfLife.Nanoseconds()))
+}
+
+// prune removes the counters that decayed below 1. It runs at most once per second.
+func (a *accessCounter) prune(now int64) {
+	if now-a.lastPrune < time.Second.Nanoseconds() {
+		return
+	}
+	a.lastPrune = now
+	for key, c := range a.counters {
+		if a.decayed(c, now) < 1 {
+			delete(a.counters, key)
+		}
+	}
+}
+
+func (a *accessCounter) touch(key string) {
+	now := time.Now().UnixNano()
+
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	c, ok := a.counters[key]
+	if !ok {
+		if len(a.counters) >= maxTrackedKeys {
+			a.prune(now)
+			if len(a.counters) >= maxTrackedKeys {
+				// All the tracked keys are still warm.
+				return
+			}
+		}
+		c = &keyCounter{}
+		a.counters[key] = c
+	}
+	c.count = a.decayed(c, now) + 1
+	c.updatedAt = now
+}
+
+// top returns the n keys with the highest decayed counts.
+func (a *accessCounter) top(n int) []KeyAccess {
+	now := time.Now().UnixNano()
+
+	a.mtx.Lock()
+	result := make([]KeyAccess, 0, len(a.counters))
+	for key, c := range a.counters {
+		count := int64(math.Round(a.decayed(c, now)))
+		if count == 0 {
+			continue
+		}
+		result = append(result, KeyAccess{Key: key, Count: count})
+	}
+	a.mtx.Unlock()
+
+	return sortAndLimitKeyAccesses(result, n)
+}
+
+// sortAndLimitKeyAccesses sorts the keys by their access counts, the most accessed key
+// comes first, and keeps the first n keys.
+func sortAndLimitKeyAccesses(keys []KeyAccess, n int) []KeyAccess {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count == keys[j].Count {
+			return keys[i].Key < keys[j].Key
+		}
+		return keys[i].Count > keys[j].Count
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+	return keys
+}
+
+// localHotKeys returns the n most accessed keys of the DMap on this member.
+func (s *Service) localHotKeys(name string, n int) ([]KeyAccess, error) {
+	dm, err := s.getDMap(name)
+	if errors.Is(err, ErrDMapNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if dm.accesses == nil {
+		return nil, ErrAccessCounterDisabled
+	}
+	return dm.accesses.top(n), nil
+}
+
+func (dm *DMap) hotKeysOnCluster(ctx context.Context, n int) ([]KeyAccess, error) {
+	if dm.accesses == nil {
+		return nil, ErrAccessCounterDisabled
+	}
+
+	counts := make(map[string]int64)
+	var mtx sync.Mutex
+
+	num := int64(runtime.NumCPU())
+	sem := semaphore.NewWeighted(num)
+
+	var members []discovery.Member
+	m := dm.s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		members = append(members, member)
+		return true
+	})
+	m.RUnlock()
+
+	var g errgroup.Group
+	for _, item := range members {
+		member := item
+		g.Go(func() error {
+			if err := sem.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer sem.Release(1)
+
+			var keys []KeyAccess
+			if member.CompareByID(dm.s.rt.This()) {
+				var err error
+				keys, err = dm.s.localHotKeys(dm.name, n)
+				if err != nil {
+					return err
+				}
+			} else {
+				cmd := protocol.NewHotKeys(dm.name, int64(n)).SetLocal().Command(ctx)
+				rc := dm.s.client.Get(member.String())
+				if err := rc.Process(ctx, cmd); err != nil {
+					return protocol.ConvertError(err)
+				}
+				var err error
+				keys, err = parseHotKeys(cmd.Val())
+				if err != nil {
+					return err
+				}
+			}
+
+			mtx.Lock()
+			for _, k := range keys {
+				// A key may be counted on more than one member after a partition move.
+				counts[k.Key] += k.Count
+			}
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	result := make([]KeyAccess, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, KeyAccess{Key: key, Count: count})
+	}
+	return sortAndLimitKeyAccesses(result, n), nil
+}
+
+// parseHotKeys parses a flat list of key and access count pairs.
+func parseHotKeys(values []interface{}) ([]KeyAccess, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("invalid hot keys response")
+	}
+	var keys []KeyAccess
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", values[i])
+		}
+		count, ok := values[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid access count: %v", values[i+1])
+		}
+		keys = append(keys, KeyAccess{Key: key, Count: count})
+	}
+	return keys, nil
+}
+
+// HotKeys returns the n most accessed keys of the DMap with their approximate access
+// counts, the most accessed key first. The reads are counted on the partition owners and
+// the counts decay with config.DMaps.AccessCounterHalfLife, so they reflect the recent
+// accesses. Every member returns its top n keys and the results are merged. It returns
+// ErrAccessCounterDisabled if the access counter of the DMap is disabled.
+func (dm *DMap) HotKeys(ctx context.Context, n int) ([]KeyAccess, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: non-positive count: %d", protocol.ErrInvalidArgument, n)
+	}
+	return dm.hotKeysOnCluster(ctx, n)
+}
@@ -0,0 +1,53 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) hotKeysCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	hotKeysCmd, err := protocol.ParseHotKeysCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	n := int(hotKeysCmd.Count)
+
+	var keys []KeyAccess
+	if hotKeysCmd.Local {
+		keys, err = s.localHotKeys(hotKeysCmd.DMap, n)
+	} else {
+		var dm *DMap
+		dm, err = s.getOrCreateDMap(hotKeysCmd.DMap)
+		if err != nil {
+			protocol.WriteError(conn, err)
+			return
+		}
+		keys, err = dm.hotKeysOnCluster(s.ctx, n)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(keys) * 2)
+	for _, k := range keys {
+		conn.WriteBulkString(k.Key)
+		conn.WriteInt64(k.Count)
+	}
+}
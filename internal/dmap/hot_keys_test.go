@@ -0,0 +1,99 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_HotKeys(t *testing.T) {
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.DMaps.Custom = map[string]config.DMap{
+			"mydmap": {AccessCounter: true},
+		}
+		return c
+	}
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	s2 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+
+	// Read every key once and the hot keys many times, from both members.
+	hotKeys := []string{testutil.ToKey(7), testutil.ToKey(42), testutil.ToKey(93)}
+	for i := 0; i < 100; i++ {
+		_, err = dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+	}
+	for i, key := range hotKeys {
+		for j := 0; j < 50*(i+1); j++ {
+			dm := dm1
+			if j%2 == 0 {
+				dm = dm2
+			}
+			_, err = dm.Get(ctx, key)
+			require.NoError(t, err)
+		}
+	}
+
+	keys, err := dm2.HotKeys(ctx, 3)
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+	// The most accessed key comes first.
+	for i, key := range []string{hotKeys[2], hotKeys[1], hotKeys[0]} {
+		require.Equal(t, key, keys[i].Key)
+		require.Greater(t, keys[i].Count, int64(1))
+	}
+
+	t.Run("Access counter is disabled", func(t *testing.T) {
+		dm, err := s1.NewDMap("other")
+		require.NoError(t, err)
+		_, err = dm.HotKeys(ctx, 3)
+		require.ErrorIs(t, err, ErrAccessCounterDisabled)
+	})
+}
+
+func TestDMap_AccessCounter_Decay(t *testing.T) {
+	a := newAccessCounter(time.Minute)
+	for i := 0; i < 100; i++ {
+		a.touch("old")
+	}
+	for i := 0; i < 60; i++ {
+		a.touch("new")
+	}
+
+	// Move the old accesses one half-life back.
+	a.counters["old"].updatedAt -= time.Minute.Nanoseconds()
+
+	keys := a.top(2)
+	require.Equal(t, []KeyAccess{{Key: "new", Count: 60}, {Key: "old", Count: 50}}, keys)
+}
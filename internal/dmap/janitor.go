@@ -89,6 +89,7 @@ func (s *Service) janitorWorker() {
 		select {
 		case <-timer.C:
 			s.deleteEmptyFragments()
+			s.gossip.reclaimTombstones(time.Now().Add(-gossipTombstoneTTL).UnixNano())
 		case <-s.ctx.Done():
 			return
 		}
@@ -0,0 +1,31 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeySchema is returned when a key doesn't match the key schema of the DMap.
+var ErrKeySchema = errors.New("key doesn't match the schema")
+
+// checkKeySchema returns ErrKeySchema if the DMap has a key schema and the key doesn't match it.
+func (c *dmapConfig) checkKeySchema(key string) error {
+	if c.keySchema == nil || c.keySchema.MatchString(key) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s: %s", ErrKeySchema, c.keySchema, key)
+}
@@ -0,0 +1,72 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_KeySchema(t *testing.T) {
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.DMaps.Custom = map[string]config.DMap{
+			"mydmap": {
+				KeySchema: "^[a-z]+:[0-9]+:[a-z]+$",
+			},
+			"invalid": {
+				KeySchema: "[a-z",
+			},
+		}
+		return c
+	}
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	s2 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+
+		// The keys are distributed to both members, the owner validates them.
+		for i := 0; i < 10; i++ {
+			require.NoError(t, dm.Put(ctx, "user:"+testutil.ToKey(i)+":name", testutil.ToVal(i), nil))
+
+			err = dm.Put(ctx, "user:"+testutil.ToKey(i), testutil.ToVal(i), nil)
+			require.ErrorIs(t, err, ErrKeySchema)
+		}
+
+		err = dm.Put(ctx, "usr:1:name:", "value", nil)
+		require.ErrorIs(t, err, ErrKeySchema)
+	}
+
+	t.Run("No schema", func(t *testing.T) {
+		dm, err := s1.NewDMap("other")
+		require.NoError(t, err)
+		require.NoError(t, dm.Put(ctx, "anything goes", "value", nil))
+	})
+
+	t.Run("Invalid schema", func(t *testing.T) {
+		_, err := s1.NewDMap("invalid")
+		require.Error(t, err)
+	})
+}
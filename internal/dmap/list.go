@@ -0,0 +1,223 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/redis/go-redis/v9"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrNotAList is returned when a list operation is called on a key whose value is not a list.
+var ErrNotAList = errors.New("value is not a list")
+
+// ListTrim denotes the end of a capped list that LPushCapped drops the elements from.
+type ListTrim uint8
+
+const (
+	// TrimTail drops the oldest elements from the tail of the list. The list keeps
+	// the most recent elements.
+	TrimTail ListTrim = iota
+
+	// TrimHead drops the newest elements from the head of the list. The list keeps
+	// the oldest elements, the values pushed after the list is full are discarded.
+	TrimHead
+)
+
+// decodeList decodes a list value. A list is stored as a msgpack encoded array,
+// the head of the list is the first element.
+func decodeList(raw []byte) ([][]byte, error) {
+	var list [][]byte
+	if err := msgpack.Unmarshal(raw, &list); err != nil {
+		return nil, ErrNotAList
+	}
+	return list, nil
+}
+
+// loadList returns the elements and the TTL of the list. It returns an empty list
+// if the key does not exist.
+func (dm *DMap) loadList(e *env) ([][]byte, int64, error) {
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	list, err := decodeList(entry.Value())
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %s", err, e.key)
+	}
+	return list, entry.TTL(), nil
+}
+
+// storeList writes the list back to the key and preserves the TTL.
+func (dm *DMap) storeList(e *env, list [][]byte, ttl int64) error {
+	value, err := msgpack.Marshal(list)
+	if err != nil {
+		return err
+	}
+	e.value = value
+	if ttl != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Until(time.UnixMilli(ttl))
+	}
+	return dm.put(e)
+}
+
+// lpushCapped runs on the partition owner of the key.
+func (dm *DMap) lpushCapped(e *env, max int, trim ListTrim, values [][]byte) (int, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	current, ttl, err := dm.loadList(e)
+	if err != nil {
+		return 0, err
+	}
+
+	// The values are pushed one after another, so the last one becomes the head.
+	list := make([][]byte, 0, len(values)+len(current))
+	for i := len(values) - 1; i >= 0; i-- {
+		list = append(list, values[i])
+	}
+	list = append(list, current...)
+
+	if len(list) > max {
+		switch trim {
+		case TrimHead:
+			list = list[len(list)-max:]
+		default:
+			list = list[:max]
+		}
+	}
+
+	if err = dm.storeList(e, list, ttl); err != nil {
+		return 0, err
+	}
+	return len(list), nil
+}
+
+// lrange runs on the partition owner of the key.
+func (dm *DMap) lrange(e *env, start, stop int) ([][]byte, error) {
+	list, _, err := dm.loadList(e)
+	if err != nil {
+		return nil, err
+	}
+
+	// Negative indexes are counted from the tail, -1 is the last element.
+	if start < 0 {
+		start += len(list)
+	}
+	if stop < 0 {
+		stop += len(list)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= len(list) {
+		stop = len(list) - 1
+	}
+	if start > stop {
+		return [][]byte{}, nil
+	}
+	return list[start : stop+1], nil
+}
+
+// LPushCapped pushes the values to the head of the list stored at the key and trims the
+// list to max elements in the same step, so the list never exceeds max. The values are
+// pushed in the given order, the last value becomes the head. trim decides which end
+// of the list is dropped. The list is created if it doesn't exist and the TTL is preserved.
+// It returns the length of the list. It returns ErrNotAList if the value is not a list.
+// The operation runs on the partition owner of the key.
+func (dm *DMap) LPushCapped(ctx context.Context, key string, max int, trim ListTrim, values ...[]byte) (int, error) {
+	if max <= 0 {
+		return 0, fmt.Errorf("%w: max must be greater than zero: %d", protocol.ErrInvalidArgument, max)
+	}
+	if len(values) == 0 {
+		return 0, fmt.Errorf("%w: no values", protocol.ErrInvalidArgument)
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.lpushCapped(e, max, trim, values)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewLPushCapped(dm.name, key, max, int(trim), values...).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	length, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(length), nil
+}
+
+// LRange returns the elements of the list stored at the key between start and stop,
+// both inclusive. The head of the list is at index 0, the negative indexes are counted
+// from the tail. An absent key is an empty list. It returns ErrNotAList if the value
+// is not a list.
+func (dm *DMap) LRange(ctx context.Context, key string, start, stop int) ([][]byte, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.lrange(e, start, stop)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewLRange(dm.name, key, start, stop).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if errors.Is(err, redis.Nil) {
+		return [][]byte{}, nil
+	}
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	return parseList(cmd.Val())
+}
+
+// parseList parses the elements of a list response.
+func parseList(values []interface{}) ([][]byte, error) {
+	list := make([][]byte, 0, len(values))
+	for _, value := range values {
+		element, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid element: %v", value)
+		}
+		list = append(list, []byte(element))
+	}
+	return list, nil
+}
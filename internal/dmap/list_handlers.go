@@ -0,0 +1,64 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) lpushCappedCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	lpushCmd, err := protocol.ParseLPushCappedCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(lpushCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	length, err := dm.LPushCapped(s.ctx, lpushCmd.Key, lpushCmd.Max, ListTrim(lpushCmd.Trim), lpushCmd.Values...)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt(length)
+}
+
+func (s *Service) lrangeCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	lrangeCmd, err := protocol.ParseLRangeCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(lrangeCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	list, err := dm.LRange(s.ctx, lrangeCmd.Key, lrangeCmd.Start, lrangeCmd.Stop)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(list))
+	for _, element := range list {
+		conn.WriteBulk(element)
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_LPushCapped(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	const max = 10
+
+	// Push past the cap on both members concurrently, the non-owner redirects the request.
+	var errGr errgroup.Group
+	for i := 0; i < 100; i++ {
+		i := i
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			length, err := dm.LPushCapped(ctx, "mylist", max, TrimTail, []byte(strconv.Itoa(i)))
+			if err != nil {
+				return err
+			}
+			if length > max {
+				return fmt.Errorf("list exceeds the cap: %d", length)
+			}
+			return nil
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	list, err := dm1.LRange(ctx, "mylist", 0, -1)
+	require.NoError(t, err)
+	require.Len(t, list, max)
+
+	// The list keeps the most recent values, the last one is the head.
+	for i := 0; i < max; i++ {
+		length, err := dm2.LPushCapped(ctx, "mylist", max, TrimTail, []byte(fmt.Sprintf("recent-%d", i)))
+		require.NoError(t, err)
+		require.Equal(t, max, length)
+	}
+	list, err = dm2.LRange(ctx, "mylist", 0, -1)
+	require.NoError(t, err)
+	require.Len(t, list, max)
+	for i, value := range list {
+		require.Equal(t, fmt.Sprintf("recent-%d", max-1-i), string(value))
+	}
+
+	t.Run("Trim head", func(t *testing.T) {
+		length, err := dm1.LPushCapped(ctx, "oldest", 3, TrimHead, []byte("a"), []byte("b"))
+		require.NoError(t, err)
+		require.Equal(t, 2, length)
+
+		length, err = dm2.LPushCapped(ctx, "oldest", 3, TrimHead, []byte("c"), []byte("d"))
+		require.NoError(t, err)
+		require.Equal(t, 3, length)
+
+		list, err := dm2.LRange(ctx, "oldest", 0, -1)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("c"), []byte("b"), []byte("a")}, list)
+	})
+
+	t.Run("Range", func(t *testing.T) {
+		list, err := dm1.LRange(ctx, "mylist", 1, 2)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("recent-8"), []byte("recent-7")}, list)
+
+		list, err = dm2.LRange(ctx, "mylist", -2, 100)
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("recent-1"), []byte("recent-0")}, list)
+
+		list, err = dm1.LRange(ctx, "absent", 0, -1)
+		require.NoError(t, err)
+		require.Empty(t, list)
+	})
+
+	t.Run("Invalid max", func(t *testing.T) {
+		_, err := dm1.LPushCapped(ctx, "mylist", 0, TrimTail, []byte("foo"))
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+	})
+
+	t.Run("Not a list", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "mystring", "foo", nil))
+
+		_, err := dm2.LPushCapped(ctx, "mystring", max, TrimTail, []byte("foo"))
+		require.ErrorIs(t, err, ErrNotAList)
+
+		_, err = dm2.LRange(ctx, "mystring", 0, -1)
+		require.ErrorIs(t, err, ErrNotAList)
+	})
+}
+
+func TestDMap_lpushCappedCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The commands are sent to the member that doesn't own the key, the other
+	// pushes run on the owner. Every value must survive.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mylist")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	const numValues = 1000
+	var errGr errgroup.Group
+	for i := 0; i < numValues; i++ {
+		value := []byte(strconv.Itoa(i))
+		if i%2 == 0 {
+			errGr.Go(func() error {
+				_, err := owner.LPushCapped(ctx, "mylist", numValues, TrimTail, value)
+				return err
+			})
+			continue
+		}
+		errGr.Go(func() error {
+			cmd := protocol.NewLPushCapped("mydmap", "mylist", numValues, int(TrimTail), value).Command(ctx)
+			rc := other.client.Get(other.rt.This().String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return err
+			}
+			return cmd.Err()
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	cmd := protocol.NewLRange("mydmap", "mylist", 0, -1).Command(ctx)
+	rc := other.client.Get(other.rt.This().String())
+	require.NoError(t, rc.Process(ctx, cmd))
+	list, err := parseList(cmd.Val())
+	require.NoError(t, err)
+	require.Equal(t, numValues, len(list))
+}
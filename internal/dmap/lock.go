@@ -21,6 +21,7 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/buraksezer/olric/internal/cluster/partitions"
@@ -35,6 +36,91 @@ var (
 	ErrNoSuchLock = errors.New("no such lock")
 )
 
+// fencingDMap is the internal DMap that keeps the fencing counters of the locks.
+const fencingDMap = internalDMapPrefix + "fencing"
+
+// fencingCounterTTL is how long the fencing counter of a lock is kept after the last
+// acquisition. A reclaimed counter starts again from the current time in microseconds,
+// which is greater than the tokens it has issued unless the clock goes back more than that.
+const fencingCounterTTL = time.Hour
+
+// fencingKey returns the key of the fencing counter of a lock in fencingDMap. The length of the
+// DMap name is prepended, so the keys of different DMaps never collide.
+func fencingKey(name, key string) string {
+	return fmt.Sprintf("%d.%s.%s", len(name), name, key)
+}
+
+// ProtectedValueKeyPrefix is the prefix of the keys that keep the values written by LeaseAndPut.
+// The protected value of a lock is stored in the same DMap with the key ProtectedValueKeyPrefix + key.
+const ProtectedValueKeyPrefix = "olric.protected."
+
+// nextFencingToken increments the fencing counter of the lock and returns the new value.
+// The caller must hold the fine-grained lock of the key on its partition owner, so the
+// counter is never updated by two acquisitions at the same time.
+func (dm *DMap) nextFencingToken(ctx context.Context, key string) (uint64, error) {
+	fdm, err := dm.s.getOrCreateDMap(fencingDMap)
+	if err != nil {
+		return 0, err
+	}
+
+	fkey := fencingKey(dm.name, key)
+	fencingToken := uint64(time.Now().UnixMicro())
+	entry, err := fdm.Get(ctx, fkey)
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+	case err != nil:
+		return 0, err
+	default:
+		last, err := strconv.ParseUint(string(entry.Value()), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid fencing counter for key: %s: %w", key, err)
+		}
+		fencingToken = last + 1
+	}
+
+	e := newEnv(ctx)
+	e.dmap = fdm.name
+	e.key = fkey
+	e.value = []byte(strconv.FormatUint(fencingToken, 10))
+	e.putConfig = &PutConfig{HasPX: true, PX: fencingCounterTTL}
+	err = fdm.put(e)
+	if err != nil {
+		return 0, err
+	}
+	return fencingToken, nil
+}
+
+// lockKey tries to acquire the lock once and issues its fencing token under the fine-grained
+// lock of the key, so a holder that acquires the lock later always gets a greater fencing token.
+// It returns ErrKeyFound if the lock is held by someone else.
+func (dm *DMap) lockKey(e *env) (uint64, error) {
+	lkey := dm.name + e.key
+	dm.s.locker.Lock(lkey)
+	defer func() {
+		err := dm.s.locker.Unlock(lkey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, dm.name, err)
+		}
+	}()
+
+	err := dm.put(e)
+	if err != nil {
+		return 0, err
+	}
+
+	fencingToken, err := dm.nextFencingToken(e.ctx, e.key)
+	if err != nil {
+		// The caller doesn't get a token to unlock it. The caller's context may be done,
+		// use the service's context.
+		_, delErr := dm.deleteKeys(dm.s.ctx, e.key)
+		if delErr != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the lock for key: %s on DMap: %s: %v", e.key, dm.name, delErr)
+		}
+		return 0, err
+	}
+	return fencingToken, nil
+}
+
 // unlockKey tries to unlock the lock by verifying the lock with token.
 func (dm *DMap) unlockKey(ctx context.Context, key string, token []byte) error {
 	lkey := dm.name + key
@@ -88,58 +174,108 @@ func (dm *DMap) Unlock(ctx context.Context, key string, token []byte) error {
 }
 
 // tryLock takes a deadline and env and sets a key-value pair by using
-// Put with NX and PX commands. It tries to acquire the lock 100 times per second
-// if the lock is already acquired. It returns ErrLockNotAcquired if the deadline exceeds.
-func (dm *DMap) tryLock(e *env, deadline time.Duration) error {
-	err := dm.put(e)
+// Put with NX and PX commands. If the lock is already acquired, it tries again after
+// the poll interval, which backs off up to LockPollMaxInterval. It returns ErrLockNotAcquired if the deadline exceeds,
+// and the context's error if the caller's context is done. It returns the fencing token of the lock.
+func (dm *DMap) tryLock(e *env, deadline, pollInterval time.Duration) (uint64, error) {
+	fencingToken, err := dm.lockKey(e)
 	if err == nil {
-		return nil
+		return fencingToken, nil
 	}
 	// If it returns ErrKeyFound, the lock is already acquired.
 	if !errors.Is(err, ErrKeyFound) {
 		// something went wrong
-		return err
+		return 0, err
+	}
+	if deadline <= 0 {
+		// Tried once, don't wait.
+		return 0, ErrLockNotAcquired
 	}
 
 	ctx, cancel := context.WithTimeout(e.ctx, deadline)
 	defer cancel()
 
-	timer := time.NewTimer(10 * time.Millisecond)
+	poller := dm.newLockPoller(pollInterval)
+	timer := time.NewTimer(poller.next())
 	defer timer.Stop()
 
 	// Try to acquire lock.
 LOOP:
 	for {
-		timer.Reset(10 * time.Millisecond)
 		select {
 		case <-timer.C:
-			err = dm.put(e)
+			fencingToken, err = dm.lockKey(e)
 			if errors.Is(err, ErrKeyFound) {
 				// not released by the other process/goroutine. try again.
+				timer.Reset(poller.next())
 				continue
 			}
 			if err != nil {
 				// something went wrong.
-				return err
+				return 0, err
 			}
 			// Acquired! Quit without error.
 			break LOOP
 		case <-ctx.Done():
+			if err := e.ctx.Err(); err != nil {
+				// Cancelled by the caller.
+				return 0, err
+			}
 			// Deadline exceeded. Quit with an error.
-			return ErrLockNotAcquired
+			return 0, ErrLockNotAcquired
 		case <-dm.s.ctx.Done():
-			return fmt.Errorf("server is gone")
+			return 0, fmt.Errorf("server is gone")
 		}
 	}
-	return nil
+	return fencingToken, nil
+}
+
+// Lock prepares a token and env, then calls tryLock. It returns the token and the fencing token of the lock.
+func (dm *DMap) Lock(ctx context.Context, key string, timeout, deadline time.Duration) ([]byte, uint64, error) {
+	return dm.LockWithPollInterval(ctx, key, timeout, deadline, 0)
+}
+
+// TryLock tries to acquire the lock only once. It returns ErrLockNotAcquired immediately
+// if the lock is held by someone else.
+func (dm *DMap) TryLock(ctx context.Context, key string, timeout time.Duration) ([]byte, uint64, error) {
+	return dm.LockWithPollInterval(ctx, key, timeout, 0, 0)
 }
 
-// Lock prepares a token and env, then calls tryLock
-func (dm *DMap) Lock(ctx context.Context, key string, timeout, deadline time.Duration) ([]byte, error) {
+// LockWithPollInterval works like Lock, but it tries to acquire the lock once per
+// pollInterval. It uses the configured default if pollInterval is zero.
+// It redirects the request to the partition owner, if required.
+func (dm *DMap) LockWithPollInterval(ctx context.Context, key string, timeout, deadline, pollInterval time.Duration) ([]byte, uint64, error) {
+	hkey := partitions.HKey(dm.name, key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if !member.CompareByName(dm.s.rt.This()) {
+		cmd := protocol.NewLock(dm.name, key, deadline.Seconds()).
+			SetPX(timeout.Milliseconds()).
+			SetPollInterval(pollInterval.Milliseconds()).
+			Command(ctx)
+		rc := dm.s.client.Get(member.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return nil, 0, protocol.ConvertError(err)
+		}
+		res, err := cmd.Result()
+		if err != nil {
+			return nil, 0, protocol.ConvertError(err)
+		}
+		token, fencingToken, err := protocol.ParseLockResponse(res)
+		if err != nil {
+			return nil, 0, err
+		}
+		raw, err := hex.DecodeString(token)
+		if err != nil {
+			return nil, 0, err
+		}
+		return raw, fencingToken, nil
+	}
+
 	token := make([]byte, 16)
 	_, err := rand.Read(token)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
 	var pc PutConfig
@@ -149,17 +285,21 @@ func (dm *DMap) Lock(ctx context.Context, key string, timeout, deadline time.Dur
 		pc.PX = timeout
 	}
 
+	if pollInterval <= 0 {
+		pollInterval = dm.s.config.DMaps.LockPollInterval
+	}
+
 	e := newEnv(ctx)
 	e.putConfig = &pc
 	e.dmap = dm.name
 	e.key = key
 	e.value = token
-	err = dm.tryLock(e, deadline)
+	fencingToken, err := dm.tryLock(e, deadline, pollInterval)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	return token, nil
+	return token, fencingToken, nil
 }
 
 // leaseKey tries to update the expiry of the key by verifying token.
@@ -174,6 +314,12 @@ func (dm *DMap) leaseKey(ctx context.Context, key string, token []byte, timeout
 		}
 	}()
 
+	return dm.extendLock(ctx, key, token, timeout)
+}
+
+// extendLock verifies the token and updates the expiry of the lock. The caller must hold
+// the fine-grained lock of the key.
+func (dm *DMap) extendLock(ctx context.Context, key string, token []byte, timeout time.Duration) error {
 	// get the key to check its value
 	e, err := dm.Get(ctx, key)
 	if errors.Is(err, ErrKeyNotFound) {
@@ -211,7 +357,53 @@ func (dm *DMap) Lease(ctx context.Context, key string, token []byte, timeout tim
 		return dm.leaseKey(ctx, key, token, timeout)
 	}
 
-	cmd := protocol.NewLockLease(dm.name, key, hex.EncodeToString(token), timeout.Seconds()).Command(dm.s.ctx)
+	cmd := protocol.NewLockLease(dm.name, key, hex.EncodeToString(token), timeout.Seconds()).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// leaseAndPutKey updates the expiry of the lock and writes its protected value under the
+// fine-grained lock of the key, so no Unlock, Lease or LeaseAndPut can run in between.
+func (dm *DMap) leaseAndPutKey(ctx context.Context, key string, token, value []byte, timeout time.Duration) error {
+	lkey := dm.name + key
+	dm.s.locker.Lock(lkey)
+	defer func() {
+		err := dm.s.locker.Unlock(lkey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", key, dm.name, err)
+		}
+	}()
+
+	err := dm.extendLock(ctx, key, token, timeout)
+	if err != nil {
+		return err
+	}
+
+	// The lock is valid for timeout from now on, nobody else can acquire it while the value is written.
+	err = dm.Put(ctx, ProtectedValueKeyPrefix+key, value, nil)
+	if err != nil {
+		return fmt.Errorf("lease and put failed: %w", err)
+	}
+	return nil
+}
+
+// LeaseAndPut takes key and token, updates the expiry of the lock with timeout and writes
+// the protected value of the lock in a single step. It returns ErrNoSuchLock without writing
+// the value if the lock has been released or acquired by someone else. The value is stored
+// with the key ProtectedValueKeyPrefix + key. It redirects the request to the partition owner
+// of the lock, if required.
+func (dm *DMap) LeaseAndPut(ctx context.Context, key string, token, value []byte, timeout time.Duration) error {
+	hkey := partitions.HKey(dm.name, key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.leaseAndPutKey(ctx, key, token, value, timeout)
+	}
+
+	cmd := protocol.NewLockLeasePut(dm.name, key, hex.EncodeToString(token), timeout.Milliseconds(), value).Command(ctx)
 	rc := dm.s.client.Get(member.String())
 	err := rc.Process(ctx, cmd)
 	if err != nil {
@@ -219,3 +411,21 @@ func (dm *DMap) Lease(ctx context.Context, key string, token []byte, timeout tim
 	}
 	return protocol.ConvertError(cmd.Err())
 }
+
+// LockInfo returns whether the lock of the key is held and its remaining TTL. The TTL is
+// zero if the lock never expires. It reads the TTL on the partition owner of the key, the
+// lock is neither acquired nor modified.
+func (dm *DMap) LockInfo(ctx context.Context, key string) (bool, time.Duration, error) {
+	ttls, err := dm.GetTTLMany(ctx, []string{key})
+	if err != nil {
+		return false, 0, err
+	}
+	ttl, ok := ttls[key]
+	if !ok {
+		return false, 0, nil
+	}
+	if ttl == NoExpiry {
+		return true, 0, nil
+	}
+	return true, time.Duration(ttl) * time.Millisecond, nil
+}
@@ -22,6 +22,16 @@ import (
 	"github.com/tidwall/redcon"
 )
 
+// decodeLockToken decodes the token of a lock. It accepts the whole response of the
+// Lock command, the fencing token is ignored.
+func decodeLockToken(raw string) ([]byte, error) {
+	token, _, err := protocol.ParseLockResponse(raw)
+	if err != nil {
+		return nil, err
+	}
+	return hex.DecodeString(token)
+}
+
 func (s *Service) unlockCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	unlockCmd, err := protocol.ParseUnlockCommand(cmd)
 	if err != nil {
@@ -34,7 +44,7 @@ func (s *Service) unlockCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		protocol.WriteError(conn, err)
 		return
 	}
-	token, err := hex.DecodeString(unlockCmd.Token)
+	token, err := decodeLockToken(unlockCmd.Token)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -71,13 +81,14 @@ func (s *Service) lockCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	}
 
 	var deadline = time.Duration(lockCmd.Deadline * float64(time.Second))
-	token, err := dm.Lock(s.ctx, lockCmd.Key, timeout, deadline)
+	var pollInterval = time.Duration(lockCmd.PollInterval * int64(time.Millisecond))
+	token, fencingToken, err := dm.LockWithPollInterval(s.ctx, lockCmd.Key, timeout, deadline, pollInterval)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
 	}
 
-	conn.WriteString(hex.EncodeToString(token))
+	conn.WriteString(protocol.FormatLockResponse(token, fencingToken))
 }
 
 func (s *Service) lockLeaseCommandHandler(conn redcon.Conn, cmd redcon.Command) {
@@ -94,7 +105,7 @@ func (s *Service) lockLeaseCommandHandler(conn redcon.Conn, cmd redcon.Command)
 	}
 
 	timeout := time.Duration(lockLeaseCmd.Timeout * float64(time.Second))
-	token, err := hex.DecodeString(lockLeaseCmd.Token)
+	token, err := decodeLockToken(lockLeaseCmd.Token)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -121,7 +132,7 @@ func (s *Service) plockLeaseCommandHandler(conn redcon.Conn, cmd redcon.Command)
 	}
 
 	timeout := time.Duration(plockLeaseCmd.Timeout * int64(time.Millisecond))
-	token, err := hex.DecodeString(plockLeaseCmd.Token)
+	token, err := decodeLockToken(plockLeaseCmd.Token)
 	if err != nil {
 		protocol.WriteError(conn, err)
 		return
@@ -133,3 +144,30 @@ func (s *Service) plockLeaseCommandHandler(conn redcon.Conn, cmd redcon.Command)
 	}
 	conn.WriteString(protocol.StatusOK)
 }
+
+func (s *Service) lockLeasePutCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	lockLeasePutCmd, err := protocol.ParseLockLeasePutCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.getOrCreateDMap(lockLeasePutCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	timeout := time.Duration(lockLeasePutCmd.Timeout * int64(time.Millisecond))
+	token, err := decodeLockToken(lockLeasePutCmd.Token)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	err = dm.LeaseAndPut(s.ctx, lockLeasePutCmd.Key, token, lockLeasePutCmd.Value, timeout)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
@@ -0,0 +1,53 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"math/rand"
+	"time"
+)
+
+// lockPoller computes the intervals between the attempts of tryLock. The interval is
+// doubled after every attempt up to maxInterval, and randomized by the jitter fraction
+// so that the waiters of the same lock don't wake up at the same time.
+type lockPoller struct {
+	interval    time.Duration
+	maxInterval time.Duration
+	jitter      float64
+}
+
+func (dm *DMap) newLockPoller(interval time.Duration) *lockPoller {
+	return &lockPoller{
+		interval:    interval,
+		maxInterval: dm.s.config.DMaps.LockPollMaxInterval,
+		jitter:      dm.s.config.DMaps.LockPollJitter,
+	}
+}
+
+// next returns the interval before the next attempt.
+func (p *lockPoller) next() time.Duration {
+	interval := p.interval
+	if p.interval < p.maxInterval {
+		p.interval *= 2
+		if p.interval > p.maxInterval {
+			p.interval = p.maxInterval
+		}
+	}
+	if p.jitter > 0 {
+		// Pick a random interval in [interval*(1-jitter), interval*(1+jitter)).
+		interval = time.Duration(float64(interval) * (1 - p.jitter + 2*p.jitter*rand.Float64()))
+	}
+	return interval
+}
@@ -0,0 +1,64 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_lockPoller(t *testing.T) {
+	t.Run("Fixed interval", func(t *testing.T) {
+		p := &lockPoller{interval: 10 * time.Millisecond}
+		for i := 0; i < 3; i++ {
+			require.Equal(t, 10*time.Millisecond, p.next())
+		}
+	})
+
+	t.Run("Exponential backoff", func(t *testing.T) {
+		p := &lockPoller{
+			interval:    10 * time.Millisecond,
+			maxInterval: 50 * time.Millisecond,
+		}
+		var intervals []time.Duration
+		for i := 0; i < 5; i++ {
+			intervals = append(intervals, p.next())
+		}
+		require.Equal(t, []time.Duration{
+			10 * time.Millisecond,
+			20 * time.Millisecond,
+			40 * time.Millisecond,
+			50 * time.Millisecond,
+			50 * time.Millisecond,
+		}, intervals)
+	})
+
+	t.Run("Jitter", func(t *testing.T) {
+		p := &lockPoller{
+			interval: 100 * time.Millisecond,
+			jitter:   0.2,
+		}
+		distinct := make(map[time.Duration]struct{})
+		for i := 0; i < 100; i++ {
+			interval := p.next()
+			require.GreaterOrEqual(t, interval, 80*time.Millisecond)
+			require.Less(t, interval, 120*time.Millisecond)
+			distinct[interval] = struct{}{}
+		}
+		require.Greater(t, len(distinct), 1)
+	})
+}
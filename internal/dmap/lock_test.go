@@ -17,13 +17,18 @@ package dmap
 import (
 	"context"
 	"encoding/hex"
+	"fmt"
 	"github.com/buraksezer/olric/internal/protocol"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
 )
 
 func TestDMap_Lock_With_Timeout_Standalone(t *testing.T) {
@@ -36,7 +41,7 @@ func TestDMap_Lock_With_Timeout_Standalone(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := context.Background()
-	token, err := dm.Lock(ctx, key, time.Second, time.Second)
+	token, _, err := dm.Lock(ctx, key, time.Second, time.Second)
 	require.NoError(t, err)
 
 	err = dm.Unlock(ctx, key, token)
@@ -53,7 +58,7 @@ func TestDMap_Unlock_After_Timeout_Standalone(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := context.Background()
-	token, err := dm.Lock(context.Background(), key, time.Millisecond, time.Second)
+	token, _, err := dm.Lock(context.Background(), key, time.Millisecond, time.Second)
 	require.NoError(t, err)
 
 	<-time.After(10 * time.Millisecond)
@@ -72,10 +77,10 @@ func TestDMap_Lock_With_Timeout_ErrLockNotAcquired_Standalone(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := context.Background()
-	_, err = dm.Lock(ctx, key, time.Second, time.Second)
+	_, _, err = dm.Lock(ctx, key, time.Second, time.Second)
 	require.NoError(t, err)
 
-	_, err = dm.Lock(context.Background(), key, time.Second, time.Millisecond)
+	_, _, err = dm.Lock(context.Background(), key, time.Second, time.Millisecond)
 	require.ErrorIs(t, err, ErrLockNotAcquired)
 }
 
@@ -89,7 +94,7 @@ func TestDMap_LockLease_Standalone(t *testing.T) {
 	require.NoError(t, err)
 
 	ctx := context.Background()
-	token, err := dm.Lock(context.Background(), key, time.Second, time.Second)
+	token, _, err := dm.Lock(context.Background(), key, time.Second, time.Second)
 	require.NoError(t, err)
 
 	err = dm.Lease(ctx, key, token, 2*time.Second)
@@ -118,7 +123,7 @@ func TestDMap_Lock_Standalone(t *testing.T) {
 	dm, err := s.NewDMap("lock.test")
 	require.NoError(t, err)
 
-	token, err := dm.Lock(ctx, key, nilTimeout, time.Second)
+	token, _, err := dm.Lock(ctx, key, nilTimeout, time.Second)
 	require.NoError(t, err)
 
 	err = dm.Unlock(ctx, key, token)
@@ -135,10 +140,10 @@ func TestDMap_Lock_ErrLockNotAcquired_Standalone(t *testing.T) {
 	dm, err := s.NewDMap("lock.test")
 	require.NoError(t, err)
 
-	_, err = dm.Lock(ctx, key, nilTimeout, time.Second)
+	_, _, err = dm.Lock(ctx, key, nilTimeout, time.Second)
 	require.NoError(t, err)
 
-	_, err = dm.Lock(ctx, key, nilTimeout, time.Millisecond)
+	_, _, err = dm.Lock(ctx, key, nilTimeout, time.Millisecond)
 	require.ErrorIs(t, err, ErrLockNotAcquired)
 }
 
@@ -154,7 +159,7 @@ func TestDMap_LockWithTimeout_Cluster(t *testing.T) {
 	tokens := make(map[string][]byte)
 	for i := 0; i < 100; i++ {
 		key := "lock.test.foo." + strconv.Itoa(i)
-		token, err := dm.Lock(ctx, key, time.Hour, time.Second)
+		token, _, err := dm.Lock(ctx, key, time.Hour, time.Second)
 		require.NoError(t, err)
 		tokens[key] = token
 	}
@@ -178,7 +183,7 @@ func TestDMap_LockLease_Cluster(t *testing.T) {
 	tokens := make(map[string][]byte)
 	for i := 0; i < 100; i++ {
 		key := "lock.test.foo." + strconv.Itoa(i)
-		token, err := dm.Lock(ctx, key, 5*time.Second, 10*time.Millisecond)
+		token, _, err := dm.Lock(ctx, key, 5*time.Second, 10*time.Millisecond)
 		require.NoError(t, err)
 		tokens[key] = token
 	}
@@ -202,7 +207,7 @@ func TestDMap_Lock_Cluster(t *testing.T) {
 	tokens := make(map[string][]byte)
 	for i := 0; i < 100; i++ {
 		key := "lock.test.foo." + strconv.Itoa(i)
-		token, err := dm.Lock(ctx, key, nilTimeout, time.Second)
+		token, _, err := dm.Lock(ctx, key, nilTimeout, time.Second)
 		require.NoError(t, err)
 		tokens[key] = token
 	}
@@ -225,7 +230,7 @@ func TestDMap_LockWithTimeout_ErrLockNotAcquired_Cluster(t *testing.T) {
 	ctx := context.Background()
 	for i := 0; i < 100; i++ {
 		key := "lock.test.foo." + strconv.Itoa(i)
-		_, err := dm.Lock(ctx, key, time.Second, time.Second)
+		_, _, err := dm.Lock(ctx, key, time.Second, time.Second)
 		require.NoError(t, err)
 	}
 
@@ -233,7 +238,7 @@ func TestDMap_LockWithTimeout_ErrLockNotAcquired_Cluster(t *testing.T) {
 
 	for i := 0; i < 100; i++ {
 		key := "lock.test.foo." + strconv.Itoa(i)
-		_, err = dm.Lock(ctx, key, time.Second, time.Millisecond)
+		_, _, err = dm.Lock(ctx, key, time.Second, time.Millisecond)
 		require.ErrorIs(t, err, ErrLockNotAcquired)
 	}
 }
@@ -249,14 +254,14 @@ func TestDMap_Lock_After_Lock_With_Timeout_Cluster(t *testing.T) {
 	ctx := context.Background()
 	for i := 0; i < 100; i++ {
 		key := "lock.test.foo." + strconv.Itoa(i)
-		_, err = dm.Lock(ctx, key, time.Millisecond, time.Second)
+		_, _, err = dm.Lock(ctx, key, time.Millisecond, time.Second)
 		require.NoError(t, err)
 	}
 
 	cluster.AddMember(nil)
 	for i := 0; i < 100; i++ {
 		key := "lock.test.foo." + strconv.Itoa(i)
-		_, err = dm.Lock(ctx, key, nilTimeout, time.Second)
+		_, _, err = dm.Lock(ctx, key, nilTimeout, time.Second)
 		require.NoError(t, err)
 	}
 }
@@ -270,14 +275,14 @@ func TestDMap_tryLock(t *testing.T) {
 	dm, err := s.NewDMap("lock.test")
 	require.NoError(t, err)
 
-	_, err = dm.Lock(context.Background(), key, time.Second, time.Second)
+	_, _, err = dm.Lock(context.Background(), key, time.Second, time.Second)
 	require.NoError(t, err)
 
 	var i int
 	var acquired bool
 	for i <= 10 {
 		i++
-		_, err := dm.Lock(context.Background(), key, nilTimeout, 100*time.Millisecond)
+		_, _, err := dm.Lock(context.Background(), key, nilTimeout, 100*time.Millisecond)
 		if err == ErrLockNotAcquired {
 			// already acquired
 			continue
@@ -292,6 +297,48 @@ func TestDMap_tryLock(t *testing.T) {
 	}
 }
 
+func TestDMap_LockWithPollInterval(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	key := "lock.test.foo"
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, _, err = dm.Lock(ctx, key, 50*time.Millisecond, time.Second)
+	require.NoError(t, err)
+
+	// The lock expires after 50ms, but the next attempt is made after the poll interval.
+	pollInterval := 500 * time.Millisecond
+	start := time.Now()
+	_, _, err = dm.LockWithPollInterval(ctx, key, nilTimeout, 5*time.Second, pollInterval)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, int64(time.Since(start)), int64(pollInterval))
+}
+
+func TestDMap_Lock_ContextCancelled(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	key := "lock.test.foo"
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	_, _, err = dm.Lock(context.Background(), key, nilTimeout, time.Second)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, _, err = dm.Lock(ctx, key, nilTimeout, 10*time.Second)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, int64(time.Since(start)), int64(10*time.Second))
+}
+
 func TestDMap_lockCommandHandler(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	s := cluster.AddMember(nil).(*Service)
@@ -367,7 +414,7 @@ func TestDMap_lockLeaseCommandHandler(t *testing.T) {
 
 	ctx := context.Background()
 
-	token, err := dm.Lock(ctx, key, time.Second, time.Second)
+	token, _, err := dm.Lock(ctx, key, time.Second, time.Second)
 	require.NoError(t, err)
 
 	// Update the timeout
@@ -394,7 +441,7 @@ func TestDMap_plockLeaseCommandHandler(t *testing.T) {
 
 	ctx := context.Background()
 
-	token, err := dm.Lock(ctx, key, 250*time.Millisecond, time.Second)
+	token, _, err := dm.Lock(ctx, key, 250*time.Millisecond, time.Second)
 	require.NoError(t, err)
 
 	// Update the timeout
@@ -409,3 +456,262 @@ func TestDMap_plockLeaseCommandHandler(t *testing.T) {
 	err = dm.Unlock(ctx, key, token)
 	require.NoError(t, err)
 }
+
+func TestDMap_TryLock(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	// TryLock must not wait for the next attempt.
+	c.DMaps.LockPollInterval = time.Hour
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := "lock.test.foo." + strconv.Itoa(i)
+		token, _, err := dm2.TryLock(ctx, key, time.Minute)
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, _, err = dm1.TryLock(ctx, key, time.Minute)
+		require.ErrorIs(t, err, ErrLockNotAcquired)
+		require.Less(t, time.Since(start), time.Second)
+
+		require.NoError(t, dm1.Unlock(ctx, key, token))
+		token, _, err = dm1.TryLock(ctx, key, 0)
+		require.NoError(t, err)
+		require.NoError(t, dm1.Unlock(ctx, key, token))
+	}
+}
+
+func TestDMap_FencingToken(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	key := "lock.test.foo"
+	var last uint64
+	for i := 0; i < 5; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		token, fencingToken, err := dm.Lock(ctx, key, time.Minute, time.Second)
+		require.NoError(t, err)
+		require.Greater(t, fencingToken, last)
+		last = fencingToken
+		require.NoError(t, dm.Unlock(ctx, key, token))
+	}
+}
+
+func TestDMap_FencingToken_Concurrent(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	key := "lock.test.foo"
+	var mtx sync.Mutex
+	var last uint64
+	var g errgroup.Group
+	for i := 0; i < 20; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		g.Go(func() error {
+			token, fencingToken, err := dm.Lock(ctx, key, time.Minute, 10*time.Second)
+			if err != nil {
+				return err
+			}
+			// The holders are serialized by the lock, the fencing tokens must follow the same order.
+			mtx.Lock()
+			if fencingToken <= last {
+				mtx.Unlock()
+				return fmt.Errorf("fencing token %d is not greater than %d", fencingToken, last)
+			}
+			last = fencingToken
+			mtx.Unlock()
+			return dm.Unlock(ctx, key, token)
+		})
+	}
+	require.NoError(t, g.Wait())
+}
+
+func TestDMap_FencingToken_Eviction(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"ttl": {
+			TTLDuration: 100 * time.Millisecond,
+		},
+		"max-age": {
+			MaxAge: 100 * time.Millisecond,
+		},
+		"lru": {
+			EvictionPolicy: config.LRUEviction,
+			MaxKeys:        1000,
+		},
+	}
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for _, name := range []string{"ttl", "max-age", "lru"} {
+		dm, err := s.NewDMap(name)
+		require.NoError(t, err)
+
+		key := "lock.test.foo"
+		var last uint64
+		for i := 0; i < 3; i++ {
+			token, fencingToken, err := dm.Lock(ctx, key, time.Minute, time.Second)
+			require.NoError(t, err)
+			require.Greater(t, fencingToken, last)
+			last = fencingToken
+			require.NoError(t, dm.Unlock(ctx, key, token))
+		}
+
+		// The counters are not stored in the DMap of the lock, they survive its TTL and MaxAge.
+		<-time.After(200 * time.Millisecond)
+		token, fencingToken, err := dm.Lock(ctx, key, time.Minute, time.Second)
+		require.NoError(t, err)
+		require.Greater(t, fencingToken, last)
+		require.NoError(t, dm.Unlock(ctx, key, token))
+	}
+}
+
+func TestDMap_FencingToken_Counter_Expiry(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	key := "lock.test.foo"
+	token, fencingToken, err := dm.Lock(ctx, key, time.Minute, time.Second)
+	require.NoError(t, err)
+	require.NoError(t, dm.Unlock(ctx, key, token))
+
+	// The idle counters are reclaimed.
+	fdm, err := s.getOrCreateDMap(fencingDMap)
+	require.NoError(t, err)
+	ttls, err := fdm.GetTTLMany(ctx, []string{fencingKey(dm.name, key)})
+	require.NoError(t, err)
+	ttl := ttls[fencingKey(dm.name, key)]
+	require.NotEqual(t, NoExpiry, ttl)
+	require.LessOrEqual(t, ttl, fencingCounterTTL.Milliseconds())
+
+	// A reclaimed counter starts from the current time, it doesn't hand out the same tokens again.
+	_, err = fdm.Delete(ctx, fencingKey(dm.name, key))
+	require.NoError(t, err)
+	token, newFencingToken, err := dm.Lock(ctx, key, time.Minute, time.Second)
+	require.NoError(t, err)
+	require.Greater(t, newFencingToken, fencingToken)
+	require.NoError(t, dm.Unlock(ctx, key, token))
+}
+
+func TestDMap_LeaseAndPut(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	key := "lock.test.foo"
+	token, _, err := dm1.Lock(ctx, key, 100*time.Millisecond, time.Second)
+	require.NoError(t, err)
+
+	// Renew the lease and write the protected value from both members, one of them redirects.
+	for i, dm := range []*DMap{dm1, dm2} {
+		require.NoError(t, dm.LeaseAndPut(ctx, key, token, testutil.ToVal(i), 2*time.Second))
+
+		e, err := dm.Get(ctx, ProtectedValueKeyPrefix+key)
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), e.Value())
+	}
+
+	e, err := dm1.Get(ctx, key)
+	require.NoError(t, err)
+	require.Greater(t, e.TTL()-time.Now().UnixMilli(), int64(1900))
+
+	t.Run("Lost lease", func(t *testing.T) {
+		require.NoError(t, dm1.Lease(ctx, key, token, 10*time.Millisecond))
+		<-time.After(50 * time.Millisecond)
+
+		err := dm2.LeaseAndPut(ctx, key, token, testutil.ToVal(100), time.Second)
+		require.ErrorIs(t, err, ErrNoSuchLock)
+
+		// The value is not written.
+		e, err := dm1.Get(ctx, ProtectedValueKeyPrefix+key)
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(1), e.Value())
+	})
+}
+
+func TestDMap_LockInfo(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("lock.test")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("lock.test")
+	require.NoError(t, err)
+
+	key := "lock.test.foo"
+	held, _, err := dm2.LockInfo(ctx, key)
+	require.NoError(t, err)
+	require.False(t, held)
+
+	token, _, err := dm1.Lock(ctx, key, time.Minute, time.Second)
+	require.NoError(t, err)
+
+	for _, dm := range []*DMap{dm1, dm2} {
+		held, ttl, err := dm.LockInfo(ctx, key)
+		require.NoError(t, err)
+		require.True(t, held)
+		require.Greater(t, ttl, 50*time.Second)
+		require.LessOrEqual(t, ttl, time.Minute)
+	}
+
+	// LockInfo doesn't modify the lock.
+	require.NoError(t, dm1.Unlock(ctx, key, token))
+	held, _, err = dm2.LockInfo(ctx, key)
+	require.NoError(t, err)
+	require.False(t, held)
+
+	token, _, err = dm1.Lock(ctx, key, nilTimeout, time.Second)
+	require.NoError(t, err)
+	held, ttl, err := dm2.LockInfo(ctx, key)
+	require.NoError(t, err)
+	require.True(t, held)
+	require.Zero(t, ttl)
+	require.NoError(t, dm1.Unlock(ctx, key, token))
+}
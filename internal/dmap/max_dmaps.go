@@ -0,0 +1,116 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+)
+
+// ErrTooManyDMaps is returned when a new DMap is requested and the number of DMaps in
+// the cluster has reached config.DMaps.MaxDMaps.
+var ErrTooManyDMaps = errors.New("too many DMaps")
+
+// localDMapNames returns the names of the DMaps initialized on this member.
+func (s *Service) localDMapNames() []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	names := make([]string, 0, len(s.dmaps))
+	for name := range s.dmaps {
+		names = append(names, name)
+	}
+	return names
+}
+
+// clusterDMapNames returns the names of the DMaps initialized on any member. The internal
+// DMaps are excluded.
+func (s *Service) clusterDMapNames() (map[string]struct{}, error) {
+	var mtx sync.Mutex
+	result := make(map[string]struct{})
+
+	var members []discovery.Member
+	m := s.rt.Members()
+	m.RLock()
+	m.Range(func(_ uint64, member discovery.Member) bool {
+		members = append(members, member)
+		return true
+	})
+	m.RUnlock()
+
+	var g errgroup.Group
+	for _, item := range members {
+		member := item
+		g.Go(func() error {
+			var names []string
+			if member.CompareByID(s.rt.This()) {
+				names = s.localDMapNames()
+			} else {
+				cmd := protocol.NewDMapNames().Command(s.ctx)
+				rc := s.client.Get(member.String())
+				if err := rc.Process(s.ctx, cmd); err != nil {
+					return protocol.ConvertError(err)
+				}
+				var err error
+				names, err = cmd.Result()
+				if err != nil {
+					return protocol.ConvertError(err)
+				}
+			}
+
+			mtx.Lock()
+			for _, name := range names {
+				if isInternalDMap(name) {
+					continue
+				}
+				result[name] = struct{}{}
+			}
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkMaxDMaps returns ErrTooManyDMaps if the DMap doesn't exist in the cluster and the
+// number of DMaps has reached config.DMaps.MaxDMaps. The DMaps that are initialized on
+// this member are accepted without a network call.
+func (s *Service) checkMaxDMaps(name string) error {
+	if s.config.DMaps.MaxDMaps <= 0 || isInternalDMap(name) {
+		return nil
+	}
+	if _, err := s.getDMap(name); err == nil {
+		return nil
+	}
+
+	names, err := s.clusterDMapNames()
+	if err != nil {
+		return err
+	}
+	if _, ok := names[name]; ok {
+		return nil
+	}
+	if len(names) >= s.config.DMaps.MaxDMaps {
+		return ErrTooManyDMaps
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_MaxDMaps(t *testing.T) {
+	cluster := testcluster.New(NewService)
+
+	c1 := testutil.NewConfig()
+	c1.DMaps.MaxDMaps = 3
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.DMaps.MaxDMaps = 3
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+
+	defer cluster.Shutdown()
+
+	for i := 0; i < 3; i++ {
+		_, err := s1.NewDMap(fmt.Sprintf("mydmap-%d", i))
+		require.NoError(t, err)
+	}
+
+	// The limit is checked cluster-wide.
+	_, err := s2.NewDMap("mydmap-3")
+	require.ErrorIs(t, err, ErrTooManyDMaps)
+	_, err = s1.NewDMap("mydmap-3")
+	require.ErrorIs(t, err, ErrTooManyDMaps)
+
+	// The existing DMaps remain usable on all members.
+	ctx := context.Background()
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("mydmap-1")
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// memoryUsageOnFragment returns the size of the stored entry on the primary copy. It
+// reads the raw entry, so the last access time of the key is not updated.
+func (dm *DMap) memoryUsageOnFragment(hkey uint64) (int, error) {
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return 0, ErrKeyNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	ttl, err := f.storage.GetTTL(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, ErrKeyNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if ttl != 0 && time.Now().UnixNano()/1000000 >= ttl {
+		// Expired but not evicted yet.
+		return 0, ErrKeyNotFound
+	}
+
+	raw, err := f.storage.GetRaw(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, ErrKeyNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(raw), nil
+}
+
+// MemoryUsage returns the number of bytes that the entry occupies in the storage engine,
+// including the key and the metadata. The value is not transferred over the network. It
+// returns ErrKeyNotFound if the key doesn't exist.
+func (dm *DMap) MemoryUsage(ctx context.Context, key string) (int, error) {
+	hkey := partitions.HKey(dm.name, key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	// We are on the partition owner
+	if member.CompareByName(dm.s.rt.This()) {
+		return dm.memoryUsageOnFragment(hkey)
+	}
+
+	// Redirect to the partition owner
+	cmd := protocol.NewMemoryUsage(dm.name, key).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	size, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int(size), nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/kvstore/table"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_MemoryUsage(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	value := bytes.Repeat([]byte("a"), 1000)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), value, nil))
+	}
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		expected := len(key) + len(value) + table.MetadataLength
+		// The keys are distributed, one of the calls is redirected to the owner.
+		for _, dm := range []*DMap{dm1, dm2} {
+			size, err := dm.MemoryUsage(ctx, key)
+			require.NoError(t, err)
+			require.Equal(t, expected, size)
+		}
+	}
+
+	for _, dm := range []*DMap{dm1, dm2} {
+		_, err = dm.MemoryUsage(ctx, "absent-key")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+}
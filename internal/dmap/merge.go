@@ -0,0 +1,126 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep the precision of the numbers.
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// mergePatch applies the patch to the target as described in RFC 7386. A null in the
+// patch deletes the field, an object is merged recursively and the other values replace
+// the target.
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for field, value := range patchObject {
+		if value == nil {
+			delete(targetObject, field)
+			continue
+		}
+		targetObject[field] = mergePatch(targetObject[field], value)
+	}
+	return targetObject
+}
+
+// merge runs on the partition owner of the key.
+func (dm *DMap) merge(e *env, patch []byte) error {
+	p, err := decodeJSON(patch)
+	if err != nil {
+		return fmt.Errorf("%w: invalid merge patch: %v", protocol.ErrInvalidArgument, err)
+	}
+
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	var target interface{}
+	entry, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if entry != nil {
+		target, err = decodeJSON(entry.Value())
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrNotAnObject, e.key)
+		}
+		if entry.TTL() != 0 {
+			e.putConfig.HasPX = true
+			e.putConfig.PX = time.Until(time.UnixMilli(entry.TTL()))
+		}
+	}
+
+	e.value, err = json.Marshal(mergePatch(target, p))
+	if err != nil {
+		return err
+	}
+	return dm.put(e)
+}
+
+// Merge atomically applies a JSON merge patch (RFC 7386) to the JSON document stored at
+// the key. The fields of the patch replace the fields of the document, the objects are
+// merged recursively and a null deletes the field. The key is created from the patch if
+// it doesn't exist. It returns ErrNotAnObject if the stored value is not JSON. The TTL
+// of the key is preserved. The operation runs on the partition owner of the key.
+func (dm *DMap) Merge(ctx context.Context, key string, patch []byte) error {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.merge(e, patch)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewMerge(dm.name, key, patch).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
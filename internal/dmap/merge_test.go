@@ -0,0 +1,156 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Merge(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	document := func(key string) string {
+		entry, err := dm1.Get(ctx, key)
+		require.NoError(t, err)
+		return string(entry.Value())
+	}
+
+	tests := []struct {
+		name     string
+		stored   string
+		patch    string
+		expected string
+	}{
+		{
+			name:     "Add field",
+			stored:   `{"a": 1}`,
+			patch:    `{"b": "new"}`,
+			expected: `{"a": 1, "b": "new"}`,
+		},
+		{
+			name:     "Override field",
+			stored:   `{"a": 1, "b": [1, 2]}`,
+			patch:    `{"a": 2, "b": [3]}`,
+			expected: `{"a": 2, "b": [3]}`,
+		},
+		{
+			name:     "Nested merge",
+			stored:   `{"a": {"b": 1, "c": {"d": 1}}}`,
+			patch:    `{"a": {"c": {"e": 2}, "f": 3}}`,
+			expected: `{"a": {"b": 1, "c": {"d": 1, "e": 2}, "f": 3}}`,
+		},
+		{
+			name:     "Delete field",
+			stored:   `{"a": 1, "b": {"c": 1, "d": 2}}`,
+			patch:    `{"a": null, "b": {"c": null}}`,
+			expected: `{"b": {"d": 2}}`,
+		},
+		{
+			name:     "Replace non-object",
+			stored:   `[1, 2]`,
+			patch:    `{"a": 1}`,
+			expected: `{"a": 1}`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key := "doc-" + test.name
+			require.NoError(t, dm1.Put(ctx, key, []byte(test.stored), nil))
+			require.NoError(t, dm2.Merge(ctx, key, []byte(test.patch)))
+			require.JSONEq(t, test.expected, document(key))
+		})
+	}
+
+	t.Run("Create from patch", func(t *testing.T) {
+		require.NoError(t, dm2.Merge(ctx, "absent", []byte(`{"a": 1, "b": null}`)))
+		require.JSONEq(t, `{"a": 1}`, document("absent"))
+	})
+
+	t.Run("Keep number precision", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "number", []byte(`{"id": 9007199254740993}`), nil))
+		require.NoError(t, dm2.Merge(ctx, "number", []byte(`{"a": 1}`)))
+
+		var doc map[string]json.Number
+		require.NoError(t, json.Unmarshal([]byte(document("number")), &doc))
+		require.Equal(t, json.Number("9007199254740993"), doc["id"])
+	})
+
+	t.Run("Not JSON", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "string", []byte("value"), nil))
+		err := dm2.Merge(ctx, "string", []byte(`{"a": 1}`))
+		require.ErrorIs(t, err, ErrNotAnObject)
+	})
+
+	t.Run("Invalid patch", func(t *testing.T) {
+		err := dm2.Merge(ctx, "absent", []byte(`{"a":`))
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+	})
+}
+
+func TestDMap_mergeCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the key.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mykey")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	// Hold the fine-grained lock of the key on the owner. The command must wait for
+	// it and merge the patch into the document written in the meantime.
+	owner.s.locker.Lock("mydmap" + "mykey")
+	result := make(chan error, 1)
+	go func() {
+		cmd := protocol.NewMerge("mydmap", "mykey", []byte(`{"b": 2}`)).Command(ctx)
+		rc := other.client.Get(other.rt.This().String())
+		_ = rc.Process(ctx, cmd)
+		result <- cmd.Err()
+	}()
+
+	<-time.After(100 * time.Millisecond)
+	require.NoError(t, owner.Put(ctx, "mykey", []byte(`{"a": 1}`), nil))
+	require.NoError(t, owner.s.locker.Unlock("mydmap"+"mykey"))
+	require.NoError(t, <-result)
+
+	entry, err := owner.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"a": 1, "b": 2}`, string(entry.Value()))
+}
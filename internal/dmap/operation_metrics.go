@@ -0,0 +1,117 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/stats"
+)
+
+// OtherMetricLabel is the label of the DMaps beyond config.DMaps.MaxMetricLabels
+// in the operation metrics.
+const OtherMetricLabel = "other"
+
+// latencyBounds are the bucket bounds of the operation latencies in microseconds.
+var latencyBounds = []int64{100, 250, 500, 1000, 2500, 5000, 10000, 25000, 50000, 100000, 250000, 500000, 1000000}
+
+// OperationMetrics is the set of the operation metrics of a DMap label. The
+// latencies are in microseconds.
+type OperationMetrics struct {
+	GetTotal      *stats.Int64Counter
+	PutTotal      *stats.Int64Counter
+	DeleteTotal   *stats.Int64Counter
+	GetLatency    *stats.Int64Histogram
+	PutLatency    *stats.Int64Histogram
+	DeleteLatency *stats.Int64Histogram
+}
+
+func newOperationMetrics() *OperationMetrics {
+	return &OperationMetrics{
+		GetTotal:      stats.NewInt64Counter(),
+		PutTotal:      stats.NewInt64Counter(),
+		DeleteTotal:   stats.NewInt64Counter(),
+		GetLatency:    stats.NewInt64Histogram(latencyBounds...),
+		PutLatency:    stats.NewInt64Histogram(latencyBounds...),
+		DeleteLatency: stats.NewInt64Histogram(latencyBounds...),
+	}
+}
+
+func (m *OperationMetrics) observeGet(start time.Time) {
+	m.GetTotal.Increase(1)
+	m.GetLatency.Observe(time.Since(start).Microseconds())
+}
+
+func (m *OperationMetrics) observePut(start time.Time) {
+	m.PutTotal.Increase(1)
+	m.PutLatency.Observe(time.Since(start).Microseconds())
+}
+
+func (m *OperationMetrics) observeDelete(start time.Time) {
+	m.DeleteTotal.Increase(1)
+	m.DeleteLatency.Observe(time.Since(start).Microseconds())
+}
+
+// operationMetricsByLabel keeps the operation metrics by the DMap label. A DMap
+// resolves its label once, when it's created, so the hot path doesn't touch the map.
+type operationMetricsByLabel struct {
+	mtx     sync.RWMutex
+	metrics map[string]*OperationMetrics
+}
+
+// DMapOperations is the per-DMap operation metrics of this process.
+var DMapOperations = &operationMetricsByLabel{
+	metrics: make(map[string]*OperationMetrics),
+}
+
+// metricsOf returns the metrics of the DMap. The DMap has its own label if there are
+// less than maxLabels labels, otherwise it shares the OtherMetricLabel.
+func (o *operationMetricsByLabel) metricsOf(name string, maxLabels int) *OperationMetrics {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if m, ok := o.metrics[name]; ok {
+		return m
+	}
+
+	label := name
+	labels := len(o.metrics)
+	if _, ok := o.metrics[OtherMetricLabel]; ok {
+		labels--
+	}
+	if labels >= maxLabels {
+		label = OtherMetricLabel
+	}
+
+	m, ok := o.metrics[label]
+	if !ok {
+		m = newOperationMetrics()
+		o.metrics[label] = m
+	}
+	return m
+}
+
+// Range calls f for each label and its metrics.
+func (o *operationMetricsByLabel) Range(f func(label string, m *OperationMetrics) bool) {
+	o.mtx.RLock()
+	defer o.mtx.RUnlock()
+
+	for label, m := range o.metrics {
+		if !f(label, m) {
+			break
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_OperationMetrics_MaxLabels(t *testing.T) {
+	o := &operationMetricsByLabel{
+		metrics: make(map[string]*OperationMetrics),
+	}
+
+	first := o.metricsOf("first", 2)
+	second := o.metricsOf("second", 2)
+	require.NotSame(t, first, second)
+	require.Same(t, first, o.metricsOf("first", 2))
+
+	// The DMaps beyond the limit share the other label.
+	third := o.metricsOf("third", 2)
+	fourth := o.metricsOf("fourth", 2)
+	require.Same(t, third, fourth)
+	require.Same(t, third, o.metrics[OtherMetricLabel])
+
+	third.PutTotal.Increase(1)
+	fourth.PutTotal.Increase(1)
+
+	labels := make(map[string]int64)
+	o.Range(func(label string, m *OperationMetrics) bool {
+		labels[label] = m.PutTotal.Read()
+		return true
+	})
+	require.Equal(t, map[string]int64{"first": 0, "second": 0, OtherMetricLabel: 2}, labels)
+}
+
+func TestDMap_OperationMetrics(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for _, name := range []string{"metrics-dmap-1", "metrics-dmap-2"} {
+		dm, err := s.NewDMap(name)
+		require.NoError(t, err)
+		require.NoError(t, dm.Put(ctx, "mykey", "myvalue", nil))
+		_, err = dm.Get(ctx, "mykey")
+		require.NoError(t, err)
+	}
+
+	dm, err := s.NewDMap("metrics-dmap-2")
+	require.NoError(t, err)
+	_, err = dm.Delete(ctx, "mykey")
+	require.NoError(t, err)
+
+	var labels []string
+	DMapOperations.Range(func(label string, m *OperationMetrics) bool {
+		switch label {
+		case "metrics-dmap-1":
+			require.Equal(t, int64(1), m.PutTotal.Read())
+			require.Equal(t, int64(1), m.GetTotal.Read())
+			require.Equal(t, int64(0), m.DeleteTotal.Read())
+		case "metrics-dmap-2":
+			require.Equal(t, int64(1), m.PutTotal.Read())
+			require.Equal(t, int64(1), m.GetTotal.Read())
+			require.Equal(t, int64(1), m.DeleteTotal.Read())
+			_, count, _ := m.DeleteLatency.Read()
+			require.Equal(t, int64(1), count)
+		default:
+			return true
+		}
+		labels = append(labels, label)
+		return true
+	})
+	require.ElementsMatch(t, []string{"metrics-dmap-1", "metrics-dmap-2"}, labels)
+}
@@ -0,0 +1,53 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+// preSplitTableSize returns the size of the initial table of a fragment if the DMap is
+// pre-split, otherwise zero. The expected size of the DMap is distributed over the partitions.
+func (dm *DMap) preSplitTableSize() uint64 {
+	if dm.config.preSplitSize <= 0 {
+		return 0
+	}
+	return uint64(dm.config.preSplitSize) / dm.s.config.PartitionCount
+}
+
+// preSplit creates the fragments of the partitions hosted by this member, so their tables
+// are allocated with the pre-split size before the DMap is loaded.
+func (dm *DMap) preSplit() error {
+	if dm.preSplitTableSize() == 0 {
+		return nil
+	}
+
+	this := dm.s.rt.This()
+	for partID := uint64(0); partID < dm.s.config.PartitionCount; partID++ {
+		part := dm.s.primary.PartitionByID(partID)
+		if part.Owner().CompareByName(this) {
+			if _, err := dm.loadOrCreateFragment(part); err != nil {
+				return err
+			}
+		}
+
+		backup := dm.s.backup.PartitionByID(partID)
+		for _, owner := range backup.Owners() {
+			if owner.CompareByName(this) {
+				if _, err := dm.loadOrCreateFragment(backup); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"testing"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_PreSplit(t *testing.T) {
+	const fragmentSize = 1 << 21
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.DMaps.Custom = map[string]config.DMap{
+			"mydmap": {PreSplitSize: fragmentSize * int(c.PartitionCount)},
+		}
+		return c
+	}
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	s2 := cluster.AddMember(testcluster.NewEnvironment(newConfig())).(*Service)
+	defer cluster.Shutdown()
+
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+		require.Equal(t, uint64(fragmentSize), dm.preSplitTableSize())
+	}
+
+	for _, s := range []*Service{s1, s2} {
+		dm, err := s.getDMap("mydmap")
+		require.NoError(t, err)
+		var owned int
+		for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+			part := s.primary.PartitionByID(partID)
+			if !part.Owner().CompareByName(s.rt.This()) {
+				continue
+			}
+			owned++
+			f, err := dm.loadFragment(part)
+			require.NoError(t, err)
+			require.Equal(t, fragmentSize, f.Stats().Allocated)
+		}
+		require.NotZero(t, owned)
+	}
+
+	t.Run("Not pre-split", func(t *testing.T) {
+		dm, err := s1.NewDMap("otherdmap")
+		require.NoError(t, err)
+		require.Zero(t, dm.preSplitTableSize())
+		_, err = dm.loadFragment(s1.primary.PartitionByID(0))
+		require.ErrorIs(t, err, errFragmentNotFound)
+	})
+}
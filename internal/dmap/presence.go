@@ -0,0 +1,198 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNoSuchInstance is returned by Heartbeat when the instance is expired or registered again by someone else.
+var ErrNoSuchInstance = errors.New("no such instance")
+
+// Instance is a live instance of a service registered by Register.
+type Instance struct {
+	Name string
+	Meta []byte
+	TTL  time.Duration
+}
+
+// presence is the registration of an instance. A service is stored as a hash, the fields
+// are the instances and the values are the encoded registrations.
+type presence struct {
+	token  string
+	expiry int64
+	meta   []byte
+}
+
+func (p presence) encode() string {
+	return p.token + ":" + strconv.FormatInt(p.expiry, 10) + ":" + string(p.meta)
+}
+
+func decodePresence(raw string) (presence, bool) {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) != 3 {
+		return presence{}, false
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return presence{}, false
+	}
+	return presence{token: parts[0], expiry: expiry, meta: []byte(parts[2])}, true
+}
+
+// liveInstances removes the expired instances and returns the latest expiry time.
+func liveInstances(fields map[string]string, now int64) int64 {
+	var latest int64
+	for instance, raw := range fields {
+		p, ok := decodePresence(raw)
+		if !ok || p.expiry <= now {
+			delete(fields, instance)
+			continue
+		}
+		if p.expiry > latest {
+			latest = p.expiry
+		}
+	}
+	return latest
+}
+
+// updatePresence runs on the partition owner of the service. It registers the instance if
+// register is true. Otherwise, it extends the registration if the token matches.
+func (dm *DMap) updatePresence(e *env, instance, token string, meta []byte, ttl time.Duration, register bool) error {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	fields, _, err := dm.loadHash(e)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UnixMilli()
+	latest := liveInstances(fields, now)
+	p := presence{token: token, expiry: now + ttl.Milliseconds(), meta: meta}
+	if !register {
+		current, ok := decodePresence(fields[instance])
+		if !ok || current.token != token {
+			// Expired or registered again, don't clobber the new registration.
+			return fmt.Errorf("%w: %s", ErrNoSuchInstance, instance)
+		}
+		p.meta = current.meta
+	}
+	fields[instance] = p.encode()
+	if p.expiry > latest {
+		latest = p.expiry
+	}
+	// The service expires with its last instance.
+	return dm.storeHash(e, fields, latest)
+}
+
+// presenceOnOwner runs updatePresence on the partition owner of the service.
+func (dm *DMap) presenceOnOwner(ctx context.Context, service, instance, token string, meta []byte, ttl time.Duration, register bool) error {
+	if ttl.Milliseconds() <= 0 {
+		return fmt.Errorf("%w: TTL is less than a millisecond: %s", protocol.ErrInvalidArgument, ttl)
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, service)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = service
+		return dm.updatePresence(e, instance, token, meta, ttl, register)
+	}
+
+	// Redirect to the partition owner.
+	var cmd *redis.StatusCmd
+	if register {
+		cmd = protocol.NewRegister(dm.name, service, instance, token, ttl.Milliseconds(), meta).Command(ctx)
+	} else {
+		cmd = protocol.NewHeartbeat(dm.name, service, instance, token, ttl.Milliseconds()).Command(ctx)
+	}
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// Register stores the instance of the service with its metadata. The registration expires
+// after ttl, unless it's extended by Heartbeat with the returned token. Registering the
+// instance again replaces the registration and invalidates the previous token. The services
+// are stored as hashes, the operation runs on the partition owner of the service.
+func (dm *DMap) Register(ctx context.Context, service, instance string, meta []byte, ttl time.Duration) ([]byte, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return nil, err
+	}
+
+	err = dm.presenceOnOwner(ctx, service, instance, hex.EncodeToString(token), meta, ttl, true)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Heartbeat extends the registration of the instance by ttl. It returns ErrNoSuchInstance
+// if the registration is expired or the instance is registered again with another token.
+func (dm *DMap) Heartbeat(ctx context.Context, service, instance string, token []byte, ttl time.Duration) error {
+	return dm.presenceOnOwner(ctx, service, instance, hex.EncodeToString(token), nil, ttl, false)
+}
+
+// Discover returns the live instances of the service sorted by their names. The expired
+// instances are not returned.
+func (dm *DMap) Discover(ctx context.Context, service string) ([]Instance, error) {
+	e := newEnv(ctx)
+	e.dmap = dm.name
+	e.key = service
+	fields, _, err := dm.loadHash(e)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UnixMilli()
+	liveInstances(fields, now)
+	instances := make([]Instance, 0, len(fields))
+	for name, raw := range fields {
+		p, _ := decodePresence(raw)
+		instances = append(instances, Instance{
+			Name: name,
+			Meta: p.meta,
+			TTL:  time.Duration(p.expiry-now) * time.Millisecond,
+		})
+	}
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].Name < instances[j].Name
+	})
+	return instances, nil
+}
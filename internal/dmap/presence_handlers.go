@@ -0,0 +1,90 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) registerCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	registerCmd, err := protocol.ParseRegisterCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(registerCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ttl := time.Duration(registerCmd.TTL) * time.Millisecond
+	err = dm.presenceOnOwner(s.ctx, registerCmd.Service, registerCmd.Instance, registerCmd.Token, registerCmd.Meta, ttl, true)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (s *Service) heartbeatCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	heartbeatCmd, err := protocol.ParseHeartbeatCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(heartbeatCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ttl := time.Duration(heartbeatCmd.TTL) * time.Millisecond
+	err = dm.presenceOnOwner(s.ctx, heartbeatCmd.Service, heartbeatCmd.Instance, heartbeatCmd.Token, nil, ttl, false)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
+
+func (s *Service) discoverCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	discoverCmd, err := protocol.ParseDiscoverCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(discoverCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	instances, err := dm.Discover(s.ctx, discoverCmd.Service)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(instances) * 3)
+	for _, instance := range instances {
+		conn.WriteBulkString(instance.Name)
+		conn.WriteBulk(instance.Meta)
+		conn.WriteInt64(instance.TTL.Milliseconds())
+	}
+}
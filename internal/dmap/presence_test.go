@@ -0,0 +1,146 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Presence(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("services")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("services")
+	require.NoError(t, err)
+
+	ttl := 300 * time.Millisecond
+	tokenA, err := dm1.Register(ctx, "api", "instance-a", []byte("10.0.0.1:8080"), ttl)
+	require.NoError(t, err)
+	tokenB, err := dm2.Register(ctx, "api", "instance-b", []byte("10.0.0.2:8080"), ttl)
+	require.NoError(t, err)
+	_, err = dm1.Register(ctx, "api", "instance-c", nil, time.Minute)
+	require.NoError(t, err)
+
+	for _, dm := range []*DMap{dm1, dm2} {
+		instances, err := dm.Discover(ctx, "api")
+		require.NoError(t, err)
+		require.Len(t, instances, 3)
+		require.Equal(t, "instance-a", instances[0].Name)
+		require.Equal(t, []byte("10.0.0.1:8080"), instances[0].Meta)
+		require.Greater(t, instances[0].TTL, time.Duration(0))
+		require.LessOrEqual(t, instances[0].TTL, ttl)
+	}
+
+	// Instance B stops its heartbeats and ages out, instance A stays alive.
+	for i := 0; i < 6; i++ {
+		<-time.After(100 * time.Millisecond)
+		require.NoError(t, dm2.Heartbeat(ctx, "api", "instance-a", tokenA, ttl))
+	}
+
+	instances, err := dm2.Discover(ctx, "api")
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	require.Equal(t, "instance-a", instances[0].Name)
+	require.Equal(t, []byte("10.0.0.1:8080"), instances[0].Meta)
+	require.Equal(t, "instance-c", instances[1].Name)
+
+	err = dm1.Heartbeat(ctx, "api", "instance-b", tokenB, ttl)
+	require.ErrorIs(t, err, ErrNoSuchInstance)
+
+	instances, err = dm1.Discover(ctx, "unknown")
+	require.NoError(t, err)
+	require.Empty(t, instances)
+}
+
+func TestDMap_Presence_RegisterAgain(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("services")
+	require.NoError(t, err)
+
+	oldToken, err := dm.Register(ctx, "api", "instance-a", []byte("old"), time.Minute)
+	require.NoError(t, err)
+	newToken, err := dm.Register(ctx, "api", "instance-a", []byte("new"), time.Minute)
+	require.NoError(t, err)
+
+	// The stale heartbeat doesn't clobber the new registration.
+	err = dm.Heartbeat(ctx, "api", "instance-a", oldToken, time.Millisecond)
+	require.ErrorIs(t, err, ErrNoSuchInstance)
+	require.NoError(t, dm.Heartbeat(ctx, "api", "instance-a", newToken, time.Minute))
+
+	instances, err := dm.Discover(ctx, "api")
+	require.NoError(t, err)
+	require.Len(t, instances, 1)
+	require.Equal(t, []byte("new"), instances[0].Meta)
+}
+
+func TestDMap_registerCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the service.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "myservice")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	// Hold the fine-grained lock of the service on the owner. The command must wait
+	// for it and keep the instance registered in the meantime.
+	owner.s.locker.Lock("mydmap" + "myservice")
+	result := make(chan error, 1)
+	go func() {
+		cmd := protocol.NewRegister("mydmap", "myservice", "instance-2", "token-2", time.Minute.Milliseconds(), nil).Command(ctx)
+		rc := other.client.Get(other.rt.This().String())
+		_ = rc.Process(ctx, cmd)
+		result <- cmd.Err()
+	}()
+
+	<-time.After(100 * time.Millisecond)
+	p := presence{token: "token-1", expiry: time.Now().Add(time.Minute).UnixMilli()}
+	raw, err := encodeHash(map[string]string{"instance-1": p.encode()})
+	require.NoError(t, err)
+	require.NoError(t, owner.Put(ctx, "myservice", raw, nil))
+	require.NoError(t, owner.s.locker.Unlock("mydmap"+"myservice"))
+	require.NoError(t, <-result)
+
+	instances, err := owner.Discover(ctx, "myservice")
+	require.NoError(t, err)
+	require.Len(t, instances, 2)
+	require.Equal(t, "instance-1", instances[0].Name)
+	require.Equal(t, "instance-2", instances[1].Name)
+}
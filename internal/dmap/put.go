@@ -18,6 +18,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/buraksezer/olric/config"
@@ -27,6 +28,7 @@ import (
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/resp"
 	"github.com/buraksezer/olric/internal/stats"
+	"github.com/buraksezer/olric/internal/wal"
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/redis/go-redis/v9"
 )
@@ -37,6 +39,14 @@ var pool = bufpool.New()
 // stored during the life of this instance.
 var EntriesTotal = stats.NewInt64Counter()
 
+var (
+	// KeySizes is the histogram of the key sizes in bytes observed on the put path.
+	KeySizes = stats.NewInt64Histogram(8, 16, 32, 64, 128, 256)
+
+	// ValueSizes is the histogram of the value sizes in bytes observed on the put path.
+	ValueSizes = stats.NewInt64Histogram(16, 64, 256, 1<<10, 4<<10, 16<<10, 64<<10, 256<<10, 1<<20, 4<<20, 16<<20)
+)
+
 var (
 	ErrKeyFound      = errors.New("key found")
 	ErrWriteQuorum   = errors.New("write quorum cannot be reached")
@@ -44,6 +54,12 @@ var (
 	ErrEntryTooLarge = errors.New("entry too large for the configured table size")
 )
 
+// entryTooLarge converts the storage error to ErrEntryTooLarge, it keeps the key and the
+// required size.
+func entryTooLarge(err error) error {
+	return fmt.Errorf("%w%s", ErrEntryTooLarge, strings.TrimPrefix(err.Error(), storage.ErrEntryTooLarge.Error()))
+}
+
 func prepareTTL(e *env) int64 {
 	var ttl int64
 	switch {
@@ -66,6 +82,7 @@ func prepareTTL(e *env) int64 {
 
 // putOnFragment calls underlying storage engine's Put method to store the key/value pair. It's not thread-safe.
 func (dm *DMap) putEntryOnFragment(e *env, nt storage.Entry) error {
+	prev := dm.walPreviousEntry(e)
 	if e.putConfig.OnlyUpdateTTL {
 		err := e.fragment.storage.UpdateTTL(e.hkey, nt)
 		if err != nil {
@@ -74,6 +91,12 @@ func (dm *DMap) putEntryOnFragment(e *env, nt storage.Entry) error {
 			}
 			return err
 		}
+		if err = dm.appendWAL(wal.OpExpire, e.key, nil, nt.TTL()); err != nil {
+			dm.restoreWALPreviousEntry(e, prev)
+			return err
+		}
+		// The replicas don't know the new TTL.
+		dm.gossipDelete(e.key)
 		return nil
 	}
 	err := e.fragment.storage.Put(e.hkey, nt)
@@ -81,25 +104,45 @@ func (dm *DMap) putEntryOnFragment(e *env, nt storage.Entry) error {
 		err = ErrKeyTooLarge
 	}
 	if errors.Is(err, storage.ErrEntryTooLarge) {
-		err = ErrEntryTooLarge
+		err = entryTooLarge(err)
 	}
 	if err != nil {
 		return err
 	}
+	if err = dm.appendWAL(wal.OpPut, e.key, e.value, nt.TTL()); err != nil {
+		dm.restoreWALPreviousEntry(e, prev)
+		return err
+	}
 
 	// total number of entries stored during the life of this instance.
 	EntriesTotal.Increase(1)
+	KeySizes.Observe(int64(len(nt.Key())))
+	ValueSizes.Observe(int64(len(nt.Value())))
+
+	dm.publishPut(nt)
 
 	return nil
 }
 
-func (dm *DMap) prepareEntry(e *env) storage.Entry {
+func (dm *DMap) prepareEntry(e *env) (storage.Entry, error) {
+	value := e.value
+	if !e.putConfig.OnlyUpdateTTL {
+		var err error
+		value, err = dm.encryptValue(e.key, e.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	nt := e.fragment.storage.NewEntry()
 	nt.SetKey(e.key)
-	nt.SetValue(e.value)
+	nt.SetValue(value)
 	nt.SetTTL(prepareTTL(e))
 	nt.SetTimestamp(e.timestamp)
-	return nt
+	if ve, ok := nt.(storage.VersionedEntry); ok {
+		ve.SetVersion(e.version)
+	}
+	return nt, nil
 }
 
 func (dm *DMap) putOnReplicaFragment(e *env) error {
@@ -118,7 +161,7 @@ func (dm *DMap) putOnReplicaFragment(e *env) error {
 		err = ErrKeyTooLarge
 	}
 	if errors.Is(err, storage.ErrEntryTooLarge) {
-		err = ErrEntryTooLarge
+		err = entryTooLarge(err)
 	}
 	if err != nil {
 		return err
@@ -130,6 +173,32 @@ func (dm *DMap) putOnReplicaFragment(e *env) error {
 	return nil
 }
 
+// updateTTLOnReplicaFragment updates the TTL and the timestamp of an entry on the replica
+// fragment in place, the value is not rewritten.
+func (dm *DMap) updateTTLOnReplicaFragment(e *env, ttl int64) error {
+	part := dm.getPartitionByHKey(e.hkey, partitions.BACKUP)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return ErrKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	nt := f.storage.NewEntry()
+	nt.SetKey(e.key)
+	nt.SetTTL(ttl)
+	nt.SetTimestamp(e.timestamp)
+	err = f.storage.UpdateTTL(e.hkey, nt)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		err = ErrKeyNotFound
+	}
+	return err
+}
+
 func (dm *DMap) asyncPutOnBackup(e *env, data []byte, owner discovery.Member) {
 	defer dm.s.wg.Done()
 
@@ -271,6 +340,17 @@ func (dm *DMap) checkPutConditions(e *env) error {
 		}
 	}
 
+	// An expired key that has not been evicted yet must not be persisted.
+	if e.putConfig.Persist {
+		ttl, err := e.fragment.storage.GetTTL(e.hkey)
+		if errors.Is(err, storage.ErrKeyNotFound) || (err == nil && isKeyExpired(ttl)) {
+			return ErrKeyNotFound
+		}
+		if err != nil {
+			return err
+		}
+	}
+
 	// Only set the key if it already exists.
 	if e.putConfig.HasXX && !e.fragment.storage.Check(e.hkey) {
 		ttl, err := e.fragment.storage.GetTTL(e.hkey)
@@ -297,15 +377,35 @@ func (dm *DMap) putOnCluster(e *env) error {
 	}
 
 	e.fragment = f
-	f.Lock()
+	if err = f.lockContext(e.ctx); err != nil {
+		return err
+	}
+	if dm.isWriteFenced(e.hkey, f) {
+		f.Unlock()
+		// The partition has been moved, forward the write to the current owner.
+		return dm.put(e)
+	}
 	defer f.Unlock()
 
+	return dm.putOnFragment(e)
+}
+
+// putOnFragment writes the entry on the primary copy and replicates it. e.fragment has to
+// be locked by the caller.
+func (dm *DMap) putOnFragment(e *env) error {
+	var err error
+	if !e.putConfig.OnlyUpdateTTL {
+		if err = dm.config.checkKeySchema(e.key); err != nil {
+			return err
+		}
+	}
+
 	if err = dm.checkPutConditions(e); err != nil {
 		return err
 	}
 
 	if dm.config != nil {
-		if dm.config.ttlDuration.Seconds() != 0 && e.timeout.Seconds() == 0 {
+		if dm.config.ttlDuration.Seconds() != 0 && e.timeout.Seconds() == 0 && !e.putConfig.Persist {
 			e.timeout = dm.config.ttlDuration
 		}
 		if dm.config.evictionPolicy == config.LRUEviction {
@@ -315,7 +415,10 @@ func (dm *DMap) putOnCluster(e *env) error {
 		}
 	}
 
-	nt := dm.prepareEntry(e)
+	nt, err := dm.prepareEntry(e)
+	if err != nil {
+		return err
+	}
 	if dm.s.config.ReplicaCount > config.MinimumReplicaCount {
 		switch dm.s.config.ReplicationMode {
 		case config.AsyncReplicationMode:
@@ -335,6 +438,14 @@ func (dm *DMap) putOnCluster(e *env) error {
 }
 
 func (dm *DMap) writePutCommand(e *env) (*redis.StatusCmd, error) {
+	if e.putConfig.Persist {
+		return protocol.NewPersist(e.dmap, e.key).Command(dm.s.ctx), nil
+	}
+	if e.putConfig.OnlyUpdateTTL {
+		// Only the TTL is updated on the partition owner, the value is not sent.
+		return protocol.NewPExpire(e.dmap, e.key, e.timeout).Command(dm.s.ctx), nil
+	}
+
 	cmd := protocol.NewPut(e.dmap, e.key, e.value)
 	switch {
 	case e.putConfig.HasEX:
@@ -392,6 +503,8 @@ type PutConfig struct {
 	HasNX         bool
 	HasXX         bool
 	OnlyUpdateTTL bool
+	// Persist removes the TTL of the key, it's only valid with OnlyUpdateTTL.
+	Persist bool
 }
 
 // Put sets the value for the given key. It overwrites any previous value
@@ -399,6 +512,9 @@ type PutConfig struct {
 // is arbitrary. It is safe to modify the contents of the arguments after
 // Put returns but not before.
 func (dm *DMap) Put(ctx context.Context, key string, value interface{}, cfg *PutConfig) error {
+	defer dm.metrics.observePut(time.Now())
+	defer dm.observeSlow(protocol.DMap.Put, time.Now(), key)
+
 	valueBuf := pool.Get()
 	defer pool.Put(valueBuf)
 
@@ -411,11 +527,14 @@ func (dm *DMap) Put(ctx context.Context, key string, value interface{}, cfg *Put
 	if cfg == nil {
 		cfg = &PutConfig{}
 	}
-	e := newEnv(ctx)
-	e.putConfig = cfg
-	e.dmap = dm.name
-	e.key = key
-	e.value = make([]byte, valueBuf.Len())
-	copy(e.value[:], valueBuf.Bytes())
-	return dm.put(e)
+	raw := make([]byte, valueBuf.Len())
+	copy(raw, valueBuf.Bytes())
+	return RunWithTimeout(ctx, dm.config.writeTimeout, func(ctx context.Context) error {
+		e := newEnv(ctx)
+		e.putConfig = cfg
+		e.dmap = dm.name
+		e.key = key
+		e.value = raw
+		return dm.put(e)
+	})
 }
@@ -0,0 +1,85 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+func (dm *DMap) putAllIfNoneExistOnOwner(ctx context.Context, pairs map[string][]byte) (bool, error) {
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	unlock := dm.lockKeys(keys)
+	defer unlock()
+
+	for _, key := range keys {
+		_, err := dm.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		// The key already exists, write nothing.
+		return false, nil
+	}
+
+	// All keys are absent, a failed write deletes the already written ones.
+	writes := make([]keyWrite, 0, len(keys))
+	for _, key := range keys {
+		writes = append(writes, keyWrite{key: key, value: pairs[key]})
+	}
+	if err := dm.applyWrites(ctx, writes); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PutAllIfNoneExist sets all the given keys only if none of them exists. It returns false
+// without writing anything if any of the keys already exists. All keys must belong to the
+// same partition owner, otherwise it returns ErrCrossOwnerKeys. The keys are locked on the
+// owner during the operation, so concurrent calls on overlapping keys are serialized. If
+// a write fails, the already written keys are deleted and the error is returned.
+func (dm *DMap) PutAllIfNoneExist(ctx context.Context, pairs map[string][]byte) (bool, error) {
+	if len(pairs) == 0 {
+		return true, nil
+	}
+
+	keys := make([]string, 0, len(pairs))
+	for key := range pairs {
+		keys = append(keys, key)
+	}
+	owner, err := dm.keysOwner(keys)
+	if err != nil {
+		return false, err
+	}
+	if owner.CompareByName(dm.s.rt.This()) {
+		return dm.putAllIfNoneExistOnOwner(ctx, pairs)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewPutAllIfNoneExist(dm.name, pairs).Command(ctx)
+	rc := dm.s.client.Get(owner.String())
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	return cmd.Val() == 1, nil
+}
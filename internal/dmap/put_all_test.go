@@ -0,0 +1,110 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_PutAllIfNoneExist(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	keys, separated := findKeys(s1, "mydmap")
+
+	// The keys are owned by the same member, the other member redirects the call.
+	for _, dm := range []*DMap{dm1, dm2} {
+		pairs := map[string][]byte{
+			keys[0]: []byte(dm.s.rt.This().String()),
+			keys[1]: []byte(dm.s.rt.This().String()),
+		}
+		written, err := dm.PutAllIfNoneExist(ctx, pairs)
+		require.NoError(t, err)
+		if dm == dm1 {
+			require.True(t, written)
+		} else {
+			require.False(t, written)
+		}
+	}
+
+	for _, key := range keys {
+		e, err := dm2.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, s1.rt.This().String(), string(e.Value()))
+	}
+
+	t.Run("Write nothing if any key exists", func(t *testing.T) {
+		_, err := dm1.Delete(ctx, keys[0])
+		require.NoError(t, err)
+
+		written, err := dm2.PutAllIfNoneExist(ctx, map[string][]byte{
+			keys[0]: []byte("new-value"),
+			keys[1]: []byte("new-value"),
+		})
+		require.NoError(t, err)
+		require.False(t, written)
+
+		_, err = dm1.Get(ctx, keys[0])
+		require.ErrorIs(t, err, ErrKeyNotFound)
+
+		e, err := dm1.Get(ctx, keys[1])
+		require.NoError(t, err)
+		require.Equal(t, s1.rt.This().String(), string(e.Value()))
+	})
+
+	t.Run("Write nothing if a write fails", func(t *testing.T) {
+		_, err := dm1.Delete(ctx, keys[1])
+		require.NoError(t, err)
+
+		// One of the values exceeds the table size of the storage engine.
+		large := make([]byte, 2<<20)
+		_, err = dm2.PutAllIfNoneExist(ctx, map[string][]byte{
+			keys[0]: []byte("value"),
+			keys[1]: large,
+		})
+		require.ErrorIs(t, err, ErrEntryTooLarge)
+
+		_, err = dm2.PutAllIfNoneExist(ctx, map[string][]byte{
+			keys[0]: large,
+			keys[1]: []byte("value"),
+		})
+		require.ErrorIs(t, err, ErrEntryTooLarge)
+
+		for _, key := range keys {
+			_, err = dm1.Get(ctx, key)
+			require.ErrorIs(t, err, ErrKeyNotFound)
+		}
+	})
+
+	t.Run("Reject keys on different owners", func(t *testing.T) {
+		_, err := dm1.PutAllIfNoneExist(ctx, map[string][]byte{
+			separated[0]: []byte("value"),
+			separated[1]: []byte("value"),
+		})
+		require.ErrorIs(t, err, ErrCrossOwnerKeys)
+	})
+}
@@ -33,6 +33,8 @@ func (s *Service) putCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		protocol.WriteError(conn, err)
 		return
 	}
+	defer dm.metrics.observePut(time.Now())
+	defer dm.observeSlow(protocol.DMap.Put, time.Now(), putCmd.Key)
 
 	var pc PutConfig
 	switch {
@@ -40,6 +42,9 @@ func (s *Service) putCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 		pc.HasNX = true
 	case putCmd.XX:
 		pc.HasXX = true
+	}
+
+	switch {
 	case putCmd.EX != 0:
 		pc.HasEX = true
 		pc.EX = time.Duration(putCmd.EX * float64(time.Second))
@@ -92,3 +97,29 @@ func (s *Service) putEntryCommandHandler(conn redcon.Conn, cmd redcon.Command) {
 	}
 	conn.WriteString(protocol.StatusOK)
 }
+
+func (s *Service) updateEntryTTLCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	updateCmd, err := protocol.ParseUpdateEntryTTLCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.getOrCreateDMap(updateCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	e := newEnv(s.ctx)
+	e.hkey = partitions.HKey(updateCmd.DMap, updateCmd.Key)
+	e.dmap = updateCmd.DMap
+	e.key = updateCmd.Key
+	e.timestamp = updateCmd.Timestamp
+	err = dm.updateTTLOnReplicaFragment(e, updateCmd.TTL)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
@@ -0,0 +1,119 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// ErrNotAnObject is returned when a field operation is called on a key whose value is
+// neither a hash nor a JSON object.
+var ErrNotAnObject = errors.New("value is not an object")
+
+// objectField returns the value of the field of an object. The object is either a hash or
+// a JSON object. The string fields of a JSON object are unquoted, the other fields are
+// returned as JSON. ok is false if the object doesn't have the field.
+func objectField(raw []byte, field string) (value []byte, ok bool, err error) {
+	if fields, err := decodeHash(raw); err == nil {
+		v, ok := fields[field]
+		return []byte(v), ok, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, false, ErrNotAnObject
+	}
+	v, ok := fields[field]
+	if !ok {
+		return nil, false, nil
+	}
+	var s string
+	if err := json.Unmarshal(v, &s); err == nil {
+		return []byte(s), true, nil
+	}
+	return v, true, nil
+}
+
+// putIfField runs on the partition owner of the key.
+func (dm *DMap) putIfField(e *env, field string, expected []byte) (bool, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	entry, err := dm.Get(e.ctx, e.key)
+	if err != nil {
+		return false, err
+	}
+	current, ok, err := objectField(entry.Value(), field)
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", err, e.key)
+	}
+	if !ok || !bytes.Equal(current, expected) {
+		return false, nil
+	}
+
+	if entry.TTL() != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Until(time.UnixMilli(entry.TTL()))
+	}
+	if err = dm.put(e); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PutIfField atomically sets the value of the key if the field of the stored object is
+// equal to expected, and returns true if the value is written. It's useful to implement
+// state transitions without a lock. The stored object is either a hash or a JSON object,
+// the string fields of a JSON object are compared without the quotes. It returns false
+// if the object doesn't have the field, ErrKeyNotFound if the key does not exist and
+// ErrNotAnObject if the value is not an object. The TTL of the key is preserved. The
+// operation runs on the partition owner of the key.
+func (dm *DMap) PutIfField(ctx context.Context, key, field string, expected, value []byte) (bool, error) {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		e.value = value
+		return dm.putIfField(e, field, expected)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewPutIfField(dm.name, key, field, expected, value).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	written, err := cmd.Result()
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	return written == 1, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_PutIfField(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		err = dm1.Put(ctx, key, []byte(`{"id": 1, "state": "pending"}`), nil)
+		require.NoError(t, err)
+
+		written, err := dm2.PutIfField(ctx, key, "state", []byte("pending"), []byte(`{"id": 1, "state": "active"}`))
+		require.NoError(t, err)
+		require.True(t, written)
+
+		// The state is already changed.
+		written, err = dm1.PutIfField(ctx, key, "state", []byte("pending"), []byte(`{"id": 1, "state": "cancelled"}`))
+		require.NoError(t, err)
+		require.False(t, written)
+
+		value, err := dm1.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, `{"id": 1, "state": "active"}`, string(value.Value()))
+	}
+
+	t.Run("Hash", func(t *testing.T) {
+		err := dm1.HMSet(ctx, "myhash", map[string][]byte{"state": []byte("pending")})
+		require.NoError(t, err)
+
+		written, err := dm2.PutIfField(ctx, "myhash", "state", []byte("pending"), []byte("done"))
+		require.NoError(t, err)
+		require.True(t, written)
+	})
+
+	t.Run("Missing field", func(t *testing.T) {
+		err := dm1.Put(ctx, "nofield", []byte(`{"id": 1}`), nil)
+		require.NoError(t, err)
+
+		written, err := dm2.PutIfField(ctx, "nofield", "state", []byte("pending"), []byte("value"))
+		require.NoError(t, err)
+		require.False(t, written)
+	})
+
+	t.Run("Not an object", func(t *testing.T) {
+		err := dm1.Put(ctx, "string", []byte("value"), nil)
+		require.NoError(t, err)
+
+		_, err = dm2.PutIfField(ctx, "string", "state", []byte("pending"), []byte("value"))
+		require.ErrorIs(t, err, ErrNotAnObject)
+	})
+
+	t.Run("Key not found", func(t *testing.T) {
+		_, err := dm2.PutIfField(ctx, "absent", "state", []byte("pending"), []byte("value"))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+}
+
+func TestDMap_putIfFieldCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the key.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mykey")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+	require.NoError(t, owner.Put(ctx, "mykey", []byte(`{"state": "pending"}`), nil))
+
+	// Hold the fine-grained lock of the key on the owner. The command must wait for
+	// it and see the state written in the meantime.
+	owner.s.locker.Lock("mydmap" + "mykey")
+	result := make(chan *redis.IntCmd, 1)
+	go func() {
+		cmd := protocol.NewPutIfField("mydmap", "mykey", "state", []byte("pending"), []byte(`{"state": "active"}`)).Command(ctx)
+		rc := other.client.Get(other.rt.This().String())
+		_ = rc.Process(ctx, cmd)
+		result <- cmd
+	}()
+
+	<-time.After(100 * time.Millisecond)
+	require.NoError(t, owner.Put(ctx, "mykey", []byte(`{"state": "cancelled"}`), nil))
+	require.NoError(t, owner.s.locker.Unlock("mydmap"+"mykey"))
+
+	cmd := <-result
+	written, err := cmd.Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), written)
+
+	value, err := owner.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, `{"state": "cancelled"}`, string(value.Value()))
+}
@@ -0,0 +1,106 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"golang.org/x/sync/errgroup"
+)
+
+// localPutMany writes the pairs one by one, like Put. It stops at the first failure.
+func (dm *DMap) localPutMany(ctx context.Context, keys []string, values [][]byte, cfg *PutConfig) error {
+	for i, key := range keys {
+		e := newEnv(ctx)
+		e.putConfig = cfg
+		e.dmap = dm.name
+		e.key = key
+		e.value = values[i]
+		if err := dm.put(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (dm *DMap) writePutManyCommand(cfg *PutConfig, keys []string, values [][]byte) *protocol.PutMany {
+	cmd := protocol.NewPutMany(dm.name)
+	for i, key := range keys {
+		cmd.Add(key, values[i])
+	}
+
+	switch {
+	case cfg.HasEX:
+		cmd.SetEX(cfg.EX.Seconds())
+	case cfg.HasPX:
+		cmd.SetPX(cfg.PX.Milliseconds())
+	case cfg.HasEXAT:
+		cmd.SetEXAT(cfg.EXAT.Seconds())
+	case cfg.HasPXAT:
+		cmd.SetPXAT(cfg.PXAT.Milliseconds())
+	}
+
+	switch {
+	case cfg.HasNX:
+		cmd.SetNX()
+	case cfg.HasXX:
+		cmd.SetXX()
+	}
+
+	return cmd.SetLocal()
+}
+
+// PutMany sets the values of the keys with the same options. The pairs are grouped by
+// their partition owners and every owner receives one request, concurrently.
+//
+// Every pair is written like Put, the batch is not atomic: a failed pair, for example
+// an existing key with NX, stops the rest of the batch on its owner, and the pairs that
+// were already written, on that owner or on the other owners, are kept.
+func (dm *DMap) PutMany(ctx context.Context, pairs map[string][]byte, cfg *PutConfig) error {
+	if cfg == nil {
+		cfg = &PutConfig{}
+	}
+
+	owners := make(map[string]discovery.Member)
+	keys := make(map[string][]string)
+	values := make(map[string][][]byte)
+	for key, value := range pairs {
+		owner := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+		owners[owner.String()] = owner
+		keys[owner.String()] = append(keys[owner.String()], key)
+		values[owner.String()] = append(values[owner.String()], value)
+	}
+
+	var g errgroup.Group
+	for name, owner := range owners {
+		owner, groupKeys, groupValues := owner, keys[name], values[name]
+		g.Go(func() error {
+			if owner.CompareByID(dm.s.rt.This()) {
+				return dm.localPutMany(ctx, groupKeys, groupValues, cfg)
+			}
+
+			cmd := dm.writePutManyCommand(cfg, groupKeys, groupValues).Command(ctx)
+			rc := dm.s.client.Get(owner.String())
+			if err := rc.Process(ctx, cmd); err != nil {
+				return protocol.ConvertError(err)
+			}
+			return protocol.ConvertError(cmd.Err())
+		})
+	}
+	return g.Wait()
+}
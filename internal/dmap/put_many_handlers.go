@@ -0,0 +1,73 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) putManyCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	putManyCmd, err := protocol.ParsePutManyCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(putManyCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	var pc PutConfig
+	switch {
+	case putManyCmd.NX:
+		pc.HasNX = true
+	case putManyCmd.XX:
+		pc.HasXX = true
+	}
+
+	switch {
+	case putManyCmd.EX != 0:
+		pc.HasEX = true
+		pc.EX = time.Duration(putManyCmd.EX * float64(time.Second))
+	case putManyCmd.PX != 0:
+		pc.HasPX = true
+		pc.PX = time.Duration(putManyCmd.PX * int64(time.Millisecond))
+	case putManyCmd.EXAT != 0:
+		pc.HasEXAT = true
+		pc.EXAT = time.Duration(putManyCmd.EXAT * float64(time.Second))
+	case putManyCmd.PXAT != 0:
+		pc.HasPXAT = true
+		pc.PXAT = time.Duration(putManyCmd.PXAT * int64(time.Millisecond))
+	}
+
+	if putManyCmd.Local {
+		err = dm.localPutMany(s.ctx, putManyCmd.Keys, putManyCmd.Values, &pc)
+	} else {
+		pairs := make(map[string][]byte)
+		for i, key := range putManyCmd.Keys {
+			pairs[key] = putManyCmd.Values[i]
+		}
+		err = dm.PutMany(s.ctx, pairs, &pc)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
@@ -0,0 +1,71 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_PutMany(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	pairs := make(map[string][]byte)
+	partIDs := make(map[uint64]struct{})
+	for i := 0; i < 100; i++ {
+		key := testutil.ToKey(i)
+		pairs[key] = testutil.ToVal(i)
+		partIDs[partitions.HKey("mydmap", key)%s1.config.PartitionCount] = struct{}{}
+	}
+	require.Greater(t, len(partIDs), 1)
+	require.NoError(t, dm1.PutMany(ctx, pairs, &PutConfig{
+		HasPX: true,
+		PX:    time.Hour,
+	}))
+
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+	for key, value := range pairs {
+		entry, err := dm2.Get(ctx, key)
+		require.NoError(t, err)
+		require.Equal(t, value, entry.Value())
+
+		ttl, err := dm2.TTL(ctx, key)
+		require.NoError(t, err)
+		require.Greater(t, ttl, 59*time.Minute)
+	}
+
+	t.Run("NX", func(t *testing.T) {
+		err := dm2.PutMany(ctx, map[string][]byte{testutil.ToKey(1): []byte("new-value")}, &PutConfig{HasNX: true})
+		require.ErrorIs(t, err, ErrKeyFound)
+
+		entry, err := dm1.Get(ctx, testutil.ToKey(1))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(1), entry.Value())
+	})
+}
@@ -18,7 +18,9 @@ import (
 	"context"
 	"encoding/hex"
 	"errors"
+	"io"
 	"math/rand"
+	"os"
 	"testing"
 	"time"
 
@@ -26,6 +28,7 @@ import (
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/testcluster"
 	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/internal/wal"
 	"github.com/stretchr/testify/require"
 )
 
@@ -210,6 +213,34 @@ func TestDMap_Put_NX(t *testing.T) {
 	}
 }
 
+func TestDMap_Put_NX_EX(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	cluster.AddMember(nil)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Some of the keys are owned by the other member, so the command is sent
+	// over the network with both of the options.
+	pc := &PutConfig{
+		HasNX: true,
+		HasEX: true,
+		EX:    time.Hour,
+	}
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc))
+	}
+
+	for i := 0; i < 10; i++ {
+		gr, err := dm.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.NotZero(t, gr.TTL())
+	}
+}
+
 func TestDMap_Put_XX(t *testing.T) {
 	cluster := testcluster.New(NewService)
 	s := cluster.AddMember(nil).(*Service)
@@ -363,3 +394,100 @@ func TestDMap_Put_ErrEntryTooLarge(t *testing.T) {
 	err = dm.Put(ctx, "key", data, nil)
 	require.ErrorIs(t, err, ErrEntryTooLarge)
 }
+
+func TestDMap_Put_HashTags(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.HashTags = true
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	c2 := testutil.NewConfig()
+	c2.HashTags = true
+	cluster.AddMember(testcluster.NewEnvironment(c2))
+	defer cluster.Shutdown()
+
+	dm, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	owner := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, "user:1")).Owner()
+	for i := 0; i < 10; i++ {
+		key := "{user:1}:" + testutil.ToKey(i)
+		// All keys with the same tag are stored on the same member.
+		require.True(t, owner.CompareByName(dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()))
+		require.NoError(t, dm.Put(ctx, key, testutil.ToVal(i), nil))
+	}
+
+	for i := 0; i < 10; i++ {
+		gr, err := dm.Get(ctx, "{user:1}:"+testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), gr.Value())
+	}
+}
+
+func TestDMap_Put_WAL(t *testing.T) {
+	readWAL := func(t *testing.T, dir string) []*wal.Record {
+		r, err := wal.NewReader(dir)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, r.Close())
+		}()
+
+		var records []*wal.Record
+		for {
+			record, err := r.Next()
+			if errors.Is(err, io.EOF) {
+				return records
+			}
+			require.NoError(t, err)
+			records = append(records, record)
+		}
+	}
+
+	newService := func(t *testing.T) (*Service, string) {
+		cluster := testcluster.New(NewService)
+		t.Cleanup(cluster.Shutdown)
+
+		c := testutil.NewConfig()
+		c.DMaps.WALDir = t.TempDir()
+		c.DMaps.WALSync = "always"
+		s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+		return s, c.DMaps.WALDir
+	}
+
+	t.Run("Rejected write", func(t *testing.T) {
+		s, dir := newService(t)
+		ctx := context.Background()
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+
+		require.NoError(t, dm.Put(ctx, "key", []byte("value"), nil))
+		err = dm.Put(ctx, "key", make([]byte, 1<<21), nil)
+		require.ErrorIs(t, err, ErrEntryTooLarge)
+
+		records := readWAL(t, dir)
+		require.Len(t, records, 1)
+		require.Equal(t, "key", records[0].Key)
+	})
+
+	t.Run("Write that cannot be logged", func(t *testing.T) {
+		s, _ := newService(t)
+		ctx := context.Background()
+		dm, err := s.NewDMap("mydmap")
+		require.NoError(t, err)
+
+		require.NoError(t, dm.Put(ctx, "key", []byte("value"), nil))
+		require.NoError(t, s.wal.Close())
+
+		err = dm.Put(ctx, "key", []byte("updated"), nil)
+		require.ErrorIs(t, err, os.ErrClosed)
+		err = dm.Put(ctx, "new-key", []byte("value"), nil)
+		require.ErrorIs(t, err, os.ErrClosed)
+
+		gr, err := dm.Get(ctx, "key")
+		require.NoError(t, err)
+		require.Equal(t, []byte("value"), gr.Value())
+
+		_, err = dm.Get(ctx, "new-key")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/resp"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// PreviousKeySuffix is appended to the key to derive the key of the previous value in Rotate.
+const PreviousKeySuffix = ":previous"
+
+// rotate runs on the partition owner of the key. The previous value is written before
+// the new one, so the old value is always retrievable once the new value is visible.
+func (dm *DMap) rotate(e *env, graceTTL time.Duration) (storage.Entry, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	old, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if old != nil {
+		// The derived key may belong to another partition owner.
+		pe := newEnv(e.ctx)
+		pe.dmap = e.dmap
+		pe.key = e.key + PreviousKeySuffix
+		pe.value = old.Value()
+		pe.putConfig.HasPX = true
+		pe.putConfig.PX = graceTTL
+		if err = dm.put(pe); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = dm.put(e); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+// Rotate atomically sets the key to value and returns the old value. The old value is
+// copied to the key with PreviousKeySuffix, it expires after graceTTL. It's useful to
+// rotate a secret without downtime, the old secret is still valid in the grace period.
+// There is no previous value if the key doesn't exist, it returns nil in that case. The
+// operation runs on the partition owner of the key.
+func (dm *DMap) Rotate(ctx context.Context, key string, value interface{}, graceTTL time.Duration) (storage.Entry, error) {
+	if graceTTL <= 0 {
+		return nil, fmt.Errorf("%w: non-positive grace TTL: %s", protocol.ErrInvalidArgument, graceTTL)
+	}
+
+	valueBuf := pool.Get()
+	defer pool.Put(valueBuf)
+
+	enc := resp.New(valueBuf)
+	err := enc.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		e.value = make([]byte, valueBuf.Len())
+		copy(e.value, valueBuf.Bytes())
+		return dm.rotate(e, graceTTL)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewRotate(dm.name, key, valueBuf.Bytes(), graceTTL.Milliseconds()).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err = rc.Process(ctx, cmd)
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	raw, err := cmd.Bytes()
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	entry := dm.engine.NewEntry()
+	entry.Decode(raw)
+	return entry, nil
+}
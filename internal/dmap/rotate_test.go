@@ -0,0 +1,115 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Rotate(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Call Rotate on the member that doesn't own the key, it's redirected to the owner.
+	dm := dm1
+	if s1.primary.PartitionByHKey(partitions.HKey("mydmap", "secret")).Owner().CompareByID(s1.rt.This()) {
+		dm = dm2
+	}
+
+	old, err := dm.Rotate(ctx, "secret", "v1", time.Minute)
+	require.NoError(t, err)
+	require.Nil(t, old)
+
+	_, err = dm1.Get(ctx, "secret"+PreviousKeySuffix)
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	old, err = dm.Rotate(ctx, "secret", "v2", time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, old)
+	require.Equal(t, "v1", string(old.Value()))
+
+	current, err := dm1.Get(ctx, "secret")
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(current.Value()))
+	require.Equal(t, int64(0), current.TTL())
+
+	previous, err := dm2.Get(ctx, "secret"+PreviousKeySuffix)
+	require.NoError(t, err)
+	require.Equal(t, "v1", string(previous.Value()))
+
+	remaining := time.Duration(previous.TTL()-time.Now().UnixNano()/1000000) * time.Millisecond
+	require.LessOrEqual(t, remaining, time.Minute)
+	require.Greater(t, remaining, 50*time.Second)
+}
+
+func TestDMap_rotateCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the key.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "secret")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	// Hold the fine-grained lock of the key on the owner. The command must wait for
+	// it and rotate the value written in the meantime.
+	owner.s.locker.Lock("mydmap" + "secret")
+	result := make(chan []byte, 1)
+	go func() {
+		cmd := protocol.NewRotate("mydmap", "secret", []byte("v2"), time.Minute.Milliseconds()).Command(ctx)
+		rc := other.client.Get(other.rt.This().String())
+		_ = rc.Process(ctx, cmd)
+		raw, _ := cmd.Bytes()
+		result <- raw
+	}()
+
+	<-time.After(100 * time.Millisecond)
+	require.NoError(t, owner.Put(ctx, "secret", "v1", nil))
+	require.NoError(t, owner.s.locker.Unlock("mydmap"+"secret"))
+
+	raw := <-result
+	require.NotEmpty(t, raw)
+	old := owner.engine.NewEntry()
+	old.Decode(raw)
+	require.Equal(t, "v1", string(old.Value()))
+
+	current, err := owner.Get(ctx, "secret")
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(current.Value()))
+}
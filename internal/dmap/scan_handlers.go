@@ -15,14 +15,26 @@
 package dmap
 
 import (
+	"errors"
+	"regexp"
+	"strconv"
+	"time"
+
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/tidwall/redcon"
-	"strconv"
 )
 
+// ErrScanTimeout is returned when a scan call exceeds config.DMaps.ScanTimeBudget.
+var ErrScanTimeout = errors.New("scan timed out")
+
 func (dm *DMap) scanOnFragment(f *fragment, cursor uint64, sc *ScanConfig) ([]string, uint64, error) {
+	budget := dm.s.config.DMaps.ScanTimeBudget
+	if budget > 0 {
+		return dm.scanOnFragmentWithBudget(f, cursor, sc, budget)
+	}
+
 	f.Lock()
 	defer f.Unlock()
 
@@ -50,6 +62,53 @@ func (dm *DMap) scanOnFragment(f *fragment, cursor uint64, sc *ScanConfig) ([]st
 	return items, cursor, nil
 }
 
+// scanOnFragmentWithBudget scans the fragment like scanOnFragment, but it aborts the scan
+// with ErrScanTimeout if it takes longer than the budget. Go's regexp package guarantees
+// linear time matching, the cost of a broad match is the number of visited keys. So the
+// keys are matched here, the storage engine calls back for every visited key, and the
+// budget is checked on each of them.
+func (dm *DMap) scanOnFragmentWithBudget(f *fragment, cursor uint64, sc *ScanConfig, budget time.Duration) ([]string, uint64, error) {
+	var r *regexp.Regexp
+	if sc.HasMatch {
+		var err error
+		r, err = regexp.Compile(sc.Match)
+		if err != nil {
+			return nil, 0, err
+		}
+	}
+
+	f.Lock()
+	defer f.Unlock()
+
+	deadline := time.Now().Add(budget)
+	var items []string
+	var timedOut bool
+	for {
+		var err error
+		// Visit at most the remaining number of keys, so the result never exceeds sc.Count.
+		cursor, err = f.storage.Scan(cursor, sc.Count-len(items), func(e storage.Entry) bool {
+			if time.Now().After(deadline) {
+				timedOut = true
+				return false
+			}
+			if r == nil || r.MatchString(e.Key()) {
+				items = append(items, e.Key())
+			}
+			return true
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		if timedOut {
+			return nil, 0, ErrScanTimeout
+		}
+		// A regex scan continues until it finds sc.Count matching keys or the fragment ends.
+		if r == nil || cursor == 0 || len(items) >= sc.Count {
+			return items, cursor, nil
+		}
+	}
+}
+
 func (dm *DMap) Scan(partID, cursor uint64, sc *ScanConfig) ([]string, uint64, error) {
 	var part *partitions.Partition
 	if sc.Replica {
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/testcluster"
@@ -205,3 +206,65 @@ func TestDMap_scanCommandHandler_count(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, keys, 5)
 }
+
+func TestDMap_scanCommandHandler_ScanTimeBudget(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.ScanTimeBudget = time.Minute
+	e := testcluster.NewEnvironment(c)
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(e).(*Service)
+	defer cluster.Shutdown()
+
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	evenKeys := make(map[string]bool)
+	for i := 0; i < 20000; i++ {
+		var key string
+		if i%2 == 0 {
+			key = fmt.Sprintf("even:%s", testutil.ToKey(i))
+			evenKeys[key] = false
+		} else {
+			key = fmt.Sprintf("odd:%s", testutil.ToKey(i))
+		}
+		err = dm.Put(ctx, key, i, nil)
+		require.NoError(t, err)
+	}
+
+	t.Run("Within the budget", func(t *testing.T) {
+		sc := &ScanConfig{
+			HasMatch: true,
+			Match:    "^even:",
+		}
+		totalKeys := testScanIterator(t, s, evenKeys, sc)
+		require.Equal(t, 10000, totalKeys)
+		for _, value := range evenKeys {
+			require.True(t, value)
+		}
+	})
+
+	t.Run("Abort the scan", func(t *testing.T) {
+		s.config.DMaps.ScanTimeBudget = time.Nanosecond
+
+		sc := &ScanConfig{
+			HasCount: true,
+			Count:    10,
+			HasMatch: true,
+			Match:    "^no-such-prefix:",
+		}
+		_, _, err = dm.Scan(0, 0, sc)
+		require.ErrorIs(t, err, ErrScanTimeout)
+
+		rc := s.client.Get(s.rt.This().String())
+		cmd := protocol.NewScan(0, "mydmap", 0).SetMatch("^no-such-prefix:").Command(ctx)
+		err = rc.Process(ctx, cmd)
+		require.ErrorIs(t, protocol.ConvertError(err), ErrScanTimeout)
+
+		// The fragment is still usable after an aborted scan.
+		_, err = dm.Get(ctx, testutil.ToKey(0))
+		require.ErrorIs(t, err, ErrKeyNotFound)
+		_, err = dm.Get(ctx, fmt.Sprintf("even:%s", testutil.ToKey(0)))
+		require.NoError(t, err)
+	})
+}
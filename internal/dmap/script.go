@@ -0,0 +1,287 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/script"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultScriptTimeout is the maximum execution time of a script. The writes of
+// a timed out script are discarded.
+const DefaultScriptTimeout = time.Second
+
+var (
+	// ErrScript is returned when a script cannot be compiled or fails at runtime.
+	ErrScript = errors.New("script error")
+
+	// ErrScriptTimeout is returned when a script runs longer than DefaultScriptTimeout.
+	ErrScriptTimeout = errors.New("script timed out")
+
+	// ErrCrossOwnerKeys is returned when the keys of a multi-key operation belong to different partition owners.
+	ErrCrossOwnerKeys = errors.New("keys belong to different partition owners")
+)
+
+// scriptStore implements script.Store. It buffers the writes, they are applied
+// after the script returns successfully.
+type scriptStore struct {
+	dm     *DMap
+	writes map[string][]byte
+}
+
+func (s *scriptStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if value, ok := s.writes[key]; ok {
+		// nil means that the key is deleted by the script.
+		return value, value != nil, nil
+	}
+	entry, err := s.dm.Get(ctx, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return entry.Value(), true, nil
+}
+
+func (s *scriptStore) Set(_ context.Context, key string, value []byte) error {
+	s.writes[key] = value
+	return nil
+}
+
+func (s *scriptStore) Delete(ctx context.Context, key string) (bool, error) {
+	_, ok, err := s.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	s.writes[key] = nil
+	return ok, nil
+}
+
+func (s *scriptStore) apply(ctx context.Context) error {
+	writes := make([]keyWrite, 0, len(s.writes))
+	for key, value := range s.writes {
+		writes = append(writes, keyWrite{key: key, value: value})
+	}
+	sort.Slice(writes, func(i, j int) bool {
+		return writes[i].key < writes[j].key
+	})
+	return s.dm.applyWrites(ctx, writes)
+}
+
+// keyWrite is a write of a multi-key operation. A nil value deletes the key.
+type keyWrite struct {
+	key   string
+	value []byte
+}
+
+// applyWrites applies the writes in the given order. The writes are validated before the
+// first one is applied. If a write fails anyway, the applied ones are rolled back to the
+// previous values and TTLs of the keys, so either all the writes are applied or none of
+// them. The caller has to hold the fine-grained locks of the keys.
+func (dm *DMap) applyWrites(ctx context.Context, writes []keyWrite) error {
+	for _, w := range writes {
+		if w.value == nil {
+			continue
+		}
+		if err := dm.config.checkKeySchema(w.key); err != nil {
+			return err
+		}
+	}
+
+	// previous keeps the entries before the writes, nil means that the key didn't exist.
+	previous := make(map[string]storage.Entry)
+	var applied []string
+	for _, w := range writes {
+		if _, ok := previous[w.key]; !ok {
+			entry, err := dm.Get(ctx, w.key)
+			if errors.Is(err, ErrKeyNotFound) {
+				entry, err = nil, nil
+			}
+			if err != nil {
+				dm.rollbackWrites(ctx, applied, previous)
+				return err
+			}
+			previous[w.key] = entry
+		}
+
+		var err error
+		if w.value == nil {
+			_, err = dm.deleteKeys(ctx, w.key)
+		} else {
+			e := newEnv(ctx)
+			e.dmap = dm.name
+			e.key = w.key
+			e.value = w.value
+			err = dm.put(e)
+		}
+		if err != nil {
+			dm.rollbackWrites(ctx, applied, previous)
+			return err
+		}
+		applied = append(applied, w.key)
+	}
+	return nil
+}
+
+// rollbackWrites restores the previous entries of the applied keys in the reverse order.
+func (dm *DMap) rollbackWrites(ctx context.Context, applied []string, previous map[string]storage.Entry) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		key := applied[i]
+		entry := previous[key]
+
+		var err error
+		if entry == nil {
+			_, err = dm.deleteKeys(ctx, key)
+		} else {
+			e := newEnv(ctx)
+			e.dmap = dm.name
+			e.key = key
+			e.value = entry.Value()
+			if entry.TTL() != 0 {
+				// Keep the previous expiration time of the key.
+				e.putConfig.HasPXAT = true
+				e.putConfig.PXAT = time.Duration(entry.TTL()) * time.Millisecond
+			}
+			err = dm.put(e)
+		}
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to roll back key: %s on DMap: %s: %v", key, dm.name, err)
+		}
+	}
+}
+
+// keysOwner returns the partition owner of the keys. All keys must belong to the same owner.
+func (dm *DMap) keysOwner(keys []string) (discovery.Member, error) {
+	owner := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, keys[0])).Owner()
+	for _, key := range keys[1:] {
+		member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+		if !member.CompareByName(owner) {
+			return discovery.Member{}, ErrCrossOwnerKeys
+		}
+	}
+	return owner, nil
+}
+
+// lockKeys acquires the fine-grained locks of the keys and returns a function to release them.
+func (dm *DMap) lockKeys(keys []string) func() {
+	// Lock the keys in the same order to prevent deadlocks.
+	locked := make([]string, len(keys))
+	copy(locked, keys)
+	sort.Strings(locked)
+	for i, key := range locked {
+		if i > 0 && key == locked[i-1] {
+			continue
+		}
+		dm.s.locker.Lock(dm.name + key)
+	}
+
+	return func() {
+		for i, key := range locked {
+			if i > 0 && key == locked[i-1] {
+				continue
+			}
+			err := dm.s.locker.Unlock(dm.name + key)
+			if err != nil {
+				dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", key, dm.name, err)
+			}
+		}
+	}
+}
+
+func (dm *DMap) evalOnOwner(ctx context.Context, s *script.Script, keys, args []string) (script.Value, error) {
+	unlock := dm.lockKeys(keys)
+	defer unlock()
+
+	store := &scriptStore{
+		dm:     dm,
+		writes: make(map[string][]byte),
+	}
+	runCtx, cancel := context.WithTimeout(ctx, DefaultScriptTimeout)
+	defer cancel()
+
+	value, err := s.Run(runCtx, store, keys, args)
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return nil, ErrScriptTimeout
+	}
+	var scriptErr *script.Error
+	if errors.As(err, &scriptErr) {
+		return nil, fmt.Errorf("%w: %s", ErrScript, scriptErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = store.apply(ctx); err != nil {
+		return nil, err
+	}
+
+	if b, ok := value.(bool); ok {
+		// Booleans are returned as integers, like the other commands.
+		if b {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	}
+	return value, nil
+}
+
+// Eval runs a script atomically on the given keys. All keys must belong to the same
+// partition owner, the script runs on that member under the fine-grained locks of the
+// keys. The writes are applied only if the script returns successfully, and a write that
+// fails rolls back the other ones. The isolation only holds against the commands that
+// take the same locks, like the atomic commands, Lock and the other scripts. A plain
+// Put or Delete doesn't take them and may interleave with a running script. The return
+// value is nil, an int64 or a string. See the script package for the language.
+func (dm *DMap) Eval(ctx context.Context, src string, keys []string, args ...string) (interface{}, error) {
+	s, err := script.Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrScript, err)
+	}
+	if len(keys) == 0 {
+		// The script cannot access any key, run it locally.
+		return dm.evalOnOwner(ctx, s, keys, args)
+	}
+
+	owner, err := dm.keysOwner(keys)
+	if err != nil {
+		return nil, err
+	}
+	if owner.CompareByName(dm.s.rt.This()) {
+		return dm.evalOnOwner(ctx, s, keys, args)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewEval(dm.name, src, keys, args...).Command(ctx)
+	rc := dm.s.client.Get(owner.String())
+	err = rc.Process(ctx, cmd)
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, protocol.ConvertError(err)
+	}
+	return cmd.Val(), nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+const transferScript = `
+let balance = get(KEYS[1])
+if balance == nil || balance < ARGV[1] {
+	return 0
+}
+set(KEYS[1], balance - ARGV[1])
+incr(KEYS[2], ARGV[1])
+return 1
+`
+
+// findKeys returns two keys owned by the same member and two keys owned by different members.
+func findKeys(s *Service, dmap string) (colocated, separated []string) {
+	owners := make(map[string]string)
+	for i := 0; len(colocated) < 2 || len(separated) < 2; i++ {
+		key := testutil.ToKey(i)
+		owner := s.primary.PartitionByHKey(partitions.HKey(dmap, key)).Owner().String()
+		for other, otherOwner := range owners {
+			if len(colocated) < 2 && otherOwner == owner {
+				colocated = []string{other, key}
+			}
+			if len(separated) < 2 && otherOwner != owner {
+				separated = []string{other, key}
+			}
+		}
+		owners[key] = owner
+	}
+	return colocated, separated
+}
+
+func TestDMap_Eval(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	keys, separated := findKeys(s1, "mydmap")
+	require.NoError(t, dm1.Put(ctx, keys[0], 100, nil))
+
+	// Run the transfers on both members concurrently, the non-owner redirects the script.
+	var errGr errgroup.Group
+	for i := 0; i < 20; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			_, err := dm.Eval(ctx, transferScript, keys, "10")
+			return err
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	value, err := dm1.Get(ctx, keys[0])
+	require.NoError(t, err)
+	require.Equal(t, "0", string(value.Value()))
+
+	value, err = dm2.Get(ctx, keys[1])
+	require.NoError(t, err)
+	require.Equal(t, "100", string(value.Value()))
+
+	result, err := dm2.Eval(ctx, transferScript, keys, "10")
+	require.NoError(t, err)
+	require.Equal(t, int64(0), result)
+
+	t.Run("Discard writes on error", func(t *testing.T) {
+		_, err := dm1.Eval(ctx, `set(KEYS[1], "1"); error("aborted")`, keys)
+		require.ErrorIs(t, err, ErrScript)
+
+		value, err := dm1.Get(ctx, keys[0])
+		require.NoError(t, err)
+		require.Equal(t, "0", string(value.Value()))
+	})
+
+	t.Run("Roll back writes on a failed write", func(t *testing.T) {
+		sorted := []string{keys[0], keys[1]}
+		sort.Strings(sorted)
+
+		// The second write exceeds the table size of the storage engine, the first one
+		// has to be rolled back.
+		large := strings.Repeat("x", 2<<20)
+		_, err := dm1.Eval(ctx, `set(KEYS[1], "1"); set(KEYS[2], ARGV[1])`, sorted, large)
+		require.ErrorIs(t, err, ErrEntryTooLarge)
+
+		value, err := dm1.Get(ctx, keys[0])
+		require.NoError(t, err)
+		require.Equal(t, "0", string(value.Value()))
+
+		value, err = dm1.Get(ctx, keys[1])
+		require.NoError(t, err)
+		require.Equal(t, "100", string(value.Value()))
+	})
+
+	t.Run("Reject keys on different owners", func(t *testing.T) {
+		_, err := dm1.Eval(ctx, transferScript, separated, "10")
+		require.ErrorIs(t, err, ErrCrossOwnerKeys)
+	})
+
+	t.Run("Compile error", func(t *testing.T) {
+		_, err := dm1.Eval(ctx, `return (`, keys)
+		require.ErrorIs(t, err, ErrScript)
+	})
+}
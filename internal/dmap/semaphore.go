@@ -0,0 +1,185 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// livePermits removes the expired permits and returns the latest expiry time. A semaphore
+// is stored as a hash. The fields are the tokens of the permits and the values are their
+// expiry times in milliseconds, so the permits of the crashed holders expire.
+func livePermits(fields map[string]string, now int64) int64 {
+	var latest int64
+	for token, raw := range fields {
+		expiry, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || expiry <= now {
+			delete(fields, token)
+			continue
+		}
+		if expiry > latest {
+			latest = expiry
+		}
+	}
+	return latest
+}
+
+// acquirePermit runs on the partition owner of the key.
+func (dm *DMap) acquirePermit(e *env, token string, max int, ttl time.Duration) (bool, error) {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	fields, _, err := dm.loadHash(e)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now().UnixMilli()
+	latest := livePermits(fields, now)
+	if len(fields) >= max {
+		return false, nil
+	}
+
+	expiry := now + ttl.Milliseconds()
+	fields[token] = strconv.FormatInt(expiry, 10)
+	if expiry > latest {
+		latest = expiry
+	}
+	// The semaphore expires with its last permit.
+	if err = dm.storeHash(e, fields, latest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// releasePermit runs on the partition owner of the key.
+func (dm *DMap) releasePermit(e *env, token string) error {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	fields, _, err := dm.loadHash(e)
+	if err != nil {
+		return err
+	}
+	if _, ok := fields[token]; !ok {
+		// The permit is already expired.
+		return nil
+	}
+	delete(fields, token)
+
+	latest := livePermits(fields, time.Now().UnixMilli())
+	if len(fields) == 0 {
+		_, err = dm.deleteKeys(e.ctx, e.key)
+		return err
+	}
+	return dm.storeHash(e, fields, latest)
+}
+
+// AcquirePermit takes a permit from the distributed semaphore stored at the key. At most
+// max permits are held at the same time, it returns false without blocking if the semaphore
+// is full. The permit is released automatically after ttl if it's not released by
+// ReleasePermit. The returned token identifies the permit. The operation runs on the
+// partition owner of the key.
+func (dm *DMap) AcquirePermit(ctx context.Context, key string, max int, ttl time.Duration) ([]byte, bool, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ok, err := dm.acquirePermitOnOwner(ctx, key, hex.EncodeToString(token), max, ttl)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return token, true, nil
+}
+
+// acquirePermitOnOwner runs acquirePermit on the partition owner of the key, so the
+// permits are counted under the fine-grained lock of the key on a single member.
+func (dm *DMap) acquirePermitOnOwner(ctx context.Context, key, token string, max int, ttl time.Duration) (bool, error) {
+	if max <= 0 {
+		return false, fmt.Errorf("%w: non-positive max: %d", protocol.ErrInvalidArgument, max)
+	}
+	if ttl.Milliseconds() <= 0 {
+		return false, fmt.Errorf("%w: TTL is less than a millisecond: %s", protocol.ErrInvalidArgument, ttl)
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.acquirePermit(e, token, max, ttl)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewAcquirePermit(dm.name, key, token, max, ttl.Milliseconds()).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	res, err := cmd.Result()
+	if err != nil {
+		return false, protocol.ConvertError(err)
+	}
+	return res == 1, nil
+}
+
+// ReleasePermit returns the permit with the given token to the distributed semaphore
+// stored at the key. It's a no-op if the permit is already expired.
+func (dm *DMap) ReleasePermit(ctx context.Context, key string, token []byte) error {
+	return dm.releasePermitOnOwner(ctx, key, hex.EncodeToString(token))
+}
+
+// releasePermitOnOwner runs releasePermit on the partition owner of the key.
+func (dm *DMap) releasePermitOnOwner(ctx context.Context, key, token string) error {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.releasePermit(e, token)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewReleasePermit(dm.name, key, token).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
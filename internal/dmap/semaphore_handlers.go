@@ -0,0 +1,67 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) acquirePermitCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	acquireCmd, err := protocol.ParseAcquirePermitCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(acquireCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	ttl := time.Duration(acquireCmd.TTL) * time.Millisecond
+	ok, err := dm.acquirePermitOnOwner(s.ctx, acquireCmd.Key, acquireCmd.Token, acquireCmd.Max, ttl)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	if ok {
+		conn.WriteInt(1)
+		return
+	}
+	conn.WriteInt(0)
+}
+
+func (s *Service) releasePermitCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	releaseCmd, err := protocol.ParseReleasePermitCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(releaseCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	err = dm.releasePermitOnOwner(s.ctx, releaseCmd.Key, releaseCmd.Token)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteString(protocol.StatusOK)
+}
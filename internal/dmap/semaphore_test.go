@@ -0,0 +1,148 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_AcquirePermit(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	const max = 3
+	var held, maxHeld, acquired int32
+	var errGr errgroup.Group
+	for i := 0; i < 50; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			for {
+				token, ok, err := dm.AcquirePermit(ctx, "mysemaphore", max, time.Minute)
+				if err != nil {
+					return err
+				}
+				if !ok {
+					<-time.After(time.Millisecond)
+					continue
+				}
+
+				current := atomic.AddInt32(&held, 1)
+				for {
+					m := atomic.LoadInt32(&maxHeld)
+					if current <= m || atomic.CompareAndSwapInt32(&maxHeld, m, current) {
+						break
+					}
+				}
+				atomic.AddInt32(&acquired, 1)
+				<-time.After(time.Millisecond)
+				atomic.AddInt32(&held, -1)
+				return dm.ReleasePermit(ctx, "mysemaphore", token)
+			}
+		})
+	}
+	require.NoError(t, errGr.Wait())
+	require.Equal(t, int32(50), acquired)
+	require.LessOrEqual(t, maxHeld, int32(max))
+
+	// All permits are released, the semaphore is deleted.
+	_, err = dm1.Get(ctx, "mysemaphore")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	t.Run("Reject when full", func(t *testing.T) {
+		_, ok, err := dm1.AcquirePermit(ctx, "full", 1, time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		_, ok, err = dm2.AcquirePermit(ctx, "full", 1, time.Minute)
+		require.NoError(t, err)
+		require.False(t, ok)
+	})
+
+	t.Run("Release expired permits", func(t *testing.T) {
+		_, ok, err := dm1.AcquirePermit(ctx, "expiring", 1, 50*time.Millisecond)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		<-time.After(100 * time.Millisecond)
+		token, ok, err := dm2.AcquirePermit(ctx, "expiring", 1, time.Minute)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.NoError(t, dm2.ReleasePermit(ctx, "expiring", token))
+	})
+}
+
+func TestDMap_acquirePermitCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the key.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mysem")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	// Hold the fine-grained lock of the key on the owner. The command must wait for
+	// it and see the permit taken in the meantime.
+	owner.s.locker.Lock("mydmap" + "mysem")
+	result := make(chan *redis.IntCmd, 1)
+	go func() {
+		cmd := protocol.NewAcquirePermit("mydmap", "mysem", "other", 1, time.Minute.Milliseconds()).Command(ctx)
+		rc := other.client.Get(other.rt.This().String())
+		_ = rc.Process(ctx, cmd)
+		result <- cmd
+	}()
+
+	<-time.After(100 * time.Millisecond)
+	expiry := time.Now().Add(time.Minute).UnixMilli()
+	raw, err := encodeHash(map[string]string{"owner": strconv.FormatInt(expiry, 10)})
+	require.NoError(t, err)
+	require.NoError(t, owner.Put(ctx, "mysem", raw, nil))
+	require.NoError(t, owner.s.locker.Unlock("mydmap"+"mysem"))
+
+	cmd := <-result
+	acquired, err := cmd.Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), acquired)
+}
@@ -0,0 +1,91 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// checkSequence returns an error if the DMap may drop the counter of the sequence. A dropped
+// counter restarts from 1 and the sequence hands out the same numbers again.
+func (dm *DMap) checkSequence(name string) error {
+	if dm.config == nil {
+		return nil
+	}
+	if dm.config.ttlDuration != 0 || dm.config.maxAge != 0 {
+		return fmt.Errorf("%w: sequences cannot be stored on a DMap with TTLDuration or MaxAge: %s",
+			protocol.ErrInvalidArgument, dm.name)
+	}
+	evicts := dm.config.maxIdleDuration != 0 || dm.config.evictionPolicy == config.LRUEviction
+	if evicts && dm.config.isEvictable(name) {
+		return fmt.Errorf("%w: the sequence must match one of the NoEvictKeyPatterns of the DMap: %s",
+			protocol.ErrInvalidArgument, dm.name)
+	}
+	return nil
+}
+
+// NextSequence returns the next number of the sequence stored at the key. The numbers
+// start from 1, they are unique and increasing across the cluster. See ReserveSequenceBlock.
+func (dm *DMap) NextSequence(ctx context.Context, name string) (int64, error) {
+	return dm.ReserveSequenceBlock(ctx, name, 1)
+}
+
+// ReserveSequenceBlock reserves n numbers of the sequence stored at the key, and returns
+// the first one. The caller owns the numbers in [start, start+n). The sequence is a counter
+// incremented on the partition owner of the key, so the blocks never overlap. The members
+// that are not the owner redirect the request, since the fine-grained locks of Incr are local.
+//
+// The counter is a regular key of the DMap, so the DMap must not drop it. The sequences
+// are rejected on the DMaps with TTLDuration or MaxAge. If the DMap evicts the keys with
+// the LRU policy or MaxIdleDuration, the name of the sequence must match one of its
+// NoEvictKeyPatterns.
+func (dm *DMap) ReserveSequenceBlock(ctx context.Context, name string, n int) (int64, error) {
+	if n <= 0 {
+		return 0, fmt.Errorf("%w: block size must be greater than zero: %d", protocol.ErrInvalidArgument, n)
+	}
+	if err := dm.checkSequence(name); err != nil {
+		return 0, err
+	}
+
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, name)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = name
+		last, err := dm.atomicIncrDecr(protocol.DMap.Incr, e, n)
+		if err != nil {
+			return 0, err
+		}
+		return int64(last - n + 1), nil
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewIncr(dm.name, name, n).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	last, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return last - int64(n) + 1, nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_NextSequence(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// Every caller observes increasing numbers, and the numbers are unique across the callers.
+	var mtx sync.Mutex
+	var all []int64
+	var errGr errgroup.Group
+	for i := 0; i < 10; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			var last int64
+			for j := 0; j < 50; j++ {
+				n, err := dm.NextSequence(ctx, "myseq")
+				if err != nil {
+					return err
+				}
+				if n <= last {
+					return fmt.Errorf("sequence is not increasing: %d after %d", n, last)
+				}
+				last = n
+
+				mtx.Lock()
+				all = append(all, n)
+				mtx.Unlock()
+			}
+			return nil
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+	for i, n := range all {
+		require.Equal(t, int64(i+1), n)
+	}
+}
+
+func TestDMap_ReserveSequenceBlock(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	const blockSize = 100
+
+	var mtx sync.Mutex
+	var starts []int64
+	var errGr errgroup.Group
+	for i := 0; i < 20; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			start, err := dm.ReserveSequenceBlock(ctx, "myseq", blockSize)
+			if err != nil {
+				return err
+			}
+			mtx.Lock()
+			starts = append(starts, start)
+			mtx.Unlock()
+			return nil
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	// The blocks are adjacent and don't overlap.
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+	for i, start := range starts {
+		require.Equal(t, int64(i*blockSize+1), start)
+	}
+
+	n, err := dm1.NextSequence(ctx, "myseq")
+	require.NoError(t, err)
+	require.Equal(t, int64(20*blockSize+1), n)
+
+	_, err = dm2.ReserveSequenceBlock(ctx, "myseq", 0)
+	require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+}
+
+func TestDMap_NextSequence_Eviction(t *testing.T) {
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"ttl": {
+			TTLDuration: time.Hour,
+		},
+		"max-age": {
+			MaxAge: time.Hour,
+		},
+		"lru": {
+			EvictionPolicy:     config.LRUEviction,
+			MaxKeys:            1000,
+			NoEvictKeyPatterns: []string{"^seq:"},
+		},
+	}
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	for _, name := range []string{"ttl", "max-age"} {
+		dm, err := s.NewDMap(name)
+		require.NoError(t, err)
+		_, err = dm.NextSequence(ctx, "seq:1")
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+	}
+
+	dm, err := s.NewDMap("lru")
+	require.NoError(t, err)
+	_, err = dm.NextSequence(ctx, "myseq")
+	require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+
+	// The sequences that cannot be evicted are allowed.
+	n, err := dm.NextSequence(ctx, "seq:1")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
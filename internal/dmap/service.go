@@ -17,6 +17,7 @@ package dmap
 import (
 	"context"
 	"errors"
+	"fmt"
 	"reflect"
 	"sync"
 
@@ -29,6 +30,7 @@ import (
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/server"
 	"github.com/buraksezer/olric/internal/service"
+	"github.com/buraksezer/olric/internal/wal"
 	"github.com/buraksezer/olric/pkg/flog"
 	"github.com/buraksezer/olric/pkg/storage"
 )
@@ -53,6 +55,12 @@ type Service struct {
 	locker  *locker.Locker
 	dmaps   map[string]*DMap
 	storage *storageMap
+	changes *changeFeed
+	syncs   replicaSyncLimiter
+	zeros   *zeroCallbacks
+	gossip  *gossipReplicas
+	slowLog *slowLog
+	wal     *wal.WAL
 	wg      sync.WaitGroup
 	ctx     context.Context
 	cancel  context.CancelFunc
@@ -68,6 +76,27 @@ func registerErrors() {
 	protocol.SetError("ENTRYTOOLARGE", ErrEntryTooLarge)
 	protocol.SetError("KEYNOTFOUND", ErrKeyNotFound)
 	protocol.SetError("KEYFOUND", ErrKeyFound)
+	protocol.SetError("NOTANINTEGER", ErrNotAnInteger)
+	protocol.SetError("INVALIDFLOAT", ErrInvalidFloat)
+	protocol.SetError("SCRIPT", ErrScript)
+	protocol.SetError("SCRIPTTIMEOUT", ErrScriptTimeout)
+	protocol.SetError("CROSSOWNER", ErrCrossOwnerKeys)
+	protocol.SetError("SCANTIMEOUT", ErrScanTimeout)
+	protocol.SetError("NOAVAILABLE", ErrNoAvailable)
+	protocol.SetError("REPLICASYNCRATELIMITED", ErrReplicaSyncRateLimited)
+	protocol.SetError("DECRYPTION", ErrDecryption)
+	protocol.SetError("TOOMANYDMAPS", ErrTooManyDMaps)
+	protocol.SetError("DMAPTIMEOUT", ErrTimeout)
+	protocol.SetError("KEYSCHEMA", ErrKeySchema)
+	protocol.SetError("ACCESSCOUNTERDISABLED", ErrAccessCounterDisabled)
+	protocol.SetError("NOTAHASH", ErrNotAHash)
+	protocol.SetError("VERSIONMISMATCH", ErrVersionMismatch)
+	protocol.SetError("NOTALIST", ErrNotAList)
+	protocol.SetError("NOTANOBJECT", ErrNotAnObject)
+	protocol.SetError("NOSTANDBY", ErrNoStandby)
+	protocol.SetError("HASHTAGSDISABLED", ErrHashTagsDisabled)
+	protocol.SetError("NOSUCHINSTANCE", ErrNoSuchInstance)
+	protocol.SetError("NOLEADER", ErrNoLeader)
 }
 
 func NewService(e *environment.Environment) (service.Service, error) {
@@ -85,12 +114,17 @@ func NewService(e *environment.Environment) (service.Service, error) {
 			engines: make(map[string]storage.Engine),
 			configs: make(map[string]map[string]interface{}),
 		},
-		dmaps:  make(map[string]*DMap),
-		ctx:    ctx,
-		cancel: cancel,
+		dmaps:   make(map[string]*DMap),
+		changes: newChangeFeed(DefaultChangeLogSize, DefaultChangeLogBytes, DefaultChangeLogRetention),
+		zeros:   newZeroCallbacks(),
+		gossip:  newGossipReplicas(),
+		slowLog: newSlowLog(e.Get("config").(*config.Config).DMaps.SlowLogMaxLen),
+		ctx:     ctx,
+		cancel:  cancel,
 	}
 	registerErrors()
 	s.RegisterHandlers()
+	s.rt.Discovery().SetMessageHandler(s.applyGossip)
 	return s, nil
 }
 
@@ -130,12 +164,29 @@ func (s *Service) publishEvent(e events.Event) {
 
 // Start starts the distributed map service.
 func (s *Service) Start() error {
+	if s.config.DMaps.ShutdownSnapshotDir != "" {
+		if err := s.restoreShutdownSnapshot(); err != nil {
+			return fmt.Errorf("failed to restore the shutdown snapshot: %w", err)
+		}
+	}
+
+	if s.config.DMaps.WALDir != "" {
+		if err := s.openWAL(); err != nil {
+			return fmt.Errorf("failed to open the write-ahead log: %w", err)
+		}
+	}
+
 	s.wg.Add(1)
 	go s.janitorWorker()
 
 	s.wg.Add(1)
 	go s.compactionWorker()
 
+	if s.config.DMaps.IdleCompactionInterval > 0 {
+		s.wg.Add(1)
+		go s.idleCompactionWorker()
+	}
+
 	s.wg.Add(1)
 	go s.evictKeysAtBackground()
 
@@ -143,6 +194,13 @@ func (s *Service) Start() error {
 }
 
 func (s *Service) Shutdown(ctx context.Context) error {
+	select {
+	case <-s.ctx.Done():
+		// Shutdown only once.
+		return nil
+	default:
+	}
+
 	s.cancel()
 	done := make(chan struct{})
 
@@ -159,6 +217,22 @@ func (s *Service) Shutdown(ctx context.Context) error {
 		}
 	case <-done:
 	}
+
+	if s.config.DMaps.ShutdownSnapshotDir != "" {
+		// The background workers are stopped, they don't race with the snapshot.
+		if err := s.writeShutdownSnapshot(ctx); err != nil {
+			s.log.V(2).Printf("[ERROR] Failed to write the shutdown snapshot: %v", err)
+			return err
+		}
+		s.log.V(2).Printf("[INFO] Shutdown snapshot has been written: %s", s.shutdownSnapshotPath())
+	}
+
+	if s.wal != nil {
+		if err := s.wal.Close(); err != nil {
+			s.log.V(2).Printf("[ERROR] Failed to close the write-ahead log: %v", err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,100 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// SlowLogEntry is an operation that took longer than the slow log threshold of its DMap.
+type SlowLogEntry struct {
+	ID        int64
+	Timestamp int64
+	Duration  time.Duration
+	DMap      string
+	Operation string
+	Keys      []string
+}
+
+// slowLog keeps the latest slow operations of the member in a ring buffer.
+type slowLog struct {
+	mtx     sync.Mutex
+	maxLen  int
+	nextID  int64
+	entries []SlowLogEntry
+}
+
+func newSlowLog(maxLen int) *slowLog {
+	return &slowLog{
+		maxLen:  maxLen,
+		entries: make([]SlowLogEntry, 0, maxLen),
+	}
+}
+
+func (l *slowLog) add(e SlowLogEntry) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	e.ID = l.nextID
+	l.nextID++
+	if len(l.entries) < l.maxLen {
+		l.entries = append(l.entries, e)
+		return
+	}
+	l.entries[e.ID%int64(l.maxLen)] = e
+}
+
+// list returns the entries, the newest one first.
+func (l *slowLog) list() []SlowLogEntry {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	result := make([]SlowLogEntry, 0, len(l.entries))
+	for id := l.nextID - 1; id >= 0 && len(result) < len(l.entries); id-- {
+		result = append(result, l.entries[id%int64(l.maxLen)])
+	}
+	return result
+}
+
+// SlowLog returns the slow operations recorded on this member, the newest one first.
+func (s *Service) SlowLog() []SlowLogEntry {
+	return s.slowLog.list()
+}
+
+// observeSlow records the operation in the slow log if it took longer than the slow log
+// threshold of the DMap. Only a SlowLogSampleRate fraction of the slow operations is recorded.
+func (dm *DMap) observeSlow(operation string, start time.Time, keys ...string) {
+	if dm.config.slowLogThreshold <= 0 {
+		return
+	}
+
+	elapsed := time.Since(start)
+	if elapsed < dm.config.slowLogThreshold {
+		return
+	}
+	if dm.config.slowLogSampleRate < 1 && rand.Float64() >= dm.config.slowLogSampleRate {
+		return
+	}
+
+	dm.s.slowLog.add(SlowLogEntry{
+		Timestamp: start.UnixNano(),
+		Duration:  elapsed,
+		DMap:      dm.name,
+		Operation: operation,
+		Keys:      append([]string(nil), keys...),
+	})
+}
@@ -0,0 +1,91 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_SlowLog(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.SlowLogThreshold = time.Second
+	c.DMaps.Custom = map[string]config.DMap{
+		"critical": {
+			SlowLogThreshold: 10 * time.Millisecond,
+		},
+		"sampled": {
+			SlowLogThreshold:  10 * time.Millisecond,
+			SlowLogSampleRate: 0.000001,
+		},
+	}
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	key := testutil.ToKey(1)
+	// slowPut holds the lock of the key's fragment to slow down the Put on it.
+	slowPut := func(name string) {
+		dm, err := s.NewDMap(name)
+		require.NoError(t, err)
+		part := dm.getPartitionByHKey(partitions.HKey(dm.name, key), partitions.PRIMARY)
+		f, err := dm.loadOrCreateFragment(part)
+		require.NoError(t, err)
+		f.Lock()
+		time.AfterFunc(100*time.Millisecond, f.Unlock)
+		require.NoError(t, dm.Put(ctx, key, testutil.ToVal(1), nil))
+	}
+
+	slowPut("critical")
+	slowPut("bulk")
+	slowPut("sampled")
+
+	entries := s.SlowLog()
+	require.Len(t, entries, 1)
+	require.Equal(t, "critical", entries[0].DMap)
+	require.Equal(t, protocol.DMap.Put, entries[0].Operation)
+	require.Equal(t, []string{key}, entries[0].Keys)
+	require.GreaterOrEqual(t, entries[0].Duration, 10*time.Millisecond)
+
+	// The fast operations are not recorded.
+	dm, err := s.NewDMap("critical")
+	require.NoError(t, err)
+	_, err = dm.Get(ctx, key)
+	require.NoError(t, err)
+	require.Len(t, s.SlowLog(), 1)
+}
+
+func TestDMap_SlowLog_MaxLen(t *testing.T) {
+	l := newSlowLog(3)
+	for i := 0; i < 5; i++ {
+		l.add(SlowLogEntry{Keys: []string{testutil.ToKey(i)}})
+	}
+
+	entries := l.list()
+	require.Len(t, entries, 3)
+	for i, e := range entries {
+		require.Equal(t, int64(4-i), e.ID)
+		require.Equal(t, []string{testutil.ToKey(4 - i)}, e.Keys)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// shutdownSnapshotFile is the name of the snapshot file in DMaps.ShutdownSnapshotDir.
+const shutdownSnapshotFile = "dmaps.snapshot"
+
+func (s *Service) shutdownSnapshotPath() string {
+	return filepath.Join(s.config.DMaps.ShutdownSnapshotDir, shutdownSnapshotFile)
+}
+
+// exportTo drains the tables of the fragment into the encoder, one fragmentPack for every
+// table. It's only called during shutdown, the fragment is empty when it returns.
+func (f *fragment) exportTo(ctx context.Context, enc *msgpack.Encoder, part *partitions.Partition, name string) error {
+	f.Lock()
+	defer f.Unlock()
+
+	i := f.storage.TransferIterator()
+	for i.Next() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		payload, index, err := i.Export()
+		if errors.Is(err, io.EOF) {
+			// Only the recycled tables are left.
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		fp := &fragmentPack{
+			PartID:  part.ID(),
+			Kind:    part.Kind(),
+			Name:    strings.TrimPrefix(name, "dmap."),
+			Payload: payload,
+		}
+		if err = enc.Encode(fp); err != nil {
+			return err
+		}
+		if err = i.Drop(index); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Service) exportPartitions(ctx context.Context, enc *msgpack.Encoder, kind partitions.Kind) error {
+	var err error
+	for partID := uint64(0); partID < s.config.PartitionCount; partID++ {
+		part := s.primary.PartitionByID(partID)
+		if kind == partitions.BACKUP {
+			part = s.backup.PartitionByID(partID)
+		}
+
+		part.Map().Range(func(name, tmp interface{}) bool {
+			if !strings.HasPrefix(name.(string), "dmap.") {
+				// Continue. This fragment belongs to a different data structure.
+				return true
+			}
+
+			exportErr := tmp.(*fragment).exportTo(ctx, enc, part, name.(string))
+			if errors.Is(exportErr, context.DeadlineExceeded) || errors.Is(exportErr, context.Canceled) {
+				err = exportErr
+				return false
+			}
+			if exportErr != nil {
+				// Skip this fragment, the others may still be exported.
+				s.log.V(2).Printf("[ERROR] Failed to export DMap fragment: %s (kind: %s) on PartID: %d to the snapshot: %v",
+					name, kind, partID, exportErr)
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeShutdownSnapshot writes the DMap fragments hosted by this member into the snapshot file.
+// The snapshot is a sequence of msgpack encoded fragmentPacks, the same payload that is used to
+// move fragments between members. It's written into a temporary file first, so an incomplete
+// snapshot never replaces the file.
+func (s *Service) writeShutdownSnapshot(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, s.config.DMaps.ShutdownSnapshotTimeout)
+	defer cancel()
+
+	file, err := ioutil.TempFile(s.config.DMaps.ShutdownSnapshotDir, shutdownSnapshotFile+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// It's a no-op if the file has already been renamed.
+		_ = os.Remove(file.Name())
+	}()
+
+	w := bufio.NewWriter(file)
+	enc := msgpack.NewEncoder(w)
+	for _, kind := range []partitions.Kind{partitions.PRIMARY, partitions.BACKUP} {
+		if err = s.exportPartitions(ctx, enc, kind); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+
+	if err = w.Flush(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), s.shutdownSnapshotPath())
+}
+
+// restoreFragmentPack merges the entries of a fragmentPack from the shutdown snapshot into the
+// local fragments. The hash seed of the cluster may be generated again after a restart, so the
+// keys are hashed again instead of using the hkeys and the partition in the snapshot.
+func (dm *DMap) restoreFragmentPack(fp *fragmentPack) error {
+	return dm.engine.Import(fp.Payload, func(_ uint64, entry storage.Entry) error {
+		hkey := partitions.HKey(dm.name, entry.Key())
+		part := dm.getPartitionByHKey(hkey, fp.Kind)
+		f, err := dm.loadOrCreateFragment(part)
+		if err != nil {
+			return err
+		}
+
+		f.Lock()
+		defer f.Unlock()
+		return dm.fragmentMergeFunction(f, hkey, entry)
+	})
+}
+
+// restoreShutdownSnapshot merges the shutdown snapshot into the local fragments and removes
+// the snapshot file. The balancer moves the fragments that belong to the other members.
+// There are no tombstones, so the keys that have been deleted by the other members since
+// the snapshot was written are restored as well.
+func (s *Service) restoreShutdownSnapshot() error {
+	file, err := os.Open(s.shutdownSnapshotPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if !s.rt.Discovery().IsCoordinator() {
+		s.log.V(2).Printf("[WARN] Restoring the shutdown snapshot: %s on a member that joined a running cluster. "+
+			"The keys deleted while this member was down will be restored", s.shutdownSnapshotPath())
+	}
+
+	var total int
+	dec := msgpack.NewDecoder(bufio.NewReader(file))
+	for {
+		fp := &fragmentPack{}
+		err = dec.Decode(fp)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		dm, err := s.newDMap(fp.Name)
+		if err != nil {
+			return err
+		}
+		if err = dm.restoreFragmentPack(fp); err != nil {
+			return err
+		}
+		total++
+	}
+
+	s.log.V(2).Printf("[INFO] Restored %d DMap tables from the shutdown snapshot: %s", total, s.shutdownSnapshotPath())
+	return os.Remove(s.shutdownSnapshotPath())
+}
@@ -0,0 +1,131 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/balancer"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_ShutdownSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "olric-snapshot")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	c1 := testutil.NewConfig()
+	c1.DMaps.ShutdownSnapshotDir = dir
+	cluster1 := testcluster.New(NewService)
+	s1 := cluster1.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+
+	// Graceful stop
+	cluster1.Shutdown()
+
+	path := filepath.Join(dir, shutdownSnapshotFile)
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	c2 := testutil.NewConfig()
+	c2.DMaps.ShutdownSnapshotDir = dir
+	cluster2 := testcluster.New(NewService)
+	s2 := cluster2.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+	defer cluster2.Shutdown()
+
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The service is started in the background.
+	err = testutil.TryWithInterval(50, 20*time.Millisecond, func() error {
+		_, err := os.Stat(path)
+		if err == nil {
+			return errors.New("snapshot file still exists")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		e, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), e.Value())
+	}
+}
+
+func TestDMap_ShutdownSnapshot_RestoreDeletedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "olric-snapshot")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	ctx := context.Background()
+	c1 := testutil.NewConfig()
+	c1.DMaps.ShutdownSnapshotDir = dir
+	cluster1 := testcluster.New(NewService)
+	s1 := cluster1.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	require.NoError(t, dm1.Put(ctx, "mykey", "myvalue", nil))
+	cluster1.Shutdown()
+
+	// The key is deleted while the member with the snapshot is down.
+	cluster2 := testcluster.New(NewService)
+	s2 := cluster2.AddMember(nil).(*Service)
+	defer cluster2.Shutdown()
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+	require.NoError(t, dm2.Put(ctx, "mykey", "myvalue", nil))
+	_, err = dm2.Delete(ctx, "mykey")
+	require.NoError(t, err)
+
+	c3 := testutil.NewConfig()
+	c3.DMaps.ShutdownSnapshotDir = dir
+	e3 := testcluster.NewEnvironment(c3)
+	cluster2.AddMember(e3)
+
+	// The service is started in the background. Move the restored fragments to their owners
+	// after the restore.
+	err = testutil.TryWithInterval(50, 20*time.Millisecond, func() error {
+		_, err := os.Stat(filepath.Join(dir, shutdownSnapshotFile))
+		if err == nil {
+			return errors.New("snapshot file still exists")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	e3.Get("balancer").(*balancer.Balancer).BalanceEagerly()
+
+	// There are no tombstones, the snapshot brings the deleted key back.
+	_, err = dm2.Get(ctx, "mykey")
+	require.NoError(t, err)
+}
@@ -0,0 +1,177 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// StandbyKeyPrefix is the prefix of the keys that keep the standby values written by SetStandby.
+// The key itself keeps the active value, so Get returns the active slot. The standby value is
+// stored in the same DMap with the key StandbyKeyPrefix + key.
+const StandbyKeyPrefix = "olric.standby."
+
+// ErrNoStandby is returned by PromoteStandby when the key has no standby value.
+var ErrNoStandby = errors.New("no standby value")
+
+// putSlot writes the value of a slot and sets its TTL. ttl is the expiry time in milliseconds,
+// zero means no expiry.
+func (dm *DMap) putSlot(ctx context.Context, key string, value []byte, ttl int64) error {
+	e := newEnv(ctx)
+	e.dmap = dm.name
+	e.key = key
+	e.value = value
+	if ttl != 0 {
+		e.putConfig.HasPX = true
+		e.putConfig.PX = time.Until(time.UnixMilli(ttl))
+	}
+	return dm.put(e)
+}
+
+// setStandby runs on the partition owner of the key. The standby value is written under the
+// fine-grained lock of the key, so it doesn't interleave with promoteStandby. It expires with
+// the active value.
+func (dm *DMap) setStandby(e *env, value []byte) error {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	var ttl int64
+	active, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if active != nil {
+		ttl = active.TTL()
+	}
+	return dm.putSlot(e.ctx, StandbyKeyPrefix+e.key, value, ttl)
+}
+
+// promoteStandby runs on the partition owner of the key. The active value is replaced first,
+// then the previous active value is moved to the standby key. The active value is restored
+// if the standby key cannot be written.
+func (dm *DMap) promoteStandby(e *env) error {
+	atomicKey := e.dmap + e.key
+	dm.s.locker.Lock(atomicKey)
+	defer func() {
+		err := dm.s.locker.Unlock(atomicKey)
+		if err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to release the fine grained lock for key: %s on DMap: %s: %v", e.key, e.dmap, err)
+		}
+	}()
+
+	standbyKey := StandbyKeyPrefix + e.key
+	standby, err := dm.Get(e.ctx, standbyKey)
+	if errors.Is(err, ErrKeyNotFound) {
+		return fmt.Errorf("%w: %s", ErrNoStandby, e.key)
+	}
+	if err != nil {
+		return err
+	}
+	active, err := dm.Get(e.ctx, e.key)
+	if errors.Is(err, ErrKeyNotFound) {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// The TTL of the key is preserved.
+	ttl := standby.TTL()
+	if active != nil {
+		ttl = active.TTL()
+	}
+	if err = dm.putSlot(e.ctx, e.key, standby.Value(), ttl); err != nil {
+		return err
+	}
+
+	if active == nil {
+		_, err = dm.deleteKeys(e.ctx, standbyKey)
+	} else {
+		err = dm.putSlot(e.ctx, standbyKey, active.Value(), ttl)
+	}
+	if err != nil {
+		var rerr error
+		if active == nil {
+			_, rerr = dm.deleteKeys(e.ctx, e.key)
+		} else {
+			rerr = dm.putSlot(e.ctx, e.key, active.Value(), ttl)
+		}
+		if rerr != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to restore the active value of key: %s on DMap: %s: %v", e.key, e.dmap, rerr)
+		}
+		return err
+	}
+	return nil
+}
+
+// SetStandby stages the value in the standby slot of the key. The active slot is the value
+// of the key, it's preserved and Get keeps returning it. The standby value is stored with the
+// key StandbyKeyPrefix + key and expires with the active value. The operation runs on the
+// partition owner of the key.
+func (dm *DMap) SetStandby(ctx context.Context, key string, value []byte) error {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.setStandby(e, value)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewSetStandby(dm.name, key, value).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
+
+// PromoteStandby atomically swaps the active and the standby slots of the key, the previous
+// active value becomes the standby one. Calling it again rolls the change back. It returns
+// ErrNoStandby if there is no standby value. The operation runs on the partition owner of the key.
+func (dm *DMap) PromoteStandby(ctx context.Context, key string) error {
+	member := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		return dm.promoteStandby(e)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewPromoteStandby(dm.name, key).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return protocol.ConvertError(err)
+	}
+	return protocol.ConvertError(cmd.Err())
+}
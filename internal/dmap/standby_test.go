@@ -0,0 +1,130 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_PromoteStandby(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// slot returns the value of the key, or an empty string if it doesn't exist.
+	slot := func(key string) string {
+		entry, err := dm1.Get(ctx, key)
+		if errors.Is(err, ErrKeyNotFound) {
+			return ""
+		}
+		require.NoError(t, err)
+		return string(entry.Value())
+	}
+
+	require.NoError(t, dm1.SetStandby(ctx, "mykey", []byte("v1")))
+	require.Equal(t, "", slot("mykey"))
+	require.NoError(t, dm2.PromoteStandby(ctx, "mykey"))
+	require.Equal(t, "v1", slot("mykey"))
+	require.Equal(t, "", slot(StandbyKeyPrefix+"mykey"))
+
+	// Stage the next value, the active one doesn't change.
+	require.NoError(t, dm2.SetStandby(ctx, "mykey", []byte("v2")))
+	require.Equal(t, "v1", slot("mykey"))
+	require.Equal(t, "v2", slot(StandbyKeyPrefix+"mykey"))
+
+	require.NoError(t, dm1.PromoteStandby(ctx, "mykey"))
+	require.Equal(t, "v2", slot("mykey"))
+	require.Equal(t, "v1", slot(StandbyKeyPrefix+"mykey"))
+
+	// Roll back.
+	require.NoError(t, dm2.PromoteStandby(ctx, "mykey"))
+	require.Equal(t, "v1", slot("mykey"))
+	require.Equal(t, "v2", slot(StandbyKeyPrefix+"mykey"))
+
+	t.Run("Preserve TTL", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "ttlkey", []byte("v1"), &PutConfig{HasEX: true, EX: time.Hour}))
+		active, err := dm1.Get(ctx, "ttlkey")
+		require.NoError(t, err)
+
+		require.NoError(t, dm2.SetStandby(ctx, "ttlkey", []byte("v2")))
+		require.NoError(t, dm1.PromoteStandby(ctx, "ttlkey"))
+
+		for _, key := range []string{"ttlkey", StandbyKeyPrefix + "ttlkey"} {
+			entry, err := dm2.Get(ctx, key)
+			require.NoError(t, err)
+			require.InDelta(t, active.TTL(), entry.TTL(), float64(time.Second.Milliseconds()))
+		}
+	})
+
+	t.Run("No standby value", func(t *testing.T) {
+		err := dm1.PromoteStandby(ctx, "absent")
+		require.ErrorIs(t, err, ErrNoStandby)
+	})
+}
+
+func TestDMap_promoteStandbyCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the key.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mykey")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+	require.NoError(t, owner.SetStandby(ctx, "mykey", []byte("v1")))
+
+	// Hold the fine-grained lock of the key on the owner. The command must wait for
+	// it and promote the standby value staged in the meantime.
+	owner.s.locker.Lock("mydmap" + "mykey")
+	result := make(chan error, 1)
+	go func() {
+		cmd := protocol.NewPromoteStandby("mydmap", "mykey").Command(ctx)
+		rc := other.client.Get(other.rt.This().String())
+		_ = rc.Process(ctx, cmd)
+		result <- cmd.Err()
+	}()
+
+	<-time.After(100 * time.Millisecond)
+	require.NoError(t, owner.Put(ctx, StandbyKeyPrefix+"mykey", []byte("v2"), nil))
+	require.NoError(t, owner.s.locker.Unlock("mydmap"+"mykey"))
+	require.NoError(t, <-result)
+
+	entry, err := owner.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(entry.Value()))
+}
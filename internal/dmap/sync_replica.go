@@ -0,0 +1,234 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// ErrReplicaSyncRateLimited is returned when a replica sync is requested earlier than
+// config.DMaps.ReplicaSyncInterval after the previous one on the same member.
+var ErrReplicaSyncRateLimited = errors.New("replica sync is rate limited")
+
+// fragmentSnapshot is a full copy of a fragment. Unlike fragmentPack, it replaces the
+// fragment on the receiver instead of being merged into it.
+type fragmentSnapshot struct {
+	PartID   uint64
+	Name     string
+	Payloads [][]byte
+}
+
+// replicaSyncLimiter allows one replica sync per config.DMaps.ReplicaSyncInterval.
+type replicaSyncLimiter struct {
+	mtx  sync.Mutex
+	last time.Time
+}
+
+func (l *replicaSyncLimiter) allow(interval time.Duration) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	now := time.Now()
+	if !l.last.IsZero() && now.Sub(l.last) < interval {
+		return false
+	}
+	l.last = now
+	return true
+}
+
+// exportCopy copies the entries of the fragment into a new storage engine and exports
+// the tables of the copy. The fragment itself is not modified. The caller must hold the
+// fragment's lock.
+func (dm *DMap) exportCopy(f *fragment) ([][]byte, error) {
+	engine, err := dm.newEngine()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := engine.Close(); err != nil {
+			dm.s.log.V(3).Printf("[ERROR] Failed to close the copy of a fragment: %v", err)
+		}
+	}()
+
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		err = engine.Put(hkey, e)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var payloads [][]byte
+	i := engine.TransferIterator()
+	for i.Next() {
+		payload, index, err := i.Export()
+		if errors.Is(err, io.EOF) {
+			// Only the recycled tables are left.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		payloads = append(payloads, payload)
+		if err = i.Drop(index); err != nil {
+			return nil, err
+		}
+	}
+	return payloads, nil
+}
+
+// replaceFragment replaces the storage engine of the fragment with the tables in the
+// payloads. The readers and the writers of the fragment observe either the old or the
+// new content.
+func (dm *DMap) replaceFragment(part *partitions.Partition, payloads [][]byte) error {
+	engine, err := dm.newEngine()
+	if err != nil {
+		return err
+	}
+	for _, payload := range payloads {
+		err = engine.Import(payload, func(hkey uint64, e storage.Entry) error {
+			return engine.Put(hkey, e)
+		})
+		if err != nil {
+			_ = engine.Close()
+			return err
+		}
+	}
+
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		_ = engine.Close()
+		return err
+	}
+
+	f.Lock()
+	old := f.storage
+	f.storage = engine
+	f.Unlock()
+
+	if err = old.Close(); err != nil {
+		return err
+	}
+	return old.Destroy()
+}
+
+// syncReplica sends a copy of the primary fragment to the replica owner. The fragment is
+// locked until the replica is replaced, so the writes wait for the sync. It returns the
+// number of bytes transferred.
+func (dm *DMap) syncReplica(ctx context.Context, partID uint64, owner discovery.Member) (int64, error) {
+	snapshot := &fragmentSnapshot{
+		PartID: partID,
+		Name:   dm.name,
+	}
+
+	part := dm.s.primary.PartitionByID(partID)
+	f, err := dm.loadFragment(part)
+	if err != nil && !errors.Is(err, errFragmentNotFound) {
+		return 0, err
+	}
+	if err == nil {
+		// An absent fragment is sent as an empty copy, the replica is emptied.
+		f.Lock()
+		defer f.Unlock()
+
+		snapshot.Payloads, err = dm.exportCopy(f)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	data, err := msgpack.Marshal(snapshot)
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := protocol.NewReplaceFragment(data).Command(ctx)
+	rc := dm.s.client.Get(owner.String())
+	err = rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	if err = cmd.Err(); err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return int64(len(data)), nil
+}
+
+// SyncReplica replaces the replica of the DMap's fragment on the given partition and
+// replica owner with a full copy of the primary fragment. It's useful to repair a stale
+// replica without waiting for read-repair. The request is redirected to the primary
+// owner of the partition. A member runs one sync per config.DMaps.ReplicaSyncInterval,
+// it returns ErrReplicaSyncRateLimited otherwise. It returns the number of bytes
+// transferred.
+func (s *Service) SyncReplica(ctx context.Context, name string, partID uint64, member string) (int64, error) {
+	if partID >= s.config.PartitionCount {
+		return 0, fmt.Errorf("%w: invalid partition id: %d", protocol.ErrInvalidArgument, partID)
+	}
+
+	owner := s.primary.PartitionByID(partID).Owner()
+	if !owner.CompareByID(s.rt.This()) {
+		// Redirect to the primary owner.
+		cmd := protocol.NewSyncReplica(name, partID, member).Command(ctx)
+		rc := s.client.Get(owner.String())
+		err := rc.Process(ctx, cmd)
+		if err != nil {
+			return 0, protocol.ConvertError(err)
+		}
+		n, err := cmd.Result()
+		if err != nil {
+			return 0, protocol.ConvertError(err)
+		}
+		return n, nil
+	}
+
+	var replica *discovery.Member
+	for _, backup := range s.backup.PartitionByID(partID).Owners() {
+		if backup.String() == member {
+			replica = &backup
+			break
+		}
+	}
+	if replica == nil {
+		return 0, fmt.Errorf("%w: %s is not a replica owner of PartID: %d",
+			protocol.ErrInvalidArgument, member, partID)
+	}
+
+	if !s.syncs.allow(s.config.DMaps.ReplicaSyncInterval) {
+		return 0, ErrReplicaSyncRateLimited
+	}
+
+	dm, err := s.NewDMap(name)
+	if err != nil {
+		return 0, err
+	}
+	n, err := dm.syncReplica(ctx, partID, *replica)
+	if err != nil {
+		return 0, err
+	}
+	s.log.V(2).Printf("[INFO] Synced the replica of DMap: %s on PartID: %d to %s, %d bytes transferred",
+		name, partID, member, n)
+	return n, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func (s *Service) syncReplicaCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	syncReplicaCmd, err := protocol.ParseSyncReplicaCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	n, err := s.SyncReplica(s.ctx, syncReplicaCmd.DMap, syncReplicaCmd.PartID, syncReplicaCmd.Member)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt64(n)
+}
+
+func (s *Service) replaceFragmentCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	replaceFragmentCmd, err := protocol.ParseReplaceFragmentCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	snapshot := &fragmentSnapshot{}
+	err = msgpack.Unmarshal(replaceFragmentCmd.Payload, snapshot)
+	if err != nil {
+		s.log.V(2).Printf("[ERROR] Failed to unmarshal DMap: %v", err)
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	err = s.validateFragmentPack(&fragmentPack{
+		PartID: snapshot.PartID,
+		Kind:   partitions.BACKUP,
+		Name:   snapshot.Name,
+	})
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	dm, err := s.newDMap(snapshot.Name)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	part := s.backup.PartitionByID(snapshot.PartID)
+	err = dm.replaceFragment(part, snapshot.Payloads)
+	if err != nil {
+		s.log.V(2).Printf("[ERROR] Failed to replace the replica of DMap: %s on PartID: %d: %v",
+			snapshot.Name, snapshot.PartID, err)
+		protocol.WriteError(conn, err)
+		return
+	}
+	s.log.V(2).Printf("[INFO] Replaced the replica of DMap: %s on PartID: %d", snapshot.Name, snapshot.PartID)
+
+	conn.WriteString(protocol.StatusOK)
+}
@@ -0,0 +1,106 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/buraksezer/olric/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func fragmentEntries(t *testing.T, dm *DMap, part *partitions.Partition) map[uint64]string {
+	f, err := dm.loadFragment(part)
+	require.NoError(t, err)
+
+	f.RLock()
+	defer f.RUnlock()
+
+	entries := make(map[uint64]string)
+	f.storage.Range(func(hkey uint64, e storage.Entry) bool {
+		entries[hkey] = string(e.Value())
+		return true
+	})
+	return entries
+}
+
+func TestDMap_SyncReplica(t *testing.T) {
+	cluster := testcluster.New(NewService)
+
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	s1 := cluster.AddMember(testcluster.NewEnvironment(c1)).(*Service)
+
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	s2 := cluster.AddMember(testcluster.NewEnvironment(c2)).(*Service)
+
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), nil))
+	}
+
+	hkey := partitions.HKey("mydmap", testutil.ToKey(0))
+	partID := s1.primary.PartitionByHKey(hkey).ID()
+
+	primary, replica := s1, s2
+	if !s1.primary.PartitionByID(partID).Owner().CompareByID(s1.rt.This()) {
+		primary, replica = s2, s1
+	}
+	primaryDM, err := primary.NewDMap("mydmap")
+	require.NoError(t, err)
+	replicaDM, err := replica.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	expected := fragmentEntries(t, primaryDM, primary.primary.PartitionByID(partID))
+	require.NotEmpty(t, expected)
+
+	// Make the replica stale.
+	f, err := replicaDM.loadFragment(replica.backup.PartitionByID(partID))
+	require.NoError(t, err)
+	f.Lock()
+	require.NoError(t, f.storage.Delete(hkey))
+	stale := f.storage.NewEntry()
+	stale.SetKey("stale-key")
+	stale.SetValue([]byte("stale-value"))
+	require.NoError(t, f.storage.Put(1, stale))
+	f.Unlock()
+	require.NotEqual(t, expected, fragmentEntries(t, replicaDM, replica.backup.PartitionByID(partID)))
+
+	// The request is redirected to the primary owner.
+	n, err := replica.SyncReplica(ctx, "mydmap", partID, replica.rt.This().String())
+	require.NoError(t, err)
+	require.Greater(t, n, int64(0))
+	require.Equal(t, expected, fragmentEntries(t, replicaDM, replica.backup.PartitionByID(partID)))
+
+	t.Run("Rate limited", func(t *testing.T) {
+		_, err := primary.SyncReplica(ctx, "mydmap", partID, replica.rt.This().String())
+		require.ErrorIs(t, err, ErrReplicaSyncRateLimited)
+	})
+
+	t.Run("Not a replica owner", func(t *testing.T) {
+		_, err := primary.SyncReplica(ctx, "mydmap", partID, primary.rt.This().String())
+		require.ErrorIs(t, err, protocol.ErrInvalidArgument)
+	})
+}
@@ -0,0 +1,44 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrTimeout is returned when an operation exceeds the read or write timeout of the DMap.
+var ErrTimeout = errors.New("dmap operation timeout")
+
+// RunWithTimeout runs f with a context that expires after the timeout and returns ErrTimeout
+// if the deadline is exceeded. f runs on the calling goroutine, it has to pass the context
+// down to the lock waits and the network calls, so that nothing keeps running after
+// RunWithTimeout returns. There is no deadline if the timeout is zero.
+func RunWithTimeout(ctx context.Context, timeout time.Duration, f func(ctx context.Context) error) error {
+	if timeout <= 0 {
+		return f(ctx)
+	}
+
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := f(tctx)
+	if err != nil && errors.Is(tctx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+		// The network calls may fail with an I/O timeout instead of the context error.
+		return ErrTimeout
+	}
+	return err
+}
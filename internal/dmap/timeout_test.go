@@ -0,0 +1,87 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_Timeout(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	c := testutil.NewConfig()
+	c.DMaps.Custom = map[string]config.DMap{
+		"fast": {
+			ReadTimeout:  50 * time.Millisecond,
+			WriteTimeout: 50 * time.Millisecond,
+		},
+	}
+	s := cluster.AddMember(testcluster.NewEnvironment(c)).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	fast, err := s.NewDMap("fast")
+	require.NoError(t, err)
+	bulk, err := s.NewDMap("bulk")
+	require.NoError(t, err)
+
+	key := testutil.ToKey(1)
+	// lockFragment holds the lock of the key's fragment to slow down the operations on it.
+	lockFragment := func(dm *DMap, d time.Duration) {
+		part := dm.getPartitionByHKey(partitions.HKey(dm.name, key), partitions.PRIMARY)
+		f, err := dm.loadOrCreateFragment(part)
+		require.NoError(t, err)
+		f.Lock()
+		time.AfterFunc(d, f.Unlock)
+	}
+
+	lockFragment(fast, 300*time.Millisecond)
+	err = fast.Put(ctx, key, testutil.ToVal(1), nil)
+	require.ErrorIs(t, err, ErrTimeout)
+
+	_, err = fast.Get(ctx, key)
+	require.ErrorIs(t, err, ErrTimeout)
+
+	_, err = fast.Delete(ctx, key)
+	require.ErrorIs(t, err, ErrTimeout)
+
+	// The DMaps without a timeout wait for the slow operation.
+	lockFragment(bulk, 300*time.Millisecond)
+	require.NoError(t, bulk.Put(ctx, key, testutil.ToVal(1), nil))
+	_, err = bulk.Get(ctx, key)
+	require.NoError(t, err)
+
+	// The fragment of the fast DMap is released too.
+	require.NoError(t, fast.Put(ctx, key, testutil.ToVal(2), nil))
+	expected, err := fast.Get(ctx, key)
+	require.NoError(t, err)
+
+	// A write that times out is not applied after the lock is released.
+	lockFragment(fast, 100*time.Millisecond)
+	err = fast.Put(ctx, key, testutil.ToVal(3), nil)
+	require.ErrorIs(t, err, ErrTimeout)
+	<-time.After(200 * time.Millisecond)
+
+	entry, err := fast.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, expected.Value(), entry.Value())
+}
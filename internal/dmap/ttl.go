@@ -0,0 +1,67 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+)
+
+// The replies of the PTTL and TTL commands for the keys without a TTL and the absent
+// keys, same as Redis.
+const (
+	replyNoExpiry    int64 = -1
+	replyKeyNotFound int64 = -2
+)
+
+// TTL returns the remaining time to live of the key. It returns zero if the key has no
+// expiry, and ErrKeyNotFound if the key does not exist. The TTL is read on the partition
+// owner, the value is not transferred and the last access time of the key is not updated.
+func (dm *DMap) TTL(ctx context.Context, key string) (time.Duration, error) {
+	hkey := partitions.HKey(dm.name, key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		ttl, err := dm.ttlOnFragment(hkey)
+		if err != nil {
+			return 0, err
+		}
+		if ttl == NoExpiry {
+			return 0, nil
+		}
+		return time.Duration(ttl) * time.Millisecond, nil
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewPTTL(dm.name, key).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	ttl, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	switch ttl {
+	case replyKeyNotFound:
+		return 0, ErrKeyNotFound
+	case replyNoExpiry:
+		return 0, nil
+	}
+	return time.Duration(ttl) * time.Millisecond, nil
+}
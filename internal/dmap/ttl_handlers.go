@@ -0,0 +1,72 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"errors"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+// ttlReply returns the remaining TTL in the unit, -1 if the key has no expiry and -2 if
+// the key does not exist.
+func (s *Service) ttlReply(dmap, key string, unit time.Duration) (int64, error) {
+	dm, err := s.getOrCreateDMap(dmap)
+	if err != nil {
+		return 0, err
+	}
+	ttl, err := dm.TTL(s.ctx, key)
+	if errors.Is(err, ErrKeyNotFound) {
+		return replyKeyNotFound, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if ttl == 0 {
+		return replyNoExpiry, nil
+	}
+	// Round to the nearest unit, like Redis.
+	return int64((ttl + unit/2) / unit), nil
+}
+
+func (s *Service) pttlCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	pttlCmd, err := protocol.ParsePTTLCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	ttl, err := s.ttlReply(pttlCmd.DMap, pttlCmd.Key, time.Millisecond)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt64(ttl)
+}
+
+func (s *Service) ttlCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	ttlCmd, err := protocol.ParseTTLCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	ttl, err := s.ttlReply(ttlCmd.DMap, ttlCmd.Key, time.Second)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	conn.WriteInt64(ttl)
+}
@@ -0,0 +1,156 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/discovery"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// NoExpiry is the TTL returned by GetTTLMany for the keys without a TTL.
+const NoExpiry int64 = -1
+
+// ttlOnFragment returns the remaining TTL of the key in milliseconds on the primary
+// copy. It reads the TTL only, so the last access time of the key is not updated.
+func (dm *DMap) ttlOnFragment(hkey uint64) (int64, error) {
+	part := dm.getPartitionByHKey(hkey, partitions.PRIMARY)
+	f, err := dm.loadFragment(part)
+	if errors.Is(err, errFragmentNotFound) {
+		return 0, ErrKeyNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	f.RLock()
+	defer f.RUnlock()
+
+	ttl, err := f.storage.GetTTL(hkey)
+	if errors.Is(err, storage.ErrKeyNotFound) {
+		return 0, ErrKeyNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	if ttl == 0 {
+		return NoExpiry, nil
+	}
+	remaining := ttl - time.Now().UnixNano()/1000000
+	if remaining <= 0 {
+		// Expired but not evicted yet.
+		return 0, ErrKeyNotFound
+	}
+	return remaining, nil
+}
+
+// localTTLs returns the remaining TTLs of the keys stored on this member. The absent
+// keys are omitted.
+func (dm *DMap) localTTLs(keys []string) (map[string]int64, error) {
+	ttls := make(map[string]int64)
+	for _, key := range keys {
+		ttl, err := dm.ttlOnFragment(partitions.HKey(dm.name, key))
+		if errors.Is(err, ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ttls[key] = ttl
+	}
+	return ttls, nil
+}
+
+// parseTTLs parses a flat list of key and remaining TTL in milliseconds pairs.
+func parseTTLs(values []interface{}) (map[string]int64, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("invalid TTL response")
+	}
+	ttls := make(map[string]int64)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid key: %v", values[i])
+		}
+		ttl, ok := values[i+1].(int64)
+		if !ok {
+			return nil, fmt.Errorf("invalid TTL: %v", values[i+1])
+		}
+		ttls[key] = ttl
+	}
+	return ttls, nil
+}
+
+// GetTTLMany returns the remaining TTLs of the keys in milliseconds. The keys without
+// a TTL are mapped to NoExpiry and the absent keys are omitted. The keys are grouped by
+// their partition owners, every owner is queried once and concurrently. The values are
+// not transferred.
+func (dm *DMap) GetTTLMany(ctx context.Context, keys []string) (map[string]int64, error) {
+	owners := make(map[string]discovery.Member)
+	groups := make(map[string][]string)
+	for _, key := range keys {
+		owner := dm.s.primary.PartitionByHKey(partitions.HKey(dm.name, key)).Owner()
+		owners[owner.String()] = owner
+		groups[owner.String()] = append(groups[owner.String()], key)
+	}
+
+	result := make(map[string]int64)
+	var mtx sync.Mutex
+
+	var g errgroup.Group
+	for name, items := range groups {
+		owner, group := owners[name], items
+		g.Go(func() error {
+			var ttls map[string]int64
+			if owner.CompareByID(dm.s.rt.This()) {
+				var err error
+				ttls, err = dm.localTTLs(group)
+				if err != nil {
+					return err
+				}
+			} else {
+				cmd := protocol.NewGetTTLMany(dm.name, group).SetLocal().Command(ctx)
+				rc := dm.s.client.Get(owner.String())
+				if err := rc.Process(ctx, cmd); err != nil {
+					return protocol.ConvertError(err)
+				}
+				var err error
+				ttls, err = parseTTLs(cmd.Val())
+				if err != nil {
+					return err
+				}
+			}
+
+			mtx.Lock()
+			for key, ttl := range ttls {
+				result[key] = ttl
+			}
+			mtx.Unlock()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
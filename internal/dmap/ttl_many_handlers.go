@@ -0,0 +1,50 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/tidwall/redcon"
+)
+
+func (s *Service) getTTLManyCommandHandler(conn redcon.Conn, cmd redcon.Command) {
+	getTTLManyCmd, err := protocol.ParseGetTTLManyCommand(cmd)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+	dm, err := s.getOrCreateDMap(getTTLManyCmd.DMap)
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	var ttls map[string]int64
+	if getTTLManyCmd.Local {
+		ttls, err = dm.localTTLs(getTTLManyCmd.Keys)
+	} else {
+		ttls, err = dm.GetTTLMany(s.ctx, getTTLManyCmd.Keys)
+	}
+	if err != nil {
+		protocol.WriteError(conn, err)
+		return
+	}
+
+	conn.WriteArray(len(ttls) * 2)
+	for key, ttl := range ttls {
+		conn.WriteBulkString(key)
+		conn.WriteInt64(ttl)
+	}
+}
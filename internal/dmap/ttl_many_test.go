@@ -0,0 +1,70 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_GetTTLMany(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	var keys []string
+	for i := 0; i < 100; i++ {
+		key := testutil.ToKey(i)
+		keys = append(keys, key)
+		if i%10 == 0 {
+			// Without a TTL.
+			require.NoError(t, dm1.Put(ctx, key, testutil.ToVal(i), nil))
+			continue
+		}
+		require.NoError(t, dm1.Put(ctx, key, testutil.ToVal(i), &PutConfig{
+			HasPX: true,
+			PX:    time.Duration(i) * time.Minute,
+		}))
+	}
+	keys = append(keys, "missing-key")
+
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+	ttls, err := dm2.GetTTLMany(ctx, keys)
+	require.NoError(t, err)
+	require.Len(t, ttls, 100)
+	require.NotContains(t, ttls, "missing-key")
+
+	for i := 0; i < 100; i++ {
+		ttl := ttls[testutil.ToKey(i)]
+		if i%10 == 0 {
+			require.Equal(t, NoExpiry, ttl)
+			continue
+		}
+		expected := (time.Duration(i) * time.Minute).Milliseconds()
+		require.LessOrEqual(t, ttl, expected)
+		require.Greater(t, ttl, expected-(10*time.Second).Milliseconds())
+	}
+}
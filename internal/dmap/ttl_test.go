@@ -0,0 +1,110 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDMap_TTL(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		var pc *PutConfig
+		if i%2 == 0 {
+			pc = &PutConfig{
+				HasPX: true,
+				PX:    time.Minute,
+			}
+		}
+		require.NoError(t, dm1.Put(ctx, testutil.ToKey(i), testutil.ToVal(i), pc))
+	}
+
+	// The keys are distributed, some of the calls are redirected to the owners.
+	for _, dm := range []*DMap{dm1, dm2} {
+		t.Run("With expiry", func(t *testing.T) {
+			for i := 0; i < 10; i += 2 {
+				ttl, err := dm.TTL(ctx, testutil.ToKey(i))
+				require.NoError(t, err)
+				require.LessOrEqual(t, ttl, time.Minute)
+				require.Greater(t, ttl, time.Minute-10*time.Second)
+			}
+		})
+
+		t.Run("Without expiry", func(t *testing.T) {
+			for i := 1; i < 10; i += 2 {
+				ttl, err := dm.TTL(ctx, testutil.ToKey(i))
+				require.NoError(t, err)
+				require.Equal(t, time.Duration(0), ttl)
+			}
+		})
+
+		t.Run("Missing key", func(t *testing.T) {
+			_, err := dm.TTL(ctx, "missing-key")
+			require.ErrorIs(t, err, ErrKeyNotFound)
+		})
+	}
+}
+
+func TestDMap_TTL_pttlCommandHandler(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm, err := s.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	require.NoError(t, dm.Put(ctx, "with-expiry", "value", &PutConfig{
+		HasPX: true,
+		PX:    time.Minute,
+	}))
+	require.NoError(t, dm.Put(ctx, "without-expiry", "value", nil))
+
+	rc := s.client.Get(s.rt.This().String())
+
+	pttl := func(key string) int64 {
+		cmd := protocol.NewPTTL("mydmap", key).Command(ctx)
+		require.NoError(t, rc.Process(ctx, cmd))
+		ttl, err := cmd.Result()
+		require.NoError(t, err)
+		return ttl
+	}
+	require.Greater(t, pttl("with-expiry"), (50 * time.Second).Milliseconds())
+	require.Equal(t, int64(-1), pttl("without-expiry"))
+	require.Equal(t, int64(-2), pttl("missing-key"))
+
+	cmd := protocol.NewTTL("mydmap", "with-expiry").Command(ctx)
+	require.NoError(t, rc.Process(ctx, cmd))
+	ttl, err := cmd.Result()
+	require.NoError(t, err)
+	require.Equal(t, int64(60), ttl)
+}
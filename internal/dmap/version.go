@@ -0,0 +1,125 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+// ErrVersionMismatch is returned by PutWithVersion when the current version of the key
+// is not equal to the expected version.
+var ErrVersionMismatch = errors.New("version mismatch")
+
+// versionOf returns the version in the metadata of the entry. An entry of a storage engine
+// that doesn't keep versions has version 0.
+func versionOf(entry storage.Entry) int64 {
+	if ve, ok := entry.(storage.VersionedEntry); ok {
+		return ve.Version()
+	}
+	return 0
+}
+
+// putWithVersion runs on the partition owner of the key. The version check and the write
+// run under the fragment lock, like the NX and XX conditions of Put.
+func (dm *DMap) putWithVersion(e *env, expectedVersion int64) (int64, error) {
+	part := dm.getPartitionByHKey(e.hkey, partitions.PRIMARY)
+	f, err := dm.loadOrCreateFragment(part)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := f.storage.NewEntry().(storage.VersionedEntry); !ok {
+		return 0, fmt.Errorf("%w: %s doesn't keep versions", storage.ErrNotImplemented, f.storage.Name())
+	}
+
+	e.fragment = f
+	if err = f.lockContext(e.ctx); err != nil {
+		return 0, err
+	}
+	if dm.isWriteFenced(e.hkey, f) {
+		f.Unlock()
+		// The partition has been moved, run it on the current owner.
+		return dm.PutWithVersion(e.ctx, e.key, e.value, expectedVersion)
+	}
+	defer f.Unlock()
+
+	var current int64
+	entry, err := f.storage.Get(e.hkey)
+	switch {
+	case err == nil:
+		if !isKeyExpired(entry.TTL()) {
+			current = versionOf(entry)
+			// Preserve the TTL of the key.
+			if entry.TTL() != 0 {
+				e.putConfig.HasPXAT = true
+				e.putConfig.PXAT = time.Duration(entry.TTL()) * time.Millisecond
+			}
+		}
+	case errors.Is(err, storage.ErrKeyNotFound):
+	default:
+		return 0, err
+	}
+	if current != expectedVersion {
+		return 0, fmt.Errorf("%w: current: %d, expected: %d", ErrVersionMismatch, current, expectedVersion)
+	}
+
+	e.version = current + 1
+	if err = dm.putOnFragment(e); err != nil {
+		return 0, err
+	}
+	return e.version, nil
+}
+
+// PutWithVersion sets the value of the key if its current version is equal to expectedVersion,
+// and increments the version. An absent key has version 0. It returns the new version, or
+// ErrVersionMismatch if the version is different. The version is kept in the metadata of
+// the entry, so Get returns the value and a Put without a version resets the version to 0.
+// The TTL of the key is preserved. The operation runs on the partition owner of the key.
+func (dm *DMap) PutWithVersion(ctx context.Context, key string, value []byte, expectedVersion int64) (int64, error) {
+	if expectedVersion < 0 {
+		return 0, fmt.Errorf("%w: negative version: %d", protocol.ErrInvalidArgument, expectedVersion)
+	}
+
+	hkey := partitions.HKey(dm.name, key)
+	member := dm.s.primary.PartitionByHKey(hkey).Owner()
+	if member.CompareByName(dm.s.rt.This()) {
+		e := newEnv(ctx)
+		e.dmap = dm.name
+		e.key = key
+		e.hkey = hkey
+		e.value = make([]byte, len(value))
+		copy(e.value, value)
+		return dm.putWithVersion(e, expectedVersion)
+	}
+
+	// Redirect to the partition owner.
+	cmd := protocol.NewPutWithVersion(dm.name, key, value, expectedVersion).Command(ctx)
+	rc := dm.s.client.Get(member.String())
+	err := rc.Process(ctx, cmd)
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	version, err := cmd.Result()
+	if err != nil {
+		return 0, protocol.ConvertError(err)
+	}
+	return version, nil
+}
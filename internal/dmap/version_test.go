@@ -0,0 +1,156 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_PutWithVersion(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// All writers expect the same version, only one of them succeeds in every round.
+	for expected := int64(0); expected < 5; expected++ {
+		var succeeded int32
+		var errGr errgroup.Group
+		for i := 0; i < 20; i++ {
+			dm := dm1
+			if i%2 == 0 {
+				dm = dm2
+			}
+			errGr.Go(func() error {
+				version, err := dm.PutWithVersion(ctx, "mykey", []byte("value"), expected)
+				if errors.Is(err, ErrVersionMismatch) {
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if version != expected+1 {
+					return errors.New("unexpected version")
+				}
+				atomic.AddInt32(&succeeded, 1)
+				return nil
+			})
+		}
+		require.NoError(t, errGr.Wait())
+		require.Equal(t, int32(1), succeeded)
+	}
+
+	version, err := dm2.PutWithVersion(ctx, "mykey", []byte("latest"), 5)
+	require.NoError(t, err)
+	require.Equal(t, int64(6), version)
+
+	t.Run("Get returns the value", func(t *testing.T) {
+		entry, err := dm1.Get(ctx, "mykey")
+		require.NoError(t, err)
+		require.Equal(t, []byte("latest"), entry.Value())
+		require.Equal(t, int64(6), versionOf(entry))
+	})
+
+	t.Run("TTL is preserved", func(t *testing.T) {
+		require.NoError(t, dm1.Expire(ctx, "mykey", time.Hour))
+		version, err := dm2.PutWithVersion(ctx, "mykey", []byte("with-ttl"), 6)
+		require.NoError(t, err)
+		require.Equal(t, int64(7), version)
+
+		entry, err := dm1.Get(ctx, "mykey")
+		require.NoError(t, err)
+		require.NotZero(t, entry.TTL())
+		require.Equal(t, int64(7), versionOf(entry))
+	})
+
+	t.Run("Put resets the version", func(t *testing.T) {
+		require.NoError(t, dm1.Put(ctx, "reset", []byte("value"), nil))
+		_, err := dm1.PutWithVersion(ctx, "reset", []byte("value"), 0)
+		require.NoError(t, err)
+		require.NoError(t, dm2.Put(ctx, "reset", []byte("value"), nil))
+
+		entry, err := dm1.Get(ctx, "reset")
+		require.NoError(t, err)
+		require.Equal(t, int64(0), versionOf(entry))
+	})
+
+	t.Run("Stale version", func(t *testing.T) {
+		_, err := dm1.PutWithVersion(ctx, "mykey", []byte("stale"), 5)
+		require.ErrorIs(t, err, ErrVersionMismatch)
+	})
+
+	t.Run("Absent key has version 0", func(t *testing.T) {
+		_, err := dm1.PutWithVersion(ctx, "absent", []byte("value"), 1)
+		require.ErrorIs(t, err, ErrVersionMismatch)
+	})
+}
+
+func TestDMap_putWithVersionCommandHandler_Cluster(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the key.
+	owner, other := dm1, s2
+	if !s1.primary.PartitionByHKey(partitions.HKey("mydmap", "mykey")).Owner().CompareByName(s1.rt.This()) {
+		owner, other = dm2, s1
+	}
+
+	_, err = owner.PutWithVersion(ctx, "mykey", []byte("first"), 0)
+	require.NoError(t, err)
+
+	// The command is sent to the member that doesn't own the key, it must see the
+	// version that is written on the owner.
+	put := func(value string, expectedVersion int64) (int64, error) {
+		cmd := protocol.NewPutWithVersion("mydmap", "mykey", []byte(value), expectedVersion).Command(ctx)
+		rc := other.client.Get(other.rt.This().String())
+		_ = rc.Process(ctx, cmd)
+		return cmd.Result()
+	}
+	_, err = put("stale", 0)
+	require.ErrorIs(t, protocol.ConvertError(err), ErrVersionMismatch)
+
+	version, err := put("second", 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), version)
+
+	entry, err := owner.Get(ctx, "mykey")
+	require.NoError(t, err)
+	require.Equal(t, []byte("second"), entry.Value())
+	require.Equal(t, int64(2), versionOf(entry))
+}
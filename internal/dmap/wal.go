@@ -0,0 +1,81 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"time"
+
+	"github.com/buraksezer/olric/internal/wal"
+	"github.com/buraksezer/olric/pkg/storage"
+)
+
+func (s *Service) openWAL() error {
+	w, err := wal.Open(wal.Config{
+		Dir:            s.config.DMaps.WALDir,
+		MaxSegmentSize: s.config.DMaps.WALMaxSegmentSize,
+		Sync:           wal.SyncPolicy(s.config.DMaps.WALSync),
+		SyncInterval:   s.config.DMaps.WALSyncInterval,
+	})
+	if err != nil {
+		return err
+	}
+	s.wal = w
+	return nil
+}
+
+// appendWAL appends a mutation to the write-ahead log, if it's enabled. It's called by
+// the partition owner. A write is logged after the storage accepts it, and rolled back
+// if it cannot be logged, so the log never holds a write that the client saw rejected.
+// A deletion is logged before it's applied.
+func (dm *DMap) appendWAL(op wal.Op, key string, value []byte, ttl int64) error {
+	if dm.s.wal == nil {
+		return nil
+	}
+	return dm.s.wal.Append(&wal.Record{
+		DMap:      dm.name,
+		Key:       key,
+		Op:        op,
+		Value:     value,
+		TTL:       ttl,
+		Timestamp: time.Now().UnixNano(),
+	})
+}
+
+// walPreviousEntry returns the stored entry that a write replaces, to restore it if the
+// write cannot be logged. It returns nil if the write-ahead log is disabled or the key
+// doesn't exist.
+func (dm *DMap) walPreviousEntry(e *env) storage.Entry {
+	if dm.s.wal == nil {
+		return nil
+	}
+	prev, err := e.fragment.storage.Get(e.hkey)
+	if err != nil {
+		return nil
+	}
+	return prev
+}
+
+// restoreWALPreviousEntry rolls back a write that cannot be logged.
+func (dm *DMap) restoreWALPreviousEntry(e *env, prev storage.Entry) {
+	var err error
+	if prev == nil {
+		err = e.fragment.storage.Delete(e.hkey)
+	} else {
+		err = e.fragment.storage.Put(e.hkey, prev)
+	}
+	if err != nil {
+		dm.s.log.V(3).Printf("[ERROR] Failed to roll back the write of key: %s on DMap: %s: %v", e.key, dm.name, err)
+	}
+}
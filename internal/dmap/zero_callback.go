@@ -0,0 +1,101 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"sync"
+)
+
+// ZeroCallback is called when DecrAndDeleteAtZero brings the counter stored at the key to zero.
+type ZeroCallback func(dmap, key string)
+
+type zeroCallbackKey struct {
+	dmap string
+	key  string
+}
+
+// zeroCallbacks keeps the callbacks registered on this member.
+type zeroCallbacks struct {
+	mtx       sync.Mutex
+	callbacks map[zeroCallbackKey]ZeroCallback
+}
+
+func newZeroCallbacks() *zeroCallbacks {
+	return &zeroCallbacks{
+		callbacks: make(map[zeroCallbackKey]ZeroCallback),
+	}
+}
+
+func (z *zeroCallbacks) register(dmap, key string, f ZeroCallback) {
+	z.mtx.Lock()
+	defer z.mtx.Unlock()
+
+	z.callbacks[zeroCallbackKey{dmap: dmap, key: key}] = f
+}
+
+func (z *zeroCallbacks) cancel(dmap, key string) {
+	z.mtx.Lock()
+	defer z.mtx.Unlock()
+
+	delete(z.callbacks, zeroCallbackKey{dmap: dmap, key: key})
+}
+
+// take removes the callback of the key and returns it, so a callback is returned only once.
+func (z *zeroCallbacks) take(dmap, key string) (ZeroCallback, bool) {
+	z.mtx.Lock()
+	defer z.mtx.Unlock()
+
+	k := zeroCallbackKey{dmap: dmap, key: key}
+	f, ok := z.callbacks[k]
+	if ok {
+		delete(z.callbacks, k)
+	}
+	return f, ok
+}
+
+// fireZeroCallback runs the callback of the key in the background, if there is any.
+func (dm *DMap) fireZeroCallback(key string) {
+	f, ok := dm.s.zeros.take(dm.name, key)
+	if !ok {
+		return
+	}
+
+	dm.s.wg.Add(1)
+	go func() {
+		defer dm.s.wg.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				dm.s.log.V(3).Printf("[ERROR] Zero callback panicked for key: %s on DMap: %s: %v", key, dm.name, r)
+			}
+		}()
+		f(dm.name, key)
+	}()
+}
+
+// OnZero registers a callback that is called when DecrAndDeleteAtZero brings the counter
+// stored at the key to zero. The callback is called once, then it's removed. It runs in the
+// background, so it doesn't delay the decrement.
+//
+// The callbacks are kept in memory and fire on the member that runs the decrement, which
+// is the partition owner of the key. Register the callback on every member to survive
+// the ownership changes. Registering a new callback for the key replaces the previous one.
+func (dm *DMap) OnZero(key string, f ZeroCallback) {
+	dm.s.zeros.register(dm.name, key, f)
+}
+
+// CancelOnZero removes the callback of the key registered on this member.
+func (dm *DMap) CancelOnZero(key string) {
+	dm.s.zeros.cancel(dm.name, key)
+}
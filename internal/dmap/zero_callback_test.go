@@ -0,0 +1,109 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dmap
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testcluster"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+func TestDMap_OnZero(t *testing.T) {
+	cluster := testcluster.New(NewService)
+	s1 := cluster.AddMember(nil).(*Service)
+	s2 := cluster.AddMember(nil).(*Service)
+	defer cluster.Shutdown()
+
+	ctx := context.Background()
+	dm1, err := s1.NewDMap("mydmap")
+	require.NoError(t, err)
+	dm2, err := s2.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	const refs = 100
+	require.NoError(t, dm1.Put(ctx, "refcount", encodeInt(refs), nil))
+
+	// Register the callback on both members, it fires on the partition owner.
+	calls := make(map[string]*int64)
+	for _, dm := range []*DMap{dm1, dm2} {
+		var counter int64
+		calls[dm.s.rt.This().String()] = &counter
+		dm.OnZero("refcount", func(dmap, key string) {
+			if dmap == "mydmap" && key == "refcount" {
+				atomic.AddInt64(&counter, 1)
+			}
+		})
+	}
+
+	// Decrement on both members concurrently, the non-owner redirects the request.
+	var errGr errgroup.Group
+	for i := 0; i < refs; i++ {
+		dm := dm1
+		if i%2 == 0 {
+			dm = dm2
+		}
+		errGr.Go(func() error {
+			_, _, err := dm.DecrAndDeleteAtZero(ctx, "refcount", 1)
+			return err
+		})
+	}
+	require.NoError(t, errGr.Wait())
+
+	owner := s1.primary.PartitionByHKey(partitions.HKey("mydmap", "refcount")).Owner().String()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(calls[owner]) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The callback is removed after it fires.
+	require.NoError(t, dm1.Put(ctx, "refcount", encodeInt(1), nil))
+	_, deleted, err := dm2.DecrAndDeleteAtZero(ctx, "refcount", 1)
+	require.NoError(t, err)
+	require.True(t, deleted)
+
+	<-time.After(100 * time.Millisecond)
+	for addr, counter := range calls {
+		if addr == owner {
+			require.Equal(t, int64(1), atomic.LoadInt64(counter))
+			continue
+		}
+		require.Equal(t, int64(0), atomic.LoadInt64(counter))
+	}
+
+	t.Run("CancelOnZero", func(t *testing.T) {
+		var fired int32
+		dm1.OnZero("canceled", func(_, _ string) {
+			atomic.StoreInt32(&fired, 1)
+		})
+		dm2.OnZero("canceled", func(_, _ string) {
+			atomic.StoreInt32(&fired, 1)
+		})
+		dm1.CancelOnZero("canceled")
+		dm2.CancelOnZero("canceled")
+
+		require.NoError(t, dm1.Put(ctx, "canceled", encodeInt(1), nil))
+		_, deleted, err := dm1.DecrAndDeleteAtZero(ctx, "canceled", 1)
+		require.NoError(t, err)
+		require.True(t, deleted)
+
+		<-time.After(100 * time.Millisecond)
+		require.Equal(t, int32(0), atomic.LoadInt32(&fired))
+	})
+}
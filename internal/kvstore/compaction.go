@@ -20,9 +20,24 @@ import (
 	"time"
 
 	"github.com/buraksezer/olric/internal/kvstore/table"
+	"github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/pkg/storage"
 )
 
+var (
+	// CompactionsByGarbageTotal is the number of compaction steps triggered by the garbage ratio of a table.
+	CompactionsByGarbageTotal = stats.NewInt64Counter()
+
+	// CompactionsByTableCountTotal is the number of compaction steps triggered by the maxTables limit.
+	CompactionsByTableCountTotal = stats.NewInt64Counter()
+
+	// CompactionsByTableAgeTotal is the number of compaction steps run by CompactTablesOlderThan.
+	CompactionsByTableAgeTotal = stats.NewInt64Counter()
+
+	// CompactionsWhenIdleTotal is the number of compaction steps run by ConsolidateTables.
+	CompactionsWhenIdleTotal = stats.NewInt64Counter()
+)
+
 func (k *KVStore) evictTable(t *table.Table) error {
 	var total int
 	var evictErr error
@@ -78,21 +93,144 @@ func (k *KVStore) isTableExpired(recycledAt int64) bool {
 
 func (k *KVStore) isCompactionOK(t *table.Table) bool {
 	s := t.Stats()
-	return float64(s.Garbage) >= float64(s.Allocated)*maxGarbageRatio
+	return float64(s.Garbage) >= float64(s.Allocated)*k.maxGarbageRatio
+}
+
+// maxTables returns the value of maxTables option. It's an optional setting, zero
+// means the table count based compaction is disabled.
+func (k *KVStore) maxTables() int {
+	raw, err := k.config.Get("maxTables")
+	if err != nil {
+		// Not configured
+		return 0
+	}
+	value, err := toUint64("maxTables", raw)
+	if err != nil {
+		return 0
+	}
+	return int(value)
+}
+
+// activeTables returns the non-recycled tables and the total size of their live data.
+func (k *KVStore) activeTables() ([]*table.Table, uint64) {
+	var inuse uint64
+	var active []*table.Table
+	for _, t := range k.tables {
+		if t.State() == table.RecycledState {
+			continue
+		}
+		inuse += uint64(t.Stats().Inuse)
+		active = append(active, t)
+	}
+	return active, inuse
+}
+
+// tableToCompactByCount returns a table to evict if the number of non-recycled tables
+// exceeds maxTables and the live data fits into fewer tables. Otherwise, it returns nil.
+func (k *KVStore) tableToCompactByCount() *table.Table {
+	limit := k.maxTables()
+	if limit <= 0 || len(k.tables) <= limit {
+		return nil
+	}
+
+	active, inuse := k.activeTables()
+	if len(active) <= limit {
+		return nil
+	}
+	return k.tableToMerge(active, inuse)
+}
+
+// requiredTables returns the minimum number of tables that can host the live data of the
+// active tables.
+func (k *KVStore) requiredTables(active []*table.Table, inuse uint64) int {
+	required := int((inuse + k.tableSize - 1) / k.tableSize)
+	if limit := k.maxKeysPerTable(); limit > 0 {
+		var length int
+		for _, t := range active {
+			length += t.Stats().Length
+		}
+		if byKeys := (length + limit - 1) / limit; byKeys > required {
+			required = byKeys
+		}
+	}
+	if required < 1 {
+		required = 1
+	}
+	return required
+}
+
+// tableToMerge returns the table with the least live data if the live data of the active
+// tables fits into fewer tables. Otherwise, it returns nil.
+func (k *KVStore) tableToMerge(active []*table.Table, inuse uint64) *table.Table {
+	if len(active) <= 1 {
+		return nil
+	}
+
+	// Compaction cannot decrease the number of tables if the live data doesn't fit into fewer tables.
+	if k.requiredTables(active, inuse) >= len(active) {
+		return nil
+	}
+
+	// The last table is the head, new entries are moved into it. Pick the table
+	// with the least live data to minimize the work.
+	var candidate *table.Table
+	for _, t := range active[:len(active)-1] {
+		if candidate == nil || t.Stats().Inuse < candidate.Stats().Inuse {
+			candidate = t
+		}
+	}
+	return candidate
 }
 
 func (k *KVStore) Compaction() (bool, error) {
+	return k.compaction(true)
+}
+
+// UrgentCompaction works like Compaction, but it skips the compactions triggered by the
+// maxTables limit. They are not urgent, the caller can run them with DeferredCompaction
+// when the traffic is low. The tables with too much garbage are still compacted.
+func (k *KVStore) UrgentCompaction() (bool, error) {
+	return k.compaction(false)
+}
+
+// DeferredCompaction runs only the compactions triggered by the maxTables limit, which
+// are skipped by UrgentCompaction. It works step by step like Compaction, the caller
+// should call it again until it returns true.
+func (k *KVStore) DeferredCompaction() (bool, error) {
+	t := k.tableToCompactByCount()
+	if t == nil {
+		return true, nil
+	}
+
+	err := k.evictTable(t)
+	if err != nil {
+		return false, err
+	}
+	CompactionsByTableCountTotal.Increase(1)
+	// Continue scanning
+	return false, nil
+}
+
+func (k *KVStore) compaction(byTableCount bool) (bool, error) {
 	for _, t := range k.tables {
 		if k.isCompactionOK(t) {
 			err := k.evictTable(t)
 			if err != nil {
 				return false, err
 			}
+			CompactionsByGarbageTotal.Increase(1)
 			// Continue scanning
 			return false, nil
 		}
 	}
 
+	if byTableCount {
+		done, err := k.DeferredCompaction()
+		if !done || err != nil {
+			return done, err
+		}
+	}
+
 	for i := 0; i < len(k.tables); i++ {
 		t := k.tables[i]
 		s := t.Stats()
@@ -110,3 +248,121 @@ func (k *KVStore) Compaction() (bool, error) {
 
 	return true, nil
 }
+
+// tableToCompactByAge returns a fragmented table that is not written within the given age.
+// The head table is never returned, new entries are moved into it.
+func (k *KVStore) tableToCompactByAge(age time.Duration) *table.Table {
+	if len(k.tables) == 0 {
+		return nil
+	}
+
+	threshold := time.Now().Add(-age).UnixNano()
+	for _, t := range k.tables[:len(k.tables)-1] {
+		if t.State() == table.RecycledState {
+			continue
+		}
+		s := t.Stats()
+		if s.Garbage > 0 && s.LastWriteAt < threshold {
+			return t
+		}
+	}
+	return nil
+}
+
+// CompactTablesOlderThan merges the fragmented tables that are not written within the
+// given age into the head table. The recently written tables are left alone, they are
+// likely to be fragmented again. It works step by step like Compaction, the caller should
+// call it again until it returns true.
+func (k *KVStore) CompactTablesOlderThan(age time.Duration) (bool, error) {
+	t := k.tableToCompactByAge(age)
+	if t == nil {
+		return true, nil
+	}
+
+	err := k.evictTable(t)
+	if err != nil {
+		return false, err
+	}
+	CompactionsByTableAgeTotal.Increase(1)
+	// Continue scanning
+	return false, nil
+}
+
+// ConsolidateTables merges the tables into fewer tables if the live data fits into them,
+// regardless of the garbage ratio and maxTables. It's called when the member is idle, a
+// Get has to scan fewer tables after it. It works step by step like Compaction, the caller
+// should call it again until it returns true.
+func (k *KVStore) ConsolidateTables() (bool, error) {
+	active, inuse := k.activeTables()
+	t := k.tableToMerge(active, inuse)
+	if t == nil {
+		return true, nil
+	}
+
+	err := k.evictTable(t)
+	if err != nil {
+		return false, err
+	}
+	CompactionsWhenIdleTotal.Increase(1)
+	// Continue scanning
+	return false, nil
+}
+
+// Compact merges the tables regardless of the garbage ratio: it moves the live entries of
+// the oldest table into the head table and drops the table from the store once it's
+// drained. It returns true when the live data is hosted by as few tables as possible, a
+// single table if the live data fits into one.
+//
+// Every call moves a limited number of entries, so the caller can budget the work and call
+// it again later, the next call resumes with the same table. Calling it on a compacted store
+// is a no-op. See CompactionBacklog to estimate the remaining work.
+func (k *KVStore) Compact() (bool, error) {
+	k.dropRecycledTables()
+	if k.isCompacted() {
+		return true, nil
+	}
+
+	err := k.evictTable(k.tables[0])
+	if err != nil {
+		return false, err
+	}
+
+	k.dropRecycledTables()
+	return k.isCompacted(), nil
+}
+
+// isCompacted returns true if the number of tables cannot be decreased any further.
+func (k *KVStore) isCompacted() bool {
+	active, inuse := k.activeTables()
+	return len(k.tables) <= k.requiredTables(active, inuse)
+}
+
+// dropRecycledTables removes the drained tables from the store, instead of keeping them
+// to be reused until maxIdleTableTimeout. The store always keeps one table.
+func (k *KVStore) dropRecycledTables() {
+	for i := 0; i < len(k.tables) && len(k.tables) > 1; i++ {
+		t := k.tables[i]
+		if t.State() != table.RecycledState {
+			continue
+		}
+		delete(k.tablesByCoefficient, t.Coefficient())
+		k.tables = append(k.tables[:i], k.tables[i+1:]...)
+		i--
+	}
+}
+
+// CompactionBacklog returns the number of the live bytes that Compact may have to move,
+// it's zero if the store is compacted. A scheduler can use it to compact the most
+// fragmented stores first.
+func (k *KVStore) CompactionBacklog() int {
+	active, inuse := k.activeTables()
+	if len(active) <= k.requiredTables(active, inuse) {
+		return 0
+	}
+
+	var backlog int
+	for _, t := range active[:len(active)-1] {
+		backlog += int(t.Stats().Inuse)
+	}
+	return backlog
+}
@@ -21,6 +21,7 @@ import (
 
 	"github.com/buraksezer/olric/internal/kvstore/entry"
 	"github.com/buraksezer/olric/internal/kvstore/table"
+	"github.com/buraksezer/olric/pkg/storage"
 	"github.com/cespare/xxhash/v2"
 	"github.com/stretchr/testify/require"
 )
@@ -121,3 +122,324 @@ func TestKVStore_Compaction_MaxIdleTableDuration(t *testing.T) {
 
 	require.Equal(t, 1, len(s.(*KVStore).tables))
 }
+
+func TestKVStore_Compaction_MaxTables(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("tableSize", 4096)
+	c.Add("maxTables", 8)
+
+	s := testKVStore(t, c)
+
+	for i := 0; i < 300; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%0100d", i)))
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		err := s.Put(hkey, e)
+		require.NoError(t, err)
+	}
+
+	// Delete every third key. The garbage ratio of the tables remains below the default maxGarbageRatio.
+	for i := 0; i < 300; i += 3 {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		err := s.Delete(hkey)
+		require.NoError(t, err)
+	}
+
+	kv := s.(*KVStore)
+	for _, tb := range kv.tables {
+		require.False(t, kv.isCompactionOK(tb))
+	}
+	require.Greater(t, s.Stats().NumTables, 8)
+
+	before := CompactionsByTableCountTotal.Read()
+	for {
+		done, err := s.Compaction()
+		require.NoError(t, err)
+		if done {
+			break
+		}
+	}
+	require.Greater(t, CompactionsByTableCountTotal.Read(), before)
+
+	var active int
+	for _, tb := range kv.tables {
+		if tb.State() != table.RecycledState {
+			active++
+		}
+	}
+	require.LessOrEqual(t, active, 8)
+
+	for i := 0; i < 300; i++ {
+		hkey := xxhash.Sum64([]byte(bkey(i)))
+		_, err := s.Get(hkey)
+		if i%3 == 0 {
+			require.ErrorIs(t, err, storage.ErrKeyNotFound)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+}
+
+func TestKVStore_UrgentCompaction_DeferredCompaction(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("tableSize", 4096)
+	c.Add("maxTables", 8)
+
+	s := testKVStore(t, c)
+	kv := s.(*KVStore)
+
+	for i := 0; i < 300; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%0100d", i)))
+		require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+	// The garbage ratio of the tables remains below the default maxGarbageRatio.
+	for i := 0; i < 300; i += 3 {
+		require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+	}
+	numTables := s.Stats().NumTables
+	require.Greater(t, numTables, 8)
+
+	// The maxTables limit is not urgent.
+	before := CompactionsByTableCountTotal.Read()
+	done, err := kv.UrgentCompaction()
+	require.NoError(t, err)
+	require.True(t, done)
+	require.Equal(t, before, CompactionsByTableCountTotal.Read())
+	require.Equal(t, numTables, s.Stats().NumTables)
+
+	for {
+		done, err := kv.DeferredCompaction()
+		require.NoError(t, err)
+		if done {
+			break
+		}
+	}
+	require.Greater(t, CompactionsByTableCountTotal.Read(), before)
+
+	var active int
+	for _, tb := range kv.tables {
+		if tb.State() != table.RecycledState {
+			active++
+		}
+	}
+	require.LessOrEqual(t, active, 8)
+	require.Equal(t, 200, s.Stats().Length)
+}
+
+func TestKVStore_CompactTablesOlderThan(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("maxKeysPerTable", 10)
+	s := testKVStore(t, c)
+	kv := s.(*KVStore)
+
+	put := func(from, to int) {
+		for i := from; i < to; i++ {
+			e := entry.New()
+			e.SetKey(bkey(i))
+			e.SetValue([]byte(fmt.Sprintf("%010d", i)))
+			require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+		}
+	}
+	del := func(from, to int) {
+		for i := from; i < to; i++ {
+			require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+		}
+	}
+
+	// Three old tables, the first two of them are fragmented.
+	put(0, 30)
+	del(0, 5)
+	del(10, 15)
+
+	<-time.After(200 * time.Millisecond)
+
+	// A recently written, fragmented table and the head table.
+	put(30, 50)
+	del(30, 35)
+	require.Len(t, kv.tables, 5)
+
+	untouched := make(map[uint64]table.Stats)
+	for _, cf := range []uint64{2, 3} {
+		untouched[cf] = kv.tablesByCoefficient[cf].Stats()
+	}
+
+	for {
+		done, err := kv.CompactTablesOlderThan(100 * time.Millisecond)
+		require.NoError(t, err)
+		if done {
+			break
+		}
+	}
+
+	// The old and fragmented tables are merged and recycled.
+	for _, cf := range []uint64{0, 1} {
+		_, ok := kv.tablesByCoefficient[cf]
+		require.False(t, ok, cf)
+	}
+
+	// The old table without garbage and the recent table are not touched.
+	for cf, stats := range untouched {
+		require.Equal(t, stats, kv.tablesByCoefficient[cf].Stats(), cf)
+	}
+
+	require.Equal(t, 35, s.Stats().Length)
+	for _, i := range []int{5, 15, 25, 35, 49} {
+		e, err := s.Get(xxhash.Sum64([]byte(bkey(i))))
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("%010d", i), string(e.Value()))
+	}
+}
+
+func TestKVStore_ConsolidateTables(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("maxKeysPerTable", 10)
+	s := testKVStore(t, c)
+	kv := s.(*KVStore)
+
+	for i := 0; i < 50; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%010d", i)))
+		require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+	// Leave 6 keys in the first four tables, the garbage ratio remains low.
+	for i := 0; i < 40; i += 10 {
+		for j := i; j < i+4; j++ {
+			require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(j)))))
+		}
+	}
+
+	activeTables := func() int {
+		active, _ := kv.activeTables()
+		return len(active)
+	}
+	require.Equal(t, 5, activeTables())
+
+	// The garbage and the table count based compactions don't merge the tables.
+	done, err := s.Compaction()
+	require.NoError(t, err)
+	require.True(t, done)
+	require.Equal(t, 5, activeTables())
+
+	before := CompactionsWhenIdleTotal.Read()
+	for {
+		done, err := kv.ConsolidateTables()
+		require.NoError(t, err)
+		if done {
+			break
+		}
+	}
+	require.Greater(t, CompactionsWhenIdleTotal.Read(), before)
+
+	// 34 keys fit into 4 tables.
+	require.Equal(t, 4, activeTables())
+	require.Equal(t, 34, s.Stats().Length)
+	for i := 0; i < 50; i++ {
+		_, err := s.Get(xxhash.Sum64([]byte(bkey(i))))
+		if i < 40 && i%10 < 4 {
+			require.ErrorIs(t, err, storage.ErrKeyNotFound)
+			continue
+		}
+		require.NoError(t, err)
+	}
+}
+
+func TestKVStore_Compact(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("maxKeysPerTable", 10)
+	s := testKVStore(t, c)
+	kv := s.(*KVStore)
+
+	for i := 0; i < 50; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf("%010d", i)))
+		require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+	// Leave 10 keys, they fit into a single table.
+	for i := 0; i < 50; i++ {
+		if i%5 != 0 {
+			require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+		}
+	}
+
+	activeTables := func() int {
+		active, _ := kv.activeTables()
+		return len(active)
+	}
+	require.Greater(t, activeTables(), 1)
+	require.Greater(t, kv.CompactionBacklog(), 0)
+
+	for {
+		backlog := kv.CompactionBacklog()
+		done, err := kv.Compact()
+		require.NoError(t, err)
+		if done {
+			break
+		}
+		// Every step decreases the remaining work.
+		require.Less(t, kv.CompactionBacklog(), backlog)
+		// The drained tables are dropped right away.
+		require.Equal(t, activeTables(), len(kv.tables))
+	}
+
+	require.Equal(t, 1, activeTables())
+	require.Len(t, kv.tables, 1)
+	require.Len(t, kv.tablesByCoefficient, 1)
+	require.Equal(t, 0, kv.CompactionBacklog())
+	require.Equal(t, 10, s.Stats().Length)
+	for i := 0; i < 50; i++ {
+		_, err := s.Get(xxhash.Sum64([]byte(bkey(i))))
+		if i%5 == 0 {
+			require.NoError(t, err)
+			continue
+		}
+		require.ErrorIs(t, err, storage.ErrKeyNotFound)
+	}
+
+	// Calling it again is a no-op.
+	done, err := kv.Compact()
+	require.NoError(t, err)
+	require.True(t, done)
+}
+
+func TestKVStore_Compaction_MaxGarbageRatio(t *testing.T) {
+	fill := func(t *testing.T, c *storage.Config) *KVStore {
+		s := testKVStore(t, c)
+		for i := 0; i < 1500; i++ {
+			e := entry.New()
+			e.SetKey(bkey(i))
+			e.SetValue([]byte(fmt.Sprintf("%01000d", i)))
+			require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+		}
+		// Delete about the half of the first table.
+		for i := 0; i < 500; i++ {
+			require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+		}
+		return s.(*KVStore)
+	}
+
+	t.Run("Default", func(t *testing.T) {
+		kv := fill(t, nil)
+		require.True(t, kv.isCompactionOK(kv.tables[0]))
+	})
+
+	t.Run("Configured", func(t *testing.T) {
+		c := DefaultConfig()
+		c.Add("maxGarbageRatio", 0.9)
+		kv := fill(t, c)
+		require.False(t, kv.isCompactionOK(kv.tables[0]))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, ratio := range []interface{}{0.0, 1.0, 1.5, -0.1, "0.5"} {
+			c := DefaultConfig()
+			c.Add("maxGarbageRatio", ratio)
+			_, err := New(c)
+			require.Error(t, err, ratio)
+		}
+	})
+}
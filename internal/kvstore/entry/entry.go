@@ -22,7 +22,7 @@ import (
 
 // In-memory layout for an entry:
 //
-// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | | Timestamp(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
+// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | | Timestamp(uint64) | LASTACCESS(uint64) | VERSION(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 
 // Entry represents a value with its metadata.
 type Entry struct {
@@ -30,10 +30,11 @@ type Entry struct {
 	ttl        int64
 	timestamp  int64
 	lastAccess int64
+	version    int64
 	value      []byte
 }
 
-var _ storage.Entry = (*Entry)(nil)
+var _ storage.VersionedEntry = (*Entry)(nil)
 
 func New() *Entry {
 	return &Entry{}
@@ -79,12 +80,20 @@ func (e *Entry) LastAccess() int64 {
 	return e.lastAccess
 }
 
+func (e *Entry) SetVersion(version int64) {
+	e.version = version
+}
+
+func (e *Entry) Version() int64 {
+	return e.version
+}
+
 func (e *Entry) Encode() []byte {
 	var offset int
 
 	klen := uint8(len(e.Key()))
 	vlen := len(e.Value())
-	length := 29 + len(e.Key()) + vlen
+	length := 37 + len(e.Key()) + vlen
 
 	buf := make([]byte, length)
 
@@ -108,6 +117,10 @@ func (e *Entry) Encode() []byte {
 	binary.BigEndian.PutUint64(buf[offset:], uint64(e.LastAccess()))
 	offset += 8
 
+	// Set the Version. It's 8 bytes.
+	binary.BigEndian.PutUint64(buf[offset:], uint64(e.Version()))
+	offset += 8
+
 	// Set the value length. It's 4 bytes.
 	binary.BigEndian.PutUint32(buf[offset:], uint32(len(e.Value())))
 	offset += 4
@@ -135,6 +148,9 @@ func (e *Entry) Decode(buf []byte) {
 	e.lastAccess = int64(binary.BigEndian.Uint64(buf[offset : offset+8]))
 	offset += 8
 
+	e.version = int64(binary.BigEndian.Uint64(buf[offset : offset+8]))
+	offset += 8
+
 	vlen := binary.BigEndian.Uint32(buf[offset : offset+4])
 	offset += 4
 	e.value = buf[offset : offset+int(vlen)]
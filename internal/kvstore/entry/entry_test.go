@@ -27,6 +27,7 @@ func TestEntryEncodeDecode(t *testing.T) {
 	e.SetTTL(200)
 	e.SetTimestamp(time.Now().UnixNano())
 	e.SetLastAccess(time.Now().UnixNano())
+	e.SetVersion(3)
 	e.SetValue([]byte("mydata"))
 
 	t.Run("Encode", func(t *testing.T) {
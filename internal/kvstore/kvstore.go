@@ -24,6 +24,7 @@ import (
 	"io"
 	"log"
 	"reflect"
+	"regexp"
 	"sort"
 	"time"
 
@@ -33,7 +34,7 @@ import (
 )
 
 const (
-	maxGarbageRatio = 0.40
+	defaultMaxGarbageRatio = 0.40
 	// 1MB
 	defaultTableSize = uint64(1 << 20)
 
@@ -44,6 +45,7 @@ const (
 type KVStore struct {
 	coefficient         uint64
 	tableSize           uint64
+	maxGarbageRatio     float64
 	tablesByCoefficient map[uint64]*table.Table
 	tables              []*table.Table
 	config              *storage.Config
@@ -52,6 +54,7 @@ type KVStore struct {
 func DefaultConfig() *storage.Config {
 	options := storage.NewConfig(nil)
 	options.Add("tableSize", defaultTableSize)
+	options.Add("maxGarbageRatio", defaultMaxGarbageRatio)
 	options.Add("maxIdleTableTimeout", defaultMaxIdleTableTimeout)
 	return options
 }
@@ -71,8 +74,14 @@ func New(c *storage.Config) (*KVStore, error) {
 		return nil, err
 	}
 
+	ratio, err := prepareMaxGarbageRatio(c)
+	if err != nil {
+		return nil, err
+	}
+
 	return &KVStore{
 		tableSize:           size,
+		maxGarbageRatio:     ratio,
 		tablesByCoefficient: make(map[uint64]*table.Table),
 		config:              c,
 	}, nil
@@ -124,7 +133,43 @@ func requiredSizeForAnEntry(e storage.Entry) uint64 {
 	return uint64(len(e.Key()) + len(e.Value()) + table.MetadataLength)
 }
 
-func prepareTableSize(raw interface{}) (size uint64, err error) {
+// fits returns true if an entry of the given size fits in an empty table. A table
+// needs at least one free byte after the entry, the writers would create new tables
+// forever for an entry that cannot fit.
+func (k *KVStore) fits(size uint64) bool {
+	return size < k.tableSize
+}
+
+func prepareTableSize(raw interface{}) (uint64, error) {
+	return toUint64("tableSize", raw)
+}
+
+// prepareMaxGarbageRatio returns the value of maxGarbageRatio option. A table is compacted
+// when the ratio of its garbage to its size reaches this value. A higher ratio trades memory
+// for fewer compactions. It's an optional setting, the default is 0.40.
+func prepareMaxGarbageRatio(c *storage.Config) (float64, error) {
+	raw, err := c.Get("maxGarbageRatio")
+	if err != nil {
+		// Not configured
+		return defaultMaxGarbageRatio, nil
+	}
+
+	var ratio float64
+	switch value := raw.(type) {
+	case float32:
+		ratio = float64(value)
+	case float64:
+		ratio = value
+	default:
+		return 0, fmt.Errorf("invalid type for maxGarbageRatio: %s", reflect.TypeOf(raw))
+	}
+	if ratio <= 0 || ratio >= 1 {
+		return 0, fmt.Errorf("maxGarbageRatio must be between 0 and 1, exclusive: %v", ratio)
+	}
+	return ratio, nil
+}
+
+func toUint64(name string, raw interface{}) (size uint64, err error) {
 	switch raw.(type) {
 	case uint:
 		size = uint64(raw.(uint))
@@ -147,7 +192,7 @@ func prepareTableSize(raw interface{}) (size uint64, err error) {
 	case int64:
 		size = uint64(raw.(int64))
 	default:
-		err = fmt.Errorf("invalid type for tableSize: %s", reflect.TypeOf(raw))
+		err = fmt.Errorf("invalid type for %s: %s", name, reflect.TypeOf(raw))
 		return
 	}
 	return
@@ -163,7 +208,7 @@ func (k *KVStore) Fork(c *storage.Config) (storage.Engine, error) {
 	if err != nil {
 		return nil, err
 	}
-	t := table.New(k.tableSize)
+	t := table.New(child.tableSize)
 	child.tables = append(child.tables, t)
 	t.SetCoefficient(child.coefficient)
 	child.tablesByCoefficient[child.coefficient] = t
@@ -179,10 +224,43 @@ func (k *KVStore) NewEntry() storage.Entry {
 	return entry.New()
 }
 
+// maxKeysPerTable returns the value of maxKeysPerTable option. It's an optional setting,
+// zero means the tables are only rolled over when they run out of space.
+//
+// A table indexes its entries with a built-in map. Capping the number of keys per table
+// bounds the size of each map, so the GC scans smaller maps. The tradeoff is that a
+// fragment with many small values has more tables, and reads, scans and compaction
+// have to visit more of them.
+func (k *KVStore) maxKeysPerTable() int {
+	raw, err := k.config.Get("maxKeysPerTable")
+	if err != nil {
+		// Not configured
+		return 0
+	}
+	value, err := toUint64("maxKeysPerTable", raw)
+	if err != nil {
+		return 0
+	}
+	return int(value)
+}
+
+// isTableFull returns true if a new key cannot be inserted into the table because the
+// table already contains maxKeysPerTable keys. Overwriting an existing key is allowed.
+func (k *KVStore) isTableFull(t *table.Table, hkey uint64, limit int) bool {
+	if limit <= 0 {
+		return false
+	}
+	return t.Stats().Length >= limit && !t.Check(hkey)
+}
+
 // PutRaw sets the raw value for the given key.
 func (k *KVStore) PutRaw(hkey uint64, value []byte) error {
-	if uint64(len(value)) > k.tableSize {
-		return storage.ErrEntryTooLarge
+	if size := uint64(len(value)); !k.fits(size) {
+		return &storage.EntryTooLargeError{
+			HKey:      hkey,
+			Size:      size,
+			TableSize: k.tableSize,
+		}
 	}
 
 	if len(k.tables) == 0 {
@@ -191,9 +269,16 @@ func (k *KVStore) PutRaw(hkey uint64, value []byte) error {
 		}
 	}
 
+	limit := k.maxKeysPerTable()
 	for {
 		// Get the last value, storage only calls Put on the last created table.
 		t := k.tables[len(k.tables)-1]
+		if k.isTableFull(t, hkey, limit) {
+			if err := k.makeTable(); err != nil {
+				return err
+			}
+			continue
+		}
 		err := t.PutRaw(hkey, value)
 		if errors.Is(err, table.ErrNotEnoughSpace) {
 			err := k.makeTable()
@@ -215,8 +300,13 @@ func (k *KVStore) PutRaw(hkey uint64, value []byte) error {
 
 // Put sets the value for the given key. It overwrites any previous value for that key
 func (k *KVStore) Put(hkey uint64, value storage.Entry) error {
-	if requiredSizeForAnEntry(value) > k.tableSize {
-		return storage.ErrEntryTooLarge
+	if size := requiredSizeForAnEntry(value); !k.fits(size) {
+		return &storage.EntryTooLargeError{
+			Key:       value.Key(),
+			HKey:      hkey,
+			Size:      size,
+			TableSize: k.tableSize,
+		}
 	}
 
 	if len(k.tables) == 0 {
@@ -225,9 +315,16 @@ func (k *KVStore) Put(hkey uint64, value storage.Entry) error {
 		}
 	}
 
+	limit := k.maxKeysPerTable()
 	for {
 		// Get the last value, storage only calls Put on the last created table.
 		t := k.tables[len(k.tables)-1]
+		if k.isTableFull(t, hkey, limit) {
+			if err := k.makeTable(); err != nil {
+				return err
+			}
+			continue
+		}
 		err := t.Put(hkey, value)
 		if errors.Is(err, table.ErrNotEnoughSpace) {
 			err := k.makeTable()
@@ -409,6 +506,30 @@ func (k *KVStore) Stats() storage.Stats {
 	return stats
 }
 
+// TableStat is the memory allocation and the garbage of a single table.
+type TableStat struct {
+	Allocated int
+	Inuse     int
+	Garbage   int
+	Length    int
+}
+
+// TableStats returns the statistics of every table, from the oldest to the newest one. Unlike
+// Stats, it shows how the garbage is distributed over the tables.
+func (k *KVStore) TableStats() []TableStat {
+	stats := make([]TableStat, 0, len(k.tables))
+	for _, t := range k.tables {
+		s := t.Stats()
+		stats = append(stats, TableStat{
+			Allocated: int(s.Allocated),
+			Inuse:     int(s.Inuse),
+			Garbage:   int(s.Garbage),
+			Length:    s.Length,
+		})
+	}
+	return stats
+}
+
 // Check checks the key existence.
 func (k *KVStore) Check(hkey uint64) bool {
 	// Scan available tables by starting the last added table.
@@ -452,6 +573,42 @@ func (k *KVStore) RangeHKey(f func(hkey uint64) bool) {
 	}
 }
 
+// matchOn calls f for the entries whose field, the raw key or value, matches expr.
+func (k *KVStore) matchOn(expr string, field func(key, value []byte) []byte, f func(hkey uint64, e storage.Entry) bool) error {
+	r, err := regexp.Compile(expr)
+	if err != nil {
+		return err
+	}
+
+	match := func(key, value []byte) bool {
+		return r.Match(field(key, value))
+	}
+	// Scan available tables by starting the last added table.
+	for i := len(k.tables) - 1; i >= 0; i-- {
+		if !k.tables[i].RangeMatch(match, f) {
+			break
+		}
+	}
+	return nil
+}
+
+// MatchOnKey calls f sequentially for each entry whose key matches the regular expression.
+// If f returns false, it stops the iteration.
+func (k *KVStore) MatchOnKey(expr string, f func(hkey uint64, e storage.Entry) bool) error {
+	return k.matchOn(expr, func(key, _ []byte) []byte {
+		return key
+	}, f)
+}
+
+// MatchOnValue calls f sequentially for each entry whose value matches the regular expression,
+// e.g. the JSON documents that contain a field. The values are matched in place, the metadata of
+// an entry is only decoded if it matches. If f returns false, it stops the iteration.
+func (k *KVStore) MatchOnValue(expr string, f func(hkey uint64, e storage.Entry) bool) error {
+	return k.matchOn(expr, func(_, value []byte) []byte {
+		return value
+	}, f)
+}
+
 func (k *KVStore) findCoefficient(coefficient uint64) (uint64, error) {
 	var sortedCoefficients []uint64
 	for newCf, _ := range k.tablesByCoefficient {
@@ -499,25 +656,36 @@ func (k *KVStore) scanCommon(cursor uint64, expr string, count int, f func(e sto
 	}
 
 	if tableCursor == 0 {
-		_, ok := k.tablesByCoefficient[cf+1]
-		if !ok {
-			cf, err = k.findCoefficient(cf)
+		next := cf + 1
+		if _, ok := k.tablesByCoefficient[next]; !ok {
+			// The tables dropped by the compaction leave gaps in the coefficients.
+			next, err = k.findCoefficient(cf)
 			if err != nil {
-				// Invalid cursor
+				// End of the scan
 				return 0, nil
 			}
 		}
 		// The next table
-		return k.tableSize * (cf + 1), nil
+		return k.tableSize * next, nil
 	}
 
 	return tableCursor + (k.tableSize * cf), nil
 }
 
+// Scan calls f for up to count entries, starting from the cursor, and returns the cursor of
+// the next call. Zero starts a new scan and the returned cursor is zero at the end of the scan.
+// A call doesn't cross the table boundaries, so it may return fewer entries.
+//
+// The cursor encodes the coefficient of a table and an offset in it. The coefficients only
+// increase, so the tables appended during a scan are visited after the current one, and the
+// cursor remains valid if a table is dropped by the compaction. Like Redis SCAN, the entries
+// that exist during the whole scan are returned at least once. An entry that is added, updated
+// or moved by the compaction during the scan may be returned more than once or not at all.
 func (k *KVStore) Scan(cursor uint64, count int, f func(e storage.Entry) bool) (uint64, error) {
 	return k.scanCommon(cursor, "", count, f)
 }
 
+// ScanRegexMatch is the same as Scan, but it only returns the entries whose key matches expr.
 func (k *KVStore) ScanRegexMatch(cursor uint64, expr string, count int, f func(e storage.Entry) bool) (uint64, error) {
 	return k.scanCommon(cursor, expr, count, f)
 }
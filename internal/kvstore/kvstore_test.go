@@ -175,6 +175,55 @@ func TestKVStore_ExportImport(t *testing.T) {
 	}
 }
 
+func TestKVStore_ExportToImportFrom(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("tableSize", 1<<12)
+	s := testKVStore(t, c)
+
+	for i := 0; i < 1000; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue(bval(i))
+		require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+	require.Greater(t, s.Stats().NumTables, 1)
+
+	var buf bytes.Buffer
+	require.NoError(t, s.(*KVStore).ExportTo(&buf))
+
+	fresh := testKVStore(t, nil)
+	err := fresh.(*KVStore).ImportFrom(&buf, func(hkey uint64, e storage.Entry) error {
+		return fresh.Put(hkey, e)
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 1000, fresh.Stats().Length)
+	for i := 0; i < 1000; i++ {
+		e, err := fresh.Get(xxhash.Sum64([]byte(bkey(i))))
+		require.NoError(t, err)
+		require.Equal(t, bval(i), e.Value())
+	}
+}
+
+func TestKVStore_Import_LegacyPack(t *testing.T) {
+	tb := table.New(1 << 16)
+	for i := 0; i < 100; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue(bval(i))
+		require.NoError(t, tb.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+	data, err := table.Encode(tb)
+	require.NoError(t, err)
+
+	s := testKVStore(t, nil)
+	err = s.Import(data, func(hkey uint64, e storage.Entry) error {
+		return s.Put(hkey, e)
+	})
+	require.NoError(t, err)
+	require.Equal(t, 100, s.Stats().Length)
+}
+
 func TestKVStore_Stats_Length(t *testing.T) {
 	s := testKVStore(t, nil)
 
@@ -191,6 +240,131 @@ func TestKVStore_Stats_Length(t *testing.T) {
 	require.Equal(t, 100, s.Stats().Length)
 }
 
+func TestKVStore_TableStats(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("maxKeysPerTable", 10)
+	s := testKVStore(t, c)
+
+	for i := 0; i < 30; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue(bval(i))
+		err := s.Put(xxhash.Sum64([]byte(e.Key())), e)
+		require.NoError(t, err)
+	}
+
+	// Create garbage in the oldest table.
+	for i := 0; i < 5; i++ {
+		err := s.Delete(xxhash.Sum64([]byte(bkey(i))))
+		require.NoError(t, err)
+	}
+
+	stats := s.(*KVStore).TableStats()
+	require.Len(t, stats, 3)
+
+	var total TableStat
+	for _, ts := range stats {
+		total.Allocated += ts.Allocated
+		total.Inuse += ts.Inuse
+		total.Garbage += ts.Garbage
+		total.Length += ts.Length
+	}
+	aggregate := s.Stats()
+	require.Equal(t, aggregate.Allocated, total.Allocated)
+	require.Equal(t, aggregate.Inuse, total.Inuse)
+	require.Equal(t, aggregate.Garbage, total.Garbage)
+	require.Equal(t, aggregate.Length, total.Length)
+
+	require.Equal(t, 5, stats[0].Length)
+	require.NotZero(t, stats[0].Garbage)
+	require.Zero(t, stats[1].Garbage)
+	require.Equal(t, 10, stats[2].Length)
+}
+
+func TestKVStore_MatchOnKeyAndValue(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("maxKeysPerTable", 10)
+	s := testKVStore(t, c)
+	kv := s.(*KVStore)
+
+	for i := 0; i < 30; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte(fmt.Sprintf(`{"id": %d, "even": %t}`, i, i%2 == 0)))
+		err := s.Put(xxhash.Sum64([]byte(e.Key())), e)
+		require.NoError(t, err)
+	}
+
+	var values int
+	err := kv.MatchOnValue(`"even": true`, func(hkey uint64, e storage.Entry) bool {
+		require.Equal(t, xxhash.Sum64([]byte(e.Key())), hkey)
+		require.Contains(t, string(e.Value()), `"even": true`)
+		values++
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, 15, values)
+
+	var keys int
+	err = kv.MatchOnKey(`^0+2[0-9]$`, func(hkey uint64, e storage.Entry) bool {
+		keys++
+		return true
+	})
+	require.NoError(t, err)
+	require.Equal(t, 10, keys)
+
+	t.Run("Stop the iteration", func(t *testing.T) {
+		var num int
+		err := kv.MatchOnValue(`"id"`, func(hkey uint64, e storage.Entry) bool {
+			num++
+			return false
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, num)
+	})
+
+	t.Run("Invalid expression", func(t *testing.T) {
+		err := kv.MatchOnValue(`[`, func(hkey uint64, e storage.Entry) bool {
+			return true
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestKVStore_MaxKeysPerTable(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("maxKeysPerTable", 100)
+	s := testKVStore(t, c)
+
+	for i := 0; i < 1000; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue([]byte{1})
+		hkey := xxhash.Sum64([]byte(e.Key()))
+		err := s.Put(hkey, e)
+		require.NoError(t, err)
+	}
+
+	// Overwriting a key in the head table doesn't create a new table.
+	e := entry.New()
+	e.SetKey(bkey(999))
+	e.SetValue([]byte{2})
+	require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+
+	kv := s.(*KVStore)
+	// The byte space of a single table is enough for all the values.
+	require.Len(t, kv.tables, 10)
+	for _, tb := range kv.tables {
+		require.Equal(t, 100, tb.Stats().Length)
+	}
+	require.Equal(t, 1000, s.Stats().Length)
+
+	for i := 0; i < 1000; i++ {
+		_, err := s.Get(xxhash.Sum64([]byte(bkey(i))))
+		require.NoError(t, err)
+	}
+}
+
 func TestKVStore_Range(t *testing.T) {
 	s := testKVStore(t, nil)
 
@@ -394,6 +568,19 @@ func TestKVStore_Fork(t *testing.T) {
 	}
 }
 
+func TestKVStore_Fork_TableSize(t *testing.T) {
+	s := testKVStore(t, nil)
+
+	c := DefaultConfig()
+	c.Add("tableSize", 1<<12)
+	child, err := s.Fork(c)
+	require.NoError(t, err)
+
+	stats := child.Stats()
+	require.Equal(t, 1<<12, stats.Allocated)
+	require.Equal(t, 1, stats.NumTables)
+}
+
 func TestKVStore_StateChange(t *testing.T) {
 	s := testKVStore(t, nil)
 
@@ -472,6 +659,62 @@ func TestStorage_Scan(t *testing.T) {
 	require.Equal(t, 1000000, count)
 }
 
+func TestKVStore_Scan_Mutations(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("tableSize", 1<<12)
+	c.Add("maxKeysPerTable", 10)
+	s := testKVStore(t, c)
+	kv := s.(*KVStore)
+
+	put := func(i int) {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue(bval(i))
+		require.NoError(t, s.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+	for i := 0; i < 50; i++ {
+		put(i)
+	}
+	require.Equal(t, 5, s.Stats().NumTables)
+
+	// Drain the second table, the compaction drops it and leaves a gap in the coefficients.
+	for i := 10; i < 20; i++ {
+		require.NoError(t, s.Delete(xxhash.Sum64([]byte(bkey(i)))))
+	}
+	dropped := kv.tables[1].Coefficient()
+	require.NoError(t, kv.evictTable(kv.tables[1]))
+	require.NotContains(t, kv.tablesByCoefficient, dropped)
+
+	var err error
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		cursor, err = kv.Scan(cursor, 3, func(e storage.Entry) bool {
+			seen[e.Key()]++
+			return true
+		})
+		require.NoError(t, err)
+		if cursor == 0 {
+			break
+		}
+		// New tables are appended during the scan.
+		if len(seen) == 6 {
+			for i := 50; i < 70; i++ {
+				put(i)
+			}
+		}
+	}
+
+	// The keys that exist during the whole scan are returned at least once.
+	for i := 0; i < 50; i++ {
+		if i >= 10 && i < 20 {
+			continue
+		}
+		require.GreaterOrEqual(t, seen[bkey(i)], 1, bkey(i))
+	}
+}
+
 func TestStorage_ScanRegexMatch(t *testing.T) {
 	s := testKVStore(t, nil)
 
@@ -575,3 +818,35 @@ func TestKVStore_Put_ErrEntryTooLarge(t *testing.T) {
 	err := s.Put(hkey, e)
 	require.ErrorIs(t, err, storage.ErrEntryTooLarge)
 }
+
+func TestKVStore_Put_ErrEntryTooLarge_EmptyStore(t *testing.T) {
+	c := DefaultConfig()
+	c.Add("tableSize", 1024)
+	s := testKVStore(t, c)
+	kv := s.(*KVStore)
+	require.Equal(t, 0, kv.Stats().Inuse)
+
+	e := entry.New()
+	e.SetKey("key")
+	e.SetTimestamp(time.Now().UnixNano())
+	hkey := xxhash.Sum64([]byte(e.Key()))
+
+	// The entry requires exactly the table size, a table cannot hold it.
+	e.SetValue(make([]byte, 1024-len(e.Key())-table.MetadataLength))
+	err := kv.Put(hkey, e)
+	require.ErrorIs(t, err, storage.ErrEntryTooLarge)
+
+	var tooLarge *storage.EntryTooLargeError
+	require.ErrorAs(t, err, &tooLarge)
+	require.Equal(t, "key", tooLarge.Key)
+	require.Equal(t, uint64(1024), tooLarge.Size)
+	require.Equal(t, uint64(1024), tooLarge.TableSize)
+
+	err = kv.PutRaw(hkey, make([]byte, 1024))
+	require.ErrorIs(t, err, storage.ErrEntryTooLarge)
+
+	// One byte less fits in a new table.
+	e.SetValue(make([]byte, 1023-len(e.Key())-table.MetadataLength))
+	require.NoError(t, kv.Put(hkey, e))
+	require.Len(t, kv.tables, 1)
+}
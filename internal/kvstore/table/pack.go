@@ -19,7 +19,15 @@ import (
 	"github.com/vmihailenco/msgpack/v5"
 )
 
+// PackVersion is the version of the transport format written by Encode. The packs
+// without a version field are written by the older releases, they have version 0.
+const PackVersion uint8 = 1
+
+// Pack is the transport format of a table. It's used to move the partitions between
+// the members, so the members of different releases must be able to decode it during
+// a rolling upgrade.
 type Pack struct {
+	Version     uint8
 	Offset      uint64
 	Allocated   uint64
 	Inuse       uint64
@@ -37,6 +45,7 @@ func Encode(t *Table) ([]byte, error) {
 		return nil, err
 	}
 	p := Pack{
+		Version:     PackVersion,
 		Offset:      t.offset,
 		Allocated:   t.allocated,
 		Inuse:       t.inuse,
@@ -52,9 +61,66 @@ func Encode(t *Table) ([]byte, error) {
 	return msgpack.Marshal(p)
 }
 
-func Decode(data []byte) (*Table, error) {
+// legacyArrayPack is the version 0 format encoded as an array, as the msgpack libraries
+// that encode the structs as arrays do. The array-encoded structs cannot be decoded into
+// a struct with a different number of fields.
+type legacyArrayPack struct {
+	_msgpack    struct{} `msgpack:",as_array"`
+	Offset      uint64
+	Allocated   uint64
+	Inuse       uint64
+	Garbage     uint64
+	RecycledAt  int64
+	State       State
+	HKeys       map[uint64]uint64
+	OffsetIndex []byte
+	Memory      []byte
+}
+
+func decodeLegacyArrayPack(data []byte) (*Pack, error) {
+	lp := &legacyArrayPack{}
+	err := msgpack.Unmarshal(data, lp)
+	if err != nil {
+		return nil, err
+	}
+	return &Pack{
+		Offset:      lp.Offset,
+		Allocated:   lp.Allocated,
+		Inuse:       lp.Inuse,
+		Garbage:     lp.Garbage,
+		RecycledAt:  lp.RecycledAt,
+		State:       lp.State,
+		HKeys:       lp.HKeys,
+		OffsetIndex: lp.OffsetIndex,
+		Memory:      lp.Memory,
+	}, nil
+}
+
+// fallbackDecoders are tried in order if a pack cannot be decoded in the current format.
+var fallbackDecoders = []func(data []byte) (*Pack, error){
+	decodeLegacyArrayPack,
+}
+
+// decodePack decodes a pack in the current format. The map-encoded packs of the other
+// versions are decoded in the same way, the missing fields are left zero and the unknown
+// fields are ignored. If it fails, the fallback decoders are tried.
+func decodePack(data []byte) (*Pack, error) {
 	p := &Pack{}
 	err := msgpack.Unmarshal(data, p)
+	if err == nil {
+		return p, nil
+	}
+	for _, decode := range fallbackDecoders {
+		fp, fallbackErr := decode(data)
+		if fallbackErr == nil {
+			return fp, nil
+		}
+	}
+	return nil, err
+}
+
+func Decode(data []byte) (*Table, error) {
+	p, err := decodePack(data)
 	if err != nil {
 		return nil, err
 	}
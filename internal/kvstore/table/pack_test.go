@@ -15,6 +15,7 @@
 package table
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 	"time"
@@ -22,6 +23,7 @@ import (
 	"github.com/buraksezer/olric/internal/kvstore/entry"
 	"github.com/cespare/xxhash/v2"
 	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
 func bkey(i int) string {
@@ -65,3 +67,76 @@ func TestTable_Pack_EncodeDecode(t *testing.T) {
 	}
 
 }
+
+// packV0 is the transport format of the releases before the version field.
+type packV0 struct {
+	Offset      uint64
+	Allocated   uint64
+	Inuse       uint64
+	Garbage     uint64
+	RecycledAt  int64
+	State       State
+	HKeys       map[uint64]uint64
+	OffsetIndex []byte
+	Memory      []byte
+}
+
+func TestTable_Pack_Decode_Legacy(t *testing.T) {
+	tb := New(1 << 16)
+	for i := 0; i < 100; i++ {
+		e := entry.New()
+		e.SetKey(bkey(i))
+		e.SetValue(bval(i))
+		require.NoError(t, tb.Put(xxhash.Sum64([]byte(e.Key())), e))
+	}
+
+	offsetIndex, err := tb.offsetIndex.MarshalBinary()
+	require.NoError(t, err)
+	old := packV0{
+		Offset:      tb.offset,
+		Allocated:   tb.allocated,
+		Inuse:       tb.inuse,
+		Garbage:     tb.garbage,
+		State:       tb.state,
+		HKeys:       tb.hkeys,
+		OffsetIndex: offsetIndex,
+		Memory:      tb.memory[:tb.offset],
+	}
+
+	check := func(t *testing.T, data []byte) {
+		decoded, err := Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, tb.Stats().Length, decoded.Stats().Length)
+		for i := 0; i < 100; i++ {
+			e, err := decoded.Get(xxhash.Sum64([]byte(bkey(i))))
+			require.NoError(t, err)
+			require.Equal(t, bkey(i), e.Key())
+			require.Equal(t, bval(i), e.Value())
+		}
+	}
+
+	t.Run("Map encoded", func(t *testing.T) {
+		data, err := msgpack.Marshal(old)
+		require.NoError(t, err)
+		check(t, data)
+	})
+
+	t.Run("Array encoded", func(t *testing.T) {
+		var buf bytes.Buffer
+		enc := msgpack.NewEncoder(&buf)
+		enc.UseArrayEncodedStructs(true)
+		require.NoError(t, enc.Encode(old))
+
+		// The current format cannot decode it.
+		require.Error(t, msgpack.Unmarshal(buf.Bytes(), &Pack{}))
+		check(t, buf.Bytes())
+	})
+
+	t.Run("Current version", func(t *testing.T) {
+		data, err := Encode(tb)
+		require.NoError(t, err)
+		p := &Pack{}
+		require.NoError(t, msgpack.Unmarshal(data, p))
+		require.Equal(t, PackVersion, p.Version)
+	})
+}
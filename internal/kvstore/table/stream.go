@@ -0,0 +1,163 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// StreamVersion is the version of the streaming format written by EncodeTo.
+const StreamVersion uint8 = 1
+
+// streamChunkSize is the size of the chunks that the memory of a table is written in.
+const streamChunkSize = 64 << 10
+
+// streamMagic is the prefix of a table in the streaming format, it's followed by the version.
+var streamMagic = []byte("OLTB")
+
+// ErrIncompatibleStream is returned when a table is written in a newer version of the
+// streaming format.
+var ErrIncompatibleStream = errors.New("incompatible table stream version")
+
+// streamHeader is written after the magic and the version. The HKeys and the memory of
+// the table follow it, the memory is not encoded with msgpack.
+type streamHeader struct {
+	Offset      uint64
+	Allocated   uint64
+	Inuse       uint64
+	Garbage     uint64
+	RecycledAt  int64
+	State       State
+	NumHKeys    int
+	OffsetIndex []byte
+}
+
+// IsStream returns true if the data starts with a table in the streaming format.
+func IsStream(data []byte) bool {
+	return bytes.HasPrefix(data, streamMagic)
+}
+
+// EncodeTo writes the table to w in the streaming format. Unlike Encode, it doesn't copy
+// the memory of the table, the memory is written in chunks. w should be buffered, the
+// HKeys are written one by one.
+func EncodeTo(w io.Writer, t *Table) error {
+	offsetIndex, err := t.offsetIndex.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if _, err = w.Write(streamMagic); err != nil {
+		return err
+	}
+	if _, err = w.Write([]byte{StreamVersion}); err != nil {
+		return err
+	}
+
+	enc := msgpack.NewEncoder(w)
+	err = enc.Encode(&streamHeader{
+		Offset:      t.offset,
+		Allocated:   t.allocated,
+		Inuse:       t.inuse,
+		Garbage:     t.garbage,
+		RecycledAt:  t.recycledAt,
+		State:       t.state,
+		NumHKeys:    len(t.hkeys),
+		OffsetIndex: offsetIndex,
+	})
+	if err != nil {
+		return err
+	}
+	for hkey, offset := range t.hkeys {
+		if err = enc.EncodeUint64(hkey); err != nil {
+			return err
+		}
+		if err = enc.EncodeUint64(offset); err != nil {
+			return err
+		}
+	}
+
+	for start := uint64(0); start < t.offset; start += streamChunkSize {
+		end := start + streamChunkSize
+		if end > t.offset {
+			end = t.offset
+		}
+		if _, err = w.Write(t.memory[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DecodeFrom reads a table in the streaming format from r. It reads exactly one table,
+// so the tables written one after another can be read with the same reader.
+func DecodeFrom(r *bufio.Reader) (*Table, error) {
+	prefix := make([]byte, len(streamMagic)+1)
+	if _, err := io.ReadFull(r, prefix); err != nil {
+		return nil, err
+	}
+	if !IsStream(prefix) {
+		return nil, fmt.Errorf("invalid table stream header")
+	}
+	if version := prefix[len(streamMagic)]; version > StreamVersion {
+		return nil, fmt.Errorf("%w: %d", ErrIncompatibleStream, version)
+	}
+
+	// The decoder reads directly from r, because bufio.Reader implements io.ByteScanner.
+	dec := msgpack.NewDecoder(r)
+	h := &streamHeader{}
+	if err := dec.Decode(h); err != nil {
+		return nil, err
+	}
+
+	rb := roaring64.New()
+	if err := rb.UnmarshalBinary(h.OffsetIndex); err != nil {
+		return nil, err
+	}
+	if h.Offset > h.Allocated {
+		return nil, fmt.Errorf("invalid table offset: %d", h.Offset)
+	}
+
+	t := New(h.Allocated)
+	t.offset = h.Offset
+	t.inuse = h.Inuse
+	t.garbage = h.Garbage
+	t.recycledAt = h.RecycledAt
+	t.state = h.State
+	t.offsetIndex = rb
+
+	for i := 0; i < h.NumHKeys; i++ {
+		hkey, err := dec.DecodeUint64()
+		if err != nil {
+			return nil, err
+		}
+		offset, err := dec.DecodeUint64()
+		if err != nil {
+			return nil, err
+		}
+		t.hkeys[hkey] = offset
+	}
+
+	if _, err := io.ReadFull(r, t.memory[:t.offset]); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
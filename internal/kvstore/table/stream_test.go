@@ -0,0 +1,72 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package table
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/buraksezer/olric/internal/kvstore/entry"
+	"github.com/cespare/xxhash/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTable_Stream_EncodeDecode(t *testing.T) {
+	// Two tables in the same stream, the memory is larger than a chunk.
+	var tables []*Table
+	var buf bytes.Buffer
+	for n := 0; n < 2; n++ {
+		tb := New(1 << 20)
+		for i := 0; i < 5000; i++ {
+			e := entry.New()
+			e.SetKey(bkey(n*5000 + i))
+			e.SetValue(bval(i))
+			require.NoError(t, tb.Put(xxhash.Sum64([]byte(e.Key())), e))
+		}
+		require.NoError(t, EncodeTo(&buf, tb))
+		tables = append(tables, tb)
+	}
+	require.True(t, IsStream(buf.Bytes()))
+
+	r := bufio.NewReader(&buf)
+	for n, tb := range tables {
+		decoded, err := DecodeFrom(r)
+		require.NoError(t, err)
+		require.Equal(t, tb.Stats().Inuse, decoded.Stats().Inuse)
+		require.Equal(t, tb.Stats().Length, decoded.Stats().Length)
+		for i := 0; i < 5000; i++ {
+			e, err := decoded.Get(xxhash.Sum64([]byte(bkey(n*5000 + i))))
+			require.NoError(t, err)
+			require.Equal(t, bval(i), e.Value())
+		}
+	}
+	_, err := r.Peek(1)
+	require.Error(t, err)
+}
+
+func TestTable_Stream_IncompatibleVersion(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, EncodeTo(&buf, New(1024)))
+
+	data := buf.Bytes()
+	data[len(streamMagic)] = StreamVersion + 1
+
+	_, err := DecodeFrom(bufio.NewReader(bytes.NewReader(data)))
+	require.ErrorIs(t, err, ErrIncompatibleStream)
+
+	_, err = DecodeFrom(bufio.NewReader(bytes.NewReader([]byte("not a table"))))
+	require.Error(t, err)
+}
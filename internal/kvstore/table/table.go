@@ -29,7 +29,7 @@ import (
 
 const (
 	MaxKeyLength   = 256
-	MetadataLength = 29
+	MetadataLength = 37
 )
 
 type State uint8
@@ -46,11 +46,12 @@ var (
 )
 
 type Stats struct {
-	Allocated  uint64
-	Inuse      uint64
-	Garbage    uint64
-	Length     int
-	RecycledAt int64
+	Allocated   uint64
+	Inuse       uint64
+	Garbage     uint64
+	Length      int
+	RecycledAt  int64
+	LastWriteAt int64
 }
 
 type Table struct {
@@ -61,6 +62,7 @@ type Table struct {
 	inuse         uint64
 	garbage       uint64
 	recycledAt    int64
+	lastWriteAt   int64
 	state         State
 	hkeys         map[uint64]uint64
 	offsetIndex   *roaring64.Bitmap
@@ -73,6 +75,7 @@ func New(size uint64) *Table {
 		allocated:   size,
 		offsetIndex: roaring64.New(),
 		state:       ReadWriteState,
+		lastWriteAt: time.Now().UnixNano(),
 	}
 	//  From builtin.go:
 	//
@@ -113,12 +116,13 @@ func (t *Table) PutRaw(hkey uint64, value []byte) error {
 	copy(t.memory[t.offset:], value)
 	t.inuse += inuse
 	t.offset += inuse
+	t.lastWriteAt = time.Now().UnixNano()
 	return nil
 }
 
 // In-memory layout for entry:
 //
-// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VALUE-LENGTH(uint64) | VALUE(bytes)
+// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VERSION(uint64) | VALUE-LENGTH(uint64) | VALUE(bytes)
 func (t *Table) Put(hkey uint64, value storage.Entry) error {
 	if len(value.Key()) >= MaxKeyLength {
 		return storage.ErrKeyTooLarge
@@ -126,7 +130,7 @@ func (t *Table) Put(hkey uint64, value storage.Entry) error {
 
 	// Check empty space on allocated memory area.
 
-	// TTL + Timestamp + LastAccess + + value-Length + key-Length
+	// TTL + Timestamp + LastAccess + Version + value-Length + key-Length
 	inuse := uint64(len(value.Key()) + len(value.Value()) + MetadataLength)
 	if inuse+t.offset >= t.allocated {
 		return ErrNotEnoughSpace
@@ -144,6 +148,7 @@ func (t *Table) Put(hkey uint64, value storage.Entry) error {
 	t.hkeys[hkey] = t.offset
 	t.offsetIndex.Add(t.offset)
 	t.inuse += inuse
+	t.lastWriteAt = time.Now().UnixNano()
 
 	// Set key length. It's 1 byte.
 	klen := uint8(len(value.Key()))
@@ -166,6 +171,14 @@ func (t *Table) Put(hkey uint64, value storage.Entry) error {
 	binary.BigEndian.PutUint64(t.memory[t.offset:], uint64(time.Now().UnixNano()))
 	t.offset += 8
 
+	// Set the version. It's 8 bytes.
+	var version int64
+	if ve, ok := value.(storage.VersionedEntry); ok {
+		version = ve.Version()
+	}
+	binary.BigEndian.PutUint64(t.memory[t.offset:], uint64(version))
+	t.offset += 8
+
 	// Set the value length. It's 4 bytes.
 	binary.BigEndian.PutUint32(t.memory[t.offset:], uint32(len(value.Value())))
 	t.offset += 4
@@ -184,14 +197,15 @@ func (t *Table) GetRaw(hkey uint64) ([]byte, error) {
 	start, end := offset, offset
 
 	// In-memory structure:
-	// 1                 | klen       | 8           | 8                  | 8                  | 4                    | vlen
-	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64)  | LASTACCESS(uint64) | VALUE-LENGTH(uint64) | VALUE(bytes)
+	// 1                 | klen       | 8           | 8                  | 8                  | 8               | 4                    | vlen
+	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64)  | LASTACCESS(uint64) | VERSION(uint64) | VALUE-LENGTH(uint64) | VALUE(bytes)
 	klen := uint64(t.memory[end])
 	end++       // One byte to keep key length
 	end += klen // key length
 	end += 8    // TTL
 	end += 8    // Timestamp
 	end += 8    // LastAccess
+	end += 8    // Version
 
 	vlen := binary.BigEndian.Uint32(t.memory[end : end+4])
 	end += 4            // 4 bytes to keep value length
@@ -209,6 +223,16 @@ func (t *Table) getRawKey(offset uint64) ([]byte, error) {
 	return t.memory[offset : offset+klen], nil
 }
 
+// getRawValue returns the value stored at the offset without decoding the metadata.
+func (t *Table) getRawValue(offset uint64) []byte {
+	klen := uint64(t.memory[offset])
+	// Skip KEY-LENGTH, KEY, TTL, TIMESTAMP, LASTACCESS and VERSION
+	offset += 1 + klen + 32
+	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
+	offset += 4
+	return t.memory[offset : offset+uint64(vlen)]
+}
+
 func (t *Table) GetRawKey(hkey uint64) ([]byte, error) {
 	offset, ok := t.hkeys[hkey]
 	if !ok {
@@ -258,7 +282,7 @@ func (t *Table) get(offset uint64) storage.Entry {
 	e := &entry.Entry{}
 	// In-memory structure:
 	//
-	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
+	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VERSION(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 	klen := uint64(t.memory[offset])
 	offset++
 
@@ -283,6 +307,9 @@ func (t *Table) get(offset uint64) storage.Entry {
 	t.lastAccessMtx.Unlock()
 	offset += 8
 
+	e.SetVersion(int64(binary.BigEndian.Uint64(t.memory[offset : offset+8])))
+	offset += 8
+
 	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
 	offset += 4
 	e.SetValue(t.memory[offset : offset+uint64(vlen)])
@@ -298,7 +325,7 @@ func (t *Table) Get(hkey uint64) (storage.Entry, error) {
 	e := &entry.Entry{}
 	// In-memory structure:
 	//
-	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
+	// KEY-LENGTH(uint8) | KEY(bytes) | TTL(uint64) | TIMESTAMP(uint64) | LASTACCESS(uint64) | VERSION(uint64) | VALUE-LENGTH(uint32) | VALUE(bytes)
 	klen := uint64(t.memory[offset])
 	offset++
 
@@ -324,6 +351,9 @@ func (t *Table) Get(hkey uint64) (storage.Entry, error) {
 
 	offset += 8
 
+	e.SetVersion(int64(binary.BigEndian.Uint64(t.memory[offset : offset+8])))
+	offset += 8
+
 	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
 	offset += 4
 	e.SetValue(t.memory[offset : offset+uint64(vlen)])
@@ -360,6 +390,10 @@ func (t *Table) Delete(hkey uint64) error {
 	offset += 8
 	garbage += 8
 
+	// Version, skip it.
+	offset += 8
+	garbage += 8
+
 	// value len and its header.
 	vlen := binary.BigEndian.Uint32(t.memory[offset : offset+4])
 	garbage += 4 + uint64(vlen)
@@ -369,6 +403,7 @@ func (t *Table) Delete(hkey uint64) error {
 
 	t.garbage += garbage
 	t.inuse -= garbage
+	t.lastWriteAt = time.Now().UnixNano()
 	return nil
 }
 
@@ -393,6 +428,7 @@ func (t *Table) UpdateTTL(hkey uint64, value storage.Entry) error {
 
 	// Update the last access field
 	binary.BigEndian.PutUint64(t.memory[offset:], uint64(time.Now().UnixNano()))
+	t.lastWriteAt = time.Now().UnixNano()
 
 	return nil
 }
@@ -404,11 +440,12 @@ func (t *Table) Check(hkey uint64) bool {
 
 func (t *Table) Stats() Stats {
 	return Stats{
-		Allocated:  t.allocated,
-		Inuse:      t.inuse,
-		Garbage:    t.garbage,
-		Length:     len(t.hkeys),
-		RecycledAt: t.recycledAt,
+		Allocated:   t.allocated,
+		Inuse:       t.inuse,
+		Garbage:     t.garbage,
+		Length:      len(t.hkeys),
+		RecycledAt:  t.recycledAt,
+		LastWriteAt: t.lastWriteAt,
 	}
 }
 
@@ -425,6 +462,22 @@ func (t *Table) Range(f func(hkey uint64, e storage.Entry) bool) {
 	}
 }
 
+// RangeMatch calls f for the entries accepted by match. match is called with the raw key
+// and value of every entry, so the rejected entries are not decoded. It returns false if
+// f stops the iteration.
+func (t *Table) RangeMatch(match func(key, value []byte) bool, f func(hkey uint64, e storage.Entry) bool) bool {
+	for hkey, offset := range t.hkeys {
+		key, _ := t.getRawKey(offset)
+		if !match(key, t.getRawValue(offset)) {
+			continue
+		}
+		if !f(hkey, t.get(offset)) {
+			return false
+		}
+	}
+	return true
+}
+
 func (t *Table) RangeHKey(f func(hkey uint64) bool) {
 	for hkey := range t.hkeys {
 		if !f(hkey) {
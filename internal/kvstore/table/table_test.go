@@ -59,6 +59,25 @@ func TestTable_Get(t *testing.T) {
 	require.NotEqual(t, int64(0), value.LastAccess())
 }
 
+func TestTable_Get_Version(t *testing.T) {
+	tb, e := setupTable()
+	e.(*entry.Entry).SetVersion(7)
+	err := tb.Put(hkey, e)
+	require.NoError(t, err)
+
+	value, err := tb.Get(hkey)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), value.(storage.VersionedEntry).Version())
+	require.Equal(t, e.Value(), value.Value())
+
+	raw, err := tb.GetRaw(hkey)
+	require.NoError(t, err)
+	extracted := entry.New()
+	extracted.Decode(raw)
+	require.Equal(t, int64(7), extracted.Version())
+	require.Equal(t, e.Value(), extracted.Value())
+}
+
 func TestTable_Delete(t *testing.T) {
 	tb, e := setupTable()
 
@@ -242,6 +261,36 @@ func TestTable_Range(t *testing.T) {
 	})
 }
 
+func TestTable_RangeMatch(t *testing.T) {
+	tb := New(1 << 20)
+	for i := 0; i < 100; i++ {
+		e := entry.New()
+		e.SetKey(fmt.Sprintf("key-%d", i))
+		e.SetValue([]byte(fmt.Sprintf("value-%d", i%10)))
+		err := tb.Put(xxhash.Sum64String(e.Key()), e)
+		require.NoError(t, err)
+	}
+
+	var num int
+	completed := tb.RangeMatch(func(key, value []byte) bool {
+		return string(value) == "value-3"
+	}, func(hk uint64, e storage.Entry) bool {
+		require.Equal(t, xxhash.Sum64String(e.Key()), hk)
+		require.Equal(t, []byte("value-3"), e.Value())
+		num++
+		return true
+	})
+	require.True(t, completed)
+	require.Equal(t, 10, num)
+
+	completed = tb.RangeMatch(func(key, value []byte) bool {
+		return true
+	}, func(hk uint64, e storage.Entry) bool {
+		return false
+	})
+	require.False(t, completed)
+}
+
 func TestTable_Stats(t *testing.T) {
 	tb := New(1 << 20)
 	for i := 0; i < 100; i++ {
@@ -258,7 +307,7 @@ func TestTable_Stats(t *testing.T) {
 	s := tb.Stats()
 	require.Equal(t, uint64(1<<20), s.Allocated)
 	require.Equal(t, 100, s.Length)
-	require.Equal(t, uint64(4280), s.Inuse)
+	require.Equal(t, uint64(5080), s.Inuse)
 	require.Equal(t, uint64(0), s.Garbage)
 
 	for i := 0; i < 100; i++ {
@@ -272,7 +321,7 @@ func TestTable_Stats(t *testing.T) {
 	require.Equal(t, uint64(1<<20), s.Allocated)
 	require.Equal(t, 0, s.Length)
 	require.Equal(t, uint64(0), s.Inuse)
-	require.Equal(t, uint64(4280), s.Garbage)
+	require.Equal(t, uint64(5080), s.Garbage)
 }
 
 func TestTable_Reset(t *testing.T) {
@@ -15,6 +15,8 @@
 package kvstore
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 
@@ -48,27 +50,80 @@ func (t *transferIterator) Export() ([]byte, int, error) {
 			continue
 		}
 
-		data, err := table.Encode(t)
+		var buf bytes.Buffer
+		err := table.EncodeTo(&buf, t)
 		if err != nil {
 			return nil, 0, err
 		}
-		return data, index, nil
+		return buf.Bytes(), index, nil
 	}
 	return nil, 0, io.EOF
 }
 
+// Import calls f for every entry of the tables in data. data is written by Export or
+// ExportTo. The tables in the msgpack format written by the older releases are also
+// supported.
 func (k *KVStore) Import(data []byte, f func(uint64, storage.Entry) error) error {
+	if table.IsStream(data) {
+		return k.ImportFrom(bytes.NewReader(data), f)
+	}
+
 	tb, err := table.Decode(data)
 	if err != nil {
 		return err
 	}
+	return importTable(tb, f)
+}
 
+func importTable(tb *table.Table, f func(uint64, storage.Entry) error) error {
+	var err error
 	tb.Range(func(hkey uint64, e storage.Entry) bool {
-		return f(hkey, e) == nil
+		err = f(hkey, e)
+		return err == nil
 	})
 	return err
 }
 
+// ExportTo writes the tables to w one after another in the streaming format of the tables.
+// The memory of the tables is not copied, so it doesn't need extra memory in the size of
+// the store. The format starts with a version header, ImportFrom returns an error if the
+// data is written by a newer release in an incompatible format.
+func (k *KVStore) ExportTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range k.tables {
+		if t.State() == table.RecycledState {
+			continue
+		}
+		if err := table.EncodeTo(bw, t); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// ImportFrom reads the tables written by ExportTo from r, and calls f for every entry.
+// It decodes one table at a time.
+func (k *KVStore) ImportFrom(r io.Reader, f func(uint64, storage.Entry) error) error {
+	br := bufio.NewReader(r)
+	for {
+		_, err := br.Peek(1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		tb, err := table.DecodeFrom(br)
+		if err != nil {
+			return err
+		}
+		if err = importTable(tb, f); err != nil {
+			return err
+		}
+	}
+}
+
 func (k *KVStore) TransferIterator() storage.TransferIterator {
 	return &transferIterator{
 		storage: k,
@@ -17,6 +17,7 @@ package protocol
 import (
 	"context"
 
+	"github.com/buraksezer/olric/internal/util"
 	"github.com/redis/go-redis/v9"
 	"github.com/tidwall/redcon"
 )
@@ -62,3 +63,34 @@ func ParseClusterMembers(cmd redcon.Command) (*ClusterMembers, error) {
 	c := NewClusterMembers()
 	return c, nil
 }
+
+type ClusterKeyPlacement struct {
+	DMap string
+	Key  string
+}
+
+func NewClusterKeyPlacement(dmap, key string) *ClusterKeyPlacement {
+	return &ClusterKeyPlacement{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (c *ClusterKeyPlacement) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, Cluster.KeyPlacement)
+	args = append(args, c.DMap)
+	args = append(args, c.Key)
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseClusterKeyPlacement(cmd redcon.Command) (*ClusterKeyPlacement, error) {
+	if len(cmd.Args) != 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewClusterKeyPlacement(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
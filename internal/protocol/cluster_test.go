@@ -48,3 +48,19 @@ func TestProtocol_ClusterMembers(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestProtocol_ClusterKeyPlacement(t *testing.T) {
+	kpCmd := NewClusterKeyPlacement("my-dmap", "my-key")
+
+	cmd := stringToCommand(kpCmd.Command(context.Background()).String())
+	parsed, err := ParseClusterKeyPlacement(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+
+	t.Run("CLUSTER.KEYPLACEMENT invalid command", func(t *testing.T) {
+		cmd := stringToCommand("cluster.keyplacement my-dmap")
+		_, err = ParseClusterKeyPlacement(cmd)
+		require.Error(t, err)
+	})
+}
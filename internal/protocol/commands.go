@@ -19,11 +19,13 @@ const StatusOK = "OK"
 type ClusterCommands struct {
 	RoutingTable string
 	Members      string
+	KeyPlacement string
 }
 
 var Cluster = &ClusterCommands{
 	RoutingTable: "cluster.routingtable",
 	Members:      "cluster.members",
+	KeyPlacement: "cluster.keyplacement",
 }
 
 type InternalCommands struct {
@@ -31,65 +33,169 @@ type InternalCommands struct {
 	UpdateRouting       string
 	LengthOfPart        string
 	ClusterRoutingTable string
+	ReplaceFragment     string
+	DMapNames           string
 }
 
 var Internal = &InternalCommands{
-	MoveFragment:  "internal.node.movefragment",
-	UpdateRouting: "internal.node.updaterouting",
-	LengthOfPart:  "internal.node.lengthofpart",
+	MoveFragment:    "internal.node.movefragment",
+	UpdateRouting:   "internal.node.updaterouting",
+	LengthOfPart:    "internal.node.lengthofpart",
+	ReplaceFragment: "internal.node.replacefragment",
+	DMapNames:       "internal.node.dmapnames",
 }
 
 type GenericCommands struct {
-	Ping  string
-	Stats string
+	Ping          string
+	Stats         string
+	CompactTables string
 }
 
 var Generic = &GenericCommands{
-	Ping:  "ping",
-	Stats: "stats",
+	Ping:          "ping",
+	Stats:         "stats",
+	CompactTables: "compacttables",
 }
 
 type DMapCommands struct {
-	Get         string
-	GetEntry    string
-	Put         string
-	PutEntry    string
-	Del         string
-	DelEntry    string
-	Expire      string
-	PExpire     string
-	Destroy     string
-	Query       string
-	Incr        string
-	Decr        string
-	GetPut      string
-	IncrByFloat string
-	Lock        string
-	Unlock      string
-	LockLease   string
-	PLockLease  string
-	Scan        string
+	Get                 string
+	GetEntry            string
+	Put                 string
+	PutEntry            string
+	UpdateEntryTTL      string
+	Del                 string
+	DelEntry            string
+	Expire              string
+	PExpire             string
+	Destroy             string
+	Query               string
+	Incr                string
+	Decr                string
+	GetPut              string
+	IncrByFloat         string
+	Lock                string
+	Unlock              string
+	LockLease           string
+	PLockLease          string
+	LockLeasePut        string
+	Scan                string
+	SetNXGet            string
+	DecrAndDeleteAtZero string
+	Eval                string
+	PutAllIfNoneExist   string
+	EntryAgeRange       string
+	MemoryUsage         string
+	ExpiringSoon        string
+	ValueHashes         string
+	Diff                string
+	ClaimNext           string
+	SyncReplica         string
+	GetTTLMany          string
+	Rotate              string
+	DelIfExpiredBefore  string
+	HotKeys             string
+	HIncrBy             string
+	HMSet               string
+	HMGet               string
+	AcquirePermit       string
+	ReleasePermit       string
+	PutWithVersion      string
+	LPushCapped         string
+	LRange              string
+	CapTTL              string
+	SetBit              string
+	GetBit              string
+	GetAndReset         string
+	PutIfField          string
+	Merge               string
+	SetStandby          string
+	PromoteStandby      string
+	GetByTag            string
+	GetDel              string
+	Register            string
+	Heartbeat           string
+	Discover            string
+	Append              string
+	Persist             string
+	TTL                 string
+	PTTL                string
+	Campaign            string
+	Resign              string
+	GetMany             string
+	PutMany             string
+	Exists              string
+	ReserveSequence     string
+	Changes             string
 }
 
 var DMap = &DMapCommands{
-	Get:         "dm.get",
-	GetEntry:    "dm.getentry",
-	Put:         "dm.put",
-	PutEntry:    "dm.putentry",
-	Del:         "dm.del",
-	DelEntry:    "dm.delentry",
-	Expire:      "dm.expire",
-	PExpire:     "dm.pexpire",
-	Destroy:     "dm.destroy",
-	Incr:        "dm.incr",
-	Decr:        "dm.decr",
-	GetPut:      "dm.getput",
-	IncrByFloat: "dm.incrbyfloat",
-	Lock:        "dm.lock",
-	Unlock:      "dm.unlock",
-	LockLease:   "dm.locklease",
-	PLockLease:  "dm.plocklease",
-	Scan:        "dm.scan",
+	Get:                 "dm.get",
+	GetEntry:            "dm.getentry",
+	Put:                 "dm.put",
+	PutEntry:            "dm.putentry",
+	UpdateEntryTTL:      "dm.updateentryttl",
+	Del:                 "dm.del",
+	DelEntry:            "dm.delentry",
+	Expire:              "dm.expire",
+	PExpire:             "dm.pexpire",
+	Destroy:             "dm.destroy",
+	Incr:                "dm.incr",
+	Decr:                "dm.decr",
+	GetPut:              "dm.getput",
+	IncrByFloat:         "dm.incrbyfloat",
+	Lock:                "dm.lock",
+	Unlock:              "dm.unlock",
+	LockLease:           "dm.locklease",
+	PLockLease:          "dm.plocklease",
+	LockLeasePut:        "dm.lockleaseput",
+	Scan:                "dm.scan",
+	SetNXGet:            "dm.setnxget",
+	DecrAndDeleteAtZero: "dm.decranddeleteatzero",
+	Eval:                "dm.eval",
+	PutAllIfNoneExist:   "dm.putallifnoneexist",
+	EntryAgeRange:       "dm.entryagerange",
+	MemoryUsage:         "dm.memoryusage",
+	ExpiringSoon:        "dm.expiringsoon",
+	ValueHashes:         "dm.valuehashes",
+	Diff:                "dm.diff",
+	ClaimNext:           "dm.claimnext",
+	SyncReplica:         "dm.syncreplica",
+	GetTTLMany:          "dm.getttlmany",
+	Rotate:              "dm.rotate",
+	DelIfExpiredBefore:  "dm.delifexpiredbefore",
+	HotKeys:             "dm.hotkeys",
+	HIncrBy:             "dm.hincrby",
+	HMSet:               "dm.hmset",
+	HMGet:               "dm.hmget",
+	AcquirePermit:       "dm.acquirepermit",
+	ReleasePermit:       "dm.releasepermit",
+	PutWithVersion:      "dm.putwithversion",
+	LPushCapped:         "dm.lpushcapped",
+	LRange:              "dm.lrange",
+	CapTTL:              "dm.capttl",
+	SetBit:              "dm.setbit",
+	GetBit:              "dm.getbit",
+	GetAndReset:         "dm.getandreset",
+	PutIfField:          "dm.putiffield",
+	Merge:               "dm.merge",
+	SetStandby:          "dm.setstandby",
+	PromoteStandby:      "dm.promotestandby",
+	GetByTag:            "dm.getbytag",
+	GetDel:              "dm.getdel",
+	Register:            "dm.register",
+	Heartbeat:           "dm.heartbeat",
+	Discover:            "dm.discover",
+	Append:              "dm.append",
+	Persist:             "dm.persist",
+	TTL:                 "dm.ttl",
+	PTTL:                "dm.pttl",
+	Campaign:            "dm.campaign",
+	Resign:              "dm.resign",
+	GetMany:             "dm.getmany",
+	PutMany:             "dm.putmany",
+	Exists:              "dm.exists",
+	ReserveSequence:     "dm.reserveseq",
+	Changes:             "dm.changes",
 }
 
 type PubSubCommands struct {
@@ -16,8 +16,10 @@ package protocol
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -212,6 +214,56 @@ func ParsePutEntryCommand(cmd redcon.Command) (*PutEntry, error) {
 	), nil
 }
 
+// UpdateEntryTTL updates the TTL and the timestamp of an entry on a replica without
+// rewriting its value. It's used by read-repair.
+type UpdateEntryTTL struct {
+	DMap      string
+	Key       string
+	TTL       int64
+	Timestamp int64
+}
+
+func NewUpdateEntryTTL(dmap, key string, ttl, timestamp int64) *UpdateEntryTTL {
+	return &UpdateEntryTTL{
+		DMap:      dmap,
+		Key:       key,
+		TTL:       ttl,
+		Timestamp: timestamp,
+	}
+}
+
+func (u *UpdateEntryTTL) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.UpdateEntryTTL)
+	args = append(args, u.DMap)
+	args = append(args, u.Key)
+	args = append(args, u.TTL)
+	args = append(args, u.Timestamp)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseUpdateEntryTTLCommand(cmd redcon.Command) (*UpdateEntryTTL, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	ttl, err := strconv.ParseInt(util.BytesToString(cmd.Args[3]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := strconv.ParseInt(util.BytesToString(cmd.Args[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewUpdateEntryTTL(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		ttl,
+		timestamp,
+	), nil
+}
+
 type Get struct {
 	DMap string
 	Key  string
@@ -267,6 +319,9 @@ type GetEntry struct {
 	DMap    string
 	Key     string
 	Replica bool
+	// Stored returns the entry as it's stored, without decoding the value. It's used
+	// by the members to compare and repair the copies of an entry.
+	Stored bool
 }
 
 func NewGetEntry(dmap, key string) *GetEntry {
@@ -281,6 +336,11 @@ func (g *GetEntry) SetReplica() *GetEntry {
 	return g
 }
 
+func (g *GetEntry) SetStored() *GetEntry {
+	g.Stored = true
+	return g
+}
+
 func (g *GetEntry) Command(ctx context.Context) *redis.StringCmd {
 	var args []interface{}
 	args = append(args, DMap.GetEntry)
@@ -289,6 +349,9 @@ func (g *GetEntry) Command(ctx context.Context) *redis.StringCmd {
 	if g.Replica {
 		args = append(args, "RC")
 	}
+	if g.Stored {
+		args = append(args, "ST")
+	}
 	return redis.NewStringCmd(ctx, args...)
 }
 
@@ -302,11 +365,14 @@ func ParseGetEntryCommand(cmd redcon.Command) (*GetEntry, error) {
 		util.BytesToString(cmd.Args[2]), // Key
 	)
 
-	if len(cmd.Args) == 4 {
-		arg := util.BytesToString(cmd.Args[3])
-		if arg == "RC" {
+	for _, raw := range cmd.Args[3:] {
+		arg := util.BytesToString(raw)
+		switch arg {
+		case "RC":
 			g.SetReplica()
-		} else {
+		case "ST":
+			g.SetStored()
+		default:
 			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
 		}
 	}
@@ -480,6 +546,102 @@ func ParseExpireCommand(cmd redcon.Command) (*Expire, error) {
 	return e, nil
 }
 
+// Persist removes the expiry of the key.
+type Persist struct {
+	DMap string
+	Key  string
+}
+
+func NewPersist(dmap, key string) *Persist {
+	return &Persist{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (p *Persist) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Persist)
+	args = append(args, p.DMap)
+	args = append(args, p.Key)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParsePersistCommand(cmd redcon.Command) (*Persist, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewPersist(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
+// TTL returns the remaining TTL of the key in seconds.
+type TTL struct {
+	DMap string
+	Key  string
+}
+
+func NewTTL(dmap, key string) *TTL {
+	return &TTL{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (t *TTL) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.TTL)
+	args = append(args, t.DMap)
+	args = append(args, t.Key)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseTTLCommand(cmd redcon.Command) (*TTL, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewTTL(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
+// PTTL returns the remaining TTL of the key in milliseconds.
+type PTTL struct {
+	DMap string
+	Key  string
+}
+
+func NewPTTL(dmap, key string) *PTTL {
+	return &PTTL{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (p *PTTL) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.PTTL)
+	args = append(args, p.DMap)
+	args = append(args, p.Key)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParsePTTLCommand(cmd redcon.Command) (*PTTL, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewPTTL(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
 type Destroy struct {
 	DMap  string
 	Local bool
@@ -800,11 +962,12 @@ func ParseIncrByFloatCommand(cmd redcon.Command) (*IncrByFloat, error) {
 }
 
 type Lock struct {
-	DMap     string
-	Key      string
-	Deadline float64
-	EX       float64
-	PX       int64
+	DMap         string
+	Key          string
+	Deadline     float64
+	EX           float64
+	PX           int64
+	PollInterval int64
 }
 
 func NewLock(dmap, key string, deadline float64) *Lock {
@@ -825,6 +988,12 @@ func (l *Lock) SetPX(px int64) *Lock {
 	return l
 }
 
+// SetPollInterval sets the interval between two attempts to acquire the lock, in milliseconds.
+func (l *Lock) SetPollInterval(pollInterval int64) *Lock {
+	l.PollInterval = pollInterval
+	return l
+}
+
 func (l *Lock) Command(ctx context.Context) *redis.StringCmd {
 	var args []interface{}
 	args = append(args, DMap.Lock)
@@ -843,6 +1012,11 @@ func (l *Lock) Command(ctx context.Context) *redis.StringCmd {
 		args = append(args, l.PX)
 	}
 
+	if l.PollInterval != 0 {
+		args = append(args, "POLL")
+		args = append(args, l.PollInterval)
+	}
+
 	return redis.NewStringCmd(ctx, args...)
 }
 
@@ -862,33 +1036,63 @@ func ParseLockCommand(cmd redcon.Command) (*Lock, error) {
 		deadline,                        // Deadline
 	)
 
-	// EX or PX are optional.
-	if len(cmd.Args) > 4 {
-		if len(cmd.Args) == 5 {
-			return nil, fmt.Errorf("%w: %s needs a numerical argument", ErrInvalidArgument, util.BytesToString(cmd.Args[5]))
+	// EX, PX and POLL are optional.
+	args := cmd.Args[4:]
+	for len(args) > 0 {
+		arg := strings.ToUpper(util.BytesToString(args[0]))
+		if len(args) == 1 {
+			return nil, fmt.Errorf("%w: %s needs a numerical argument", ErrInvalidArgument, arg)
 		}
 
-		switch arg := strings.ToUpper(util.BytesToString(cmd.Args[4])); arg {
+		switch arg {
 		case "PX":
-			px, err := strconv.ParseInt(util.BytesToString(cmd.Args[5]), 10, 64)
+			px, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
 			if err != nil {
 				return nil, err
 			}
 			l.PX = px
 		case "EX":
-			ex, err := strconv.ParseFloat(util.BytesToString(cmd.Args[5]), 64)
+			ex, err := strconv.ParseFloat(util.BytesToString(args[1]), 64)
 			if err != nil {
 				return nil, err
 			}
 			l.EX = ex
+		case "POLL":
+			pollInterval, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			l.PollInterval = pollInterval
 		default:
 			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
 		}
+		args = args[2:]
 	}
 
 	return l, nil
 }
 
+// FormatLockResponse returns the response of the Lock command: the hex encoded
+// token and the fencing token, separated by a colon.
+func FormatLockResponse(token []byte, fencingToken uint64) string {
+	return hex.EncodeToString(token) + ":" + strconv.FormatUint(fencingToken, 10)
+}
+
+// ParseLockResponse parses the response of the Lock command and returns the hex
+// encoded token and the fencing token. The fencing token is zero if the response
+// doesn't have it.
+func ParseLockResponse(resp string) (string, uint64, error) {
+	idx := strings.IndexByte(resp, ':')
+	if idx == -1 {
+		return resp, 0, nil
+	}
+	fencingToken, err := strconv.ParseUint(resp[idx+1:], 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid fencing token: %w", err)
+	}
+	return resp[:idx], fencingToken, nil
+}
+
 type Unlock struct {
 	DMap  string
 	Key   string
@@ -1011,3 +1215,2216 @@ func ParsePLockLeaseCommand(cmd redcon.Command) (*PLockLease, error) {
 		timeout,                         // Timeout
 	), nil
 }
+
+// LockLeasePut updates the timeout of a lock in milliseconds and writes its protected value.
+type LockLeasePut struct {
+	DMap    string
+	Key     string
+	Token   string
+	Timeout int64
+	Value   []byte
+}
+
+func NewLockLeasePut(dmap, key, token string, timeout int64, value []byte) *LockLeasePut {
+	return &LockLeasePut{
+		DMap:    dmap,
+		Key:     key,
+		Token:   token,
+		Timeout: timeout,
+		Value:   value,
+	}
+}
+
+func (l *LockLeasePut) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.LockLeasePut)
+	args = append(args, l.DMap)
+	args = append(args, l.Key)
+	args = append(args, l.Token)
+	args = append(args, l.Timeout)
+	args = append(args, l.Value)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseLockLeasePutCommand(cmd redcon.Command) (*LockLeasePut, error) {
+	if len(cmd.Args) != 6 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	timeout, err := strconv.ParseInt(util.BytesToString(cmd.Args[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLockLeasePut(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		util.BytesToString(cmd.Args[3]), // Token
+		timeout,                         // Timeout
+		cmd.Args[5],                     // Value
+	), nil
+}
+
+type SetNXGet struct {
+	DMap  string
+	Key   string
+	Value []byte
+	PX    int64
+	Raw   bool
+}
+
+func NewSetNXGet(dmap, key string, value []byte, px int64) *SetNXGet {
+	return &SetNXGet{
+		DMap:  dmap,
+		Key:   key,
+		Value: value,
+		PX:    px,
+	}
+}
+
+func (s *SetNXGet) SetRaw() *SetNXGet {
+	s.Raw = true
+	return s
+}
+
+func (s *SetNXGet) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.SetNXGet)
+	args = append(args, s.DMap)
+	args = append(args, s.Key)
+	args = append(args, s.Value)
+	args = append(args, s.PX)
+	if s.Raw {
+		args = append(args, "RW")
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseSetNXGetCommand(cmd redcon.Command) (*SetNXGet, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	px, err := strconv.ParseInt(util.BytesToString(cmd.Args[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	s := NewSetNXGet(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		cmd.Args[3],                     // Value
+		px,                              // PX
+	)
+
+	if len(cmd.Args) == 6 {
+		arg := util.BytesToString(cmd.Args[5])
+		if arg == "RW" {
+			s.SetRaw()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+	return s, nil
+}
+
+type DecrAndDeleteAtZero struct {
+	DMap  string
+	Key   string
+	Delta int
+}
+
+func NewDecrAndDeleteAtZero(dmap, key string, delta int) *DecrAndDeleteAtZero {
+	return &DecrAndDeleteAtZero{
+		DMap:  dmap,
+		Key:   key,
+		Delta: delta,
+	}
+}
+
+func (d *DecrAndDeleteAtZero) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.DecrAndDeleteAtZero)
+	args = append(args, d.DMap)
+	args = append(args, d.Key)
+	args = append(args, d.Delta)
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseDecrAndDeleteAtZeroCommand(cmd redcon.Command) (*DecrAndDeleteAtZero, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	delta, err := strconv.Atoi(util.BytesToString(cmd.Args[3]))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDecrAndDeleteAtZero(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		delta,                           // Delta
+	), nil
+}
+
+type Eval struct {
+	DMap   string
+	Script string
+	Keys   []string
+	Args   []string
+}
+
+func NewEval(dmap, script string, keys []string, args ...string) *Eval {
+	return &Eval{
+		DMap:   dmap,
+		Script: script,
+		Keys:   keys,
+		Args:   args,
+	}
+}
+
+func (e *Eval) Command(ctx context.Context) *redis.Cmd {
+	var args []interface{}
+	args = append(args, DMap.Eval)
+	args = append(args, e.DMap)
+	args = append(args, e.Script)
+	args = append(args, len(e.Keys))
+	for _, key := range e.Keys {
+		args = append(args, key)
+	}
+	for _, arg := range e.Args {
+		args = append(args, arg)
+	}
+	return redis.NewCmd(ctx, args...)
+}
+
+func ParseEvalCommand(cmd redcon.Command) (*Eval, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	numKeys, err := strconv.Atoi(util.BytesToString(cmd.Args[3]))
+	if err != nil {
+		return nil, err
+	}
+	if numKeys < 0 || len(cmd.Args) < 4+numKeys {
+		return nil, fmt.Errorf("%w: numkeys: %d", ErrInvalidArgument, numKeys)
+	}
+
+	e := NewEval(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Script
+		nil,
+	)
+	for _, key := range cmd.Args[4 : 4+numKeys] {
+		e.Keys = append(e.Keys, util.BytesToString(key))
+	}
+	for _, arg := range cmd.Args[4+numKeys:] {
+		e.Args = append(e.Args, util.BytesToString(arg))
+	}
+	return e, nil
+}
+
+type PutAllIfNoneExist struct {
+	DMap  string
+	Pairs map[string][]byte
+}
+
+func NewPutAllIfNoneExist(dmap string, pairs map[string][]byte) *PutAllIfNoneExist {
+	return &PutAllIfNoneExist{
+		DMap:  dmap,
+		Pairs: pairs,
+	}
+}
+
+func (p *PutAllIfNoneExist) Command(ctx context.Context) *redis.IntCmd {
+	keys := make([]string, 0, len(p.Pairs))
+	for key := range p.Pairs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var args []interface{}
+	args = append(args, DMap.PutAllIfNoneExist)
+	args = append(args, p.DMap)
+	for _, key := range keys {
+		args = append(args, key, p.Pairs[key])
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParsePutAllIfNoneExistCommand(cmd redcon.Command) (*PutAllIfNoneExist, error) {
+	if len(cmd.Args) < 4 || len(cmd.Args)%2 != 0 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	p := NewPutAllIfNoneExist(
+		util.BytesToString(cmd.Args[1]), // DMap
+		make(map[string][]byte),
+	)
+	for i := 2; i < len(cmd.Args); i += 2 {
+		p.Pairs[util.BytesToString(cmd.Args[i])] = cmd.Args[i+1]
+	}
+	return p, nil
+}
+
+type EntryAgeRange struct {
+	DMap  string
+	Local bool
+}
+
+func NewEntryAgeRange(dmap string) *EntryAgeRange {
+	return &EntryAgeRange{
+		DMap: dmap,
+	}
+}
+
+func (e *EntryAgeRange) SetLocal() *EntryAgeRange {
+	e.Local = true
+	return e
+}
+
+func (e *EntryAgeRange) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.EntryAgeRange)
+	args = append(args, e.DMap)
+	if e.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseEntryAgeRangeCommand(cmd redcon.Command) (*EntryAgeRange, error) {
+	if len(cmd.Args) < 2 || len(cmd.Args) > 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	e := NewEntryAgeRange(
+		util.BytesToString(cmd.Args[1]), // DMap
+	)
+
+	if len(cmd.Args) == 3 {
+		arg := util.BytesToString(cmd.Args[2])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		e.SetLocal()
+	}
+	return e, nil
+}
+
+type MemoryUsage struct {
+	DMap string
+	Key  string
+}
+
+func NewMemoryUsage(dmap, key string) *MemoryUsage {
+	return &MemoryUsage{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (m *MemoryUsage) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.MemoryUsage)
+	args = append(args, m.DMap)
+	args = append(args, m.Key)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseMemoryUsageCommand(cmd redcon.Command) (*MemoryUsage, error) {
+	if len(cmd.Args) != 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewMemoryUsage(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
+type ExpiringSoon struct {
+	DMap   string
+	Within int64
+	Limit  int64
+	Local  bool
+}
+
+// NewExpiringSoon creates a new ExpiringSoon command. within is in milliseconds.
+func NewExpiringSoon(dmap string, within, limit int64) *ExpiringSoon {
+	return &ExpiringSoon{
+		DMap:   dmap,
+		Within: within,
+		Limit:  limit,
+	}
+}
+
+func (e *ExpiringSoon) SetLocal() *ExpiringSoon {
+	e.Local = true
+	return e
+}
+
+func (e *ExpiringSoon) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.ExpiringSoon)
+	args = append(args, e.DMap)
+	args = append(args, e.Within)
+	args = append(args, e.Limit)
+	if e.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseExpiringSoonCommand(cmd redcon.Command) (*ExpiringSoon, error) {
+	if len(cmd.Args) < 4 || len(cmd.Args) > 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	within, err := strconv.ParseInt(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := strconv.ParseInt(util.BytesToString(cmd.Args[3]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	e := NewExpiringSoon(
+		util.BytesToString(cmd.Args[1]), // DMap
+		within,                          // Within
+		limit,                           // Limit
+	)
+
+	if len(cmd.Args) == 5 {
+		arg := util.BytesToString(cmd.Args[4])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		e.SetLocal()
+	}
+	return e, nil
+}
+
+// ValueHashes returns the hashes of the values in the primary copies of a DMap on
+// the receiving member.
+type ValueHashes struct {
+	DMap string
+}
+
+func NewValueHashes(dmap string) *ValueHashes {
+	return &ValueHashes{
+		DMap: dmap,
+	}
+}
+
+func (v *ValueHashes) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.ValueHashes)
+	args = append(args, v.DMap)
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseValueHashesCommand(cmd redcon.Command) (*ValueHashes, error) {
+	if len(cmd.Args) != 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewValueHashes(
+		util.BytesToString(cmd.Args[1]), // DMap
+	), nil
+}
+
+type Diff struct {
+	DMapA string
+	DMapB string
+}
+
+func NewDiff(dmapA, dmapB string) *Diff {
+	return &Diff{
+		DMapA: dmapA,
+		DMapB: dmapB,
+	}
+}
+
+func (d *Diff) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.Diff)
+	args = append(args, d.DMapA)
+	args = append(args, d.DMapB)
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseDiffCommand(cmd redcon.Command) (*Diff, error) {
+	if len(cmd.Args) != 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewDiff(
+		util.BytesToString(cmd.Args[1]), // DMapA
+		util.BytesToString(cmd.Args[2]), // DMapB
+	), nil
+}
+
+type ClaimNext struct {
+	DMap     string
+	ClaimTTL int64
+	Local    bool
+}
+
+// NewClaimNext creates a new ClaimNext command. claimTTL is in milliseconds.
+func NewClaimNext(dmap string, claimTTL int64) *ClaimNext {
+	return &ClaimNext{
+		DMap:     dmap,
+		ClaimTTL: claimTTL,
+	}
+}
+
+func (c *ClaimNext) SetLocal() *ClaimNext {
+	c.Local = true
+	return c
+}
+
+func (c *ClaimNext) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.ClaimNext)
+	args = append(args, c.DMap)
+	args = append(args, c.ClaimTTL)
+	if c.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseClaimNextCommand(cmd redcon.Command) (*ClaimNext, error) {
+	if len(cmd.Args) < 3 || len(cmd.Args) > 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	claimTTL, err := strconv.ParseInt(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if claimTTL <= 0 {
+		return nil, fmt.Errorf("%w: non-positive claim TTL: %d", ErrInvalidArgument, claimTTL)
+	}
+
+	c := NewClaimNext(
+		util.BytesToString(cmd.Args[1]), // DMap
+		claimTTL,                        // ClaimTTL
+	)
+
+	if len(cmd.Args) == 4 {
+		arg := util.BytesToString(cmd.Args[3])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		c.SetLocal()
+	}
+	return c, nil
+}
+
+// SyncReplica replaces the replica of a DMap fragment on Member with a copy of the
+// primary fragment.
+type SyncReplica struct {
+	DMap   string
+	PartID uint64
+	Member string
+}
+
+func NewSyncReplica(dmap string, partID uint64, member string) *SyncReplica {
+	return &SyncReplica{
+		DMap:   dmap,
+		PartID: partID,
+		Member: member,
+	}
+}
+
+func (s *SyncReplica) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.SyncReplica)
+	args = append(args, s.DMap)
+	args = append(args, s.PartID)
+	args = append(args, s.Member)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseSyncReplicaCommand(cmd redcon.Command) (*SyncReplica, error) {
+	if len(cmd.Args) != 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	partID, err := strconv.ParseUint(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSyncReplica(
+		util.BytesToString(cmd.Args[1]), // DMap
+		partID,                          // PartID
+		util.BytesToString(cmd.Args[3]), // Member
+	), nil
+}
+
+// GetTTLMany returns the remaining TTLs of the keys in milliseconds.
+type GetTTLMany struct {
+	DMap  string
+	Keys  []string
+	Local bool
+}
+
+func NewGetTTLMany(dmap string, keys []string) *GetTTLMany {
+	return &GetTTLMany{
+		DMap: dmap,
+		Keys: keys,
+	}
+}
+
+func (g *GetTTLMany) SetLocal() *GetTTLMany {
+	g.Local = true
+	return g
+}
+
+func (g *GetTTLMany) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.GetTTLMany)
+	args = append(args, g.DMap)
+	args = append(args, len(g.Keys))
+	for _, key := range g.Keys {
+		args = append(args, key)
+	}
+	if g.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseGetTTLManyCommand(cmd redcon.Command) (*GetTTLMany, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	numKeys, err := strconv.Atoi(util.BytesToString(cmd.Args[2]))
+	if err != nil {
+		return nil, err
+	}
+	if numKeys < 0 || len(cmd.Args) < 3+numKeys || len(cmd.Args) > 4+numKeys {
+		return nil, fmt.Errorf("%w: numkeys: %d", ErrInvalidArgument, numKeys)
+	}
+
+	g := NewGetTTLMany(util.BytesToString(cmd.Args[1]), nil)
+	for _, key := range cmd.Args[3 : 3+numKeys] {
+		g.Keys = append(g.Keys, util.BytesToString(key))
+	}
+
+	if len(cmd.Args) == 4+numKeys {
+		arg := util.BytesToString(cmd.Args[3+numKeys])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		g.SetLocal()
+	}
+	return g, nil
+}
+
+// GetMany returns the values of the keys.
+type GetMany struct {
+	DMap  string
+	Keys  []string
+	Local bool
+}
+
+func NewGetMany(dmap string, keys []string) *GetMany {
+	return &GetMany{
+		DMap: dmap,
+		Keys: keys,
+	}
+}
+
+func (g *GetMany) SetLocal() *GetMany {
+	g.Local = true
+	return g
+}
+
+func (g *GetMany) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.GetMany)
+	args = append(args, g.DMap)
+	args = append(args, len(g.Keys))
+	for _, key := range g.Keys {
+		args = append(args, key)
+	}
+	if g.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseGetManyCommand(cmd redcon.Command) (*GetMany, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	numKeys, err := strconv.Atoi(util.BytesToString(cmd.Args[2]))
+	if err != nil {
+		return nil, err
+	}
+	if numKeys < 0 || len(cmd.Args) < 3+numKeys || len(cmd.Args) > 4+numKeys {
+		return nil, fmt.Errorf("%w: numkeys: %d", ErrInvalidArgument, numKeys)
+	}
+
+	g := NewGetMany(util.BytesToString(cmd.Args[1]), nil)
+	for _, key := range cmd.Args[3 : 3+numKeys] {
+		g.Keys = append(g.Keys, util.BytesToString(key))
+	}
+
+	if len(cmd.Args) == 4+numKeys {
+		arg := util.BytesToString(cmd.Args[3+numKeys])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		g.SetLocal()
+	}
+	return g, nil
+}
+
+// Exists returns the number of the keys that exist.
+type Exists struct {
+	DMap  string
+	Keys  []string
+	Local bool
+}
+
+func NewExists(dmap string, keys []string) *Exists {
+	return &Exists{
+		DMap: dmap,
+		Keys: keys,
+	}
+}
+
+func (e *Exists) SetLocal() *Exists {
+	e.Local = true
+	return e
+}
+
+func (e *Exists) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.Exists)
+	args = append(args, e.DMap)
+	args = append(args, len(e.Keys))
+	for _, key := range e.Keys {
+		args = append(args, key)
+	}
+	if e.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseExistsCommand(cmd redcon.Command) (*Exists, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	numKeys, err := strconv.Atoi(util.BytesToString(cmd.Args[2]))
+	if err != nil {
+		return nil, err
+	}
+	if numKeys < 0 || len(cmd.Args) < 3+numKeys || len(cmd.Args) > 4+numKeys {
+		return nil, fmt.Errorf("%w: numkeys: %d", ErrInvalidArgument, numKeys)
+	}
+
+	e := NewExists(util.BytesToString(cmd.Args[1]), nil)
+	for _, key := range cmd.Args[3 : 3+numKeys] {
+		e.Keys = append(e.Keys, util.BytesToString(key))
+	}
+
+	if len(cmd.Args) == 4+numKeys {
+		arg := util.BytesToString(cmd.Args[3+numKeys])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		e.SetLocal()
+	}
+	return e, nil
+}
+
+// ReserveSequence reserves a block of numbers of a sequence.
+type ReserveSequence struct {
+	DMap string
+	Name string
+	N    int
+}
+
+func NewReserveSequence(dmap, name string, n int) *ReserveSequence {
+	return &ReserveSequence{
+		DMap: dmap,
+		Name: name,
+		N:    n,
+	}
+}
+
+func (r *ReserveSequence) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.ReserveSequence)
+	args = append(args, r.DMap)
+	args = append(args, r.Name)
+	args = append(args, r.N)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseReserveSequenceCommand(cmd redcon.Command) (*ReserveSequence, error) {
+	if len(cmd.Args) != 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	n, err := strconv.Atoi(util.BytesToString(cmd.Args[3]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, err)
+	}
+
+	return NewReserveSequence(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Name
+		n,
+	), nil
+}
+
+// PutMany sets the values of the keys with the same options.
+type PutMany struct {
+	DMap   string
+	Keys   []string
+	Values [][]byte
+	EX     float64
+	PX     int64
+	EXAT   float64
+	PXAT   int64
+	NX     bool
+	XX     bool
+	Local  bool
+}
+
+func NewPutMany(dmap string) *PutMany {
+	return &PutMany{
+		DMap: dmap,
+	}
+}
+
+func (p *PutMany) Add(key string, value []byte) *PutMany {
+	p.Keys = append(p.Keys, key)
+	p.Values = append(p.Values, value)
+	return p
+}
+
+func (p *PutMany) SetEX(ex float64) *PutMany {
+	p.EX = ex
+	return p
+}
+
+func (p *PutMany) SetPX(px int64) *PutMany {
+	p.PX = px
+	return p
+}
+
+func (p *PutMany) SetEXAT(exat float64) *PutMany {
+	p.EXAT = exat
+	return p
+}
+
+func (p *PutMany) SetPXAT(pxat int64) *PutMany {
+	p.PXAT = pxat
+	return p
+}
+
+func (p *PutMany) SetNX() *PutMany {
+	p.NX = true
+	return p
+}
+
+func (p *PutMany) SetXX() *PutMany {
+	p.XX = true
+	return p
+}
+
+func (p *PutMany) SetLocal() *PutMany {
+	p.Local = true
+	return p
+}
+
+func (p *PutMany) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.PutMany)
+	args = append(args, p.DMap)
+	args = append(args, len(p.Keys))
+	for i, key := range p.Keys {
+		args = append(args, key)
+		args = append(args, p.Values[i])
+	}
+
+	if p.EX != 0 {
+		args = append(args, "EX")
+		args = append(args, p.EX)
+	}
+
+	if p.PX != 0 {
+		args = append(args, "PX")
+		args = append(args, p.PX)
+	}
+
+	if p.EXAT != 0 {
+		args = append(args, "EXAT")
+		args = append(args, p.EXAT)
+	}
+
+	if p.PXAT != 0 {
+		args = append(args, "PXAT")
+		args = append(args, p.PXAT)
+	}
+
+	if p.NX {
+		args = append(args, "NX")
+	}
+
+	if p.XX {
+		args = append(args, "XX")
+	}
+
+	if p.Local {
+		args = append(args, "LC")
+	}
+
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParsePutManyCommand(cmd redcon.Command) (*PutMany, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	numPairs, err := strconv.Atoi(util.BytesToString(cmd.Args[2]))
+	if err != nil {
+		return nil, err
+	}
+	if numPairs < 0 || len(cmd.Args) < 3+2*numPairs {
+		return nil, fmt.Errorf("%w: numpairs: %d", ErrInvalidArgument, numPairs)
+	}
+
+	p := NewPutMany(util.BytesToString(cmd.Args[1]))
+	pairs := cmd.Args[3 : 3+2*numPairs]
+	for i := 0; i < len(pairs); i += 2 {
+		p.Add(util.BytesToString(pairs[i]), pairs[i+1])
+	}
+
+	args := cmd.Args[3+2*numPairs:]
+	for len(args) > 0 {
+		arg := strings.ToUpper(util.BytesToString(args[0]))
+		switch arg {
+		case "NX":
+			p.SetNX()
+			args = args[1:]
+			continue
+		case "XX":
+			p.SetXX()
+			args = args[1:]
+			continue
+		case "LC":
+			p.SetLocal()
+			args = args[1:]
+			continue
+		}
+
+		if len(args) < 2 {
+			return nil, errors.New("syntax error")
+		}
+		switch arg {
+		case "PX":
+			px, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetPX(px)
+		case "EX":
+			ex, err := strconv.ParseFloat(util.BytesToString(args[1]), 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetEX(ex)
+		case "EXAT":
+			exat, err := strconv.ParseFloat(util.BytesToString(args[1]), 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetEXAT(exat)
+		case "PXAT":
+			pxat, err := strconv.ParseInt(util.BytesToString(args[1]), 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			p.SetPXAT(pxat)
+		default:
+			return nil, errors.New("syntax error")
+		}
+		args = args[2:]
+	}
+
+	return p, nil
+}
+
+// Rotate sets the key to value and copies the old value to a derived key with a TTL.
+// GraceTTL is in milliseconds.
+type Rotate struct {
+	DMap     string
+	Key      string
+	Value    []byte
+	GraceTTL int64
+}
+
+func NewRotate(dmap, key string, value []byte, graceTTL int64) *Rotate {
+	return &Rotate{
+		DMap:     dmap,
+		Key:      key,
+		Value:    value,
+		GraceTTL: graceTTL,
+	}
+}
+
+func (r *Rotate) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.Rotate)
+	args = append(args, r.DMap)
+	args = append(args, r.Key)
+	args = append(args, r.Value)
+	args = append(args, r.GraceTTL)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseRotateCommand(cmd redcon.Command) (*Rotate, error) {
+	if len(cmd.Args) != 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	graceTTL, err := strconv.ParseInt(util.BytesToString(cmd.Args[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if graceTTL <= 0 {
+		return nil, fmt.Errorf("%w: non-positive grace TTL: %d", ErrInvalidArgument, graceTTL)
+	}
+
+	return NewRotate(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		cmd.Args[3],                     // Value
+		graceTTL,                        // GraceTTL
+	), nil
+}
+
+// DelIfExpiredBefore deletes the key if its expiry time is at or before the given timestamp.
+// The timestamp is in milliseconds since the Unix epoch.
+type DelIfExpiredBefore struct {
+	DMap      string
+	Key       string
+	Timestamp int64
+}
+
+func NewDelIfExpiredBefore(dmap, key string, timestamp int64) *DelIfExpiredBefore {
+	return &DelIfExpiredBefore{
+		DMap:      dmap,
+		Key:       key,
+		Timestamp: timestamp,
+	}
+}
+
+func (d *DelIfExpiredBefore) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.DelIfExpiredBefore)
+	args = append(args, d.DMap)
+	args = append(args, d.Key)
+	args = append(args, d.Timestamp)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseDelIfExpiredBeforeCommand(cmd redcon.Command) (*DelIfExpiredBefore, error) {
+	if len(cmd.Args) != 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	timestamp, err := strconv.ParseInt(util.BytesToString(cmd.Args[3]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDelIfExpiredBefore(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		timestamp,                       // Timestamp
+	), nil
+}
+
+// HotKeys returns the most accessed keys of a DMap with their approximate access counts.
+type HotKeys struct {
+	DMap  string
+	Count int64
+	Local bool
+}
+
+func NewHotKeys(dmap string, count int64) *HotKeys {
+	return &HotKeys{
+		DMap:  dmap,
+		Count: count,
+	}
+}
+
+func (h *HotKeys) SetLocal() *HotKeys {
+	h.Local = true
+	return h
+}
+
+func (h *HotKeys) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.HotKeys)
+	args = append(args, h.DMap)
+	args = append(args, h.Count)
+	if h.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseHotKeysCommand(cmd redcon.Command) (*HotKeys, error) {
+	if len(cmd.Args) < 3 || len(cmd.Args) > 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	count, err := strconv.ParseInt(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		return nil, fmt.Errorf("%w: non-positive count: %d", ErrInvalidArgument, count)
+	}
+
+	h := NewHotKeys(
+		util.BytesToString(cmd.Args[1]), // DMap
+		count,                           // Count
+	)
+
+	if len(cmd.Args) == 4 {
+		arg := util.BytesToString(cmd.Args[3])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		h.SetLocal()
+	}
+	return h, nil
+}
+
+// HIncrBy increments a field of the hash stored at the key.
+type HIncrBy struct {
+	DMap  string
+	Key   string
+	Field string
+	Delta int
+}
+
+func NewHIncrBy(dmap, key, field string, delta int) *HIncrBy {
+	return &HIncrBy{
+		DMap:  dmap,
+		Key:   key,
+		Field: field,
+		Delta: delta,
+	}
+}
+
+func (h *HIncrBy) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.HIncrBy)
+	args = append(args, h.DMap)
+	args = append(args, h.Key)
+	args = append(args, h.Field)
+	args = append(args, h.Delta)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseHIncrByCommand(cmd redcon.Command) (*HIncrBy, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	delta, err := strconv.Atoi(util.BytesToString(cmd.Args[4]))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHIncrBy(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		util.BytesToString(cmd.Args[3]), // Field
+		delta,
+	), nil
+}
+
+// HMSet sets multiple fields of the hash stored at the key.
+type HMSet struct {
+	DMap   string
+	Key    string
+	Fields map[string][]byte
+}
+
+func NewHMSet(dmap, key string, fields map[string][]byte) *HMSet {
+	return &HMSet{
+		DMap:   dmap,
+		Key:    key,
+		Fields: fields,
+	}
+}
+
+func (h *HMSet) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.HMSet)
+	args = append(args, h.DMap)
+	args = append(args, h.Key)
+	for field, value := range h.Fields {
+		args = append(args, field)
+		args = append(args, value)
+	}
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseHMSetCommand(cmd redcon.Command) (*HMSet, error) {
+	if len(cmd.Args) < 5 || len(cmd.Args)%2 != 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	fields := make(map[string][]byte)
+	for i := 3; i < len(cmd.Args); i += 2 {
+		fields[string(cmd.Args[i])] = cmd.Args[i+1]
+	}
+
+	return NewHMSet(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		fields,
+	), nil
+}
+
+// HMGet returns multiple fields of the hash stored at the key.
+type HMGet struct {
+	DMap   string
+	Key    string
+	Fields []string
+}
+
+func NewHMGet(dmap, key string, fields ...string) *HMGet {
+	return &HMGet{
+		DMap:   dmap,
+		Key:    key,
+		Fields: fields,
+	}
+}
+
+func (h *HMGet) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.HMGet)
+	args = append(args, h.DMap)
+	args = append(args, h.Key)
+	for _, field := range h.Fields {
+		args = append(args, field)
+	}
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseHMGetCommand(cmd redcon.Command) (*HMGet, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	var fields []string
+	for _, field := range cmd.Args[3:] {
+		fields = append(fields, string(field))
+	}
+
+	return NewHMGet(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		fields...,
+	), nil
+}
+
+// AcquirePermit takes a permit from the semaphore stored at the key if there are
+// less than Max permits held.
+type AcquirePermit struct {
+	DMap  string
+	Key   string
+	Token string
+	Max   int
+	TTL   int64
+}
+
+func NewAcquirePermit(dmap, key, token string, max int, ttl int64) *AcquirePermit {
+	return &AcquirePermit{
+		DMap:  dmap,
+		Key:   key,
+		Token: token,
+		Max:   max,
+		TTL:   ttl,
+	}
+}
+
+func (a *AcquirePermit) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.AcquirePermit)
+	args = append(args, a.DMap)
+	args = append(args, a.Key)
+	args = append(args, a.Token)
+	args = append(args, a.Max)
+	args = append(args, a.TTL)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseAcquirePermitCommand(cmd redcon.Command) (*AcquirePermit, error) {
+	if len(cmd.Args) < 6 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	max, err := strconv.Atoi(util.BytesToString(cmd.Args[4]))
+	if err != nil {
+		return nil, err
+	}
+	if max <= 0 {
+		return nil, fmt.Errorf("%w: non-positive max: %d", ErrInvalidArgument, max)
+	}
+
+	ttl, err := strconv.ParseInt(util.BytesToString(cmd.Args[5]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: non-positive TTL: %d", ErrInvalidArgument, ttl)
+	}
+
+	return NewAcquirePermit(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		util.BytesToString(cmd.Args[3]), // Token
+		max,
+		ttl,
+	), nil
+}
+
+// ReleasePermit returns the permit with the given token to the semaphore stored at the key.
+type ReleasePermit struct {
+	DMap  string
+	Key   string
+	Token string
+}
+
+func NewReleasePermit(dmap, key, token string) *ReleasePermit {
+	return &ReleasePermit{
+		DMap:  dmap,
+		Key:   key,
+		Token: token,
+	}
+}
+
+func (r *ReleasePermit) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.ReleasePermit)
+	args = append(args, r.DMap)
+	args = append(args, r.Key)
+	args = append(args, r.Token)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseReleasePermitCommand(cmd redcon.Command) (*ReleasePermit, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewReleasePermit(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		util.BytesToString(cmd.Args[3]), // Token
+	), nil
+}
+
+// PutWithVersion sets the value of a versioned key if its current version is equal to
+// the expected version.
+type PutWithVersion struct {
+	DMap            string
+	Key             string
+	Value           []byte
+	ExpectedVersion int64
+}
+
+func NewPutWithVersion(dmap, key string, value []byte, expectedVersion int64) *PutWithVersion {
+	return &PutWithVersion{
+		DMap:            dmap,
+		Key:             key,
+		Value:           value,
+		ExpectedVersion: expectedVersion,
+	}
+}
+
+func (p *PutWithVersion) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.PutWithVersion)
+	args = append(args, p.DMap)
+	args = append(args, p.Key)
+	args = append(args, p.Value)
+	args = append(args, p.ExpectedVersion)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParsePutWithVersionCommand(cmd redcon.Command) (*PutWithVersion, error) {
+	if len(cmd.Args) != 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	expectedVersion, err := strconv.ParseInt(util.BytesToString(cmd.Args[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if expectedVersion < 0 {
+		return nil, fmt.Errorf("%w: negative version: %d", ErrInvalidArgument, expectedVersion)
+	}
+
+	return NewPutWithVersion(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		cmd.Args[3],                     // Value
+		expectedVersion,                 // ExpectedVersion
+	), nil
+}
+
+// LPushCapped pushes the values to the head of the list stored at the key and trims
+// the list to Max elements.
+type LPushCapped struct {
+	DMap   string
+	Key    string
+	Max    int
+	Trim   int
+	Values [][]byte
+}
+
+func NewLPushCapped(dmap, key string, max, trim int, values ...[]byte) *LPushCapped {
+	return &LPushCapped{
+		DMap:   dmap,
+		Key:    key,
+		Max:    max,
+		Trim:   trim,
+		Values: values,
+	}
+}
+
+func (l *LPushCapped) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.LPushCapped)
+	args = append(args, l.DMap)
+	args = append(args, l.Key)
+	args = append(args, l.Max)
+	args = append(args, l.Trim)
+	for _, value := range l.Values {
+		args = append(args, value)
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseLPushCappedCommand(cmd redcon.Command) (*LPushCapped, error) {
+	if len(cmd.Args) < 6 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	max, err := strconv.Atoi(util.BytesToString(cmd.Args[3]))
+	if err != nil {
+		return nil, err
+	}
+	if max <= 0 {
+		return nil, fmt.Errorf("%w: max must be greater than zero: %d", ErrInvalidArgument, max)
+	}
+
+	trim, err := strconv.Atoi(util.BytesToString(cmd.Args[4]))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLPushCapped(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		max,
+		trim,
+		cmd.Args[5:]...,
+	), nil
+}
+
+// LRange returns the elements of the list stored at the key between Start and Stop.
+type LRange struct {
+	DMap  string
+	Key   string
+	Start int
+	Stop  int
+}
+
+func NewLRange(dmap, key string, start, stop int) *LRange {
+	return &LRange{
+		DMap:  dmap,
+		Key:   key,
+		Start: start,
+		Stop:  stop,
+	}
+}
+
+func (l *LRange) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.LRange)
+	args = append(args, l.DMap)
+	args = append(args, l.Key)
+	args = append(args, l.Start)
+	args = append(args, l.Stop)
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseLRangeCommand(cmd redcon.Command) (*LRange, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	start, err := strconv.Atoi(util.BytesToString(cmd.Args[3]))
+	if err != nil {
+		return nil, err
+	}
+	stop, err := strconv.Atoi(util.BytesToString(cmd.Args[4]))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLRange(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		start,
+		stop,
+	), nil
+}
+
+// CapTTL sets the TTL of the keys without a TTL or with a longer TTL than MaxTTL to MaxTTL.
+type CapTTL struct {
+	DMap   string
+	MaxTTL int64
+	Local  bool
+}
+
+// NewCapTTL creates a new CapTTL command. maxTTL is in milliseconds.
+func NewCapTTL(dmap string, maxTTL int64) *CapTTL {
+	return &CapTTL{
+		DMap:   dmap,
+		MaxTTL: maxTTL,
+	}
+}
+
+func (c *CapTTL) SetLocal() *CapTTL {
+	c.Local = true
+	return c
+}
+
+func (c *CapTTL) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.CapTTL)
+	args = append(args, c.DMap)
+	args = append(args, c.MaxTTL)
+	if c.Local {
+		args = append(args, "LC")
+	}
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseCapTTLCommand(cmd redcon.Command) (*CapTTL, error) {
+	if len(cmd.Args) < 3 || len(cmd.Args) > 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	maxTTL, err := strconv.ParseInt(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if maxTTL <= 0 {
+		return nil, fmt.Errorf("%w: max TTL must be greater than zero: %d", ErrInvalidArgument, maxTTL)
+	}
+
+	c := NewCapTTL(
+		util.BytesToString(cmd.Args[1]), // DMap
+		maxTTL,                          // MaxTTL
+	)
+
+	if len(cmd.Args) == 4 {
+		arg := util.BytesToString(cmd.Args[3])
+		if arg != "LC" {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+		c.SetLocal()
+	}
+	return c, nil
+}
+
+// SetBit sets or clears the bit at Offset in the value stored at the key.
+type SetBit struct {
+	DMap   string
+	Key    string
+	Offset int
+	Value  int
+}
+
+func NewSetBit(dmap, key string, offset, value int) *SetBit {
+	return &SetBit{
+		DMap:   dmap,
+		Key:    key,
+		Offset: offset,
+		Value:  value,
+	}
+}
+
+func (s *SetBit) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.SetBit)
+	args = append(args, s.DMap)
+	args = append(args, s.Key)
+	args = append(args, s.Offset)
+	args = append(args, s.Value)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseSetBitCommand(cmd redcon.Command) (*SetBit, error) {
+	if len(cmd.Args) < 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	offset, err := strconv.Atoi(util.BytesToString(cmd.Args[3]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid offset: %s", ErrInvalidArgument, cmd.Args[3])
+	}
+	value, err := strconv.Atoi(util.BytesToString(cmd.Args[4]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid bit value: %s", ErrInvalidArgument, cmd.Args[4])
+	}
+
+	return NewSetBit(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		offset,
+		value,
+	), nil
+}
+
+// GetBit returns the bit at Offset in the value stored at the key.
+type GetBit struct {
+	DMap   string
+	Key    string
+	Offset int
+}
+
+func NewGetBit(dmap, key string, offset int) *GetBit {
+	return &GetBit{
+		DMap:   dmap,
+		Key:    key,
+		Offset: offset,
+	}
+}
+
+func (g *GetBit) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.GetBit)
+	args = append(args, g.DMap)
+	args = append(args, g.Key)
+	args = append(args, g.Offset)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseGetBitCommand(cmd redcon.Command) (*GetBit, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	offset, err := strconv.Atoi(util.BytesToString(cmd.Args[3]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid offset: %s", ErrInvalidArgument, cmd.Args[3])
+	}
+
+	return NewGetBit(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		offset,
+	), nil
+}
+
+// GetAndReset returns the integer value stored at the key and sets it to zero.
+type GetAndReset struct {
+	DMap string
+	Key  string
+}
+
+func NewGetAndReset(dmap, key string) *GetAndReset {
+	return &GetAndReset{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (g *GetAndReset) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.GetAndReset)
+	args = append(args, g.DMap)
+	args = append(args, g.Key)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseGetAndResetCommand(cmd redcon.Command) (*GetAndReset, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewGetAndReset(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
+type GetDel struct {
+	DMap string
+	Key  string
+	Raw  bool
+}
+
+func NewGetDel(dmap, key string) *GetDel {
+	return &GetDel{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (g *GetDel) SetRaw() *GetDel {
+	g.Raw = true
+	return g
+}
+
+func (g *GetDel) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.GetDel)
+	args = append(args, g.DMap)
+	args = append(args, g.Key)
+	if g.Raw {
+		args = append(args, "RW")
+	}
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseGetDelCommand(cmd redcon.Command) (*GetDel, error) {
+	if len(cmd.Args) < 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	g := NewGetDel(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	)
+
+	if len(cmd.Args) == 4 {
+		arg := util.BytesToString(cmd.Args[3])
+		if arg == "RW" {
+			g.SetRaw()
+		} else {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidArgument, arg)
+		}
+	}
+	return g, nil
+}
+
+// PutIfField sets the value of the key if the Field of the stored object is equal to Expected.
+type PutIfField struct {
+	DMap     string
+	Key      string
+	Field    string
+	Expected []byte
+	Value    []byte
+}
+
+func NewPutIfField(dmap, key, field string, expected, value []byte) *PutIfField {
+	return &PutIfField{
+		DMap:     dmap,
+		Key:      key,
+		Field:    field,
+		Expected: expected,
+		Value:    value,
+	}
+}
+
+func (p *PutIfField) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.PutIfField)
+	args = append(args, p.DMap)
+	args = append(args, p.Key)
+	args = append(args, p.Field)
+	args = append(args, p.Expected)
+	args = append(args, p.Value)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParsePutIfFieldCommand(cmd redcon.Command) (*PutIfField, error) {
+	if len(cmd.Args) != 6 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewPutIfField(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		util.BytesToString(cmd.Args[3]), // Field
+		cmd.Args[4],                     // Expected
+		cmd.Args[5],                     // Value
+	), nil
+}
+
+// Merge applies a JSON merge patch to the value of the key.
+type Merge struct {
+	DMap  string
+	Key   string
+	Patch []byte
+}
+
+func NewMerge(dmap, key string, patch []byte) *Merge {
+	return &Merge{
+		DMap:  dmap,
+		Key:   key,
+		Patch: patch,
+	}
+}
+
+func (m *Merge) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Merge)
+	args = append(args, m.DMap)
+	args = append(args, m.Key)
+	args = append(args, m.Patch)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseMergeCommand(cmd redcon.Command) (*Merge, error) {
+	if len(cmd.Args) != 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewMerge(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		cmd.Args[3],                     // Patch
+	), nil
+}
+
+// SetStandby stages the value in the standby slot of the key.
+type SetStandby struct {
+	DMap  string
+	Key   string
+	Value []byte
+}
+
+func NewSetStandby(dmap, key string, value []byte) *SetStandby {
+	return &SetStandby{
+		DMap:  dmap,
+		Key:   key,
+		Value: value,
+	}
+}
+
+func (s *SetStandby) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.SetStandby)
+	args = append(args, s.DMap)
+	args = append(args, s.Key)
+	args = append(args, s.Value)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseSetStandbyCommand(cmd redcon.Command) (*SetStandby, error) {
+	if len(cmd.Args) != 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewSetStandby(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		cmd.Args[3],                     // Value
+	), nil
+}
+
+// PromoteStandby swaps the active and the standby slots of the key.
+type PromoteStandby struct {
+	DMap string
+	Key  string
+}
+
+func NewPromoteStandby(dmap, key string) *PromoteStandby {
+	return &PromoteStandby{
+		DMap: dmap,
+		Key:  key,
+	}
+}
+
+func (p *PromoteStandby) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.PromoteStandby)
+	args = append(args, p.DMap)
+	args = append(args, p.Key)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParsePromoteStandbyCommand(cmd redcon.Command) (*PromoteStandby, error) {
+	if len(cmd.Args) != 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewPromoteStandby(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+	), nil
+}
+
+type GetByTag struct {
+	DMap string
+	Tag  string
+}
+
+func NewGetByTag(dmap, tag string) *GetByTag {
+	return &GetByTag{
+		DMap: dmap,
+		Tag:  tag,
+	}
+}
+
+func (g *GetByTag) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, DMap.GetByTag)
+	args = append(args, g.DMap)
+	args = append(args, g.Tag)
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseGetByTagCommand(cmd redcon.Command) (*GetByTag, error) {
+	if len(cmd.Args) != 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewGetByTag(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Tag
+	), nil
+}
+
+type Register struct {
+	DMap     string
+	Service  string
+	Instance string
+	Token    string
+	TTL      int64
+	Meta     []byte
+}
+
+func NewRegister(dmap, service, instance, token string, ttl int64, meta []byte) *Register {
+	return &Register{
+		DMap:     dmap,
+		Service:  service,
+		Instance: instance,
+		Token:    token,
+		TTL:      ttl,
+		Meta:     meta,
+	}
+}
+
+func (r *Register) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Register)
+	args = append(args, r.DMap)
+	args = append(args, r.Service)
+	args = append(args, r.Instance)
+	args = append(args, r.Token)
+	args = append(args, r.TTL)
+	args = append(args, r.Meta)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseRegisterCommand(cmd redcon.Command) (*Register, error) {
+	if len(cmd.Args) != 7 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	ttl, err := strconv.ParseInt(util.BytesToString(cmd.Args[5]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: non-positive TTL: %d", ErrInvalidArgument, ttl)
+	}
+
+	return NewRegister(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Service
+		util.BytesToString(cmd.Args[3]), // Instance
+		util.BytesToString(cmd.Args[4]), // Token
+		ttl,
+		cmd.Args[6], // Meta
+	), nil
+}
+
+type Heartbeat struct {
+	DMap     string
+	Service  string
+	Instance string
+	Token    string
+	TTL      int64
+}
+
+func NewHeartbeat(dmap, service, instance, token string, ttl int64) *Heartbeat {
+	return &Heartbeat{
+		DMap:     dmap,
+		Service:  service,
+		Instance: instance,
+		Token:    token,
+		TTL:      ttl,
+	}
+}
+
+func (h *Heartbeat) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Heartbeat)
+	args = append(args, h.DMap)
+	args = append(args, h.Service)
+	args = append(args, h.Instance)
+	args = append(args, h.Token)
+	args = append(args, h.TTL)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseHeartbeatCommand(cmd redcon.Command) (*Heartbeat, error) {
+	if len(cmd.Args) != 6 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	ttl, err := strconv.ParseInt(util.BytesToString(cmd.Args[5]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: non-positive TTL: %d", ErrInvalidArgument, ttl)
+	}
+
+	return NewHeartbeat(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Service
+		util.BytesToString(cmd.Args[3]), // Instance
+		util.BytesToString(cmd.Args[4]), // Token
+		ttl,
+	), nil
+}
+
+type Discover struct {
+	DMap    string
+	Service string
+}
+
+func NewDiscover(dmap, service string) *Discover {
+	return &Discover{
+		DMap:    dmap,
+		Service: service,
+	}
+}
+
+func (d *Discover) Command(ctx context.Context) *redis.SliceCmd {
+	var args []interface{}
+	args = append(args, DMap.Discover)
+	args = append(args, d.DMap)
+	args = append(args, d.Service)
+	return redis.NewSliceCmd(ctx, args...)
+}
+
+func ParseDiscoverCommand(cmd redcon.Command) (*Discover, error) {
+	if len(cmd.Args) != 3 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewDiscover(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Service
+	), nil
+}
+
+// Append appends Suffix to the value stored at the key.
+type Append struct {
+	DMap   string
+	Key    string
+	Suffix []byte
+}
+
+func NewAppend(dmap, key string, suffix []byte) *Append {
+	return &Append{
+		DMap:   dmap,
+		Key:    key,
+		Suffix: suffix,
+	}
+}
+
+func (a *Append) Command(ctx context.Context) *redis.IntCmd {
+	var args []interface{}
+	args = append(args, DMap.Append)
+	args = append(args, a.DMap)
+	args = append(args, a.Key)
+	args = append(args, a.Suffix)
+	return redis.NewIntCmd(ctx, args...)
+}
+
+func ParseAppendCommand(cmd redcon.Command) (*Append, error) {
+	if len(cmd.Args) < 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewAppend(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Key
+		cmd.Args[3],                     // Suffix
+	), nil
+}
+
+// Campaign makes Candidate the leader of Election for TTL milliseconds, if the election
+// has no leader or Candidate is already the leader.
+type Campaign struct {
+	DMap      string
+	Election  string
+	Candidate string
+	TTL       int64
+}
+
+func NewCampaign(dmap, election, candidate string, ttl int64) *Campaign {
+	return &Campaign{
+		DMap:      dmap,
+		Election:  election,
+		Candidate: candidate,
+		TTL:       ttl,
+	}
+}
+
+func (c *Campaign) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.Campaign)
+	args = append(args, c.DMap)
+	args = append(args, c.Election)
+	args = append(args, c.Candidate)
+	args = append(args, c.TTL)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseCampaignCommand(cmd redcon.Command) (*Campaign, error) {
+	if len(cmd.Args) != 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	ttl, err := strconv.ParseInt(util.BytesToString(cmd.Args[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("%w: non-positive TTL: %d", ErrInvalidArgument, ttl)
+	}
+
+	return NewCampaign(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Election
+		util.BytesToString(cmd.Args[3]), // Candidate
+		ttl,
+	), nil
+}
+
+// Resign releases the lease of Candidate on Election.
+type Resign struct {
+	DMap      string
+	Election  string
+	Candidate string
+}
+
+func NewResign(dmap, election, candidate string) *Resign {
+	return &Resign{
+		DMap:      dmap,
+		Election:  election,
+		Candidate: candidate,
+	}
+}
+
+func (r *Resign) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, DMap.Resign)
+	args = append(args, r.DMap)
+	args = append(args, r.Election)
+	args = append(args, r.Candidate)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseResignCommand(cmd redcon.Command) (*Resign, error) {
+	if len(cmd.Args) != 4 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewResign(
+		util.BytesToString(cmd.Args[1]), // DMap
+		util.BytesToString(cmd.Args[2]), // Election
+		util.BytesToString(cmd.Args[3]), // Candidate
+	), nil
+}
+
+// Changes reads the next batch of the DMap changes on a member. See dmap.ReadChanges.
+type Changes struct {
+	DMap   string
+	Since  int64
+	PartID uint64
+	Seq    uint64
+}
+
+func NewChanges(dmap string, since int64, partID, seq uint64) *Changes {
+	return &Changes{
+		DMap:   dmap,
+		Since:  since,
+		PartID: partID,
+		Seq:    seq,
+	}
+}
+
+func (c *Changes) Command(ctx context.Context) *redis.StringCmd {
+	var args []interface{}
+	args = append(args, DMap.Changes)
+	args = append(args, c.DMap)
+	args = append(args, c.Since)
+	args = append(args, c.PartID)
+	args = append(args, c.Seq)
+	return redis.NewStringCmd(ctx, args...)
+}
+
+func ParseChangesCommand(cmd redcon.Command) (*Changes, error) {
+	if len(cmd.Args) != 5 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	since, err := strconv.ParseInt(util.BytesToString(cmd.Args[2]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	partID, err := strconv.ParseUint(util.BytesToString(cmd.Args[3]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	seq, err := strconv.ParseUint(util.BytesToString(cmd.Args[4]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewChanges(
+		util.BytesToString(cmd.Args[1]), // DMap
+		since,                           // Since
+		partID,                          // PartID
+		seq,                             // Seq
+	), nil
+}
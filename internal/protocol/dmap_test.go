@@ -231,6 +231,19 @@ func TestProtocol_PutEntry(t *testing.T) {
 	require.Equal(t, []byte("my-value"), parsed.Value)
 }
 
+func TestProtocol_UpdateEntryTTL(t *testing.T) {
+	updateCmd := NewUpdateEntryTTL("my-dmap", "my-key", 1700000000000, 1700000000000000000)
+
+	cmd := stringToCommand(updateCmd.Command(context.Background()).String())
+	parsed, err := ParseUpdateEntryTTLCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, int64(1700000000000), parsed.TTL)
+	require.Equal(t, int64(1700000000000000000), parsed.Timestamp)
+}
+
 func TestProtocol_Get(t *testing.T) {
 	getCmd := NewGet("my-dmap", "my-key")
 
@@ -281,6 +294,20 @@ func TestProtocol_GetEntry_RC(t *testing.T) {
 	require.True(t, parsed.Replica)
 }
 
+func TestProtocol_GetEntry_ST(t *testing.T) {
+	getEntryCmd := NewGetEntry("my-dmap", "my-key")
+	getEntryCmd.SetReplica().SetStored()
+
+	cmd := stringToCommand(getEntryCmd.Command(context.Background()).String())
+	parsed, err := ParseGetEntryCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.True(t, parsed.Replica)
+	require.True(t, parsed.Stored)
+}
+
 func TestProtocol_Del(t *testing.T) {
 	delCmd := NewDel("my-dmap", "key1", "key2")
 
@@ -341,6 +368,39 @@ func TestProtocol_Expire(t *testing.T) {
 	require.Equal(t, 10*time.Second, parsed.Seconds)
 }
 
+func TestProtocol_Persist(t *testing.T) {
+	persistCmd := NewPersist("my-dmap", "my-key")
+
+	cmd := stringToCommand(persistCmd.Command(context.Background()).String())
+	parsed, err := ParsePersistCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
+func TestProtocol_TTL(t *testing.T) {
+	ttlCmd := NewTTL("my-dmap", "my-key")
+
+	cmd := stringToCommand(ttlCmd.Command(context.Background()).String())
+	parsed, err := ParseTTLCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
+func TestProtocol_PTTL(t *testing.T) {
+	pttlCmd := NewPTTL("my-dmap", "my-key")
+
+	cmd := stringToCommand(pttlCmd.Command(context.Background()).String())
+	parsed, err := ParsePTTLCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
 func TestProtocol_Destroy(t *testing.T) {
 	destroyCmd := NewDestroy("my-dmap")
 
@@ -439,6 +499,22 @@ func TestProtocol_Lock(t *testing.T) {
 	require.Equal(t, float64(7), parsed.Deadline)
 }
 
+func TestProtocol_LockResponse(t *testing.T) {
+	token, fencingToken, err := ParseLockResponse(FormatLockResponse([]byte{0xca, 0xfe}, 42))
+	require.NoError(t, err)
+	require.Equal(t, "cafe", token)
+	require.Equal(t, uint64(42), fencingToken)
+
+	// The older servers don't send the fencing token.
+	token, fencingToken, err = ParseLockResponse("cafe")
+	require.NoError(t, err)
+	require.Equal(t, "cafe", token)
+	require.Equal(t, uint64(0), fencingToken)
+
+	_, _, err = ParseLockResponse("cafe:x")
+	require.Error(t, err)
+}
+
 func TestProtocol_Lock_EX(t *testing.T) {
 	exDuration := (250 * time.Second).Seconds()
 	lockCmd := NewLock("my-dmap", "my-key", 7)
@@ -481,6 +557,21 @@ func TestProtocol_Unlock(t *testing.T) {
 	require.Equal(t, "token", parsed.Token)
 }
 
+func TestProtocol_Lock_PollInterval(t *testing.T) {
+	pxDuration := (250 * time.Millisecond).Milliseconds()
+	pollInterval := (50 * time.Millisecond).Milliseconds()
+	lockCmd := NewLock("my-dmap", "my-key", 7)
+	lockCmd.SetPX(pxDuration).SetPollInterval(pollInterval)
+
+	cmd := stringToCommand(lockCmd.Command(context.Background()).String())
+	parsed, err := ParseLockCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, float64(7), parsed.Deadline)
+	require.Equal(t, pxDuration, parsed.PX)
+	require.Equal(t, pollInterval, parsed.PollInterval)
+}
+
 func TestProtocol_LockLease(t *testing.T) {
 	timeout := (7 * time.Second).Seconds()
 	unlockCmd := NewLockLease("my-dmap", "my-key", "token", timeout)
@@ -509,6 +600,21 @@ func TestProtocol_PLockLease(t *testing.T) {
 	require.Equal(t, timeout, parsed.Timeout)
 }
 
+func TestProtocol_LockLeasePut(t *testing.T) {
+	timeout := (250 * time.Millisecond).Milliseconds()
+	lockLeasePutCmd := NewLockLeasePut("my-dmap", "my-key", "token", timeout, []byte("my-value"))
+
+	cmd := stringToCommand(lockLeasePutCmd.Command(context.Background()).String())
+	parsed, err := ParseLockLeasePutCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, "token", parsed.Token)
+	require.Equal(t, timeout, parsed.Timeout)
+	require.Equal(t, []byte("my-value"), parsed.Value)
+}
+
 func TestProtocol_Scan(t *testing.T) {
 	scanCmd := NewScan(17, "my-dmap", 234)
 
@@ -541,3 +647,702 @@ func TestProtocol_Scan_Count_Match_Replica(t *testing.T) {
 	require.Equal(t, 123, parsed.Count)
 	require.Equal(t, "^even:", parsed.Match)
 }
+
+func TestProtocol_SetNXGet(t *testing.T) {
+	setNXGetCmd := NewSetNXGet("my-dmap", "my-key", []byte("my-value"), 1000)
+	setNXGetCmd.SetRaw()
+
+	cmd := stringToCommand(setNXGetCmd.Command(context.Background()).String())
+	parsed, err := ParseSetNXGetCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []byte("my-value"), parsed.Value)
+	require.Equal(t, int64(1000), parsed.PX)
+	require.True(t, parsed.Raw)
+}
+
+func TestProtocol_DecrAndDeleteAtZero(t *testing.T) {
+	decrAndDeleteAtZeroCmd := NewDecrAndDeleteAtZero("my-dmap", "my-key", 3)
+
+	cmd := stringToCommand(decrAndDeleteAtZeroCmd.Command(context.Background()).String())
+	parsed, err := ParseDecrAndDeleteAtZeroCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, 3, parsed.Delta)
+}
+
+func TestProtocol_GetAndReset(t *testing.T) {
+	getAndResetCmd := NewGetAndReset("my-dmap", "my-key")
+
+	cmd := stringToCommand(getAndResetCmd.Command(context.Background()).String())
+	parsed, err := ParseGetAndResetCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
+func TestProtocol_PutIfField(t *testing.T) {
+	putIfFieldCmd := NewPutIfField("my-dmap", "my-key", "state", []byte("pending"), []byte("my-value"))
+
+	cmd := stringToCommand(putIfFieldCmd.Command(context.Background()).String())
+	parsed, err := ParsePutIfFieldCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, "state", parsed.Field)
+	require.Equal(t, []byte("pending"), parsed.Expected)
+	require.Equal(t, []byte("my-value"), parsed.Value)
+}
+
+func TestProtocol_Merge(t *testing.T) {
+	mergeCmd := NewMerge("my-dmap", "my-key", []byte(`{"a":null}`))
+
+	cmd := stringToCommand(mergeCmd.Command(context.Background()).String())
+	parsed, err := ParseMergeCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []byte(`{"a":null}`), parsed.Patch)
+}
+
+func TestProtocol_SetStandby(t *testing.T) {
+	setStandbyCmd := NewSetStandby("my-dmap", "my-key", []byte("my-value"))
+
+	cmd := stringToCommand(setStandbyCmd.Command(context.Background()).String())
+	parsed, err := ParseSetStandbyCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []byte("my-value"), parsed.Value)
+}
+
+func TestProtocol_PromoteStandby(t *testing.T) {
+	promoteCmd := NewPromoteStandby("my-dmap", "my-key")
+
+	cmd := stringToCommand(promoteCmd.Command(context.Background()).String())
+	parsed, err := ParsePromoteStandbyCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
+func TestProtocol_GetDel(t *testing.T) {
+	getDelCmd := NewGetDel("my-dmap", "my-key")
+
+	cmd := stringToCommand(getDelCmd.Command(context.Background()).String())
+	parsed, err := ParseGetDelCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.False(t, parsed.Raw)
+
+	cmd = stringToCommand(getDelCmd.SetRaw().Command(context.Background()).String())
+	parsed, err = ParseGetDelCommand(cmd)
+	require.NoError(t, err)
+	require.True(t, parsed.Raw)
+}
+
+func TestProtocol_Register(t *testing.T) {
+	registerCmd := NewRegister("my-dmap", "my-service", "my-instance", "my-token", 1000, []byte("my:meta"))
+
+	cmd := stringToCommand(registerCmd.Command(context.Background()).String())
+	parsed, err := ParseRegisterCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-service", parsed.Service)
+	require.Equal(t, "my-instance", parsed.Instance)
+	require.Equal(t, "my-token", parsed.Token)
+	require.Equal(t, int64(1000), parsed.TTL)
+	require.Equal(t, []byte("my:meta"), parsed.Meta)
+}
+
+func TestProtocol_Heartbeat(t *testing.T) {
+	heartbeatCmd := NewHeartbeat("my-dmap", "my-service", "my-instance", "my-token", 1000)
+
+	cmd := stringToCommand(heartbeatCmd.Command(context.Background()).String())
+	parsed, err := ParseHeartbeatCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-service", parsed.Service)
+	require.Equal(t, "my-instance", parsed.Instance)
+	require.Equal(t, "my-token", parsed.Token)
+	require.Equal(t, int64(1000), parsed.TTL)
+
+	heartbeatCmd.TTL = 0
+	cmd = stringToCommand(heartbeatCmd.Command(context.Background()).String())
+	_, err = ParseHeartbeatCommand(cmd)
+	require.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestProtocol_Discover(t *testing.T) {
+	discoverCmd := NewDiscover("my-dmap", "my-service")
+
+	cmd := stringToCommand(discoverCmd.Command(context.Background()).String())
+	parsed, err := ParseDiscoverCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-service", parsed.Service)
+}
+
+func TestProtocol_GetByTag(t *testing.T) {
+	getByTagCmd := NewGetByTag("my-dmap", "user:42")
+
+	cmd := stringToCommand(getByTagCmd.Command(context.Background()).String())
+	parsed, err := ParseGetByTagCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "user:42", parsed.Tag)
+}
+
+func TestProtocol_Eval(t *testing.T) {
+	evalCmd := NewEval("my-dmap", "my-script", []string{"key-1", "key-2"}, "arg-1")
+
+	cmd := stringToCommand(evalCmd.Command(context.Background()).String())
+	parsed, err := ParseEvalCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-script", parsed.Script)
+	require.Equal(t, []string{"key-1", "key-2"}, parsed.Keys)
+	require.Equal(t, []string{"arg-1"}, parsed.Args)
+}
+
+func TestProtocol_PutAllIfNoneExist(t *testing.T) {
+	pairs := map[string][]byte{
+		"key-1": []byte("value-1"),
+		"key-2": []byte("value-2"),
+	}
+	putAllCmd := NewPutAllIfNoneExist("my-dmap", pairs)
+
+	cmd := stringToCommand(putAllCmd.Command(context.Background()).String())
+	parsed, err := ParsePutAllIfNoneExistCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, pairs, parsed.Pairs)
+}
+
+func TestProtocol_EntryAgeRange(t *testing.T) {
+	entryAgeRangeCmd := NewEntryAgeRange("my-dmap")
+
+	cmd := stringToCommand(entryAgeRangeCmd.Command(context.Background()).String())
+	parsed, err := ParseEntryAgeRangeCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.False(t, parsed.Local)
+
+	t.Run("EntryAgeRange with LC", func(t *testing.T) {
+		entryAgeRangeCmd := NewEntryAgeRange("my-dmap").SetLocal()
+
+		cmd := stringToCommand(entryAgeRangeCmd.Command(context.Background()).String())
+		parsed, err := ParseEntryAgeRangeCommand(cmd)
+		require.NoError(t, err)
+		require.Equal(t, "my-dmap", parsed.DMap)
+		require.True(t, parsed.Local)
+	})
+}
+
+func TestProtocol_MemoryUsage(t *testing.T) {
+	memoryUsageCmd := NewMemoryUsage("my-dmap", "my-key")
+
+	cmd := stringToCommand(memoryUsageCmd.Command(context.Background()).String())
+	parsed, err := ParseMemoryUsageCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+}
+
+func TestProtocol_ExpiringSoon(t *testing.T) {
+	expiringSoonCmd := NewExpiringSoon("my-dmap", 5000, 10)
+
+	cmd := stringToCommand(expiringSoonCmd.Command(context.Background()).String())
+	parsed, err := ParseExpiringSoonCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, int64(5000), parsed.Within)
+	require.Equal(t, int64(10), parsed.Limit)
+	require.False(t, parsed.Local)
+
+	t.Run("ExpiringSoon with LC", func(t *testing.T) {
+		expiringSoonCmd := NewExpiringSoon("my-dmap", 5000, 10).SetLocal()
+
+		cmd := stringToCommand(expiringSoonCmd.Command(context.Background()).String())
+		parsed, err := ParseExpiringSoonCommand(cmd)
+		require.NoError(t, err)
+		require.True(t, parsed.Local)
+	})
+}
+
+func TestProtocol_ValueHashes(t *testing.T) {
+	valueHashesCmd := NewValueHashes("my-dmap")
+
+	cmd := stringToCommand(valueHashesCmd.Command(context.Background()).String())
+	parsed, err := ParseValueHashesCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+}
+
+func TestProtocol_Diff(t *testing.T) {
+	diffCmd := NewDiff("my-dmap-a", "my-dmap-b")
+
+	cmd := stringToCommand(diffCmd.Command(context.Background()).String())
+	parsed, err := ParseDiffCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap-a", parsed.DMapA)
+	require.Equal(t, "my-dmap-b", parsed.DMapB)
+}
+
+func TestProtocol_ClaimNext(t *testing.T) {
+	claimNextCmd := NewClaimNext("my-dmap", 5000)
+
+	cmd := stringToCommand(claimNextCmd.Command(context.Background()).String())
+	parsed, err := ParseClaimNextCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, int64(5000), parsed.ClaimTTL)
+	require.False(t, parsed.Local)
+
+	t.Run("ClaimNext with LC", func(t *testing.T) {
+		claimNextCmd := NewClaimNext("my-dmap", 5000).SetLocal()
+
+		cmd := stringToCommand(claimNextCmd.Command(context.Background()).String())
+		parsed, err := ParseClaimNextCommand(cmd)
+		require.NoError(t, err)
+		require.True(t, parsed.Local)
+	})
+
+	t.Run("Non-positive claim TTL", func(t *testing.T) {
+		claimNextCmd := NewClaimNext("my-dmap", 0)
+
+		cmd := stringToCommand(claimNextCmd.Command(context.Background()).String())
+		_, err := ParseClaimNextCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+}
+
+func TestProtocol_SyncReplica(t *testing.T) {
+	syncReplicaCmd := NewSyncReplica("my-dmap", 12, "127.0.0.1:3320")
+
+	cmd := stringToCommand(syncReplicaCmd.Command(context.Background()).String())
+	parsed, err := ParseSyncReplicaCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, uint64(12), parsed.PartID)
+	require.Equal(t, "127.0.0.1:3320", parsed.Member)
+}
+
+func TestProtocol_GetMany(t *testing.T) {
+	getManyCmd := NewGetMany("my-dmap", []string{"key-1", "LC"})
+
+	cmd := stringToCommand(getManyCmd.Command(context.Background()).String())
+	parsed, err := ParseGetManyCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, []string{"key-1", "LC"}, parsed.Keys)
+	require.False(t, parsed.Local)
+
+	t.Run("GetMany with LC", func(t *testing.T) {
+		getManyCmd := NewGetMany("my-dmap", []string{"key-1"}).SetLocal()
+
+		cmd := stringToCommand(getManyCmd.Command(context.Background()).String())
+		parsed, err := ParseGetManyCommand(cmd)
+		require.NoError(t, err)
+		require.Equal(t, []string{"key-1"}, parsed.Keys)
+		require.True(t, parsed.Local)
+	})
+}
+
+func TestProtocol_ReserveSequence(t *testing.T) {
+	reserveCmd := NewReserveSequence("my-dmap", "my-seq", 10)
+
+	cmd := stringToCommand(reserveCmd.Command(context.Background()).String())
+	parsed, err := ParseReserveSequenceCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-seq", parsed.Name)
+	require.Equal(t, 10, parsed.N)
+}
+
+func TestProtocol_Exists(t *testing.T) {
+	existsCmd := NewExists("my-dmap", []string{"key-1", "key-2"})
+
+	cmd := stringToCommand(existsCmd.Command(context.Background()).String())
+	parsed, err := ParseExistsCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, []string{"key-1", "key-2"}, parsed.Keys)
+	require.False(t, parsed.Local)
+
+	t.Run("Exists with LC", func(t *testing.T) {
+		existsCmd := NewExists("my-dmap", []string{"key-1"}).SetLocal()
+
+		cmd := stringToCommand(existsCmd.Command(context.Background()).String())
+		parsed, err := ParseExistsCommand(cmd)
+		require.NoError(t, err)
+		require.Equal(t, []string{"key-1"}, parsed.Keys)
+		require.True(t, parsed.Local)
+	})
+}
+
+func TestProtocol_PutMany(t *testing.T) {
+	putManyCmd := NewPutMany("my-dmap").
+		Add("key-1", []byte("value-1")).
+		Add("key-2", []byte("value-2"))
+
+	cmd := stringToCommand(putManyCmd.Command(context.Background()).String())
+	parsed, err := ParsePutManyCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, []string{"key-1", "key-2"}, parsed.Keys)
+	require.Equal(t, [][]byte{[]byte("value-1"), []byte("value-2")}, parsed.Values)
+	require.False(t, parsed.Local)
+
+	t.Run("PutMany with options", func(t *testing.T) {
+		putManyCmd := NewPutMany("my-dmap").
+			Add("key-1", []byte("value-1")).
+			SetPX(1000).
+			SetNX().
+			SetLocal()
+
+		cmd := stringToCommand(putManyCmd.Command(context.Background()).String())
+		parsed, err := ParsePutManyCommand(cmd)
+		require.NoError(t, err)
+		require.Equal(t, []string{"key-1"}, parsed.Keys)
+		require.Equal(t, int64(1000), parsed.PX)
+		require.True(t, parsed.NX)
+		require.True(t, parsed.Local)
+	})
+}
+
+func TestProtocol_GetTTLMany(t *testing.T) {
+	getTTLManyCmd := NewGetTTLMany("my-dmap", []string{"key-1", "LC"})
+
+	cmd := stringToCommand(getTTLManyCmd.Command(context.Background()).String())
+	parsed, err := ParseGetTTLManyCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, []string{"key-1", "LC"}, parsed.Keys)
+	require.False(t, parsed.Local)
+
+	t.Run("GetTTLMany with LC", func(t *testing.T) {
+		getTTLManyCmd := NewGetTTLMany("my-dmap", []string{"key-1"}).SetLocal()
+
+		cmd := stringToCommand(getTTLManyCmd.Command(context.Background()).String())
+		parsed, err := ParseGetTTLManyCommand(cmd)
+		require.NoError(t, err)
+		require.Equal(t, []string{"key-1"}, parsed.Keys)
+		require.True(t, parsed.Local)
+	})
+}
+
+func TestProtocol_Rotate(t *testing.T) {
+	rotateCmd := NewRotate("my-dmap", "my-key", []byte("my-value"), 5000)
+
+	cmd := stringToCommand(rotateCmd.Command(context.Background()).String())
+	parsed, err := ParseRotateCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []byte("my-value"), parsed.Value)
+	require.Equal(t, int64(5000), parsed.GraceTTL)
+
+	t.Run("Non-positive grace TTL", func(t *testing.T) {
+		rotateCmd := NewRotate("my-dmap", "my-key", []byte("my-value"), 0)
+
+		cmd := stringToCommand(rotateCmd.Command(context.Background()).String())
+		_, err := ParseRotateCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+}
+
+func TestProtocol_DelIfExpiredBefore(t *testing.T) {
+	delCmd := NewDelIfExpiredBefore("my-dmap", "my-key", 1700000000000)
+
+	cmd := stringToCommand(delCmd.Command(context.Background()).String())
+	parsed, err := ParseDelIfExpiredBeforeCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, int64(1700000000000), parsed.Timestamp)
+}
+
+func TestProtocol_HotKeys(t *testing.T) {
+	hotKeysCmd := NewHotKeys("my-dmap", 10)
+
+	cmd := stringToCommand(hotKeysCmd.Command(context.Background()).String())
+	parsed, err := ParseHotKeysCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, int64(10), parsed.Count)
+	require.False(t, parsed.Local)
+
+	t.Run("HotKeys with LC", func(t *testing.T) {
+		hotKeysCmd := NewHotKeys("my-dmap", 10).SetLocal()
+
+		cmd := stringToCommand(hotKeysCmd.Command(context.Background()).String())
+		parsed, err := ParseHotKeysCommand(cmd)
+		require.NoError(t, err)
+		require.True(t, parsed.Local)
+	})
+
+	t.Run("Non-positive count", func(t *testing.T) {
+		hotKeysCmd := NewHotKeys("my-dmap", 0)
+
+		cmd := stringToCommand(hotKeysCmd.Command(context.Background()).String())
+		_, err := ParseHotKeysCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+}
+
+func TestProtocol_HIncrBy(t *testing.T) {
+	hincrByCmd := NewHIncrBy("my-dmap", "my-key", "my-field", -7)
+
+	cmd := stringToCommand(hincrByCmd.Command(context.Background()).String())
+	parsed, err := ParseHIncrByCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, "my-field", parsed.Field)
+	require.Equal(t, -7, parsed.Delta)
+}
+
+func TestProtocol_HMSet(t *testing.T) {
+	fields := map[string][]byte{
+		"name": []byte("foo"),
+		"age":  []byte("42"),
+	}
+	hmsetCmd := NewHMSet("my-dmap", "my-key", fields)
+
+	cmd := stringToCommand(hmsetCmd.Command(context.Background()).String())
+	parsed, err := ParseHMSetCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, fields, parsed.Fields)
+
+	t.Run("Missing value", func(t *testing.T) {
+		cmd := stringToCommand("dm.hmset my-dmap my-key name")
+		_, err := ParseHMSetCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_HMGet(t *testing.T) {
+	hmgetCmd := NewHMGet("my-dmap", "my-key", "name", "age")
+
+	cmd := stringToCommand(hmgetCmd.Command(context.Background()).String())
+	parsed, err := ParseHMGetCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []string{"name", "age"}, parsed.Fields)
+}
+
+func TestProtocol_AcquirePermit(t *testing.T) {
+	acquireCmd := NewAcquirePermit("my-dmap", "my-key", "my-token", 3, 1000)
+
+	cmd := stringToCommand(acquireCmd.Command(context.Background()).String())
+	parsed, err := ParseAcquirePermitCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, "my-token", parsed.Token)
+	require.Equal(t, 3, parsed.Max)
+	require.Equal(t, int64(1000), parsed.TTL)
+
+	t.Run("Non-positive max", func(t *testing.T) {
+		acquireCmd := NewAcquirePermit("my-dmap", "my-key", "my-token", 0, 1000)
+
+		cmd := stringToCommand(acquireCmd.Command(context.Background()).String())
+		_, err := ParseAcquirePermitCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+}
+
+func TestProtocol_ReleasePermit(t *testing.T) {
+	releaseCmd := NewReleasePermit("my-dmap", "my-key", "my-token")
+
+	cmd := stringToCommand(releaseCmd.Command(context.Background()).String())
+	parsed, err := ParseReleasePermitCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, "my-token", parsed.Token)
+}
+
+func TestProtocol_PutWithVersion(t *testing.T) {
+	putCmd := NewPutWithVersion("my-dmap", "my-key", []byte("my-value"), 3)
+
+	cmd := stringToCommand(putCmd.Command(context.Background()).String())
+	parsed, err := ParsePutWithVersionCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []byte("my-value"), parsed.Value)
+	require.Equal(t, int64(3), parsed.ExpectedVersion)
+
+	t.Run("Negative version", func(t *testing.T) {
+		putCmd := NewPutWithVersion("my-dmap", "my-key", []byte("my-value"), -1)
+
+		cmd := stringToCommand(putCmd.Command(context.Background()).String())
+		_, err := ParsePutWithVersionCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+}
+
+func TestProtocol_LPushCapped(t *testing.T) {
+	lpushCmd := NewLPushCapped("my-dmap", "my-key", 3, 1, []byte("foo"), []byte("bar"))
+
+	cmd := stringToCommand(lpushCmd.Command(context.Background()).String())
+	parsed, err := ParseLPushCappedCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, 3, parsed.Max)
+	require.Equal(t, 1, parsed.Trim)
+	require.Equal(t, [][]byte{[]byte("foo"), []byte("bar")}, parsed.Values)
+
+	t.Run("Non-positive max", func(t *testing.T) {
+		lpushCmd := NewLPushCapped("my-dmap", "my-key", 0, 0, []byte("foo"))
+
+		cmd := stringToCommand(lpushCmd.Command(context.Background()).String())
+		_, err := ParseLPushCappedCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+
+	t.Run("Missing value", func(t *testing.T) {
+		cmd := stringToCommand("dm.lpushcapped my-dmap my-key 3 0")
+		_, err := ParseLPushCappedCommand(cmd)
+		require.Error(t, err)
+	})
+}
+
+func TestProtocol_LRange(t *testing.T) {
+	lrangeCmd := NewLRange("my-dmap", "my-key", 0, -1)
+
+	cmd := stringToCommand(lrangeCmd.Command(context.Background()).String())
+	parsed, err := ParseLRangeCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, 0, parsed.Start)
+	require.Equal(t, -1, parsed.Stop)
+}
+
+func TestProtocol_CapTTL(t *testing.T) {
+	capCmd := NewCapTTL("my-dmap", 60000)
+
+	cmd := stringToCommand(capCmd.Command(context.Background()).String())
+	parsed, err := ParseCapTTLCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, int64(60000), parsed.MaxTTL)
+	require.False(t, parsed.Local)
+
+	t.Run("Local", func(t *testing.T) {
+		capCmd := NewCapTTL("my-dmap", 60000).SetLocal()
+
+		cmd := stringToCommand(capCmd.Command(context.Background()).String())
+		parsed, err := ParseCapTTLCommand(cmd)
+		require.NoError(t, err)
+		require.True(t, parsed.Local)
+	})
+
+	t.Run("Non-positive max TTL", func(t *testing.T) {
+		cmd := stringToCommand("dm.capttl my-dmap 0")
+		_, err := ParseCapTTLCommand(cmd)
+		require.ErrorIs(t, err, ErrInvalidArgument)
+	})
+}
+
+func TestProtocol_SetBit(t *testing.T) {
+	setBitCmd := NewSetBit("my-dmap", "my-key", 7, 1)
+
+	cmd := stringToCommand(setBitCmd.Command(context.Background()).String())
+	parsed, err := ParseSetBitCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, 7, parsed.Offset)
+	require.Equal(t, 1, parsed.Value)
+}
+
+func TestProtocol_GetBit(t *testing.T) {
+	getBitCmd := NewGetBit("my-dmap", "my-key", 7)
+
+	cmd := stringToCommand(getBitCmd.Command(context.Background()).String())
+	parsed, err := ParseGetBitCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, 7, parsed.Offset)
+}
+
+func TestProtocol_Append(t *testing.T) {
+	appendCmd := NewAppend("my-dmap", "my-key", []byte("my-suffix"))
+
+	cmd := stringToCommand(appendCmd.Command(context.Background()).String())
+	parsed, err := ParseAppendCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-key", parsed.Key)
+	require.Equal(t, []byte("my-suffix"), parsed.Suffix)
+}
+
+func TestProtocol_Campaign(t *testing.T) {
+	campaignCmd := NewCampaign("my-dmap", "my-election", "my-candidate", 1000)
+
+	cmd := stringToCommand(campaignCmd.Command(context.Background()).String())
+	parsed, err := ParseCampaignCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-election", parsed.Election)
+	require.Equal(t, "my-candidate", parsed.Candidate)
+	require.Equal(t, int64(1000), parsed.TTL)
+
+	cmd = stringToCommand(NewCampaign("my-dmap", "my-election", "my-candidate", 0).Command(context.Background()).String())
+	_, err = ParseCampaignCommand(cmd)
+	require.ErrorIs(t, err, ErrInvalidArgument)
+}
+
+func TestProtocol_Resign(t *testing.T) {
+	resignCmd := NewResign("my-dmap", "my-election", "my-candidate")
+
+	cmd := stringToCommand(resignCmd.Command(context.Background()).String())
+	parsed, err := ParseResignCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, "my-election", parsed.Election)
+	require.Equal(t, "my-candidate", parsed.Candidate)
+}
+
+func TestProtocol_Changes(t *testing.T) {
+	changesCmd := NewChanges("my-dmap", 1700000000000000000, 12, 345)
+
+	cmd := stringToCommand(changesCmd.Command(context.Background()).String())
+	parsed, err := ParseChangesCommand(cmd)
+	require.NoError(t, err)
+	require.Equal(t, "my-dmap", parsed.DMap)
+	require.Equal(t, int64(1700000000000000000), parsed.Since)
+	require.Equal(t, uint64(12), parsed.PartID)
+	require.Equal(t, uint64(345), parsed.Seq)
+}
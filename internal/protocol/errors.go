@@ -85,6 +85,10 @@ func ConvertError(err error) error {
 
 	parsed := strings.SplitN(err.Error(), " ", 2)
 	if perr := GetError(parsed[0]); perr != nil {
+		// Keep the details if the error is wrapped on the server side.
+		if len(parsed) > 1 && parsed[1] != perr.Error() && strings.HasPrefix(parsed[1], perr.Error()) {
+			return fmt.Errorf("%w%s", perr, strings.TrimPrefix(parsed[1], perr.Error()))
+		}
 		return perr
 	}
 
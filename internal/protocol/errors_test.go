@@ -51,3 +51,11 @@ func TestProtocol_ConvertError(t *testing.T) {
 	cerr := ConvertError(err)
 	require.ErrorIs(t, cerr, errSomethingWentWrong)
 }
+
+func TestProtocol_ConvertError_Details(t *testing.T) {
+	SetError("WRONG", errSomethingWentWrong)
+	err := fmt.Errorf("WRONG %s: mykey", errSomethingWentWrong.Error())
+	cerr := ConvertError(err)
+	require.ErrorIs(t, cerr, errSomethingWentWrong)
+	require.Equal(t, "something went wrong: mykey", cerr.Error())
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/buraksezer/olric/internal/util"
 	"github.com/redis/go-redis/v9"
@@ -83,6 +84,52 @@ func ParseMoveFragmentCommand(cmd redcon.Command) (*MoveFragment, error) {
 	return NewMoveFragment(cmd.Args[1]), nil
 }
 
+// ReplaceFragment carries a full copy of a fragment. It replaces the fragment on the receiver.
+type ReplaceFragment struct {
+	Payload []byte
+}
+
+func NewReplaceFragment(payload []byte) *ReplaceFragment {
+	return &ReplaceFragment{
+		Payload: payload,
+	}
+}
+
+func (r *ReplaceFragment) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Internal.ReplaceFragment)
+	args = append(args, r.Payload)
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseReplaceFragmentCommand(cmd redcon.Command) (*ReplaceFragment, error) {
+	if len(cmd.Args) != 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	return NewReplaceFragment(cmd.Args[1]), nil
+}
+
+// DMapNames returns the names of the DMaps initialized on the receiving member.
+type DMapNames struct{}
+
+func NewDMapNames() *DMapNames {
+	return &DMapNames{}
+}
+
+func (d *DMapNames) Command(ctx context.Context) *redis.StringSliceCmd {
+	var args []interface{}
+	args = append(args, Internal.DMapNames)
+	return redis.NewStringSliceCmd(ctx, args...)
+}
+
+func ParseDMapNamesCommand(cmd redcon.Command) (*DMapNames, error) {
+	if len(cmd.Args) != 1 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+	return NewDMapNames(), nil
+}
+
 type UpdateRouting struct {
 	Payload       []byte
 	CoordinatorID uint64
@@ -202,3 +249,38 @@ func ParseStatsCommand(cmd redcon.Command) (*Stats, error) {
 
 	return s, nil
 }
+
+// CompactTables compacts the storage tables of the DMaps on the receiving member. Only the
+// tables that are not written within Age are compacted.
+type CompactTables struct {
+	Age time.Duration
+}
+
+func NewCompactTables(age time.Duration) *CompactTables {
+	return &CompactTables{
+		Age: age,
+	}
+}
+
+func (c *CompactTables) Command(ctx context.Context) *redis.StatusCmd {
+	var args []interface{}
+	args = append(args, Generic.CompactTables)
+	args = append(args, c.Age.Milliseconds())
+	return redis.NewStatusCmd(ctx, args...)
+}
+
+func ParseCompactTablesCommand(cmd redcon.Command) (*CompactTables, error) {
+	if len(cmd.Args) != 2 {
+		return nil, errWrongNumber(cmd.Args)
+	}
+
+	age, err := strconv.ParseInt(util.BytesToString(cmd.Args[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if age < 0 {
+		return nil, fmt.Errorf("%w: negative age: %d", ErrInvalidArgument, age)
+	}
+
+	return NewCompactTables(time.Duration(age) * time.Millisecond), nil
+}
@@ -18,6 +18,7 @@ import (
 	"context"
 	"github.com/stretchr/testify/require"
 	"testing"
+	"time"
 )
 
 func TestProtocol_Ping(t *testing.T) {
@@ -51,6 +52,24 @@ func TestProtocol_MoveFragment(t *testing.T) {
 	require.Equal(t, []byte("payload"), parsed.Payload)
 }
 
+func TestProtocol_ReplaceFragment(t *testing.T) {
+	replaceFragmentCmd := NewReplaceFragment([]byte("payload"))
+
+	cmd := stringToCommand(replaceFragmentCmd.Command(context.Background()).String())
+	parsed, err := ParseReplaceFragmentCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, []byte("payload"), parsed.Payload)
+}
+
+func TestProtocol_DMapNames(t *testing.T) {
+	dmapNamesCmd := NewDMapNames()
+
+	cmd := stringToCommand(dmapNamesCmd.Command(context.Background()).String())
+	_, err := ParseDMapNamesCommand(cmd)
+	require.NoError(t, err)
+}
+
 func TestProtocol_UpdateRoutingTable(t *testing.T) {
 	updateRoutingTableCmd := NewUpdateRouting([]byte("payload"), 123)
 
@@ -105,3 +124,13 @@ func TestProtocol_Stats_CR(t *testing.T) {
 
 	require.True(t, parsed.CollectRuntime)
 }
+
+func TestProtocol_CompactTables(t *testing.T) {
+	compactTablesCmd := NewCompactTables(time.Minute)
+
+	cmd := stringToCommand(compactTablesCmd.Command(context.Background()).String())
+	parsed, err := ParseCompactTablesCommand(cmd)
+	require.NoError(t, err)
+
+	require.Equal(t, time.Minute, parsed.Age)
+}
@@ -0,0 +1,414 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"context"
+	"strconv"
+)
+
+// builtins maps the built-in functions to their number of arguments.
+var builtins = map[string]int{
+	"get":    1,
+	"set":    2,
+	"del":    1,
+	"exists": 1,
+	"incr":   2,
+	"error":  1,
+}
+
+type interpreter struct {
+	ctx     context.Context
+	store   Store
+	keys    []string
+	args    []string
+	allowed map[string]struct{}
+	vars    map[string]Value
+}
+
+func typeName(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case int64:
+		return "integer"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	default:
+		return "unknown"
+	}
+}
+
+func truthy(v Value) bool {
+	switch val := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return val
+	default:
+		return true
+	}
+}
+
+// toInt converts integers and the strings that contain an integer.
+func toInt(v Value) (int64, bool) {
+	switch val := v.(type) {
+	case int64:
+		return val, true
+	case string:
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func toBytes(v Value) ([]byte, bool) {
+	switch val := v.(type) {
+	case int64:
+		return strconv.AppendInt(nil, val, 10), true
+	case string:
+		return []byte(val), true
+	default:
+		return nil, false
+	}
+}
+
+// equal compares two values. An integer is equal to a string if the string contains the same integer.
+func equal(left, right Value) bool {
+	switch l := left.(type) {
+	case int64:
+		if r, ok := right.(string); ok {
+			n, ok := toInt(r)
+			return ok && n == l
+		}
+	case string:
+		if r, ok := right.(int64); ok {
+			n, ok := toInt(l)
+			return ok && n == r
+		}
+	}
+	return left == right
+}
+
+func (in *interpreter) checkContext() error {
+	select {
+	case <-in.ctx.Done():
+		return in.ctx.Err()
+	default:
+	}
+	return nil
+}
+
+// execBlock runs the statements. It returns true if a return statement is executed.
+func (in *interpreter) execBlock(stmts []stmt) (Value, bool, error) {
+	for _, s := range stmts {
+		value, returned, err := in.exec(s)
+		if err != nil {
+			return nil, false, err
+		}
+		if returned {
+			return value, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (in *interpreter) exec(s stmt) (Value, bool, error) {
+	switch st := s.(type) {
+	case *assignStmt:
+		if err := in.checkContext(); err != nil {
+			return nil, false, err
+		}
+		if _, ok := in.vars[st.name]; !ok && !st.declare {
+			return nil, false, newError(st.line, "undefined variable: %s", st.name)
+		}
+		value, err := in.eval(st.value)
+		if err != nil {
+			return nil, false, err
+		}
+		in.vars[st.name] = value
+		return nil, false, nil
+	case *ifStmt:
+		if err := in.checkContext(); err != nil {
+			return nil, false, err
+		}
+		cond, err := in.eval(st.cond)
+		if err != nil {
+			return nil, false, err
+		}
+		if truthy(cond) {
+			return in.execBlock(st.then)
+		}
+		return in.execBlock(st.els)
+	case *returnStmt:
+		if st.value == nil {
+			return nil, true, nil
+		}
+		value, err := in.eval(st.value)
+		if err != nil {
+			return nil, false, err
+		}
+		return value, true, nil
+	case *exprStmt:
+		if err := in.checkContext(); err != nil {
+			return nil, false, err
+		}
+		_, err := in.eval(st.value)
+		return nil, false, err
+	default:
+		return nil, false, newError(0, "unknown statement")
+	}
+}
+
+func (in *interpreter) eval(e expr) (Value, error) {
+	switch ex := e.(type) {
+	case *literalExpr:
+		return ex.value, nil
+	case *identExpr:
+		value, ok := in.vars[ex.name]
+		if !ok {
+			return nil, newError(ex.line, "undefined variable: %s", ex.name)
+		}
+		return value, nil
+	case *indexExpr:
+		return in.evalIndex(ex)
+	case *unaryExpr:
+		operand, err := in.eval(ex.operand)
+		if err != nil {
+			return nil, err
+		}
+		if ex.op == "!" {
+			return !truthy(operand), nil
+		}
+		n, ok := toInt(operand)
+		if !ok {
+			return nil, newError(ex.line, "cannot negate %s", typeName(operand))
+		}
+		return -n, nil
+	case *binaryExpr:
+		return in.evalBinary(ex)
+	case *callExpr:
+		return in.call(ex)
+	default:
+		return nil, newError(0, "unknown expression")
+	}
+}
+
+func (in *interpreter) evalIndex(ex *indexExpr) (Value, error) {
+	index, err := in.eval(ex.index)
+	if err != nil {
+		return nil, err
+	}
+	i, ok := toInt(index)
+	if !ok {
+		return nil, newError(ex.line, "invalid index for %s: %s", ex.target, typeName(index))
+	}
+	list := in.keys
+	if ex.target == "ARGV" {
+		list = in.args
+	}
+	if i < 1 || i > int64(len(list)) {
+		return nil, newError(ex.line, "index out of range: %s[%d]", ex.target, i)
+	}
+	return list[i-1], nil
+}
+
+func (in *interpreter) evalBinary(ex *binaryExpr) (Value, error) {
+	left, err := in.eval(ex.left)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit and return one of the operands.
+	switch ex.op {
+	case "&&":
+		if !truthy(left) {
+			return left, nil
+		}
+		return in.eval(ex.right)
+	case "||":
+		if truthy(left) {
+			return left, nil
+		}
+		return in.eval(ex.right)
+	}
+
+	right, err := in.eval(ex.right)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ex.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "+", "-":
+		l, lok := toInt(left)
+		r, rok := toInt(right)
+		if !lok || !rok {
+			return nil, newError(ex.line, "invalid operands for %s: %s and %s", ex.op, typeName(left), typeName(right))
+		}
+		if ex.op == "+" {
+			return l + r, nil
+		}
+		return l - r, nil
+	}
+
+	// Ordering operators compare the integers numerically, and the other strings lexically.
+	var cmp int
+	l, lok := toInt(left)
+	r, rok := toInt(right)
+	switch {
+	case lok && rok:
+		switch {
+		case l < r:
+			cmp = -1
+		case l > r:
+			cmp = 1
+		}
+	default:
+		ls, lok := left.(string)
+		rs, rok := right.(string)
+		if !lok || !rok {
+			return nil, newError(ex.line, "cannot compare %s with %s", typeName(left), typeName(right))
+		}
+		switch {
+		case ls < rs:
+			cmp = -1
+		case ls > rs:
+			cmp = 1
+		}
+	}
+
+	switch ex.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func (in *interpreter) keyArg(ex *callExpr, args []Value) (string, error) {
+	key, ok := args[0].(string)
+	if !ok {
+		return "", newError(ex.line, "%s: key must be a string, got %s", ex.name, typeName(args[0]))
+	}
+	if _, ok := in.allowed[key]; !ok {
+		return "", newError(ex.line, "%s: key is not declared in KEYS: %s", ex.name, key)
+	}
+	return key, nil
+}
+
+func (in *interpreter) call(ex *callExpr) (Value, error) {
+	if err := in.checkContext(); err != nil {
+		return nil, err
+	}
+
+	expected, ok := builtins[ex.name]
+	if !ok {
+		return nil, newError(ex.line, "undefined function: %s", ex.name)
+	}
+	if len(ex.args) != expected {
+		return nil, newError(ex.line, "%s takes %d arguments, got %d", ex.name, expected, len(ex.args))
+	}
+
+	args := make([]Value, 0, len(ex.args))
+	for _, arg := range ex.args {
+		value, err := in.eval(arg)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, value)
+	}
+
+	if ex.name == "error" {
+		message, ok := args[0].(string)
+		if !ok {
+			message = typeName(args[0])
+			if n, ok := args[0].(int64); ok {
+				message = strconv.FormatInt(n, 10)
+			}
+		}
+		return nil, newError(ex.line, "%s", message)
+	}
+
+	key, err := in.keyArg(ex, args)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ex.name {
+	case "get":
+		value, ok, err := in.store.Get(in.ctx, key)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return string(value), nil
+	case "set":
+		value, ok := toBytes(args[1])
+		if !ok {
+			return nil, newError(ex.line, "set: value must be a string or an integer, got %s", typeName(args[1]))
+		}
+		return nil, in.store.Set(in.ctx, key, value)
+	case "del":
+		deleted, err := in.store.Delete(in.ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if deleted {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case "exists":
+		_, ok, err := in.store.Get(in.ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		return ok, nil
+	default: // incr
+		delta, ok := toInt(args[1])
+		if !ok {
+			return nil, newError(ex.line, "incr: delta must be an integer, got %s", typeName(args[1]))
+		}
+		raw, ok, err := in.store.Get(in.ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		var current int64
+		if ok {
+			current, ok = toInt(string(raw))
+			if !ok {
+				return nil, newError(ex.line, "incr: value is not an integer: %s", key)
+			}
+		}
+		current += delta
+		value, _ := toBytes(current)
+		if err = in.store.Set(in.ctx, key, value); err != nil {
+			return nil, err
+		}
+		return current, nil
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenInt
+	tokenString
+	tokenPunct
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+// twoCharPuncts is the list of the operators with two characters. They have to be
+// checked before the single character ones.
+var twoCharPuncts = []string{"==", "!=", "<=", ">=", "&&", "||"}
+
+const singleCharPuncts = "(){}[],;=<>+-!"
+
+func isLetter(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// tokenize splits the source into tokens. Comments start with '#' and run until the end of the line.
+func tokenize(src string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case isLetter(c):
+			start := i
+			for i < len(src) && (isLetter(src[i]) || isDigit(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, value: src[start:i], line: line})
+		case isDigit(c):
+			start := i
+			for i < len(src) && isDigit(src[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenInt, value: src[start:i], line: line})
+		case c == '"' || c == '\'':
+			value, n, err := readString(src[i:], line)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokenString, value: value, line: line})
+			i += n
+		default:
+			matched := false
+			for _, punct := range twoCharPuncts {
+				if strings.HasPrefix(src[i:], punct) {
+					tokens = append(tokens, token{kind: tokenPunct, value: punct, line: line})
+					i += len(punct)
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if strings.IndexByte(singleCharPuncts, c) >= 0 {
+				tokens = append(tokens, token{kind: tokenPunct, value: string(c), line: line})
+				i++
+				continue
+			}
+			return nil, newError(line, "unexpected character %q", c)
+		}
+	}
+	tokens = append(tokens, token{kind: tokenEOF, line: line})
+	return tokens, nil
+}
+
+// readString reads a quoted string literal. It returns the unquoted value and the number of bytes consumed.
+func readString(src string, line int) (string, int, error) {
+	quote := src[0]
+	var sb strings.Builder
+	for i := 1; i < len(src); i++ {
+		c := src[i]
+		switch c {
+		case quote:
+			return sb.String(), i + 1, nil
+		case '\n':
+			return "", 0, newError(line, "unterminated string")
+		case '\\':
+			i++
+			if i >= len(src) {
+				return "", 0, newError(line, "unterminated string")
+			}
+			switch src[i] {
+			case 'n':
+				sb.WriteByte('\n')
+			case 't':
+				sb.WriteByte('\t')
+			case '\\', '"', '\'':
+				sb.WriteByte(src[i])
+			default:
+				return "", 0, newError(line, "invalid escape sequence: \\%c", src[i])
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", 0, newError(line, "unterminated string")
+}
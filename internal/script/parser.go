@@ -0,0 +1,430 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"strconv"
+)
+
+// maxNestingDepth is the maximum depth of nested blocks and expressions.
+const maxNestingDepth = 64
+
+type stmt interface{}
+
+type expr interface{}
+
+type assignStmt struct {
+	line    int
+	name    string
+	declare bool
+	value   expr
+}
+
+type ifStmt struct {
+	line int
+	cond expr
+	then []stmt
+	els  []stmt
+}
+
+type returnStmt struct {
+	line  int
+	value expr
+}
+
+type exprStmt struct {
+	line  int
+	value expr
+}
+
+type literalExpr struct {
+	value Value
+}
+
+type identExpr struct {
+	line int
+	name string
+}
+
+type indexExpr struct {
+	line   int
+	target string
+	index  expr
+}
+
+type callExpr struct {
+	line int
+	name string
+	args []expr
+}
+
+type unaryExpr struct {
+	line    int
+	op      string
+	operand expr
+}
+
+type binaryExpr struct {
+	line  int
+	op    string
+	left  expr
+	right expr
+}
+
+var keywords = map[string]struct{}{
+	"let":    {},
+	"if":     {},
+	"else":   {},
+	"return": {},
+	"nil":    {},
+	"true":   {},
+	"false":  {},
+	"KEYS":   {},
+	"ARGV":   {},
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+	depth  int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isPunct(value string) bool {
+	t := p.peek()
+	return t.kind == tokenPunct && t.value == value
+}
+
+func (p *parser) isKeyword(value string) bool {
+	t := p.peek()
+	return t.kind == tokenIdent && t.value == value
+}
+
+// skipSemicolons skips the optional statement separators.
+func (p *parser) skipSemicolons() {
+	for p.isPunct(";") {
+		p.next()
+	}
+}
+
+func (p *parser) expect(value string) error {
+	t := p.next()
+	if t.kind != tokenPunct || t.value != value {
+		return newError(t.line, "expected %q, got %s", value, describe(t))
+	}
+	return nil
+}
+
+func (p *parser) enter(line int) error {
+	p.depth++
+	if p.depth > maxNestingDepth {
+		return newError(line, "maximum nesting depth exceeded")
+	}
+	return nil
+}
+
+func (p *parser) leave() {
+	p.depth--
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of script"
+	case tokenString:
+		return strconv.Quote(t.value)
+	default:
+		return "'" + t.value + "'"
+	}
+}
+
+func parse(tokens []token) ([]stmt, error) {
+	p := &parser{tokens: tokens}
+	var stmts []stmt
+	for p.skipSemicolons(); p.peek().kind != tokenEOF; p.skipSemicolons() {
+		s, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+func (p *parser) parseBlock() ([]stmt, error) {
+	t := p.peek()
+	if err := p.enter(t.line); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []stmt
+	for p.skipSemicolons(); !p.isPunct("}"); p.skipSemicolons() {
+		if p.peek().kind == tokenEOF {
+			return nil, newError(p.peek().line, "expected '}', got end of script")
+		}
+		s, err := p.parseStmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	p.next()
+	return stmts, nil
+}
+
+func (p *parser) parseStmt() (stmt, error) {
+	t := p.peek()
+	switch {
+	case p.isKeyword("let"):
+		p.next()
+		name := p.next()
+		if name.kind != tokenIdent {
+			return nil, newError(name.line, "expected a variable name, got %s", describe(name))
+		}
+		if _, ok := keywords[name.value]; ok {
+			return nil, newError(name.line, "%s is a reserved word", name.value)
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &assignStmt{line: t.line, name: name.value, declare: true, value: value}, nil
+	case p.isKeyword("if"):
+		return p.parseIf()
+	case p.isKeyword("return"):
+		p.next()
+		if p.isPunct("}") || p.isPunct(";") || p.peek().kind == tokenEOF {
+			return &returnStmt{line: t.line}, nil
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &returnStmt{line: t.line, value: value}, nil
+	case t.kind == tokenIdent && p.tokens[p.pos+1].kind == tokenPunct && p.tokens[p.pos+1].value == "=":
+		if _, ok := keywords[t.value]; ok {
+			return nil, newError(t.line, "cannot assign to %s", t.value)
+		}
+		p.next()
+		p.next()
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		return &assignStmt{line: t.line, name: t.value, value: value}, nil
+	}
+
+	value, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(*callExpr); !ok {
+		return nil, newError(t.line, "expression is not a statement")
+	}
+	return &exprStmt{line: t.line, value: value}, nil
+}
+
+func (p *parser) parseIf() (stmt, error) {
+	t := p.next()
+	if err := p.enter(t.line); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	then, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	s := &ifStmt{line: t.line, cond: cond, then: then}
+	if !p.isKeyword("else") {
+		return s, nil
+	}
+	p.next()
+	if p.isKeyword("if") {
+		elseIf, err := p.parseIf()
+		if err != nil {
+			return nil, err
+		}
+		s.els = []stmt{elseIf}
+		return s, nil
+	}
+	s.els, err = p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (p *parser) parseExpr() (expr, error) {
+	t := p.peek()
+	if err := p.enter(t.line); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+
+	return p.parseOr()
+}
+
+func (p *parser) parseBinary(ops []string, operand func() (expr, error)) (expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		matched := false
+		for _, op := range ops {
+			if t.kind == tokenPunct && t.value == op {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return left, nil
+		}
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{line: t.line, op: t.value, left: left, right: right}
+	}
+}
+
+func (p *parser) parseOr() (expr, error) {
+	return p.parseBinary([]string{"||"}, p.parseAnd)
+}
+
+func (p *parser) parseAnd() (expr, error) {
+	return p.parseBinary([]string{"&&"}, p.parseComparison)
+}
+
+func (p *parser) parseComparison() (expr, error) {
+	return p.parseBinary([]string{"==", "!=", "<", "<=", ">", ">="}, p.parseAdditive)
+}
+
+func (p *parser) parseAdditive() (expr, error) {
+	return p.parseBinary([]string{"+", "-"}, p.parseUnary)
+}
+
+func (p *parser) parseUnary() (expr, error) {
+	t := p.peek()
+	if p.isPunct("!") || p.isPunct("-") {
+		if err := p.enter(t.line); err != nil {
+			return nil, err
+		}
+		defer p.leave()
+
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{line: t.line, op: t.value, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenInt:
+		n, err := strconv.ParseInt(t.value, 10, 64)
+		if err != nil {
+			return nil, newError(t.line, "invalid integer: %s", t.value)
+		}
+		return &literalExpr{value: n}, nil
+	case tokenString:
+		return &literalExpr{value: t.value}, nil
+	case tokenPunct:
+		if t.value != "(" {
+			break
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(")"); err != nil {
+			return nil, err
+		}
+		return value, nil
+	case tokenIdent:
+		switch t.value {
+		case "nil":
+			return &literalExpr{value: nil}, nil
+		case "true":
+			return &literalExpr{value: true}, nil
+		case "false":
+			return &literalExpr{value: false}, nil
+		case "KEYS", "ARGV":
+			if err := p.expect("["); err != nil {
+				return nil, err
+			}
+			index, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect("]"); err != nil {
+				return nil, err
+			}
+			return &indexExpr{line: t.line, target: t.value, index: index}, nil
+		}
+		if _, ok := keywords[t.value]; ok {
+			break
+		}
+		if !p.isPunct("(") {
+			return &identExpr{line: t.line, name: t.value}, nil
+		}
+		p.next()
+		call := &callExpr{line: t.line, name: t.value}
+		for !p.isPunct(")") {
+			if len(call.args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+		}
+		p.next()
+		return call, nil
+	}
+	return nil, newError(t.line, "unexpected %s", describe(t))
+}
@@ -0,0 +1,129 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package script implements a small, safe scripting language to run atomic
+operations on a set of keys.
+
+The language has no loops and no function definitions, so the execution time is bounded by the
+length of the script. The keys and the arguments are passed with KEYS[n] and ARGV[n], starting
+from 1. A script can only access the keys given in KEYS.
+
+	# Move a value between two keys if the source has the expected value.
+	let current = get(KEYS[1])
+	if current == ARGV[1] {
+		set(KEYS[2], current)
+		del(KEYS[1])
+		return 1
+	}
+	return 0
+
+Values are nil, integers, strings and booleans. nil and false are falsy, all other values are truthy.
+The stored values are read as strings, the arithmetic and ordering operators convert the strings
+to integers if it's possible.
+
+Built-in functions:
+
+	get(key)          returns the value or nil
+	set(key, value)   sets the value
+	del(key)          deletes the key, returns 1 if the key existed, otherwise 0
+	exists(key)       returns true if the key exists
+	incr(key, delta)  increments the integer value by delta and returns the new value
+	error(message)    aborts the script with the given message
+*/
+package script
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxScriptLength is the maximum length of a script in bytes.
+const MaxScriptLength = 16 << 10
+
+// Value is a script value: nil, int64, string or bool.
+type Value interface{}
+
+// Store is the storage that a script operates on.
+type Store interface {
+	// Get returns the value of the key. It returns false if the key does not exist.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set sets the value of the key.
+	Set(ctx context.Context, key string, value []byte) error
+
+	// Delete deletes the key. It returns false if the key does not exist.
+	Delete(ctx context.Context, key string) (bool, error)
+}
+
+// Error is a compile or runtime error of a script.
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+func newError(line int, format string, args ...interface{}) *Error {
+	return &Error{
+		Line:    line,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// Script is a compiled script. It's safe to run a Script concurrently.
+type Script struct {
+	stmts []stmt
+}
+
+// Compile parses the source and returns a Script.
+func Compile(src string) (*Script, error) {
+	if len(src) > MaxScriptLength {
+		return nil, newError(0, "script is longer than %d bytes", MaxScriptLength)
+	}
+	tokens, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	stmts, err := parse(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{stmts: stmts}, nil
+}
+
+// Run runs the script on the given store. keys is the list of the keys that the script
+// can access. The return value is the value of the return statement, or nil. Run checks
+// the context before every statement and function call, it returns the context's error
+// if the context is done.
+func (s *Script) Run(ctx context.Context, store Store, keys, args []string) (Value, error) {
+	in := &interpreter{
+		ctx:     ctx,
+		store:   store,
+		keys:    keys,
+		args:    args,
+		allowed: make(map[string]struct{}),
+		vars:    make(map[string]Value),
+	}
+	for _, key := range keys {
+		in.allowed[key] = struct{}{}
+	}
+	value, _, err := in.execBlock(s.stmts)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package script
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mapStore map[string][]byte
+
+func (m mapStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	value, ok := m[key]
+	return value, ok, nil
+}
+
+func (m mapStore) Set(_ context.Context, key string, value []byte) error {
+	m[key] = value
+	return nil
+}
+
+func (m mapStore) Delete(_ context.Context, key string) (bool, error) {
+	_, ok := m[key]
+	delete(m, key)
+	return ok, nil
+}
+
+func run(t *testing.T, src string, store mapStore, keys, args []string) (Value, error) {
+	s, err := Compile(src)
+	require.NoError(t, err)
+	return s.Run(context.Background(), store, keys, args)
+}
+
+func TestScript_Run(t *testing.T) {
+	src := `
+# Transfer ARGV[1] units from KEYS[1] to KEYS[2], if the balance is enough.
+let balance = get(KEYS[1])
+if balance == nil || balance < ARGV[1] {
+	return 0
+}
+set(KEYS[1], balance - ARGV[1])
+incr(KEYS[2], ARGV[1])
+return 1
+`
+	store := mapStore{"from": []byte("100")}
+
+	value, err := run(t, src, store, []string{"from", "to"}, []string{"30"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), value)
+	require.Equal(t, "70", string(store["from"]))
+	require.Equal(t, "30", string(store["to"]))
+
+	value, err = run(t, src, store, []string{"from", "to"}, []string{"80"})
+	require.NoError(t, err)
+	require.Equal(t, int64(0), value)
+	require.Equal(t, "70", string(store["from"]))
+	require.Equal(t, "30", string(store["to"]))
+}
+
+func TestScript_Values(t *testing.T) {
+	tests := []struct {
+		src      string
+		expected Value
+	}{
+		{src: `return 1 + 2 - 4`, expected: int64(-1)},
+		{src: `return -(1 + 2)`, expected: int64(-3)},
+		{src: `return "foo" == 'foo'`, expected: true},
+		{src: `return "10" == 10`, expected: true},
+		{src: `return "9" < "10"`, expected: true},
+		{src: `return "b" > "a"`, expected: true},
+		{src: `return nil || "default"`, expected: "default"},
+		{src: `return !nil && true`, expected: true},
+		{src: `let x = 1; x = x + 1; return x`, expected: int64(2)},
+		{src: `if false { return 1 } else if true { return 2 } else { return 3 }`, expected: int64(2)},
+		{src: `return ARGV[1]`, expected: "arg"},
+		{src: `return`, expected: nil},
+		{src: `return exists(KEYS[1])`, expected: false},
+		{src: `set(KEYS[1], "a"); return del(KEYS[1]) + del(KEYS[1])`, expected: int64(1)},
+		{src: ``, expected: nil},
+	}
+	for _, test := range tests {
+		value, err := run(t, test.src, mapStore{}, []string{"key"}, []string{"arg"})
+		require.NoError(t, err, test.src)
+		require.Equal(t, test.expected, value, test.src)
+	}
+}
+
+func TestScript_Errors(t *testing.T) {
+	compileErrors := []string{
+		`let = 1`,
+		`return (1`,
+		`if true { return 1`,
+		`"unterminated`,
+		`1 + 2`,
+		`KEYS = 1`,
+		`@`,
+		strings.Repeat("(", maxNestingDepth+1) + "1" + strings.Repeat(")", maxNestingDepth+1),
+		strings.Repeat(" ", MaxScriptLength+1),
+	}
+	for _, src := range compileErrors {
+		_, err := Compile(src)
+		var scriptErr *Error
+		require.ErrorAs(t, err, &scriptErr, src)
+	}
+
+	runtimeErrors := []string{
+		`return x`,
+		`x = 1`,
+		`return KEYS[2]`,
+		`return get("undeclared")`,
+		`return "a" + 1`,
+		`return nil < 1`,
+		`return foo()`,
+		`return get()`,
+		`set(KEYS[1], "a"); return incr(KEYS[1], 1)`,
+		`error("aborted")`,
+	}
+	for _, src := range runtimeErrors {
+		_, err := run(t, src, mapStore{}, []string{"key"}, nil)
+		var scriptErr *Error
+		require.ErrorAs(t, err, &scriptErr, src)
+	}
+}
+
+func TestScript_ContextDone(t *testing.T) {
+	s, err := Compile(`set(KEYS[1], "value")`)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	store := mapStore{}
+	_, err = s.Run(ctx, store, []string{"key"}, nil)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Empty(t, store)
+}
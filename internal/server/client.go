@@ -16,20 +16,35 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	"github.com/buraksezer/olric/config"
 	"github.com/buraksezer/olric/internal/roundrobin"
 	"github.com/redis/go-redis/v9"
 )
 
+// ErrConnBudgetExhausted is returned when the number of connections reaches
+// config.Client.MaxTotalConns and no member has an idle connection to close.
+var ErrConnBudgetExhausted = errors.New("connection budget exhausted")
+
 type Client struct {
 	mu sync.RWMutex
 
 	config     *config.Client
 	clients    map[string]*redis.Client
 	roundRobin *roundrobin.RoundRobin
+
+	// conns is the number of open connections to all members, and lastUsed keeps
+	// the last time a member's client is used in nanoseconds. They are only
+	// maintained if MaxTotalConns is set.
+	conns    int64
+	lastUsed map[string]*int64
 }
 
 func NewClient(c *config.Client) *Client {
@@ -44,6 +59,7 @@ func NewClient(c *config.Client) *Client {
 		config:     c,
 		clients:    make(map[string]*redis.Client),
 		roundRobin: roundrobin.New(nil),
+		lastUsed:   make(map[string]*int64),
 	}
 }
 
@@ -62,6 +78,7 @@ func (c *Client) Get(addr string) *redis.Client {
 	c.mu.RLock()
 	rc, ok := c.clients[addr]
 	if ok {
+		c.touch(addr)
 		c.mu.RUnlock()
 		return rc
 	}
@@ -75,17 +92,128 @@ func (c *Client) Get(addr string) *redis.Client {
 	// Need to check again, because another goroutine may have updated clients
 	// between our calls to RUnlock and Lock.
 	if rc, ok = c.clients[addr]; ok {
+		c.touch(addr)
 		return rc
 	}
 
-	opt := c.config.RedisOptions()
-	opt.Addr = addr
-	rc = redis.NewClient(opt)
+	rc = c.newRedisClient(addr)
 	c.clients[addr] = rc
 	c.roundRobin.Add(addr)
+	if c.config.MaxTotalConns > 0 {
+		c.lastUsed[addr] = new(int64)
+		c.touch(addr)
+	}
 	return rc
 }
 
+func (c *Client) newRedisClient(addr string) *redis.Client {
+	opt := c.config.RedisOptions()
+	opt.Addr = addr
+	if c.config.MaxTotalConns > 0 {
+		dial := opt.Dialer
+		opt.Dialer = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return c.dialWithinBudget(ctx, dial, network, addr)
+		}
+	}
+	return redis.NewClient(opt)
+}
+
+// touch records the usage of the member's client. The caller must hold the lock.
+func (c *Client) touch(addr string) {
+	if lastUsed, ok := c.lastUsed[addr]; ok {
+		atomic.StoreInt64(lastUsed, time.Now().UnixNano())
+	}
+}
+
+// dialWithinBudget opens a new connection if the total number of connections is below
+// MaxTotalConns. Otherwise, it closes the idle connections to the least recently used
+// member to make room for the new connection.
+func (c *Client) dialWithinBudget(ctx context.Context,
+	dial func(context.Context, string, string) (net.Conn, error), network, addr string) (net.Conn, error) {
+	for {
+		conns := atomic.LoadInt64(&c.conns)
+		if conns < int64(c.config.MaxTotalConns) {
+			if atomic.CompareAndSwapInt64(&c.conns, conns, conns+1) {
+				break
+			}
+			continue
+		}
+		if !c.evictIdleMember(addr) {
+			return nil, ErrConnBudgetExhausted
+		}
+	}
+
+	conn, err := dial(ctx, network, addr)
+	if err != nil {
+		atomic.AddInt64(&c.conns, -1)
+		return nil, err
+	}
+	bc := &budgetConn{
+		Conn:    conn,
+		release: func() { atomic.AddInt64(&c.conns, -1) },
+	}
+	if _, ok := conn.(syscall.Conn); ok {
+		// Keep the health check of the pooled connections working.
+		return &budgetSyscallConn{bc}, nil
+	}
+	return bc, nil
+}
+
+// evictIdleMember closes the connections to the least recently used member except
+// the given one. The members with in-use connections are skipped. The closed client
+// is replaced with a new one, it connects again on the next request. It returns false
+// if there is no idle member.
+func (c *Client) evictIdleMember(except string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var lru string
+	var lruTime int64
+	for addr, rc := range c.clients {
+		if addr == except {
+			continue
+		}
+		s := rc.PoolStats()
+		if s.TotalConns == 0 || s.IdleConns != s.TotalConns {
+			continue
+		}
+		lastUsed := atomic.LoadInt64(c.lastUsed[addr])
+		if lru == "" || lastUsed < lruTime {
+			lru, lruTime = addr, lastUsed
+		}
+	}
+	if lru == "" {
+		return false
+	}
+
+	// Closing the client closes its connections, they are released from the budget.
+	_ = c.clients[lru].Close()
+	c.clients[lru] = c.newRedisClient(lru)
+	return true
+}
+
+// budgetConn releases its slot in the connection budget when it's closed.
+type budgetConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (b *budgetConn) Close() error {
+	b.once.Do(b.release)
+	return b.Conn.Close()
+}
+
+// budgetSyscallConn exposes the underlying file descriptor, go-redis uses it to
+// check the health of the idle connections.
+type budgetSyscallConn struct {
+	*budgetConn
+}
+
+func (b *budgetSyscallConn) SyscallConn() (syscall.RawConn, error) {
+	return b.Conn.(syscall.Conn).SyscallConn()
+}
+
 func (c *Client) pickNodeRoundRobin() (string, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -120,6 +248,7 @@ func (c *Client) Close(addr string) error {
 		}
 		c.roundRobin.Delete(addr)
 		delete(c.clients, addr)
+		delete(c.lastUsed, addr)
 	}
 
 	return nil
@@ -140,6 +269,7 @@ func (c *Client) Shutdown(ctx context.Context) error {
 			return err
 		}
 		delete(c.clients, addr)
+		delete(c.lastUsed, addr)
 		c.roundRobin.Delete(addr)
 	}
 
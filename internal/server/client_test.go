@@ -18,6 +18,7 @@ import (
 	"context"
 	"net"
 	"strconv"
+	"sync/atomic"
 	"testing"
 
 	"github.com/buraksezer/olric/config"
@@ -96,6 +97,54 @@ func TestServer_Client_Pick(t *testing.T) {
 	require.Greater(t, len(clients), 1)
 }
 
+func TestServer_Client_MaxTotalConns(t *testing.T) {
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		srv := newServer(t)
+		srv.ServeMux().HandleFunc(protocol.Generic.Ping, func(conn redcon.Conn, cmd redcon.Command) {
+			conn.WriteBulkString("pong")
+		})
+		<-srv.StartedCtx.Done()
+		addrs = append(addrs, net.JoinHostPort(srv.config.BindAddr, strconv.Itoa(srv.config.BindPort)))
+	}
+
+	c := config.NewClient()
+	c.MaxTotalConns = 2
+	require.NoError(t, c.Sanitize())
+
+	cs := NewClient(c)
+	defer func() {
+		require.NoError(t, cs.Shutdown(context.Background()))
+	}()
+
+	totalConns := func() int {
+		cs.mu.RLock()
+		defer cs.mu.RUnlock()
+
+		var total int
+		for _, rc := range cs.clients {
+			total += int(rc.PoolStats().TotalConns)
+		}
+		return total
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		for _, addr := range addrs {
+			cmd := protocol.NewPing().Command(ctx)
+			err := cs.Get(addr).Process(ctx, cmd)
+			require.NoError(t, err)
+
+			result, err := cmd.Result()
+			require.NoError(t, err)
+			require.Equal(t, "pong", result)
+
+			require.LessOrEqual(t, totalConns(), c.MaxTotalConns)
+			require.LessOrEqual(t, atomic.LoadInt64(&cs.conns), int64(c.MaxTotalConns))
+		}
+	}
+}
+
 func TestServer_Client_Close(t *testing.T) {
 	srv := newServer(t)
 
@@ -0,0 +1,164 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/tidwall/redcon"
+)
+
+// connState tracks whether a connection runs a command, so a draining server can close
+// the connection once it's idle.
+type connState struct {
+	mtx    sync.Mutex
+	conn   redcon.Conn
+	busy   bool
+	closed bool
+}
+
+// begin marks the connection as busy. It returns false if the connection is closed by
+// the draining server, the command shouldn't run in that case.
+func (c *connState) begin() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.closed {
+		return false
+	}
+	c.busy = true
+	return true
+}
+
+// end marks the connection as idle. The connection is closed after the response is
+// flushed if the server is draining.
+func (c *connState) end(draining bool) {
+	c.mtx.Lock()
+	c.busy = false
+	if !draining || c.closed {
+		c.mtx.Unlock()
+		return
+	}
+	c.closed = true
+	c.mtx.Unlock()
+
+	// Close flushes the pending responses. It's called on the connection's own goroutine.
+	_ = c.conn.Close()
+}
+
+// closeIfIdle closes an idle connection. A busy connection is closed by end.
+func (c *connState) closeIfIdle() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.busy || c.closed {
+		return
+	}
+	c.closed = true
+	// The connection's goroutine is blocked on read, close the underlying connection
+	// to wake it up.
+	_ = c.conn.NetConn().Close()
+}
+
+// connTracker keeps the states of the open client connections.
+type connTracker struct {
+	mtx      sync.Mutex
+	draining bool
+	conns    map[redcon.Conn]*connState
+	drained  chan struct{}
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{
+		conns:   make(map[redcon.Conn]*connState),
+		drained: make(chan struct{}),
+	}
+}
+
+// add starts tracking a new connection. It returns false if the server is draining,
+// the connection should be rejected.
+func (t *connTracker) add(conn redcon.Conn) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.draining {
+		return false
+	}
+	t.conns[conn] = &connState{conn: conn}
+	return true
+}
+
+func (t *connTracker) remove(conn redcon.Conn) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if _, ok := t.conns[conn]; !ok {
+		return
+	}
+	delete(t.conns, conn)
+	if t.draining && len(t.conns) == 0 {
+		close(t.drained)
+	}
+}
+
+func (t *connTracker) get(conn redcon.Conn) *connState {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.conns[conn]
+}
+
+func (t *connTracker) isDraining() bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.draining
+}
+
+// Drain closes the client connections gracefully. The new connections are rejected,
+// the idle connections are closed immediately and the busy connections are closed once
+// their in-flight commands complete and the responses are sent. The clients reconnect
+// to another member on their next command. Drain returns when all the connections are
+// closed, or returns the context's error if the context expires before. The remaining
+// connections are closed by Shutdown.
+func (s *Server) Drain(ctx context.Context) error {
+	t := s.conns
+	t.mtx.Lock()
+	if t.draining {
+		t.mtx.Unlock()
+		return nil
+	}
+	t.draining = true
+	if len(t.conns) == 0 {
+		close(t.drained)
+	}
+	states := make([]*connState, 0, len(t.conns))
+	for _, c := range t.conns {
+		states = append(states, c)
+	}
+	t.mtx.Unlock()
+
+	for _, c := range states {
+		c.closeIfIdle()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.drained:
+		return nil
+	}
+}
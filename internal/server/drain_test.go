@@ -0,0 +1,91 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/redcon"
+)
+
+func TestServer_Drain(t *testing.T) {
+	s := newServer(t)
+
+	started := make(chan struct{})
+	s.ServeMux().HandleFunc(protocol.DMap.Get, func(conn redcon.Conn, cmd redcon.Command) {
+		close(started)
+		time.Sleep(250 * time.Millisecond)
+		conn.WriteBulkString("value")
+	})
+	s.ServeMux().HandleFunc(protocol.Generic.Ping, func(conn redcon.Conn, cmd redcon.Command) {
+		conn.WriteString("PONG")
+	})
+	<-s.StartedCtx.Done()
+
+	ctx := context.Background()
+	newClient := func() *redis.Client {
+		opts := defaultRedisOptions(s.config)
+		opts.MaxRetries = -1
+		rdb := redis.NewClient(opts)
+		t.Cleanup(func() {
+			_ = rdb.Close()
+		})
+		return rdb
+	}
+
+	// An idle connection.
+	idle := newClient()
+	require.NoError(t, idle.Process(ctx, protocol.NewPing().Command(ctx)))
+
+	// A connection with an in-flight command.
+	busy := newClient()
+	result := make(chan error, 1)
+	go func() {
+		cmd := protocol.NewGet("mydmap", "mykey").Command(ctx)
+		if err := busy.Process(ctx, cmd); err != nil {
+			result <- err
+			return
+		}
+		value, err := cmd.Result()
+		if err == nil && value != "value" {
+			err = fmt.Errorf("unexpected value: %s", value)
+		}
+		result <- err
+	}()
+	<-started
+
+	drainCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, s.Drain(drainCtx))
+
+	// Drain returns after the response of the in-flight command is sent and its
+	// connection is closed.
+	select {
+	case err := <-result:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		require.Fail(t, "in-flight command is not completed")
+	}
+
+	// The idle connection is closed and the new connections are rejected.
+	require.Error(t, idle.Process(ctx, protocol.NewPing().Command(ctx)))
+	require.Error(t, newClient().Process(ctx, protocol.NewPing().Command(ctx)))
+}
@@ -0,0 +1,124 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/util"
+	"github.com/buraksezer/olric/pkg/flog"
+	"github.com/tidwall/redcon"
+)
+
+const (
+	// maxLoggedArgs is the maximum number of command arguments included in an error log entry.
+	// The first two arguments are the DMap name and the key for the DMap commands.
+	maxLoggedArgs = 2
+
+	// maxLoggedArgLength is the maximum length of a command argument in an error log entry.
+	maxLoggedArgLength = 64
+)
+
+// errorLogger logs the error responses returned to the clients. It logs at most
+// rate entries per second, the rest is counted and reported in the next window.
+type errorLogger struct {
+	mtx         sync.Mutex
+	log         *flog.Logger
+	verbosity   int32
+	rate        int
+	windowStart time.Time
+	logged      int
+	suppressed  int
+}
+
+func newErrorLogger(l *flog.Logger, verbosity int32, rate int) *errorLogger {
+	return &errorLogger{
+		log:       l,
+		verbosity: verbosity,
+		rate:      rate,
+	}
+}
+
+// allow reports whether an error response can be logged in the current window. It also
+// returns the number of suppressed entries in the previous window, if the window is over.
+func (e *errorLogger) allow(now time.Time) (bool, int) {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	var suppressed int
+	if now.Sub(e.windowStart) >= time.Second {
+		suppressed = e.suppressed
+		e.windowStart = now
+		e.logged = 0
+		e.suppressed = 0
+	}
+
+	if e.rate > 0 && e.logged >= e.rate {
+		e.suppressed++
+		return false, suppressed
+	}
+	e.logged++
+	return true, suppressed
+}
+
+func formatArgs(args [][]byte) string {
+	if len(args) > maxLoggedArgs {
+		args = args[:maxLoggedArgs]
+	}
+	formatted := make([]string, 0, len(args))
+	for _, arg := range args {
+		if len(arg) > maxLoggedArgLength {
+			arg = arg[:maxLoggedArgLength]
+		}
+		formatted = append(formatted, util.BytesToString(arg))
+	}
+	return strings.Join(formatted, " ")
+}
+
+func (e *errorLogger) logError(conn redcon.Conn, cmd redcon.Command, msg string) {
+	v := e.log.V(e.verbosity)
+	if !v.Ok() {
+		return
+	}
+
+	ok, suppressed := e.allow(time.Now())
+	if suppressed > 0 {
+		v.Printf("[INFO] %d error responses have not been logged due to rate limiting", suppressed)
+	}
+	if !ok {
+		return
+	}
+
+	var command, args string
+	if len(cmd.Args) > 0 {
+		command = strings.ToLower(util.BytesToString(cmd.Args[0]))
+		args = formatArgs(cmd.Args[1:])
+	}
+	v.Printf("[INFO] Error response to %s: command: %s, args: [%s], error: %s", conn.RemoteAddr(), command, args, msg)
+}
+
+// errorLoggingConn wraps a redcon.Conn to log the error responses.
+type errorLoggingConn struct {
+	redcon.Conn
+	cmd    redcon.Command
+	logger *errorLogger
+}
+
+func (c *errorLoggingConn) WriteError(msg string) {
+	c.logger.logError(c.Conn, c.cmd, msg)
+	c.Conn.WriteError(msg)
+}
@@ -0,0 +1,109 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/internal/protocol"
+	"github.com/buraksezer/olric/pkg/flog"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/redcon"
+)
+
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.buf.String()
+}
+
+func TestServer_LogErrorResponses(t *testing.T) {
+	bindPort, err := getFreePort()
+	require.NoError(t, err)
+
+	output := &syncBuffer{}
+	fl := flog.New(log.New(output, "server-test: ", log.LstdFlags))
+	fl.SetLevel(3)
+	c := &Config{
+		BindAddr:                  "127.0.0.1",
+		BindPort:                  bindPort,
+		KeepAlivePeriod:           time.Second,
+		LogErrorResponses:         true,
+		ErrorResponseLogVerbosity: 3,
+		ErrorResponseLogRate:      100,
+	}
+	s := New(c, fl)
+	s.ServeMux().HandleFunc(protocol.DMap.Put, func(conn redcon.Conn, cmd redcon.Command) {
+		conn.WriteError("KEYFOUND key found")
+	})
+
+	go func() {
+		err := s.ListenAndServe()
+		if err != nil {
+			t.Errorf("Expected nil. Got: %v", err)
+		}
+	}()
+	defer func() {
+		require.NoError(t, s.Shutdown(context.Background()))
+	}()
+	<-s.StartedCtx.Done()
+
+	rdb := redis.NewClient(defaultRedisOptions(c))
+	defer func() {
+		require.NoError(t, rdb.Close())
+	}()
+
+	ctx := context.Background()
+	cmd := protocol.NewPut("mydmap", "mykey", []byte("myvalue")).SetNX().Command(ctx)
+	err = rdb.Process(ctx, cmd)
+	require.Error(t, err)
+
+	require.Contains(t, output.String(), "command: dm.put, args: [mydmap mykey], error: KEYFOUND key found")
+}
+
+func TestServer_errorLogger_RateLimit(t *testing.T) {
+	e := newErrorLogger(nil, 3, 2)
+
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		ok, _ := e.allow(now)
+		require.True(t, ok)
+	}
+	for i := 0; i < 3; i++ {
+		ok, _ := e.allow(now)
+		require.False(t, ok)
+	}
+
+	ok, suppressed := e.allow(now.Add(time.Second))
+	require.True(t, ok)
+	require.Equal(t, 3, suppressed)
+}
@@ -16,6 +16,7 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
 	"strconv"
 	"sync"
@@ -50,6 +51,16 @@ type Config struct {
 	BindPort        int
 	KeepAlivePeriod time.Duration
 	IdleClose       time.Duration
+
+	// TLSConfig enables TLS on the listener, if it's set.
+	TLSConfig *tls.Config
+
+	// LogErrorResponses enables logging of the error responses returned to the clients.
+	// The entries are logged at ErrorResponseLogVerbosity, at most ErrorResponseLogRate
+	// entries per second.
+	LogErrorResponses         bool
+	ErrorResponseLogVerbosity int32
+	ErrorResponseLogRate      int
 }
 
 type ConnWrapper struct {
@@ -103,9 +114,12 @@ type Server struct {
 	config     *Config
 	mux        *ServeMux
 	wmux       *ServeMuxWrapper
+	errorLog   *errorLogger
 	server     *redcon.Server
 	log        *flog.Logger
 	listener   *ListenerWrapper
+	preBound   net.Listener
+	conns      *connTracker
 	StartedCtx context.Context
 	started    context.CancelFunc
 	ctx        context.Context
@@ -129,10 +143,14 @@ func New(c *Config, l *flog.Logger) *Server {
 		started:    started,
 		StartedCtx: startedCtx,
 		stopped:    make(chan struct{}),
+		conns:      newConnTracker(),
 		ctx:        ctx,
 		cancel:     cancel,
 	}
 	s.wmux = &ServeMuxWrapper{mux: s.mux}
+	if c.LogErrorResponses {
+		s.errorLog = newErrorLogger(l, c.ErrorResponseLogVerbosity, c.ErrorResponseLogRate)
+	}
 	return s
 }
 
@@ -150,12 +168,44 @@ func (s *Server) ServeMux() *ServeMuxWrapper {
 	return s.wmux
 }
 
+func (s *Server) serveRESP(conn redcon.Conn, cmd redcon.Command) {
+	if state := s.conns.get(conn); state != nil {
+		if !state.begin() {
+			// The connection is closed by Drain.
+			return
+		}
+		defer func() {
+			state.end(s.conns.isDraining())
+		}()
+	}
+
+	if s.errorLog != nil {
+		conn = &errorLoggingConn{
+			Conn:   conn,
+			cmd:    cmd,
+			logger: s.errorLog,
+		}
+	}
+	s.mux.ServeRESP(conn, cmd)
+}
+
+// SetListener sets a listener that is already bound. ListenAndServe serves on it
+// instead of binding BindAddr:BindPort. It's useful to bind an ephemeral port before
+// starting the server.
+func (s *Server) SetListener(l net.Listener) {
+	s.preBound = l
+}
+
 // ListenAndServe listens on the TCP network address addr.
 func (s *Server) ListenAndServe() error {
 	addr := net.JoinHostPort(s.config.BindAddr, strconv.Itoa(s.config.BindPort))
-	listener, err := net.Listen("tcp", addr)
-	if err != nil {
-		return err
+	listener := s.preBound
+	if listener == nil {
+		var err error
+		listener, err = net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
 	}
 
 	lw := &ListenerWrapper{
@@ -167,13 +217,18 @@ func (s *Server) ListenAndServe() error {
 	s.listener = lw
 
 	srv := redcon.NewServer(addr,
-		s.mux.ServeRESP,
+		s.serveRESP,
 		func(conn redcon.Conn) bool {
+			if !s.conns.add(conn) {
+				// The server is draining.
+				return false
+			}
 			ConnectionsTotal.Increase(1)
 			CurrentConnections.Increase(1)
 			return true
 		},
 		func(conn redcon.Conn, err error) {
+			s.conns.remove(conn)
 			CurrentConnections.Increase(-1)
 		},
 	)
@@ -183,10 +238,16 @@ func (s *Server) ListenAndServe() error {
 	}
 	s.server = srv
 
+	// The keep-alive settings are applied to the TCP connections, under the TLS layer.
+	var ln net.Listener = lw
+	if s.config.TLSConfig != nil {
+		ln = tls.NewListener(lw, s.config.TLSConfig)
+	}
+
 	// The TCP server has been started
 	s.started()
 	checkpoint.Pass()
-	return s.server.Serve(lw)
+	return s.server.Serve(ln)
 }
 
 // Shutdown gracefully shuts down the server without interrupting any active connections.
@@ -206,7 +267,10 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	s.cancel()
 
 	if s.server == nil {
-		// There is nothing to close.
+		// There is nothing to close, except a listener that is never served.
+		if s.preBound != nil {
+			return s.preBound.Close()
+		}
 		return nil
 	}
 
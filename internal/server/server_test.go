@@ -16,13 +16,21 @@ package server
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"log"
+	"math/big"
 	"net"
 	"os"
 	"strconv"
 	"testing"
 	"time"
 
+	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/pkg/flog"
 	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
@@ -113,3 +121,95 @@ func TestServer_RESP_Stats(t *testing.T) {
 	require.NotEqual(t, int64(0), WrittenBytesTotal.Read())
 	require.NotEqual(t, int64(0), ReadBytesTotal.Read())
 }
+
+// newTestCertificate creates a self-signed certificate for 127.0.0.1 that is valid
+// for both server and client authentication.
+func newTestCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "olric-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+func TestServer_TLS(t *testing.T) {
+	bindPort, err := getFreePort()
+	require.NoError(t, err)
+
+	cert, pool := newTestCertificate(t)
+	c := &Config{
+		BindAddr:        "127.0.0.1",
+		BindPort:        bindPort,
+		KeepAlivePeriod: time.Second,
+		TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			ClientCAs:    pool,
+			ClientAuth:   tls.RequireAndVerifyClientCert,
+		},
+	}
+	s := New(c, flog.New(log.New(os.Stdout, "server-test: ", log.LstdFlags)))
+	s.ServeMux().HandleFunc(protocol.Generic.Ping, func(conn redcon.Conn, cmd redcon.Command) {
+		conn.WriteString("PONG")
+	})
+	go func() {
+		require.NoError(t, s.ListenAndServe())
+	}()
+	defer func() {
+		require.NoError(t, s.Shutdown(context.Background()))
+	}()
+	<-s.StartedCtx.Done()
+
+	ctx := context.Background()
+	t.Run("mTLS", func(t *testing.T) {
+		opts := defaultRedisOptions(c)
+		opts.TLSConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			RootCAs:      pool,
+		}
+		rdb := redis.NewClient(opts)
+		defer rdb.Close()
+
+		cmd := protocol.NewPing().Command(ctx)
+		require.NoError(t, rdb.Process(ctx, cmd))
+		require.Equal(t, "PONG", cmd.Val())
+	})
+
+	t.Run("Without a client certificate", func(t *testing.T) {
+		opts := defaultRedisOptions(c)
+		opts.MaxRetries = -1
+		opts.TLSConfig = &tls.Config{
+			RootCAs: pool,
+		}
+		rdb := redis.NewClient(opts)
+		defer rdb.Close()
+
+		require.Error(t, rdb.Process(ctx, protocol.NewPing().Command(ctx)))
+	})
+
+	t.Run("Without TLS", func(t *testing.T) {
+		opts := defaultRedisOptions(c)
+		opts.MaxRetries = -1
+		opts.ReadTimeout = time.Second
+		rdb := redis.NewClient(opts)
+		defer rdb.Close()
+
+		require.Error(t, rdb.Process(ctx, protocol.NewPing().Command(ctx)))
+	})
+}
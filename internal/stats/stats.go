@@ -14,7 +14,10 @@
 
 package stats
 
-import "sync/atomic"
+import (
+	"sort"
+	"sync/atomic"
+)
 
 // Int64Counter is a cumulative metric that represents a single monotonically
 // increasing counter whose value can only increase or be reset to zero on restart.
@@ -72,3 +75,60 @@ func (c *Int64Gauge) Read() int64 {
 func (c *Int64Gauge) Reset() {
 	atomic.StoreInt64(&c.gauge, 0)
 }
+
+// Int64Histogram is a metric that counts the observations in buckets. The buckets
+// are cumulative, like the buckets of a Prometheus histogram.
+type Int64Histogram struct {
+	bounds []int64
+	// counts has one more element than bounds for the observations that are
+	// greater than the largest bound.
+	counts []int64
+	count  int64
+	sum    int64
+}
+
+// NewInt64Histogram returns a new Int64Histogram with the given inclusive upper
+// bounds. The bounds must be sorted in increasing order.
+func NewInt64Histogram(bounds ...int64) *Int64Histogram {
+	return &Int64Histogram{
+		bounds: bounds,
+		counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe adds an observation to the histogram.
+func (h *Int64Histogram) Observe(v int64) {
+	i := sort.Search(len(h.bounds), func(i int) bool {
+		return v <= h.bounds[i]
+	})
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, v)
+}
+
+// Bounds returns the upper bounds of the buckets.
+func (h *Int64Histogram) Bounds() []int64 {
+	return h.bounds
+}
+
+// Read returns the cumulative counts of the buckets, the total number of
+// observations and the sum of the observed values. buckets[i] is the number
+// of observations that are less than or equal to Bounds()[i].
+func (h *Int64Histogram) Read() (buckets []int64, count, sum int64) {
+	buckets = make([]int64, len(h.bounds))
+	var cumulative int64
+	for i := range h.bounds {
+		cumulative += atomic.LoadInt64(&h.counts[i])
+		buckets[i] = cumulative
+	}
+	return buckets, atomic.LoadInt64(&h.count), atomic.LoadInt64(&h.sum)
+}
+
+// Reset sets zero to the buckets, the count and the sum.
+func (h *Int64Histogram) Reset() {
+	for i := range h.counts {
+		atomic.StoreInt64(&h.counts[i], 0)
+	}
+	atomic.StoreInt64(&h.count, 0)
+	atomic.StoreInt64(&h.sum, 0)
+}
@@ -62,3 +62,30 @@ func TestUint64Gauge(t *testing.T) {
 
 	require.Equal(t, int64(80), g.Read())
 }
+
+func TestInt64Histogram(t *testing.T) {
+	h := NewInt64Histogram(10, 100, 1000)
+
+	var wg sync.WaitGroup
+	for _, v := range []int64{1, 10, 11, 100, 500, 5000} {
+		wg.Add(1)
+		go func(v int64) {
+			defer wg.Done()
+			h.Observe(v)
+		}(v)
+	}
+
+	wg.Wait()
+
+	buckets, count, sum := h.Read()
+	require.Equal(t, []int64{10, 100, 1000}, h.Bounds())
+	require.Equal(t, []int64{2, 4, 5}, buckets)
+	require.Equal(t, int64(6), count)
+	require.Equal(t, int64(5622), sum)
+
+	h.Reset()
+	buckets, count, sum = h.Read()
+	require.Equal(t, []int64{0, 0, 0}, buckets)
+	require.Zero(t, count)
+	require.Zero(t, sum)
+}
@@ -127,6 +127,14 @@ func (t *TestCluster) AddMember(e *environment.Environment) service.Service {
 	}
 	c := e.Get("config").(*config.Config)
 	partitions.SetHashFunc(c.Hasher)
+	if c.HashSeed != 0 {
+		partitions.SetHashSeed(c.HashSeed)
+	}
+	if c.HashTags {
+		partitions.SetHashTags(c.PartitionCount)
+	} else {
+		partitions.SetHashTags(0)
+	}
 
 	port, err := testutil.GetFreePort()
 	if err != nil {
@@ -0,0 +1,146 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Reader reads the records of a log in the order of writing.
+type Reader struct {
+	dir      string
+	segments []uint64
+	file     *os.File
+	r        *bufio.Reader
+	name     string
+
+	// remaining is the number of unread bytes in the current segment.
+	remaining int64
+}
+
+// NewReader returns a Reader for the log in the directory.
+func NewReader(dir string) (*Reader, error) {
+	existing, err := segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{
+		dir:      dir,
+		segments: existing,
+	}, nil
+}
+
+func (r *Reader) openNext() error {
+	if r.file != nil {
+		if err := r.file.Close(); err != nil {
+			return err
+		}
+		r.file = nil
+	}
+	if len(r.segments) == 0 {
+		return io.EOF
+	}
+	r.name = filepath.Join(r.dir, segmentName(r.segments[0]))
+	r.segments = r.segments[1:]
+	file, err := os.Open(r.name)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	r.file = file
+	r.remaining = info.Size()
+	r.r = bufio.NewReader(file)
+	return nil
+}
+
+// readFrame reads the next record of the current segment. It returns io.EOF at the end
+// of the segment, a torn record at the end is skipped.
+func (r *Reader) readFrame() (*Record, error) {
+	var header [frameHeaderSize]byte
+	_, err := io.ReadFull(r.r, header[:])
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	r.remaining -= frameHeaderSize
+
+	length := int64(binary.BigEndian.Uint32(header[:]))
+	if length > r.remaining {
+		// The last record is torn, or the length is corrupted.
+		return nil, io.EOF
+	}
+	payload := make([]byte, length)
+	if _, err = io.ReadFull(r.r, payload); err != nil {
+		return nil, err
+	}
+	r.remaining -= length
+	if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:]) {
+		if r.remaining == 0 {
+			// The last record is torn.
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("%w: checksum mismatch in %s", ErrCorrupted, r.name)
+	}
+
+	record := &Record{}
+	if err = msgpack.Unmarshal(payload, record); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorrupted, r.name, err)
+	}
+	return record, nil
+}
+
+// Next returns the next record. It returns io.EOF if there are no more records.
+func (r *Reader) Next() (*Record, error) {
+	for {
+		if r.file == nil {
+			if err := r.openNext(); err != nil {
+				return nil, err
+			}
+		}
+		record, err := r.readFrame()
+		if errors.Is(err, io.EOF) {
+			if err = r.openNext(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		return record, err
+	}
+}
+
+// Close closes the current segment.
+func (r *Reader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
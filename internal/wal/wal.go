@@ -0,0 +1,305 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+/*
+Package wal implements an append-only write-ahead log of the DMap mutations.
+
+Every member appends the mutations that it applies as the partition owner, before
+applying them. The log is a sequence of segment files in a directory, a new segment
+is started when the current one reaches the maximum size and on every start. A record
+is framed with its length and a CRC32 checksum, so a torn write at the end of a segment
+is detected and skipped by the reader.
+
+Unlike the shutdown snapshots, the log is written while the member is running. It
+provides durability: the state can be reconstructed from the logs of the members after
+a crash, as far as the sync policy allows.
+*/
+package wal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Op is the type of a mutation.
+type Op uint8
+
+const (
+	// OpPut sets the value and the TTL of a key.
+	OpPut Op = iota + 1
+
+	// OpDelete deletes a key.
+	OpDelete
+
+	// OpExpire updates the TTL of a key.
+	OpExpire
+)
+
+func (o Op) String() string {
+	switch o {
+	case OpPut:
+		return "put"
+	case OpDelete:
+		return "delete"
+	case OpExpire:
+		return "expire"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(o))
+	}
+}
+
+// SyncPolicy decides when the log is synced to the disk.
+type SyncPolicy string
+
+const (
+	// SyncAlways syncs the log after every record. It's the most durable and the slowest policy.
+	SyncAlways SyncPolicy = "always"
+
+	// SyncInterval syncs the log periodically. A crash may lose the records of the last interval.
+	SyncInterval SyncPolicy = "interval"
+
+	// SyncNever leaves syncing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+const (
+	segmentPrefix = "wal-"
+	segmentSuffix = ".log"
+
+	// frameHeaderSize is the size of the length and the checksum of a record.
+	frameHeaderSize = 8
+)
+
+// ErrCorrupted is returned when a record in the middle of a segment cannot be read.
+var ErrCorrupted = errors.New("corrupted write-ahead log")
+
+// Record is a mutation in the log.
+type Record struct {
+	DMap string
+	Key  string
+	Op   Op
+
+	// Value is the value of a put.
+	Value []byte
+
+	// TTL is the expiry time in milliseconds since the epoch. Zero means no expiry.
+	TTL int64
+
+	// Timestamp is the time of the mutation in nanoseconds since the epoch.
+	Timestamp int64
+}
+
+// Config is the configuration of a WAL.
+type Config struct {
+	// Dir is the directory of the segment files. It should be unique per member.
+	Dir string
+
+	// MaxSegmentSize is the size of a segment file to start a new one.
+	MaxSegmentSize int64
+
+	// Sync is the sync policy.
+	Sync SyncPolicy
+
+	// SyncInterval is the interval between two syncs with SyncInterval.
+	SyncInterval time.Duration
+}
+
+// WAL is an append-only write-ahead log. It's safe for concurrent use.
+type WAL struct {
+	mtx     sync.Mutex
+	config  Config
+	file    *os.File
+	size    int64
+	segment uint64
+	dirty   bool
+	closed  bool
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+func segmentName(segment uint64) string {
+	return fmt.Sprintf("%s%020d%s", segmentPrefix, segment, segmentSuffix)
+}
+
+// segments returns the segment numbers in the directory in order.
+func segments(dir string) ([]uint64, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var res []uint64
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		var segment uint64
+		_, err = fmt.Sscanf(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), "%d", &segment)
+		if err != nil {
+			continue
+		}
+		res = append(res, segment)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i] < res[j] })
+	return res, nil
+}
+
+// Open creates the directory if it doesn't exist and starts a new segment after the
+// existing ones.
+func Open(c Config) (*WAL, error) {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return nil, err
+	}
+	existing, err := segments(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &WAL{
+		config: c,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	if len(existing) > 0 {
+		w.segment = existing[len(existing)-1]
+	}
+	if err = w.rotate(); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if c.Sync == SyncInterval {
+		w.wg.Add(1)
+		go w.syncPeriodically()
+	}
+	return w, nil
+}
+
+// rotate closes the current segment and starts a new one.
+func (w *WAL) rotate() error {
+	if w.file != nil {
+		if err := w.file.Sync(); err != nil {
+			return err
+		}
+		if err := w.file.Close(); err != nil {
+			return err
+		}
+	}
+	w.segment++
+	file, err := os.OpenFile(filepath.Join(w.config.Dir, segmentName(w.segment)), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	w.file = file
+	w.size = 0
+	w.dirty = false
+	return nil
+}
+
+// Append writes the record into the log. The record is on the disk when Append returns
+// if the sync policy is SyncAlways.
+func (w *WAL) Append(r *Record) error {
+	payload, err := msgpack.Marshal(r)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, frameHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	binary.BigEndian.PutUint32(frame[4:], crc32.ChecksumIEEE(payload))
+	copy(frame[frameHeaderSize:], payload)
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.size > 0 && w.size+int64(len(frame)) > w.config.MaxSegmentSize {
+		if err = w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(frame)
+	w.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if w.config.Sync == SyncAlways {
+		return w.file.Sync()
+	}
+	w.dirty = true
+	return nil
+}
+
+// Sync syncs the current segment to the disk.
+func (w *WAL) Sync() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed || !w.dirty {
+		return nil
+	}
+	w.dirty = false
+	return w.file.Sync()
+}
+
+func (w *WAL) syncPeriodically() {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			// The next tick tries again, the error is returned by Close at the latest.
+			_ = w.Sync()
+		case <-w.ctx.Done():
+			return
+		}
+	}
+}
+
+// Close syncs and closes the log.
+func (w *WAL) Close() error {
+	w.cancel()
+	w.wg.Wait()
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
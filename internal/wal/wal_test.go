@@ -0,0 +1,116 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package wal
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "olric-wal")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, os.RemoveAll(dir))
+	})
+	return dir
+}
+
+func readAll(t *testing.T, dir string) []*Record {
+	r, err := NewReader(dir)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, r.Close())
+	}()
+
+	var records []*Record
+	for {
+		record, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestWAL_AppendAndRead(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncAlways, SyncInterval, SyncNever} {
+		t.Run(string(policy), func(t *testing.T) {
+			dir := tempDir(t)
+			c := Config{
+				Dir:            dir,
+				MaxSegmentSize: 128,
+				Sync:           policy,
+				SyncInterval:   time.Millisecond,
+			}
+			w, err := Open(c)
+			require.NoError(t, err)
+
+			var expected []*Record
+			for i := 0; i < 10; i++ {
+				r := &Record{
+					DMap:      "mydmap",
+					Key:       "mykey",
+					Op:        OpPut,
+					Value:     []byte("value"),
+					Timestamp: time.Now().UnixNano(),
+				}
+				require.NoError(t, w.Append(r))
+				expected = append(expected, r)
+			}
+			require.NoError(t, w.Close())
+
+			// A new segment is started after the existing ones.
+			w, err = Open(c)
+			require.NoError(t, err)
+			r := &Record{DMap: "mydmap", Key: "mykey", Op: OpDelete, Timestamp: time.Now().UnixNano()}
+			require.NoError(t, w.Append(r))
+			expected = append(expected, r)
+			require.NoError(t, w.Close())
+
+			existing, err := segments(dir)
+			require.NoError(t, err)
+			require.Greater(t, len(existing), 2)
+
+			require.Equal(t, expected, readAll(t, dir))
+		})
+	}
+}
+
+func TestWAL_TornRecord(t *testing.T) {
+	dir := tempDir(t)
+	w, err := Open(Config{Dir: dir, MaxSegmentSize: 1 << 20, Sync: SyncNever})
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, w.Append(&Record{DMap: "mydmap", Key: "mykey", Op: OpPut, Value: []byte("value")}))
+	}
+	require.NoError(t, w.Close())
+
+	// Cut the last record in the middle.
+	name := filepath.Join(dir, segmentName(1))
+	info, err := os.Stat(name)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(name, info.Size()-3))
+
+	require.Len(t, readAll(t, dir), 1)
+}
@@ -94,6 +94,75 @@ var (
 	// ErrEntryTooLarge returned if the required space for an entry is bigger than table size.
 	ErrEntryTooLarge = errors.New("entry too large for the configured table size")
 
+	// ErrNotAnInteger is returned when an integer operation is called on a key whose value is not an integer.
+	ErrNotAnInteger = errors.New("value is not an integer")
+
+	// ErrInvalidFloat is returned when a float operation is called with NaN or Infinity, or would produce one.
+	ErrInvalidFloat = errors.New("value is NaN or Infinity")
+
+	// ErrScript is returned when a script cannot be compiled or fails at runtime.
+	ErrScript = errors.New("script error")
+
+	// ErrScriptTimeout is returned when a script runs longer than the allowed time.
+	ErrScriptTimeout = errors.New("script timed out")
+
+	// ErrCrossOwnerKeys is returned when the keys of a multi-key operation belong to different partition owners.
+	ErrCrossOwnerKeys = errors.New("keys belong to different partition owners")
+
+	// ErrScanTimeout is returned when a scan call exceeds config.DMaps.ScanTimeBudget.
+	ErrScanTimeout = errors.New("scan timed out")
+
+	// ErrNoAvailable is returned by ClaimNext when all entries are already claimed.
+	ErrNoAvailable = errors.New("no available entry")
+
+	// ErrReplicaSyncRateLimited is returned by SyncReplica when it's called more often
+	// than config.DMaps.ReplicaSyncInterval.
+	ErrReplicaSyncRateLimited = errors.New("replica sync is rate limited")
+
+	// ErrDecryption is returned when a stored value cannot be decrypted with the
+	// configured encryption key.
+	ErrDecryption = errors.New("failed to decrypt value")
+
+	// ErrTooManyDMaps is returned when a new DMap is requested and the number of DMaps
+	// in the cluster has reached config.DMaps.MaxDMaps.
+	ErrTooManyDMaps = errors.New("too many DMaps")
+
+	// ErrTimeout is returned when an operation exceeds the read or write timeout of the DMap.
+	ErrTimeout = errors.New("dmap operation timeout")
+
+	// ErrKeySchema is returned when a key doesn't match the key schema of the DMap.
+	ErrKeySchema = errors.New("key doesn't match the schema")
+
+	// ErrAccessCounterDisabled is returned by HotKeys when the access counter of the DMap is disabled.
+	ErrAccessCounterDisabled = errors.New("access counter is disabled")
+
+	// ErrNotAHash is returned when a hash operation is called on a key whose value is not a hash.
+	ErrNotAHash = errors.New("value is not a hash")
+
+	// ErrVersionMismatch is returned by PutWithVersion when the current version of the key
+	// is not equal to the expected version.
+	ErrVersionMismatch = errors.New("version mismatch")
+
+	// ErrNotAList is returned when a list operation is called on a key whose value is not a list.
+	ErrNotAList = errors.New("value is not a list")
+
+	// ErrNotAnObject is returned when a field operation is called on a key whose value is
+	// neither a hash nor a JSON object.
+	ErrNotAnObject = errors.New("value is not an object")
+
+	// ErrNoStandby is returned by PromoteStandby when the key has no standby value.
+	ErrNoStandby = errors.New("no standby value")
+
+	// ErrHashTagsDisabled is returned by GetByTag when the hash tags are not enabled.
+	ErrHashTagsDisabled = errors.New("hash tags are disabled")
+
+	// ErrNoSuchInstance is returned by the heartbeat of Register when the instance is
+	// expired or registered again somewhere else.
+	ErrNoSuchInstance = errors.New("no such instance")
+
+	// ErrNoLeader is returned by Leader when the election has no leader.
+	ErrNoLeader = errors.New("no leader")
+
 	// ErrConnRefused returned if the target node refused a connection request.
 	// It is good to call RefreshMetadata to update the underlying data structures.
 	ErrConnRefused = errors.New("connection refused")
@@ -134,24 +203,44 @@ type Olric struct {
 	started func()
 }
 
-func prepareConfig(c *config.Config) (*config.Config, error) {
+// bindEphemeralPort binds a port chosen by the operating system if BindPort is zero,
+// and sets BindPort to the chosen port. So the node advertises the actual port with
+// its name. The listener is handed over to the RESP server.
+func bindEphemeralPort(c *config.Config) (net.Listener, error) {
+	if c.BindPort != 0 {
+		return nil, nil
+	}
+	l, err := net.Listen("tcp", net.JoinHostPort(c.BindAddr, "0"))
+	if err != nil {
+		return nil, err
+	}
+	c.BindPort = l.Addr().(*net.TCPAddr).Port
+	return l, nil
+}
+
+func prepareConfig(c *config.Config) (*config.Config, net.Listener, error) {
 	if c == nil {
-		return nil, fmt.Errorf("config cannot be nil")
+		return nil, nil, fmt.Errorf("config cannot be nil")
 	}
 
 	err := c.Sanitize()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = c.Validate()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	err = c.SetupNetworkConfig()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	listener, err := bindEphemeralPort(c)
+	if err != nil {
+		return nil, nil, err
 	}
 	c.MemberlistConfig.Name = net.JoinHostPort(c.BindAddr,
 		strconv.Itoa(c.BindPort))
@@ -163,7 +252,7 @@ func prepareConfig(c *config.Config) (*config.Config, error) {
 	}
 	c.Logger.SetOutput(filter)
 
-	return c, nil
+	return c, listener, nil
 }
 
 func initializeServices(db *Olric) error {
@@ -190,8 +279,7 @@ func initializeServices(db *Olric) error {
 
 // New creates a new Olric instance, otherwise returns an error.
 func New(c *config.Config) (*Olric, error) {
-	var err error
-	c, err = prepareConfig(c)
+	c, listener, err := prepareConfig(c)
 	if err != nil {
 		return nil, err
 	}
@@ -201,6 +289,15 @@ func New(c *config.Config) (*Olric, error) {
 
 	// Set the hash function. Olric distributes keys over partitions by hashing.
 	partitions.SetHashFunc(c.Hasher)
+	if c.HashSeed != 0 {
+		// Otherwise, the seed is set after it's generated or learned from the cluster.
+		partitions.SetHashSeed(c.HashSeed)
+	}
+	if c.HashTags {
+		partitions.SetHashTags(c.PartitionCount)
+	} else {
+		partitions.SetHashTags(0)
+	}
 
 	flogger := flog.New(c.Logger)
 	flogger.SetLevel(c.LogVerbosity)
@@ -231,18 +328,28 @@ func New(c *config.Config) (*Olric, error) {
 
 	// Create a Redcon server instance
 	rc := &server.Config{
-		BindAddr:        c.BindAddr,
-		BindPort:        c.BindPort,
-		KeepAlivePeriod: c.KeepAlivePeriod,
+		BindAddr:                  c.BindAddr,
+		BindPort:                  c.BindPort,
+		KeepAlivePeriod:           c.KeepAlivePeriod,
+		TLSConfig:                 c.TLS,
+		LogErrorResponses:         c.LogErrorResponses,
+		ErrorResponseLogVerbosity: c.ErrorResponseLogVerbosity,
+		ErrorResponseLogRate:      c.ErrorResponseLogRate,
 	}
 	srv := server.New(rc, flogger)
 	srv.SetPreConditionFunc(db.preconditionFunc)
+	if listener != nil {
+		srv.SetListener(listener)
+	}
 
 	db.server = srv
 	e.Set("server", srv)
 
 	err = initializeServices(db)
 	if err != nil {
+		if listener != nil {
+			_ = listener.Close()
+		}
 		return nil, err
 	}
 
@@ -265,6 +372,7 @@ func (db *Olric) registerCommandHandlers() {
 	db.server.ServeMux().HandleFunc(protocol.Cluster.RoutingTable, db.clusterRoutingTableCommandHandler)
 	db.server.ServeMux().HandleFunc(protocol.Generic.Stats, db.statsCommandHandler)
 	db.server.ServeMux().HandleFunc(protocol.Cluster.Members, db.clusterMembersCommandHandler)
+	db.server.ServeMux().HandleFunc(protocol.Cluster.KeyPlacement, db.clusterKeyPlacementCommandHandler)
 }
 
 // callStartedCallback checks passed checkpoint count and calls the callback
@@ -313,6 +421,13 @@ func (db *Olric) isOperable() error {
 	return db.rt.CheckBootstrap()
 }
 
+// Address returns the address of this node in host:port format. It's the name of the
+// node in the cluster, and clients connect to it. If BindPort is zero, it contains the
+// port chosen by the operating system.
+func (db *Olric) Address() string {
+	return db.name
+}
+
 // Start starts background servers and joins the cluster. You still must call Shutdown
 // method if Start function returns an early error.
 func (db *Olric) Start() error {
@@ -411,6 +526,15 @@ func (db *Olric) Shutdown(ctx context.Context) error {
 
 	var latestError error
 
+	if db.config.DrainConnections {
+		// Let the in-flight commands complete before shutting down the services.
+		drainCtx, cancel := context.WithTimeout(ctx, db.config.LeaveTimeout)
+		if err := db.server.Drain(drainCtx); err != nil {
+			db.log.V(2).Printf("[WARN] Failed to drain the client connections in %v: %v", db.config.LeaveTimeout, err)
+		}
+		cancel()
+	}
+
 	if err := db.pubsub.Shutdown(ctx); err != nil {
 		db.log.V(2).Printf("[ERROR] Failed to shutdown PubSub service: %v", err)
 		latestError = err
@@ -477,7 +601,51 @@ func convertDMapError(err error) error {
 	case errors.Is(err, dmap.ErrKeyTooLarge):
 		return ErrKeyTooLarge
 	case errors.Is(err, dmap.ErrEntryTooLarge):
-		return ErrEntryTooLarge
+		// Keep the key and the required size.
+		return fmt.Errorf("%w%s", ErrEntryTooLarge, strings.TrimPrefix(err.Error(), dmap.ErrEntryTooLarge.Error()))
+	case errors.Is(err, dmap.ErrNotAnInteger):
+		return ErrNotAnInteger
+	case errors.Is(err, dmap.ErrInvalidFloat):
+		return ErrInvalidFloat
+	case errors.Is(err, dmap.ErrScript):
+		// Keep the details of the script error.
+		return fmt.Errorf("%w%s", ErrScript, strings.TrimPrefix(err.Error(), dmap.ErrScript.Error()))
+	case errors.Is(err, dmap.ErrScriptTimeout):
+		return ErrScriptTimeout
+	case errors.Is(err, dmap.ErrCrossOwnerKeys):
+		return ErrCrossOwnerKeys
+	case errors.Is(err, dmap.ErrScanTimeout):
+		return ErrScanTimeout
+	case errors.Is(err, dmap.ErrNoAvailable):
+		return ErrNoAvailable
+	case errors.Is(err, dmap.ErrReplicaSyncRateLimited):
+		return ErrReplicaSyncRateLimited
+	case errors.Is(err, dmap.ErrDecryption):
+		return ErrDecryption
+	case errors.Is(err, dmap.ErrTooManyDMaps):
+		return ErrTooManyDMaps
+	case errors.Is(err, dmap.ErrTimeout):
+		return ErrTimeout
+	case errors.Is(err, dmap.ErrKeySchema):
+		return ErrKeySchema
+	case errors.Is(err, dmap.ErrAccessCounterDisabled):
+		return ErrAccessCounterDisabled
+	case errors.Is(err, dmap.ErrNotAHash):
+		return ErrNotAHash
+	case errors.Is(err, dmap.ErrVersionMismatch):
+		return ErrVersionMismatch
+	case errors.Is(err, dmap.ErrNotAList):
+		return ErrNotAList
+	case errors.Is(err, dmap.ErrNotAnObject):
+		return ErrNotAnObject
+	case errors.Is(err, dmap.ErrNoStandby):
+		return ErrNoStandby
+	case errors.Is(err, dmap.ErrHashTagsDisabled):
+		return ErrHashTagsDisabled
+	case errors.Is(err, dmap.ErrNoSuchInstance):
+		return ErrNoSuchInstance
+	case errors.Is(err, dmap.ErrNoLeader):
+		return ErrNoLeader
 	default:
 		return convertClusterError(err)
 	}
@@ -17,6 +17,7 @@ package olric
 import (
 	"context"
 	"fmt"
+	"net"
 	"sync"
 	"testing"
 	"time"
@@ -32,18 +33,16 @@ import (
 // This function is intended for internal use. Please use testOlricCluster and its
 // methods to form a cluster in tests.
 func newTestOlricWithConfig(t *testing.T, c *config.Config) *Olric {
-	port, err := testutil.GetFreePort()
-	require.NoError(t, err)
-
 	if c.MemberlistConfig == nil {
 		c.MemberlistConfig = memberlist.DefaultLocalConfig()
 	}
 	c.MemberlistConfig.BindPort = 0
 
+	// Bind to ephemeral ports, the chosen port is advertised by the node's name.
 	c.BindAddr = "127.0.0.1"
-	c.BindPort = port
+	c.BindPort = 0
 
-	err = c.Sanitize()
+	err := c.Sanitize()
 	require.NoError(t, err)
 
 	err = c.Validate()
@@ -123,6 +122,48 @@ func TestOlric_StartAndShutdown(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestOlricCluster_EphemeralPort(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+	db1 := cluster.addMember(t)
+	db2 := cluster.addMember(t)
+
+	for _, db := range []*Olric{db1, db2} {
+		_, port, err := net.SplitHostPort(db.Address())
+		require.NoError(t, err)
+		require.NotEqual(t, "0", port)
+		require.Equal(t, db.rt.This().String(), db.Address())
+	}
+	require.NotEqual(t, db1.Address(), db2.Address())
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db1.Address()})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	members, err := c.Members(ctx)
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		require.NoError(t, dm.Put(ctx, testutil.ToKey(i), i))
+	}
+
+	// The keys are distributed over both nodes, read them from the other node.
+	dm2, err := db2.NewEmbeddedClient().NewDMap("mydmap")
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		gr, err := dm2.Get(ctx, testutil.ToKey(i))
+		require.NoError(t, err)
+		value, err := gr.Int()
+		require.NoError(t, err)
+		require.Equal(t, i, value)
+	}
+}
+
 func TestOlricCluster_StartAndShutdown(t *testing.T) {
 	cluster := newTestOlricCluster(t)
 	cluster.addMember(t)
@@ -16,6 +16,7 @@ package storage
 
 import (
 	"errors"
+	"fmt"
 	"log"
 )
 
@@ -26,6 +27,34 @@ var ErrKeyTooLarge = errors.New("key too large")
 // ErrEntryTooLarge returned if required space for an entry is bigger than table size.
 var ErrEntryTooLarge = errors.New("entry too large for the configured table size")
 
+// EntryTooLargeError is returned by Put and PutRaw if an entry can never fit in a table.
+// It matches ErrEntryTooLarge with errors.Is.
+type EntryTooLargeError struct {
+	// Key is the key of the entry. It's empty if the entry is written by PutRaw.
+	Key string
+
+	// HKey is the hash of the key.
+	HKey uint64
+
+	// Size is the required space for the entry in bytes.
+	Size uint64
+
+	// TableSize is the size of a table in bytes.
+	TableSize uint64
+}
+
+func (e *EntryTooLargeError) Error() string {
+	key := e.Key
+	if key == "" {
+		key = fmt.Sprintf("hkey %d", e.HKey)
+	}
+	return fmt.Sprintf("%s: %s requires %d bytes, table size is %d bytes", ErrEntryTooLarge, key, e.Size, e.TableSize)
+}
+
+func (e *EntryTooLargeError) Is(target error) bool {
+	return target == ErrEntryTooLarge
+}
+
 // ErrKeyNotFound is an error that indicates that the requested key could not be found in the DB.
 var ErrKeyNotFound = errors.New("key not found")
 
@@ -119,7 +148,9 @@ type Engine interface {
 
 	// Scan implements an iterator. The caller starts iterating from the cursor. "count" is the number of entries
 	// that will be returned during the iteration. Scan calls the function "f" on Entry items for every iteration.
-	//It returns the next cursor if everything is okay. Otherwise, it returns an error.
+	//It returns the next cursor if everything is okay. Otherwise, it returns an error. A full iteration returns
+	// the entries that exist during the whole iteration at least once, the entries that are modified in the
+	// meantime may be returned more than once or not at all.
 	Scan(cursor uint64, count int, f func(Entry) bool) (uint64, error)
 
 	// ScanRegexMatch is the same with the Scan method, but it supports regular expressions on keys.
@@ -52,3 +52,16 @@ type Entry interface {
 	// Decode decodes a byte slice into an Entry.
 	Decode([]byte)
 }
+
+// VersionedEntry is implemented by the entries that keep a version in their metadata.
+// The version is set by the writer and stored with the entry, it's zero for an entry
+// that is written without a version.
+type VersionedEntry interface {
+	Entry
+
+	// SetVersion sets the version of an entry.
+	SetVersion(int64)
+
+	// Version returns the version of an entry.
+	Version() int64
+}
@@ -0,0 +1,192 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+)
+
+// DefaultLatencyProbeInterval is the default value of LatencyProbeInterval. ClusterClient pings
+// the cluster members in this interval to measure the latency, if ReadFromFastestReplica is set.
+const DefaultLatencyProbeInterval = time.Second
+
+// latencySmoothingFactor is the weight of the latest sample in the exponentially weighted
+// moving average of the response times.
+const latencySmoothingFactor = 0.3
+
+// ReadStrategy denotes how ClusterClient selects a member to read a key.
+type ReadStrategy int
+
+const (
+	// ReadFromPrimary routes all reads to the primary owner of the key. This is the default strategy.
+	ReadFromPrimary ReadStrategy = iota
+
+	// ReadFromFastestReplica routes reads to the owner with the lowest response time, including the
+	// replica owners. It's useful in multi-region clusters. The replicas may serve stale data,
+	// especially in async replication mode. If the selected replica fails, the primary owner is queried.
+	ReadFromFastestReplica
+)
+
+type memberLatency struct {
+	ewma    time.Duration
+	healthy bool
+}
+
+// latencyTracker tracks an exponentially weighted moving average of the response time for every member.
+type latencyTracker struct {
+	mtx     sync.RWMutex
+	members map[string]*memberLatency
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		members: make(map[string]*memberLatency),
+	}
+}
+
+func (l *latencyTracker) update(addr string, rtt time.Duration) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	m, ok := l.members[addr]
+	if !ok {
+		l.members[addr] = &memberLatency{ewma: rtt, healthy: true}
+		return
+	}
+	m.ewma = time.Duration(latencySmoothingFactor*float64(rtt) + (1-latencySmoothingFactor)*float64(m.ewma))
+	m.healthy = true
+}
+
+func (l *latencyTracker) markUnhealthy(addr string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	m, ok := l.members[addr]
+	if !ok {
+		l.members[addr] = &memberLatency{}
+		return
+	}
+	m.healthy = false
+}
+
+func (l *latencyTracker) remove(addr string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	delete(l.members, addr)
+}
+
+// fastest returns the healthy member with the lowest average response time. Members without
+// any measurement are ignored. It returns false if there is no such member.
+func (l *latencyTracker) fastest(addrs []string) (string, bool) {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	var result string
+	var best *memberLatency
+	for _, addr := range addrs {
+		m, ok := l.members[addr]
+		if !ok || !m.healthy {
+			continue
+		}
+		if best == nil || m.ewma < best.ewma {
+			result, best = addr, m
+		}
+	}
+	return result, best != nil
+}
+
+// WithReadStrategy sets the strategy to select a member for the read requests. The default one is ReadFromPrimary.
+func WithReadStrategy(strategy ReadStrategy) ClusterClientOption {
+	return func(cfg *clusterClientConfig) {
+		cfg.readStrategy = strategy
+	}
+}
+
+// WithLatencyProbeInterval is used to set a custom value to latencyProbeInterval. It's only used by
+// ReadFromFastestReplica strategy.
+func WithLatencyProbeInterval(interval time.Duration) ClusterClientOption {
+	return func(cfg *clusterClientConfig) {
+		cfg.latencyProbeInterval = interval
+	}
+}
+
+func (cl *ClusterClient) probeLatency(addr string) {
+	ctx, cancel := context.WithTimeout(cl.ctx, cl.config.latencyProbeInterval)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cl.Ping(ctx, addr, "")
+	if err != nil {
+		cl.latency.markUnhealthy(addr)
+		return
+	}
+	cl.latency.update(addr, time.Since(start))
+}
+
+func (cl *ClusterClient) probeLatencies() {
+	var wg sync.WaitGroup
+	for addr := range cl.client.Addresses() {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			cl.probeLatency(addr)
+		}(addr)
+	}
+	wg.Wait()
+}
+
+func (cl *ClusterClient) probeLatenciesPeriodically() {
+	defer cl.wg.Done()
+
+	ticker := time.NewTicker(cl.config.latencyProbeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cl.ctx.Done():
+			return
+		case <-ticker.C:
+			cl.probeLatencies()
+		}
+	}
+}
+
+// pickForRead returns the address of the member that will serve a read request for the given key.
+// replica is true if the selected member is a replica owner.
+func (cl *ClusterClient) pickForRead(dmap, key string) (addr string, replica bool, err error) {
+	hkey := partitions.HKey(dmap, key)
+	partID := hkey % cl.partitionCount
+
+	route, err := cl.routeByPartID(partID)
+	if err != nil {
+		return "", false, err
+	}
+	primaryOwner := route.PrimaryOwners[len(route.PrimaryOwners)-1]
+	if cl.config.readStrategy != ReadFromFastestReplica || len(route.ReplicaOwners) == 0 {
+		return primaryOwner, false, nil
+	}
+
+	candidates := append([]string{primaryOwner}, route.ReplicaOwners...)
+	fastest, ok := cl.latency.fastest(candidates)
+	if !ok || fastest == primaryOwner {
+		return primaryOwner, false, nil
+	}
+	return fastest, true, nil
+}
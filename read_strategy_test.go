@@ -0,0 +1,158 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/buraksezer/olric/config"
+	"github.com/buraksezer/olric/internal/cluster/partitions"
+	"github.com/buraksezer/olric/internal/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadStrategy_latencyTracker(t *testing.T) {
+	l := newLatencyTracker()
+
+	_, ok := l.fastest([]string{"a", "b"})
+	require.False(t, ok)
+
+	l.update("a", 10*time.Millisecond)
+	l.update("b", 50*time.Millisecond)
+	addr, ok := l.fastest([]string{"a", "b"})
+	require.True(t, ok)
+	require.Equal(t, "a", addr)
+
+	// "a" becomes slower, the moving average follows it.
+	for i := 0; i < 10; i++ {
+		l.update("a", 100*time.Millisecond)
+	}
+	addr, ok = l.fastest([]string{"a", "b"})
+	require.True(t, ok)
+	require.Equal(t, "b", addr)
+
+	l.markUnhealthy("b")
+	addr, ok = l.fastest([]string{"a", "b"})
+	require.True(t, ok)
+	require.Equal(t, "a", addr)
+}
+
+func TestReadStrategy_ReadFromFastestReplica(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+
+	c1 := testutil.NewConfig()
+	c1.ReplicaCount = 2
+	db := cluster.addMemberWithConfig(t, c1)
+
+	c2 := testutil.NewConfig()
+	c2.ReplicaCount = 2
+	cluster.addMemberWithConfig(t, c2)
+
+	ctx := context.Background()
+	c, err := NewClusterClient(
+		[]string{db.name},
+		WithReadStrategy(ReadFromFastestReplica),
+		WithLatencyProbeInterval(time.Hour),
+	)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+	require.NoError(t, c.RefreshMetadata(ctx))
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = dm.Put(ctx, testutil.ToKey(i), testutil.ToVal(i))
+		require.NoError(t, err)
+	}
+
+	rt, err := c.RoutingTable(ctx)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+
+		// Without any measurement, the primary owner serves the reads.
+		c.latency = newLatencyTracker()
+		primary, replica, err := c.pickForRead("mydmap", key)
+		require.NoError(t, err)
+		require.False(t, replica)
+
+		route := rt[partitions.HKey("mydmap", key)%c.partitionCount]
+		require.NotEmpty(t, route.ReplicaOwners)
+		replicaOwner := route.ReplicaOwners[0]
+
+		// Simulate a slow primary owner.
+		c.latency.update(primary, 50*time.Millisecond)
+		c.latency.update(replicaOwner, time.Millisecond)
+
+		addr, replica, err := c.pickForRead("mydmap", key)
+		require.NoError(t, err)
+		require.True(t, replica)
+		require.Equal(t, replicaOwner, addr)
+
+		gr, err := dm.Get(ctx, key)
+		require.NoError(t, err)
+		value, err := gr.Byte()
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+}
+
+func TestReadStrategy_ReadFromReplica_Encryption(t *testing.T) {
+	cluster := newTestOlricCluster(t)
+
+	newConfig := func() *config.Config {
+		c := testutil.NewConfig()
+		c.ReplicaCount = 2
+		c.DMaps.EncryptionKey = []byte("0123456789abcdef0123456789abcdef")
+		return c
+	}
+	db := cluster.addMemberWithConfig(t, newConfig())
+	cluster.addMemberWithConfig(t, newConfig())
+
+	ctx := context.Background()
+	c, err := NewClusterClient([]string{db.name})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, c.Close(ctx))
+	}()
+
+	dm, err := c.NewDMap("mydmap")
+	require.NoError(t, err)
+
+	rt, err := c.RoutingTable(ctx)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		key := testutil.ToKey(i)
+		err = dm.Put(ctx, key, testutil.ToVal(i))
+		require.NoError(t, err)
+
+		route := rt[partitions.HKey("mydmap", key)%c.partitionCount]
+		require.NotEmpty(t, route.ReplicaOwners)
+
+		// The replica decrypts the value before sending it.
+		gr, err := dm.(*ClusterDMap).getFromReplica(ctx, route.ReplicaOwners[0], key)
+		require.NoError(t, err)
+		value, err := gr.Byte()
+		require.NoError(t, err)
+		require.Equal(t, testutil.ToVal(i), value)
+	}
+}
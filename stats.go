@@ -20,12 +20,15 @@ import (
 	"runtime"
 	"strings"
 
+	"github.com/buraksezer/olric/internal/cluster/balancer"
 	"github.com/buraksezer/olric/internal/cluster/partitions"
 	"github.com/buraksezer/olric/internal/discovery"
 	"github.com/buraksezer/olric/internal/dmap"
+	"github.com/buraksezer/olric/internal/kvstore"
 	"github.com/buraksezer/olric/internal/protocol"
 	"github.com/buraksezer/olric/internal/pubsub"
 	"github.com/buraksezer/olric/internal/server"
+	internalstats "github.com/buraksezer/olric/internal/stats"
 	"github.com/buraksezer/olric/stats"
 	"github.com/tidwall/redcon"
 )
@@ -38,6 +41,21 @@ func toMember(member discovery.Member) stats.Member {
 	}
 }
 
+func toHistogram(h *internalstats.Int64Histogram) stats.Histogram {
+	buckets, count, sum := h.Read()
+	res := stats.Histogram{
+		Count: count,
+		Sum:   sum,
+	}
+	for i, bound := range h.Bounds() {
+		res.Buckets = append(res.Buckets, stats.Bucket{
+			UpperBound: bound,
+			Count:      buckets[i],
+		})
+	}
+	return res
+}
+
 func toMembers(members []discovery.Member) []stats.Member {
 	var _stats []stats.Member
 	for _, m := range members {
@@ -101,12 +119,21 @@ func (db *Olric) stats(cfg statsConfig) stats.Stats {
 			CommandsTotal:      server.CommandsTotal.Read(),
 		},
 		DMaps: stats.DMaps{
-			EntriesTotal: dmap.EntriesTotal.Read(),
-			DeleteHits:   dmap.DeleteHits.Read(),
-			DeleteMisses: dmap.DeleteMisses.Read(),
-			GetMisses:    dmap.GetMisses.Read(),
-			GetHits:      dmap.GetHits.Read(),
-			EvictedTotal: dmap.EvictedTotal.Read(),
+			EntriesTotal:                 dmap.EntriesTotal.Read(),
+			DeleteHits:                   dmap.DeleteHits.Read(),
+			DeleteMisses:                 dmap.DeleteMisses.Read(),
+			GetMisses:                    dmap.GetMisses.Read(),
+			GetHits:                      dmap.GetHits.Read(),
+			EvictedTotal:                 dmap.EvictedTotal.Read(),
+			CompactionsByGarbageTotal:    kvstore.CompactionsByGarbageTotal.Read(),
+			CompactionsByTableCountTotal: kvstore.CompactionsByTableCountTotal.Read(),
+			CompactionsByTableAgeTotal:   kvstore.CompactionsByTableAgeTotal.Read(),
+			CompactionsWhenIdleTotal:     kvstore.CompactionsWhenIdleTotal.Read(),
+			CompactionsImmediateTotal:    dmap.CompactionsImmediateTotal.Read(),
+			CompactionsDeferredTotal:     dmap.CompactionsDeferredTotal.Read(),
+			KeySizes:                     toHistogram(dmap.KeySizes),
+			ValueSizes:                   toHistogram(dmap.ValueSizes),
+			Operations:                   make(map[string]stats.DMapOperations),
 		},
 		PubSub: stats.PubSub{
 			PublishedTotal:      pubsub.PublishedTotal.Read(),
@@ -115,6 +142,32 @@ func (db *Olric) stats(cfg statsConfig) stats.Stats {
 			CurrentPSubscribers: pubsub.CurrentPSubscribers.Read(),
 			PSubscribersTotal:   pubsub.PSubscribersTotal.Read(),
 		},
+		Balancer: stats.Balancer{
+			ActiveMoves: balancer.ActiveMoves.Read(),
+		},
+	}
+
+	dmap.DMapOperations.Range(func(label string, m *dmap.OperationMetrics) bool {
+		s.DMaps.Operations[label] = stats.DMapOperations{
+			GetTotal:      m.GetTotal.Read(),
+			PutTotal:      m.PutTotal.Read(),
+			DeleteTotal:   m.DeleteTotal.Read(),
+			GetLatency:    toHistogram(m.GetLatency),
+			PutLatency:    toHistogram(m.PutLatency),
+			DeleteLatency: toHistogram(m.DeleteLatency),
+		}
+		return true
+	})
+
+	for _, e := range db.dmap.SlowLog() {
+		s.DMaps.SlowLog = append(s.DMaps.SlowLog, stats.SlowLogEntry{
+			ID:                   e.ID,
+			Timestamp:            e.Timestamp,
+			DurationMicroseconds: e.Duration.Microseconds(),
+			DMap:                 e.DMap,
+			Operation:            e.Operation,
+			Keys:                 e.Keys,
+		})
 	}
 
 	if cfg.CollectRuntime {
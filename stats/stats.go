@@ -140,6 +140,110 @@ type DMaps struct {
 
 	// EvictedTotal is the number of entries removed from cache to free memory for new entries.
 	EvictedTotal int64 `json:"evicted_total"`
+
+	// CompactionsByGarbageTotal is the number of compaction steps triggered by the garbage ratio of a table.
+	CompactionsByGarbageTotal int64 `json:"compactions_by_garbage_total"`
+
+	// CompactionsByTableCountTotal is the number of compaction steps triggered by the maxTables limit.
+	CompactionsByTableCountTotal int64 `json:"compactions_by_table_count_total"`
+
+	// CompactionsByTableAgeTotal is the number of compaction steps run by the compacttables command.
+	CompactionsByTableAgeTotal int64 `json:"compactions_by_table_age_total"`
+
+	// CompactionsWhenIdleTotal is the number of compaction steps run while the member is idle.
+	CompactionsWhenIdleTotal int64 `json:"compactions_when_idle_total"`
+
+	// CompactionsImmediateTotal is the number of compaction steps run by the compaction worker.
+	CompactionsImmediateTotal int64 `json:"compactions_immediate_total"`
+
+	// CompactionsDeferredTotal is the number of compaction steps deferred to a low-traffic window and run in it.
+	CompactionsDeferredTotal int64 `json:"compactions_deferred_total"`
+
+	// KeySizes is the histogram of the key sizes in bytes observed on the put path.
+	KeySizes Histogram `json:"key_sizes"`
+
+	// ValueSizes is the histogram of the value sizes in bytes observed on the put path.
+	ValueSizes Histogram `json:"value_sizes"`
+
+	// Operations holds the operation metrics by the DMap label. The DMaps beyond the
+	// maxMetricLabels limit share the "other" label.
+	Operations map[string]DMapOperations `json:"operations"`
+
+	// SlowLog holds the operations on this member that took longer than the slow log
+	// threshold of their DMap, the newest one first.
+	SlowLog []SlowLogEntry `json:"slow_log"`
+}
+
+// SlowLogEntry is an operation that took longer than the slow log threshold of its DMap.
+type SlowLogEntry struct {
+	// ID is the unique and increasing identifier of the entry on the member.
+	ID int64 `json:"id"`
+
+	// Timestamp is the start time of the operation in nanoseconds.
+	Timestamp int64 `json:"timestamp"`
+
+	// DurationMicroseconds is the duration of the operation in microseconds.
+	DurationMicroseconds int64 `json:"duration_microseconds"`
+
+	// DMap is the name of the DMap.
+	DMap string `json:"dmap"`
+
+	// Operation is the command of the operation, like dm.get.
+	Operation string `json:"operation"`
+
+	// Keys are the keys of the operation.
+	Keys []string `json:"keys"`
+}
+
+// DMapOperations holds the operation metrics of a DMap label on a member. The operations
+// redirected to the partition owner are counted on both members.
+type DMapOperations struct {
+	// GetTotal is the number of Get operations.
+	GetTotal int64 `json:"get_total"`
+
+	// PutTotal is the number of Put operations.
+	PutTotal int64 `json:"put_total"`
+
+	// DeleteTotal is the number of Delete operations.
+	DeleteTotal int64 `json:"delete_total"`
+
+	// GetLatency is the histogram of the Get latencies in microseconds.
+	GetLatency Histogram `json:"get_latency"`
+
+	// PutLatency is the histogram of the Put latencies in microseconds.
+	PutLatency Histogram `json:"put_latency"`
+
+	// DeleteLatency is the histogram of the Delete latencies in microseconds.
+	DeleteLatency Histogram `json:"delete_latency"`
+}
+
+// Bucket is a bucket of a Histogram.
+type Bucket struct {
+	// UpperBound is the inclusive upper bound of the bucket.
+	UpperBound int64 `json:"upper_bound"`
+
+	// Count is the number of observations that are less than or equal to UpperBound.
+	Count int64 `json:"count"`
+}
+
+// Histogram is a cumulative histogram with the same layout as a Prometheus histogram.
+// The observations greater than the largest upper bound are only counted in Count,
+// it's the implicit +Inf bucket.
+type Histogram struct {
+	// Buckets is the list of the buckets, sorted by their upper bounds.
+	Buckets []Bucket `json:"buckets"`
+
+	// Count is the total number of observations.
+	Count int64 `json:"count"`
+
+	// Sum is the sum of the observed values.
+	Sum int64 `json:"sum"`
+}
+
+// Balancer holds statistics of the partition balancer.
+type Balancer struct {
+	// ActiveMoves is the number of fragment moves in progress on this member.
+	ActiveMoves int64 `json:"active_moves"`
 }
 
 // PubSub holds global Pub/Sub statistics.
@@ -197,4 +301,7 @@ type Stats struct {
 
 	// PubSub holds global Pub/Sub statistics.
 	PubSub PubSub `json:"pub_sub"`
+
+	// Balancer holds statistics of the partition balancer.
+	Balancer Balancer `json:"balancer"`
 }
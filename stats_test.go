@@ -276,4 +276,67 @@ func TestStats_DMap(t *testing.T) {
 		require.Greater(t, dmap.EvictedTotal.Read(), int64(0))
 		require.GreaterOrEqual(t, dmap.EntriesTotal.Read(), int64(10))
 	})
+
+	t.Run("DMap size histograms", func(t *testing.T) {
+		dmap.KeySizes.Reset()
+		dmap.ValueSizes.Reset()
+
+		for _, size := range []int{10, 100, 1000} {
+			key := fmt.Sprintf("key-%04d", size)
+			cmd := protocol.NewPut("mydmap", key, make([]byte, size)).Command(ctx)
+			err := rc.Process(ctx, cmd)
+			require.NoError(t, err)
+			require.NoError(t, cmd.Err())
+		}
+
+		s, err := db.NewEmbeddedClient().Stats(ctx, db.rt.This().String())
+		require.NoError(t, err)
+
+		// All keys are 8 bytes long.
+		require.Equal(t, int64(3), s.DMaps.KeySizes.Count)
+		require.Equal(t, int64(24), s.DMaps.KeySizes.Sum)
+		require.Equal(t, stats.Bucket{UpperBound: 8, Count: 3}, s.DMaps.KeySizes.Buckets[0])
+
+		require.Equal(t, int64(3), s.DMaps.ValueSizes.Count)
+		require.Equal(t, int64(1110), s.DMaps.ValueSizes.Sum)
+		expected := map[int64]int64{16: 1, 64: 1, 256: 2, 1 << 10: 3, 16 << 20: 3}
+		for _, bucket := range s.DMaps.ValueSizes.Buckets {
+			if count, ok := expected[bucket.UpperBound]; ok {
+				require.Equalf(t, count, bucket.Count, "bucket: %d", bucket.UpperBound)
+			}
+		}
+	})
+
+	t.Run("Per-DMap operation metrics", func(t *testing.T) {
+		for _, name := range []string{"orders", "users"} {
+			for i := 0; i < 10; i++ {
+				cmd := protocol.NewPut(name, fmt.Sprintf("mykey-%d", i), []byte("myvalue")).Command(ctx)
+				require.NoError(t, rc.Process(ctx, cmd))
+			}
+		}
+		for i := 0; i < 5; i++ {
+			cmd := protocol.NewGet("orders", fmt.Sprintf("mykey-%d", i)).Command(ctx)
+			require.NoError(t, rc.Process(ctx, cmd))
+		}
+		for i := 0; i < 3; i++ {
+			cmd := protocol.NewDel("users", fmt.Sprintf("mykey-%d", i)).Command(ctx)
+			require.NoError(t, rc.Process(ctx, cmd))
+		}
+
+		s, err := db.NewEmbeddedClient().Stats(ctx, db.rt.This().String())
+		require.NoError(t, err)
+
+		orders := s.DMaps.Operations["orders"]
+		require.Equal(t, int64(10), orders.PutTotal)
+		require.Equal(t, int64(5), orders.GetTotal)
+		require.Equal(t, int64(0), orders.DeleteTotal)
+		require.Equal(t, int64(10), orders.PutLatency.Count)
+		require.Equal(t, int64(5), orders.GetLatency.Count)
+
+		users := s.DMaps.Operations["users"]
+		require.Equal(t, int64(10), users.PutTotal)
+		require.Equal(t, int64(0), users.GetTotal)
+		require.Equal(t, int64(3), users.DeleteTotal)
+		require.Equal(t, int64(3), users.DeleteLatency.Count)
+	})
 }
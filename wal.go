@@ -0,0 +1,129 @@
+// Copyright 2018-2024 Burak Sezer
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package olric
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/buraksezer/olric/internal/wal"
+)
+
+// walStream is the next record of a write-ahead log.
+type walStream struct {
+	reader *wal.Reader
+	head   *wal.Record
+}
+
+func (s *walStream) advance() error {
+	record, err := s.reader.Next()
+	if errors.Is(err, io.EOF) {
+		s.head = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.head = record
+	return nil
+}
+
+// applyWALRecord applies a mutation of the write-ahead log. The keys that would have
+// expired by now are deleted.
+func applyWALRecord(ctx context.Context, dm DMap, record *wal.Record) error {
+	nowInMs := time.Now().UnixNano() / 1000000
+	expired := record.TTL != 0 && record.TTL <= nowInMs
+
+	var err error
+	switch {
+	case record.Op == wal.OpDelete || expired:
+		_, err = dm.Delete(ctx, record.Key)
+	case record.Op == wal.OpPut:
+		var options []PutOption
+		if record.TTL != 0 {
+			options = append(options, PXAT(time.Duration(record.TTL)*time.Millisecond))
+		}
+		err = dm.Put(ctx, record.Key, record.Value, options...)
+	case record.Op == wal.OpExpire && record.TTL == 0:
+		err = dm.Persist(ctx, record.Key)
+	case record.Op == wal.OpExpire:
+		err = dm.Expire(ctx, record.Key, time.Duration(record.TTL-nowInMs)*time.Millisecond)
+	}
+	if errors.Is(err, ErrKeyNotFound) {
+		// The key was expired or deleted when the mutation was applied.
+		return nil
+	}
+	return err
+}
+
+// replayWAL applies the mutations in the write-ahead logs of the members. The logs are
+// merged by the timestamps of the records.
+func replayWAL(ctx context.Context, c Client, dirs []string) (int, error) {
+	var streams []*walStream
+	defer func() {
+		for _, s := range streams {
+			_ = s.reader.Close()
+		}
+	}()
+	for _, dir := range dirs {
+		reader, err := wal.NewReader(dir)
+		if err != nil {
+			return 0, err
+		}
+		s := &walStream{reader: reader}
+		streams = append(streams, s)
+		if err = s.advance(); err != nil {
+			return 0, err
+		}
+	}
+
+	dmaps := make(map[string]DMap)
+	var count int
+	for {
+		var next *walStream
+		for _, s := range streams {
+			if s.head == nil {
+				continue
+			}
+			if next == nil || s.head.Timestamp < next.head.Timestamp {
+				next = s
+			}
+		}
+		if next == nil {
+			return count, nil
+		}
+
+		record := next.head
+		dm, ok := dmaps[record.DMap]
+		if !ok {
+			var err error
+			dm, err = c.NewDMap(record.DMap)
+			if err != nil {
+				return count, err
+			}
+			dmaps[record.DMap] = dm
+		}
+		if err := applyWALRecord(ctx, dm, record); err != nil {
+			return count, err
+		}
+		count++
+
+		if err := next.advance(); err != nil {
+			return count, err
+		}
+	}
+}